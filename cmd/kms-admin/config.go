@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"kms/internal/bootstrap"
+	"os"
+)
+
+// Validates the server's config (file and environment) without starting it, e.g. before a deploy
+func runConfig(args []string) {
+	if len(args) < 1 || args[0] != "check" {
+		usage()
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("config check", flag.ExitOnError)
+	var envFile string
+	fs.StringVar(&envFile, "env", ".env", "config file, environment variables override it")
+	fs.Parse(args[1:])
+
+	if _, err := bootstrap.LoadKmsConfig(envFile); err != nil {
+		// every problem on its own line
+		var joined interface{ Unwrap() []error }
+		if errors.As(err, &joined) {
+			for _, err := range joined.Unwrap() {
+				fmt.Fprintf(os.Stderr, "invalid config: %v\n", err)
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "invalid config: %v\n", err)
+		}
+		os.Exit(1)
+	}
+
+	fmt.Println("config ok")
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"kms/internal/bootstrap"
+	"kms/internal/escrow"
+	dbEncr "kms/internal/storage/encryption"
+	"kms/internal/storage/postgres"
+	"kms/pkg/encryption"
+	"os"
+)
+
+func runEscrowKeygen(args []string) {
+	fs := flag.NewFlagSet("escrow_keygen", flag.ExitOnError)
+	var (
+		alg string
+		out string
+	)
+	fs.StringVar(&alg, "alg", encryption.WrapAlgRSAOAEP256, "wrapping algorithm ("+encryption.WrapAlgRSAOAEP256+" | "+encryption.WrapAlgECDHP256+")")
+	fs.StringVar(&out, "out", "", "private key file to write, public key is written to <out>.pub")
+	fs.Parse(args)
+
+	if out == "" {
+		fmt.Fprintln(os.Stderr, "error: --out is required")
+		usage()
+		os.Exit(2)
+	}
+
+	key, err := encryption.GenerateWrappingKey(alg)
+	exitOnError(err)
+
+	privateKey, err := key.PrivateKeyDER()
+	exitOnError(err)
+	publicKey, err := key.PublicKeyDER()
+	exitOnError(err)
+
+	// keep the private key offline, it can unwrap every exported key
+	err = os.WriteFile(out, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateKey}), 0600)
+	exitOnError(err)
+	err = os.WriteFile(out+".pub", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey}), 0644)
+	exitOnError(err)
+
+	fmt.Printf("generated %s escrow key, fingerprint: %s\n", alg, escrow.Fingerprint(publicKey))
+}
+
+func runEscrowVerify(args []string) {
+	fs := flag.NewFlagSet("escrow_verify", flag.ExitOnError)
+	var path string
+	fs.StringVar(&path, "file", "", "escrow file")
+	fs.Parse(args)
+
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "error: --file is required")
+		usage()
+		os.Exit(2)
+	}
+
+	file := readEscrowFile(path)
+	if err := file.Verify(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid escrow file: %v\n", err)
+		os.Exit(1)
+	}
+
+	// the labels are only checked when the keys are unwrapped, by escrow_import
+	fmt.Printf("escrow file is intact: %d keys of client '%s', wrapped with %s for %s\n", len(file.Keys), file.Clientname, file.Algorithm, file.Recipient)
+}
+
+func runEscrowImport(args []string) {
+	fs := flag.NewFlagSet("escrow_import", flag.ExitOnError)
+	var (
+		path           string
+		privateKeyFile string
+		clientId       int
+	)
+	fs.StringVar(&path, "file", "", "escrow file")
+	fs.StringVar(&privateKeyFile, "private-key", "", "PEM encoded private key the file was exported for")
+	fs.IntVar(&clientId, "client-id", 0, "client to import the keys for (default: client with the file's clientname)")
+	fs.Parse(args)
+
+	if path == "" || privateKeyFile == "" {
+		fmt.Fprintln(os.Stderr, "error: --file and --private-key are required")
+		usage()
+		os.Exit(2)
+	}
+
+	file := readEscrowFile(path)
+
+	pemBytes, err := os.ReadFile(privateKeyFile)
+	exitOnError(err)
+	block, _ := pem.Decode(pemBytes)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		fmt.Fprintln(os.Stderr, "error: --private-key must be a PEM encoded private key")
+		os.Exit(2)
+	}
+	operatorKey, err := encryption.ParseWrappingKey(file.Algorithm, block.Bytes)
+	exitOnError(err)
+
+	cfg, err := bootstrap.LoadKmsConfig(".env")
+	exitOnError(err)
+
+	keyManager, err := bootstrap.InitStaticKeyManager(cfg)
+	exitOnError(err)
+
+	logger, err := bootstrap.InitConsoleLogger(cfg.Log.Level)
+	exitOnError(err)
+
+	db, err := bootstrap.ConnectDatabase(cfg)
+	exitOnError(err)
+	defer db.Close()
+
+	keyRepo := dbEncr.NewEncryptedKeyRepo(postgres.NewPostgresKeyRepo(db), keyManager)
+	clientRepo := dbEncr.NewEncryptedClientRepo(postgres.NewPostgresClientRepo(db), keyManager)
+
+	service := escrow.NewService(keyRepo, clientRepo, keyManager, logger)
+	count, appErr := service.Import(context.Background(), file, operatorKey, clientId)
+	if appErr != nil {
+		fmt.Fprintf(os.Stderr, "import failed: %s (%v)\n", appErr.Message, appErr.Err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("imported %d keys of client '%s'\n", count, file.Clientname)
+}
+
+func readEscrowFile(path string) *escrow.File {
+	fileBytes, err := os.ReadFile(path)
+	exitOnError(err)
+
+	var file escrow.File
+	if err := json.Unmarshal(fileBytes, &file); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid escrow file: %v\n", err)
+		os.Exit(1)
+	}
+	return &file
+}
+
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unexpected error: %v\n", err)
+		os.Exit(1)
+	}
+}
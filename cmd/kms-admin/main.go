@@ -4,9 +4,9 @@ import (
 	"encoding/base64"
 	"flag"
 	"fmt"
-	"kms/internal/admin"
 	"kms/internal/auth"
 	"kms/internal/bootstrap"
+	"kms/internal/clients"
 	"kms/pkg/encryption"
 	"os"
 )
@@ -21,6 +21,16 @@ func main() {
 		runGenerateSignup(os.Args[2:])
 	case "generate_bytes":
 		runGenerateBytes(os.Args[2:])
+	case "escrow_keygen":
+		runEscrowKeygen(os.Args[2:])
+	case "escrow_verify":
+		runEscrowVerify(os.Args[2:])
+	case "escrow_import":
+		runEscrowImport(os.Args[2:])
+	case "migrate_encryption_context":
+		runMigrateEncryptionContext(os.Args[2:])
+	case "config":
+		runConfig(os.Args[2:])
 	default:
 		usage()
 		os.Exit(2)
@@ -31,6 +41,11 @@ func usage() {
 	fmt.Fprintln(os.Stderr, `kms-admin commands:
 		generate_signup --name <client name> [--ttl <token ttl in ms>]
 		generate_bytes [--n <number of bytes>]
+		escrow_keygen --out <private key file> [--alg <RSA-OAEP-256 | ECDH-P256>]
+		escrow_verify --file <escrow file>
+		escrow_import --file <escrow file> --private-key <private key file> [--client-id <id>]
+		migrate_encryption_context
+		config check [--env <config file>]
 	`)
 }
 
@@ -50,18 +65,12 @@ func runGenerateSignup(args []string) {
 		os.Exit(2)
 	}
 
-	if err := admin.ValidateClientname(name); err != nil {
+	if err := clients.ValidateClientname(name); err != nil {
 		fmt.Fprintf(os.Stderr, "invalid name: %v\n", err)
 		os.Exit(1)
 	}
 
-	cfg, err := bootstrap.LoadConfig(".env")
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "unexpected error: %v\n", err)
-		os.Exit(1)
-	}
-
-	secret, err := base64.RawURLEncoding.DecodeString(cfg["SIGNUP_SECRET"])
+	cfg, err := bootstrap.LoadKmsConfig(".env")
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "unexpected error: %v\n", err)
 		os.Exit(1)
@@ -69,7 +78,7 @@ func runGenerateSignup(args []string) {
 
 	genInfo := &auth.TokenGenInfo{
 		Ttl:    ttl,
-		Secret: secret,
+		Secret: cfg.Keys.SignupSecret,
 		Typ:    "signup",
 	}
 
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"kms/internal/bootstrap"
+	"kms/internal/storage/postgres"
+)
+
+// The server does this once at startup too, this runs it without starting the server (e.g. to see how many rows are affected).
+// Ciphertexts are now bound to their row and can't be decrypted without re-encrypting them first.
+func runMigrateEncryptionContext(args []string) {
+	fs := flag.NewFlagSet("migrate_encryption_context", flag.ExitOnError)
+	fs.Parse(args)
+
+	cfg, err := bootstrap.LoadKmsConfig(".env")
+	exitOnError(err)
+
+	keyManager, err := bootstrap.InitStaticKeyManager(cfg)
+	exitOnError(err)
+
+	db, err := bootstrap.ConnectDatabase(cfg)
+	exitOnError(err)
+	defer db.Close()
+
+	count, ran, err := postgres.MigrateEncryptionContext(db, keyManager)
+	exitOnError(err)
+
+	if !ran {
+		fmt.Println("encryption context migration already applied, nothing to do")
+		return
+	}
+	fmt.Printf("re-encrypted %d rows with their encryption context\n", count)
+}
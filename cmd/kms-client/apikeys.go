@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"kms/internal/apikeys"
+	"kms/internal/bootstrap"
+	"kms/pkg/cli"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Without --client-id the key is created for the logged in client, with it (admin only) for that client
+func runCreateApiKey(args []string) {
+	fs := flag.NewFlagSet("create-api-key", flag.ExitOnError)
+	var (
+		name     string
+		scopes   string
+		ttlDays  int
+		clientId int
+	)
+	fs.StringVar(&name, "name", "", "name of the API key, e.g. the deployment using it")
+	fs.StringVar(&scopes, "scopes", "", "comma separated scopes (keys:read, keys:write, admin)")
+	fs.IntVar(&ttlDays, "ttl-days", 0, "days until the key expires, 0 for no expiry")
+	fs.IntVar(&clientId, "client-id", 0, "id of the client (admin only)")
+	fs.Parse(args)
+
+	body := &apikeys.CreateApiKeyRequest{
+		Name:    name,
+		TtlDays: ttlDays,
+	}
+	if scopes != "" {
+		body.Scopes = strings.Split(scopes, ",")
+	}
+	if err := body.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		usage()
+		os.Exit(2)
+	}
+
+	// load config
+	cfg, err := bootstrap.LoadClientConfig(".env")
+	cli.HandleUnexpectedError(err)
+
+	// allow self-signed cert in dev mode
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.Env == "dev" {
+		tlsCfg.InsecureSkipVerify = true
+	}
+	tr := &http.Transport{
+		TLSClientConfig: tlsCfg,
+	}
+
+	client := &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: tr,
+	}
+
+	// login
+	token, err := login(cfg, client)
+	cli.HandleUnexpectedError(err)
+
+	// create API key
+	path := "/auth/api-keys/actions/create"
+	if clientId > 0 {
+		path = fmt.Sprintf("/clients/%d/api-keys/actions/create", clientId)
+	}
+	var response apikeys.CreateApiKeyResponse
+	postImportRequest(cfg, client, token, path, body, &response)
+
+	fmt.Printf("API key %d created for client %d (scopes: %s)\n", response.ID, response.ClientId, strings.Join(response.Scopes, ","))
+	if response.ExpiresAt != nil {
+		fmt.Printf("Expires at %s\n", response.ExpiresAt.Format(time.RFC3339))
+	}
+	fmt.Println("Store the key now, it can't be retrieved again:")
+	fmt.Println(response.Key)
+}
+
+func runRevokeApiKey(args []string) {
+	fs := flag.NewFlagSet("revoke-api-key", flag.ExitOnError)
+	var (
+		keyId    int
+		clientId int
+	)
+	fs.IntVar(&keyId, "id", 0, "id of the API key")
+	fs.IntVar(&clientId, "client-id", 0, "id of the client (admin only)")
+	fs.Parse(args)
+
+	if keyId <= 0 {
+		fmt.Fprintln(os.Stderr, "error: --id is required")
+		usage()
+		os.Exit(2)
+	}
+
+	// load config
+	cfg, err := bootstrap.LoadClientConfig(".env")
+	cli.HandleUnexpectedError(err)
+
+	// allow self-signed cert in dev mode
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.Env == "dev" {
+		tlsCfg.InsecureSkipVerify = true
+	}
+	tr := &http.Transport{
+		TLSClientConfig: tlsCfg,
+	}
+
+	client := &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: tr,
+	}
+
+	// login
+	token, err := login(cfg, client)
+	cli.HandleUnexpectedError(err)
+
+	// revoke API key
+	path := fmt.Sprintf("/auth/api-keys/%d", keyId)
+	if clientId > 0 {
+		path = fmt.Sprintf("/clients/%d/api-keys/%d", clientId, keyId)
+	}
+	req, err := http.NewRequest("DELETE", cfg.URL(path), nil)
+	cli.HandleUnexpectedError(err)
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	cli.HandleFailedRequest(err)
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		cli.HandleErrorResponse(resp)
+	}
+
+	fmt.Printf("API key %d revoked\n", keyId)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"kms/internal/admin"
+	"kms/internal/auth"
+	"kms/internal/bootstrap"
+	"kms/internal/clients"
+	"kms/pkg/cli"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Admin only, lets a client log in with a certificate (mTLS) for the identity
+func runBindCert(args []string) {
+	fs := flag.NewFlagSet("bind-cert", flag.ExitOnError)
+	var (
+		clientId int
+		identity string
+		pinCert  string
+	)
+	fs.IntVar(&clientId, "client-id", 0, "id of the client")
+	fs.StringVar(&identity, "identity", "", "certificate identity (uri:<SAN URI> | dns:<SAN DNS name> | email:<SAN email> | cn:<subject common name>)")
+	fs.StringVar(&pinCert, "pin-cert", "", "PEM certificate whose public key is pinned (optional)")
+	fs.Parse(args)
+
+	if clientId <= 0 || identity == "" {
+		fmt.Fprintln(os.Stderr, "error: --client-id and --identity are required")
+		usage()
+		os.Exit(2)
+	}
+
+	bindRequest := &admin.BindCertificateRequest{
+		Identity: identity,
+	}
+	if pinCert != "" {
+		pemBytes, err := os.ReadFile(pinCert)
+		cli.HandleUnexpectedError(err)
+
+		block, _ := pem.Decode(pemBytes)
+		if block == nil || block.Type != "CERTIFICATE" {
+			fmt.Fprintln(os.Stderr, "error: --pin-cert must be a PEM encoded certificate")
+			os.Exit(2)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		cli.HandleUnexpectedError(err)
+
+		bindRequest.Pin = auth.CertificatePin(cert)
+	}
+	if err := bindRequest.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
+
+	// load config
+	cfg, err := bootstrap.LoadClientConfig(".env")
+	cli.HandleUnexpectedError(err)
+
+	// allow self-signed cert in dev mode
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.Env == "dev" {
+		tlsCfg.InsecureSkipVerify = true
+	}
+	tr := &http.Transport{
+		TLSClientConfig: tlsCfg,
+	}
+
+	client := &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: tr,
+	}
+
+	// login
+	token, err := login(cfg, client)
+	cli.HandleUnexpectedError(err)
+
+	// bind certificate
+	var cert clients.Certificate
+	postImportRequest(cfg, client, token, fmt.Sprintf("/clients/%d/certificates/actions/bind", clientId), bindRequest, &cert)
+
+	fmt.Printf("Certificate identity bound to client %d with id %d (pinned: %v)\n", clientId, cert.ID, cert.Pin != "")
+}
+
+func runUnbindCert(args []string) {
+	fs := flag.NewFlagSet("unbind-cert", flag.ExitOnError)
+	var (
+		clientId int
+		certId   int
+	)
+	fs.IntVar(&clientId, "client-id", 0, "id of the client")
+	fs.IntVar(&certId, "id", 0, "id of the certificate binding")
+	fs.Parse(args)
+
+	if clientId <= 0 || certId <= 0 {
+		fmt.Fprintln(os.Stderr, "error: --client-id and --id are required")
+		usage()
+		os.Exit(2)
+	}
+
+	// load config
+	cfg, err := bootstrap.LoadClientConfig(".env")
+	cli.HandleUnexpectedError(err)
+
+	// allow self-signed cert in dev mode
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.Env == "dev" {
+		tlsCfg.InsecureSkipVerify = true
+	}
+	tr := &http.Transport{
+		TLSClientConfig: tlsCfg,
+	}
+
+	client := &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: tr,
+	}
+
+	// login
+	token, err := login(cfg, client)
+	cli.HandleUnexpectedError(err)
+
+	// unbind certificate
+	req, err := http.NewRequest("DELETE", cfg.URL(fmt.Sprintf("/clients/%d/certificates/%d", clientId, certId)), nil)
+	cli.HandleUnexpectedError(err)
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	cli.HandleFailedRequest(err)
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		cli.HandleErrorResponse(resp)
+	}
+
+	fmt.Printf("Certificate %d unbound from client %d\n", certId, clientId)
+}
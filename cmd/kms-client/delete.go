@@ -1,12 +1,13 @@
 package main
 
 import (
-	"bytes"
 	"crypto/tls"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"kms/internal/bootstrap"
+	"kms/internal/keys"
 	"kms/pkg/cli"
 	"net/http"
 	"os"
@@ -28,12 +29,12 @@ func runDelete(args []string) {
 	}
 
 	// load config
-	cfg, err := bootstrap.LoadConfig(".env")
+	cfg, err := bootstrap.LoadClientConfig(".env")
 	cli.HandleUnexpectedError(err)
 
 	// allow self-signed cert in dev mode
 	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
-	if cfg["ENV"] == "dev" {
+	if cfg.Env == "dev" {
 		tlsCfg.InsecureSkipVerify = true
 	}
 	tr := &http.Transport{
@@ -50,7 +51,7 @@ func runDelete(args []string) {
 	cli.HandleUnexpectedError(err)
 
 	// delete key
-	req, err := http.NewRequest("DELETE", fmt.Sprintf("https://%s:%s/keys/%s/actions/delete", cfg["SERVER_HOST"], cfg["SERVER_PORT"], ref), nil)
+	req, err := http.NewRequest("DELETE", cfg.URL(fmt.Sprintf("/keys/%s/actions/delete", ref)), nil)
 	cli.HandleUnexpectedError(err)
 
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -60,14 +61,14 @@ func runDelete(args []string) {
 
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 204 {
-		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-		if len(bytes.TrimSpace(body)) == 0 {
-			body = []byte(resp.Status) // fallback if no body
-		}
-		fmt.Fprintf(os.Stderr, "server error (%d): %s\n", resp.StatusCode, body)
-		os.Exit(1)
+	if resp.StatusCode != http.StatusOK {
+		cli.HandleErrorResponse(resp)
 	}
 
-	fmt.Printf("Key with reference '%s' deleted successfully\n", ref)
+	var deletion keys.KeyDeletionResponse
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&deletion)
+	cli.HandleUnexpectedError(err)
+
+	fmt.Printf("Key with reference '%s' scheduled for deletion on %s\n", ref, deletion.DeleteAfter.Local().Format(time.RFC1123))
+	fmt.Printf("Run 'restore --ref %s' before then to cancel the deletion\n", ref)
 }
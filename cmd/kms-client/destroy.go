@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"kms/internal/bootstrap"
+	"kms/pkg/cli"
+	"net/http"
+	"os"
+	"time"
+)
+
+func runDestroy(args []string) {
+	fs := flag.NewFlagSet("destroy", flag.ExitOnError)
+	var (
+		ref     string
+		version int
+	)
+	fs.StringVar(&ref, "ref", "", "key reference")
+	fs.IntVar(&version, "version", 0, "key version to destroy")
+	fs.Parse(args)
+
+	if ref == "" || version <= 0 {
+		fmt.Fprintln(os.Stderr, "error: --ref and --version are required")
+		usage()
+		os.Exit(2)
+	}
+
+	// load config
+	cfg, err := bootstrap.LoadClientConfig(".env")
+	cli.HandleUnexpectedError(err)
+
+	// allow self-signed cert in dev mode
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.Env == "dev" {
+		tlsCfg.InsecureSkipVerify = true
+	}
+	tr := &http.Transport{
+		TLSClientConfig: tlsCfg,
+	}
+
+	client := &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: tr,
+	}
+
+	// login
+	token, err := login(cfg, client)
+	cli.HandleUnexpectedError(err)
+
+	// destroy key version
+	req, err := http.NewRequest("DELETE", cfg.URL(fmt.Sprintf("/keys/%s/%d/actions/destroy", ref, version)), nil)
+	cli.HandleUnexpectedError(err)
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	cli.HandleFailedRequest(err)
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		cli.HandleErrorResponse(resp)
+	}
+
+	fmt.Printf("Version %d of key with reference '%s' destroyed successfully\n", version, ref)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	b64 "encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"kms/internal/bootstrap"
+	"kms/internal/keys"
+	"kms/pkg/cli"
+	"kms/pkg/encryption"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Encrypts a file of any size with the latest version of the key, see docs/stream.md for the format
+func runEncryptFile(args []string) {
+	fs := flag.NewFlagSet("encrypt-file", flag.ExitOnError)
+	var (
+		ref         string
+		in          string
+		out         string
+		segmentSize int
+	)
+	fs.StringVar(&ref, "ref", "", "key reference")
+	fs.StringVar(&in, "in", "", "file to encrypt")
+	fs.StringVar(&out, "out", "", "encrypted file to write")
+	fs.IntVar(&segmentSize, "segment-size", encryption.DefaultSegmentSize, "plaintext bytes per segment")
+	fs.Parse(args)
+
+	if ref == "" || in == "" || out == "" {
+		fmt.Fprintln(os.Stderr, "error: --ref, --in and --out are required")
+		usage()
+		os.Exit(2)
+	}
+
+	key := fetchKey(ref, "latest").EncryptWith
+	DEKBytes, err := b64.RawURLEncoding.DecodeString(key.DEK)
+	cli.HandleUnexpectedError(err)
+
+	src, err := os.Open(in)
+	cli.HandleUnexpectedError(err)
+	defer src.Close()
+
+	writeFile(out, func(dst io.Writer) error {
+		w, err := encryption.NewStreamWriter(dst, DEKBytes, key.Algorithm, ref, key.Version, segmentSize)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, src); err != nil {
+			return err
+		}
+		return w.Close()
+	})
+
+	fmt.Printf("%s encrypted to %s with version %d of '%s'\n", in, out, key.Version, ref)
+}
+
+// Fetches the key version from the file's header
+func runDecryptFile(args []string) {
+	fs := flag.NewFlagSet("decrypt-file", flag.ExitOnError)
+	var (
+		in  string
+		out string
+	)
+	fs.StringVar(&in, "in", "", "file to decrypt")
+	fs.StringVar(&out, "out", "", "decrypted file to write")
+	fs.Parse(args)
+
+	if in == "" || out == "" {
+		fmt.Fprintln(os.Stderr, "error: --in and --out are required")
+		usage()
+		os.Exit(2)
+	}
+
+	src, err := os.Open(in)
+	cli.HandleUnexpectedError(err)
+	defer src.Close()
+
+	r := bufio.NewReader(src)
+	header, err := encryption.ReadStreamHeader(r)
+	cli.HandleUnexpectedError(err)
+
+	key := fetchKey(header.KeyReference, strconv.Itoa(header.KeyVersion)).DecryptWith
+	DEKBytes, err := b64.RawURLEncoding.DecodeString(key.DEK)
+	cli.HandleUnexpectedError(err)
+
+	writeFile(out, func(dst io.Writer) error {
+		sr, err := encryption.NewStreamReader(r, DEKBytes, header)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(dst, sr)
+		return err
+	})
+
+	fmt.Printf("%s decrypted to %s with version %d of '%s'\n", in, out, header.KeyVersion, header.KeyReference)
+}
+
+// Removes the file again if writing fails, so no partial (or unauthenticated) output is left behind
+func writeFile(path string, write func(io.Writer) error) {
+	dst, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	cli.HandleUnexpectedError(err)
+
+	bw := bufio.NewWriter(dst)
+	err = write(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		cli.HandleUnexpectedError(err)
+	}
+}
+
+func fetchKey(ref, version string) *keys.KeyLookupResponse {
+	// load config
+	cfg, err := bootstrap.LoadClientConfig(".env")
+	cli.HandleUnexpectedError(err)
+
+	// allow self-signed cert in dev mode
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.Env == "dev" {
+		tlsCfg.InsecureSkipVerify = true
+	}
+	tr := &http.Transport{
+		TLSClientConfig: tlsCfg,
+	}
+
+	client := &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: tr,
+	}
+
+	// login
+	token, err := login(cfg, client)
+	cli.HandleUnexpectedError(err)
+
+	req, err := http.NewRequest("GET", cfg.URL(fmt.Sprintf("/keys/%s/%s", ref, version)), nil)
+	cli.HandleUnexpectedError(err)
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	cli.HandleFailedRequest(err)
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		cli.HandleErrorResponse(resp)
+	}
+
+	var lookup keys.KeyLookupResponse
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&lookup)
+	cli.HandleUnexpectedError(err)
+
+	return &lookup
+}
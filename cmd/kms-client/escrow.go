@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/tls"
+	b64 "encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"kms/internal/bootstrap"
+	"kms/internal/escrow"
+	"kms/pkg/cli"
+	"kms/pkg/encryption"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Admin only, keys are wrapped for the public key created with 'kms-admin escrow_keygen'
+func runEscrowExport(args []string) {
+	fs := flag.NewFlagSet("escrow-export", flag.ExitOnError)
+	var (
+		clientId      int
+		refs          string
+		publicKeyFile string
+		alg           string
+		out           string
+	)
+	fs.IntVar(&clientId, "client-id", 0, "id of the client whose keys are exported")
+	fs.StringVar(&refs, "refs", "", "comma separated key references (default: all keys)")
+	fs.StringVar(&publicKeyFile, "public-key", "", "PEM encoded public key of the escrow operator")
+	fs.StringVar(&alg, "alg", encryption.WrapAlgRSAOAEP256, "wrapping algorithm ("+encryption.WrapAlgRSAOAEP256+" | "+encryption.WrapAlgECDHP256+")")
+	fs.StringVar(&out, "out", "", "escrow file to write")
+	fs.Parse(args)
+
+	if clientId <= 0 || publicKeyFile == "" || out == "" {
+		fmt.Fprintln(os.Stderr, "error: --client-id, --public-key and --out are required")
+		usage()
+		os.Exit(2)
+	}
+
+	pemBytes, err := os.ReadFile(publicKeyFile)
+	cli.HandleUnexpectedError(err)
+
+	block, _ := pem.Decode(pemBytes)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		fmt.Fprintln(os.Stderr, "error: --public-key must be a PEM encoded public key")
+		os.Exit(2)
+	}
+
+	exportRequest := &escrow.ExportRequest{
+		ClientId:  clientId,
+		Algorithm: alg,
+		PublicKey: b64.RawURLEncoding.EncodeToString(block.Bytes),
+	}
+	if refs != "" {
+		exportRequest.KeyReferences = strings.Split(refs, ",")
+	}
+
+	// load config
+	cfg, err := bootstrap.LoadClientConfig(".env")
+	cli.HandleUnexpectedError(err)
+
+	// allow self-signed cert in dev mode
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.Env == "dev" {
+		tlsCfg.InsecureSkipVerify = true
+	}
+	tr := &http.Transport{
+		TLSClientConfig: tlsCfg,
+	}
+
+	client := &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: tr,
+	}
+
+	// login
+	token, err := login(cfg, client)
+	cli.HandleUnexpectedError(err)
+
+	// export keys
+	var file escrow.File
+	postImportRequest(cfg, client, token, "/admin/escrow/export", exportRequest, &file)
+
+	fileBytes, err := json.MarshalIndent(&file, "", "  ")
+	cli.HandleUnexpectedError(err)
+
+	err = os.WriteFile(out, fileBytes, 0600)
+	cli.HandleUnexpectedError(err)
+
+	fmt.Printf("Exported %d keys of client '%s' to %s\n", len(file.Keys), file.Clientname, out)
+}
@@ -6,12 +6,13 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
 	"kms/internal/bootstrap"
 	"kms/internal/keys"
 	"kms/pkg/cli"
+	"kms/pkg/encryption"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -19,8 +20,10 @@ func runGenerate(args []string) {
 	fs := flag.NewFlagSet("generate", flag.ExitOnError)
 	var (
 		ref string
+		alg string
 	)
 	fs.StringVar(&ref, "ref", "", "key reference")
+	fs.StringVar(&alg, "alg", encryption.DefaultAlgorithm, "key algorithm ("+strings.Join(encryption.Algorithms, " | ")+")")
 	fs.Parse(args)
 
 	if ref == "" {
@@ -30,14 +33,14 @@ func runGenerate(args []string) {
 	}
 
 	// load config
-	cfg, err := bootstrap.LoadConfig(".env")
+	cfg, err := bootstrap.LoadClientConfig(".env")
 	if err != nil {
 		cli.HandleUnexpectedError(err)
 	}
 
 	// allow self-signed cert in dev mode
 	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
-	if cfg["ENV"] == "dev" {
+	if cfg.Env == "dev" {
 		tlsCfg.InsecureSkipVerify = true
 	}
 
@@ -57,12 +60,13 @@ func runGenerate(args []string) {
 	// generate key
 	generateRequest := &keys.GenerateKeyRequest{
 		KeyReference: ref,
+		Algorithm:    alg,
 	}
 
 	generateBody, err := json.Marshal(generateRequest)
 	cli.HandleUnexpectedError(err)
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("https://%s:%s/keys/actions/generate", cfg["SERVER_HOST"], cfg["SERVER_PORT"]), bytes.NewReader(generateBody))
+	req, err := http.NewRequest("POST", cfg.URL("/keys/actions/generate"), bytes.NewReader(generateBody))
 	cli.HandleUnexpectedError(err)
 
 	req.Header.Set("Content-Type", "application/json")
@@ -74,13 +78,8 @@ func runGenerate(args []string) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-		if len(bytes.TrimSpace(body)) == 0 {
-			body = []byte(resp.Status) // fallback if no body
-		}
-		fmt.Fprintf(os.Stderr, "server error (%d): %s\n", resp.StatusCode, body)
-		os.Exit(1)
+		cli.HandleErrorResponse(resp)
 	}
 
-	fmt.Printf("Key with reference '%s' generated successfully\n", ref)
+	fmt.Printf("%s key with reference '%s' generated successfully\n", alg, ref)
 }
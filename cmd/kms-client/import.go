@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	b64 "encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"kms/internal/bootstrap"
+	"kms/internal/keys"
+	"kms/pkg/cli"
+	"kms/pkg/encryption"
+	"net/http"
+	"os"
+	"time"
+)
+
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	var (
+		ref     string
+		keyFile string
+		alg     string
+		keyAlg  string
+	)
+	fs.StringVar(&ref, "ref", "", "key reference")
+	fs.StringVar(&keyFile, "file", "", "file containing the raw key")
+	fs.StringVar(&alg, "alg", encryption.WrapAlgRSAOAEP256, "wrapping algorithm ("+encryption.WrapAlgRSAOAEP256+" | "+encryption.WrapAlgECDHP256+")")
+	fs.StringVar(&keyAlg, "key-alg", "", "key algorithm (default: algorithm of the existing key, or "+encryption.DefaultAlgorithm+")")
+	fs.Parse(args)
+
+	if ref == "" || keyFile == "" {
+		fmt.Fprintln(os.Stderr, "error: --ref and --file are required")
+		usage()
+		os.Exit(2)
+	}
+
+	keyMaterial, err := os.ReadFile(keyFile)
+	cli.HandleUnexpectedError(err)
+
+	// load config
+	cfg, err := bootstrap.LoadClientConfig(".env")
+	cli.HandleUnexpectedError(err)
+
+	// allow self-signed cert in dev mode
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.Env == "dev" {
+		tlsCfg.InsecureSkipVerify = true
+	}
+
+	tr := &http.Transport{
+		TLSClientConfig: tlsCfg,
+	}
+
+	client := &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: tr,
+	}
+
+	// login
+	token, err := login(cfg, client)
+	cli.HandleUnexpectedError(err)
+
+	// get one-time wrapping key
+	var wrappingKey keys.WrappingKeyResponse
+	postImportRequest(cfg, client, token, "/keys/actions/import/wrapping-key", &keys.WrappingKeyRequest{
+		Algorithm: alg,
+	}, &wrappingKey)
+
+	publicKey, err := b64.RawURLEncoding.DecodeString(wrappingKey.PublicKey)
+	cli.HandleUnexpectedError(err)
+
+	// key material is only sent wrapped
+	wrapped, err := encryption.WrapKey(wrappingKey.Algorithm, publicKey, keyMaterial)
+	cli.HandleUnexpectedError(err)
+
+	// import key
+	var key keys.KeyResponse
+	postImportRequest(cfg, client, token, "/keys/actions/import", &keys.ImportKeyRequest{
+		KeyReference: ref,
+		ImportToken:  wrappingKey.ImportToken,
+		WrappedKey:   b64.RawURLEncoding.EncodeToString(wrapped),
+		Algorithm:    keyAlg,
+	}, &key)
+
+	fmt.Printf("%s key imported as version %d of reference '%s'\n", key.Algorithm, key.Version, ref)
+}
+
+func postImportRequest(cfg *bootstrap.ClientConfig, client *http.Client, token, path string, body, dst any) {
+	reqBody, err := json.Marshal(body)
+	cli.HandleUnexpectedError(err)
+
+	req, err := http.NewRequest("POST", cfg.URL(path), bytes.NewReader(reqBody))
+	cli.HandleUnexpectedError(err)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	cli.HandleFailedRequest(err)
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		cli.HandleErrorResponse(resp)
+	}
+
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(dst)
+	cli.HandleUnexpectedError(err)
+}
@@ -7,11 +7,18 @@ import (
 	"io"
 	"kms/internal/api/dto"
 	"kms/internal/auth"
+	"kms/internal/bootstrap"
 	"kms/pkg/cli"
+	kmsErrors "kms/pkg/errors"
 	"net/http"
+	"os"
 )
 
-func login(cfg map[string]string, client *http.Client) (string, error) {
+func login(cfg *bootstrap.ClientConfig, client *http.Client) (string, error) {
+	if apiKey := os.Getenv("KMS_API_KEY"); apiKey != "" {
+		return loginWithApiKey(cfg, client, apiKey)
+	}
+
 	user, err := cli.RequireName()
 	if err != nil {
 		cli.HandleUnexpectedError(err)
@@ -27,12 +34,16 @@ func login(cfg map[string]string, client *http.Client) (string, error) {
 		Password:   password,
 	}
 
+	return loginWithCredentials(cfg, client, cred)
+}
+
+func loginWithCredentials(cfg *bootstrap.ClientConfig, client *http.Client, cred *auth.Credentials) (string, error) {
 	loginBody, err := json.Marshal(cred)
 	if err != nil {
 		return "", err
 	}
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("https://%s:%s/auth/login", cfg["SERVER_HOST"], cfg["SERVER_PORT"]), bytes.NewReader(loginBody))
+	req, err := http.NewRequest("POST", cfg.URL("/auth/login"), bytes.NewReader(loginBody))
 	if err != nil {
 		return "", err
 	}
@@ -46,8 +57,44 @@ func login(cfg map[string]string, client *http.Client) (string, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
-		return "", fmt.Errorf("login failed: %s", body)
+		problem := cli.ReadProblem(resp)
+		// MFA enabled, ask for a code and try again
+		if problem.Code == kmsErrors.CodeMfaRequired && cred.Otp == "" {
+			code, err := cli.RequireMfaCode()
+			if err != nil {
+				return "", err
+			}
+			cred.Otp = code
+			return loginWithCredentials(cfg, client, cred)
+		}
+		return "", fmt.Errorf("login failed: %w", problem)
+	}
+
+	var respData dto.TokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&respData); err != nil {
+		return "", err
+	}
+
+	return respData.Token, nil
+}
+
+// Non-interactive login, e.g. in CI
+func loginWithApiKey(cfg *bootstrap.ClientConfig, client *http.Client, apiKey string) (string, error) {
+	req, err := http.NewRequest("POST", cfg.URL("/auth/login/api-key"), nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Authorization", "ApiKey "+apiKey)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("login failed: %w", cli.ReadProblem(resp))
 	}
 
 	var respData dto.TokenResponse
@@ -14,12 +14,46 @@ func main() {
 	switch os.Args[1] {
 	case "signup":
 		runSignup(os.Args[2:])
+	case "passwd":
+		runPasswd(os.Args[2:])
+	case "reset-password":
+		runResetPassword(os.Args[2:])
+	case "unlock":
+		runUnlock(os.Args[2:])
+	case "mfa-enroll":
+		runMfaEnroll(os.Args[2:])
+	case "mfa-disable":
+		runMfaDisable(os.Args[2:])
+	case "reset-mfa":
+		runResetMfa(os.Args[2:])
 	case "generate":
 		runGenerate(os.Args[2:])
+	case "import":
+		runImport(os.Args[2:])
 	case "rotate":
 		runRotate(os.Args[2:])
 	case "delete":
 		runDelete(os.Args[2:])
+	case "restore":
+		runRestore(os.Args[2:])
+	case "destroy":
+		runDestroy(os.Args[2:])
+	case "prune":
+		runPrune(os.Args[2:])
+	case "escrow-export":
+		runEscrowExport(os.Args[2:])
+	case "encrypt-file":
+		runEncryptFile(os.Args[2:])
+	case "decrypt-file":
+		runDecryptFile(os.Args[2:])
+	case "bind-cert":
+		runBindCert(os.Args[2:])
+	case "unbind-cert":
+		runUnbindCert(os.Args[2:])
+	case "create-api-key":
+		runCreateApiKey(os.Args[2:])
+	case "revoke-api-key":
+		runRevokeApiKey(os.Args[2:])
 	default:
 		usage()
 		os.Exit(2)
@@ -29,8 +63,25 @@ func main() {
 func usage() {
 	fmt.Fprintln(os.Stderr, `kms-cli commands:
 	signup --token <signup token>
-	generate --ref <key reference> 
+	passwd [--token <password reset token>]
+	reset-password --client-id <id> [--ttl <token ttl in ms>]
+	unlock --client-id <id>
+	mfa-enroll
+	mfa-disable
+	reset-mfa --client-id <id>
+	generate --ref <key reference> [--alg <AES-128-GCM | AES-256-GCM | ChaCha20-Poly1305 | XChaCha20-Poly1305 | AES-256-SIV | HMAC-SHA512>]
+	import --ref <key reference> --file <raw key file> [--alg <RSA-OAEP-256 | ECDH-P256>] [--key-alg <key algorithm>]
 	rotate --ref <key reference>
 	delete --ref <key reference>
+	restore --ref <key reference>
+	destroy --ref <key reference> --version <version>
+	prune --ref <key reference> [--older-than <version>] [--deprecated-before <YYYY-MM-DD>]
+	escrow-export --client-id <id> --public-key <PEM file> --out <escrow file> [--refs <ref1,ref2>] [--alg <RSA-OAEP-256 | ECDH-P256>]
+	encrypt-file --ref <key reference> --in <file> --out <encrypted file> [--segment-size <bytes>]
+	decrypt-file --in <encrypted file> --out <file>
+	bind-cert --client-id <id> --identity <uri:... | dns:... | email:... | cn:...> [--pin-cert <PEM certificate>]
+	unbind-cert --client-id <id> --id <certificate id>
+	create-api-key --name <name> --scopes <keys:read,keys:write,admin> [--ttl-days <days>] [--client-id <id>]
+	revoke-api-key --id <API key id> [--client-id <id>]
 	`)
 }
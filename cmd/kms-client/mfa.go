@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"kms/internal/auth"
+	"kms/internal/bootstrap"
+	"kms/pkg/cli"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Admin only, prints the TOTP secret for an authenticator app and, once a code is confirmed, the recovery codes
+func runMfaEnroll(args []string) {
+	fs := flag.NewFlagSet("mfa-enroll", flag.ExitOnError)
+	fs.Parse(args)
+
+	// load config
+	cfg, err := bootstrap.LoadClientConfig(".env")
+	cli.HandleUnexpectedError(err)
+
+	// allow self-signed cert in dev mode
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.Env == "dev" {
+		tlsCfg.InsecureSkipVerify = true
+	}
+	tr := &http.Transport{
+		TLSClientConfig: tlsCfg,
+	}
+
+	client := &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: tr,
+	}
+
+	// login
+	token, err := login(cfg, client)
+	cli.HandleUnexpectedError(err)
+
+	var enrolment auth.EnrollMfaResponse
+	postPasswordRequest(cfg, client, token, "/auth/mfa/actions/enroll", struct{}{}, http.StatusOK, &enrolment)
+
+	fmt.Printf("add this secret to your authenticator app: %s\n", enrolment.Secret)
+	fmt.Printf("or as URI: %s\n", enrolment.Uri)
+
+	code, err := cli.RequireMfaCode()
+	cli.HandleUnexpectedError(err)
+
+	var response auth.RecoveryCodesResponse
+	postPasswordRequest(cfg, client, token, "/auth/mfa/actions/activate", &auth.MfaCodeRequest{Code: code}, http.StatusOK, &response)
+
+	fmt.Println("MFA enabled, store these recovery codes somewhere safe, each can be used once instead of a code:")
+	fmt.Println(strings.Join(response.RecoveryCodes, "\n"))
+}
+
+// Not possible if ADMIN_MFA_REQUIRED is set
+func runMfaDisable(args []string) {
+	fs := flag.NewFlagSet("mfa-disable", flag.ExitOnError)
+	fs.Parse(args)
+
+	// load config
+	cfg, err := bootstrap.LoadClientConfig(".env")
+	cli.HandleUnexpectedError(err)
+
+	// allow self-signed cert in dev mode
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.Env == "dev" {
+		tlsCfg.InsecureSkipVerify = true
+	}
+	tr := &http.Transport{
+		TLSClientConfig: tlsCfg,
+	}
+
+	client := &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: tr,
+	}
+
+	// login
+	token, err := login(cfg, client)
+	cli.HandleUnexpectedError(err)
+
+	// a code used for the login can't be used again
+	fmt.Println("Confirm with a new code")
+	code, err := cli.RequireMfaCode()
+	cli.HandleUnexpectedError(err)
+
+	postPasswordRequest(cfg, client, token, "/auth/mfa/actions/disable", &auth.MfaCodeRequest{Code: code}, http.StatusNoContent, nil)
+
+	fmt.Println("MFA disabled")
+}
+
+// Admin only, for admins who lost their authenticator and recovery codes
+func runResetMfa(args []string) {
+	fs := flag.NewFlagSet("reset-mfa", flag.ExitOnError)
+	var (
+		clientId int
+	)
+	fs.IntVar(&clientId, "client-id", 0, "id of the client")
+	fs.Parse(args)
+
+	if clientId <= 0 {
+		fmt.Fprintln(os.Stderr, "error: --client-id is required")
+		usage()
+		os.Exit(2)
+	}
+
+	// load config
+	cfg, err := bootstrap.LoadClientConfig(".env")
+	cli.HandleUnexpectedError(err)
+
+	// allow self-signed cert in dev mode
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.Env == "dev" {
+		tlsCfg.InsecureSkipVerify = true
+	}
+	tr := &http.Transport{
+		TLSClientConfig: tlsCfg,
+	}
+
+	client := &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: tr,
+	}
+
+	// login
+	token, err := login(cfg, client)
+	cli.HandleUnexpectedError(err)
+
+	postPasswordRequest(cfg, client, token, fmt.Sprintf("/clients/%d/mfa/actions/reset", clientId), struct{}{}, http.StatusNoContent, nil)
+
+	fmt.Printf("MFA of client %d reset\n", clientId)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"kms/internal/admin"
+	"kms/internal/api/dto"
+	"kms/internal/auth"
+	"kms/internal/bootstrap"
+	"kms/pkg/cli"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Change the password (re-entering the current one), or set a new one with a reset token from an admin
+func runPasswd(args []string) {
+	fs := flag.NewFlagSet("passwd", flag.ExitOnError)
+	var (
+		token string
+	)
+	fs.StringVar(&token, "token", "", "password reset token (optional)")
+	fs.Parse(args)
+
+	// load config
+	cfg, err := bootstrap.LoadClientConfig(".env")
+	cli.HandleUnexpectedError(err)
+
+	// allow self-signed cert in dev mode
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.Env == "dev" {
+		tlsCfg.InsecureSkipVerify = true
+	}
+	tr := &http.Transport{
+		TLSClientConfig: tlsCfg,
+	}
+
+	client := &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: tr,
+	}
+
+	if token != "" {
+		fmt.Println("New password")
+		password, err := cli.RequirePasswordTwice()
+		cli.HandleUnexpectedError(err)
+
+		cred := &auth.ResetPasswordCredentials{
+			Token:    token,
+			Password: password,
+		}
+		postPasswordRequest(cfg, client, "", "/auth/password/reset", cred, http.StatusOK, nil)
+
+		fmt.Println("Password reset")
+		return
+	}
+
+	// login with the current password, which is verified again when changing it
+	user, err := cli.RequireName()
+	cli.HandleUnexpectedError(err)
+
+	current, err := cli.RequirePassword()
+	cli.HandleUnexpectedError(err)
+
+	jwt, err := loginWithCredentials(cfg, client, &auth.Credentials{
+		Clientname: user,
+		Password:   current,
+	})
+	cli.HandleUnexpectedError(err)
+
+	fmt.Println("New password")
+	password, err := cli.RequirePasswordTwice()
+	cli.HandleUnexpectedError(err)
+
+	body := &auth.ChangePasswordRequest{
+		CurrentPassword: current,
+		NewPassword:     password,
+	}
+	postPasswordRequest(cfg, client, jwt, "/auth/password", body, http.StatusNoContent, nil)
+
+	fmt.Println("Password changed")
+}
+
+// Admin only, prints a one-time token the client can set a new password with ('passwd --token')
+func runResetPassword(args []string) {
+	fs := flag.NewFlagSet("reset-password", flag.ExitOnError)
+	var (
+		clientId int
+		ttl      int64
+	)
+	fs.IntVar(&clientId, "client-id", 0, "id of the client")
+	fs.Int64Var(&ttl, "ttl", admin.DefaultResetTokenTtl, "token's time-to-live in ms")
+	fs.Parse(args)
+
+	if clientId <= 0 {
+		fmt.Fprintln(os.Stderr, "error: --client-id is required")
+		usage()
+		os.Exit(2)
+	}
+
+	body := &admin.GenerateResetTokenRequest{
+		Ttl: ttl,
+	}
+	if err := body.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
+
+	// load config
+	cfg, err := bootstrap.LoadClientConfig(".env")
+	cli.HandleUnexpectedError(err)
+
+	// allow self-signed cert in dev mode
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.Env == "dev" {
+		tlsCfg.InsecureSkipVerify = true
+	}
+	tr := &http.Transport{
+		TLSClientConfig: tlsCfg,
+	}
+
+	client := &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: tr,
+	}
+
+	// login
+	token, err := login(cfg, client)
+	cli.HandleUnexpectedError(err)
+
+	// generate reset token
+	var response dto.TokenResponse
+	postImportRequest(cfg, client, token, fmt.Sprintf("/clients/%d/password/actions/reset", clientId), body, &response)
+
+	fmt.Printf("generated password reset token for client %d: %s\n", clientId, response.Token)
+}
+
+// Admin only, lifts a lockout after too many failed logins
+func runUnlock(args []string) {
+	fs := flag.NewFlagSet("unlock", flag.ExitOnError)
+	var (
+		clientId int
+	)
+	fs.IntVar(&clientId, "client-id", 0, "id of the client")
+	fs.Parse(args)
+
+	if clientId <= 0 {
+		fmt.Fprintln(os.Stderr, "error: --client-id is required")
+		usage()
+		os.Exit(2)
+	}
+
+	// load config
+	cfg, err := bootstrap.LoadClientConfig(".env")
+	cli.HandleUnexpectedError(err)
+
+	// allow self-signed cert in dev mode
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.Env == "dev" {
+		tlsCfg.InsecureSkipVerify = true
+	}
+	tr := &http.Transport{
+		TLSClientConfig: tlsCfg,
+	}
+
+	client := &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: tr,
+	}
+
+	// login
+	token, err := login(cfg, client)
+	cli.HandleUnexpectedError(err)
+
+	postPasswordRequest(cfg, client, token, fmt.Sprintf("/clients/%d/actions/unlock", clientId), struct{}{}, http.StatusNoContent, nil)
+
+	fmt.Printf("client %d unlocked\n", clientId)
+}
+
+func postPasswordRequest(cfg *bootstrap.ClientConfig, client *http.Client, token, path string, body any, status int, dst any) {
+	reqBody, err := json.Marshal(body)
+	cli.HandleUnexpectedError(err)
+
+	req, err := http.NewRequest("POST", cfg.URL(path), bytes.NewReader(reqBody))
+	cli.HandleUnexpectedError(err)
+
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	cli.HandleFailedRequest(err)
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != status {
+		cli.HandleErrorResponse(resp)
+	}
+
+	if dst != nil {
+		err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(dst)
+		cli.HandleUnexpectedError(err)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"kms/internal/bootstrap"
+	"kms/internal/keys"
+	"kms/pkg/cli"
+	"net/http"
+	"os"
+	"time"
+)
+
+func runPrune(args []string) {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	var (
+		ref              string
+		olderThan        int
+		deprecatedBefore string
+	)
+	fs.StringVar(&ref, "ref", "", "key reference")
+	fs.IntVar(&olderThan, "older-than", 0, "destroy versions older than this version")
+	fs.StringVar(&deprecatedBefore, "deprecated-before", "", "destroy versions deprecated before this date (YYYY-MM-DD)")
+	fs.Parse(args)
+
+	if ref == "" || (olderThan <= 0 && deprecatedBefore == "") {
+		fmt.Fprintln(os.Stderr, "error: --ref and at least one of --older-than or --deprecated-before are required")
+		usage()
+		os.Exit(2)
+	}
+
+	pruneRequest := &keys.PruneKeyVersionsRequest{
+		OlderThanVersion: olderThan,
+	}
+	if deprecatedBefore != "" {
+		date, err := time.ParseInLocation(time.DateOnly, deprecatedBefore, time.Local)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error: --deprecated-before must be formatted as YYYY-MM-DD")
+			os.Exit(2)
+		}
+		pruneRequest.DeprecatedBefore = &date
+	}
+
+	// load config
+	cfg, err := bootstrap.LoadClientConfig(".env")
+	cli.HandleUnexpectedError(err)
+
+	// allow self-signed cert in dev mode
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.Env == "dev" {
+		tlsCfg.InsecureSkipVerify = true
+	}
+	tr := &http.Transport{
+		TLSClientConfig: tlsCfg,
+	}
+
+	client := &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: tr,
+	}
+
+	// login
+	token, err := login(cfg, client)
+	cli.HandleUnexpectedError(err)
+
+	// prune key versions
+	pruneBody, err := json.Marshal(pruneRequest)
+	cli.HandleUnexpectedError(err)
+
+	req, err := http.NewRequest("POST", cfg.URL(fmt.Sprintf("/keys/%s/actions/prune", ref)), bytes.NewBuffer(pruneBody))
+	cli.HandleUnexpectedError(err)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	cli.HandleFailedRequest(err)
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		cli.HandleErrorResponse(resp)
+	}
+
+	var pruned keys.PruneKeyVersionsResponse
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&pruned)
+	cli.HandleUnexpectedError(err)
+
+	if len(pruned.DestroyedVersions) == 0 {
+		fmt.Printf("No versions of key with reference '%s' matched\n", ref)
+		return
+	}
+	fmt.Printf("Destroyed versions %v of key with reference '%s'\n", pruned.DestroyedVersions, ref)
+}
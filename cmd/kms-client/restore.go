@@ -0,0 +1,66 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"kms/internal/bootstrap"
+	"kms/pkg/cli"
+	"net/http"
+	"os"
+	"time"
+)
+
+func runRestore(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	var (
+		ref string
+	)
+	fs.StringVar(&ref, "ref", "", "key reference")
+	fs.Parse(args)
+
+	if ref == "" {
+		fmt.Fprintln(os.Stderr, "error: --ref is required")
+		usage()
+		os.Exit(2)
+	}
+
+	// load config
+	cfg, err := bootstrap.LoadClientConfig(".env")
+	cli.HandleUnexpectedError(err)
+
+	// allow self-signed cert in dev mode
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.Env == "dev" {
+		tlsCfg.InsecureSkipVerify = true
+	}
+	tr := &http.Transport{
+		TLSClientConfig: tlsCfg,
+	}
+
+	client := &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: tr,
+	}
+
+	// login
+	token, err := login(cfg, client)
+	cli.HandleUnexpectedError(err)
+
+	// restore key
+	req, err := http.NewRequest("POST", cfg.URL(fmt.Sprintf("/keys/%s/actions/restore", ref)), nil)
+	cli.HandleUnexpectedError(err)
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	cli.HandleFailedRequest(err)
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		cli.HandleErrorResponse(resp)
+	}
+
+	fmt.Printf("Key with reference '%s' restored successfully\n", ref)
+}
@@ -1,11 +1,9 @@
 package main
 
 import (
-	"bytes"
 	"crypto/tls"
 	"flag"
 	"fmt"
-	"io"
 	"kms/internal/bootstrap"
 	"kms/pkg/cli"
 	"net/http"
@@ -28,12 +26,12 @@ func runRotate(args []string) {
 	}
 
 	// load config
-	cfg, err := bootstrap.LoadConfig(".env")
+	cfg, err := bootstrap.LoadClientConfig(".env")
 	cli.HandleUnexpectedError(err)
 
 	// allow self-signed cert in dev mode
 	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
-	if cfg["ENV"] == "dev" {
+	if cfg.Env == "dev" {
 		tlsCfg.InsecureSkipVerify = true
 	}
 
@@ -51,7 +49,7 @@ func runRotate(args []string) {
 	cli.HandleUnexpectedError(err)
 
 	// rotate key
-	req, err := http.NewRequest("POST", fmt.Sprintf("https://%s:%s/keys/%s/actions/rotate", cfg["SERVER_HOST"], cfg["SERVER_PORT"], ref), nil)
+	req, err := http.NewRequest("POST", cfg.URL(fmt.Sprintf("/keys/%s/actions/rotate", ref)), nil)
 	cli.HandleUnexpectedError(err)
 
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -62,12 +60,7 @@ func runRotate(args []string) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-		if len(bytes.TrimSpace(body)) == 0 {
-			body = []byte(resp.Status) // fallback if no body
-		}
-		fmt.Fprintf(os.Stderr, "server error (%d): %s\n", resp.StatusCode, body)
-		os.Exit(1)
+		cli.HandleErrorResponse(resp)
 	}
 
 	fmt.Printf("Key with reference '%s' rotated successfully\n", ref)
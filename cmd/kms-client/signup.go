@@ -6,7 +6,6 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
 	"kms/internal/auth"
 	"kms/internal/bootstrap"
 	"kms/pkg/cli"
@@ -38,21 +37,21 @@ func runSignup(args []string) {
 		Password: password,
 	}
 
-	cfg, err := bootstrap.LoadConfig(".env")
+	cfg, err := bootstrap.LoadClientConfig(".env")
 	cli.HandleUnexpectedError(err)
 
 	// signup
 	body, err := json.Marshal(cred)
 	cli.HandleUnexpectedError(err)
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("https://%s:%s/auth/signup", cfg["SERVER_HOST"], cfg["SERVER_PORT"]), bytes.NewReader(body))
+	req, err := http.NewRequest("POST", cfg.URL("/auth/signup"), bytes.NewReader(body))
 	cli.HandleUnexpectedError(err)
 
 	req.Header.Set("Content-Type", "application/json")
 
 	// allow self-signed cert in dev mode
 	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
-	if cfg["ENV"] == "dev" {
+	if cfg.Env == "dev" {
 		tlsCfg.InsecureSkipVerify = true
 	}
 
@@ -69,12 +68,7 @@ func runSignup(args []string) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-		if len(bytes.TrimSpace(body)) == 0 {
-			body = []byte(resp.Status) // fallback if no body
-		}
-		fmt.Fprintf(os.Stderr, "server error (%d): %s\n", resp.StatusCode, body)
-		os.Exit(1)
+		cli.HandleErrorResponse(resp)
 	}
 
 	fmt.Printf("Signup successful, status code: %d\n", resp.StatusCode)
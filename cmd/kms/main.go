@@ -1,18 +1,25 @@
 package main
 
 import (
+	"context"
 	"errors"
-	"fmt"
 	"kms/internal/api"
 	"kms/internal/bootstrap"
+	c "kms/internal/bootstrap/context"
+	"kms/internal/keys"
+	"kms/internal/metrics"
 	dbEncr "kms/internal/storage/encryption"
 	"kms/internal/storage/postgres"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
-	cfg, err := bootstrap.LoadConfig(".env")
+	cfg, err := bootstrap.LoadKmsConfig(".env")
 	if err != nil {
 		log.Fatal("Unable to load config: ", err)
 	}
@@ -22,10 +29,19 @@ func main() {
 		log.Fatal("Unable to initialise key manager: ", err)
 	}
 
-	consoleLogger, err := bootstrap.InitConsoleLogger(cfg["LOG_LEVEL"])
+	logger, err := bootstrap.InitLogger(cfg)
 	if err != nil {
 		log.Fatal("Unable to initialise logger: ", err)
 	}
+	defer logger.Close()
+
+	if exporter := bootstrap.InitTracing(cfg, logger); exporter != nil {
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			exporter.Shutdown(ctx)
+		}()
+	}
 
 	db, err := bootstrap.ConnectDatabase(cfg)
 	if err != nil {
@@ -33,7 +49,8 @@ func main() {
 	}
 	defer db.Close()
 
-	if err := postgres.InitSchema(cfg, db, keyManager, "database/migrations"); err != nil {
+	migrationsPath := "database/migrations"
+	if err := postgres.InitSchema(cfg, db, keyManager, migrationsPath); err != nil {
 		log.Fatal("Failed to init schema: ", err)
 	}
 
@@ -42,20 +59,102 @@ func main() {
 	clientRepo := dbEncr.NewEncryptedClientRepo(postgres.NewPostgresClientRepo(db), keyManager)
 
 	appCtx := &bootstrap.AppContext{
-		Cfg:        cfg,
-		KeyManager: keyManager,
-		Logger:     consoleLogger,
-		DB:         db,
-		ClientRepo: clientRepo,
-		KeyRepo:    keyRepo,
-		AdminRepo:  adminRepo,
+		Cfg:            cfg,
+		KeyManager:     keyManager,
+		Logger:         logger,
+		DB:             db,
+		MigrationsPath: migrationsPath,
+		ClientRepo:     clientRepo,
+		KeyRepo:        keyRepo,
+		AdminRepo:      adminRepo,
 	}
 
 	if err := api.RegisterRoutes(appCtx); err != nil {
 		log.Fatal("Unable to register routes: ", err)
 	}
 
-	if err := http.ListenAndServeTLS(fmt.Sprintf(":%v", cfg["SERVER_PORT"]), "kms.crt", "kms.key", nil); err != nil && !errors.Is(err, http.ErrServerClosed) {
+	// admin listener, plain HTTP, should only be reachable from the monitoring network
+	var metricsServer *http.Server
+	if addr := cfg.MetricsAddr; addr != "" {
+		metrics.RegisterDBStats(metrics.Default, db)
+		metricsMux := http.NewServeMux()
+		if err := api.RegisterAdminRoutes(metricsMux, appCtx); err != nil {
+			log.Fatal("Unable to register admin routes: ", err)
+		}
+		metricsServer = &http.Server{Addr: addr, Handler: metricsMux, ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout}
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("Metrics listener failed", "addr", addr, "error", err)
+			}
+		}()
+	}
+
+	jobCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+	jobsDone := make(chan struct{})
+	go func() {
+		defer close(jobsDone)
+		keys.RunDestructionJob(jobCtx, keys.NewService(keyRepo, keyManager, cfg.KeyDeletionWindow, logger), time.Hour)
+	}()
+
+	certs, err := bootstrap.NewCertReloader(cfg.Server.CertFile, cfg.Server.KeyFile, cfg.MTLSCAFile, logger)
+	if err != nil {
+		log.Fatal("Unable to load TLS certificate: ", err)
+	}
+	tlsCfg := bootstrap.InitTLSConfig(certs)
+	go certs.Watch(jobCtx, 30*time.Second)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := certs.Reload(); err != nil {
+				logger.Error("Unable to reload TLS certificate", "error", err)
+				continue
+			}
+			logger.Notice("Reloaded TLS certificate", "certFile", cfg.Server.CertFile, "caFile", cfg.MTLSCAFile)
+		}
+	}()
+
+	stop, cancelStop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer cancelStop()
+
+	server := bootstrap.NewServer(cfg.Server, http.DefaultServeMux, tlsCfg)
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServeTLS("", "")
+	}()
+
+	select {
+	case err := <-serverErr:
 		log.Fatal("HTTPS server failed: ", err)
+	case <-stop.Done():
+	}
+	// a second signal kills the process
+	cancelStop()
+
+	logger.Notice("Shutting down", "timeout", cfg.Server.ShutdownTimeout.String())
+	drain(server, metricsServer, cancelJobs, jobsDone, cfg.Server.ShutdownTimeout, logger)
+}
+
+// Stops accepting connections and waits for in-flight requests and a running job pass, at most timeout.
+// The database is closed once this returns.
+func drain(server, metricsServer *http.Server, cancelJobs context.CancelFunc, jobsDone <-chan struct{}, timeout time.Duration, logger c.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Error("Requests still running at shutdown", "error", err)
+	}
+
+	cancelJobs()
+	select {
+	case <-jobsDone:
+	case <-ctx.Done():
+		logger.Error("Background jobs still running at shutdown", "error", ctx.Err())
+	}
+
+	if metricsServer != nil {
+		metricsServer.Shutdown(ctx)
 	}
 }
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS keys_deleteafter_idx;
+ALTER TABLE keys DROP COLUMN IF EXISTS deleteAfter;
\ No newline at end of file
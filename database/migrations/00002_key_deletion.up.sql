@@ -0,0 +1,3 @@
+ALTER TABLE keys ADD COLUMN IF NOT EXISTS deleteAfter TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS keys_deleteafter_idx ON keys (deleteAfter) WHERE deleteAfter IS NOT NULL;
@@ -0,0 +1,2 @@
+-- Existing rows get the migration time, which errs on the side of keeping old versions when pruning by date
+ALTER TABLE keys ADD COLUMN IF NOT EXISTS createdAt TIMESTAMPTZ NOT NULL DEFAULT NOW();
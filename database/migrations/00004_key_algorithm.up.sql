@@ -0,0 +1,5 @@
+-- Existing keys are all 32 byte AES-256-GCM keys
+ALTER TABLE keys ADD COLUMN IF NOT EXISTS algorithm VARCHAR(32) NOT NULL DEFAULT 'AES-256-GCM';
+
+-- Encrypted 64 byte (HMAC-SHA512) keys don't fit in 80 characters
+ALTER TABLE keys ALTER COLUMN dek TYPE VARCHAR(128);
@@ -0,0 +1,12 @@
+-- Long-lived API keys of a client, identified by their (public) prefix, the secret part is stored hashed
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    clientId INTEGER NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+    name VARCHAR(64) NOT NULL,
+    prefix VARCHAR(16) UNIQUE NOT NULL,
+    hashedKey VARCHAR(44) NOT NULL,
+    scopes TEXT NOT NULL,
+    expiresAt TIMESTAMPTZ,
+    lastUsedAt TIMESTAMPTZ,
+    createdAt TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
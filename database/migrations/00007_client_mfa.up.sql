@@ -0,0 +1,10 @@
+-- TOTP enrolment of a client (admins only), the secret is encrypted like other client fields and recovery codes are hashed.
+-- lastStep is the time step of the last accepted code, so a code can't be used twice.
+CREATE TABLE IF NOT EXISTS client_mfa (
+    clientId INTEGER PRIMARY KEY REFERENCES clients(id) ON DELETE CASCADE,
+    secret TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    lastStep BIGINT NOT NULL DEFAULT 0,
+    recoveryCodes TEXT NOT NULL DEFAULT '',
+    createdAt TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -1,7 +1,9 @@
 package admin
 
 import (
+	b64 "encoding/base64"
 	"fmt"
+	"strings"
 )
 
 type GenerateSignupTokenRequest struct {
@@ -16,6 +18,24 @@ func (r *GenerateSignupTokenRequest) Validate() error {
 	return nil
 }
 
+// Default and maximum time-to-live (ms) of password reset tokens
+const (
+	DefaultResetTokenTtl = 60 * 60 * 1000
+	MaxResetTokenTtl     = 7 * 24 * 60 * 60 * 1000
+)
+
+type GenerateResetTokenRequest struct {
+	// Optional, defaults to DefaultResetTokenTtl
+	Ttl int64 `json:"ttl,omitempty"`
+}
+
+func (r *GenerateResetTokenRequest) Validate() error {
+	if r.Ttl < 0 || r.Ttl > MaxResetTokenTtl {
+		return fmt.Errorf("ttl should be between 0 and %d ms", MaxResetTokenTtl)
+	}
+	return nil
+}
+
 type UpdateRoleRequest struct {
 	Role string `json:"role"`
 }
@@ -26,3 +46,23 @@ func (r *UpdateRoleRequest) Validate() error {
 	}
 	return nil
 }
+
+type BindCertificateRequest struct {
+	// One of auth.CertificateIdentities, e.g. 'uri:spiffe://mesh/ns/app' or 'cn:app'
+	Identity string `json:"identity"`
+	// Optional auth.CertificatePin, only certificates with this public key are accepted
+	Pin string `json:"pin,omitempty"`
+}
+
+func (r *BindCertificateRequest) Validate() error {
+	kind, value, ok := strings.Cut(r.Identity, ":")
+	if !ok || value == "" || (kind != "uri" && kind != "dns" && kind != "email" && kind != "cn") {
+		return fmt.Errorf("identity must be 'uri:', 'dns:', 'email:' or 'cn:' followed by a value")
+	}
+	if r.Pin != "" {
+		if pin, err := b64.RawURLEncoding.DecodeString(r.Pin); err != nil || len(pin) != 32 {
+			return fmt.Errorf("pin must be a base64url SHA-256 of the certificate's public key")
+		}
+	}
+	return nil
+}
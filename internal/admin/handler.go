@@ -1,6 +1,7 @@
 package admin
 
 import (
+	"context"
 	"kms/internal/api/dto"
 	c "kms/internal/bootstrap/context"
 	"kms/internal/clients"
@@ -25,11 +26,17 @@ func NewHandler(adminService AdminService, logger c.Logger) *Handler {
 }
 
 type AdminService interface {
-	UpdateRole(clientId int, role string, adminId string) *kmsErrors.AppError
-	Me(clientId int) (*clients.Client, *kmsErrors.AppError)
-	GenerateSignupToken(body *GenerateSignupTokenRequest, adminId string) (string, *kmsErrors.AppError)
-	GetClients() ([]clients.Client, *kmsErrors.AppError)
-	DeleteClient(clientId int) *kmsErrors.AppError
+	UpdateRole(ctx context.Context, clientId int, role string, adminId string) *kmsErrors.AppError
+	Me(ctx context.Context, clientId int) (*clients.Client, *kmsErrors.AppError)
+	GenerateSignupToken(ctx context.Context, body *GenerateSignupTokenRequest, adminId string) (string, *kmsErrors.AppError)
+	GenerateResetToken(ctx context.Context, clientId int, body *GenerateResetTokenRequest, adminId string) (string, *kmsErrors.AppError)
+	UnlockClient(ctx context.Context, clientId int, adminId string) *kmsErrors.AppError
+	ResetMfa(ctx context.Context, clientId int, adminId string) *kmsErrors.AppError
+	GetClients(ctx context.Context) ([]clients.Client, *kmsErrors.AppError)
+	DeleteClient(ctx context.Context, clientId int) *kmsErrors.AppError
+	BindCertificate(ctx context.Context, clientId int, body *BindCertificateRequest, adminId string) (*clients.Certificate, *kmsErrors.AppError)
+	GetCertificates(ctx context.Context, clientId int) ([]clients.Certificate, *kmsErrors.AppError)
+	UnbindCertificate(ctx context.Context, clientId, certId int, adminId string) *kmsErrors.AppError
 }
 
 func (h *Handler) UpdateRole(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
@@ -45,19 +52,19 @@ func (h *Handler) UpdateRole(w http.ResponseWriter, r *http.Request) *kmsErrors.
 
 	clientId, err := strconv.Atoi(clientIdStr)
 	if err != nil {
-		return kmsErrors.NewAppError(err, "ID must be integer", 400)
+		return kmsErrors.NewAppError(err, "ID must be integer", 400).WithErrorCode(kmsErrors.CodeInvalidParameter)
 	}
 
 	var requestBody UpdateRoleRequest
 	if err := json.ParseBody(r.Body, &requestBody); err != nil {
-		return kmsErrors.NewAppError(err, "Invalid request body", 400)
+		return kmsErrors.NewInvalidBodyError(err)
 	}
 
 	if err := requestBody.Validate(); err != nil {
 		return kmsErrors.NewInvalidBodyError(err)
 	}
 
-	if appErr := h.Service.UpdateRole(clientId, requestBody.Role, token.Payload.Sub); appErr != nil {
+	if appErr := h.Service.UpdateRole(r.Context(), clientId, requestBody.Role, token.Payload.Sub); appErr != nil {
 		return appErr
 	}
 
@@ -74,7 +81,7 @@ func (h *Handler) Me(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError
 	if err != nil {
 		return kmsErrors.NewInternalServerError(err)
 	}
-	admin, appErr := h.Service.Me(clientId)
+	admin, appErr := h.Service.Me(r.Context(), clientId)
 	if appErr != nil {
 		return appErr
 	}
@@ -102,7 +109,39 @@ func (h *Handler) GenerateSignupToken(w http.ResponseWriter, r *http.Request) *k
 		return kmsErrors.NewInvalidBodyError(err)
 	}
 
-	token, appErr := h.Service.GenerateSignupToken(&body, adminToken.Payload.Sub)
+	token, appErr := h.Service.GenerateSignupToken(r.Context(), &body, adminToken.Payload.Sub)
+	if appErr != nil {
+		return appErr
+	}
+
+	response := &dto.TokenResponse{
+		Token: token,
+	}
+
+	return pHttp.WriteJSON(w, response)
+}
+
+func (h *Handler) GenerateResetToken(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	adminToken, err := httpctx.ExtractToken(r.Context())
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	clientId, appErr := routeParamInt(r, "id")
+	if appErr != nil {
+		return appErr
+	}
+
+	var body GenerateResetTokenRequest
+	if err := json.ParseBody(r.Body, &body); err != nil {
+		return kmsErrors.NewInvalidBodyError(err)
+	}
+
+	if err := body.Validate(); err != nil {
+		return kmsErrors.NewInvalidBodyError(err)
+	}
+
+	token, appErr := h.Service.GenerateResetToken(r.Context(), clientId, &body, adminToken.Payload.Sub)
 	if appErr != nil {
 		return appErr
 	}
@@ -114,8 +153,26 @@ func (h *Handler) GenerateSignupToken(w http.ResponseWriter, r *http.Request) *k
 	return pHttp.WriteJSON(w, response)
 }
 
+func (h *Handler) UnlockClient(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	adminToken, err := httpctx.ExtractToken(r.Context())
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	clientId, appErr := routeParamInt(r, "id")
+	if appErr != nil {
+		return appErr
+	}
+
+	if appErr := h.Service.UnlockClient(r.Context(), clientId, adminToken.Payload.Sub); appErr != nil {
+		return appErr
+	}
+
+	return pHttp.WriteStatus(w, http.StatusNoContent)
+}
+
 func (h *Handler) GetClients(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
-	clients, appErr := h.Service.GetClients()
+	clients, appErr := h.Service.GetClients(r.Context())
 	if appErr != nil {
 		return appErr
 	}
@@ -131,12 +188,109 @@ func (h *Handler) DeleteClient(w http.ResponseWriter, r *http.Request) *kmsError
 
 	clientId, err := strconv.Atoi(clientIdStr)
 	if err != nil {
-		return kmsErrors.NewAppError(err, "ID must be integer", 400)
+		return kmsErrors.NewAppError(err, "ID must be integer", 400).WithErrorCode(kmsErrors.CodeInvalidParameter)
+	}
+
+	if appErr := h.Service.DeleteClient(r.Context(), clientId); appErr != nil {
+		return appErr
+	}
+
+	return pHttp.WriteStatus(w, 204)
+}
+
+func (h *Handler) BindCertificate(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	token, err := httpctx.ExtractToken(r.Context())
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	clientId, appErr := routeParamInt(r, "id")
+	if appErr != nil {
+		return appErr
+	}
+
+	var body BindCertificateRequest
+	if err := json.ParseBody(r.Body, &body); err != nil {
+		return kmsErrors.NewInvalidBodyError(err)
+	}
+
+	if err := body.Validate(); err != nil {
+		return kmsErrors.NewInvalidBodyError(err)
+	}
+
+	cert, appErr := h.Service.BindCertificate(r.Context(), clientId, &body, token.Payload.Sub)
+	if appErr != nil {
+		return appErr
+	}
+
+	return pHttp.WriteJSON(w, cert)
+}
+
+func (h *Handler) GetCertificates(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	clientId, appErr := routeParamInt(r, "id")
+	if appErr != nil {
+		return appErr
+	}
+
+	certs, appErr := h.Service.GetCertificates(r.Context(), clientId)
+	if appErr != nil {
+		return appErr
+	}
+
+	return pHttp.WriteJSON(w, certs)
+}
+
+func (h *Handler) UnbindCertificate(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	token, err := httpctx.ExtractToken(r.Context())
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
 	}
 
-	if appErr := h.Service.DeleteClient(clientId); appErr != nil {
+	clientId, appErr := routeParamInt(r, "id")
+	if appErr != nil {
+		return appErr
+	}
+
+	certId, appErr := routeParamInt(r, "certId")
+	if appErr != nil {
+		return appErr
+	}
+
+	if appErr := h.Service.UnbindCertificate(r.Context(), clientId, certId, token.Payload.Sub); appErr != nil {
 		return appErr
 	}
 
 	return pHttp.WriteStatus(w, 204)
 }
+
+func routeParamInt(r *http.Request, name string) (int, *kmsErrors.AppError) {
+	str, err := httpctx.GetRouteParam(r.Context(), name)
+	if err != nil {
+		return 0, kmsErrors.NewInternalServerError(err)
+	}
+
+	value, err := strconv.Atoi(str)
+	if err != nil {
+		return 0, kmsErrors.NewAppError(err, "ID must be integer", 400).WithErrorCode(kmsErrors.CodeInvalidParameter)
+	}
+	return value, nil
+}
+
+// For admins who lost both their authenticator and recovery codes
+func (h *Handler) ResetMfa(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	adminToken, err := httpctx.ExtractToken(r.Context())
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	clientId, appErr := routeParamInt(r, "id")
+	if appErr != nil {
+		return appErr
+	}
+
+	if appErr := h.Service.ResetMfa(r.Context(), clientId, adminToken.Payload.Sub); appErr != nil {
+		return appErr
+	}
+
+	return pHttp.WriteStatus(w, http.StatusNoContent)
+}
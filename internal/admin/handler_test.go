@@ -16,7 +16,7 @@ import (
 
 func TestHandler_UpdateRole_Success(t *testing.T) {
 	mockService := NewAdminServiceMock()
-	mockService.UpdateRoleFunc = func(clientId int, role string, adminId string) *kmsErrors.AppError {
+	mockService.UpdateRoleFunc = func(ctx context.Context, clientId int, role string, adminId string) *kmsErrors.AppError {
 		return nil // Simulate successful role update
 	}
 	mockLogger := mocks.NewLoggerMock()
@@ -158,7 +158,7 @@ func TestHandler_UpdateRole_ParseBodyError(t *testing.T) {
 
 func TestHandler_UpdateRole_ServiceError(t *testing.T) {
 	mockService := NewAdminServiceMock()
-	mockService.UpdateRoleFunc = func(clientId int, role string, adminId string) *kmsErrors.AppError {
+	mockService.UpdateRoleFunc = func(ctx context.Context, clientId int, role string, adminId string) *kmsErrors.AppError {
 		return kmsErrors.NewAppError(nil, "service error", 500)
 	}
 	mockLogger := mocks.NewLoggerMock()
@@ -190,7 +190,7 @@ func TestHandler_UpdateRole_ServiceError(t *testing.T) {
 
 func TestHandler_GenerateSignupToken_Success(t *testing.T) {
 	mockService := NewAdminServiceMock()
-	mockService.GenerateSignupTokenFunc = func(body *GenerateSignupTokenRequest, adminId string) (string, *kmsErrors.AppError) {
+	mockService.GenerateSignupTokenFunc = func(ctx context.Context, body *GenerateSignupTokenRequest, adminId string) (string, *kmsErrors.AppError) {
 		return "jwt", nil
 	}
 	mockLogger := mocks.NewLoggerMock()
@@ -292,7 +292,7 @@ func TestHandler_GenerateSignupToken_InvalidBodyError(t *testing.T) {
 
 func TestHandler_GenerateSignupToken_ServiceError(t *testing.T) {
 	mockService := NewAdminServiceMock()
-	mockService.GenerateSignupTokenFunc = func(body *GenerateSignupTokenRequest, adminId string) (string, *kmsErrors.AppError) {
+	mockService.GenerateSignupTokenFunc = func(ctx context.Context, body *GenerateSignupTokenRequest, adminId string) (string, *kmsErrors.AppError) {
 		return "", kmsErrors.NewAppError(nil, "service error", 500)
 	}
 	mockLogger := mocks.NewLoggerMock()
@@ -328,7 +328,7 @@ func TestHandler_GetClients_Success(t *testing.T) {
 		},
 	}
 	mockService := NewAdminServiceMock()
-	mockService.GetClientsFunc = func() ([]clients.Client, *kmsErrors.AppError) {
+	mockService.GetClientsFunc = func(ctx context.Context) ([]clients.Client, *kmsErrors.AppError) {
 		return u, nil
 	}
 	mockLogger := mocks.NewLoggerMock()
@@ -346,7 +346,7 @@ func TestHandler_GetClients_Success(t *testing.T) {
 
 func TestHandler_GetClients_ServiceError(t *testing.T) {
 	mockService := NewAdminServiceMock()
-	mockService.GetClientsFunc = func() ([]clients.Client, *kmsErrors.AppError) {
+	mockService.GetClientsFunc = func(ctx context.Context) ([]clients.Client, *kmsErrors.AppError) {
 		return nil, kmsErrors.NewAppError(nil, "service error", 500)
 	}
 	mockLogger := mocks.NewLoggerMock()
@@ -366,7 +366,7 @@ func TestHandler_GetClients_ServiceError(t *testing.T) {
 
 func TestHandler_DeleteClient_Success(t *testing.T) {
 	mockService := NewAdminServiceMock()
-	mockService.DeleteClientFunc = func(clientId int) *kmsErrors.AppError {
+	mockService.DeleteClientFunc = func(ctx context.Context, clientId int) *kmsErrors.AppError {
 		return nil
 	}
 	mockLogger := mocks.NewLoggerMock()
@@ -412,7 +412,7 @@ func TestHandler_DeleteClient_MissingRouteParam(t *testing.T) {
 
 func TestHandler_DeleteClient_NonIntParam(t *testing.T) {
 	mockService := NewAdminServiceMock()
-	mockService.DeleteClientFunc = func(clientId int) *kmsErrors.AppError {
+	mockService.DeleteClientFunc = func(ctx context.Context, clientId int) *kmsErrors.AppError {
 		return nil
 	}
 	mockLogger := mocks.NewLoggerMock()
@@ -439,7 +439,7 @@ func TestHandler_DeleteClient_NonIntParam(t *testing.T) {
 
 func TestHandler_DeleteClient_ServiceError(t *testing.T) {
 	mockService := NewAdminServiceMock()
-	mockService.DeleteClientFunc = func(clientId int) *kmsErrors.AppError {
+	mockService.DeleteClientFunc = func(ctx context.Context, clientId int) *kmsErrors.AppError {
 		return kmsErrors.NewAppError(nil, "service error", 500)
 	}
 	mockLogger := mocks.NewLoggerMock()
@@ -459,3 +459,139 @@ func TestHandler_DeleteClient_ServiceError(t *testing.T) {
 
 	test.RequireContains(t, err.Message, "service error")
 }
+
+func TestHandler_BindCertificate_Success(t *testing.T) {
+	mockService := NewAdminServiceMock()
+	mockService.BindCertificateFunc = func(ctx context.Context, clientId int, body *BindCertificateRequest, adminId string) (*clients.Certificate, *kmsErrors.AppError) {
+		if clientId != 1 || body.Identity != "cn:payments" || adminId != "admin-id" {
+			t.Errorf("unexpected arguments: %d, %+v, %s", clientId, body, adminId)
+		}
+		return &clients.Certificate{ID: 3, ClientId: clientId, HashedIdentity: "hashed"}, nil
+	}
+	handler := NewHandler(mockService, mocks.NewLoggerMock())
+
+	req := httptest.NewRequest("POST", "/clients/1/certificates/actions/bind", strings.NewReader(`{"identity": "cn:payments"}`))
+	ctx := context.WithValue(req.Context(), httpctx.TokenCtxKey, auth.Token{
+		Payload: &auth.TokenPayload{Sub: "admin-id"},
+	})
+	ctx = context.WithValue(ctx, httpctx.RouteParamsCtxKey, map[string]string{"id": "1"})
+	req = req.WithContext(ctx)
+	rr := httptest.NewRecorder()
+
+	if appErr := handler.BindCertificate(rr, req); appErr != nil {
+		t.Fatalf("handler returned an error: %v", appErr)
+	}
+	if !strings.Contains(rr.Body.String(), `"id":3,"clientId":1,"hashedIdentity":"hashed"`) {
+		t.Errorf("unexpected body: %s", rr.Body.String())
+	}
+}
+
+func TestHandler_BindCertificate_InvalidIdentity(t *testing.T) {
+	handler := NewHandler(NewAdminServiceMock(), mocks.NewLoggerMock())
+
+	req := httptest.NewRequest("POST", "/clients/1/certificates/actions/bind", strings.NewReader(`{"identity": "payments"}`))
+	ctx := context.WithValue(req.Context(), httpctx.TokenCtxKey, auth.Token{
+		Payload: &auth.TokenPayload{Sub: "admin-id"},
+	})
+	ctx = context.WithValue(ctx, httpctx.RouteParamsCtxKey, map[string]string{"id": "1"})
+	req = req.WithContext(ctx)
+	rr := httptest.NewRecorder()
+
+	appErr := handler.BindCertificate(rr, req)
+	if appErr == nil || appErr.Code != 400 {
+		t.Fatalf("expected 400, got %v", appErr)
+	}
+}
+
+func TestHandler_UnbindCertificate_Success(t *testing.T) {
+	mockService := NewAdminServiceMock()
+	mockService.UnbindCertificateFunc = func(ctx context.Context, clientId, certId int, adminId string) *kmsErrors.AppError {
+		if clientId != 1 || certId != 3 {
+			t.Errorf("unexpected ids: %d, %d", clientId, certId)
+		}
+		return nil
+	}
+	handler := NewHandler(mockService, mocks.NewLoggerMock())
+
+	req := httptest.NewRequest("DELETE", "/clients/1/certificates/3", nil)
+	ctx := context.WithValue(req.Context(), httpctx.TokenCtxKey, auth.Token{
+		Payload: &auth.TokenPayload{Sub: "admin-id"},
+	})
+	ctx = context.WithValue(ctx, httpctx.RouteParamsCtxKey, map[string]string{"id": "1", "certId": "3"})
+	req = req.WithContext(ctx)
+	rr := httptest.NewRecorder()
+
+	if appErr := handler.UnbindCertificate(rr, req); appErr != nil {
+		t.Fatalf("handler returned an error: %v", appErr)
+	}
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", rr.Code)
+	}
+}
+
+func TestHandler_GenerateResetToken_InvalidTtl(t *testing.T) {
+	handler := NewHandler(NewAdminServiceMock(), mocks.NewLoggerMock())
+
+	req := httptest.NewRequest("POST", "/clients/1/password/actions/reset", strings.NewReader(`{"ttl": -1}`))
+	ctx := context.WithValue(req.Context(), httpctx.TokenCtxKey, auth.Token{
+		Payload: &auth.TokenPayload{Sub: "admin-id"},
+	})
+	ctx = context.WithValue(ctx, httpctx.RouteParamsCtxKey, map[string]string{"id": "1"})
+	req = req.WithContext(ctx)
+	rr := httptest.NewRecorder()
+
+	appErr := handler.GenerateResetToken(rr, req)
+	if appErr == nil || appErr.Code != 400 {
+		t.Fatalf("expected 400, got %v", appErr)
+	}
+}
+
+func TestHandler_UnlockClient(t *testing.T) {
+	var gotClientId int
+	mockService := NewAdminServiceMock()
+	mockService.UnlockClientFunc = func(ctx context.Context, clientId int, adminId string) *kmsErrors.AppError {
+		gotClientId = clientId
+		return nil
+	}
+	handler := NewHandler(mockService, mocks.NewLoggerMock())
+
+	req := httptest.NewRequest("POST", "/clients/4/actions/unlock", nil)
+	ctx := context.WithValue(req.Context(), httpctx.TokenCtxKey, auth.Token{
+		Payload: &auth.TokenPayload{Sub: "admin-id"},
+	})
+	ctx = context.WithValue(ctx, httpctx.RouteParamsCtxKey, map[string]string{"id": "4"})
+	req = req.WithContext(ctx)
+	rr := httptest.NewRecorder()
+
+	if appErr := handler.UnlockClient(rr, req); appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	if rr.Code != 204 || gotClientId != 4 {
+		t.Errorf("expected 204 for client 4, got %d for client %d", rr.Code, gotClientId)
+	}
+}
+
+func TestHandler_ResetMfa(t *testing.T) {
+	var gotClientId int
+	mockService := NewAdminServiceMock()
+	mockService.ResetMfaFunc = func(ctx context.Context, clientId int, adminId string) *kmsErrors.AppError {
+		gotClientId = clientId
+		return nil
+	}
+	handler := NewHandler(mockService, mocks.NewLoggerMock())
+
+	req := httptest.NewRequest("POST", "/clients/4/mfa/actions/reset", nil)
+	ctx := context.WithValue(req.Context(), httpctx.TokenCtxKey, auth.Token{
+		Payload: &auth.TokenPayload{Sub: "admin-id"},
+	})
+	ctx = context.WithValue(ctx, httpctx.RouteParamsCtxKey, map[string]string{"id": "4"})
+	req = req.WithContext(ctx)
+	rr := httptest.NewRecorder()
+
+	if appErr := handler.ResetMfa(rr, req); appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	if rr.Code != 204 || gotClientId != 4 {
+		t.Errorf("expected 204 for client 4, got %d for client %d", rr.Code, gotClientId)
+	}
+}
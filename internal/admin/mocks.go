@@ -1,6 +1,7 @@
 package admin
 
 import (
+	"context"
 	"errors"
 	"kms/internal/clients"
 	kmsErrors "kms/pkg/errors"
@@ -8,64 +9,112 @@ import (
 
 // Repository mock for Admin operations
 type AdminRepositoryMock struct {
-	GetAdminFunc func(id int) (*clients.Client, error)
+	GetAdminFunc func(ctx context.Context, id int) (*clients.Client, error)
 }
 
 func NewAdminRepositoryMock() *AdminRepositoryMock {
 	return &AdminRepositoryMock{}
 }
 
-func (m *AdminRepositoryMock) GetAdmin(id int) (*clients.Client, error) {
+func (m *AdminRepositoryMock) GetAdmin(ctx context.Context, id int) (*clients.Client, error) {
 	if m.GetAdminFunc != nil {
-		return m.GetAdminFunc(id)
+		return m.GetAdminFunc(ctx, id)
 	}
 	return nil, errors.New("GetAdminFunc not implemented in mock")
 }
 
 // Service mock for Admin operations
 type AdminServiceMock struct {
-	UpdateRoleFunc          func(clientId int, role string, adminId string) *kmsErrors.AppError
-	MeFunc                  func(id int) (*clients.Client, *kmsErrors.AppError)
-	GenerateSignupTokenFunc func(body *GenerateSignupTokenRequest, adminId string) (string, *kmsErrors.AppError)
-	GetClientsFunc          func() ([]clients.Client, *kmsErrors.AppError)
-	DeleteClientFunc        func(clientId int) *kmsErrors.AppError
+	UpdateRoleFunc          func(ctx context.Context, clientId int, role string, adminId string) *kmsErrors.AppError
+	MeFunc                  func(ctx context.Context, id int) (*clients.Client, *kmsErrors.AppError)
+	GenerateSignupTokenFunc func(ctx context.Context, body *GenerateSignupTokenRequest, adminId string) (string, *kmsErrors.AppError)
+	GenerateResetTokenFunc  func(ctx context.Context, clientId int, body *GenerateResetTokenRequest, adminId string) (string, *kmsErrors.AppError)
+	UnlockClientFunc        func(ctx context.Context, clientId int, adminId string) *kmsErrors.AppError
+	ResetMfaFunc            func(ctx context.Context, clientId int, adminId string) *kmsErrors.AppError
+	GetClientsFunc          func(ctx context.Context) ([]clients.Client, *kmsErrors.AppError)
+	DeleteClientFunc        func(ctx context.Context, clientId int) *kmsErrors.AppError
+	BindCertificateFunc     func(ctx context.Context, clientId int, body *BindCertificateRequest, adminId string) (*clients.Certificate, *kmsErrors.AppError)
+	GetCertificatesFunc     func(ctx context.Context, clientId int) ([]clients.Certificate, *kmsErrors.AppError)
+	UnbindCertificateFunc   func(ctx context.Context, clientId, certId int, adminId string) *kmsErrors.AppError
 }
 
 func NewAdminServiceMock() *AdminServiceMock {
 	return &AdminServiceMock{}
 }
 
-func (m *AdminServiceMock) UpdateRole(clientId int, role string, adminId string) *kmsErrors.AppError {
+func (m *AdminServiceMock) UpdateRole(ctx context.Context, clientId int, role string, adminId string) *kmsErrors.AppError {
 	if m.UpdateRoleFunc != nil {
-		return m.UpdateRoleFunc(clientId, role, adminId)
+		return m.UpdateRoleFunc(ctx, clientId, role, adminId)
 	}
 	return kmsErrors.LiftToAppError(errors.New("UpdateRoleFunc not implemented in mock"))
 }
 
-func (m *AdminServiceMock) Me(clientId int) (*clients.Client, *kmsErrors.AppError) {
+func (m *AdminServiceMock) Me(ctx context.Context, clientId int) (*clients.Client, *kmsErrors.AppError) {
 	if m.MeFunc != nil {
-		return m.MeFunc(clientId)
+		return m.MeFunc(ctx, clientId)
 	}
 	return nil, kmsErrors.LiftToAppError(errors.New("MeFunc not implemented in mock"))
 }
 
-func (m *AdminServiceMock) GenerateSignupToken(body *GenerateSignupTokenRequest, adminId string) (string, *kmsErrors.AppError) {
+func (m *AdminServiceMock) GenerateSignupToken(ctx context.Context, body *GenerateSignupTokenRequest, adminId string) (string, *kmsErrors.AppError) {
 	if m.GenerateSignupTokenFunc != nil {
-		return m.GenerateSignupTokenFunc(body, adminId)
+		return m.GenerateSignupTokenFunc(ctx, body, adminId)
 	}
 	return "", kmsErrors.LiftToAppError(errors.New("GenerateSignupTokenFunc not implemented in mock"))
 }
 
-func (m *AdminServiceMock) GetClients() ([]clients.Client, *kmsErrors.AppError) {
+func (m *AdminServiceMock) GenerateResetToken(ctx context.Context, clientId int, body *GenerateResetTokenRequest, adminId string) (string, *kmsErrors.AppError) {
+	if m.GenerateResetTokenFunc != nil {
+		return m.GenerateResetTokenFunc(ctx, clientId, body, adminId)
+	}
+	return "", kmsErrors.LiftToAppError(errors.New("GenerateResetTokenFunc not implemented in mock"))
+}
+
+func (m *AdminServiceMock) UnlockClient(ctx context.Context, clientId int, adminId string) *kmsErrors.AppError {
+	if m.UnlockClientFunc != nil {
+		return m.UnlockClientFunc(ctx, clientId, adminId)
+	}
+	return kmsErrors.LiftToAppError(errors.New("UnlockClientFunc not implemented in mock"))
+}
+
+func (m *AdminServiceMock) ResetMfa(ctx context.Context, clientId int, adminId string) *kmsErrors.AppError {
+	if m.ResetMfaFunc != nil {
+		return m.ResetMfaFunc(ctx, clientId, adminId)
+	}
+	return kmsErrors.LiftToAppError(errors.New("ResetMfaFunc not implemented in mock"))
+}
+
+func (m *AdminServiceMock) GetClients(ctx context.Context) ([]clients.Client, *kmsErrors.AppError) {
 	if m.GetClientsFunc != nil {
-		return m.GetClientsFunc()
+		return m.GetClientsFunc(ctx)
 	}
 	return nil, kmsErrors.LiftToAppError(errors.New("GetClientsFunc not implemented in mock"))
 }
 
-func (m *AdminServiceMock) DeleteClient(clientId int) *kmsErrors.AppError {
+func (m *AdminServiceMock) DeleteClient(ctx context.Context, clientId int) *kmsErrors.AppError {
 	if m.DeleteClientFunc != nil {
-		return m.DeleteClientFunc(clientId)
+		return m.DeleteClientFunc(ctx, clientId)
 	}
 	return kmsErrors.LiftToAppError(errors.New("DeleteClientFunc not implemented in mock"))
 }
+
+func (m *AdminServiceMock) BindCertificate(ctx context.Context, clientId int, body *BindCertificateRequest, adminId string) (*clients.Certificate, *kmsErrors.AppError) {
+	if m.BindCertificateFunc != nil {
+		return m.BindCertificateFunc(ctx, clientId, body, adminId)
+	}
+	return nil, kmsErrors.LiftToAppError(errors.New("BindCertificateFunc not implemented in mock"))
+}
+
+func (m *AdminServiceMock) GetCertificates(ctx context.Context, clientId int) ([]clients.Certificate, *kmsErrors.AppError) {
+	if m.GetCertificatesFunc != nil {
+		return m.GetCertificatesFunc(ctx, clientId)
+	}
+	return nil, kmsErrors.LiftToAppError(errors.New("GetCertificatesFunc not implemented in mock"))
+}
+
+func (m *AdminServiceMock) UnbindCertificate(ctx context.Context, clientId, certId int, adminId string) *kmsErrors.AppError {
+	if m.UnbindCertificateFunc != nil {
+		return m.UnbindCertificateFunc(ctx, clientId, certId, adminId)
+	}
+	return kmsErrors.LiftToAppError(errors.New("UnbindCertificateFunc not implemented in mock"))
+}
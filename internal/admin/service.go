@@ -1,41 +1,43 @@
 package admin
 
 import (
-	"fmt"
+	"context"
 	"kms/internal/auth"
 	c "kms/internal/bootstrap/context"
 	"kms/internal/clients"
 	kmsErrors "kms/pkg/errors"
-	"unicode"
+	"kms/pkg/hashing"
 )
 
 type Service struct {
-	AdminRepo  AdminRepository
-	ClientRepo clients.ClientRepository
-	KeyManager c.KeyManager
-	Logger     c.Logger
+	AdminRepo    AdminRepository
+	ClientRepo   clients.ClientRepository
+	KeyManager   c.KeyManager
+	LoginLimiter *auth.LoginLimiter
+	Logger       c.Logger
 }
 
-func NewService(adminRepo AdminRepository, clientRepo clients.ClientRepository, keyManager c.KeyManager, logger c.Logger) *Service {
+func NewService(adminRepo AdminRepository, clientRepo clients.ClientRepository, keyManager c.KeyManager, loginLimiter *auth.LoginLimiter, logger c.Logger) *Service {
 	return &Service{
-		AdminRepo:  adminRepo,
-		ClientRepo: clientRepo,
-		KeyManager: keyManager,
-		Logger:     logger,
+		AdminRepo:    adminRepo,
+		ClientRepo:   clientRepo,
+		KeyManager:   keyManager,
+		LoginLimiter: loginLimiter,
+		Logger:       logger,
 	}
 }
 
 type AdminRepository interface {
-	GetAdmin(id int) (*clients.Client, error)
+	GetAdmin(ctx context.Context, id int) (*clients.Client, error)
 }
 
-func (s *Service) UpdateRole(clientId int, role string, adminId string) *kmsErrors.AppError {
-	oldRole, err := s.ClientRepo.GetRole(clientId)
+func (s *Service) UpdateRole(ctx context.Context, clientId int, role string, adminId string) *kmsErrors.AppError {
+	oldRole, err := s.ClientRepo.GetRole(ctx, clientId)
 	if err != nil {
 		return kmsErrors.MapRepoErr(err)
 	}
 
-	if err := s.ClientRepo.UpdateRole(clientId, role); err != nil {
+	if err := s.ClientRepo.UpdateRole(ctx, clientId, role); err != nil {
 		return kmsErrors.MapRepoErr(err)
 	}
 
@@ -44,16 +46,16 @@ func (s *Service) UpdateRole(clientId int, role string, adminId string) *kmsErro
 	return nil
 }
 
-func (s *Service) Me(clientId int) (*clients.Client, *kmsErrors.AppError) {
-	admin, err := s.ClientRepo.GetClient(clientId)
+func (s *Service) Me(ctx context.Context, clientId int) (*clients.Client, *kmsErrors.AppError) {
+	admin, err := s.ClientRepo.GetClient(ctx, clientId)
 	if err != nil {
 		return nil, kmsErrors.MapRepoErr(err)
 	}
 	return admin, nil
 }
 
-func (s *Service) GenerateSignupToken(body *GenerateSignupTokenRequest, adminId string) (string, *kmsErrors.AppError) {
-	if err := ValidateClientname(body.Clientname); err != nil {
+func (s *Service) GenerateSignupToken(ctx context.Context, body *GenerateSignupTokenRequest, adminId string) (string, *kmsErrors.AppError) {
+	if err := clients.ValidateClientname(body.Clientname); err != nil {
 		return "", kmsErrors.NewAppError(
 			kmsErrors.WrapError(err, map[string]any{
 				"clientname": body.Clientname,
@@ -79,8 +81,61 @@ func (s *Service) GenerateSignupToken(body *GenerateSignupTokenRequest, adminId
 	return token, nil
 }
 
-func (s *Service) GetClients() ([]clients.Client, *kmsErrors.AppError) {
-	clients, err := s.ClientRepo.GetAll()
+// One-time token the client can set a new password with, see auth.ResetPassword
+func (s *Service) GenerateResetToken(ctx context.Context, clientId int, body *GenerateResetTokenRequest, adminId string) (string, *kmsErrors.AppError) {
+	client, err := s.ClientRepo.GetClient(ctx, clientId)
+	if err != nil {
+		return "", kmsErrors.MapRepoErr(err)
+	}
+
+	ttl := body.Ttl
+	if ttl == 0 {
+		ttl = DefaultResetTokenTtl
+	}
+
+	tokenGenInfo := &auth.TokenGenInfo{
+		Ttl:    ttl,
+		Secret: s.KeyManager.SignupKey(),
+		Typ:    "reset",
+	}
+
+	token, err := auth.GenerateResetToken(tokenGenInfo, client)
+	if err != nil {
+		return "", kmsErrors.NewInternalServerError(err)
+	}
+
+	s.Logger.Notice("Generated password reset token", "adminId", adminId, "clientId", clientId)
+
+	return token, nil
+}
+
+// Lifts a lockout after failed logins, see auth.LoginLimiter
+func (s *Service) UnlockClient(ctx context.Context, clientId int, adminId string) *kmsErrors.AppError {
+	client, err := s.ClientRepo.GetClient(ctx, clientId)
+	if err != nil {
+		return kmsErrors.MapRepoErr(err)
+	}
+
+	wasLocked := s.LoginLimiter.Unlock(client.HashedClientname)
+
+	s.Logger.Notice("Client unlocked", "adminId", adminId, "clientId", clientId, "wasLocked", wasLocked)
+
+	return nil
+}
+
+// Removes the client's MFA, they have to enroll again on their next login
+func (s *Service) ResetMfa(ctx context.Context, clientId int, adminId string) *kmsErrors.AppError {
+	if err := s.ClientRepo.DeleteMfa(ctx, clientId); err != nil {
+		return kmsErrors.MapRepoErr(err)
+	}
+
+	s.Logger.Notice("Client MFA reset", "adminId", adminId, "clientId", clientId)
+
+	return nil
+}
+
+func (s *Service) GetClients(ctx context.Context) ([]clients.Client, *kmsErrors.AppError) {
+	clients, err := s.ClientRepo.GetAll(ctx)
 	if err != nil {
 		return nil, kmsErrors.MapRepoErr(err)
 	}
@@ -88,23 +143,55 @@ func (s *Service) GetClients() ([]clients.Client, *kmsErrors.AppError) {
 	return clients, nil
 }
 
-func (s *Service) DeleteClient(clientId int) *kmsErrors.AppError {
-	if err := s.ClientRepo.Delete(clientId); err != nil {
+func (s *Service) DeleteClient(ctx context.Context, clientId int) *kmsErrors.AppError {
+	if err := s.ClientRepo.Delete(ctx, clientId); err != nil {
 		return kmsErrors.MapRepoErr(err)
 	}
 
 	return nil
 }
 
-// Allow 0-9, a-Z and '-' in clientname
-func ValidateClientname(clientname string) error {
-	if len(clientname) < 4 || len(clientname) > 64 {
-		return fmt.Errorf("clientname length should be between 4 and 64, is %d", len(clientname))
+// Lets the client log in with a certificate for the identity, see auth.LoginWithCertificate
+func (s *Service) BindCertificate(ctx context.Context, clientId int, body *BindCertificateRequest, adminId string) (*clients.Certificate, *kmsErrors.AppError) {
+	if _, err := s.ClientRepo.GetClient(ctx, clientId); err != nil {
+		return nil, kmsErrors.MapRepoErr(err)
+	}
+
+	identitySecret, err := s.KeyManager.HashKey("clientname")
+	if err != nil {
+		return nil, kmsErrors.NewInternalServerError(err)
 	}
-	for _, r := range clientname {
-		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-') {
-			return fmt.Errorf("invalid character in clientname (%v): %c", clientname, r)
-		}
+
+	cert := &clients.Certificate{
+		ClientId:       clientId,
+		HashedIdentity: hashing.HashHS256ToB64([]byte(body.Identity), identitySecret),
+		Pin:            body.Pin,
+	}
+	id, err := s.ClientRepo.CreateCertificate(ctx, cert)
+	if err != nil {
+		return nil, kmsErrors.MapRepoErr(err)
 	}
+	cert.ID = id
+
+	s.Logger.Notice("Certificate bound to client", "adminId", adminId, "clientId", clientId, "certificateId", id, "pinned", body.Pin != "")
+
+	return cert, nil
+}
+
+func (s *Service) GetCertificates(ctx context.Context, clientId int) ([]clients.Certificate, *kmsErrors.AppError) {
+	certs, err := s.ClientRepo.GetCertificates(ctx, clientId)
+	if err != nil {
+		return nil, kmsErrors.MapRepoErr(err)
+	}
+	return certs, nil
+}
+
+func (s *Service) UnbindCertificate(ctx context.Context, clientId, certId int, adminId string) *kmsErrors.AppError {
+	if err := s.ClientRepo.DeleteCertificate(ctx, clientId, certId); err != nil {
+		return kmsErrors.MapRepoErr(err)
+	}
+
+	s.Logger.Notice("Certificate unbound from client", "adminId", adminId, "clientId", clientId, "certificateId", certId)
+
 	return nil
 }
@@ -1,12 +1,17 @@
 package admin
 
 import (
+	"context"
+	"database/sql"
 	"errors"
+	"kms/internal/auth"
 	"kms/internal/clients"
 	"kms/internal/test"
 	"kms/internal/test/mocks"
+	kmsErrors "kms/pkg/errors"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestService_UpdateRole_Success(t *testing.T) {
@@ -14,15 +19,15 @@ func TestService_UpdateRole_Success(t *testing.T) {
 	mockClientRepo := clients.NewClientRepositoryMock()
 	mockLogger := mocks.NewLoggerMock()
 
-	mockClientRepo.GetRoleFunc = func(clientId int) (string, error) {
+	mockClientRepo.GetRoleFunc = func(ctx context.Context, clientId int) (string, error) {
 		return "client", nil
 	}
-	mockClientRepo.UpdateRoleFunc = func(clientId int, role string) error {
+	mockClientRepo.UpdateRoleFunc = func(ctx context.Context, clientId int, role string) error {
 		return nil
 	}
 
-	service := NewService(mockRepo, mockClientRepo, nil, mockLogger)
-	err := service.UpdateRole(1, "admin", "admin123")
+	service := NewService(mockRepo, mockClientRepo, nil, nil, mockLogger)
+	err := service.UpdateRole(context.Background(), 1, "admin", "admin123")
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -33,12 +38,12 @@ func TestService_UpdateRole_RepoGetRoleError(t *testing.T) {
 	mockClientRepo := clients.NewClientRepositoryMock()
 	mockLogger := mocks.NewLoggerMock()
 
-	mockClientRepo.GetRoleFunc = func(clientId int) (string, error) {
+	mockClientRepo.GetRoleFunc = func(ctx context.Context, clientId int) (string, error) {
 		return "", errors.New("repo error")
 	}
 
-	service := NewService(mockRepo, mockClientRepo, nil, mockLogger)
-	err := service.UpdateRole(1, "admin", "admin123")
+	service := NewService(mockRepo, mockClientRepo, nil, nil, mockLogger)
+	err := service.UpdateRole(context.Background(), 1, "admin", "admin123")
 	if err == nil || !strings.Contains(err.Err.Error(), "repo error") {
 		t.Fatalf("expected repo error, got %v", err)
 	}
@@ -52,15 +57,15 @@ func TestService_UpdateRole_RepoUpdateRoleError(t *testing.T) {
 	mockClientRepo := clients.NewClientRepositoryMock()
 	mockLogger := mocks.NewLoggerMock()
 
-	mockClientRepo.GetRoleFunc = func(clientId int) (string, error) {
+	mockClientRepo.GetRoleFunc = func(ctx context.Context, clientId int) (string, error) {
 		return "client", nil
 	}
-	mockClientRepo.UpdateRoleFunc = func(clientId int, role string) error {
+	mockClientRepo.UpdateRoleFunc = func(ctx context.Context, clientId int, role string) error {
 		return errors.New("update error")
 	}
 
-	service := NewService(mockRepo, mockClientRepo, nil, mockLogger)
-	err := service.UpdateRole(1, "admin", "admin123")
+	service := NewService(mockRepo, mockClientRepo, nil, nil, mockLogger)
+	err := service.UpdateRole(context.Background(), 1, "admin", "admin123")
 	if err == nil || !strings.Contains(err.Err.Error(), "update error") {
 		t.Fatalf("expected update error, got %v", err)
 	}
@@ -74,12 +79,12 @@ func TestService_Me_Success(t *testing.T) {
 	mockClientRepo := clients.NewClientRepositoryMock()
 	mockLogger := mocks.NewLoggerMock()
 
-	mockClientRepo.GetClientFunc = func(clientId int) (*clients.Client, error) {
+	mockClientRepo.GetClientFunc = func(ctx context.Context, clientId int) (*clients.Client, error) {
 		return &clients.Client{Clientname: "clientname", Role: "admin"}, nil
 	}
 
-	service := NewService(mockRepo, mockClientRepo, nil, mockLogger)
-	admin, err := service.Me(1)
+	service := NewService(mockRepo, mockClientRepo, nil, nil, mockLogger)
+	admin, err := service.Me(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -96,12 +101,12 @@ func TestService_Me_Error(t *testing.T) {
 	mockClientRepo := clients.NewClientRepositoryMock()
 	mockLogger := mocks.NewLoggerMock()
 
-	mockClientRepo.GetClientFunc = func(clientId int) (*clients.Client, error) {
+	mockClientRepo.GetClientFunc = func(ctx context.Context, clientId int) (*clients.Client, error) {
 		return nil, errors.New("repo error")
 	}
 
-	service := NewService(mockRepo, mockClientRepo, nil, mockLogger)
-	admin, err := service.Me(1)
+	service := NewService(mockRepo, mockClientRepo, nil, nil, mockLogger)
+	admin, err := service.Me(context.Background(), 1)
 	if admin != nil {
 		t.Fatalf("expected nil admin, got %v", admin)
 	}
@@ -123,12 +128,12 @@ func TestService_GenerateSignupToken_Success(t *testing.T) {
 		return []byte("test-signup-key")
 	}
 
-	service := NewService(mockRepo, mockClientRepo, mockKeyManager, mockLogger)
+	service := NewService(mockRepo, mockClientRepo, mockKeyManager, nil, mockLogger)
 	body := &GenerateSignupTokenRequest{
 		Clientname: "testclient",
 		Ttl:        3600,
 	}
-	token, err := service.GenerateSignupToken(body, "admin123")
+	token, err := service.GenerateSignupToken(context.Background(), body, "admin123")
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -143,12 +148,12 @@ func TestService_GenerateSignupToken_validateClientnameError(t *testing.T) {
 	mockLogger := mocks.NewLoggerMock()
 	mockKeyManager := mocks.NewKeyManagerMock()
 
-	service := NewService(mockRepo, mockClientRepo, mockKeyManager, mockLogger)
+	service := NewService(mockRepo, mockClientRepo, mockKeyManager, nil, mockLogger)
 	body := &GenerateSignupTokenRequest{
 		Clientname: "invalid@client",
 		Ttl:        3600,
 	}
-	_, err := service.GenerateSignupToken(body, "admin123")
+	_, err := service.GenerateSignupToken(context.Background(), body, "admin123")
 	if err == nil || !strings.Contains(err.Err.Error(), "invalid character in clientname") {
 		t.Fatalf("expected invalid clientname error, got %v", err)
 	}
@@ -157,31 +162,10 @@ func TestService_GenerateSignupToken_validateClientnameError(t *testing.T) {
 	}
 }
 
-func TestService_validateClientname(t *testing.T) {
-	tests := []struct {
-		clientname  string
-		expectError bool
-	}{
-		{"validClient", false},
-		{"valid-client123", false},
-		{"invalid@client", true},
-		{"", true},
-		{"abc", true},
-		{"valid-key-with-maximum-length-12345678901234567890123456789012345678901234567890123456789012345678901234567890", true},
-	}
-	for _, tt := range tests {
-		err := ValidateClientname(tt.clientname)
-		if (err != nil) != tt.expectError {
-			t.Errorf("validateClientname(%q) error = %v, expectError %v", tt.clientname, err, tt.expectError)
-			continue
-		}
-	}
-}
-
 func TestService_GetClients_Success(t *testing.T) {
 	mockAdminRepo := NewAdminRepositoryMock()
 	mockClientRepo := clients.NewClientRepositoryMock()
-	mockClientRepo.GetAllFunc = func() ([]clients.Client, error) {
+	mockClientRepo.GetAllFunc = func(ctx context.Context) ([]clients.Client, error) {
 		return []clients.Client{
 			{ID: 1, Clientname: "clientname"},
 		}, nil
@@ -189,9 +173,9 @@ func TestService_GetClients_Success(t *testing.T) {
 	mockKeyManager := mocks.NewKeyManagerMock()
 	mockLogger := mocks.NewLoggerMock()
 
-	service := NewService(mockAdminRepo, mockClientRepo, mockKeyManager, mockLogger)
+	service := NewService(mockAdminRepo, mockClientRepo, mockKeyManager, nil, mockLogger)
 
-	u, err := service.GetClients()
+	u, err := service.GetClients(context.Background())
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -208,15 +192,15 @@ func TestService_GetClients_Success(t *testing.T) {
 func TestService_GetClients_RepoError(t *testing.T) {
 	mockAdminRepo := NewAdminRepositoryMock()
 	mockClientRepo := clients.NewClientRepositoryMock()
-	mockClientRepo.GetAllFunc = func() ([]clients.Client, error) {
+	mockClientRepo.GetAllFunc = func(ctx context.Context) ([]clients.Client, error) {
 		return nil, errors.New("repo error")
 	}
 	mockKeyManager := mocks.NewKeyManagerMock()
 	mockLogger := mocks.NewLoggerMock()
 
-	service := NewService(mockAdminRepo, mockClientRepo, mockKeyManager, mockLogger)
+	service := NewService(mockAdminRepo, mockClientRepo, mockKeyManager, nil, mockLogger)
 
-	_, err := service.GetClients()
+	_, err := service.GetClients(context.Background())
 	if err == nil {
 		t.Fatal("expected repo error, got nil")
 	}
@@ -227,15 +211,15 @@ func TestService_GetClients_RepoError(t *testing.T) {
 func TestService_DeleteClient_Success(t *testing.T) {
 	mockAdminRepo := NewAdminRepositoryMock()
 	mockClientRepo := clients.NewClientRepositoryMock()
-	mockClientRepo.DeleteFunc = func(clientId int) error {
+	mockClientRepo.DeleteFunc = func(ctx context.Context, clientId int) error {
 		return nil
 	}
 	mockKeyManager := mocks.NewKeyManagerMock()
 	mockLogger := mocks.NewLoggerMock()
 
-	service := NewService(mockAdminRepo, mockClientRepo, mockKeyManager, mockLogger)
+	service := NewService(mockAdminRepo, mockClientRepo, mockKeyManager, nil, mockLogger)
 
-	if err := service.DeleteClient(1); err != nil {
+	if err := service.DeleteClient(context.Background(), 1); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
@@ -243,15 +227,15 @@ func TestService_DeleteClient_Success(t *testing.T) {
 func TestService_DeleteClient_RepoError(t *testing.T) {
 	mockAdminRepo := NewAdminRepositoryMock()
 	mockClientRepo := clients.NewClientRepositoryMock()
-	mockClientRepo.DeleteFunc = func(clientId int) error {
+	mockClientRepo.DeleteFunc = func(ctx context.Context, clientId int) error {
 		return errors.New("repo error")
 	}
 	mockKeyManager := mocks.NewKeyManagerMock()
 	mockLogger := mocks.NewLoggerMock()
 
-	service := NewService(mockAdminRepo, mockClientRepo, mockKeyManager, mockLogger)
+	service := NewService(mockAdminRepo, mockClientRepo, mockKeyManager, nil, mockLogger)
 
-	err := service.DeleteClient(1)
+	err := service.DeleteClient(context.Background(), 1)
 
 	if err == nil {
 		t.Fatal("expected error")
@@ -259,3 +243,144 @@ func TestService_DeleteClient_RepoError(t *testing.T) {
 
 	test.RequireContains(t, err.Err.Error(), "repo error")
 }
+
+func TestService_BindCertificate_Success(t *testing.T) {
+	mockClientRepo := clients.NewClientRepositoryMock()
+	mockClientRepo.GetClientFunc = func(ctx context.Context, id int) (*clients.Client, error) {
+		return &clients.Client{ID: id}, nil
+	}
+	var stored *clients.Certificate
+	mockClientRepo.CreateCertificateFunc = func(ctx context.Context, cert *clients.Certificate) (int, error) {
+		stored = cert
+		return 3, nil
+	}
+	mockKeyManager := mocks.NewKeyManagerMock()
+	mockKeyManager.HashKeyFunc = func(kind string) ([]byte, error) {
+		return []byte("clientnamesecret"), nil
+	}
+
+	service := NewService(NewAdminRepositoryMock(), mockClientRepo, mockKeyManager, nil, mocks.NewLoggerMock())
+	cert, appErr := service.BindCertificate(context.Background(), 1, &BindCertificateRequest{Identity: "cn:payments"}, "admin123")
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	if cert.ID != 3 || cert.ClientId != 1 {
+		t.Errorf("unexpected certificate: %+v", cert)
+	}
+	// identities are stored hashed
+	if stored.HashedIdentity == "" || strings.Contains(stored.HashedIdentity, "payments") {
+		t.Errorf("expected hashed identity, got %s", stored.HashedIdentity)
+	}
+}
+
+func TestService_BindCertificate_ClientNotFound(t *testing.T) {
+	mockClientRepo := clients.NewClientRepositoryMock()
+	mockClientRepo.GetClientFunc = func(ctx context.Context, id int) (*clients.Client, error) {
+		return nil, sql.ErrNoRows
+	}
+
+	service := NewService(NewAdminRepositoryMock(), mockClientRepo, mocks.NewKeyManagerMock(), nil, mocks.NewLoggerMock())
+	_, appErr := service.BindCertificate(context.Background(), 1, &BindCertificateRequest{Identity: "cn:payments"}, "admin123")
+	if appErr == nil || appErr.Code != 404 {
+		t.Fatalf("expected 404, got %v", appErr)
+	}
+}
+
+func TestBindCertificateRequest_Validate(t *testing.T) {
+	tests := []struct {
+		req   BindCertificateRequest
+		valid bool
+	}{
+		{BindCertificateRequest{Identity: "uri:spiffe://mesh/ns/app"}, true},
+		{BindCertificateRequest{Identity: "cn:app", Pin: "47DEQpj8HBSa-_TImW-5JCeuQeRkm5NMpJWZG3hSuFU"}, true},
+		{BindCertificateRequest{Identity: "app"}, false},
+		{BindCertificateRequest{Identity: "cn:"}, false},
+		{BindCertificateRequest{Identity: "ip:10.0.0.1"}, false},
+		{BindCertificateRequest{Identity: "cn:app", Pin: "short"}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.req.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%+v: expected valid=%v, got %v", tt.req, tt.valid, err)
+		}
+	}
+}
+
+func TestService_GenerateResetToken_Success(t *testing.T) {
+	mockClientRepo := clients.NewClientRepositoryMock()
+	mockClientRepo.GetClientFunc = func(ctx context.Context, id int) (*clients.Client, error) {
+		return &clients.Client{ID: id, Password: "hashed"}, nil
+	}
+	mockKeyManager := mocks.NewKeyManagerMock()
+	mockKeyManager.SignupKeyFunc = func() []byte {
+		return []byte("test-signup-key")
+	}
+
+	service := NewService(NewAdminRepositoryMock(), mockClientRepo, mockKeyManager, nil, mocks.NewLoggerMock())
+	token, appErr := service.GenerateResetToken(context.Background(), 3, &GenerateResetTokenRequest{}, "admin123")
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+
+	verified, err := auth.VerifyToken(token, []byte("test-signup-key"))
+	if err != nil {
+		t.Fatalf("expected valid token, got %v", err)
+	}
+	if verified.Header.Typ != "reset" || verified.Payload.Ttl != DefaultResetTokenTtl || !strings.HasPrefix(verified.Payload.Sub, "3:") {
+		t.Errorf("unexpected token: %+v %+v", verified.Header, verified.Payload)
+	}
+}
+
+func TestService_GenerateResetToken_ClientNotFound(t *testing.T) {
+	mockClientRepo := clients.NewClientRepositoryMock()
+	mockClientRepo.GetClientFunc = func(ctx context.Context, id int) (*clients.Client, error) {
+		return nil, sql.ErrNoRows
+	}
+
+	service := NewService(NewAdminRepositoryMock(), mockClientRepo, mocks.NewKeyManagerMock(), nil, mocks.NewLoggerMock())
+	_, appErr := service.GenerateResetToken(context.Background(), 3, &GenerateResetTokenRequest{}, "admin123")
+	if appErr == nil || appErr.Code != 404 {
+		t.Fatalf("expected 404, got %v", appErr)
+	}
+}
+
+func TestService_UnlockClient(t *testing.T) {
+	mockClientRepo := clients.NewClientRepositoryMock()
+	mockClientRepo.GetClientFunc = func(ctx context.Context, id int) (*clients.Client, error) {
+		if id != 3 {
+			return nil, sql.ErrNoRows
+		}
+		return &clients.Client{ID: id, HashedClientname: "hashed"}, nil
+	}
+	limiter := auth.NewLoginLimiter(auth.LoginLimits{MaxFailures: 1, Lockout: time.Minute})
+	limiter.Failed("hashed", "192.0.2.1")
+
+	service := NewService(NewAdminRepositoryMock(), mockClientRepo, mocks.NewKeyManagerMock(), limiter, mocks.NewLoggerMock())
+	if appErr := service.UnlockClient(context.Background(), 3, "admin123"); appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	if wait := limiter.Blocked("hashed", ""); wait != 0 {
+		t.Errorf("expected client to be unlocked, still blocked for %v", wait)
+	}
+
+	if appErr := service.UnlockClient(context.Background(), 4, "admin123"); appErr == nil || appErr.Code != 404 {
+		t.Errorf("expected 404, got %v", appErr)
+	}
+}
+
+func TestService_ResetMfa(t *testing.T) {
+	mockClientRepo := clients.NewClientRepositoryMock()
+	mockClientRepo.DeleteMfaFunc = func(ctx context.Context, clientId int) error {
+		if clientId != 3 {
+			return kmsErrors.ErrNoRowsAffected
+		}
+		return nil
+	}
+
+	service := NewService(NewAdminRepositoryMock(), mockClientRepo, mocks.NewKeyManagerMock(), nil, mocks.NewLoggerMock())
+	if appErr := service.ResetMfa(context.Background(), 3, "admin123"); appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	if appErr := service.ResetMfa(context.Background(), 4, "admin123"); appErr == nil || appErr.Code != 404 {
+		t.Errorf("expected 404, got %v", appErr)
+	}
+}
@@ -10,9 +10,15 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
-func Authorize(jwtSecret []byte) func(httpctx.AppHandler) httpctx.AppHandler {
+type ApiKeyAuthenticator interface {
+	AuthenticateApiKey(ctx context.Context, key string) (*auth.Token, *kmsErrors.AppError)
+}
+
+// Accepts 'Authorization: Bearer <jwt>' or, if apiKeys is set, 'Authorization: ApiKey <key>'
+func Authorize(jwtSecret []byte, apiKeys ApiKeyAuthenticator) func(httpctx.AppHandler) httpctx.AppHandler {
 	return func(next httpctx.AppHandler) httpctx.AppHandler {
 		return func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
 			bearer := strings.TrimSpace(r.Header.Get("Authorization"))
@@ -25,9 +31,18 @@ func Authorize(jwtSecret []byte) func(httpctx.AppHandler) httpctx.AppHandler {
 			}
 
 			parts := strings.Split(bearer, " ")
+			if len(parts) == 2 && parts[0] == "ApiKey" && apiKeys != nil {
+				token, appErr := apiKeys.AuthenticateApiKey(r.Context(), strings.TrimSpace(parts[1]))
+				if appErr != nil {
+					return appErr
+				}
+				ctx := context.WithValue(r.Context(), httpctx.TokenCtxKey, *token)
+				return next(w, r.WithContext(ctx))
+			}
+
 			if len(parts) != 2 || parts[0] != "Bearer" {
 				return kmsErrors.NewAppError(
-					fmt.Errorf("invalid bearer token: %v", bearer),
+					fmt.Errorf("invalid bearer token (%d parts)", len(parts)),
 					"Unauthorized",
 					401,
 				)
@@ -47,7 +62,7 @@ func Authorize(jwtSecret []byte) func(httpctx.AppHandler) httpctx.AppHandler {
 					}),
 					"Unauthorized",
 					401,
-				)
+				).WithErrorCode(kmsErrors.CodeTokenInvalid)
 			}
 
 			ctx := context.WithValue(r.Context(), httpctx.TokenCtxKey, token)
@@ -57,7 +72,9 @@ func Authorize(jwtSecret []byte) func(httpctx.AppHandler) httpctx.AppHandler {
 	}
 }
 
-func RequireAdmin(clientRepo clients.ClientRepository) func(httpctx.AppHandler) httpctx.AppHandler {
+// Tokens of a login without MFA are rejected for clients that have MFA enabled, with mfaRequired (ADMIN_MFA_REQUIRED)
+// for all admins
+func RequireAdmin(clientRepo clients.ClientRepository, mfaRequired bool) func(httpctx.AppHandler) httpctx.AppHandler {
 	return func(next httpctx.AppHandler) httpctx.AppHandler {
 		return func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
 			token, err := httpctx.ExtractToken(r.Context())
@@ -65,12 +82,20 @@ func RequireAdmin(clientRepo clients.ClientRepository) func(httpctx.AppHandler)
 				return kmsErrors.NewInternalServerError(err)
 			}
 
+			if !token.Payload.HasScope(auth.ScopeAdmin) {
+				return kmsErrors.NewAppError(
+					fmt.Errorf("token is missing scope (%v)", auth.ScopeAdmin),
+					"Forbidden",
+					403,
+				)
+			}
+
 			clientId, err := strconv.Atoi(token.Payload.Sub)
 			if err != nil {
 				return kmsErrors.NewInternalServerError(err)
 			}
 
-			role, err := clientRepo.GetRole(clientId)
+			role, err := clientRepo.GetRole(r.Context(), clientId)
 			if err != nil {
 				return kmsErrors.MapRepoErr(err)
 			}
@@ -83,6 +108,88 @@ func RequireAdmin(clientRepo clients.ClientRepository) func(httpctx.AppHandler)
 				)
 			}
 
+			needsMfa := mfaRequired
+			if token.Payload.Mfa == 0 && !needsMfa {
+				needsMfa, err = auth.MfaEnabled(r.Context(), clientRepo, clientId)
+				if err != nil {
+					return kmsErrors.MapRepoErr(err)
+				}
+			}
+			if needsMfa && token.Payload.Mfa == 0 {
+				return kmsErrors.NewAppError(
+					fmt.Errorf("admin token without MFA (clientId: %d)", clientId),
+					"MFA required",
+					403,
+				).WithErrorCode(kmsErrors.CodeMfaRequired)
+			}
+
+			return next(w, r)
+		}
+	}
+}
+
+// Tokens without scopes pass, see auth.TokenPayload
+func RequireScope(scope string) func(httpctx.AppHandler) httpctx.AppHandler {
+	return func(next httpctx.AppHandler) httpctx.AppHandler {
+		return func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+			token, err := httpctx.ExtractToken(r.Context())
+			if err != nil {
+				return kmsErrors.NewInternalServerError(err)
+			}
+
+			if !token.Payload.HasScope(scope) {
+				return kmsErrors.NewAppError(
+					fmt.Errorf("token is missing scope (%v)", scope),
+					"Forbidden",
+					403,
+				)
+			}
+
+			return next(w, r)
+		}
+	}
+}
+
+// For routes that manage the client's own credentials (password, MFA, API keys): scoped tokens, i.e. API keys and the JWTs
+// exchanged for them, are rejected, so a leaked key can't revoke the others or outlive its own revocation
+func RequireUnscoped() func(httpctx.AppHandler) httpctx.AppHandler {
+	return func(next httpctx.AppHandler) httpctx.AppHandler {
+		return func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+			token, err := httpctx.ExtractToken(r.Context())
+			if err != nil {
+				return kmsErrors.NewInternalServerError(err)
+			}
+
+			if len(token.Payload.Scopes) != 0 {
+				return kmsErrors.NewAppError(
+					fmt.Errorf("scoped token on a credential route (%v)", token.Payload.Scopes),
+					"Forbidden",
+					403,
+				)
+			}
+
+			return next(w, r)
+		}
+	}
+}
+
+// For sensitive admin routes, the token must come from a login verified with MFA within maxAge
+func RequireFreshMfa(maxAge time.Duration) func(httpctx.AppHandler) httpctx.AppHandler {
+	return func(next httpctx.AppHandler) httpctx.AppHandler {
+		return func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+			token, err := httpctx.ExtractToken(r.Context())
+			if err != nil {
+				return kmsErrors.NewInternalServerError(err)
+			}
+
+			if !token.Payload.MfaFresh(maxAge) {
+				return kmsErrors.NewAppError(
+					fmt.Errorf("token without MFA in the last %v", maxAge),
+					"Recent MFA verification required",
+					403,
+				).WithErrorCode(kmsErrors.CodeMfaRequired)
+			}
+
 			return next(w, r)
 		}
 	}
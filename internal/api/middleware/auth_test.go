@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"kms/internal/auth"
 	"kms/internal/clients"
@@ -11,6 +12,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestAuthorize_Success(t *testing.T) {
@@ -53,7 +55,7 @@ func TestAuthorize_Success(t *testing.T) {
 	}
 
 	// Create a mock request with the Authorization header
-	handler := Authorize(jwtSecret)(next)
+	handler := Authorize(jwtSecret, nil)(next)
 	req, err := http.NewRequest("GET", "/test", nil)
 	if err != nil {
 		t.Fatalf("failed to create request: %v", err)
@@ -94,7 +96,7 @@ func TestAuthorize_InvalidHeader(t *testing.T) {
 		t.Fatalf("failed to generate wrong token: %v", err)
 	}
 	// Create a handler with the Authorize middleware
-	handler := Authorize(jwtSecret)(next)
+	handler := Authorize(jwtSecret, nil)(next)
 
 	tests := []struct {
 		name     string
@@ -164,9 +166,12 @@ func TestAuthorize_InvalidHeader(t *testing.T) {
 func TestRequireAdmin_Success(t *testing.T) {
 	// Mock client repository
 	clientRepo := clients.NewClientRepositoryMock()
-	clientRepo.GetRoleFunc = func(clientID int) (string, error) {
+	clientRepo.GetRoleFunc = func(ctx context.Context, clientID int) (string, error) {
 		return "admin", nil // Mock admin role
 	}
+	clientRepo.GetMfaFunc = func(ctx context.Context, clientId int) (*clients.Mfa, error) {
+		return nil, sql.ErrNoRows
+	}
 
 	// Mock the next handler
 	next := func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
@@ -174,7 +179,7 @@ func TestRequireAdmin_Success(t *testing.T) {
 		return nil
 	}
 
-	handler := RequireAdmin(clientRepo)(next)
+	handler := RequireAdmin(clientRepo, false)(next)
 
 	req, err := http.NewRequest("GET", "/admin", nil)
 	if err != nil {
@@ -204,11 +209,11 @@ func TestRequireAdmin_Success(t *testing.T) {
 
 func TestRequireAdmin_Error(t *testing.T) {
 	clientRepoError := clients.NewClientRepositoryMock()
-	clientRepoError.GetRoleFunc = func(clientID int) (string, error) {
+	clientRepoError.GetRoleFunc = func(ctx context.Context, clientID int) (string, error) {
 		return "", errors.New("repo error") // Mock repository error
 	}
 	clientRepoForbidden := clients.NewClientRepositoryMock()
-	clientRepoForbidden.GetRoleFunc = func(clientID int) (string, error) {
+	clientRepoForbidden.GetRoleFunc = func(ctx context.Context, clientID int) (string, error) {
 		return "client", nil // Mock non-admin role
 	}
 
@@ -242,10 +247,19 @@ func TestRequireAdmin_Error(t *testing.T) {
 			}},
 			wantCode: 403,
 		},
+		{
+			name:       "Missing admin scope",
+			clientRepo: clientRepoError, // Will not be called
+			token: auth.Token{Payload: &auth.TokenPayload{
+				Sub:    "1",
+				Scopes: []string{auth.ScopeKeysRead},
+			}},
+			wantCode: 403,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			handler := RequireAdmin(tt.clientRepo)(func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+			handler := RequireAdmin(tt.clientRepo, false)(func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
 				return nil // This handler should not be called
 			})
 
@@ -271,7 +285,7 @@ func TestRequireAdmin_Error(t *testing.T) {
 func TestRequireAdmin_MissingToken(t *testing.T) {
 	// Mock client repository
 	clientRepo := clients.NewClientRepositoryMock()
-	clientRepo.GetRoleFunc = func(clientID int) (string, error) {
+	clientRepo.GetRoleFunc = func(ctx context.Context, clientID int) (string, error) {
 		return "admin", nil // Mock admin role
 	}
 
@@ -280,7 +294,7 @@ func TestRequireAdmin_MissingToken(t *testing.T) {
 		return nil // This handler should not be called
 	}
 
-	handler := RequireAdmin(clientRepo)(next)
+	handler := RequireAdmin(clientRepo, false)(next)
 
 	req, err := http.NewRequest("GET", "/admin", nil)
 	if err != nil {
@@ -299,3 +313,193 @@ func TestRequireAdmin_MissingToken(t *testing.T) {
 		t.Errorf("handler returned wrong message: got %v want %v", appErr.Message, "Internal server error")
 	}
 }
+
+type apiKeyAuthenticatorMock func(key string) (*auth.Token, *kmsErrors.AppError)
+
+func (m apiKeyAuthenticatorMock) AuthenticateApiKey(ctx context.Context, key string) (*auth.Token, *kmsErrors.AppError) {
+	return m(key)
+}
+
+func TestAuthorize_ApiKey(t *testing.T) {
+	apiKeys := apiKeyAuthenticatorMock(func(key string) (*auth.Token, *kmsErrors.AppError) {
+		if key != "kms_0123abcd_secret" {
+			return nil, kmsErrors.NewAppError(errors.New("invalid API key"), "Unauthorized", 401)
+		}
+		return &auth.Token{
+			Header:  &auth.TokenHeader{Ver: "1", Typ: "apikey"},
+			Payload: &auth.TokenPayload{Sub: "3", Scopes: []string{auth.ScopeKeysRead}},
+		}, nil
+	})
+	next := func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+		token, err := httpctx.ExtractToken(r.Context())
+		if err != nil || token.Payload.Sub != "3" {
+			t.Errorf("expected token of client 3 in context, got %v (%v)", token, err)
+		}
+		return nil
+	}
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Authorization", "ApiKey kms_0123abcd_secret")
+	if appErr := Authorize([]byte("testsecret"), apiKeys)(next)(httptest.NewRecorder(), req); appErr != nil {
+		t.Fatalf("handler returned an error: %v", appErr)
+	}
+
+	req.Header.Set("Authorization", "ApiKey kms_0123abcd_other")
+	if appErr := Authorize([]byte("testsecret"), apiKeys)(next)(httptest.NewRecorder(), req); appErr == nil || appErr.Code != 401 {
+		t.Errorf("expected 401, got %v", appErr)
+	}
+
+	// not accepted without an authenticator
+	req.Header.Set("Authorization", "ApiKey kms_0123abcd_secret")
+	if appErr := Authorize([]byte("testsecret"), nil)(next)(httptest.NewRecorder(), req); appErr == nil || appErr.Code != 401 {
+		t.Errorf("expected 401, got %v", appErr)
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	next := func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+		return nil
+	}
+	handler := RequireScope(auth.ScopeKeysWrite)(next)
+
+	tests := []struct {
+		name     string
+		scopes   []string
+		wantCode int
+	}{
+		{name: "Unscoped", scopes: nil},
+		{name: "Has scope", scopes: []string{auth.ScopeKeysRead, auth.ScopeKeysWrite}},
+		{name: "Missing scope", scopes: []string{auth.ScopeKeysRead}, wantCode: 403},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/keys/actions/generate", nil)
+			ctx := context.WithValue(req.Context(), httpctx.TokenCtxKey, auth.Token{
+				Payload: &auth.TokenPayload{Sub: "1", Scopes: tt.scopes},
+			})
+
+			appErr := handler(httptest.NewRecorder(), req.WithContext(ctx))
+			if tt.wantCode == 0 && appErr != nil {
+				t.Fatalf("expected no error, got %v", appErr)
+			}
+			if tt.wantCode != 0 && (appErr == nil || appErr.Code != tt.wantCode) {
+				t.Errorf("expected %d, got %v", tt.wantCode, appErr)
+			}
+		})
+	}
+}
+
+func TestRequireUnscoped(t *testing.T) {
+	next := func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+		return nil
+	}
+	handler := RequireUnscoped()(next)
+
+	tests := []struct {
+		name     string
+		scopes   []string
+		wantCode int
+	}{
+		{name: "Unscoped", scopes: nil},
+		{name: "Read only", scopes: []string{auth.ScopeKeysRead}, wantCode: 403},
+		{name: "All scopes", scopes: auth.Scopes, wantCode: 403},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/auth/api-keys", nil)
+			ctx := context.WithValue(req.Context(), httpctx.TokenCtxKey, auth.Token{
+				Payload: &auth.TokenPayload{Sub: "1", Scopes: tt.scopes},
+			})
+
+			appErr := handler(httptest.NewRecorder(), req.WithContext(ctx))
+			if tt.wantCode == 0 && appErr != nil {
+				t.Fatalf("expected no error, got %v", appErr)
+			}
+			if tt.wantCode != 0 && (appErr == nil || appErr.Code != tt.wantCode) {
+				t.Errorf("expected %d, got %v", tt.wantCode, appErr)
+			}
+		})
+	}
+}
+
+func TestRequireAdmin_MfaRequired(t *testing.T) {
+	clientRepo := clients.NewClientRepositoryMock()
+	clientRepo.GetRoleFunc = func(ctx context.Context, clientID int) (string, error) {
+		return "admin", nil
+	}
+	handler := RequireAdmin(clientRepo, true)(func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+		return nil
+	})
+
+	for _, tt := range []struct {
+		mfa      int64
+		wantCode int
+	}{
+		{0, 403},
+		{time.Now().Add(-time.Hour).UnixMilli(), 0},
+	} {
+		req := httptest.NewRequest("GET", "/clients", nil)
+		ctx := context.WithValue(req.Context(), httpctx.TokenCtxKey, auth.Token{Payload: &auth.TokenPayload{Sub: "1", Mfa: tt.mfa}})
+		appErr := handler(httptest.NewRecorder(), req.WithContext(ctx))
+		if (appErr == nil && tt.wantCode != 0) || (appErr != nil && appErr.Code != tt.wantCode) {
+			t.Errorf("mfa %d: expected %d, got %v", tt.mfa, tt.wantCode, appErr)
+		}
+	}
+}
+
+// Without ADMIN_MFA_REQUIRED, admins that enabled MFA still need a token of a login with MFA
+func TestRequireAdmin_MfaEnabled(t *testing.T) {
+	clientRepo := clients.NewClientRepositoryMock()
+	clientRepo.GetRoleFunc = func(ctx context.Context, clientID int) (string, error) {
+		return "admin", nil
+	}
+	clientRepo.GetMfaFunc = func(ctx context.Context, clientId int) (*clients.Mfa, error) {
+		return &clients.Mfa{ClientId: clientId, Enabled: clientId == 1}, nil
+	}
+	handler := RequireAdmin(clientRepo, false)(func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+		return nil
+	})
+
+	for _, tt := range []struct {
+		sub      string
+		mfa      int64
+		wantCode int
+	}{
+		{"1", 0, 403},
+		{"1", time.Now().Add(-time.Hour).UnixMilli(), 0},
+		{"2", 0, 0},
+	} {
+		req := httptest.NewRequest("GET", "/clients", nil)
+		ctx := context.WithValue(req.Context(), httpctx.TokenCtxKey, auth.Token{Payload: &auth.TokenPayload{Sub: tt.sub, Mfa: tt.mfa}})
+		appErr := handler(httptest.NewRecorder(), req.WithContext(ctx))
+		if (appErr == nil && tt.wantCode != 0) || (appErr != nil && appErr.Code != tt.wantCode) {
+			t.Errorf("client %s, mfa %d: expected %d, got %v", tt.sub, tt.mfa, tt.wantCode, appErr)
+		}
+		if appErr != nil && appErr.ErrorCode != kmsErrors.CodeMfaRequired {
+			t.Errorf("client %s: expected %s, got %s", tt.sub, kmsErrors.CodeMfaRequired, appErr.ErrorCode)
+		}
+	}
+}
+
+func TestRequireFreshMfa(t *testing.T) {
+	handler := RequireFreshMfa(10 * time.Minute)(func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+		return nil
+	})
+
+	for _, tt := range []struct {
+		name     string
+		mfa      int64
+		wantCode int
+	}{
+		{"no MFA", 0, 403},
+		{"stale", time.Now().Add(-time.Hour).UnixMilli(), 403},
+		{"fresh", time.Now().Add(-time.Minute).UnixMilli(), 0},
+	} {
+		req := httptest.NewRequest("POST", "/clients/2/role", nil)
+		ctx := context.WithValue(req.Context(), httpctx.TokenCtxKey, auth.Token{Payload: &auth.TokenPayload{Sub: "1", Mfa: tt.mfa}})
+		appErr := handler(httptest.NewRecorder(), req.WithContext(ctx))
+		if (appErr == nil && tt.wantCode != 0) || (appErr != nil && appErr.Code != tt.wantCode) {
+			t.Errorf("%s: expected %d, got %v", tt.name, tt.wantCode, appErr)
+		}
+	}
+}
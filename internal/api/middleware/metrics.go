@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"kms/internal/httpctx"
+	"kms/internal/metrics"
+	kmsErrors "kms/pkg/errors"
+	"net/http"
+)
+
+// Counts the operation per outcome, 'success' or the error code (e.g. KEY_NOT_FOUND).
+// Placed after Authorize, so only authenticated attempts count.
+func CountKeyOperation(operation string) Middleware {
+	return func(next httpctx.AppHandler) httpctx.AppHandler {
+		return func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+			appErr := next(w, r)
+			outcome := "success"
+			if appErr != nil {
+				outcome = appErr.CodeOrDefault()
+			}
+			metrics.KeyOperations.Inc(operation, outcome)
+			return appErr
+		}
+	}
+}
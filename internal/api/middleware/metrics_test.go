@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"errors"
+	"kms/internal/metrics"
+	kmsErrors "kms/pkg/errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCountKeyOperation(t *testing.T) {
+	notFound := func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+		return kmsErrors.NewAppError(errors.New("no rows"), "Entity not found", 404).WithErrorCode(kmsErrors.CodeKeyNotFound)
+	}
+	ok := func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+		return nil
+	}
+
+	successBefore := metrics.KeyOperations.Value("test-get", "success")
+	notFoundBefore := metrics.KeyOperations.Value("test-get", kmsErrors.CodeKeyNotFound)
+
+	req := httptest.NewRequest("GET", "/keys/ref/1", nil)
+	CountKeyOperation("test-get")(ok)(httptest.NewRecorder(), req)
+	if appErr := CountKeyOperation("test-get")(notFound)(httptest.NewRecorder(), req); appErr == nil || appErr.Code != 404 {
+		t.Fatalf("expected the error to be passed on, got %v", appErr)
+	}
+
+	if got := metrics.KeyOperations.Value("test-get", "success"); got != successBefore+1 {
+		t.Errorf("expected 1 success, got %v", got-successBefore)
+	}
+	if got := metrics.KeyOperations.Value("test-get", kmsErrors.CodeKeyNotFound); got != notFoundBefore+1 {
+		t.Errorf("expected 1 KEY_NOT_FOUND, got %v", got-notFoundBefore)
+	}
+}
@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"fmt"
+	c "kms/internal/bootstrap/context"
+	"kms/internal/httpctx"
+	kmsErrors "kms/pkg/errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Idle buckets and past days' counters are pruned once a limiter tracks more keys than this
+const maxRateLimitEntries = 10000
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// In-memory token buckets per client (JWT 'sub') or, for unauthenticated requests, per IP
+type RateLimiter struct {
+	limit c.Rate
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// nil (unlimited) if limit is nil
+func NewRateLimiter(limit *c.Rate) *RateLimiter {
+	if limit == nil {
+		return nil
+	}
+	return &RateLimiter{
+		limit:   *limit,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Takes a token, if there is none returns how long until there is
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxRateLimitEntries {
+			l.prune(now)
+		}
+		b = &bucket{tokens: float64(l.limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = min(float64(l.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*l.limit.PerSecond)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.limit.PerSecond * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// Buckets that have refilled completely are the same as new ones
+func (l *RateLimiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.limit.PerSecond >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+type quotaUsage struct {
+	day   string
+	count int
+}
+
+// In-memory request counters per client, reset at midnight UTC
+type DailyQuota struct {
+	limit int
+	now   func() time.Time
+
+	mu    sync.Mutex
+	usage map[string]*quotaUsage
+}
+
+// nil (unlimited) if limit is 0
+func NewDailyQuota(limit int) *DailyQuota {
+	if limit == 0 {
+		return nil
+	}
+	return &DailyQuota{
+		limit: limit,
+		now:   time.Now,
+		usage: make(map[string]*quotaUsage),
+	}
+}
+
+// Counts a request, if the quota is used up returns how long until it's reset
+func (q *DailyQuota) Allow(key string) (bool, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now().UTC()
+	day := now.Format(time.DateOnly)
+	u, ok := q.usage[key]
+	if !ok || u.day != day {
+		if !ok && len(q.usage) >= maxRateLimitEntries {
+			q.prune(day)
+		}
+		u = &quotaUsage{day: day}
+		q.usage[key] = u
+	}
+
+	if u.count >= q.limit {
+		midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+		return false, midnight.Sub(now)
+	}
+	u.count++
+	return true, 0
+}
+
+func (q *DailyQuota) prune(day string) {
+	for key, u := range q.usage {
+		if u.day != day {
+			delete(q.usage, key)
+		}
+	}
+}
+
+type limiter interface {
+	Allow(key string) (bool, time.Duration)
+}
+
+// Rejects requests over the limit with 429 and Retry-After, must be wrapped by Authorize to limit per client.
+// A nil limiter lets everything through.
+func RateLimit(l *RateLimiter) func(httpctx.AppHandler) httpctx.AppHandler {
+	if l == nil {
+		return passThrough
+	}
+	return limitBy(l, limitKey, "Too many requests", kmsErrors.CodeRateLimited)
+}
+
+// Like RateLimit, always per IP. Goes in front of Authorize, so requests with bad tokens are limited before they're verified.
+func RateLimitByIP(l *RateLimiter) func(httpctx.AppHandler) httpctx.AppHandler {
+	if l == nil {
+		return passThrough
+	}
+	return limitBy(l, ipKey, "Too many requests", kmsErrors.CodeRateLimited)
+}
+
+// Like RateLimit, for quotas
+func Quota(q *DailyQuota) func(httpctx.AppHandler) httpctx.AppHandler {
+	if q == nil {
+		return passThrough
+	}
+	return limitBy(q, limitKey, "Daily quota exceeded", kmsErrors.CodeQuotaExceeded)
+}
+
+func passThrough(next httpctx.AppHandler) httpctx.AppHandler {
+	return next
+}
+
+func limitBy(l limiter, keyOf func(*http.Request) string, msg, code string) func(httpctx.AppHandler) httpctx.AppHandler {
+	return func(next httpctx.AppHandler) httpctx.AppHandler {
+		return func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+			key := keyOf(r)
+			if ok, wait := l.Allow(key); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				return kmsErrors.NewAppError(
+					fmt.Errorf("limit reached (%s), retry in %v", key, wait.Round(time.Second)),
+					msg,
+					429,
+				).WithErrorCode(code)
+			}
+			return next(w, r)
+		}
+	}
+}
+
+// The client's id if authenticated, the connection's address otherwise
+func limitKey(r *http.Request) string {
+	if token, err := httpctx.ExtractToken(r.Context()); err == nil {
+		return "client:" + token.Payload.Sub
+	}
+	return ipKey(r)
+}
+
+// The connection's address, tokens are ignored
+func ipKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
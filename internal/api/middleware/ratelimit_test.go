@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"context"
+	"kms/internal/auth"
+	c "kms/internal/bootstrap/context"
+	"kms/internal/httpctx"
+	kmsErrors "kms/pkg/errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewRateLimiter(&c.Rate{PerSecond: 2, Burst: 2})
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("expected request %d to be allowed", i+1)
+		}
+	}
+	ok, wait := l.Allow("a")
+	if ok || wait != 500*time.Millisecond {
+		t.Errorf("expected to wait 500ms, got %v (%v)", wait, ok)
+	}
+
+	// separate bucket
+	if ok, _ := l.Allow("b"); !ok {
+		t.Error("expected other key to be allowed")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Error("expected request to be allowed after refill")
+	}
+}
+
+func TestDailyQuota_Allow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)
+	q := NewDailyQuota(1)
+	q.now = func() time.Time { return now }
+
+	if ok, _ := q.Allow("a"); !ok {
+		t.Fatal("expected first request to be allowed")
+	}
+	ok, wait := q.Allow("a")
+	if ok || wait != time.Hour {
+		t.Errorf("expected to wait until midnight, got %v (%v)", wait, ok)
+	}
+
+	now = now.Add(time.Hour)
+	if ok, _ := q.Allow("a"); !ok {
+		t.Error("expected quota to be reset")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	next := func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+	handler := RateLimit(NewRateLimiter(&c.Rate{PerSecond: 1, Burst: 1}))(next)
+
+	newRequest := func(sub string) *http.Request {
+		req := httptest.NewRequest("GET", "/keys/ref/1", nil)
+		if sub != "" {
+			ctx := context.WithValue(req.Context(), httpctx.TokenCtxKey, auth.Token{Payload: &auth.TokenPayload{Sub: sub}})
+			req = req.WithContext(ctx)
+		}
+		return req
+	}
+
+	if appErr := handler(httptest.NewRecorder(), newRequest("1")); appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+
+	rr := httptest.NewRecorder()
+	appErr := handler(rr, newRequest("1"))
+	if appErr == nil || appErr.Code != 429 {
+		t.Fatalf("expected 429, got %v", appErr)
+	}
+	if rr.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected Retry-After 1, got %q", rr.Header().Get("Retry-After"))
+	}
+
+	// limited per client, unauthenticated requests per IP
+	if appErr := handler(httptest.NewRecorder(), newRequest("2")); appErr != nil {
+		t.Errorf("expected other client to be allowed, got %v", appErr)
+	}
+	if appErr := handler(httptest.NewRecorder(), newRequest("")); appErr != nil {
+		t.Errorf("expected IP to be allowed, got %v", appErr)
+	}
+}
+
+func TestRateLimitByIP(t *testing.T) {
+	next := func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+		return nil
+	}
+	handler := RateLimitByIP(NewRateLimiter(&c.Rate{PerSecond: 1, Burst: 1}))(next)
+
+	newRequest := func(sub, addr string) *http.Request {
+		req := httptest.NewRequest("GET", "/keys/ref/1", nil)
+		req.RemoteAddr = addr
+		ctx := context.WithValue(req.Context(), httpctx.TokenCtxKey, auth.Token{Payload: &auth.TokenPayload{Sub: sub}})
+		return req.WithContext(ctx)
+	}
+
+	if appErr := handler(httptest.NewRecorder(), newRequest("1", "10.0.0.1:1234")); appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	// the token doesn't matter, only the address
+	if appErr := handler(httptest.NewRecorder(), newRequest("2", "10.0.0.1:5678")); appErr == nil || appErr.Code != 429 {
+		t.Fatalf("expected 429, got %v", appErr)
+	}
+	if appErr := handler(httptest.NewRecorder(), newRequest("1", "10.0.0.2:1234")); appErr != nil {
+		t.Errorf("expected other IP to be allowed, got %v", appErr)
+	}
+}
+
+func TestRateLimit_Disabled(t *testing.T) {
+	next := func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+		return nil
+	}
+	handler := Quota(NewDailyQuota(0))(RateLimit(NewRateLimiter(nil))(next))
+
+	for i := 0; i < 100; i++ {
+		if appErr := handler(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil)); appErr != nil {
+			t.Fatalf("expected no error, got %v", appErr)
+		}
+	}
+}
@@ -2,79 +2,228 @@ package middleware
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"kms/internal/httpctx"
 	kmsErrors "kms/pkg/errors"
+	pHttp "kms/pkg/http"
 	"net/http"
+	"slices"
 	"strings"
 )
 
+// Wraps a handler, e.g. Authorize or RequireAdmin
+type Middleware func(httpctx.AppHandler) httpctx.AppHandler
+
 type Route struct {
-	Method  string
+	Method string
+	// Segments are static or params, '{name}' matches any value, '{name:int}' only non-negative integers
 	Pattern string
 	Handler httpctx.AppHandler
+	// Applied in order, i.e. the first one runs first
+	Middleware []Middleware
+	// Routes without are left out of the OpenAPI document
+	Doc *RouteDoc
+}
+
+// OpenAPI description of a route, see internal/api/openapi
+type RouteDoc struct {
+	// operationId, e.g. 'generateKey'
+	Operation string
+	Summary   string
+	// DTOs of the JSON request body and the success response, nil if there is none
+	Request  any
+	Response any
+	// Success status, defaults to 200 (204 without Response)
+	Status int
+	// Reachable without a token
+	Public bool
 }
 
-func NewRoute(method, pattern string, handler httpctx.AppHandler) *Route {
+func NewRoute(method, pattern string, handler httpctx.AppHandler, middleware ...Middleware) *Route {
 	return &Route{
-		Method:  method,
-		Pattern: pattern,
-		Handler: handler,
+		Method:     method,
+		Pattern:    pattern,
+		Handler:    handler,
+		Middleware: middleware,
 	}
 }
 
-func MakeRouter(routes []*Route) httpctx.AppHandler {
-	return func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
-		for _, route := range routes {
-			params, err := matchPattern(route, r)
-			if err != nil {
-				if strings.Contains(err.Error(), "method not allowed") {
-					return kmsErrors.NewAppError(err, "Method not allowed", 405)
-				}
-				continue
+func (r *Route) Describe(doc RouteDoc) *Route {
+	r.Doc = &doc
+	return r
+}
+
+// Route tree, the most specific path wins (static segments before params), so e.g. GET '/keys/actions/generate'
+// is a 405 instead of falling through to '/keys/{keyReference}/{version}'
+type Router struct {
+	root *node
+}
+
+type node struct {
+	static map[string]*node
+	param  *node
+	// param name and type, set on param nodes
+	name string
+	typ  string
+	// per method, with the route's middleware applied
+	handlers map[string]httpctx.AppHandler
+	// per method, reported to httpctx.RouteRecorder for metrics
+	patterns map[string]string
+}
+
+func newNode() *node {
+	return &node{
+		static:   make(map[string]*node),
+		handlers: make(map[string]httpctx.AppHandler),
+		patterns: make(map[string]string),
+	}
+}
+
+func NewRouter(routes ...*Route) (*Router, error) {
+	router := &Router{root: newNode()}
+	for _, route := range routes {
+		if err := router.Handle(route); err != nil {
+			return nil, err
+		}
+	}
+	return router, nil
+}
+
+// Fails for duplicate routes and params with different names or types at the same position
+func (rt *Router) Handle(route *Route) error {
+	n := rt.root
+	names := make(map[string]bool)
+	for _, segment := range splitPath(route.Pattern) {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			child, ok := n.static[segment]
+			if !ok {
+				child = newNode()
+				n.static[segment] = child
 			}
-			ctx := context.WithValue(r.Context(), httpctx.RouteParamsCtxKey, params)
-			return route.Handler(w, r.WithContext(ctx))
+			n = child
+			continue
+		}
+
+		name, typ, _ := strings.Cut(strings.Trim(segment, "{}"), ":")
+		if typ != "" && typ != "int" {
+			return fmt.Errorf("route %s %s: unknown param type %q", route.Method, route.Pattern, typ)
 		}
+		if name == "" || names[name] {
+			return fmt.Errorf("route %s %s: empty or duplicate param %q", route.Method, route.Pattern, name)
+		}
+		names[name] = true
+
+		if n.param == nil {
+			n.param = newNode()
+			n.param.name = name
+			n.param.typ = typ
+		} else if n.param.name != name || n.param.typ != typ {
+			return fmt.Errorf("route %s %s: param %q conflicts with {%s:%s}", route.Method, route.Pattern, segment, n.param.name, n.param.typ)
+		}
+		n = n.param
+	}
+
+	if _, ok := n.handlers[route.Method]; ok {
+		return fmt.Errorf("route %s %s registered twice", route.Method, route.Pattern)
+	}
+	handler := route.Handler
+	for i := len(route.Middleware) - 1; i >= 0; i-- {
+		handler = route.Middleware[i](handler)
+	}
+	n.handlers[route.Method] = handler
+	n.patterns[route.Method] = route.Pattern
+	return nil
+}
+
+// 404 if no path matches, 405 with Allow if the path has no route for the method, OPTIONS is answered with Allow
+func (rt *Router) ServeApp(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	params := make(map[string]string)
+	n := rt.root.lookup(splitPath(r.URL.Path), params)
+	if n == nil {
 		return kmsErrors.NewAppError(
 			fmt.Errorf("path does not exist: [%v] %v", r.Method, r.URL.Path),
 			"Not found",
 			404,
 		)
 	}
+
+	if handler, ok := n.handlers[r.Method]; ok {
+		if rec, ok := w.(httpctx.RouteRecorder); ok {
+			rec.SetRoute(n.patterns[r.Method])
+		}
+		ctx := context.WithValue(r.Context(), httpctx.RouteParamsCtxKey, params)
+		return handler(w, r.WithContext(ctx))
+	}
+
+	w.Header().Set("Allow", strings.Join(n.allowed(), ", "))
+	if r.Method == http.MethodOptions {
+		return pHttp.WriteStatus(w, http.StatusNoContent)
+	}
+	return kmsErrors.NewAppError(
+		fmt.Errorf("method not allowed: [%v] %v", r.Method, r.URL.Path),
+		"Method not allowed",
+		405,
+	)
 }
 
-// FIXME: Allows GET "/keys/generate" (POST-only path) to fall through to GET "/keys/{keyReference}"
-// FIXME: Returns 405 if two endpoints share path, but different method
-func matchPattern(route *Route, r *http.Request) (map[string]string, error) {
-	methodMatch := true
-	if r.Method != route.Method {
-		methodMatch = false
+// First node with routes for the whole path, static children are tried before the param.
+// params is filled with the values along the way.
+func (n *node) lookup(segments []string, params map[string]string) *node {
+	if len(segments) == 0 {
+		if len(n.handlers) == 0 {
+			return nil
+		}
+		return n
 	}
 
-	routeParts := strings.Split(strings.Trim(route.Pattern, "/"), "/")
-	reqParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if child, ok := n.static[segments[0]]; ok {
+		if found := child.lookup(segments[1:], params); found != nil {
+			return found
+		}
+	}
 
-	if len(reqParts) != len(routeParts) {
-		return nil, errors.New("lengths don't match")
+	if n.param != nil && n.param.accepts(segments[0]) {
+		params[n.param.name] = segments[0]
+		if found := n.param.lookup(segments[1:], params); found != nil {
+			return found
+		}
+		delete(params, n.param.name)
 	}
+	return nil
+}
 
-	params := make(map[string]string)
-	for idx, routePart := range routeParts {
-		// Check if param
-		if strings.HasPrefix(routePart, "{") && strings.HasSuffix(routePart, "}") {
-			params[strings.Trim(routePart, "{}")] = reqParts[idx]
-			continue
+func (n *node) accepts(value string) bool {
+	if value == "" {
+		return false
+	}
+	if n.typ == "int" {
+		// no sign, and short enough for an int
+		if len(value) > 18 {
+			return false
 		}
-		// Compare
-		if routePart == reqParts[idx] {
-			continue
+		for _, c := range value {
+			if c < '0' || c > '9' {
+				return false
+			}
 		}
-		return nil, errors.New("paths don't match")
 	}
-	if !methodMatch {
-		return nil, errors.New("method not allowed")
+	return true
+}
+
+func (n *node) allowed() []string {
+	methods := []string{http.MethodOptions}
+	for method := range n.handlers {
+		methods = append(methods, method)
+	}
+	slices.Sort(methods)
+	return slices.Compact(methods)
+}
+
+// Leading and trailing slashes are ignored
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
 	}
-	return params, nil
+	return strings.Split(path, "/")
 }
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"kms/internal/httpctx"
 	"kms/internal/test"
 	kmsErrors "kms/pkg/errors"
 	pHttp "kms/pkg/http"
@@ -9,174 +10,202 @@ import (
 	"testing"
 )
 
-func TestMakeRouter_Success(t *testing.T) {
-	handler := func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
-		w.WriteHeader(http.StatusOK)
-		return nil
-	}
-	routes := []*Route{
-		NewRoute("GET", "/test", handler),
-	}
-	router := MakeRouter(routes)
-	req, err := http.NewRequest("GET", "/test", nil)
-	if err != nil {
-		t.Fatalf("failed to create request: %v", err)
-	}
-	rr := httptest.NewRecorder()
-	respErr := router(rr, req)
-	if respErr != nil {
-		t.Fatalf("expected no error, got: %v", respErr)
-	}
-	if rr.Code != http.StatusOK {
-		t.Errorf("expected status code 200, got: %d", rr.Code)
+// Writes the route's name and params
+func namedHandler(name string) httpctx.AppHandler {
+	return func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+		params, _ := r.Context().Value(httpctx.RouteParamsCtxKey).(map[string]string)
+		return pHttp.WriteJSON(w, map[string]any{
+			"route":  name,
+			"params": params,
+		})
 	}
 }
 
-func TestMakeRouter_NotFound(t *testing.T) {
-	handler := func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
-		w.WriteHeader(http.StatusOK)
-		return nil
-	}
-	routes := []*Route{
-		NewRoute("GET", "/test", handler),
-	}
-	router := MakeRouter(routes)
-	req, err := http.NewRequest("GET", "/notfound", nil)
+func serve(t *testing.T, router *Router, method, path string) (*httptest.ResponseRecorder, *kmsErrors.AppError) {
+	t.Helper()
+	req, err := http.NewRequest(method, path, nil)
 	if err != nil {
 		t.Fatalf("failed to create request: %v", err)
 	}
 	rr := httptest.NewRecorder()
-	respErr := router(rr, req)
-	if respErr == nil {
-		t.Fatal("expected an error, got nil")
-	}
-	if respErr.Code != http.StatusNotFound {
-		t.Errorf("expected status code 404, got: %d", respErr.Code)
-	}
+	return rr, router.ServeApp(rr, req)
 }
 
-func TestMakeRouter_MethodNotAllowed(t *testing.T) {
-	routes := []*Route{
-		NewRoute(
-			"POST",
-			"/test/abc", // static route
-			func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
-				return pHttp.WriteJSON(w, map[string]string{
-					"msg": "static",
-				})
-			},
-		),
-		NewRoute(
-			"GET",
-			"/test/{id}", // dynamic route that matches "/test/abc"
-			func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
-				return pHttp.WriteJSON(w, map[string]string{
-					"msg": "static",
-				})
-			},
-		),
-	}
-	router := MakeRouter(routes)
+func newTestRouter(t *testing.T) *Router {
+	router, err := NewRouter(
+		NewRoute("POST", "/keys/actions/generate", namedHandler("generate")),
+		NewRoute("GET", "/keys/{keyReference}/latest", namedHandler("get latest")),
+		NewRoute("GET", "/keys/{keyReference}/{version:int}", namedHandler("get")),
+		NewRoute("DELETE", "/keys/{keyReference}/{version:int}/actions/destroy", namedHandler("destroy")),
+		NewRoute("GET", "/clients/{id:int}/api-keys", namedHandler("list")),
+		NewRoute("POST", "/clients/{id:int}/api-keys", namedHandler("create")),
+		NewRoute("DELETE", "/clients/{id:int}/api-keys/{keyId:int}", namedHandler("revoke")),
+		NewRoute("GET", "/test/{id}", namedHandler("param")),
+		NewRoute("POST", "/test/abc", namedHandler("static")),
+	)
+	test.RequireErrNil(t, err)
+	return router
+}
 
-	// make request for static route with wrong method
-	req, err := http.NewRequest("GET", "/test/abc", nil)
-	if err != nil {
-		t.Fatalf("failed to create request: %v", err)
-	}
-	rr := httptest.NewRecorder()
-	respErr := router(rr, req)
-	if respErr == nil {
-		t.Fatal("expected an error, got nil")
+func TestRouter_Match(t *testing.T) {
+	router := newTestRouter(t)
+
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{"POST", "/keys/actions/generate", `"route":"generate"`},
+		{"GET", "/keys/ref/latest", `"params":{"keyReference":"ref"},"route":"get latest"`},
+		{"GET", "/keys/ref/3", `"params":{"keyReference":"ref","version":"3"},"route":"get"`},
+		// static 'actions' has no '1', so the params match
+		{"GET", "/keys/actions/1", `"params":{"keyReference":"actions","version":"1"},"route":"get"`},
+		{"DELETE", "/keys/ref/3/actions/destroy", `"route":"destroy"`},
+		{"GET", "/clients/12/api-keys/", `"params":{"id":"12"},"route":"list"`},
+		{"POST", "/clients/12/api-keys", `"route":"create"`},
+		{"DELETE", "/clients/12/api-keys/4", `"params":{"id":"12","keyId":"4"},"route":"revoke"`},
+		{"POST", "/test/abc", `"route":"static"`},
+		{"GET", "/test/def", `"route":"param"`},
 	}
-	if respErr.Code != 405 {
-		t.Errorf("expected status code 405, got: %d", respErr.Code)
+
+	for _, tt := range tests {
+		rr, appErr := serve(t, router, tt.method, tt.path)
+		if appErr != nil {
+			t.Errorf("%s %s: expected no error, got %v", tt.method, tt.path, appErr)
+			continue
+		}
+		test.RequireContains(t, rr.Body.String(), tt.want)
 	}
-	test.RequireContains(t, respErr.Message, "Method not allowed")
+}
 
+func TestRouter_NotFound(t *testing.T) {
+	router := newTestRouter(t)
+
+	for _, path := range []string{
+		"/",
+		"/notfound",
+		"/keys",
+		"/keys/ref",
+		"/keys/ref/abc",         // version must be int
+		"/keys/ref/-1",          // no sign
+		"/keys/ref/1/actions",   // no route
+		"/clients/abc/api-keys", // id must be int
+		"/keys//1",              // empty param
+	} {
+		_, appErr := serve(t, router, "GET", path)
+		if appErr == nil || appErr.Code != 404 {
+			t.Errorf("%s: expected 404, got %v", path, appErr)
+		}
+	}
 }
 
-func TestMatchPattern_Simple(t *testing.T) {
-	route := NewRoute("GET", "/test/{id}", func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
-		return nil
-	})
+func TestRouter_MethodNotAllowed(t *testing.T) {
+	router := newTestRouter(t)
 
 	tests := []struct {
-		method   string
-		path     string
-		expected bool
-		err      string
+		method string
+		path   string
+		allow  string
 	}{
-		{"GET", "/test/123", true, ""},
-		{"GET", "/test/abc", true, ""},
-		{"GET", "/test/", false, "lengths don't match"},
-		{"GET", "/other/123", false, "paths don't match"},
-		{"GET", "/test/123/extra", false, "lengths don't match"},
-		{"GET", "/test/{id}", true, ""}, // Router doesn't care about the actual value of {id}
-		{"POST", "/test/123", false, "method not allowed"},
-		{"POST", "/test/abc", false, "method not allowed"},
-		{"POST", "/test/", false, "lengths don't match"},
-		{"POST", "/other/123", false, "paths don't match"},
-		{"POST", "/test/123/extra", false, "lengths don't match"},
-		{"POST", "/test/{id}", false, "method not allowed"},
+		// static paths don't fall through to param routes
+		{"GET", "/keys/actions/generate", "OPTIONS, POST"},
+		{"GET", "/test/abc", "OPTIONS, POST"},
+		{"POST", "/keys/ref/1", "GET, OPTIONS"},
+		// same path, different methods
+		{"DELETE", "/clients/1/api-keys", "GET, OPTIONS, POST"},
 	}
 
 	for _, tt := range tests {
-		req, err := http.NewRequest(tt.method, tt.path, nil)
-		if err != nil {
-			t.Fatalf("failed to create request: %v", err)
-		}
-		params, err := matchPattern(route, req)
-		if (err == nil) != tt.expected {
-			t.Errorf("expected matchPattern(%s) to return %v, got %v", tt.path, tt.err, err)
+		rr, appErr := serve(t, router, tt.method, tt.path)
+		if appErr == nil || appErr.Code != 405 {
+			t.Errorf("%s %s: expected 405, got %v", tt.method, tt.path, appErr)
 			continue
 		}
-		if (err == nil) && params["id"] == "" {
-			t.Errorf("expected 'id' parameter to be set for path %s", tt.path)
+		test.RequireContains(t, appErr.Message, "Method not allowed")
+		if allow := rr.Header().Get("Allow"); allow != tt.allow {
+			t.Errorf("%s %s: expected Allow %q, got %q", tt.method, tt.path, tt.allow, allow)
+		}
+	}
+}
+
+func TestRouter_Options(t *testing.T) {
+	router := newTestRouter(t)
+
+	rr, appErr := serve(t, router, "OPTIONS", "/clients/1/api-keys")
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	if rr.Code != 204 || rr.Header().Get("Allow") != "GET, OPTIONS, POST" {
+		t.Errorf("expected 204 with Allow, got %d %q", rr.Code, rr.Header().Get("Allow"))
+	}
+}
+
+func TestRouter_Middleware(t *testing.T) {
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next httpctx.AppHandler) httpctx.AppHandler {
+			return func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+				order = append(order, name)
+				return next(w, r)
+			}
 		}
 	}
+
+	router, err := NewRouter(NewRoute("GET", "/test", namedHandler("test"), trace("first"), trace("second")))
+	test.RequireErrNil(t, err)
+
+	if _, appErr := serve(t, router, "GET", "/test"); appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Errorf("expected middleware in order [first second], got %v", order)
+	}
 }
 
-func TestMatchPattern_MultipleParams(t *testing.T) {
-	route := NewRoute("GET", "/test/{id}/{name}", func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
-		return nil
-	})
+func TestNewRouter_Invalid(t *testing.T) {
+	handler := namedHandler("test")
+
 	tests := []struct {
-		path     string
-		expected bool
+		name   string
+		routes []*Route
 	}{
-		{"/test/123/john", true},
-		{"/test/456/jane", true},
-		{"/test/123", false},
-		{"/test/john/123", true}, // Router doesn't care about param types
-		{"/test/123/john/extra", false},
-		{"/test/{id}/{name}", true}, // Router doesn't care about the actual values of {id} and {name}
+		{"duplicate", []*Route{NewRoute("GET", "/test", handler), NewRoute("GET", "/test/", handler)}},
+		{"param names", []*Route{NewRoute("GET", "/test/{id}", handler), NewRoute("POST", "/test/{name}", handler)}},
+		{"param types", []*Route{NewRoute("GET", "/test/{id}", handler), NewRoute("POST", "/test/{id:int}", handler)}},
+		{"unknown type", []*Route{NewRoute("GET", "/test/{id:uuid}", handler)}},
+		{"repeated param", []*Route{NewRoute("GET", "/test/{id}/{id}", handler)}},
 	}
+
 	for _, tt := range tests {
-		req, err := http.NewRequest("GET", tt.path, nil)
-		if err != nil {
-			t.Fatalf("failed to create request: %v", err)
-		}
-		params, err := matchPattern(route, req)
-		if (err == nil) != tt.expected {
-			t.Errorf("expected matchPattern(%s) to return %v, got %v", tt.path, tt.expected, err)
-			continue
-		}
-		if (err == nil) && (params["id"] == "" || params["name"] == "") {
-			t.Error("expected 'id' and 'name' parameters to be set for path", tt.path)
+		if _, err := NewRouter(tt.routes...); err == nil {
+			t.Errorf("%s: expected error, got nil", tt.name)
 		}
 	}
 }
 
-func TestMatchPattern_WrongMethod(t *testing.T) {
-	route := NewRoute("GET", "/test/{id}", func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
-		return nil
-	})
-	req, err := http.NewRequest("POST", "/test/12", nil)
-	if err != nil {
-		t.Fatalf("failed to create request: %v", err)
-	}
-	_, err = matchPattern(route, req)
-	if err.Error() != "method not allowed" {
-		t.Fatalf("expected matchPattern(%s) to return method not allowed, got %v", "POST", err)
+type routeRecorder struct {
+	*httptest.ResponseRecorder
+	route string
+}
+
+func (r *routeRecorder) SetRoute(pattern string) {
+	r.route = pattern
+}
+
+func TestRouter_ReportsRoute(t *testing.T) {
+	router := newTestRouter(t)
+
+	for path, want := range map[string]string{
+		"/keys/ref/3":          "/keys/{keyReference}/{version:int}",
+		"/clients/12/api-keys": "/clients/{id:int}/api-keys",
+		"/notfound":            "",
+	} {
+		rec := &routeRecorder{ResponseRecorder: httptest.NewRecorder()}
+		req, err := http.NewRequest("GET", path, nil)
+		test.RequireErrNil(t, err)
+		router.ServeApp(rec, req)
+		if rec.route != want {
+			t.Errorf("%s: expected route %q, got %q", path, want, rec.route)
+		}
 	}
 }
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"context"
+	"kms/internal/httpctx"
+	kmsErrors "kms/pkg/errors"
+	"net/http"
+	"time"
+)
+
+// Gives the request a deadline, queries still running when it passes are cancelled.
+// Server errors caused by the deadline become 503 TIMEOUT.
+func Timeout(d time.Duration) Middleware {
+	return func(next httpctx.AppHandler) httpctx.AppHandler {
+		return func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			appErr := next(w, r.WithContext(ctx))
+			if appErr != nil && appErr.Code >= 500 {
+				if ctxErr := kmsErrors.FromContext(ctx, appErr.Err); ctxErr != nil {
+					return ctxErr
+				}
+			}
+			return appErr
+		}
+	}
+}
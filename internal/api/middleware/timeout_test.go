@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"errors"
+	kmsErrors "kms/pkg/errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	slow := func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+		if _, ok := r.Context().Deadline(); !ok {
+			t.Error("expected a deadline on the request context")
+		}
+		// like a query cancelled by the deadline
+		<-r.Context().Done()
+		return kmsErrors.NewInternalServerError(r.Context().Err())
+	}
+
+	req := httptest.NewRequest("GET", "/keys/ref/latest", nil)
+	appErr := Timeout(10*time.Millisecond)(slow)(httptest.NewRecorder(), req)
+	if appErr == nil || appErr.Code != 503 || appErr.ErrorCode != kmsErrors.CodeTimeout {
+		t.Fatalf("expected 503 TIMEOUT, got %v", appErr)
+	}
+
+	// errors in time are left alone
+	notFound := func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+		return kmsErrors.NewAppError(errors.New("no rows"), "Entity not found", 404)
+	}
+	appErr = Timeout(time.Second)(notFound)(httptest.NewRecorder(), req)
+	if appErr == nil || appErr.Code != 404 {
+		t.Errorf("expected 404, got %v", appErr)
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"fmt"
+	"kms/internal/httpctx"
+	kmsErrors "kms/pkg/errors"
+	"net/http"
+	"time"
+)
+
+// When the unversioned paths were deprecated in favour of '/v1'
+var UnversionedDeprecatedAt = time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+
+// Serves the routes under prefix (e.g. '/v1') and, as deprecated aliases, at their unversioned paths
+func Versioned(prefix string, routes []*Route) []*Route {
+	versioned := make([]*Route, 0, 2*len(routes))
+	for _, route := range routes {
+		current := *route
+		current.Pattern = prefix + route.Pattern
+		versioned = append(versioned, &current)
+
+		alias := *route
+		alias.Middleware = append([]Middleware{Deprecated(prefix, UnversionedDeprecatedAt)}, route.Middleware...)
+		alias.Doc = nil
+		versioned = append(versioned, &alias)
+	}
+	return versioned
+}
+
+// Sets Deprecation (RFC 9745) and links the same path under prefix as successor
+func Deprecated(prefix string, since time.Time) Middleware {
+	return func(next httpctx.AppHandler) httpctx.AppHandler {
+		return func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+			w.Header().Set("Deprecation", fmt.Sprintf("@%d", since.Unix()))
+			w.Header().Set("Link", fmt.Sprintf("<%s%s>; rel=\"successor-version\"", prefix, r.URL.Path))
+			return next(w, r)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"kms/internal/test"
+	"net/http"
+	"testing"
+)
+
+func TestVersioned(t *testing.T) {
+	routes := Versioned("/v1", []*Route{
+		NewRoute("GET", "/keys/{keyReference}/{version:int}", namedHandler("get")).Describe(RouteDoc{Operation: "getKey"}),
+	})
+	if len(routes) != 2 || routes[0].Doc == nil || routes[1].Doc != nil {
+		t.Fatalf("expected documented /v1 route and undocumented alias, got %+v", routes)
+	}
+
+	router, err := NewRouter(routes...)
+	test.RequireErrNil(t, err)
+
+	rr, appErr := serve(t, router, "GET", "/v1/keys/ref/1")
+	if appErr != nil || rr.Header().Get("Deprecation") != "" {
+		t.Errorf("expected /v1 route without Deprecation, got %v %v", appErr, rr.Header())
+	}
+	test.RequireContains(t, rr.Body.String(), `"route":"get"`)
+
+	rr, appErr = serve(t, router, "GET", "/keys/ref/1")
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	if rr.Header().Get("Deprecation") != "@1792368000" {
+		t.Errorf("expected Deprecation header, got %q", rr.Header().Get("Deprecation"))
+	}
+	if rr.Header().Get("Link") != `</v1/keys/ref/1>; rel="successor-version"` {
+		t.Errorf("expected successor Link header, got %q", rr.Header().Get("Link"))
+	}
+
+	// not aliased under '/v1/v1'
+	if _, appErr := serve(t, router, http.MethodGet, "/v1/v1/keys/ref/1"); appErr == nil || appErr.Code != 404 {
+		t.Errorf("expected 404, got %v", appErr)
+	}
+}
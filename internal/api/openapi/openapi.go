@@ -0,0 +1,314 @@
+package openapi
+
+import (
+	"fmt"
+	mw "kms/internal/api/middleware"
+	"kms/internal/httpctx"
+	kmsErrors "kms/pkg/errors"
+	pHttp "kms/pkg/http"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// OpenAPI 3.0 document, only the parts the KMS uses
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Servers    []Server                         `json:"servers"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+	Security   []map[string][]string            `json:"security"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Server struct {
+	Url string `json:"url"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+type Operation struct {
+	OperationId string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+// Describes the documented routes, their patterns relative to basePath (e.g. '/v1')
+func Generate(title, version, basePath string, routes []*mw.Route) (*Document, error) {
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    Info{Title: title, Version: version},
+		Servers: []Server{{Url: basePath}},
+		Paths:   make(map[string]map[string]*Operation),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+			SecuritySchemes: map[string]*SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+				"apiKeyAuth": {Type: "apiKey", In: "header", Name: "Authorization", Description: "'ApiKey <key>'"},
+			},
+		},
+		Security: []map[string][]string{{"bearerAuth": {}}, {"apiKeyAuth": {}}},
+	}
+	schemas := &schemaRegistry{schemas: doc.Components.Schemas, types: make(map[string]reflect.Type)}
+	problem, err := schemas.schemaOf(reflect.TypeOf(kmsErrors.Problem{}))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, route := range routes {
+		if route.Doc == nil {
+			continue
+		}
+
+		path, params := pathParams(route.Pattern)
+		op := &Operation{
+			OperationId: route.Doc.Operation,
+			Summary:     route.Doc.Summary,
+			Tags:        []string{strings.Split(strings.Trim(route.Pattern, "/"), "/")[0]},
+			Parameters:  params,
+			Responses: map[string]*Response{
+				"default": {
+					Description: "Error",
+					Content:     map[string]*MediaType{kmsErrors.ProblemContentType: {Schema: problem}},
+				},
+			},
+		}
+		if route.Doc.Public {
+			op.Security = []map[string][]string{{}}
+		}
+
+		if route.Doc.Request != nil {
+			schema, err := schemas.schemaOf(reflect.TypeOf(route.Doc.Request))
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", route.Method, route.Pattern, err)
+			}
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]*MediaType{"application/json": {Schema: schema}},
+			}
+		}
+
+		status := route.Doc.Status
+		response := &Response{Description: "Success"}
+		if route.Doc.Response != nil {
+			schema, err := schemas.schemaOf(reflect.TypeOf(route.Doc.Response))
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", route.Method, route.Pattern, err)
+			}
+			response.Content = map[string]*MediaType{"application/json": {Schema: schema}}
+			if status == 0 {
+				status = http.StatusOK
+			}
+		} else if status == 0 {
+			status = http.StatusNoContent
+		}
+		op.Responses[fmt.Sprint(status)] = response
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*Operation)
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = op
+	}
+
+	return doc, nil
+}
+
+// Serves the document, e.g. at '/v1/openapi.json'
+func Handler(doc *Document) httpctx.AppHandler {
+	return func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+		return pHttp.WriteJSON(w, doc)
+	}
+}
+
+// '/clients/{id:int}' -> '/clients/{id}' with an integer path parameter
+func pathParams(pattern string) (string, []*Parameter) {
+	var params []*Parameter
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			continue
+		}
+		name, typ, _ := strings.Cut(strings.Trim(segment, "{}"), ":")
+		schema := &Schema{Type: "string"}
+		if typ == "int" {
+			schema = &Schema{Type: "integer", Format: "int64"}
+		}
+		params = append(params, &Parameter{Name: name, In: "path", Required: true, Schema: schema})
+		segments[i] = "{" + name + "}"
+	}
+	return strings.Join(segments, "/"), params
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Structs become components, referenced by their type name (prefixed with the package if names collide)
+type schemaRegistry struct {
+	schemas map[string]*Schema
+	types   map[string]reflect.Type
+}
+
+func (s *schemaRegistry) schemaOf(t reflect.Type) (*Schema, error) {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}, nil
+	case t.Kind() == reflect.Pointer:
+		schema, err := s.schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		if schema.Ref != "" {
+			return schema, nil
+		}
+		schema.Nullable = true
+		return schema, nil
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return &Schema{Type: "string", Format: "byte"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}, nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := s.schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		values, err := s.schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	case reflect.Struct:
+		return s.component(t)
+	}
+	return nil, fmt.Errorf("unsupported type %v", t)
+}
+
+func (s *schemaRegistry) component(t reflect.Type) (*Schema, error) {
+	name := t.Name()
+	if other, ok := s.types[name]; ok && other != t {
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = string(unicode.ToUpper(rune(pkg[0]))) + pkg[1:] + name
+	}
+	ref := &Schema{Ref: "#/components/schemas/" + name}
+	if _, ok := s.types[name]; ok {
+		return ref, nil
+	}
+
+	// registered before the fields, so recursive types terminate
+	s.types[name] = t
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	s.schemas[name] = schema
+	if err := s.addFields(schema, t); err != nil {
+		return nil, err
+	}
+	return ref, nil
+}
+
+// Follows encoding/json: json tags, '-' and unexported fields are skipped, embedded structs are flattened
+func (s *schemaRegistry) addFields(schema *Schema, t reflect.Type) error {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		fieldType := field.Type
+		if field.Anonymous && name == "" {
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				if err := s.addFields(schema, fieldType); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		fieldSchema, err := s.schemaOf(field.Type)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", t.Name(), field.Name, err)
+		}
+		schema.Properties[name] = fieldSchema
+		if !strings.Contains(opts, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return nil
+}
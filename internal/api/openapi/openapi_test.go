@@ -0,0 +1,108 @@
+package openapi
+
+import (
+	"encoding/json"
+	mw "kms/internal/api/middleware"
+	"kms/internal/test"
+	kmsErrors "kms/pkg/errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type testBase struct {
+	ID int `json:"id"`
+}
+
+type testRequest struct {
+	Name     string   `json:"name"`
+	Scopes   []string `json:"scopes,omitempty"`
+	Internal string   `json:"-"`
+	hidden   string
+}
+
+type testResponse struct {
+	*testBase
+	Secret    []byte            `json:"secret"`
+	ExpiresAt *time.Time        `json:"expiresAt,omitempty"`
+	Labels    map[string]string `json:"labels"`
+	Child     *testResponse     `json:"child,omitempty"`
+}
+
+func noop(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	return nil
+}
+
+func TestGenerate(t *testing.T) {
+	routes := []*mw.Route{
+		mw.NewRoute("POST", "/things/{id:int}/actions/create", noop).
+			Describe(mw.RouteDoc{Operation: "createThing", Request: testRequest{}, Response: testResponse{}}),
+		mw.NewRoute("GET", "/things/{ref}", noop).
+			Describe(mw.RouteDoc{Operation: "getThings", Response: []testResponse{}, Public: true}),
+		mw.NewRoute("DELETE", "/things/{ref}", noop).
+			Describe(mw.RouteDoc{Operation: "deleteThing"}),
+		mw.NewRoute("GET", "/undocumented", noop),
+	}
+
+	doc, err := Generate("KMS", "1.0.0", "/v1", routes)
+	test.RequireErrNil(t, err)
+
+	if len(doc.Paths) != 2 || doc.Servers[0].Url != "/v1" {
+		t.Fatalf("expected 2 paths under /v1, got %v", doc.Paths)
+	}
+
+	create := doc.Paths["/things/{id}/actions/create"]["post"]
+	if create == nil || create.Parameters[0].Name != "id" || create.Parameters[0].Schema.Type != "integer" {
+		t.Fatalf("expected integer path param id, got %+v", create)
+	}
+	if create.RequestBody.Content["application/json"].Schema.Ref != "#/components/schemas/testRequest" {
+		t.Errorf("expected request body ref, got %+v", create.RequestBody)
+	}
+	if create.Responses["default"].Content[kmsErrors.ProblemContentType].Schema.Ref != "#/components/schemas/Problem" {
+		t.Errorf("expected problem as default response, got %+v", create.Responses["default"])
+	}
+	if create.Responses["200"] == nil || create.Security != nil {
+		t.Errorf("expected 200 with default security, got %+v", create)
+	}
+
+	get := doc.Paths["/things/{ref}"]["get"]
+	if get.Responses["200"].Content["application/json"].Schema.Items.Ref != "#/components/schemas/testResponse" {
+		t.Errorf("expected array of testResponse, got %+v", get.Responses["200"])
+	}
+	if len(get.Security) != 1 || len(get.Security[0]) != 0 {
+		t.Errorf("expected public route to clear security, got %v", get.Security)
+	}
+	if doc.Paths["/things/{ref}"]["delete"].Responses["204"] == nil {
+		t.Error("expected 204 without response")
+	}
+
+	request := doc.Components.Schemas["testRequest"]
+	if len(request.Properties) != 2 || len(request.Required) != 1 || request.Required[0] != "name" {
+		t.Errorf("expected name (required) and scopes, got %+v", request)
+	}
+
+	response, err := json.Marshal(doc.Components.Schemas["testResponse"])
+	test.RequireErrNil(t, err)
+	for _, want := range []string{
+		`"id":{"type":"integer","format":"int64"}`,
+		`"secret":{"type":"string","format":"byte"}`,
+		`"expiresAt":{"type":"string","format":"date-time","nullable":true}`,
+		`"labels":{"type":"object","additionalProperties":{"type":"string"}}`,
+		`"child":{"$ref":"#/components/schemas/testResponse"}`,
+		`"required":["id","secret","labels"]`,
+	} {
+		test.RequireContains(t, string(response), want)
+	}
+}
+
+func TestGenerate_UnsupportedType(t *testing.T) {
+	routes := []*mw.Route{
+		mw.NewRoute("POST", "/things", noop).Describe(mw.RouteDoc{Request: struct {
+			C chan int `json:"c"`
+		}{}}),
+	}
+
+	if _, err := Generate("KMS", "1.0.0", "/v1", routes); err == nil {
+		t.Error("expected error for chan field, got nil")
+	}
+}
@@ -1,120 +1,226 @@
 package api
 
 import (
+	"fmt"
 	"kms/internal/admin"
+	"kms/internal/api/dto"
 	mw "kms/internal/api/middleware"
+	"kms/internal/api/openapi"
+	"kms/internal/apikeys"
 	"kms/internal/auth"
 	"kms/internal/bootstrap"
+	"kms/internal/clients"
+	"kms/internal/escrow"
+	"kms/internal/health"
 	"kms/internal/httpctx"
 	"kms/internal/keys"
+	"kms/internal/metrics"
 	"net/http"
-	"strconv"
 )
 
-// Single http.HandleFunc() with custom router?
-func RegisterRoutes(ctx *bootstrap.AppContext) error {
-	jwtTtl, err := strconv.ParseInt(ctx.Cfg["JWT_TTL"], 0, 64)
-	if err != nil {
-		return err
-	}
+// Prefix of the current API version, see mw.Versioned
+const apiVersion = "/v1"
 
+// All routes are served by a single router on '/'
+func RegisterRoutes(ctx *bootstrap.AppContext) error {
 	jwtGenInfo := &auth.TokenGenInfo{
-		Ttl:    jwtTtl,
+		Ttl:    ctx.Cfg.JWTTTL.Milliseconds(),
 		Secret: ctx.KeyManager.JWTKey(),
 		Typ:    "jwt",
 	}
 
-	authService := auth.NewService(ctx.Cfg, ctx.ClientRepo, jwtGenInfo, ctx.KeyManager, ctx.Logger)
+	loginLimiter := auth.NewLoginLimiter(auth.LoginLimits{MaxFailures: ctx.Cfg.LoginMaxFailures, Lockout: ctx.Cfg.LoginLockout})
+
+	oidcVerifier, err := auth.LoadOIDCVerifier(ctx.Cfg.OIDC)
+	if err != nil {
+		return err
+	}
+
+	authService := auth.NewService(ctx.Cfg, ctx.ClientRepo, jwtGenInfo, ctx.KeyManager, loginLimiter, oidcVerifier, ctx.Logger)
 	authHandler := auth.NewHandler(authService, ctx.Logger)
 
-	keyService := keys.NewService(ctx.KeyRepo, ctx.KeyManager, ctx.Logger)
+	keyService := keys.NewService(ctx.KeyRepo, ctx.KeyManager, ctx.Cfg.KeyDeletionWindow, ctx.Logger)
 	keyHandler := keys.NewHandler(keyService, ctx.Logger)
 
-	adminService := admin.NewService(ctx.AdminRepo, ctx.ClientRepo, ctx.KeyManager, ctx.Logger)
+	adminService := admin.NewService(ctx.AdminRepo, ctx.ClientRepo, ctx.KeyManager, loginLimiter, ctx.Logger)
 	adminHandler := admin.NewHandler(adminService, ctx.Logger)
 
+	escrowService := escrow.NewService(ctx.KeyRepo, ctx.ClientRepo, ctx.KeyManager, ctx.Logger)
+	escrowHandler := escrow.NewHandler(escrowService, ctx.Logger)
+
+	apiKeyService := apikeys.NewService(ctx.ClientRepo, jwtGenInfo, ctx.KeyManager, ctx.Logger)
+	apiKeyHandler := apikeys.NewHandler(apiKeyService, ctx.Logger)
+
 	// clientService := clients.NewService(ctx.ClientRepo, ctx.Logger)
 	// clientHandler := clients.NewHandler(clientService, ctx.Logger)
 
-	var withAuth = mw.Authorize(ctx.KeyManager.JWTKey())
-	var adminOnly = mw.RequireAdmin(ctx.ClientRepo)
+	var withAuth = mw.Authorize(ctx.KeyManager.JWTKey(), apiKeyService)
+	var adminOnly = mw.RequireAdmin(ctx.ClientRepo, auth.AdminMfaRequired(ctx.Cfg))
+	var freshMfa = mw.RequireFreshMfa(auth.MfaFreshFor)
+	var keysRead = mw.RequireScope(auth.ScopeKeysRead)
+	var keysWrite = mw.RequireScope(auth.ScopeKeysWrite)
+	var unscoped = mw.RequireUnscoped()
 	var globalHandler = httpctx.GlobalAppHandler(ctx.Logger)
 
-	// Register routes for dev-only environment
-	if ctx.Cfg["ENV"] == "dev" {
-		// http.Handle("/clients", globalHandler(httpctx.AppHandler(clientHandler.GetAllDev)))
-		http.Handle("/keys", globalHandler(httpctx.AppHandler(keyHandler.GetAllDev)))
+	// RATE_LIMIT applies to every route, route specific limits (e.g. RATE_LIMIT_KEY_READ) replace it with their own buckets.
+	// Authenticated routes are limited per IP before the token is verified too, and per client after that.
+	// The quota only counts requests that passed the scope check.
+	var ipLimited = mw.RateLimitByIP(mw.NewRateLimiter(ctx.Cfg.RateLimit))
+	var limited = mw.RateLimit(mw.NewRateLimiter(ctx.Cfg.RateLimit))
+	var keyReadLimited = mw.RateLimit(mw.NewRateLimiter(ctx.Cfg.RateLimitKeyRead))
+	var authLimited = mw.RateLimit(mw.NewRateLimiter(ctx.Cfg.RateLimitAuth))
+	var keyReadQuotaReached = mw.Quota(mw.NewDailyQuota(ctx.Cfg.KeyReadQuotaDaily))
+
+	routes := []*mw.Route{
+		// Keys
+		mw.NewRoute("POST", "/keys/actions/generate", keyHandler.GenerateKey, ipLimited, withAuth, mw.CountKeyOperation("generate"), limited, keysWrite).
+			Describe(mw.RouteDoc{Operation: "generateKey", Summary: "Generate a key", Request: keys.GenerateKeyRequest{}, Response: keys.KeyResponse{}}),
+		mw.NewRoute("POST", "/keys/actions/import", keyHandler.ImportKey, ipLimited, withAuth, mw.CountKeyOperation("import"), limited, keysWrite).
+			Describe(mw.RouteDoc{Operation: "importKey", Summary: "Import a wrapped key", Request: keys.ImportKeyRequest{}, Response: keys.KeyResponse{}}),
+		mw.NewRoute("POST", "/keys/actions/import/wrapping-key", keyHandler.CreateWrappingKey, ipLimited, withAuth, mw.CountKeyOperation("create-wrapping-key"), limited, keysWrite).
+			Describe(mw.RouteDoc{Operation: "createWrappingKey", Summary: "Create a one-time key to wrap an imported key with", Request: keys.WrappingKeyRequest{}, Response: keys.WrappingKeyResponse{}}),
+		mw.NewRoute("GET", "/keys/{keyReference}/latest", keyHandler.GetKey, ipLimited, withAuth, mw.CountKeyOperation("get"), keyReadLimited, keysRead, keyReadQuotaReached).
+			Describe(mw.RouteDoc{Operation: "getLatestKey", Summary: "Get the latest version of a key", Response: keys.KeyLookupResponse{}}),
+		mw.NewRoute("GET", "/keys/{keyReference}/{version:int}", keyHandler.GetKey, ipLimited, withAuth, mw.CountKeyOperation("get"), keyReadLimited, keysRead, keyReadQuotaReached).
+			Describe(mw.RouteDoc{Operation: "getKey", Summary: "Get a key version, and the latest version to encrypt with", Response: keys.KeyLookupResponse{}}),
+		mw.NewRoute("DELETE", "/keys/{keyReference}/actions/delete", keyHandler.DeleteKey, ipLimited, withAuth, mw.CountKeyOperation("delete"), limited, keysWrite).
+			Describe(mw.RouteDoc{Operation: "deleteKey", Summary: "Schedule a key for destruction", Response: keys.KeyDeletionResponse{}}),
+		mw.NewRoute("POST", "/keys/{keyReference}/actions/restore", keyHandler.RestoreKey, ipLimited, withAuth, mw.CountKeyOperation("restore"), limited, keysWrite).
+			Describe(mw.RouteDoc{Operation: "restoreKey", Summary: "Restore a key scheduled for destruction"}),
+		mw.NewRoute("DELETE", "/keys/{keyReference}/{version:int}/actions/destroy", keyHandler.DestroyKeyVersion, ipLimited, withAuth, mw.CountKeyOperation("destroy"), limited, keysWrite).
+			Describe(mw.RouteDoc{Operation: "destroyKeyVersion", Summary: "Destroy a key version"}),
+		mw.NewRoute("POST", "/keys/{keyReference}/actions/prune", keyHandler.PruneKeyVersions, ipLimited, withAuth, mw.CountKeyOperation("prune"), limited, keysWrite).
+			Describe(mw.RouteDoc{Operation: "pruneKeyVersions", Summary: "Destroy old key versions", Request: keys.PruneKeyVersionsRequest{}, Response: keys.PruneKeyVersionsResponse{}}),
+		mw.NewRoute("POST", "/keys/{keyReference}/actions/rotate", keyHandler.RotateKey, ipLimited, withAuth, mw.CountKeyOperation("rotate"), limited, keysWrite).
+			Describe(mw.RouteDoc{Operation: "rotateKey", Summary: "Create a new key version", Response: keys.KeyResponse{}}),
+
+		// Auth
+		mw.NewRoute("POST", "/auth/signup/generate", adminHandler.GenerateSignupToken, ipLimited, withAuth, limited, adminOnly).
+			Describe(mw.RouteDoc{Operation: "generateSignupToken", Summary: "Generate a signup token (admin)", Request: admin.GenerateSignupTokenRequest{}, Response: dto.TokenResponse{}}),
+		mw.NewRoute("POST", "/auth/signup", authHandler.Signup, authLimited).
+			Describe(mw.RouteDoc{Operation: "signup", Summary: "Register with a signup token", Request: auth.SignupCredentials{}, Response: dto.TokenResponse{}, Public: true}),
+		mw.NewRoute("POST", "/auth/login", authHandler.Login, authLimited).
+			Describe(mw.RouteDoc{Operation: "login", Summary: "Log in with clientname and password", Request: auth.Credentials{}, Response: dto.TokenResponse{}, Public: true}),
+		mw.NewRoute("POST", "/auth/login/certificate", authHandler.LoginWithCertificate, authLimited).
+			Describe(mw.RouteDoc{Operation: "loginWithCertificate", Summary: "Log in with a client certificate (mTLS)", Response: dto.TokenResponse{}, Public: true}),
+		mw.NewRoute("POST", "/auth/login/oidc", authHandler.LoginWithOIDC, authLimited).
+			Describe(mw.RouteDoc{Operation: "loginWithOIDC", Summary: "Log in with an OIDC ID token", Request: auth.OIDCCredentials{}, Response: dto.TokenResponse{}, Public: true}),
+		mw.NewRoute("POST", "/auth/login/api-key", apiKeyHandler.Login, authLimited).
+			Describe(mw.RouteDoc{Operation: "loginWithApiKey", Summary: "Exchange an API key for a JWT", Response: dto.TokenResponse{}}),
+		mw.NewRoute("POST", "/auth/password", authHandler.ChangePassword, ipLimited, withAuth, limited, unscoped).
+			Describe(mw.RouteDoc{Operation: "changePassword", Summary: "Change the password", Request: auth.ChangePasswordRequest{}}),
+		mw.NewRoute("POST", "/auth/password/reset", authHandler.ResetPassword, authLimited).
+			Describe(mw.RouteDoc{Operation: "resetPassword", Summary: "Set a new password with a reset token", Request: auth.ResetPasswordCredentials{}, Response: dto.TokenResponse{}, Public: true}),
+		mw.NewRoute("POST", "/auth/mfa/actions/enroll", authHandler.EnrollMfa, ipLimited, withAuth, limited, unscoped).
+			Describe(mw.RouteDoc{Operation: "enrollMfa", Summary: "Start a TOTP enrolment (admins)", Response: auth.EnrollMfaResponse{}}),
+		mw.NewRoute("POST", "/auth/mfa/actions/activate", authHandler.ActivateMfa, ipLimited, withAuth, limited, unscoped).
+			Describe(mw.RouteDoc{Operation: "activateMfa", Summary: "Enable MFA with a code, returns recovery codes", Request: auth.MfaCodeRequest{}, Response: auth.RecoveryCodesResponse{}}),
+		mw.NewRoute("POST", "/auth/mfa/actions/disable", authHandler.DisableMfa, ipLimited, withAuth, limited, unscoped).
+			Describe(mw.RouteDoc{Operation: "disableMfa", Summary: "Disable MFA", Request: auth.MfaCodeRequest{}}),
+		mw.NewRoute("POST", "/auth/api-keys/actions/create", apiKeyHandler.Create, ipLimited, withAuth, limited, unscoped).
+			Describe(mw.RouteDoc{Operation: "createApiKey", Summary: "Create an API key", Request: apikeys.CreateApiKeyRequest{}, Response: apikeys.CreateApiKeyResponse{}}),
+		mw.NewRoute("GET", "/auth/api-keys", apiKeyHandler.GetAll, ipLimited, withAuth, limited, unscoped).
+			Describe(mw.RouteDoc{Operation: "getApiKeys", Summary: "List API keys", Response: []clients.ApiKey{}}),
+		mw.NewRoute("DELETE", "/auth/api-keys/{keyId:int}", apiKeyHandler.Revoke, ipLimited, withAuth, limited, unscoped).
+			Describe(mw.RouteDoc{Operation: "revokeApiKey", Summary: "Revoke an API key"}),
+
+		// Clients
+		mw.NewRoute("GET", "/clients", adminHandler.GetClients, ipLimited, withAuth, limited, adminOnly).
+			Describe(mw.RouteDoc{Operation: "getClients", Summary: "List clients (admin)", Response: []clients.Client{}}),
+		mw.NewRoute("DELETE", "/clients/{id:int}", adminHandler.DeleteClient, ipLimited, withAuth, limited, adminOnly, freshMfa).
+			Describe(mw.RouteDoc{Operation: "deleteClient", Summary: "Delete a client (admin, recent MFA)"}),
+		mw.NewRoute("POST", "/clients/{id:int}/role", adminHandler.UpdateRole, ipLimited, withAuth, limited, adminOnly, freshMfa).
+			Describe(mw.RouteDoc{Operation: "updateRole", Summary: "Change a client's role (admin, recent MFA)", Request: admin.UpdateRoleRequest{}}),
+		mw.NewRoute("POST", "/clients/{id:int}/actions/unlock", adminHandler.UnlockClient, ipLimited, withAuth, limited, adminOnly).
+			Describe(mw.RouteDoc{Operation: "unlockClient", Summary: "Lift a login lockout (admin)"}),
+		mw.NewRoute("POST", "/clients/{id:int}/mfa/actions/reset", adminHandler.ResetMfa, ipLimited, withAuth, limited, adminOnly, freshMfa).
+			Describe(mw.RouteDoc{Operation: "resetMfa", Summary: "Remove a client's MFA (admin, recent MFA)"}),
+		mw.NewRoute("POST", "/clients/{id:int}/password/actions/reset", adminHandler.GenerateResetToken, ipLimited, withAuth, limited, adminOnly, freshMfa).
+			Describe(mw.RouteDoc{Operation: "generateResetToken", Summary: "Generate a password reset token (admin, recent MFA)", Request: admin.GenerateResetTokenRequest{}, Response: dto.TokenResponse{}}),
+		mw.NewRoute("POST", "/clients/{id:int}/certificates/actions/bind", adminHandler.BindCertificate, ipLimited, withAuth, limited, adminOnly, freshMfa).
+			Describe(mw.RouteDoc{Operation: "bindCertificate", Summary: "Bind a certificate identity (admin, recent MFA)", Request: admin.BindCertificateRequest{}, Response: clients.Certificate{}}),
+		mw.NewRoute("GET", "/clients/{id:int}/certificates", adminHandler.GetCertificates, ipLimited, withAuth, limited, adminOnly).
+			Describe(mw.RouteDoc{Operation: "getCertificates", Summary: "List certificate bindings (admin)", Response: []clients.Certificate{}}),
+		mw.NewRoute("DELETE", "/clients/{id:int}/certificates/{certId:int}", adminHandler.UnbindCertificate, ipLimited, withAuth, limited, adminOnly).
+			Describe(mw.RouteDoc{Operation: "unbindCertificate", Summary: "Remove a certificate binding (admin)"}),
+		mw.NewRoute("POST", "/clients/{id:int}/api-keys/actions/create", apiKeyHandler.Create, ipLimited, withAuth, limited, adminOnly, freshMfa).
+			Describe(mw.RouteDoc{Operation: "createClientApiKey", Summary: "Create an API key for a client (admin, recent MFA)", Request: apikeys.CreateApiKeyRequest{}, Response: apikeys.CreateApiKeyResponse{}}),
+		mw.NewRoute("GET", "/clients/{id:int}/api-keys", apiKeyHandler.GetAll, ipLimited, withAuth, limited, adminOnly).
+			Describe(mw.RouteDoc{Operation: "getClientApiKeys", Summary: "List a client's API keys (admin)", Response: []clients.ApiKey{}}),
+		mw.NewRoute("DELETE", "/clients/{id:int}/api-keys/{keyId:int}", apiKeyHandler.Revoke, ipLimited, withAuth, limited, adminOnly).
+			Describe(mw.RouteDoc{Operation: "revokeClientApiKey", Summary: "Revoke a client's API key (admin)"}),
+
+		// Admin
+		mw.NewRoute("POST", "/admin/escrow/export", escrowHandler.Export, ipLimited, withAuth, limited, adminOnly).
+			Describe(mw.RouteDoc{Operation: "exportEscrow", Summary: "Export wrapped keys for escrow (admin)", Request: escrow.ExportRequest{}, Response: escrow.File{}}),
+	}
+
+	// Dev-only routes
+	if ctx.Cfg.Env == "dev" {
+		routes = append(routes, mw.NewRoute("GET", "/keys", keyHandler.GetAllDev))
+	}
+
+	spec, err := openapi.Generate("KMS", "1.0.0", apiVersion, routes)
+	if err != nil {
+		return err
+	}
+
+	healthHandler, err := newHealthHandler(ctx)
+	if err != nil {
+		return err
+	}
+
+	// the unversioned paths stay as deprecated aliases
+	routes = append(mw.Versioned(apiVersion, routes),
+		mw.NewRoute("GET", apiVersion+"/openapi.json", openapi.Handler(spec), authLimited),
+		mw.NewRoute("GET", "/version", healthHandler.Version),
+	)
+	// readiness queries the database and runs the KEK self-test, so the probes are only public without an admin listener
+	if ctx.Cfg.MetricsAddr == "" {
+		routes = append(routes,
+			mw.NewRoute("GET", "/healthz", healthHandler.Healthz, ipLimited),
+			mw.NewRoute("GET", "/readyz", healthHandler.Readyz, ipLimited),
+		)
 	}
 
-	http.Handle("/keys/", globalHandler(mw.MakeRouter(
-		[]*mw.Route{
-			mw.NewRoute(
-				"POST",
-				"/keys/actions/generate",
-				withAuth(keyHandler.GenerateKey),
-			),
-			mw.NewRoute(
-				"GET",
-				"/keys/{keyReference}/{version}",
-				withAuth(keyHandler.GetKey),
-			),
-			mw.NewRoute(
-				"DELETE",
-				"/keys/{keyReference}/actions/delete",
-				withAuth(keyHandler.DeleteKey),
-			),
-			mw.NewRoute(
-				"POST",
-				"/keys/{keyReference}/actions/rotate",
-				withAuth(keyHandler.RotateKey),
-			),
-		},
-	)))
-
-	// Auth
-	http.Handle("/auth/", globalHandler(mw.MakeRouter(
-		[]*mw.Route{
-			mw.NewRoute(
-				"POST",
-				"/auth/signup/generate",
-				withAuth(adminOnly(adminHandler.GenerateSignupToken)),
-			),
-			mw.NewRoute(
-				"POST",
-				"/auth/signup",
-				authHandler.Signup,
-			),
-			mw.NewRoute(
-				"POST",
-				"/auth/login",
-				authHandler.Login,
-			),
-		},
-	)))
-
-	// Clients
-	http.Handle("/clients/", globalHandler(mw.MakeRouter(
-		[]*mw.Route{
-			mw.NewRoute(
-				"POST",
-				"/clients/{id}/role",
-				withAuth(adminOnly(adminHandler.UpdateRole)),
-			),
-			mw.NewRoute(
-				"GET",
-				"/clients",
-				withAuth(adminOnly(adminHandler.GetClients)),
-			),
-			mw.NewRoute(
-				"DELETE",
-				"/clients/{id}",
-				withAuth(adminOnly(adminHandler.DeleteClient)),
-			),
-		},
-	)))
-
-	// Admin
-	// http.Handle("/admin", globalHandler(withAuth(adminOnly(adminHandler.Me))))
+	router, err := mw.NewRouter(routes...)
+	if err != nil {
+		return err
+	}
+
+	http.Handle("/", globalHandler(mw.Timeout(ctx.Cfg.Server.RequestTimeout)(router.ServeApp)))
+
+	return nil
+}
+
+// Routes of the admin listener (METRICS_ADDR), plain HTTP and unauthenticated: metrics and the probes
+func RegisterAdminRoutes(mux *http.ServeMux, ctx *bootstrap.AppContext) error {
+	healthHandler, err := newHealthHandler(ctx)
+	if err != nil {
+		return err
+	}
+	globalHandler := httpctx.GlobalAppHandler(ctx.Logger)
 
+	mux.Handle("/metrics", metrics.Default.Handler())
+	mux.Handle("GET /healthz", globalHandler(healthHandler.Healthz))
+	mux.Handle("GET /readyz", globalHandler(healthHandler.Readyz))
 	return nil
 }
+
+// Readiness needs the database at the latest migration and working keys
+func newHealthHandler(ctx *bootstrap.AppContext) (*health.Handler, error) {
+	checks := []health.Check{health.DatabaseCheck(ctx.DB)}
+	if ctx.MigrationsPath != "" {
+		expected, err := bootstrap.LatestMigration(ctx.MigrationsPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read migrations: %w", err)
+		}
+		checks = append(checks, health.MigrationCheck(ctx.DB, expected))
+	}
+	kekCheck, err := health.KEKCheck(ctx.KeyManager)
+	if err != nil {
+		return nil, err
+	}
+	checks = append(checks, health.KeyManagerCheck(ctx.KeyManager), kekCheck)
+	return health.NewHandler(checks, ctx.Logger), nil
+}
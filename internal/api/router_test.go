@@ -3,19 +3,59 @@ package api
 import (
 	"kms/internal/bootstrap"
 	c "kms/internal/bootstrap/context"
+	"kms/internal/test/mocks"
+	"kms/pkg/encryption"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
 	"testing"
 )
 
-func TestRouter_InvalidJWTTTL(t *testing.T) {
-	cfg := c.KmsConfig(map[string]string{
-		"JWT_TTL": "invalid", // Invalid TTL
-	})
+// JWT_TTL and the other settings are validated by bootstrap.ParseKmsConfig, only files are read here
+func TestRouter_MissingOIDCJWKS(t *testing.T) {
+	cfg := &c.Config{
+		OIDC: c.OIDCConfig{
+			Issuer:   "https://issuer.example",
+			Audience: "kms",
+			JWKSFile: filepath.Join(t.TempDir(), "missing.json"),
+		},
+	}
 
 	ctx := &bootstrap.AppContext{
-		Cfg: cfg,
+		Cfg:        cfg,
+		KeyManager: mocks.NewKeyManagerMock(),
 	}
 	err := RegisterRoutes(ctx)
 	if err == nil {
-		t.Error("expected error for invalid JWT_TTL, got nil")
+		t.Error("expected error for missing OIDC_JWKS_FILE, got nil")
+	}
+}
+
+func TestRegisterAdminRoutes_Probes(t *testing.T) {
+	kek, err := encryption.GenerateKey(32)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keyManager := mocks.NewKeyManagerMock()
+	keyManager.KEKFunc = func() []byte {
+		return kek
+	}
+	ctx := &bootstrap.AppContext{
+		Cfg:        &c.Config{},
+		KeyManager: keyManager,
+		Logger:     mocks.NewLoggerMock(),
+	}
+
+	mux := http.NewServeMux()
+	if err := RegisterAdminRoutes(mux, ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for path, expected := range map[string]int{"/healthz": http.StatusOK, "/metrics": http.StatusOK, "/keys": http.StatusNotFound} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != expected {
+			t.Errorf("expected %d for %s, got %d", expected, path, rr.Code)
+		}
 	}
 }
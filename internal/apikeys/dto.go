@@ -0,0 +1,85 @@
+package apikeys
+
+import (
+	"crypto/rand"
+	b64 "encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"kms/internal/auth"
+	"kms/internal/clients"
+	"strings"
+)
+
+// API keys look like 'kms_<prefix>_<secret>', the prefix identifies the key and is stored as is,
+// the secret is only stored hashed
+const KeyPrefix = "kms_"
+
+const (
+	prefixSize = 6
+	secretSize = 32
+	maxTtlDays = 3650
+)
+
+type CreateApiKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// 0 for a key that doesn't expire
+	TtlDays int `json:"ttlDays,omitempty"`
+}
+
+func (r *CreateApiKeyRequest) Validate() error {
+	if r.Name == "" || len(r.Name) > 64 {
+		return fmt.Errorf("name should be non-empty and at most 64 characters")
+	}
+	if len(r.Scopes) == 0 {
+		return fmt.Errorf("at least one scope is required (%s)", strings.Join(auth.Scopes, ", "))
+	}
+	seen := map[string]bool{}
+	for _, scope := range r.Scopes {
+		if !auth.IsScope(scope) {
+			return fmt.Errorf("unknown scope '%s', should be one of %s", scope, strings.Join(auth.Scopes, ", "))
+		}
+		if seen[scope] {
+			return fmt.Errorf("duplicate scope '%s'", scope)
+		}
+		seen[scope] = true
+	}
+	if r.TtlDays < 0 || r.TtlDays > maxTtlDays {
+		return fmt.Errorf("ttlDays should be between 0 and %d", maxTtlDays)
+	}
+	return nil
+}
+
+type CreateApiKeyResponse struct {
+	// Only returned on creation
+	Key string `json:"key"`
+	*clients.ApiKey
+}
+
+func generateApiKey() (key, prefix, secret string, err error) {
+	prefixBytes := make([]byte, prefixSize)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", "", "", err
+	}
+	secretBytes := make([]byte, secretSize)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", "", err
+	}
+	prefix = hex.EncodeToString(prefixBytes)
+	secret = b64.RawURLEncoding.EncodeToString(secretBytes)
+	return KeyPrefix + prefix + "_" + secret, prefix, secret, nil
+}
+
+func ParseApiKey(key string) (prefix, secret string, err error) {
+	rest, ok := strings.CutPrefix(key, KeyPrefix)
+	if ok {
+		prefix, secret, ok = strings.Cut(rest, "_")
+	}
+	if !ok || len(prefix) != 2*prefixSize || secret == "" {
+		return "", "", fmt.Errorf("not an API key")
+	}
+	if _, err := hex.DecodeString(prefix); err != nil {
+		return "", "", fmt.Errorf("not an API key: %w", err)
+	}
+	return prefix, secret, nil
+}
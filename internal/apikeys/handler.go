@@ -0,0 +1,148 @@
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"kms/internal/api/dto"
+	"kms/internal/auth"
+	c "kms/internal/bootstrap/context"
+	"kms/internal/clients"
+	"kms/internal/httpctx"
+	kmsErrors "kms/pkg/errors"
+	pHttp "kms/pkg/http"
+	"kms/pkg/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type Handler struct {
+	Service ApiKeyService
+	Logger  c.Logger
+}
+
+func NewHandler(apiKeyService ApiKeyService, logger c.Logger) *Handler {
+	return &Handler{
+		Service: apiKeyService,
+		Logger:  logger,
+	}
+}
+
+type ApiKeyService interface {
+	Create(ctx context.Context, clientId int, body *CreateApiKeyRequest, caller *auth.Token) (*CreateApiKeyResponse, *kmsErrors.AppError)
+	GetAll(ctx context.Context, clientId int) ([]clients.ApiKey, *kmsErrors.AppError)
+	Revoke(ctx context.Context, clientId, id int, callerId string) *kmsErrors.AppError
+	Login(ctx context.Context, key string) (string, *kmsErrors.AppError)
+}
+
+func (h *Handler) Create(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	token, err := httpctx.ExtractToken(r.Context())
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	clientId, appErr := owner(r, token)
+	if appErr != nil {
+		return appErr
+	}
+
+	var body CreateApiKeyRequest
+	if err := json.ParseBody(r.Body, &body); err != nil {
+		return kmsErrors.NewInvalidBodyError(err)
+	}
+
+	if err := body.Validate(); err != nil {
+		return kmsErrors.NewInvalidBodyError(err)
+	}
+
+	response, appErr := h.Service.Create(r.Context(), clientId, &body, token)
+	if appErr != nil {
+		return appErr
+	}
+
+	return pHttp.WriteJSON(w, response)
+}
+
+func (h *Handler) GetAll(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	token, err := httpctx.ExtractToken(r.Context())
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	clientId, appErr := owner(r, token)
+	if appErr != nil {
+		return appErr
+	}
+
+	apiKeys, appErr := h.Service.GetAll(r.Context(), clientId)
+	if appErr != nil {
+		return appErr
+	}
+
+	return pHttp.WriteJSON(w, apiKeys)
+}
+
+func (h *Handler) Revoke(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	token, err := httpctx.ExtractToken(r.Context())
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	clientId, appErr := owner(r, token)
+	if appErr != nil {
+		return appErr
+	}
+
+	keyIdStr, err := httpctx.GetRouteParam(r.Context(), "keyId")
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	keyId, err := strconv.Atoi(keyIdStr)
+	if err != nil {
+		return kmsErrors.NewAppError(err, "ID must be integer", 400).WithErrorCode(kmsErrors.CodeInvalidParameter)
+	}
+
+	if appErr := h.Service.Revoke(r.Context(), clientId, keyId, token.Payload.Sub); appErr != nil {
+		return appErr
+	}
+
+	return pHttp.WriteStatus(w, http.StatusNoContent)
+}
+
+// Exchange 'Authorization: ApiKey <key>' for a JWT
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	key, ok := strings.CutPrefix(strings.TrimSpace(r.Header.Get("Authorization")), "ApiKey ")
+	if !ok {
+		return kmsErrors.NewAppError(errors.New("API key missing"), "Unauthorized", 401)
+	}
+
+	jwt, appErr := h.Service.Login(r.Context(), strings.TrimSpace(key))
+	if appErr != nil {
+		return appErr
+	}
+
+	response := &dto.TokenResponse{
+		Token: jwt,
+	}
+
+	return pHttp.WriteJSON(w, response)
+}
+
+// Admin routes ('/clients/{id}/api-keys') manage the keys of the client in the path,
+// the others the keys of the calling client
+func owner(r *http.Request, token *auth.Token) (int, *kmsErrors.AppError) {
+	if idStr, err := httpctx.GetRouteParam(r.Context(), "id"); err == nil {
+		clientId, err := strconv.Atoi(idStr)
+		if err != nil {
+			return 0, kmsErrors.NewAppError(err, "ID must be integer", 400).WithErrorCode(kmsErrors.CodeInvalidParameter)
+		}
+		return clientId, nil
+	}
+
+	clientId, err := strconv.Atoi(token.Payload.Sub)
+	if err != nil {
+		return 0, kmsErrors.NewInternalServerError(err)
+	}
+	return clientId, nil
+}
@@ -0,0 +1,77 @@
+package apikeys
+
+import (
+	"context"
+	"kms/internal/auth"
+	"kms/internal/clients"
+	"kms/internal/httpctx"
+	"kms/internal/test/mocks"
+	kmsErrors "kms/pkg/errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func withToken(ctx context.Context, sub string, params map[string]string) context.Context {
+	ctx = context.WithValue(ctx, httpctx.TokenCtxKey, auth.Token{Payload: &auth.TokenPayload{Sub: sub}})
+	return context.WithValue(ctx, httpctx.RouteParamsCtxKey, params)
+}
+
+func TestHandler_GetAll_Owner(t *testing.T) {
+	var gotClientId int
+	mockService := NewApiKeyServiceMock()
+	mockService.GetAllFunc = func(ctx context.Context, clientId int) ([]clients.ApiKey, *kmsErrors.AppError) {
+		gotClientId = clientId
+		return []clients.ApiKey{{ID: 1, ClientId: clientId}}, nil
+	}
+	handler := NewHandler(mockService, mocks.NewLoggerMock())
+
+	// own keys
+	req := httptest.NewRequest("GET", "/auth/api-keys", nil)
+	req = req.WithContext(withToken(req.Context(), "3", map[string]string{}))
+	if appErr := handler.GetAll(httptest.NewRecorder(), req); appErr != nil || gotClientId != 3 {
+		t.Errorf("expected keys of client 3, got %d (%v)", gotClientId, appErr)
+	}
+
+	// admin route
+	req = httptest.NewRequest("GET", "/clients/5/api-keys", nil)
+	req = req.WithContext(withToken(req.Context(), "1", map[string]string{"id": "5"}))
+	if appErr := handler.GetAll(httptest.NewRecorder(), req); appErr != nil || gotClientId != 5 {
+		t.Errorf("expected keys of client 5, got %d (%v)", gotClientId, appErr)
+	}
+}
+
+func TestHandler_Create_InvalidBody(t *testing.T) {
+	handler := NewHandler(NewApiKeyServiceMock(), mocks.NewLoggerMock())
+
+	req := httptest.NewRequest("POST", "/auth/api-keys/actions/create", strings.NewReader(`{"name":"ci","scopes":["everything"]}`))
+	req = req.WithContext(withToken(req.Context(), "3", map[string]string{}))
+	if appErr := handler.Create(httptest.NewRecorder(), req); appErr == nil || appErr.Code != 400 {
+		t.Errorf("expected 400, got %v", appErr)
+	}
+}
+
+func TestHandler_Login(t *testing.T) {
+	mockService := NewApiKeyServiceMock()
+	mockService.LoginFunc = func(ctx context.Context, key string) (string, *kmsErrors.AppError) {
+		if key != "kms_0123456789ab_secret" {
+			t.Errorf("unexpected key %q", key)
+		}
+		return "jwt", nil
+	}
+	handler := NewHandler(mockService, mocks.NewLoggerMock())
+
+	req := httptest.NewRequest("POST", "/auth/login/api-key", nil)
+	if appErr := handler.Login(httptest.NewRecorder(), req); appErr == nil || appErr.Code != 401 {
+		t.Fatalf("expected 401, got %v", appErr)
+	}
+
+	req.Header.Set("Authorization", "ApiKey kms_0123456789ab_secret")
+	rr := httptest.NewRecorder()
+	if appErr := handler.Login(rr, req); appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	if !strings.Contains(rr.Body.String(), `"token":"jwt"`) {
+		t.Errorf("expected token in body, got %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,49 @@
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"kms/internal/auth"
+	"kms/internal/clients"
+	kmsErrors "kms/pkg/errors"
+)
+
+// Service mock for API key operations
+type ApiKeyServiceMock struct {
+	CreateFunc func(ctx context.Context, clientId int, body *CreateApiKeyRequest, caller *auth.Token) (*CreateApiKeyResponse, *kmsErrors.AppError)
+	GetAllFunc func(ctx context.Context, clientId int) ([]clients.ApiKey, *kmsErrors.AppError)
+	RevokeFunc func(ctx context.Context, clientId, id int, callerId string) *kmsErrors.AppError
+	LoginFunc  func(ctx context.Context, key string) (string, *kmsErrors.AppError)
+}
+
+func NewApiKeyServiceMock() *ApiKeyServiceMock {
+	return &ApiKeyServiceMock{}
+}
+
+func (m *ApiKeyServiceMock) Create(ctx context.Context, clientId int, body *CreateApiKeyRequest, caller *auth.Token) (*CreateApiKeyResponse, *kmsErrors.AppError) {
+	if m.CreateFunc != nil {
+		return m.CreateFunc(ctx, clientId, body, caller)
+	}
+	return nil, kmsErrors.LiftToAppError(errors.New("CreateFunc not implemented in mock"))
+}
+
+func (m *ApiKeyServiceMock) GetAll(ctx context.Context, clientId int) ([]clients.ApiKey, *kmsErrors.AppError) {
+	if m.GetAllFunc != nil {
+		return m.GetAllFunc(ctx, clientId)
+	}
+	return nil, kmsErrors.LiftToAppError(errors.New("GetAllFunc not implemented in mock"))
+}
+
+func (m *ApiKeyServiceMock) Revoke(ctx context.Context, clientId, id int, callerId string) *kmsErrors.AppError {
+	if m.RevokeFunc != nil {
+		return m.RevokeFunc(ctx, clientId, id, callerId)
+	}
+	return kmsErrors.LiftToAppError(errors.New("RevokeFunc not implemented in mock"))
+}
+
+func (m *ApiKeyServiceMock) Login(ctx context.Context, key string) (string, *kmsErrors.AppError) {
+	if m.LoginFunc != nil {
+		return m.LoginFunc(ctx, key)
+	}
+	return "", kmsErrors.LiftToAppError(errors.New("LoginFunc not implemented in mock"))
+}
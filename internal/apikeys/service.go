@@ -0,0 +1,196 @@
+package apikeys
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"kms/internal/auth"
+	c "kms/internal/bootstrap/context"
+	"kms/internal/clients"
+	kmsErrors "kms/pkg/errors"
+	"kms/pkg/hashing"
+	"strconv"
+	"time"
+)
+
+type Service struct {
+	ClientRepo   clients.ClientRepository
+	TokenGenInfo *auth.TokenGenInfo
+	KeyManager   c.KeyManager
+	Logger       c.Logger
+}
+
+func NewService(
+	clientRepo clients.ClientRepository,
+	tokenGenInfo *auth.TokenGenInfo,
+	keyManager c.KeyManager,
+	logger c.Logger,
+) *Service {
+	return &Service{
+		ClientRepo:   clientRepo,
+		TokenGenInfo: tokenGenInfo,
+		KeyManager:   keyManager,
+		Logger:       logger,
+	}
+}
+
+// A scoped caller (e.g. itself an API key) can't create a key with more scopes than it has
+func (s *Service) Create(ctx context.Context, clientId int, body *CreateApiKeyRequest, caller *auth.Token) (*CreateApiKeyResponse, *kmsErrors.AppError) {
+	for _, scope := range body.Scopes {
+		if !caller.Payload.HasScope(scope) {
+			return nil, kmsErrors.NewAppError(
+				fmt.Errorf("caller is missing scope (%v)", scope),
+				"Forbidden",
+				403,
+			)
+		}
+	}
+
+	if _, err := s.ClientRepo.GetClient(ctx, clientId); err != nil {
+		return nil, kmsErrors.MapRepoErr(err)
+	}
+
+	key, prefix, secret, err := generateApiKey()
+	if err != nil {
+		return nil, kmsErrors.NewInternalServerError(err)
+	}
+
+	hashedKey, appErr := s.hashSecret(secret)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	apiKey := &clients.ApiKey{
+		ClientId:  clientId,
+		Name:      body.Name,
+		Prefix:    prefix,
+		HashedKey: hashedKey,
+		Scopes:    body.Scopes,
+	}
+	if body.TtlDays > 0 {
+		expiresAt := time.Now().UTC().AddDate(0, 0, body.TtlDays)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	id, err := s.ClientRepo.CreateApiKey(ctx, apiKey)
+	if err != nil {
+		return nil, kmsErrors.MapRepoErr(err)
+	}
+	apiKey.ID = id
+
+	s.Logger.Notice("API key created", "callerId", caller.Payload.Sub, "clientId", clientId, "apiKeyId", id, "prefix", prefix, "scopes", body.Scopes)
+
+	return &CreateApiKeyResponse{
+		Key:    key,
+		ApiKey: apiKey,
+	}, nil
+}
+
+func (s *Service) GetAll(ctx context.Context, clientId int) ([]clients.ApiKey, *kmsErrors.AppError) {
+	apiKeys, err := s.ClientRepo.GetApiKeys(ctx, clientId)
+	if err != nil {
+		return nil, kmsErrors.MapRepoErr(err)
+	}
+	return apiKeys, nil
+}
+
+// Tokens already exchanged for the key stay valid until they expire
+func (s *Service) Revoke(ctx context.Context, clientId, id int, callerId string) *kmsErrors.AppError {
+	if err := s.ClientRepo.DeleteApiKey(ctx, clientId, id); err != nil {
+		return kmsErrors.MapRepoErr(err)
+	}
+
+	s.Logger.Notice("API key revoked", "callerId", callerId, "clientId", clientId, "apiKeyId", id)
+
+	return nil
+}
+
+// Used by middleware.Authorize for 'Authorization: ApiKey <key>'
+func (s *Service) AuthenticateApiKey(ctx context.Context, key string) (*auth.Token, *kmsErrors.AppError) {
+	apiKey, appErr := s.authenticate(ctx, key)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return &auth.Token{
+		Header: &auth.TokenHeader{
+			Ver: "1",
+			Typ: "apikey",
+		},
+		Payload: &auth.TokenPayload{
+			Sub:    strconv.Itoa(apiKey.ClientId),
+			Iat:    time.Now().UnixMilli(),
+			Scopes: apiKey.Scopes,
+		},
+	}, nil
+}
+
+// Exchange the key for a JWT with the key's scopes, which doesn't outlive the key
+func (s *Service) Login(ctx context.Context, key string) (string, *kmsErrors.AppError) {
+	apiKey, appErr := s.authenticate(ctx, key)
+	if appErr != nil {
+		return "", appErr
+	}
+
+	genInfo := *s.TokenGenInfo
+	if apiKey.ExpiresAt != nil {
+		genInfo.Ttl = min(genInfo.Ttl, time.Until(*apiKey.ExpiresAt).Milliseconds())
+	}
+
+	jwt, err := auth.GenerateScopedJWT(&genInfo, &clients.Client{ID: apiKey.ClientId}, apiKey.Scopes)
+	if err != nil {
+		return "", kmsErrors.NewInternalServerError(err)
+	}
+
+	s.Logger.Info("Client signed in with API key", "clientId", apiKey.ClientId, "apiKeyId", apiKey.ID)
+
+	return jwt, nil
+}
+
+func (s *Service) authenticate(ctx context.Context, key string) (*clients.ApiKey, *kmsErrors.AppError) {
+	prefix, secret, err := ParseApiKey(key)
+	if err != nil {
+		return nil, kmsErrors.NewAppError(err, "Unauthorized", 401)
+	}
+
+	apiKey, err := s.ClientRepo.FindApiKey(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, kmsErrors.NewAppError(fmt.Errorf("no API key with prefix %s", prefix), "Unauthorized", 401)
+		}
+		return nil, kmsErrors.MapRepoErr(err)
+	}
+
+	hashedKey, appErr := s.hashSecret(secret)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if subtle.ConstantTimeCompare([]byte(apiKey.HashedKey), []byte(hashedKey)) != 1 {
+		return nil, kmsErrors.NewAppError(fmt.Errorf("invalid secret for API key %d", apiKey.ID), "Unauthorized", 401)
+	}
+
+	if apiKey.ExpiresAt != nil && !time.Now().Before(*apiKey.ExpiresAt) {
+		return nil, kmsErrors.NewAppError(fmt.Errorf("API key %d expired at %v", apiKey.ID, apiKey.ExpiresAt), "Unauthorized", 401)
+	}
+
+	// checked on every use, keys created before MFA was enabled stop working
+	if appErr := auth.RefuseMfaClient(ctx, s.ClientRepo, apiKey.ClientId, "API key"); appErr != nil {
+		return nil, appErr
+	}
+
+	if err := s.ClientRepo.TouchApiKey(ctx, apiKey.ID); err != nil {
+		s.Logger.Warn("Failed to update last use of API key", "apiKeyId", apiKey.ID, "error", err)
+	}
+
+	return apiKey, nil
+}
+
+func (s *Service) hashSecret(secret string) (string, *kmsErrors.AppError) {
+	hashKey, err := s.KeyManager.HashKey("clientname")
+	if err != nil {
+		return "", kmsErrors.NewInternalServerError(err)
+	}
+	return hashing.HashHS256ToB64([]byte(secret), hashKey), nil
+}
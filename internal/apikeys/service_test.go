@@ -0,0 +1,191 @@
+package apikeys
+
+import (
+	"context"
+	"database/sql"
+	"kms/internal/auth"
+	"kms/internal/clients"
+	"kms/internal/test/mocks"
+	kmsErrors "kms/pkg/errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestService(repo *clients.ClientRepositoryMock) *Service {
+	mockKeyManager := mocks.NewKeyManagerMock()
+	mockKeyManager.HashKeyFunc = func(key string) ([]byte, error) {
+		return []byte("hashsecret"), nil
+	}
+	tokenGenInfo := &auth.TokenGenInfo{
+		Ttl:    3600 * 1000,
+		Secret: []byte("jwtsecret"),
+		Typ:    "jwt",
+	}
+	return NewService(repo, tokenGenInfo, mockKeyManager, mocks.NewLoggerMock())
+}
+
+// Repository mock that stores the created key
+func newStoringRepo(stored **clients.ApiKey) *clients.ClientRepositoryMock {
+	repo := clients.NewClientRepositoryMock()
+	repo.CreateApiKeyFunc = func(ctx context.Context, apiKey *clients.ApiKey) (int, error) {
+		*stored = apiKey
+		return 1, nil
+	}
+	repo.FindApiKeyFunc = func(ctx context.Context, prefix string) (*clients.ApiKey, error) {
+		if *stored == nil || (*stored).Prefix != prefix {
+			return nil, sql.ErrNoRows
+		}
+		return *stored, nil
+	}
+	repo.GetMfaFunc = func(ctx context.Context, clientId int) (*clients.Mfa, error) {
+		return nil, sql.ErrNoRows
+	}
+	return repo
+}
+
+func unscopedToken(sub string) *auth.Token {
+	return &auth.Token{Payload: &auth.TokenPayload{Sub: sub}}
+}
+
+func TestService_Create_Authenticate(t *testing.T) {
+	var stored *clients.ApiKey
+	touched := false
+	repo := newStoringRepo(&stored)
+	repo.TouchApiKeyFunc = func(ctx context.Context, id int) error {
+		touched = true
+		return nil
+	}
+	service := newTestService(repo)
+
+	response, appErr := service.Create(context.Background(), 7, &CreateApiKeyRequest{Name: "ci", Scopes: []string{auth.ScopeKeysRead}, TtlDays: 30}, unscopedToken("7"))
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	if !strings.HasPrefix(response.Key, KeyPrefix+stored.Prefix+"_") || response.ID != 1 {
+		t.Errorf("unexpected response: %+v", response)
+	}
+	if strings.Contains(response.Key, stored.HashedKey) || stored.ExpiresAt == nil {
+		t.Errorf("expected hashed key with expiry to be stored, got %+v", stored)
+	}
+
+	token, appErr := service.AuthenticateApiKey(context.Background(), response.Key)
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	if token.Payload.Sub != "7" || token.Payload.HasScope(auth.ScopeKeysWrite) || !touched {
+		t.Errorf("unexpected token: %+v", token.Payload)
+	}
+}
+
+func TestService_Create_ScopeEscalation(t *testing.T) {
+	var stored *clients.ApiKey
+	service := newTestService(newStoringRepo(&stored))
+
+	caller := &auth.Token{Payload: &auth.TokenPayload{Sub: "7", Scopes: []string{auth.ScopeKeysRead}}}
+	_, appErr := service.Create(context.Background(), 7, &CreateApiKeyRequest{Name: "ci", Scopes: []string{auth.ScopeKeysWrite}}, caller)
+	if appErr == nil || appErr.Code != 403 {
+		t.Fatalf("expected 403, got %v", appErr)
+	}
+}
+
+func TestService_AuthenticateApiKey_Invalid(t *testing.T) {
+	var stored *clients.ApiKey
+	service := newTestService(newStoringRepo(&stored))
+
+	response, appErr := service.Create(context.Background(), 7, &CreateApiKeyRequest{Name: "ci", Scopes: []string{auth.ScopeKeysRead}}, unscopedToken("7"))
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	prefix, _, _ := ParseApiKey(response.Key)
+
+	tests := map[string]string{
+		"not an API key": "secret",
+		"unknown prefix": KeyPrefix + "000000000000_secret",
+		"wrong secret":   KeyPrefix + prefix + "_secret",
+	}
+	for name, key := range tests {
+		if _, appErr := service.AuthenticateApiKey(context.Background(), key); appErr == nil || appErr.Code != 401 {
+			t.Errorf("%s: expected 401, got %v", name, appErr)
+		}
+	}
+
+	expired := time.Now().Add(-time.Minute)
+	stored.ExpiresAt = &expired
+	if _, appErr := service.AuthenticateApiKey(context.Background(), response.Key); appErr == nil || appErr.Code != 401 {
+		t.Errorf("expired: expected 401, got %v", appErr)
+	}
+}
+
+func TestService_Login(t *testing.T) {
+	var stored *clients.ApiKey
+	service := newTestService(newStoringRepo(&stored))
+
+	response, appErr := service.Create(context.Background(), 7, &CreateApiKeyRequest{Name: "ci", Scopes: []string{auth.ScopeKeysRead}, TtlDays: 1}, unscopedToken("1"))
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+
+	// expires before the JWT would
+	expiresAt := time.Now().Add(time.Minute)
+	stored.ExpiresAt = &expiresAt
+
+	jwt, appErr := service.Login(context.Background(), response.Key)
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	token, err := auth.VerifyToken(jwt, []byte("jwtsecret"))
+	if err != nil {
+		t.Fatalf("expected valid token, got %v", err)
+	}
+	if token.Payload.Sub != "7" || !token.Payload.HasScope(auth.ScopeKeysRead) || token.Payload.HasScope(auth.ScopeAdmin) {
+		t.Errorf("unexpected token: %+v", token.Payload)
+	}
+	if token.Payload.Ttl > time.Minute.Milliseconds() {
+		t.Errorf("expected token to expire with the key, ttl is %d", token.Payload.Ttl)
+	}
+}
+
+// Keys can't be used once their client has enabled MFA
+func TestService_MfaEnabled(t *testing.T) {
+	var stored *clients.ApiKey
+	repo := newStoringRepo(&stored)
+	service := newTestService(repo)
+
+	response, appErr := service.Create(context.Background(), 7, &CreateApiKeyRequest{Name: "ci", Scopes: []string{auth.ScopeKeysRead}}, unscopedToken("7"))
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	repo.GetMfaFunc = func(ctx context.Context, clientId int) (*clients.Mfa, error) {
+		return &clients.Mfa{ClientId: clientId, Enabled: true}, nil
+	}
+
+	if _, appErr := service.AuthenticateApiKey(context.Background(), response.Key); appErr == nil || appErr.Code != 401 || appErr.ErrorCode != kmsErrors.CodeMfaRequired {
+		t.Errorf("authenticate: expected 401 %s, got %v", kmsErrors.CodeMfaRequired, appErr)
+	}
+	if _, appErr := service.Login(context.Background(), response.Key); appErr == nil || appErr.Code != 401 || appErr.ErrorCode != kmsErrors.CodeMfaRequired {
+		t.Errorf("login: expected 401 %s, got %v", kmsErrors.CodeMfaRequired, appErr)
+	}
+}
+
+func TestCreateApiKeyRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    CreateApiKeyRequest
+		wantErr bool
+	}{
+		{name: "Valid", body: CreateApiKeyRequest{Name: "ci", Scopes: []string{auth.ScopeKeysRead, auth.ScopeKeysWrite}}},
+		{name: "Missing name", body: CreateApiKeyRequest{Scopes: []string{auth.ScopeKeysRead}}, wantErr: true},
+		{name: "No scopes", body: CreateApiKeyRequest{Name: "ci"}, wantErr: true},
+		{name: "Unknown scope", body: CreateApiKeyRequest{Name: "ci", Scopes: []string{"keys:*"}}, wantErr: true},
+		{name: "Duplicate scope", body: CreateApiKeyRequest{Name: "ci", Scopes: []string{auth.ScopeAdmin, auth.ScopeAdmin}}, wantErr: true},
+		{name: "Negative ttl", body: CreateApiKeyRequest{Name: "ci", Scopes: []string{auth.ScopeAdmin}, TtlDays: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.body.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("expected error: %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
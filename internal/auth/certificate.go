@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	b64 "encoding/base64"
+)
+
+// Identities a client certificate can be bound to, in order of preference.
+// Prefixed with their type so e.g. a DNS name can't pass for a common name.
+func CertificateIdentities(cert *x509.Certificate) []string {
+	var identities []string
+	for _, uri := range cert.URIs {
+		identities = append(identities, "uri:"+uri.String())
+	}
+	for _, name := range cert.DNSNames {
+		identities = append(identities, "dns:"+name)
+	}
+	for _, email := range cert.EmailAddresses {
+		identities = append(identities, "email:"+email)
+	}
+	if cert.Subject.CommonName != "" {
+		identities = append(identities, "cn:"+cert.Subject.CommonName)
+	}
+	return identities
+}
+
+// base64url SHA-256 of the certificate's public key (SPKI), stays the same when a certificate is renewed with the same key
+func CertificatePin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return b64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"kms/internal/clients"
+	"kms/internal/test/mocks"
+	"kms/pkg/hashing"
+	"math/big"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	kmsErrors "kms/pkg/errors"
+)
+
+func newTestCertificate(t *testing.T) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key failed: %v", err)
+	}
+	uri, _ := url.Parse("spiffe://mesh/ns/payments/sa/api")
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "payments"},
+		DNSNames:     []string{"payments.internal"},
+		URIs:         []*url.URL{uri},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate failed: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate failed: %v", err)
+	}
+	return cert
+}
+
+func TestCertificateIdentities(t *testing.T) {
+	identities := CertificateIdentities(newTestCertificate(t))
+	want := []string{"uri:spiffe://mesh/ns/payments/sa/api", "dns:payments.internal", "cn:payments"}
+	if !slices.Equal(identities, want) {
+		t.Errorf("expected %v, got %v", want, identities)
+	}
+}
+
+func newCertificateLoginService(t *testing.T, bindings map[string]*clients.Certificate) *Service {
+	secret := []byte("clientnamesecret")
+	mockRepo := clients.NewClientRepositoryMock()
+	mockRepo.FindCertificateFunc = func(ctx context.Context, hashedIdentity string) (*clients.Certificate, error) {
+		for identity, cert := range bindings {
+			if hashing.HashHS256ToB64([]byte(identity), secret) == hashedIdentity {
+				return cert, nil
+			}
+		}
+		return nil, sql.ErrNoRows
+	}
+	mockRepo.GetClientFunc = func(ctx context.Context, id int) (*clients.Client, error) {
+		return &clients.Client{ID: id, Role: "client"}, nil
+	}
+	mockRepo.GetMfaFunc = func(ctx context.Context, clientId int) (*clients.Mfa, error) {
+		return nil, sql.ErrNoRows
+	}
+	mockKeyManager := mocks.NewKeyManagerMock()
+	mockKeyManager.HashKeyFunc = func(key string) ([]byte, error) {
+		return secret, nil
+	}
+	tokenGenInfo := &TokenGenInfo{
+		Ttl:    3600,
+		Secret: []byte("jwtsecret"),
+		Typ:    "jwt",
+	}
+	return NewService(nil, mockRepo, tokenGenInfo, mockKeyManager, nil, nil, mocks.NewLoggerMock())
+}
+
+func TestService_LoginWithCertificate_Success(t *testing.T) {
+	cert := newTestCertificate(t)
+	service := newCertificateLoginService(t, map[string]*clients.Certificate{
+		"dns:payments.internal": {ID: 1, ClientId: 7, Pin: CertificatePin(cert)},
+	})
+
+	jwt, appErr := service.LoginWithCertificate(context.Background(), cert)
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	token, err := VerifyToken(jwt, []byte("jwtsecret"))
+	if err != nil || token.Payload.Sub != "7" {
+		t.Errorf("expected token for client 7, got %v (%v)", token, err)
+	}
+}
+
+func TestService_LoginWithCertificate_MfaEnabled(t *testing.T) {
+	cert := newTestCertificate(t)
+	service := newCertificateLoginService(t, map[string]*clients.Certificate{
+		"dns:payments.internal": {ID: 1, ClientId: 7},
+	})
+	service.ClientRepo.(*clients.ClientRepositoryMock).GetMfaFunc = func(ctx context.Context, clientId int) (*clients.Mfa, error) {
+		return &clients.Mfa{ClientId: clientId, Enabled: true}, nil
+	}
+
+	_, appErr := service.LoginWithCertificate(context.Background(), cert)
+	if appErr == nil || appErr.Code != 401 || appErr.ErrorCode != kmsErrors.CodeMfaRequired {
+		t.Fatalf("expected 401 %s, got %v", kmsErrors.CodeMfaRequired, appErr)
+	}
+}
+
+func TestService_LoginWithCertificate_NotBound(t *testing.T) {
+	service := newCertificateLoginService(t, map[string]*clients.Certificate{
+		// common name of another identity type
+		"dns:payments": {ID: 1, ClientId: 7},
+	})
+
+	_, appErr := service.LoginWithCertificate(context.Background(), newTestCertificate(t))
+	if appErr == nil || appErr.Code != 401 {
+		t.Fatalf("expected 401, got %v", appErr)
+	}
+}
+
+func TestService_LoginWithCertificate_PinMismatch(t *testing.T) {
+	service := newCertificateLoginService(t, map[string]*clients.Certificate{
+		"cn:payments": {ID: 1, ClientId: 7, Pin: CertificatePin(newTestCertificate(t))},
+	})
+
+	_, appErr := service.LoginWithCertificate(context.Background(), newTestCertificate(t))
+	if appErr == nil || appErr.Code != 401 {
+		t.Fatalf("expected 401, got %v", appErr)
+	}
+}
+
+func TestHandler_LoginWithCertificate(t *testing.T) {
+	cert := newTestCertificate(t)
+	mockService := NewAuthServiceMock()
+	mockService.LoginWithCertificateFunc = func(ctx context.Context, c *x509.Certificate) (string, *kmsErrors.AppError) {
+		if c != cert {
+			t.Errorf("expected leaf certificate")
+		}
+		return "jwt", nil
+	}
+	handler := NewHandler(mockService, mocks.NewLoggerMock())
+
+	// no client certificate
+	req := httptest.NewRequest("POST", "/auth/login/certificate", nil)
+	rr := httptest.NewRecorder()
+	if appErr := handler.LoginWithCertificate(rr, req); appErr == nil || appErr.Code != 401 {
+		t.Fatalf("expected 401, got %v", appErr)
+	}
+
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	if appErr := handler.LoginWithCertificate(rr, req); appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	if !strings.Contains(rr.Body.String(), `"token":"jwt"`) {
+		t.Errorf("expected token in body, got %s", rr.Body.String())
+	}
+}
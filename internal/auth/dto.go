@@ -8,6 +8,8 @@ import (
 type Credentials struct {
 	Clientname string `json:"clientname"`
 	Password   string `json:"password"`
+	// TOTP or recovery code, required if the client has enabled MFA
+	Otp string `json:"otp,omitempty"`
 }
 
 func (c *Credentials) Lift() *clients.Client {
@@ -29,6 +31,64 @@ func (c *SignupCredentials) Validate() error {
 	return nil
 }
 
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+func (r *ChangePasswordRequest) Validate() error {
+	if r.CurrentPassword == "" || r.NewPassword == "" {
+		return fmt.Errorf("currentPassword and newPassword should be non-empty")
+	}
+	return nil
+}
+
+// ID token of the trusted issuer, see OIDCVerifier
+type OIDCCredentials struct {
+	Token string `json:"token"`
+}
+
+func (c *OIDCCredentials) Validate() error {
+	if c.Token == "" {
+		return fmt.Errorf("token should be non-empty")
+	}
+	return nil
+}
+
+type MfaCodeRequest struct {
+	Code string `json:"code"`
+}
+
+func (r *MfaCodeRequest) Validate() error {
+	if r.Code == "" {
+		return fmt.Errorf("code should be non-empty")
+	}
+	return nil
+}
+
+// The secret is only shown once, Uri is meant for a QR code
+type EnrollMfaResponse struct {
+	Secret string `json:"secret"`
+	Uri    string `json:"uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// Reset token from an admin, see GenerateResetToken
+type ResetPasswordCredentials struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func (c *ResetPasswordCredentials) Validate() error {
+	if c.Token == "" || c.Password == "" {
+		return fmt.Errorf("token and password should be non-empty")
+	}
+	return nil
+}
+
 func (c *Credentials) Validate() error {
 	if c.Clientname != "" && c.Password != "" {
 		return nil
@@ -1,12 +1,17 @@
 package auth
 
 import (
+	"context"
+	"crypto/x509"
+	"errors"
 	"kms/internal/api/dto"
 	c "kms/internal/bootstrap/context"
 	kmsErrors "kms/pkg/errors"
 	pHttp "kms/pkg/http"
 	"kms/pkg/json"
+	"net"
 	"net/http"
+	"strconv"
 )
 
 type Handler struct {
@@ -22,8 +27,15 @@ func NewHandler(authService AuthService, logger c.Logger) *Handler {
 }
 
 type AuthService interface {
-	Signup(*SignupCredentials) (string, *kmsErrors.AppError)
-	Login(*Credentials) (string, *kmsErrors.AppError)
+	Signup(ctx context.Context, cred *SignupCredentials) (string, *kmsErrors.AppError)
+	Login(ctx context.Context, cred *Credentials, ip string) (string, *kmsErrors.AppError)
+	LoginWithCertificate(ctx context.Context, cert *x509.Certificate) (string, *kmsErrors.AppError)
+	LoginWithOIDC(ctx context.Context, idToken string) (string, *kmsErrors.AppError)
+	ChangePassword(ctx context.Context, clientId int, body *ChangePasswordRequest) *kmsErrors.AppError
+	ResetPassword(ctx context.Context, cred *ResetPasswordCredentials) (string, *kmsErrors.AppError)
+	EnrollMfa(ctx context.Context, clientId int) (*EnrollMfaResponse, *kmsErrors.AppError)
+	ActivateMfa(ctx context.Context, clientId int, code string) ([]string, *kmsErrors.AppError)
+	DisableMfa(ctx context.Context, clientId int, code string) *kmsErrors.AppError
 }
 
 func (h *Handler) Signup(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
@@ -36,7 +48,7 @@ func (h *Handler) Signup(w http.ResponseWriter, r *http.Request) *kmsErrors.AppE
 		return kmsErrors.NewMissingCredentialsError(err)
 	}
 
-	jwt, appErr := h.Service.Signup(&cred)
+	jwt, appErr := h.Service.Signup(r.Context(), &cred)
 	if appErr != nil {
 		return appErr
 	}
@@ -58,7 +70,7 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) *kmsErrors.AppEr
 		return kmsErrors.NewMissingCredentialsError(err)
 	}
 
-	jwt, appErr := h.Service.Login(&cred)
+	jwt, appErr := h.Service.Login(r.Context(), &cred, remoteIP(r))
 	if appErr != nil {
 		return appErr
 	}
@@ -69,3 +81,176 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) *kmsErrors.AppEr
 
 	return pHttp.WriteJSON(w, response)
 }
+
+// Only works over mTLS, the certificate has been verified during the handshake
+func (h *Handler) LoginWithCertificate(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return kmsErrors.NewAppError(errors.New("no verified client certificate"), "Client certificate required", 401)
+	}
+
+	jwt, appErr := h.Service.LoginWithCertificate(r.Context(), r.TLS.VerifiedChains[0][0])
+	if appErr != nil {
+		return appErr
+	}
+
+	response := &dto.TokenResponse{
+		Token: jwt,
+	}
+
+	return pHttp.WriteJSON(w, response)
+}
+
+func (h *Handler) LoginWithOIDC(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	var cred OIDCCredentials
+	if err := json.ParseBody(r.Body, &cred); err != nil {
+		return kmsErrors.NewInvalidBodyError(err)
+	}
+
+	if err := cred.Validate(); err != nil {
+		return kmsErrors.NewMissingCredentialsError(err)
+	}
+
+	jwt, appErr := h.Service.LoginWithOIDC(r.Context(), cred.Token)
+	if appErr != nil {
+		return appErr
+	}
+
+	response := &dto.TokenResponse{
+		Token: jwt,
+	}
+
+	return pHttp.WriteJSON(w, response)
+}
+
+// Needs a token from middleware.Authorize
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	clientId, appErr := tokenClientId(r)
+	if appErr != nil {
+		return appErr
+	}
+
+	var body ChangePasswordRequest
+	if err := json.ParseBody(r.Body, &body); err != nil {
+		return kmsErrors.NewInvalidBodyError(err)
+	}
+
+	if err := body.Validate(); err != nil {
+		return kmsErrors.NewInvalidBodyError(err)
+	}
+
+	if appErr := h.Service.ChangePassword(r.Context(), clientId, &body); appErr != nil {
+		return appErr
+	}
+
+	return pHttp.WriteStatus(w, http.StatusNoContent)
+}
+
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	var cred ResetPasswordCredentials
+	if err := json.ParseBody(r.Body, &cred); err != nil {
+		return kmsErrors.NewInvalidBodyError(err)
+	}
+
+	if err := cred.Validate(); err != nil {
+		return kmsErrors.NewMissingCredentialsError(err)
+	}
+
+	jwt, appErr := h.Service.ResetPassword(r.Context(), &cred)
+	if appErr != nil {
+		return appErr
+	}
+
+	response := &dto.TokenResponse{
+		Token: jwt,
+	}
+
+	return pHttp.WriteJSON(w, response)
+}
+
+// Needs a token from middleware.Authorize
+func (h *Handler) EnrollMfa(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	clientId, appErr := tokenClientId(r)
+	if appErr != nil {
+		return appErr
+	}
+
+	response, appErr := h.Service.EnrollMfa(r.Context(), clientId)
+	if appErr != nil {
+		return appErr
+	}
+
+	return pHttp.WriteJSON(w, response)
+}
+
+// Needs a token from middleware.Authorize
+func (h *Handler) ActivateMfa(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	clientId, appErr := tokenClientId(r)
+	if appErr != nil {
+		return appErr
+	}
+
+	var body MfaCodeRequest
+	if err := json.ParseBody(r.Body, &body); err != nil {
+		return kmsErrors.NewInvalidBodyError(err)
+	}
+
+	if err := body.Validate(); err != nil {
+		return kmsErrors.NewInvalidBodyError(err)
+	}
+
+	codes, appErr := h.Service.ActivateMfa(r.Context(), clientId, body.Code)
+	if appErr != nil {
+		return appErr
+	}
+
+	response := &RecoveryCodesResponse{
+		RecoveryCodes: codes,
+	}
+
+	return pHttp.WriteJSON(w, response)
+}
+
+// Needs a token from middleware.Authorize
+func (h *Handler) DisableMfa(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	clientId, appErr := tokenClientId(r)
+	if appErr != nil {
+		return appErr
+	}
+
+	var body MfaCodeRequest
+	if err := json.ParseBody(r.Body, &body); err != nil {
+		return kmsErrors.NewInvalidBodyError(err)
+	}
+
+	if err := body.Validate(); err != nil {
+		return kmsErrors.NewInvalidBodyError(err)
+	}
+
+	if appErr := h.Service.DisableMfa(r.Context(), clientId, body.Code); appErr != nil {
+		return appErr
+	}
+
+	return pHttp.WriteStatus(w, http.StatusNoContent)
+}
+
+func tokenClientId(r *http.Request) (int, *kmsErrors.AppError) {
+	token, ok := r.Context().Value(TokenCtxKey).(Token)
+	if !ok {
+		return 0, kmsErrors.NewInternalServerError(errors.New("no token in context"))
+	}
+
+	clientId, err := strconv.Atoi(token.Payload.Sub)
+	if err != nil {
+		return 0, kmsErrors.NewInternalServerError(err)
+	}
+	return clientId, nil
+}
+
+// The connection's address, X-Forwarded-For isn't trusted since the KMS terminates TLS itself
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
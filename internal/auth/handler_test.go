@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"kms/internal/test/mocks"
 	kmsErrors "kms/pkg/errors"
 	"net/http/httptest"
@@ -10,7 +11,7 @@ import (
 
 func TestHandler_Signup_Success(t *testing.T) {
 	mockService := NewAuthServiceMock()
-	mockService.SignupFunc = func(cred *SignupCredentials) (string, *kmsErrors.AppError) {
+	mockService.SignupFunc = func(ctx context.Context, cred *SignupCredentials) (string, *kmsErrors.AppError) {
 		return "jwt", nil
 	}
 	mockLogger := mocks.NewLoggerMock()
@@ -70,7 +71,7 @@ func TestHandler_Signup_InvalidBodyError(t *testing.T) {
 
 func TestHandler_Signup_ServiceError(t *testing.T) {
 	mockService := NewAuthServiceMock()
-	mockService.SignupFunc = func(cred *SignupCredentials) (string, *kmsErrors.AppError) {
+	mockService.SignupFunc = func(ctx context.Context, cred *SignupCredentials) (string, *kmsErrors.AppError) {
 		return "", kmsErrors.NewAppError(nil, "service error", 500)
 	}
 	mockLogger := mocks.NewLoggerMock()
@@ -93,7 +94,7 @@ func TestHandler_Signup_ServiceError(t *testing.T) {
 
 func TestHandler_Login_Success(t *testing.T) {
 	mockService := NewAuthServiceMock()
-	mockService.LoginFunc = func(cred *Credentials) (string, *kmsErrors.AppError) {
+	mockService.LoginFunc = func(ctx context.Context, cred *Credentials, ip string) (string, *kmsErrors.AppError) {
 		return "jwt", nil
 	}
 	mockLogger := mocks.NewLoggerMock()
@@ -153,7 +154,7 @@ func TestHandler_Login_InvalidBodyError(t *testing.T) {
 
 func TestHandler_Login_ServiceError(t *testing.T) {
 	mockService := NewAuthServiceMock()
-	mockService.LoginFunc = func(cred *Credentials) (string, *kmsErrors.AppError) {
+	mockService.LoginFunc = func(ctx context.Context, cred *Credentials, ip string) (string, *kmsErrors.AppError) {
 		return "", kmsErrors.NewAppError(nil, "service error", 500)
 	}
 	mockLogger := mocks.NewLoggerMock()
@@ -173,3 +174,28 @@ func TestHandler_Login_ServiceError(t *testing.T) {
 		t.Errorf("handler returned wrong status code: got %v want %v", err.Code, 500)
 	}
 }
+
+func TestHandler_LoginWithOIDC(t *testing.T) {
+	mockService := NewAuthServiceMock()
+	mockService.LoginWithOIDCFunc = func(ctx context.Context, idToken string) (string, *kmsErrors.AppError) {
+		if idToken != "id-token" {
+			t.Errorf("unexpected ID token %q", idToken)
+		}
+		return "jwt", nil
+	}
+	handler := NewHandler(mockService, mocks.NewLoggerMock())
+
+	rr := httptest.NewRecorder()
+	err := handler.LoginWithOIDC(rr, httptest.NewRequest("POST", "/auth/login/oidc", strings.NewReader(`{"token": "id-token"}`)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(rr.Body.String(), `"token":"jwt"`) {
+		t.Errorf("expected \"token\":\"jwt\", got: %v", rr.Body.String())
+	}
+
+	err = handler.LoginWithOIDC(httptest.NewRecorder(), httptest.NewRequest("POST", "/auth/login/oidc", strings.NewReader(`{}`)))
+	if err == nil || err.Code != 400 {
+		t.Errorf("expected 400, got %v", err)
+	}
+}
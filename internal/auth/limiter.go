@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	// Failures from a single IP before it's locked out, as a multiple of the per clientname limit (NAT, shared hosts)
+	ipFailureFactor = 4
+	// Backoff after the second failure (one free retry for typos), doubled with every further one
+	loginBaseDelay = time.Second
+	// Hard cap per map, once reached stale entries are pruned and, if that's not enough, the least recently failed one is evicted
+	maxLimiterEntries = 10000
+)
+
+type LoginLimits struct {
+	MaxFailures int
+	Lockout     time.Duration
+}
+
+type loginAttempts struct {
+	key          string
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+// Counters by key, ordered from least to most recently failed
+type attemptTable struct {
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+func newAttemptTable() *attemptTable {
+	return &attemptTable{entries: make(map[string]*list.Element), order: list.New()}
+}
+
+func (t *attemptTable) remove(key string) {
+	if e, ok := t.entries[key]; ok {
+		t.order.Remove(e)
+		delete(t.entries, key)
+	}
+}
+
+// In-memory failed login counters per hashed clientname and per IP.
+// Clientnames are backed off exponentially and locked out after MaxFailures, IPs are only locked out
+// (after ipFailureFactor*MaxFailures) so clients sharing an address don't slow each other down.
+// Counters are forgotten after a quiet period of Lockout.
+type LoginLimiter struct {
+	limits LoginLimits
+	now    func() time.Time
+
+	mu    sync.Mutex
+	names *attemptTable
+	ips   *attemptTable
+}
+
+func NewLoginLimiter(limits LoginLimits) *LoginLimiter {
+	return &LoginLimiter{
+		limits: limits,
+		now:    time.Now,
+		names:  newAttemptTable(),
+		ips:    newAttemptTable(),
+	}
+}
+
+// How long until the next attempt is allowed, 0 if it is
+func (l *LoginLimiter) Blocked(hashedClientname, ip string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	var wait time.Duration
+	for _, a := range []*loginAttempts{l.get(l.names, hashedClientname, now), l.get(l.ips, ip, now)} {
+		if a != nil && now.Before(a.blockedUntil) {
+			wait = max(wait, a.blockedUntil.Sub(now))
+		}
+	}
+	return wait
+}
+
+// Records a failed attempt, returns whether the clientname and/or IP got locked out by it
+func (l *LoginLimiter) Failed(hashedClientname, ip string) (nameLocked, ipLocked bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	nameLocked = l.fail(l.names, hashedClientname, l.limits.MaxFailures, true, now)
+	if ip != "" {
+		ipLocked = l.fail(l.ips, ip, ipFailureFactor*l.limits.MaxFailures, false, now)
+	}
+	return nameLocked, ipLocked
+}
+
+// Resets the clientname's counter after a successful login, the IP's is kept so a valid login can't reset it
+func (l *LoginLimiter) Succeeded(hashedClientname string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.names.remove(hashedClientname)
+}
+
+// Returns whether the clientname was blocked
+func (l *LoginLimiter) Unlock(hashedClientname string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	a := l.get(l.names, hashedClientname, l.now())
+	l.names.remove(hashedClientname)
+	return a != nil && l.now().Before(a.blockedUntil)
+}
+
+func (l *LoginLimiter) fail(t *attemptTable, key string, maxFailures int, backoff bool, now time.Time) bool {
+	a := l.get(t, key, now)
+	if a == nil {
+		if len(t.entries) >= maxLimiterEntries {
+			l.prune(t, now)
+		}
+		if len(t.entries) >= maxLimiterEntries {
+			t.remove(t.order.Front().Value.(*loginAttempts).key)
+		}
+		a = &loginAttempts{key: key}
+		t.entries[key] = t.order.PushBack(a)
+	} else {
+		t.order.MoveToBack(t.entries[key])
+	}
+
+	a.failures++
+	a.lastFailure = now
+	if a.failures >= maxFailures {
+		a.blockedUntil = now.Add(l.limits.Lockout)
+		return true
+	}
+	if backoff && a.failures > 1 {
+		delay := loginBaseDelay << (a.failures - 2)
+		a.blockedUntil = now.Add(min(delay, l.limits.Lockout))
+	}
+	return false
+}
+
+// nil if there is no entry, or it's stale
+func (l *LoginLimiter) get(t *attemptTable, key string, now time.Time) *loginAttempts {
+	e, ok := t.entries[key]
+	if !ok {
+		return nil
+	}
+	a := e.Value.(*loginAttempts)
+	if l.stale(a, now) {
+		t.remove(key)
+		return nil
+	}
+	return a
+}
+
+func (l *LoginLimiter) stale(a *loginAttempts, now time.Time) bool {
+	return now.After(a.lastFailure.Add(l.limits.Lockout)) && now.After(a.blockedUntil.Add(l.limits.Lockout))
+}
+
+// Removes stale entries from the least recently failed end, stops at the first one that isn't
+func (l *LoginLimiter) prune(t *attemptTable, now time.Time) {
+	for e := t.order.Front(); e != nil; e = t.order.Front() {
+		a := e.Value.(*loginAttempts)
+		if !l.stale(a, now) {
+			return
+		}
+		t.remove(a.key)
+	}
+}
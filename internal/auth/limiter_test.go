@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(maxFailures int) (*LoginLimiter, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewLoginLimiter(LoginLimits{MaxFailures: maxFailures, Lockout: 10 * time.Minute})
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestLoginLimiter_Backoff(t *testing.T) {
+	l, now := newTestLimiter(5)
+
+	if wait := l.Blocked("name", "ip"); wait != 0 {
+		t.Fatalf("expected no wait, got %v", wait)
+	}
+
+	for i, want := range []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second} {
+		l.Failed("name", "ip")
+		if wait := l.Blocked("name", "other-ip"); wait != want {
+			t.Errorf("failure %d: expected %v, got %v", i+1, want, wait)
+		}
+		*now = now.Add(want)
+	}
+
+	if wait := l.Blocked("name", "ip"); wait != 0 {
+		t.Errorf("expected no wait after backoff, got %v", wait)
+	}
+}
+
+func TestLoginLimiter_Lockout(t *testing.T) {
+	l, now := newTestLimiter(3)
+
+	for i := 0; i < 2; i++ {
+		if nameLocked, _ := l.Failed("name", "ip"); nameLocked {
+			t.Fatalf("unexpected lockout after %d failures", i+1)
+		}
+	}
+	if nameLocked, _ := l.Failed("name", "ip"); !nameLocked {
+		t.Fatal("expected lockout")
+	}
+	if wait := l.Blocked("name", ""); wait != 10*time.Minute {
+		t.Errorf("expected 10m, got %v", wait)
+	}
+
+	// expires, and the counter is forgotten after another quiet period
+	*now = now.Add(10 * time.Minute)
+	if wait := l.Blocked("name", ""); wait != 0 {
+		t.Errorf("expected no wait, got %v", wait)
+	}
+	*now = now.Add(10*time.Minute + time.Second)
+	if nameLocked, _ := l.Failed("name", "ip"); nameLocked {
+		t.Error("expected counter to be reset")
+	}
+}
+
+func TestLoginLimiter_SucceededAndUnlock(t *testing.T) {
+	l, now := newTestLimiter(3)
+
+	l.Failed("name", "ip")
+	l.Failed("name", "ip")
+	l.Succeeded("name")
+	if wait := l.Blocked("name", ""); wait != 0 {
+		t.Errorf("expected no wait after success, got %v", wait)
+	}
+
+	for i := 0; i < 3; i++ {
+		l.Failed("name", "ip")
+	}
+	if !l.Unlock("name") {
+		t.Error("expected name to have been locked")
+	}
+	if l.Unlock("name") {
+		t.Error("expected name to be unlocked")
+	}
+	// the IP counter is kept, but never backed off
+	if wait := l.Blocked("name", "ip"); wait != 0 {
+		t.Errorf("expected no wait, got %v", wait)
+	}
+	if a := l.get(l.ips, "ip", *now); a == nil || a.failures != 5 {
+		t.Errorf("expected 5 IP failures, got %+v", a)
+	}
+}
+
+func TestLoginLimiter_IP(t *testing.T) {
+	l, now := newTestLimiter(2)
+
+	// spread over many clientnames, so only the IP counter locks
+	for i := 0; i < 2*ipFailureFactor-1; i++ {
+		if _, ipLocked := l.Failed(string(rune('a'+i)), "ip"); ipLocked {
+			t.Fatalf("unexpected IP lockout after %d failures", i+1)
+		}
+		*now = now.Add(time.Hour)
+	}
+	if _, ipLocked := l.Failed("z", "ip"); ipLocked {
+		t.Fatal("expected counters to be forgotten after a quiet period")
+	}
+
+	for i := 0; i < 2*ipFailureFactor-1; i++ {
+		l.Failed(string(rune('a'+i)), "ip")
+	}
+	if _, ipLocked := l.Failed("z", "ip"); !ipLocked {
+		t.Fatal("expected IP lockout")
+	}
+	if wait := l.Blocked("fresh", "ip"); wait != 10*time.Minute {
+		t.Errorf("expected 10m, got %v", wait)
+	}
+}
+
+func TestLoginLimiter_Cap(t *testing.T) {
+	l, now := newTestLimiter(3)
+
+	for i := 0; i < 3; i++ {
+		l.Failed("victim", "")
+	}
+	*now = now.Add(time.Minute)
+
+	// sprayed clientnames within the lockout window, none of them is stale
+	for i := 0; i < maxLimiterEntries+10; i++ {
+		l.Failed(fmt.Sprint("spray-", i), "")
+	}
+	if n := len(l.names.entries); n != maxLimiterEntries || l.names.order.Len() != n {
+		t.Fatalf("expected %d entries, got %d", maxLimiterEntries, n)
+	}
+	// the least recently failed entries are evicted first
+	if l.get(l.names, "victim", *now) != nil || l.get(l.names, "spray-9", *now) != nil {
+		t.Error("expected the oldest entries to be evicted")
+	}
+	if l.get(l.names, "spray-10", *now) == nil || l.get(l.names, fmt.Sprint("spray-", maxLimiterEntries+9), *now) == nil {
+		t.Error("expected the newest entries to be kept")
+	}
+
+	// once they are stale, they are pruned instead
+	*now = now.Add(time.Hour)
+	l.Failed("fresh", "")
+	if n := len(l.names.entries); n != 1 {
+		t.Errorf("expected stale entries to be pruned, got %d", n)
+	}
+}
@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	c "kms/internal/bootstrap/context"
+	"kms/internal/clients"
+	kmsErrors "kms/pkg/errors"
+	"kms/pkg/hashing"
+	"kms/pkg/totp"
+	"kms/pkg/tracing"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// Issuer shown in authenticator apps
+	MfaIssuer          = "KMS"
+	RecoveryCodeCount  = 10
+	recoveryCodeLength = 10
+	// How long after a login with MFA sensitive admin routes (e.g. role changes) can be used, see RequireFreshMfa
+	MfaFreshFor = 10 * time.Minute
+)
+
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// ADMIN_MFA_REQUIRED, admins without MFA can still log in, but only to enroll
+func AdminMfaRequired(cfg *c.Config) bool {
+	return cfg != nil && cfg.AdminMfaRequired
+}
+
+// Starts (or restarts) an enrolment, MFA is only enabled once a code is confirmed with ActivateMfa
+func (s *Service) EnrollMfa(ctx context.Context, clientId int) (*EnrollMfaResponse, *kmsErrors.AppError) {
+	ctx, span := tracing.Start(ctx, "AuthService.EnrollMfa", "kms.client_id", clientId)
+	defer span.End()
+
+	client, err := s.ClientRepo.GetClient(ctx, clientId)
+	if err != nil {
+		return nil, kmsErrors.MapRepoErr(err)
+	}
+	if client.Role != "admin" {
+		return nil, kmsErrors.NewAppError(fmt.Errorf("role %q can't enroll MFA", client.Role), "MFA is only available for admins", 403)
+	}
+
+	mfa, appErr := s.getMfa(ctx, clientId)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if mfa != nil && mfa.Enabled {
+		return nil, kmsErrors.NewAppError(errors.New("MFA already enabled"), "MFA is already enabled", 409)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, kmsErrors.NewInternalServerError(err)
+	}
+
+	if err := s.ClientRepo.SaveMfa(ctx, &clients.Mfa{
+		ClientId: clientId,
+		Secret:   totp.EncodeSecret(secret),
+	}); err != nil {
+		return nil, kmsErrors.MapRepoErr(err)
+	}
+
+	s.Logger.Info("Client started MFA enrolment", "clientId", clientId)
+
+	return &EnrollMfaResponse{
+		Secret: totp.EncodeSecret(secret),
+		Uri:    totp.URI(MfaIssuer, client.Clientname, secret),
+	}, nil
+}
+
+// Returns the recovery codes, they're only stored hashed
+func (s *Service) ActivateMfa(ctx context.Context, clientId int, code string) ([]string, *kmsErrors.AppError) {
+	ctx, span := tracing.Start(ctx, "AuthService.ActivateMfa", "kms.client_id", clientId)
+	defer span.End()
+
+	mfa, appErr := s.getMfa(ctx, clientId)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if mfa == nil {
+		return nil, kmsErrors.NewAppError(errors.New("no MFA enrolment"), "MFA enrolment not started", 400)
+	}
+	if mfa.Enabled {
+		return nil, kmsErrors.NewAppError(errors.New("MFA already enabled"), "MFA is already enabled", 409)
+	}
+
+	secret, err := totp.DecodeSecret(mfa.Secret)
+	if err != nil {
+		return nil, kmsErrors.NewInternalServerError(err)
+	}
+	step, ok := totp.Verify(secret, code, time.Now(), 0)
+	if !ok {
+		return nil, kmsErrors.NewAppError(errors.New("TOTP code mismatch"), "Invalid MFA code", 400).WithErrorCode(kmsErrors.CodeMfaInvalid)
+	}
+
+	codes := make([]string, RecoveryCodeCount)
+	hashed := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		codes[i], err = generateRecoveryCode()
+		if err != nil {
+			return nil, kmsErrors.NewInternalServerError(err)
+		}
+		hashed[i], err = s.hashRecoveryCode(codes[i])
+		if err != nil {
+			return nil, kmsErrors.NewInternalServerError(err)
+		}
+	}
+
+	mfa.Enabled = true
+	mfa.LastStep = step
+	mfa.RecoveryCodes = hashed
+	if err := s.ClientRepo.SaveMfa(ctx, mfa); err != nil {
+		return nil, kmsErrors.MapRepoErr(err)
+	}
+
+	s.Logger.Notice("Client enabled MFA", "clientId", clientId)
+
+	return codes, nil
+}
+
+// Requires a current code, so a stolen JWT can't turn MFA off
+func (s *Service) DisableMfa(ctx context.Context, clientId int, code string) *kmsErrors.AppError {
+	ctx, span := tracing.Start(ctx, "AuthService.DisableMfa", "kms.client_id", clientId)
+	defer span.End()
+
+	if AdminMfaRequired(s.Cfg) {
+		return kmsErrors.NewAppError(errors.New("ADMIN_MFA_REQUIRED is set"), "MFA is required for admins", 403)
+	}
+
+	mfa, appErr := s.getMfa(ctx, clientId)
+	if appErr != nil {
+		return appErr
+	}
+	if mfa == nil || !mfa.Enabled {
+		return kmsErrors.NewAppError(errors.New("MFA not enabled"), "MFA is not enabled", 404)
+	}
+
+	if appErr := s.verifyMfa(ctx, mfa, code); appErr != nil {
+		return appErr
+	}
+
+	if err := s.ClientRepo.DeleteMfa(ctx, clientId); err != nil {
+		return kmsErrors.MapRepoErr(err)
+	}
+
+	s.Logger.Notice("Client disabled MFA", "clientId", clientId)
+
+	return nil
+}
+
+// False if the client hasn't enrolled or hasn't activated MFA yet
+func MfaEnabled(ctx context.Context, clientRepo clients.ClientRepository, clientId int) (bool, error) {
+	mfa, err := clientRepo.GetMfa(ctx, clientId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return mfa.Enabled, nil
+}
+
+// Certificate, OIDC and API key logins have no second factor, so clients with MFA enabled can't use them.
+// Otherwise they'd bypass MFA and, for admins, get a token for the admin routes.
+func RefuseMfaClient(ctx context.Context, clientRepo clients.ClientRepository, clientId int, method string) *kmsErrors.AppError {
+	enabled, err := MfaEnabled(ctx, clientRepo, clientId)
+	if err != nil {
+		return kmsErrors.MapRepoErr(err)
+	}
+	if enabled {
+		return kmsErrors.NewAppError(
+			fmt.Errorf("%s login for client with MFA enabled (clientId: %d)", method, clientId),
+			"MFA is enabled, log in with password and MFA code",
+			401,
+		).WithErrorCode(kmsErrors.CodeMfaRequired)
+	}
+	return nil
+}
+
+// nil if the client hasn't enrolled
+func (s *Service) getMfa(ctx context.Context, clientId int) (*clients.Mfa, *kmsErrors.AppError) {
+	mfa, err := s.ClientRepo.GetMfa(ctx, clientId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, kmsErrors.MapRepoErr(err)
+	}
+	return mfa, nil
+}
+
+// Accepts a TOTP code or an unused recovery code, either can only be used once
+func (s *Service) verifyMfa(ctx context.Context, mfa *clients.Mfa, code string) *kmsErrors.AppError {
+	invalid := kmsErrors.NewAppError(fmt.Errorf("invalid MFA code (clientId: %d)", mfa.ClientId), "Invalid MFA code", 401).WithErrorCode(kmsErrors.CodeMfaInvalid)
+
+	secret, err := totp.DecodeSecret(mfa.Secret)
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	lastStep := mfa.LastStep
+	recoveryCodes := mfa.RecoveryCodes
+	usedRecoveryCode := false
+	if step, ok := totp.Verify(secret, code, time.Now(), mfa.LastStep); ok {
+		lastStep = step
+	} else {
+		hashed, err := s.hashRecoveryCode(code)
+		if err != nil {
+			return kmsErrors.NewInternalServerError(err)
+		}
+		idx := slices.Index(mfa.RecoveryCodes, hashed)
+		if idx < 0 {
+			return invalid
+		}
+		recoveryCodes = slices.Delete(slices.Clone(mfa.RecoveryCodes), idx, idx+1)
+		usedRecoveryCode = true
+	}
+
+	if err := s.ClientRepo.UseMfa(ctx, mfa, lastStep, recoveryCodes); err != nil {
+		if errors.Is(err, kmsErrors.ErrNoRowsAffected) {
+			// used by a concurrent request
+			return invalid
+		}
+		return kmsErrors.MapRepoErr(err)
+	}
+
+	if usedRecoveryCode {
+		s.Logger.Notice("Client used MFA recovery code", "clientId", mfa.ClientId, "remaining", len(recoveryCodes))
+	}
+
+	return nil
+}
+
+// xxxxx-xxxxx, lowercase base32
+func generateRecoveryCode() (string, error) {
+	raw := make([]byte, recoveryCodeLength*5/8)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	code := recoveryCodeEncoding.EncodeToString(raw)
+	return code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:], nil
+}
+
+// Case and dashes are ignored
+func (s *Service) hashRecoveryCode(code string) (string, error) {
+	hashKey, err := s.KeyManager.HashKey("clientname")
+	if err != nil {
+		return "", err
+	}
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return hashing.HashHS256ToB64([]byte(normalized), hashKey), nil
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	c "kms/internal/bootstrap/context"
+	"kms/internal/clients"
+	"kms/internal/test/mocks"
+	kmsErrors "kms/pkg/errors"
+	"kms/pkg/hashing"
+	"kms/pkg/totp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Service with an admin (id 1, password "Valid123!1234") and an in-memory MFA table
+func newMfaService(t *testing.T) (*Service, *clients.Client) {
+	hashedPassword, err := hashing.HashPassword("Valid123!1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := &clients.Client{ID: 1, Clientname: "admin@kms.local", Password: hashedPassword, Role: "admin"}
+
+	var stored *clients.Mfa
+	mockRepo := clients.NewClientRepositoryMock()
+	mockRepo.GetClientFunc = func(ctx context.Context, id int) (*clients.Client, error) {
+		return admin, nil
+	}
+	mockRepo.FindByHashedClientnameFunc = func(ctx context.Context, hashedClientname string) (*clients.Client, error) {
+		return admin, nil
+	}
+	mockRepo.SaveMfaFunc = func(ctx context.Context, mfa *clients.Mfa) error {
+		saved := *mfa
+		stored = &saved
+		return nil
+	}
+	mockRepo.GetMfaFunc = func(ctx context.Context, clientId int) (*clients.Mfa, error) {
+		if stored == nil {
+			return nil, sql.ErrNoRows
+		}
+		mfa := *stored
+		return &mfa, nil
+	}
+	mockRepo.UseMfaFunc = func(ctx context.Context, prev *clients.Mfa, lastStep int64, recoveryCodes []string) error {
+		if stored.LastStep != prev.LastStep || !slices.Equal(stored.RecoveryCodes, prev.RecoveryCodes) {
+			return kmsErrors.ErrNoRowsAffected
+		}
+		stored.LastStep = lastStep
+		stored.RecoveryCodes = recoveryCodes
+		return nil
+	}
+	mockRepo.DeleteMfaFunc = func(ctx context.Context, clientId int) error {
+		stored = nil
+		return nil
+	}
+	mockKeyManager := mocks.NewKeyManagerMock()
+	mockKeyManager.HashKeyFunc = func(key string) ([]byte, error) {
+		return []byte("clientnamesecret"), nil
+	}
+	tokenGenInfo := &TokenGenInfo{
+		Ttl:    3600,
+		Secret: []byte("jwtsecret"),
+		Typ:    "jwt",
+	}
+	limiter := NewLoginLimiter(LoginLimits{MaxFailures: 5, Lockout: time.Minute})
+	return NewService(nil, mockRepo, tokenGenInfo, mockKeyManager, limiter, nil, mocks.NewLoggerMock()), admin
+}
+
+// Returns the secret, the recovery codes and the time step of the activation code
+func enrollMfa(t *testing.T, service *Service) ([]byte, []string, int64) {
+	enrolment, appErr := service.EnrollMfa(context.Background(), 1)
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	secret, err := totp.DecodeSecret(enrolment.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	step := totp.Step(time.Now())
+	codes, appErr := service.ActivateMfa(context.Background(), 1, totp.Code(secret, step))
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("expected %d recovery codes, got %d", RecoveryCodeCount, len(codes))
+	}
+	return secret, codes, step
+}
+
+func TestService_Mfa_Login(t *testing.T) {
+	service, _ := newMfaService(t)
+	secret, codes, step := enrollMfa(t, service)
+
+	cred := &Credentials{Clientname: "admin@kms.local", Password: "Valid123!1234"}
+	_, appErr := service.Login(context.Background(), cred, "192.0.2.1")
+	if appErr == nil || appErr.Code != 401 || appErr.Message != "MFA code required" {
+		t.Fatalf("expected MFA code to be required, got %v", appErr)
+	}
+
+	// the activation code was already used
+	cred.Otp = totp.Code(secret, step)
+	if _, appErr := service.Login(context.Background(), cred, "192.0.2.1"); appErr == nil || appErr.Code != 401 {
+		t.Fatalf("expected used code to be rejected, got %v", appErr)
+	}
+
+	cred.Otp = totp.Code(secret, totp.Step(time.Now())+1)
+	jwt, appErr := service.Login(context.Background(), cred, "192.0.2.1")
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	token, err := VerifyToken(jwt, []byte("jwtsecret"))
+	if err != nil || !token.Payload.MfaFresh(MfaFreshFor) {
+		t.Errorf("expected fresh MFA token, got %+v (%v)", token.Payload, err)
+	}
+
+	// recovery codes work once, ignoring case and dashes
+	cred.Otp = strings.ToUpper(codes[0][:5] + codes[0][6:])
+	if _, appErr := service.Login(context.Background(), cred, "192.0.2.1"); appErr != nil {
+		t.Fatalf("expected recovery code to be accepted, got %v", appErr)
+	}
+	if _, appErr := service.Login(context.Background(), cred, "192.0.2.1"); appErr == nil || appErr.Code != 401 {
+		t.Fatalf("expected used recovery code to be rejected, got %v", appErr)
+	}
+}
+
+func TestService_Mfa_Enrolment(t *testing.T) {
+	service, admin := newMfaService(t)
+
+	if _, appErr := service.ActivateMfa(context.Background(), 1, "123456"); appErr == nil || appErr.Code != 400 {
+		t.Errorf("expected 400 without enrolment, got %v", appErr)
+	}
+
+	enrolment, appErr := service.EnrollMfa(context.Background(), 1)
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	if _, appErr := service.ActivateMfa(context.Background(), 1, "000000"); appErr == nil || appErr.Code != 400 {
+		t.Errorf("expected 400 for wrong code, got %v", appErr)
+	}
+	if enrolment.Uri == "" {
+		t.Error("expected otpauth URI")
+	}
+
+	secret, _, _ := enrollMfa(t, service)
+	if _, appErr := service.EnrollMfa(context.Background(), 1); appErr == nil || appErr.Code != 409 {
+		t.Errorf("expected 409 when already enabled, got %v", appErr)
+	}
+
+	// policy
+	service.Cfg = &c.Config{AdminMfaRequired: true}
+	if appErr := service.DisableMfa(context.Background(), 1, totp.Code(secret, totp.Step(time.Now())+1)); appErr == nil || appErr.Code != 403 {
+		t.Errorf("expected 403 with ADMIN_MFA_REQUIRED, got %v", appErr)
+	}
+	service.Cfg = nil
+
+	if appErr := service.DisableMfa(context.Background(), 1, "000000"); appErr == nil || appErr.Code != 401 {
+		t.Errorf("expected 401 for wrong code, got %v", appErr)
+	}
+	if appErr := service.DisableMfa(context.Background(), 1, totp.Code(secret, totp.Step(time.Now())+1)); appErr != nil {
+		t.Errorf("expected no error, got %v", appErr)
+	}
+
+	admin.Role = "client"
+	if _, appErr := service.EnrollMfa(context.Background(), 1); appErr == nil || appErr.Code != 403 {
+		t.Errorf("expected 403 for non-admins, got %v", appErr)
+	}
+}
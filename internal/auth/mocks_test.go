@@ -1,29 +1,88 @@
 package auth
 
 import (
+	"context"
+	"crypto/x509"
 	"errors"
 	kmsErrors "kms/pkg/errors"
 )
 
 type AuthServiceMock struct {
-	LoginFunc  func(credentials *Credentials) (string, *kmsErrors.AppError)
-	SignupFunc func(credentials *SignupCredentials) (string, *kmsErrors.AppError)
+	LoginFunc  func(ctx context.Context, credentials *Credentials, ip string) (string, *kmsErrors.AppError)
+	SignupFunc func(ctx context.Context, credentials *SignupCredentials) (string, *kmsErrors.AppError)
+
+	LoginWithCertificateFunc func(ctx context.Context, cert *x509.Certificate) (string, *kmsErrors.AppError)
+	LoginWithOIDCFunc        func(ctx context.Context, idToken string) (string, *kmsErrors.AppError)
+	ChangePasswordFunc       func(ctx context.Context, clientId int, body *ChangePasswordRequest) *kmsErrors.AppError
+	ResetPasswordFunc        func(ctx context.Context, cred *ResetPasswordCredentials) (string, *kmsErrors.AppError)
+	EnrollMfaFunc            func(ctx context.Context, clientId int) (*EnrollMfaResponse, *kmsErrors.AppError)
+	ActivateMfaFunc          func(ctx context.Context, clientId int, code string) ([]string, *kmsErrors.AppError)
+	DisableMfaFunc           func(ctx context.Context, clientId int, code string) *kmsErrors.AppError
 }
 
 func NewAuthServiceMock() *AuthServiceMock {
 	return &AuthServiceMock{}
 }
 
-func (m *AuthServiceMock) Login(credentials *Credentials) (string, *kmsErrors.AppError) {
+func (m *AuthServiceMock) Login(ctx context.Context, credentials *Credentials, ip string) (string, *kmsErrors.AppError) {
 	if m.LoginFunc != nil {
-		return m.LoginFunc(credentials)
+		return m.LoginFunc(ctx, credentials, ip)
 	}
 	return "", kmsErrors.LiftToAppError(errors.New("LoginFunc not implemented in mock"))
 }
 
-func (m *AuthServiceMock) Signup(credentials *SignupCredentials) (string, *kmsErrors.AppError) {
+func (m *AuthServiceMock) Signup(ctx context.Context, credentials *SignupCredentials) (string, *kmsErrors.AppError) {
 	if m.SignupFunc != nil {
-		return m.SignupFunc(credentials)
+		return m.SignupFunc(ctx, credentials)
 	}
 	return "", kmsErrors.LiftToAppError(errors.New("SignupFunc not implemented in mock"))
 }
+
+func (m *AuthServiceMock) LoginWithCertificate(ctx context.Context, cert *x509.Certificate) (string, *kmsErrors.AppError) {
+	if m.LoginWithCertificateFunc != nil {
+		return m.LoginWithCertificateFunc(ctx, cert)
+	}
+	return "", kmsErrors.LiftToAppError(errors.New("LoginWithCertificateFunc not implemented in mock"))
+}
+
+func (m *AuthServiceMock) LoginWithOIDC(ctx context.Context, idToken string) (string, *kmsErrors.AppError) {
+	if m.LoginWithOIDCFunc != nil {
+		return m.LoginWithOIDCFunc(ctx, idToken)
+	}
+	return "", kmsErrors.LiftToAppError(errors.New("LoginWithOIDCFunc not implemented in mock"))
+}
+
+func (m *AuthServiceMock) ChangePassword(ctx context.Context, clientId int, body *ChangePasswordRequest) *kmsErrors.AppError {
+	if m.ChangePasswordFunc != nil {
+		return m.ChangePasswordFunc(ctx, clientId, body)
+	}
+	return kmsErrors.LiftToAppError(errors.New("ChangePasswordFunc not implemented in mock"))
+}
+
+func (m *AuthServiceMock) ResetPassword(ctx context.Context, cred *ResetPasswordCredentials) (string, *kmsErrors.AppError) {
+	if m.ResetPasswordFunc != nil {
+		return m.ResetPasswordFunc(ctx, cred)
+	}
+	return "", kmsErrors.LiftToAppError(errors.New("ResetPasswordFunc not implemented in mock"))
+}
+
+func (m *AuthServiceMock) EnrollMfa(ctx context.Context, clientId int) (*EnrollMfaResponse, *kmsErrors.AppError) {
+	if m.EnrollMfaFunc != nil {
+		return m.EnrollMfaFunc(ctx, clientId)
+	}
+	return nil, kmsErrors.LiftToAppError(errors.New("EnrollMfaFunc not implemented in mock"))
+}
+
+func (m *AuthServiceMock) ActivateMfa(ctx context.Context, clientId int, code string) ([]string, *kmsErrors.AppError) {
+	if m.ActivateMfaFunc != nil {
+		return m.ActivateMfaFunc(ctx, clientId, code)
+	}
+	return nil, kmsErrors.LiftToAppError(errors.New("ActivateMfaFunc not implemented in mock"))
+}
+
+func (m *AuthServiceMock) DisableMfa(ctx context.Context, clientId int, code string) *kmsErrors.AppError {
+	if m.DisableMfaFunc != nil {
+		return m.DisableMfaFunc(ctx, clientId, code)
+	}
+	return kmsErrors.LiftToAppError(errors.New("DisableMfaFunc not implemented in mock"))
+}
@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	b64 "encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	c "kms/internal/bootstrap/context"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Tolerated difference between the issuer's clock and ours
+const oidcClockSkew = time.Minute
+
+var ErrInvalidIDToken = errors.New("invalid ID token")
+
+type oidcHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// Verifies ID tokens (RS256 or ES256) of a single trusted issuer against its locally configured JWKS,
+// e.g. Kubernetes projected service account tokens. The JWKS isn't fetched, so the KMS doesn't depend on the issuer being reachable.
+type OIDCVerifier struct {
+	Issuer   string
+	Audience string
+	// Claim whose value is the clientname of the client the token logs in as
+	ClientClaim string
+	// Clientnames allowed to log in, a token naming any other client is refused even if it's valid
+	clients map[string]bool
+	// Tokens whose exp - iat exceeds it are rejected, e.g. long-lived tokens that would outlive a revoked workload
+	MaxLifetime time.Duration
+
+	keys map[string]crypto.PublicKey
+	now  func() time.Time
+}
+
+func NewOIDCVerifier(issuer, audience, clientClaim string, clients []string, maxLifetime time.Duration, jwks []byte) (*OIDCVerifier, error) {
+	if issuer == "" || audience == "" {
+		return nil, errors.New("OIDC issuer and audience are required")
+	}
+	if len(clients) == 0 {
+		return nil, errors.New("at least one OIDC client is required")
+	}
+	if maxLifetime <= 0 {
+		return nil, errors.New("OIDC maximum token lifetime must be positive")
+	}
+	if clientClaim == "" {
+		clientClaim = "sub"
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(jwks, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("JWKS key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys in JWKS")
+	}
+
+	allowed := make(map[string]bool, len(clients))
+	for _, client := range clients {
+		allowed[client] = true
+	}
+
+	return &OIDCVerifier{
+		Issuer:      issuer,
+		Audience:    audience,
+		ClientClaim: clientClaim,
+		clients:     allowed,
+		MaxLifetime: maxLifetime,
+		keys:        keys,
+		now:         time.Now,
+	}, nil
+}
+
+// Whether the client named by a verified token may log in with it
+func (v *OIDCVerifier) Allows(identity string) bool {
+	return v.clients[identity]
+}
+
+// ClientClaim defaults to 'sub', nil if no issuer is configured
+func LoadOIDCVerifier(cfg c.OIDCConfig) (*OIDCVerifier, error) {
+	if cfg.Issuer == "" {
+		return nil, nil
+	}
+	jwks, err := os.ReadFile(cfg.JWKSFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OIDC JWKS: %w", err)
+	}
+	return NewOIDCVerifier(cfg.Issuer, cfg.Audience, cfg.ClientClaim, cfg.Clients, cfg.MaxTokenLifetime, jwks)
+}
+
+// Returns the value of ClientClaim
+func (v *OIDCVerifier) Verify(idToken string) (string, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("%w: malformed", ErrInvalidIDToken)
+	}
+
+	var header oidcHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return "", fmt.Errorf("%w: header: %v", ErrInvalidIDToken, err)
+	}
+	key, ok := v.keys[header.Kid]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown key %q", ErrInvalidIDToken, header.Kid)
+	}
+	signature, err := b64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("%w: signature: %v", ErrInvalidIDToken, err)
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return "", fmt.Errorf("%w: claims: %v", ErrInvalidIDToken, err)
+	}
+	if err := v.verifyClaims(claims); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	identity, _ := claims[v.ClientClaim].(string)
+	if identity == "" {
+		return "", fmt.Errorf("%w: claim %q missing", ErrInvalidIDToken, v.ClientClaim)
+	}
+	return identity, nil
+}
+
+func (v *OIDCVerifier) verifyClaims(claims map[string]any) error {
+	if iss, _ := claims["iss"].(string); iss != v.Issuer {
+		return fmt.Errorf("issuer %q not trusted", iss)
+	}
+
+	var audiences []string
+	switch aud := claims["aud"].(type) {
+	case string:
+		audiences = []string{aud}
+	case []any:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				audiences = append(audiences, s)
+			}
+		}
+	}
+	if !slices.Contains(audiences, v.Audience) {
+		return fmt.Errorf("audience %v does not contain %q", audiences, v.Audience)
+	}
+
+	now := v.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("exp missing")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(oidcClockSkew)) {
+		return errors.New("expired")
+	}
+	iat, ok := claims["iat"].(float64)
+	if !ok {
+		return errors.New("iat missing")
+	}
+	issuedAt := time.Unix(int64(iat), 0)
+	if now.Add(oidcClockSkew).Before(issuedAt) {
+		return errors.New("issued in the future")
+	}
+	if lifetime := time.Unix(int64(exp), 0).Sub(issuedAt); lifetime > v.MaxLifetime {
+		return fmt.Errorf("lifetime %v exceeds %v", lifetime, v.MaxLifetime)
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(oidcClockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("not valid yet")
+	}
+	return nil
+}
+
+func verifySignature(alg string, key crypto.PublicKey, message, signature []byte) error {
+	digest := sha256.Sum256(message)
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if alg != "RS256" {
+			return fmt.Errorf("algorithm %q doesn't match RSA key", alg)
+		}
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature)
+	case *ecdsa.PublicKey:
+		if alg != "ES256" || len(signature) != 64 {
+			return fmt.Errorf("algorithm %q doesn't match EC key", alg)
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(k, digest[:], r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return errors.New("unsupported key type")
+}
+
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := b64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.N.BitLen() < 2048 {
+			return nil, errors.New("RSA key shorter than 2048 bits")
+		}
+		return key, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := b64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("invalid P-256 coordinates")
+		}
+		// rejects points not on the curve
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeSegment(segment string, dst any) error {
+	raw, err := b64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, dst)
+}
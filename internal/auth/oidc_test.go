@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"kms/internal/clients"
+	"kms/internal/test"
+	"kms/internal/test/mocks"
+	kmsErrors "kms/pkg/errors"
+	"kms/pkg/hashing"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testIssuer   = "https://kubernetes.default.svc"
+	testAudience = "kms"
+	testSubject  = "system:serviceaccount:payments:api"
+	// a client that isn't in the verifier's allow-list
+	testAdmin = "system:serviceaccount:kube-system:admin"
+)
+
+func newTestOIDCVerifier(t *testing.T, issuer *test.FakeIssuer, clientClaim string) *OIDCVerifier {
+	verifier, err := NewOIDCVerifier(testIssuer, testAudience, clientClaim, []string{testSubject, "system:serviceaccount:other:api"}, 24*time.Hour, issuer.JWKS(t))
+	test.RequireErrNil(t, err)
+	return verifier
+}
+
+func TestOIDCVerifier_Verify(t *testing.T) {
+	issuer := test.NewFakeIssuer(t, testIssuer)
+	verifier := newTestOIDCVerifier(t, issuer, "")
+
+	for _, kid := range []string{"rsa", "ec"} {
+		identity, err := verifier.Verify(issuer.Sign(t, kid, testSubject, testAudience, nil))
+		if err != nil || identity != testSubject {
+			t.Errorf("%s: expected %q, got %q (%v)", kid, testSubject, identity, err)
+		}
+	}
+
+	identity, err := verifier.Verify(issuer.Sign(t, "rsa", testSubject, "", map[string]any{"aud": []string{"other", testAudience}}))
+	if err != nil || identity != testSubject {
+		t.Errorf("audience list: expected %q, got %q (%v)", testSubject, identity, err)
+	}
+
+	// Issuer's clock slightly ahead, token valid for exactly the maximum lifetime
+	iat := time.Now().Add(30 * time.Second)
+	identity, err = verifier.Verify(issuer.Sign(t, "rsa", testSubject, testAudience, map[string]any{"iat": iat.Unix(), "exp": iat.Add(24 * time.Hour).Unix()}))
+	if err != nil || identity != testSubject {
+		t.Errorf("clock skew: expected %q, got %q (%v)", testSubject, identity, err)
+	}
+}
+
+func TestOIDCVerifier_Verify_Invalid(t *testing.T) {
+	issuer := test.NewFakeIssuer(t, testIssuer)
+	otherIssuer := test.NewFakeIssuer(t, testIssuer)
+	verifier := newTestOIDCVerifier(t, issuer, "")
+
+	valid := issuer.Sign(t, "rsa", testSubject, testAudience, nil)
+	parts := strings.Split(valid, ".")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"malformed", "abc"},
+		{"other issuer's key", otherIssuer.Sign(t, "rsa", testSubject, testAudience, nil)},
+		{"untrusted issuer", issuer.Sign(t, "rsa", testSubject, testAudience, map[string]any{"iss": "https://evil.example"})},
+		{"wrong audience", issuer.Sign(t, "ec", testSubject, "vault", nil)},
+		{"expired", issuer.Sign(t, "rsa", testSubject, testAudience, map[string]any{"exp": time.Now().Add(-time.Hour).Unix()})},
+		{"not valid yet", issuer.Sign(t, "rsa", testSubject, testAudience, map[string]any{"nbf": time.Now().Add(time.Hour).Unix()})},
+		{"no exp", issuer.Sign(t, "rsa", testSubject, testAudience, map[string]any{"exp": nil})},
+		{"no iat", issuer.Sign(t, "rsa", testSubject, testAudience, map[string]any{"iat": nil})},
+		{"issued in the future", issuer.Sign(t, "rsa", testSubject, testAudience, map[string]any{"iat": time.Now().Add(time.Hour).Unix(), "exp": time.Now().Add(2 * time.Hour).Unix()})},
+		{"lifetime too long", issuer.Sign(t, "rsa", testSubject, testAudience, map[string]any{"exp": time.Now().Add(48 * time.Hour).Unix()})},
+		{"no sub", issuer.Sign(t, "rsa", "", testAudience, nil)},
+		{"unknown key", issuer.Sign(t, "other", testSubject, testAudience, nil)},
+		{"tampered claims", parts[0] + "." + strings.Split(issuer.Sign(t, "rsa", "admin", testAudience, nil), ".")[1] + "." + parts[2]},
+		{"alg none", "eyJhbGciOiJub25lIiwia2lkIjoicnNhIn0." + parts[1] + "."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := verifier.Verify(tt.token); !errors.Is(err, ErrInvalidIDToken) {
+				t.Errorf("expected ErrInvalidIDToken, got %v", err)
+			}
+		})
+	}
+}
+
+func TestOIDCVerifier_ClientClaim(t *testing.T) {
+	issuer := test.NewFakeIssuer(t, testIssuer)
+	verifier := newTestOIDCVerifier(t, issuer, "email")
+
+	identity, err := verifier.Verify(issuer.Sign(t, "rsa", testSubject, testAudience, map[string]any{"email": "payments@example.com"}))
+	if err != nil || identity != "payments@example.com" {
+		t.Errorf("expected email claim, got %q (%v)", identity, err)
+	}
+}
+
+func TestNewOIDCVerifier_Invalid(t *testing.T) {
+	issuer := test.NewFakeIssuer(t, testIssuer)
+
+	if _, err := NewOIDCVerifier(testIssuer, "", "", []string{testSubject}, time.Hour, issuer.JWKS(t)); err == nil {
+		t.Error("expected error without audience")
+	}
+	if _, err := NewOIDCVerifier(testIssuer, testAudience, "", nil, time.Hour, issuer.JWKS(t)); err == nil {
+		t.Error("expected error without clients")
+	}
+	if _, err := NewOIDCVerifier(testIssuer, testAudience, "", []string{testSubject}, 0, issuer.JWKS(t)); err == nil {
+		t.Error("expected error without maximum lifetime")
+	}
+	if _, err := NewOIDCVerifier(testIssuer, testAudience, "", []string{testSubject}, time.Hour, []byte(`{"keys":[]}`)); err == nil {
+		t.Error("expected error without keys")
+	}
+	if _, err := NewOIDCVerifier(testIssuer, testAudience, "", []string{testSubject}, time.Hour, []byte(`{"keys":[{"kty":"EC","kid":"ec","crv":"P-256","x":"AQ","y":"AQ"}]}`)); err == nil {
+		t.Error("expected error for invalid EC key")
+	}
+}
+
+func newOIDCLoginService(t *testing.T, verifier *OIDCVerifier) *Service {
+	secret := []byte("clientnamesecret")
+	mockRepo := clients.NewClientRepositoryMock()
+	mockRepo.FindByHashedClientnameFunc = func(ctx context.Context, hashedClientname string) (*clients.Client, error) {
+		if hashedClientname == hashing.HashHS256ToB64([]byte(testSubject), secret) {
+			return &clients.Client{ID: 7, Role: "client"}, nil
+		}
+		if hashedClientname == hashing.HashHS256ToB64([]byte(testAdmin), secret) {
+			return &clients.Client{ID: 1, Role: "admin"}, nil
+		}
+		return nil, sql.ErrNoRows
+	}
+	mockRepo.GetMfaFunc = func(ctx context.Context, clientId int) (*clients.Mfa, error) {
+		return nil, sql.ErrNoRows
+	}
+	mockKeyManager := mocks.NewKeyManagerMock()
+	mockKeyManager.HashKeyFunc = func(key string) ([]byte, error) {
+		return secret, nil
+	}
+	tokenGenInfo := &TokenGenInfo{
+		Ttl:    3600,
+		Secret: []byte("jwtsecret"),
+		Typ:    "jwt",
+	}
+	return NewService(nil, mockRepo, tokenGenInfo, mockKeyManager, nil, verifier, mocks.NewLoggerMock())
+}
+
+func TestService_LoginWithOIDC(t *testing.T) {
+	issuer := test.NewFakeIssuer(t, testIssuer)
+	service := newOIDCLoginService(t, newTestOIDCVerifier(t, issuer, ""))
+
+	jwt, appErr := service.LoginWithOIDC(context.Background(), issuer.Sign(t, "ec", testSubject, testAudience, nil))
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	token, err := VerifyToken(jwt, []byte("jwtsecret"))
+	if err != nil || token.Payload.Sub != "7" {
+		t.Errorf("expected JWT for client 7, got %+v (%v)", token.Payload, err)
+	}
+
+	_, appErr = service.LoginWithOIDC(context.Background(), issuer.Sign(t, "ec", "system:serviceaccount:other:api", testAudience, nil))
+	if appErr == nil || appErr.Code != 401 {
+		t.Errorf("expected 401 for unknown client, got %v", appErr)
+	}
+
+	// a valid token naming an existing client isn't enough
+	_, appErr = service.LoginWithOIDC(context.Background(), issuer.Sign(t, "ec", testAdmin, testAudience, nil))
+	if appErr == nil || appErr.Code != 401 {
+		t.Errorf("expected 401 for client not in OIDC_CLIENTS, got %v", appErr)
+	}
+
+	_, appErr = service.LoginWithOIDC(context.Background(), issuer.Sign(t, "ec", testSubject, "vault", nil))
+	if appErr == nil || appErr.Code != 401 {
+		t.Errorf("expected 401 for invalid token, got %v", appErr)
+	}
+}
+
+func TestService_LoginWithOIDC_MfaEnabled(t *testing.T) {
+	issuer := test.NewFakeIssuer(t, testIssuer)
+	service := newOIDCLoginService(t, newTestOIDCVerifier(t, issuer, ""))
+	service.ClientRepo.(*clients.ClientRepositoryMock).GetMfaFunc = func(ctx context.Context, clientId int) (*clients.Mfa, error) {
+		return &clients.Mfa{ClientId: clientId, Enabled: true}, nil
+	}
+
+	_, appErr := service.LoginWithOIDC(context.Background(), issuer.Sign(t, "ec", testSubject, testAudience, nil))
+	if appErr == nil || appErr.Code != 401 || appErr.ErrorCode != kmsErrors.CodeMfaRequired {
+		t.Fatalf("expected 401 %s, got %v", kmsErrors.CodeMfaRequired, appErr)
+	}
+}
+
+func TestService_LoginWithOIDC_Disabled(t *testing.T) {
+	service := newOIDCLoginService(t, nil)
+
+	_, appErr := service.LoginWithOIDC(context.Background(), "token")
+	if appErr == nil || appErr.Code != 404 {
+		t.Errorf("expected 404, got %v", appErr)
+	}
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"context"
+	"kms/internal/clients"
+	"kms/internal/test/mocks"
+	kmsErrors "kms/pkg/errors"
+	"kms/pkg/hashing"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testPassword = "Valid123!1234"
+
+// Client repository mock holding a single client with a password
+func newPasswordService(t *testing.T) (*Service, *clients.Client) {
+	hashed, err := hashing.HashPassword(testPassword)
+	if err != nil {
+		t.Fatalf("hash failed: %v", err)
+	}
+	client := &clients.Client{ID: 7, Password: hashed, Role: "client"}
+
+	mockRepo := clients.NewClientRepositoryMock()
+	mockRepo.GetClientFunc = func(ctx context.Context, id int) (*clients.Client, error) {
+		stored := *client
+		return &stored, nil
+	}
+	mockRepo.UpdatePasswordFunc = func(ctx context.Context, id int, hashedPassword string) error {
+		client.Password = hashedPassword
+		return nil
+	}
+	mockKeyManager := mocks.NewKeyManagerMock()
+	mockKeyManager.SignupKeyFunc = func() []byte {
+		return []byte("signupsecret")
+	}
+	tokenGenInfo := &TokenGenInfo{
+		Ttl:    3600,
+		Secret: []byte("jwtsecret"),
+		Typ:    "jwt",
+	}
+	return NewService(nil, mockRepo, tokenGenInfo, mockKeyManager, nil, nil, mocks.NewLoggerMock()), client
+}
+
+func TestService_ChangePassword(t *testing.T) {
+	service, client := newPasswordService(t)
+
+	appErr := service.ChangePassword(context.Background(), 7, &ChangePasswordRequest{CurrentPassword: "Wrong123!1234", NewPassword: "Other123!1234"})
+	if appErr == nil || appErr.Code != 401 {
+		t.Fatalf("expected 401 for wrong current password, got %v", appErr)
+	}
+
+	appErr = service.ChangePassword(context.Background(), 7, &ChangePasswordRequest{CurrentPassword: testPassword, NewPassword: "short"})
+	if appErr == nil || appErr.Code != 400 {
+		t.Fatalf("expected 400 for weak password, got %v", appErr)
+	}
+
+	if appErr := service.ChangePassword(context.Background(), 7, &ChangePasswordRequest{CurrentPassword: testPassword, NewPassword: "Other123!1234"}); appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	if err := hashing.CheckPassword(client.Password, "Other123!1234"); err != nil {
+		t.Errorf("expected new password to be stored: %v", err)
+	}
+}
+
+func TestService_ResetPassword_OneTime(t *testing.T) {
+	service, client := newPasswordService(t)
+
+	token, err := GenerateResetToken(&TokenGenInfo{Ttl: 3600 * 1000, Secret: []byte("signupsecret"), Typ: "reset"}, client)
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+
+	jwt, appErr := service.ResetPassword(context.Background(), &ResetPasswordCredentials{Token: token, Password: "Reset123!1234"})
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	if jwt == "" {
+		t.Error("expected non-empty JWT")
+	}
+	if err := hashing.CheckPassword(client.Password, "Reset123!1234"); err != nil {
+		t.Errorf("expected new password to be stored: %v", err)
+	}
+
+	// the password changed, so the token can't be used again
+	_, appErr = service.ResetPassword(context.Background(), &ResetPasswordCredentials{Token: token, Password: "Again123!1234"})
+	if appErr == nil || appErr.Code != 400 {
+		t.Fatalf("expected 400 for used token, got %v", appErr)
+	}
+}
+
+func TestService_ResetPassword_WrongTokenTyp(t *testing.T) {
+	service, _ := newPasswordService(t)
+
+	token, err := GenerateSignupToken(&TokenGenInfo{Ttl: 3600 * 1000, Secret: []byte("signupsecret"), Typ: "signup"}, "7:fingerprint")
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+
+	_, appErr := service.ResetPassword(context.Background(), &ResetPasswordCredentials{Token: token, Password: "Reset123!1234"})
+	if appErr == nil || appErr.Code != 400 {
+		t.Fatalf("expected 400, got %v", appErr)
+	}
+}
+
+func TestHandler_ChangePassword(t *testing.T) {
+	mockService := NewAuthServiceMock()
+	mockService.ChangePasswordFunc = func(ctx context.Context, clientId int, body *ChangePasswordRequest) *kmsErrors.AppError {
+		if clientId != 7 || body.NewPassword != "Other123!1234" {
+			t.Errorf("unexpected arguments: %d, %+v", clientId, body)
+		}
+		return nil
+	}
+	handler := NewHandler(mockService, mocks.NewLoggerMock())
+
+	req := httptest.NewRequest("POST", "/auth/password", strings.NewReader(`{"currentPassword":"Valid123!1234","newPassword":"Other123!1234"}`))
+	req = req.WithContext(context.WithValue(req.Context(), TokenCtxKey, Token{Payload: &TokenPayload{Sub: "7"}}))
+	rr := httptest.NewRecorder()
+
+	if appErr := handler.ChangePassword(rr, req); appErr != nil {
+		t.Fatalf("handler returned an error: %v", appErr)
+	}
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", rr.Code)
+	}
+}
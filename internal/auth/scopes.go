@@ -0,0 +1,16 @@
+package auth
+
+import "slices"
+
+// Scopes restrict what a token (or API key) can be used for
+const (
+	ScopeKeysRead  = "keys:read"
+	ScopeKeysWrite = "keys:write"
+	ScopeAdmin     = "admin"
+)
+
+var Scopes = []string{ScopeKeysRead, ScopeKeysWrite, ScopeAdmin}
+
+func IsScope(scope string) bool {
+	return slices.Contains(Scopes, scope)
+}
@@ -1,29 +1,43 @@
 package auth
 
 import (
+	"context"
+	"crypto/subtle"
+	"crypto/x509"
 	"database/sql"
 	"errors"
 	"fmt"
 	c "kms/internal/bootstrap/context"
 	"kms/internal/clients"
+	"kms/internal/metrics"
 	kmsErrors "kms/pkg/errors"
 	"kms/pkg/hashing"
+	"kms/pkg/tracing"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 	"unicode"
 )
 
 type Service struct {
-	Cfg          c.KmsConfig
+	Cfg          *c.Config
 	ClientRepo   clients.ClientRepository
 	TokenGenInfo *TokenGenInfo
 	KeyManager   c.KeyManager
-	Logger       c.Logger
+	LoginLimiter *LoginLimiter
+	// nil if OIDC login isn't configured
+	OIDC   *OIDCVerifier
+	Logger c.Logger
 }
 
 func NewService(
-	cfg c.KmsConfig,
+	cfg *c.Config,
 	clientRepo clients.ClientRepository,
 	tokenGenInfo *TokenGenInfo,
 	keyManager c.KeyManager,
+	loginLimiter *LoginLimiter,
+	oidc *OIDCVerifier,
 	logger c.Logger,
 ) *Service {
 	return &Service{
@@ -31,11 +45,22 @@ func NewService(
 		ClientRepo:   clientRepo,
 		TokenGenInfo: tokenGenInfo,
 		KeyManager:   keyManager,
+		LoginLimiter: loginLimiter,
+		OIDC:         oidc,
 		Logger:       logger,
 	}
 }
 
-func (s *Service) Signup(cred *SignupCredentials) (string, *kmsErrors.AppError) {
+// Compared against when the clientname doesn't exist, so the response time doesn't reveal it
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := hashing.HashPassword("dummy password for timing")
+	return hash
+})
+
+func (s *Service) Signup(ctx context.Context, cred *SignupCredentials) (string, *kmsErrors.AppError) {
+	ctx, span := tracing.Start(ctx, "AuthService.Signup")
+	defer span.End()
+
 	token, err := VerifyToken(cred.Token, s.KeyManager.SignupKey())
 	if err != nil {
 		return "", kmsErrors.MapVerifyTokenErr(err)
@@ -68,16 +93,16 @@ func (s *Service) Signup(cred *SignupCredentials) (string, *kmsErrors.AppError)
 
 	hashedClientname := hashing.HashHS256ToB64([]byte(token.Payload.Sub), clientnameSecret)
 
-	s.Logger.Debug("Signup details", "clientname", token.Payload.Sub, "hashedClient", hashedClientname, "hashLength", len(hashedClientname))
+	s.Logger.Debug("Signup details", "hashedClient", hashedClientname, "hashLength", len(hashedClientname))
 
 	client := &clients.Client{
 		Clientname:       token.Payload.Sub,
 		HashedClientname: hashedClientname,
 		Password:         hashedPassword,
-		Role:             s.Cfg["DEFAULT_ROLE"],
+		Role:             s.Cfg.DefaultRole,
 	}
 
-	id, err := s.ClientRepo.CreateClient(client)
+	id, err := s.ClientRepo.CreateClient(ctx, client)
 	if err != nil {
 		return "", kmsErrors.MapRepoErr(err)
 	}
@@ -94,27 +119,64 @@ func (s *Service) Signup(cred *SignupCredentials) (string, *kmsErrors.AppError)
 	return jwt, nil
 }
 
-func (s *Service) Login(cred *Credentials) (string, *kmsErrors.AppError) {
+// Failed attempts are throttled per clientname (whether it exists or not) and per IP, see LoginLimiter.
+// Clients with MFA enabled also need a TOTP or recovery code (cred.Otp).
+func (s *Service) Login(ctx context.Context, cred *Credentials, ip string) (string, *kmsErrors.AppError) {
+	ctx, span := tracing.Start(ctx, "AuthService.Login")
+	defer span.End()
+
 	clientnameSecret, err := s.KeyManager.HashKey("clientname")
 	if err != nil {
 		return "", kmsErrors.NewInternalServerError(err)
 	}
 
 	hashedClientname := hashing.HashHS256ToB64([]byte(cred.Clientname), clientnameSecret)
-	client, err := s.ClientRepo.FindByHashedClientname(hashedClientname)
+
+	if wait := s.LoginLimiter.Blocked(hashedClientname, ip); wait > 0 {
+		metrics.LoginFailures.Inc("throttled")
+		return "", kmsErrors.NewAppError(
+			fmt.Errorf("login blocked for %v (hashedClientname: %s, ip: %s)", wait.Round(time.Second), hashedClientname, ip),
+			"Too many login attempts, try again later",
+			429,
+		).WithErrorCode(kmsErrors.CodeLoginThrottled)
+	}
+
+	client, err := s.ClientRepo.FindByHashedClientname(ctx, hashedClientname)
 	if err != nil {
 		// Check if err is "not found" to help prevent client enumeration attacks
 		if errors.Is(err, sql.ErrNoRows) {
-			return "", kmsErrors.NewAppError(err, "Incorrect clientname or password", 401)
+			hashing.CheckPassword(dummyPasswordHash(), cred.Password)
+			s.loginFailed(hashedClientname, ip, "unknown_client")
+			return "", kmsErrors.NewAppError(err, "Incorrect clientname or password", 401).WithErrorCode(kmsErrors.CodeInvalidCredentials)
 		}
 		return "", kmsErrors.MapRepoErr(err)
 	}
 
 	if err := hashing.CheckPassword(client.Password, cred.Password); err != nil {
+		s.loginFailed(hashedClientname, ip, "wrong_password")
 		return "", kmsErrors.MapHashErr(err)
 	}
 
-	jwt, err := GenerateJWT(s.TokenGenInfo, client)
+	mfa, appErr := s.getMfa(ctx, client.ID)
+	if appErr != nil {
+		return "", appErr
+	}
+
+	generate := GenerateJWT
+	if mfa != nil && mfa.Enabled {
+		if cred.Otp == "" {
+			return "", kmsErrors.NewAppError(fmt.Errorf("no MFA code (clientId: %d)", client.ID), "MFA code required", 401).WithErrorCode(kmsErrors.CodeMfaRequired)
+		}
+		if appErr := s.verifyMfa(ctx, mfa, cred.Otp); appErr != nil {
+			s.loginFailed(hashedClientname, ip, "invalid_mfa")
+			return "", appErr
+		}
+		generate = GenerateMfaJWT
+	}
+
+	s.LoginLimiter.Succeeded(hashedClientname)
+
+	jwt, err := generate(s.TokenGenInfo, client)
 	if err != nil {
 		return "", kmsErrors.NewInternalServerError(err)
 	}
@@ -124,6 +186,226 @@ func (s *Service) Login(cred *Credentials) (string, *kmsErrors.AppError) {
 	return jwt, nil
 }
 
+// reason is the label of metrics.LoginFailures
+func (s *Service) loginFailed(hashedClientname, ip, reason string) {
+	metrics.LoginFailures.Inc(reason)
+	nameLocked, ipLocked := s.LoginLimiter.Failed(hashedClientname, ip)
+	if nameLocked {
+		s.Logger.Notice("Clientname locked out after failed logins", "hashedClientname", hashedClientname, "ip", ip)
+	}
+	if ipLocked {
+		s.Logger.Notice("IP locked out after failed logins", "ip", ip)
+	}
+}
+
+// Client certificates are verified against the trusted CAs (MTLS_CA_FILE) during the TLS handshake,
+// this maps the certificate to the client it's bound to, see CertificateIdentities.
+func (s *Service) LoginWithCertificate(ctx context.Context, cert *x509.Certificate) (string, *kmsErrors.AppError) {
+	ctx, span := tracing.Start(ctx, "AuthService.LoginWithCertificate")
+	defer span.End()
+
+	identitySecret, err := s.KeyManager.HashKey("clientname")
+	if err != nil {
+		return "", kmsErrors.NewInternalServerError(err)
+	}
+
+	var binding *clients.Certificate
+	for _, identity := range CertificateIdentities(cert) {
+		binding, err = s.ClientRepo.FindCertificate(ctx, hashing.HashHS256ToB64([]byte(identity), identitySecret))
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return "", kmsErrors.MapRepoErr(err)
+		}
+		binding = nil
+	}
+	if binding == nil {
+		return "", kmsErrors.NewAppError(
+			fmt.Errorf("no client bound to certificate (%s)", cert.Subject),
+			"Certificate is not bound to a client",
+			401,
+		)
+	}
+
+	if binding.Pin != "" && subtle.ConstantTimeCompare([]byte(binding.Pin), []byte(CertificatePin(cert))) != 1 {
+		return "", kmsErrors.NewAppError(
+			fmt.Errorf("certificate key does not match pin of binding %d", binding.ID),
+			"Certificate is not bound to a client",
+			401,
+		)
+	}
+
+	client, err := s.ClientRepo.GetClient(ctx, binding.ClientId)
+	if err != nil {
+		return "", kmsErrors.MapRepoErr(err)
+	}
+	if appErr := RefuseMfaClient(ctx, s.ClientRepo, client.ID, "certificate"); appErr != nil {
+		return "", appErr
+	}
+
+	jwt, err := GenerateJWT(s.TokenGenInfo, client)
+	if err != nil {
+		return "", kmsErrors.NewInternalServerError(err)
+	}
+
+	s.Logger.Info("Client signed in with certificate", "clientId", client.ID, "certificateId", binding.ID)
+
+	return jwt, nil
+}
+
+// Exchanges an ID token of the trusted issuer for a JWT, its client claim (see OIDCVerifier) is the clientname.
+// Only clients listed in OIDC_CLIENTS can log in this way, so a token naming any other client is refused.
+func (s *Service) LoginWithOIDC(ctx context.Context, idToken string) (string, *kmsErrors.AppError) {
+	ctx, span := tracing.Start(ctx, "AuthService.LoginWithOIDC")
+	defer span.End()
+
+	if s.OIDC == nil {
+		return "", kmsErrors.NewAppError(errors.New("OIDC_ISSUER not set"), "OIDC login is not enabled", 404)
+	}
+
+	identity, err := s.OIDC.Verify(idToken)
+	if err != nil {
+		return "", kmsErrors.NewAppError(err, "Unauthorized", 401)
+	}
+	if !s.OIDC.Allows(identity) {
+		return "", kmsErrors.NewAppError(
+			fmt.Errorf("ID token's %s claim is not in OIDC_CLIENTS", s.OIDC.ClientClaim),
+			"ID token is not bound to a client",
+			401,
+		)
+	}
+
+	clientnameSecret, err := s.KeyManager.HashKey("clientname")
+	if err != nil {
+		return "", kmsErrors.NewInternalServerError(err)
+	}
+
+	client, err := s.ClientRepo.FindByHashedClientname(ctx, hashing.HashHS256ToB64([]byte(identity), clientnameSecret))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", kmsErrors.NewAppError(
+				fmt.Errorf("no client named after ID token's %s claim", s.OIDC.ClientClaim),
+				"ID token is not bound to a client",
+				401,
+			)
+		}
+		return "", kmsErrors.MapRepoErr(err)
+	}
+	if appErr := RefuseMfaClient(ctx, s.ClientRepo, client.ID, "OIDC"); appErr != nil {
+		return "", appErr
+	}
+
+	jwt, err := GenerateJWT(s.TokenGenInfo, client)
+	if err != nil {
+		return "", kmsErrors.NewInternalServerError(err)
+	}
+
+	s.Logger.Info("Client signed in with ID token", "clientId", client.ID, "issuer", s.OIDC.Issuer)
+
+	return jwt, nil
+}
+
+// Re-verifies the current password, so a stolen JWT can't be used to take over the client
+func (s *Service) ChangePassword(ctx context.Context, clientId int, body *ChangePasswordRequest) *kmsErrors.AppError {
+	ctx, span := tracing.Start(ctx, "AuthService.ChangePassword", "kms.client_id", clientId)
+	defer span.End()
+
+	client, err := s.ClientRepo.GetClient(ctx, clientId)
+	if err != nil {
+		return kmsErrors.MapRepoErr(err)
+	}
+
+	if err := hashing.CheckPassword(client.Password, body.CurrentPassword); err != nil {
+		return kmsErrors.MapHashErr(err)
+	}
+
+	if body.NewPassword == body.CurrentPassword {
+		return kmsErrors.NewAppError(errors.New("new password equals current password"), "New password must differ from the current password", 400)
+	}
+
+	if appErr := s.updatePassword(ctx, clientId, body.NewPassword); appErr != nil {
+		return appErr
+	}
+
+	s.Logger.Notice("Client changed password", "clientId", clientId)
+
+	return nil
+}
+
+func (s *Service) ResetPassword(ctx context.Context, cred *ResetPasswordCredentials) (string, *kmsErrors.AppError) {
+	ctx, span := tracing.Start(ctx, "AuthService.ResetPassword")
+	defer span.End()
+
+	token, err := VerifyToken(cred.Token, s.KeyManager.SignupKey())
+	if err != nil {
+		return "", kmsErrors.MapVerifyTokenErr(err)
+	}
+
+	if token.Header.Typ != "reset" {
+		return "", kmsErrors.NewAppError(
+			kmsErrors.WrapError(kmsErrors.ErrInvalidToken, map[string]interface{}{
+				"msg":  "Token should be of type 'reset'",
+				"type": token.Header.Typ,
+			}),
+			"Invalid token",
+			400,
+		)
+	}
+
+	idStr, fingerprint, _ := strings.Cut(token.Payload.Sub, ":")
+	clientId, err := strconv.Atoi(idStr)
+	if err != nil {
+		return "", kmsErrors.NewAppError(err, "Invalid token", 400)
+	}
+
+	client, err := s.ClientRepo.GetClient(ctx, clientId)
+	if err != nil {
+		return "", kmsErrors.MapRepoErr(err)
+	}
+
+	// the password has changed since the token was issued, e.g. the token was already used
+	if subtle.ConstantTimeCompare([]byte(fingerprint), []byte(PasswordFingerprint(client.Password))) != 1 {
+		return "", kmsErrors.NewAppError(
+			kmsErrors.WrapError(kmsErrors.ErrInvalidToken, map[string]interface{}{
+				"msg":      "Reset token has already been used",
+				"clientId": clientId,
+			}),
+			"Invalid token",
+			400,
+		)
+	}
+
+	if appErr := s.updatePassword(ctx, clientId, cred.Password); appErr != nil {
+		return "", appErr
+	}
+
+	jwt, err := GenerateJWT(s.TokenGenInfo, client)
+	if err != nil {
+		return "", kmsErrors.NewInternalServerError(err)
+	}
+
+	s.Logger.Notice("Client reset password", "clientId", clientId)
+
+	return jwt, nil
+}
+
+func (s *Service) updatePassword(ctx context.Context, clientId int, password string) *kmsErrors.AppError {
+	if err := validatePassword(password); err != nil {
+		return kmsErrors.NewAppError(err, "Password does not meet minimum requirements. 12 <= len <= 128 & contains at least 3 of the following: Upper, lower, sym & digit", 400)
+	}
+
+	hashedPassword, err := hashing.HashPassword(password)
+	if err != nil {
+		return kmsErrors.MapHashErr(err)
+	}
+
+	if err := s.ClientRepo.UpdatePassword(ctx, clientId, hashedPassword); err != nil {
+		return kmsErrors.MapRepoErr(err)
+	}
+	return nil
+}
+
 func validatePassword(password string) error {
 	if len(password) < 12 || len(password) > 128 {
 		return fmt.Errorf("password length should be between 12 and 128, is %d", len(password))
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	c "kms/internal/bootstrap/context"
@@ -9,11 +10,12 @@ import (
 	"kms/pkg/hashing"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestService_Signup_Success(t *testing.T) {
 	mockRepo := clients.NewClientRepositoryMock()
-	mockRepo.CreateClientFunc = func(client *clients.Client) (int, error) {
+	mockRepo.CreateClientFunc = func(ctx context.Context, client *clients.Client) (int, error) {
 		return 1, nil
 	}
 	mockLogger := mocks.NewLoggerMock()
@@ -26,9 +28,7 @@ func TestService_Signup_Success(t *testing.T) {
 		return []byte("clientnamesecret"), nil
 	}
 
-	cfg := c.KmsConfig{
-		"DEFAULT_ROLE": "client",
-	}
+	cfg := &c.Config{DefaultRole: "client"}
 
 	tokenGenInfo := &TokenGenInfo{
 		Ttl:    3600,
@@ -36,7 +36,7 @@ func TestService_Signup_Success(t *testing.T) {
 		Typ:    "signup",
 	}
 
-	service := NewService(cfg, mockRepo, tokenGenInfo, mockKeyManager, mockLogger)
+	service := NewService(cfg, mockRepo, tokenGenInfo, mockKeyManager, NewLoginLimiter(LoginLimits{MaxFailures: 5, Lockout: time.Minute}), nil, mockLogger)
 
 	token, err := GenerateSignupToken(tokenGenInfo, "testclient")
 	if err != nil {
@@ -47,7 +47,7 @@ func TestService_Signup_Success(t *testing.T) {
 		Password: "Valid123!1234",
 	}
 
-	jwt, appErr := service.Signup(cred)
+	jwt, appErr := service.Signup(context.Background(), cred)
 	if appErr != nil {
 		t.Fatalf("expected no error, got %v", appErr)
 	}
@@ -68,23 +68,21 @@ func TestService_Signup_InvalidTokenError(t *testing.T) {
 	mockKeyManager.HashKeyFunc = func(key string) ([]byte, error) {
 		return []byte("clientnamesecret"), nil
 	}
-	cfg := c.KmsConfig{
-		"DEFAULT_ROLE": "client",
-	}
+	cfg := &c.Config{DefaultRole: "client"}
 	tokenGenInfo := &TokenGenInfo{
 		Ttl:    3600,
 		Secret: signupSecret,
 		Typ:    "signup",
 	}
 
-	service := NewService(cfg, mockRepo, tokenGenInfo, mockKeyManager, mockLogger)
+	service := NewService(cfg, mockRepo, tokenGenInfo, mockKeyManager, NewLoginLimiter(LoginLimits{MaxFailures: 5, Lockout: time.Minute}), nil, mockLogger)
 
 	cred := &SignupCredentials{
 		Token:    "invalidtoken",
 		Password: "Valid123!1234",
 	}
 
-	_, appErr := service.Signup(cred)
+	_, appErr := service.Signup(context.Background(), cred)
 	if appErr == nil {
 		t.Fatal("expected error for invalid token, got nil")
 	}
@@ -101,9 +99,7 @@ func TestService_Signup_WrongTokenTyp(t *testing.T) {
 	mockKeyManager.SignupKeyFunc = func() []byte {
 		return signupSecret
 	}
-	cfg := c.KmsConfig{
-		"DEFAULT_ROLE": "client",
-	}
+	cfg := &c.Config{DefaultRole: "client"}
 
 	tokenGenInfo := &TokenGenInfo{
 		Ttl:    3600,
@@ -111,7 +107,7 @@ func TestService_Signup_WrongTokenTyp(t *testing.T) {
 		Typ:    "jwt",
 	}
 
-	service := NewService(cfg, mockRepo, tokenGenInfo, mockKeyManager, mockLogger)
+	service := NewService(cfg, mockRepo, tokenGenInfo, mockKeyManager, NewLoginLimiter(LoginLimits{MaxFailures: 5, Lockout: time.Minute}), nil, mockLogger)
 
 	token, err := GenerateSignupToken(tokenGenInfo, "testclient")
 	if err != nil {
@@ -122,7 +118,7 @@ func TestService_Signup_WrongTokenTyp(t *testing.T) {
 		Password: "Valid123!1234",
 	}
 
-	_, appErr := service.Signup(cred)
+	_, appErr := service.Signup(context.Background(), cred)
 	if appErr == nil {
 		t.Fatal("expected error for invalid token type, got nil")
 	}
@@ -142,15 +138,13 @@ func TestService_Signup_InvalidPasswordError(t *testing.T) {
 	mockKeyManager.HashKeyFunc = func(key string) ([]byte, error) {
 		return []byte("clientnamesecret"), nil
 	}
-	cfg := c.KmsConfig{
-		"DEFAULT_ROLE": "client",
-	}
+	cfg := &c.Config{DefaultRole: "client"}
 	tokenGenInfo := &TokenGenInfo{
 		Ttl:    3600,
 		Secret: signupSecret,
 		Typ:    "signup",
 	}
-	service := NewService(cfg, mockRepo, tokenGenInfo, mockKeyManager, mockLogger)
+	service := NewService(cfg, mockRepo, tokenGenInfo, mockKeyManager, NewLoginLimiter(LoginLimits{MaxFailures: 5, Lockout: time.Minute}), nil, mockLogger)
 	token, err := GenerateSignupToken(tokenGenInfo, "testclient")
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
@@ -159,7 +153,7 @@ func TestService_Signup_InvalidPasswordError(t *testing.T) {
 		Token:    token,
 		Password: "short",
 	}
-	_, appErr := service.Signup(cred)
+	_, appErr := service.Signup(context.Background(), cred)
 	if appErr == nil {
 		t.Fatal("expected error for invalid password, got nil")
 	}
@@ -170,7 +164,7 @@ func TestService_Signup_InvalidPasswordError(t *testing.T) {
 
 func TestService_Signup_RepoError(t *testing.T) {
 	mockRepo := clients.NewClientRepositoryMock()
-	mockRepo.CreateClientFunc = func(client *clients.Client) (int, error) {
+	mockRepo.CreateClientFunc = func(ctx context.Context, client *clients.Client) (int, error) {
 		return 0, errors.New("repo error")
 	}
 	mockLogger := mocks.NewLoggerMock()
@@ -182,15 +176,13 @@ func TestService_Signup_RepoError(t *testing.T) {
 	mockKeyManager.HashKeyFunc = func(key string) ([]byte, error) {
 		return []byte("clientnamesecret"), nil
 	}
-	cfg := c.KmsConfig{
-		"DEFAULT_ROLE": "client",
-	}
+	cfg := &c.Config{DefaultRole: "client"}
 	tokenGenInfo := &TokenGenInfo{
 		Ttl:    3600,
 		Secret: signupSecret,
 		Typ:    "signup",
 	}
-	service := NewService(cfg, mockRepo, tokenGenInfo, mockKeyManager, mockLogger)
+	service := NewService(cfg, mockRepo, tokenGenInfo, mockKeyManager, NewLoginLimiter(LoginLimits{MaxFailures: 5, Lockout: time.Minute}), nil, mockLogger)
 	token, err := GenerateSignupToken(tokenGenInfo, "testclient")
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
@@ -200,7 +192,7 @@ func TestService_Signup_RepoError(t *testing.T) {
 		Password: "Valid123!1234",
 	}
 
-	_, appErr := service.Signup(cred)
+	_, appErr := service.Signup(context.Background(), cred)
 	if appErr == nil {
 		t.Fatal("expected error for repository failure, got nil")
 	}
@@ -212,7 +204,7 @@ func TestService_Signup_RepoError(t *testing.T) {
 func TestService_Login_Success(t *testing.T) {
 	mockRepo := clients.NewClientRepositoryMock()
 	hashedPassword, _ := hashing.HashPassword("Valid123!1234")
-	mockRepo.FindByHashedClientnameFunc = func(clientname string) (*clients.Client, error) {
+	mockRepo.FindByHashedClientnameFunc = func(ctx context.Context, clientname string) (*clients.Client, error) {
 		return &clients.Client{
 			ID:               1,
 			Clientname:       "testclient",
@@ -221,6 +213,9 @@ func TestService_Login_Success(t *testing.T) {
 			Role:             "client",
 		}, nil
 	}
+	mockRepo.GetMfaFunc = func(ctx context.Context, clientId int) (*clients.Mfa, error) {
+		return nil, sql.ErrNoRows
+	}
 	mockLogger := mocks.NewLoggerMock()
 	mockKeyManager := mocks.NewKeyManagerMock()
 	clientnameSecret := []byte("clientnamesecret")
@@ -228,9 +223,7 @@ func TestService_Login_Success(t *testing.T) {
 		return clientnameSecret, nil
 	}
 
-	cfg := c.KmsConfig{
-		"DEFAULT_ROLE": "client",
-	}
+	cfg := &c.Config{DefaultRole: "client"}
 
 	tokenGenInfo := &TokenGenInfo{
 		Ttl:    3600,
@@ -238,14 +231,14 @@ func TestService_Login_Success(t *testing.T) {
 		Typ:    "jwt",
 	}
 
-	service := NewService(cfg, mockRepo, tokenGenInfo, mockKeyManager, mockLogger)
+	service := NewService(cfg, mockRepo, tokenGenInfo, mockKeyManager, NewLoginLimiter(LoginLimits{MaxFailures: 5, Lockout: time.Minute}), nil, mockLogger)
 
 	loginCreds := &Credentials{
 		Clientname: "testclient",
 		Password:   "Valid123!1234",
 	}
 
-	jwt, appErr := service.Login(loginCreds)
+	jwt, appErr := service.Login(context.Background(), loginCreds, "192.0.2.1")
 	if appErr != nil {
 		t.Fatalf("expected no error, got %v", appErr)
 	}
@@ -262,22 +255,20 @@ func TestService_Login_KeyManagerError(t *testing.T) {
 	mockKeyManager.HashKeyFunc = func(key string) ([]byte, error) {
 		return nil, errors.New("key manager error")
 	}
-	cfg := c.KmsConfig{
-		"DEFAULT_ROLE": "client",
-	}
+	cfg := &c.Config{DefaultRole: "client"}
 	tokenGenInfo := &TokenGenInfo{
 		Ttl:    3600,
 		Secret: []byte("secret"),
 		Typ:    "jwt",
 	}
-	service := NewService(cfg, mockRepo, tokenGenInfo, mockKeyManager, mockLogger)
+	service := NewService(cfg, mockRepo, tokenGenInfo, mockKeyManager, NewLoginLimiter(LoginLimits{MaxFailures: 5, Lockout: time.Minute}), nil, mockLogger)
 
 	loginCreds := &Credentials{
 		Clientname: "testclient",
 		Password:   "Valid123!1234",
 	}
 
-	_, appErr := service.Login(loginCreds)
+	_, appErr := service.Login(context.Background(), loginCreds, "192.0.2.1")
 	if appErr == nil {
 		t.Fatal("expected error for key manager failure, got nil")
 	}
@@ -288,7 +279,7 @@ func TestService_Login_KeyManagerError(t *testing.T) {
 
 func TestService_Login_ClientNotFound(t *testing.T) {
 	mockRepo := clients.NewClientRepositoryMock()
-	mockRepo.FindByHashedClientnameFunc = func(clientname string) (*clients.Client, error) {
+	mockRepo.FindByHashedClientnameFunc = func(ctx context.Context, clientname string) (*clients.Client, error) {
 		return nil, sql.ErrNoRows
 	}
 	mockLogger := mocks.NewLoggerMock()
@@ -296,22 +287,20 @@ func TestService_Login_ClientNotFound(t *testing.T) {
 	mockKeyManager.HashKeyFunc = func(key string) ([]byte, error) {
 		return []byte("clientnamesecret"), nil
 	}
-	cfg := c.KmsConfig{
-		"DEFAULT_ROLE": "client",
-	}
+	cfg := &c.Config{DefaultRole: "client"}
 	tokenGenInfo := &TokenGenInfo{
 		Ttl:    3600,
 		Secret: []byte("secret"),
 		Typ:    "jwt",
 	}
-	service := NewService(cfg, mockRepo, tokenGenInfo, mockKeyManager, mockLogger)
+	service := NewService(cfg, mockRepo, tokenGenInfo, mockKeyManager, NewLoginLimiter(LoginLimits{MaxFailures: 5, Lockout: time.Minute}), nil, mockLogger)
 
 	loginCreds := &Credentials{
 		Clientname: "testclient",
 		Password:   "Valid123!1234",
 	}
 
-	_, appErr := service.Login(loginCreds)
+	_, appErr := service.Login(context.Background(), loginCreds, "192.0.2.1")
 	if appErr == nil {
 		t.Fatal("expected error for client not found, got nil")
 	}
@@ -326,7 +315,7 @@ func TestService_Login_ClientNotFound(t *testing.T) {
 func TestService_Login_InvalidPassword(t *testing.T) {
 	mockRepo := clients.NewClientRepositoryMock()
 	hashedPassword, _ := hashing.HashPassword("Valid123!1234")
-	mockRepo.FindByHashedClientnameFunc = func(clientname string) (*clients.Client, error) {
+	mockRepo.FindByHashedClientnameFunc = func(ctx context.Context, clientname string) (*clients.Client, error) {
 		return &clients.Client{
 			ID:               1,
 			Clientname:       "testclient",
@@ -340,22 +329,20 @@ func TestService_Login_InvalidPassword(t *testing.T) {
 	mockKeyManager.HashKeyFunc = func(key string) ([]byte, error) {
 		return []byte("clientnamesecret"), nil
 	}
-	cfg := c.KmsConfig{
-		"DEFAULT_ROLE": "client",
-	}
+	cfg := &c.Config{DefaultRole: "client"}
 	tokenGenInfo := &TokenGenInfo{
 		Ttl:    3600,
 		Secret: []byte("secret"),
 		Typ:    "jwt",
 	}
-	service := NewService(cfg, mockRepo, tokenGenInfo, mockKeyManager, mockLogger)
+	service := NewService(cfg, mockRepo, tokenGenInfo, mockKeyManager, NewLoginLimiter(LoginLimits{MaxFailures: 5, Lockout: time.Minute}), nil, mockLogger)
 
 	loginCreds := &Credentials{
 		Clientname: "testclient",
 		Password:   "WrongPassword123!",
 	}
 
-	_, appErr := service.Login(loginCreds)
+	_, appErr := service.Login(context.Background(), loginCreds, "192.0.2.1")
 	if appErr == nil {
 		t.Fatal("expected error for invalid password, got nil")
 	}
@@ -367,6 +354,42 @@ func TestService_Login_InvalidPassword(t *testing.T) {
 	}
 }
 
+func TestService_Login_Throttled(t *testing.T) {
+	mockRepo := clients.NewClientRepositoryMock()
+	mockRepo.FindByHashedClientnameFunc = func(ctx context.Context, clientname string) (*clients.Client, error) {
+		return nil, sql.ErrNoRows
+	}
+	mockKeyManager := mocks.NewKeyManagerMock()
+	mockKeyManager.HashKeyFunc = func(key string) ([]byte, error) {
+		return []byte("clientnamesecret"), nil
+	}
+	tokenGenInfo := &TokenGenInfo{
+		Ttl:    3600,
+		Secret: []byte("secret"),
+		Typ:    "jwt",
+	}
+	service := NewService(&c.Config{}, mockRepo, tokenGenInfo, mockKeyManager, NewLoginLimiter(LoginLimits{MaxFailures: 5, Lockout: time.Minute}), nil, mocks.NewLoggerMock())
+
+	loginCreds := &Credentials{
+		Clientname: "unknown",
+		Password:   "WrongPassword123!",
+	}
+
+	// unknown clientnames fail the same way
+	for i := 0; i < 2; i++ {
+		_, appErr := service.Login(context.Background(), loginCreds, "192.0.2.1")
+		if appErr == nil || appErr.Code != 401 || appErr.Message != "Incorrect clientname or password" {
+			t.Fatalf("expected 401, got %v", appErr)
+		}
+	}
+
+	// backed off
+	_, appErr := service.Login(context.Background(), loginCreds, "192.0.2.1")
+	if appErr == nil || appErr.Code != 429 {
+		t.Fatalf("expected 429, got %v", appErr)
+	}
+}
+
 func TestValidatePassword(t *testing.T) {
 	cases := []struct {
 		password string
@@ -8,6 +8,7 @@ import (
 	"kms/internal/clients"
 	kmsErrors "kms/pkg/errors"
 	"kms/pkg/hashing"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -27,8 +28,26 @@ type TokenPayload struct {
 	Sub string `json:"sub"`
 	Ttl int64  `json:"ttl"`
 	Iat int64  `json:"iat"`
+	// Empty for tokens from a password or certificate login, which can do everything the client can
+	Scopes []string `json:"scp,omitempty"`
+	// When the login was verified with a TOTP or recovery code (unix ms), 0 without MFA
+	Mfa int64 `json:"mfa,omitempty"`
 }
 
+func (p *TokenPayload) HasScope(scope string) bool {
+	return len(p.Scopes) == 0 || slices.Contains(p.Scopes, scope)
+}
+
+// Whether MFA was verified within maxAge, see RequireFreshMfa
+func (p *TokenPayload) MfaFresh(maxAge time.Duration) bool {
+	return p.Mfa != 0 && time.Since(time.UnixMilli(p.Mfa)) <= maxAge
+}
+
+type contextKey string
+
+// Request context key of the verified Token, set by middleware.Authorize (and aliased by httpctx)
+const TokenCtxKey contextKey = "token"
+
 type TokenGenInfo struct {
 	Ttl    int64
 	Secret []byte
@@ -36,15 +55,31 @@ type TokenGenInfo struct {
 }
 
 func GenerateJWT(genInfo *TokenGenInfo, client *clients.Client) (string, error) {
+	return generateJWT(genInfo, client, nil, 0)
+}
+
+// JWT that can only be used for routes requiring one of the scopes, see RequireScope
+func GenerateScopedJWT(genInfo *TokenGenInfo, client *clients.Client, scopes []string) (string, error) {
+	return generateJWT(genInfo, client, scopes, 0)
+}
+
+// JWT of a login that was verified with a second factor
+func GenerateMfaJWT(genInfo *TokenGenInfo, client *clients.Client) (string, error) {
+	return generateJWT(genInfo, client, nil, time.Now().UnixMilli())
+}
+
+func generateJWT(genInfo *TokenGenInfo, client *clients.Client, scopes []string, mfa int64) (string, error) {
 	header := TokenHeader{
 		Ver: "1",
 		Typ: genInfo.Typ,
 	}
 
 	payload := TokenPayload{
-		Sub: strconv.Itoa(client.ID),
-		Ttl: genInfo.Ttl,
-		Iat: time.Now().UnixMilli(),
+		Sub:    strconv.Itoa(client.ID),
+		Ttl:    genInfo.Ttl,
+		Iat:    time.Now().UnixMilli(),
+		Scopes: scopes,
+		Mfa:    mfa,
 	}
 
 	token := Token{
@@ -75,6 +110,33 @@ func GenerateSignupToken(genInfo *TokenGenInfo, clientname string) (string, erro
 	return GenerateToken(&token, genInfo.Secret)
 }
 
+// Reset tokens are bound to the password they reset, so they can only be used once
+func GenerateResetToken(genInfo *TokenGenInfo, client *clients.Client) (string, error) {
+	header := TokenHeader{
+		Ver: "1",
+		Typ: genInfo.Typ,
+	}
+
+	payload := TokenPayload{
+		Sub: strconv.Itoa(client.ID) + ":" + PasswordFingerprint(client.Password),
+		Ttl: genInfo.Ttl,
+		Iat: time.Now().UnixMilli(),
+	}
+
+	token := Token{
+		Header:  &header,
+		Payload: &payload,
+	}
+
+	return GenerateToken(&token, genInfo.Secret)
+}
+
+// Changes with every password change, without revealing the (bcrypt) hash
+func PasswordFingerprint(hashedPassword string) string {
+	sum := sha256.Sum256([]byte(hashedPassword))
+	return b64.RawURLEncoding.EncodeToString(sum[:16])
+}
+
 func GenerateToken(token *Token, secret []byte) (string, error) {
 	headerBytes, err := json.Marshal(*token.Header)
 	if err != nil {
@@ -102,7 +164,6 @@ func VerifyToken(jwt string, secret []byte) (Token, error) {
 	if len(parts) != 3 {
 		return token, kmsErrors.WrapError(kmsErrors.ErrInvalidToken, map[string]interface{}{
 			"msg": "Not a JWT",
-			"jwt": jwt,
 		})
 	}
 
@@ -115,7 +176,6 @@ func VerifyToken(jwt string, secret []byte) (Token, error) {
 	if !verifyHMAC([]byte(message), decodedSignature, secret) {
 		return token, kmsErrors.WrapError(kmsErrors.ErrInvalidToken, map[string]interface{}{
 			"msg": "MACs don't match",
-			"jwt": jwt,
 		})
 	}
 
@@ -129,9 +189,8 @@ func VerifyToken(jwt string, secret []byte) (Token, error) {
 	}
 
 	if !verifyStillValid(&payload) {
-		return token, kmsErrors.WrapError(kmsErrors.ErrInvalidToken, map[string]interface{}{
+		return token, kmsErrors.WrapError(kmsErrors.ErrTokenExpired, map[string]interface{}{
 			"msg": "TTL has passed",
-			"jwt": jwt,
 		})
 	}
 
@@ -9,11 +9,13 @@ import (
 )
 
 type AppContext struct {
-	Cfg        c.KmsConfig
+	Cfg        *c.Config
 	KeyManager c.KeyManager
 	Logger     c.Logger
 	DB         *sql.DB
-	KeyRepo    keys.KeyRepository
-	ClientRepo clients.ClientRepository
-	AdminRepo  admin.AdminRepository
+	// Checked by /readyz, the schema should be at its latest migration
+	MigrationsPath string
+	KeyRepo        keys.KeyRepository
+	ClientRepo     clients.ClientRepository
+	AdminRepo      admin.AdminRepository
 }
@@ -0,0 +1,135 @@
+package bootstrap
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	c "kms/internal/bootstrap/context"
+	"os"
+	"sync"
+	"time"
+)
+
+// Serves the server certificate through tls.Config.GetCertificate, and the trusted client CAs (MTLS_CA_FILE, optional)
+// through GetConfigForClient, so both can be replaced without a restart, on SIGHUP (Reload) or when the files change (Watch).
+// Files that fail to load keep the current certificate and CAs.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+	logger   c.Logger
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTime   time.Time
+}
+
+func NewCertReloader(certFile, keyFile, caFile string, logger c.Logger) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile, caFile: caFile, logger: logger}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// nil without MTLS_CA_FILE
+func (r *CertReloader) ClientCAs() *x509.CertPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.clientCAs
+}
+
+func (r *CertReloader) Reload() error {
+	modTime, err := r.lastModified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("unable to load %s and %s: %w", r.certFile, r.keyFile, err)
+	}
+	var clientCAs *x509.CertPool
+	if r.caFile != "" {
+		if clientCAs, err = loadCertPool(r.caFile); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.modTime = modTime
+	r.mu.Unlock()
+	return nil
+}
+
+// Checks the files every interval and reloads them once any of them changed, until ctx is cancelled
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		modTime, err := r.lastModified()
+		if err != nil {
+			r.logger.Warn("Unable to check TLS certificate", "error", err)
+			continue
+		}
+		r.mu.RLock()
+		changed := !modTime.Equal(r.modTime)
+		r.mu.RUnlock()
+		if !changed {
+			continue
+		}
+
+		if err := r.Reload(); err != nil {
+			// e.g. the certificate was written but the key not yet, retried on the next tick
+			r.logger.Warn("Unable to reload TLS certificate", "error", err)
+			continue
+		}
+		r.logger.Notice("Reloaded TLS certificate", "certFile", r.certFile, "caFile", r.caFile)
+	}
+}
+
+// The latest modification time of the files
+func (r *CertReloader) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile, r.caFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	bundle, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
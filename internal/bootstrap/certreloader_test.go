@@ -0,0 +1,125 @@
+package bootstrap
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"kms/internal/test/mocks"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Self-signed pair for commonName, written to dir/tls.crt and dir/tls.key
+func writeCertPair(t *testing.T, dir, commonName string, modTime time.Time) (string, string) {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate failed: %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key failed: %v", err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	for path, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: der},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDer},
+	} {
+		if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		// mtimes can be too coarse to tell quick writes apart
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("chtimes failed: %v", err)
+		}
+	}
+	return certFile, keyFile
+}
+
+func servedCommonName(t *testing.T, r *CertReloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("parse certificate failed: %v", err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	certFile, keyFile := writeCertPair(t, dir, "old", now)
+
+	r, err := NewCertReloader(certFile, keyFile, "", mocks.NewLoggerMock())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cn := servedCommonName(t, r); cn != "old" {
+		t.Fatalf("expected old, got %s", cn)
+	}
+
+	writeCertPair(t, dir, "new", now.Add(time.Second))
+	if err := r.Reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cn := servedCommonName(t, r); cn != "new" {
+		t.Errorf("expected new, got %s", cn)
+	}
+
+	// a broken pair keeps the current certificate
+	if err := os.WriteFile(keyFile, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Error("expected error for an invalid key, got nil")
+	}
+	if cn := servedCommonName(t, r); cn != "new" {
+		t.Errorf("expected new, got %s", cn)
+	}
+
+	if _, err := NewCertReloader(certFile+".missing", keyFile, "", mocks.NewLoggerMock()); err == nil {
+		t.Error("expected error for a missing certificate, got nil")
+	}
+}
+
+func TestCertReloader_Watch(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	certFile, keyFile := writeCertPair(t, dir, "old", now)
+	r, err := NewCertReloader(certFile, keyFile, "", mocks.NewLoggerMock())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 5*time.Millisecond)
+
+	writeCertPair(t, dir, "rotated", now.Add(time.Minute))
+	deadline := time.Now().Add(2 * time.Second)
+	for servedCommonName(t, r) != "rotated" {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the rotated certificate to be picked up")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -2,44 +2,397 @@ package bootstrap
 
 import (
 	"bufio"
+	b64 "encoding/base64"
+	"errors"
 	"fmt"
 	c "kms/internal/bootstrap/context"
+	"kms/internal/clients"
+	"maps"
+	"math"
+	"net"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
-func LoadConfig(path string) (c.KmsConfig, error) {
-	cfg := make(map[string]string)
+var kmsSettings = []string{
+	"ENV", "CLEAR_DB",
+	"DB_HOST", "DB_PORT", "DB_NAME", "DB_USER", "DB_PASSWORD", "DB_SSLMODE",
+	"SERVER_PORT", "TLS_CERT_FILE", "TLS_KEY_FILE",
+	"SERVER_READ_HEADER_TIMEOUT_SECONDS", "SERVER_READ_TIMEOUT_SECONDS", "SERVER_WRITE_TIMEOUT_SECONDS",
+	"SERVER_IDLE_TIMEOUT_SECONDS", "SERVER_MAX_HEADER_BYTES", "SERVER_SHUTDOWN_TIMEOUT_SECONDS", "REQUEST_TIMEOUT_SECONDS",
+	"METRICS_ADDR", "OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_SERVICE_NAME",
+	"MTLS_CA_FILE", "OIDC_ISSUER", "OIDC_AUDIENCE", "OIDC_JWKS_FILE", "OIDC_CLIENT_CLAIM", "OIDC_CLIENTS",
+	"OIDC_MAX_TOKEN_LIFETIME_SECONDS",
+	"LOG_LEVEL", "LOG_FORMAT", "LOG_FILE", "LOG_FILE_MAX_MB", "LOG_FILE_MAX_BACKUPS", "LOG_SYSLOG",
+	"JWT_SECRET", "JWT_TTL", "MASTER_ADMIN_USERNAME", "MASTER_ADMIN_PASSWORD", "DEFAULT_ROLE",
+	"KEY_DELETION_WINDOW_DAYS", "LOGIN_MAX_FAILURES", "LOGIN_LOCKOUT_MINUTES", "ADMIN_MFA_REQUIRED",
+	"RATE_LIMIT", "RATE_LIMIT_KEY_READ", "RATE_LIMIT_AUTH", "KEY_READ_QUOTA_DAILY",
+	"KEK", "DB_SECRET", "SIGNUP_SECRET", "KEY_REF_SECRET", "USERNAME_SECRET",
+}
+
+// Bytes KEK and DB_SECRET must have (AES-256), and the HMAC secrets at least
+const configKeySize = 32
+
+// Bounds of KEY_DELETION_WINDOW_DAYS
+const (
+	MinKeyDeletionWindowDays = 7
+	MaxKeyDeletionWindowDays = 30
+)
+
+// The server's config: the .env file at path if it exists, overridden by environment variables.
+// NAME_FILE can be set instead of NAME, e.g. KEK_FILE=/run/secrets/kek, to read a secret from a file.
+// All problems are reported at once.
+func LoadKmsConfig(path string) (*c.Config, error) {
+	values, err := loadSettings(path, kmsSettings)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKmsConfig(values)
+}
+
+// Settings of the kms-client CLI, read from the same .env file as the server's
+type ClientConfig struct {
+	// 'dev' accepts the server's self-signed certificate
+	Env  string
+	Host string
+	Port int
+}
+
+// URL of the API route at path, e.g. "/keys/actions/generate"
+func (cfg *ClientConfig) URL(path string) string {
+	return "https://" + net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)) + "/v1" + path
+}
+
+// Like LoadKmsConfig, only ENV, SERVER_HOST and SERVER_PORT are read
+func LoadClientConfig(path string) (*ClientConfig, error) {
+	values, err := loadSettings(path, []string{"ENV", "SERVER_HOST", "SERVER_PORT"})
+	if err != nil {
+		return nil, err
+	}
+	p := &configParser{values: values}
+	cfg := &ClientConfig{
+		Env:  p.str("ENV", ""),
+		Host: p.required("SERVER_HOST"),
+		Port: p.port("SERVER_PORT", 0),
+	}
+	if cfg.Port == 0 {
+		p.fail("SERVER_PORT", errors.New("required"))
+	}
+	if err := errors.Join(p.errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// The .env file at path if it exists, names (and NAME_FILE) set in the environment take precedence
+func loadSettings(path string, names []string) (map[string]string, error) {
+	values, err := readEnvFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			values[name] = value
+			delete(values, name+"_FILE")
+		}
+		if value := os.Getenv(name + "_FILE"); value != "" {
+			values[name+"_FILE"] = value
+			if os.Getenv(name) == "" {
+				delete(values, name)
+			}
+		}
+	}
+	return values, nil
+}
+
+// KEY=value lines, empty values are unset settings, as in .env.example
+func readEnvFile(path string) (map[string]string, error) {
+	values := make(map[string]string)
 	file, err := os.Open(path)
 	if err != nil {
-		return cfg, err
+		return values, err
 	}
 	defer file.Close()
 
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
-		line := scanner.Text()
-		line = strings.TrimSpace(line)
+		line := strings.TrimSpace(scanner.Text())
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
-			return cfg, fmt.Errorf("invalid format: %v", line)
+		key, value, ok := strings.Cut(line, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" {
+			return values, fmt.Errorf("invalid format: %v", line)
 		}
+		if value != "" {
+			values[key] = value
+		}
+	}
+	return values, scanner.Err()
+}
+
+func ParseKmsConfig(values map[string]string) (*c.Config, error) {
+	p := &configParser{values: maps.Clone(values)}
+	p.readFiles()
+
+	cfg := &c.Config{
+		Env:     p.str("ENV", ""),
+		ClearDB: p.bool("CLEAR_DB"),
+		DB: c.DBConfig{
+			Host:     p.str("DB_HOST", ""),
+			Port:     p.port("DB_PORT", 5432),
+			Name:     p.required("DB_NAME"),
+			User:     p.required("DB_USER"),
+			Password: p.str("DB_PASSWORD", ""),
+			SSLMode:  p.oneOf("DB_SSLMODE", "require", "disable", "allow", "prefer", "require", "verify-ca", "verify-full"),
+		},
+		Server: c.ServerConfig{
+			Port:              p.port("SERVER_PORT", 0),
+			CertFile:          p.str("TLS_CERT_FILE", "kms.crt"),
+			KeyFile:           p.str("TLS_KEY_FILE", "kms.key"),
+			ReadHeaderTimeout: p.seconds("SERVER_READ_HEADER_TIMEOUT_SECONDS", 10),
+			ReadTimeout:       p.seconds("SERVER_READ_TIMEOUT_SECONDS", 30),
+			WriteTimeout:      p.seconds("SERVER_WRITE_TIMEOUT_SECONDS", 60),
+			IdleTimeout:       p.seconds("SERVER_IDLE_TIMEOUT_SECONDS", 120),
+			MaxHeaderBytes:    p.int("SERVER_MAX_HEADER_BYTES", 64<<10, 4<<10, 1<<20),
+			ShutdownTimeout:   p.seconds("SERVER_SHUTDOWN_TIMEOUT_SECONDS", 30),
+			RequestTimeout:    p.seconds("REQUEST_TIMEOUT_SECONDS", 30),
+		},
+		Log: c.LogConfig{
+			Level:          p.str("LOG_LEVEL", "info"),
+			Format:         p.oneOf("LOG_FORMAT", LogFormatJSON, LogFormatJSON, LogFormatLogfmt),
+			File:           p.str("LOG_FILE", ""),
+			FileMaxMB:      p.int("LOG_FILE_MAX_MB", 100, 1, math.MaxInt32),
+			FileMaxBackups: p.int("LOG_FILE_MAX_BACKUPS", 5, 0, math.MaxInt32),
+			Syslog:         p.str("LOG_SYSLOG", ""),
+		},
+		Tracing: c.TracingConfig{
+			Endpoint:    p.str("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+			ServiceName: p.str("OTEL_SERVICE_NAME", defaultServiceName),
+		},
+		MetricsAddr: p.str("METRICS_ADDR", ""),
+		Keys: c.KeysConfig{
+			KEK:            p.key("KEK", true),
+			DBSecret:       p.key("DB_SECRET", true),
+			JWTSecret:      p.key("JWT_SECRET", false),
+			SignupSecret:   p.key("SIGNUP_SECRET", false),
+			KeyRefSecret:   p.key("KEY_REF_SECRET", false),
+			UsernameSecret: p.key("USERNAME_SECRET", false),
+		},
+		JWTTTL: time.Duration(p.int("JWT_TTL", 3600000, 1000, math.MaxInt32)) * time.Millisecond,
+		MasterAdmin: c.MasterAdminConfig{
+			Username: p.required("MASTER_ADMIN_USERNAME"),
+			Password: p.required("MASTER_ADMIN_PASSWORD"),
+		},
+		DefaultRole:       p.str("DEFAULT_ROLE", "client"),
+		KeyDeletionWindow: time.Duration(p.int("KEY_DELETION_WINDOW_DAYS", 30, MinKeyDeletionWindowDays, MaxKeyDeletionWindowDays)) * 24 * time.Hour,
+		LoginMaxFailures:  p.int("LOGIN_MAX_FAILURES", 5, 1, math.MaxInt32),
+		LoginLockout:      time.Duration(p.int("LOGIN_LOCKOUT_MINUTES", 15, 1, math.MaxInt32)) * time.Minute,
+		AdminMfaRequired:  p.bool("ADMIN_MFA_REQUIRED"),
+		RateLimit:         p.rate("RATE_LIMIT"),
+		RateLimitKeyRead:  p.rate("RATE_LIMIT_KEY_READ"),
+		RateLimitAuth:     p.rate("RATE_LIMIT_AUTH"),
+		KeyReadQuotaDaily: p.int("KEY_READ_QUOTA_DAILY", 0, 1, math.MaxInt32),
+		MTLSCAFile:        p.str("MTLS_CA_FILE", ""),
+		OIDC: c.OIDCConfig{
+			Issuer:      p.str("OIDC_ISSUER", ""),
+			Audience:    p.str("OIDC_AUDIENCE", ""),
+			JWKSFile:    p.str("OIDC_JWKS_FILE", ""),
+			ClientClaim: p.str("OIDC_CLIENT_CLAIM", ""),
+			Clients:     p.list("OIDC_CLIENTS"),
+			// Kubernetes caps service account tokens at 24h by default
+			MaxTokenLifetime: p.seconds("OIDC_MAX_TOKEN_LIFETIME_SECONDS", 24*60*60),
+		},
+	}
+
+	if cfg.RateLimitKeyRead == nil {
+		cfg.RateLimitKeyRead = cfg.RateLimit
+	}
+	if cfg.RateLimitAuth == nil {
+		cfg.RateLimitAuth = cfg.RateLimit
+	}
+	_, err := mapLogLevel(cfg.Log.Level)
+	p.check("LOG_LEVEL", err)
+	if cfg.MasterAdmin.Username != "" {
+		p.check("MASTER_ADMIN_USERNAME", clients.ValidateClientname(cfg.MasterAdmin.Username))
+	}
+
+	if cfg.Server.Port == 0 {
+		p.fail("SERVER_PORT", errors.New("required"))
+	}
+	if cfg.Server.WriteTimeout <= cfg.Server.RequestTimeout {
+		p.fail("SERVER_WRITE_TIMEOUT_SECONDS", fmt.Errorf("must be longer than the request timeout (%v)", cfg.Server.RequestTimeout))
+	}
+	if endpoint := cfg.Tracing.Endpoint; endpoint != "" {
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			p.fail("OTEL_EXPORTER_OTLP_ENDPOINT", fmt.Errorf("expected an http(s) URL, is %q", endpoint))
+		}
+	}
+	if cfg.OIDC.Issuer != "" {
+		p.required("OIDC_AUDIENCE")
+		p.required("OIDC_JWKS_FILE")
+		p.required("OIDC_CLIENTS")
+	}
+
+	if err := errors.Join(p.errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+type configParser struct {
+	values map[string]string
+	errs   []error
+}
+
+func (p *configParser) fail(name string, err error) {
+	p.errs = append(p.errs, fmt.Errorf("%s: %w", name, err))
+}
 
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
+func (p *configParser) check(name string, err error) {
+	if err != nil {
+		p.fail(name, err)
+	}
+}
 
-		if key == "" || value == "" {
-			return cfg, fmt.Errorf("invalid key or value: %v", line)
+// Replaces NAME_FILE with NAME, read from the file without its trailing newline
+func (p *configParser) readFiles() {
+	for _, name := range kmsSettings {
+		path, ok := p.values[name+"_FILE"]
+		if !ok {
+			continue
+		}
+		if _, ok := p.values[name]; ok {
+			p.fail(name, fmt.Errorf("set either %s or %s_FILE", name, name))
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			p.fail(name+"_FILE", err)
+			continue
+		}
+		if value := strings.TrimRight(string(content), "\r\n"); value != "" {
+			p.values[name] = value
 		}
+	}
+}
+
+func (p *configParser) str(name, fallback string) string {
+	if value, ok := p.values[name]; ok {
+		return value
+	}
+	return fallback
+}
 
-		if err := scanner.Err(); err != nil {
-			return cfg, err
+func (p *configParser) required(name string) string {
+	value, ok := p.values[name]
+	if !ok {
+		p.fail(name, errors.New("required"))
+	}
+	return value
+}
+
+// Comma separated, empty entries are dropped
+func (p *configParser) list(name string) []string {
+	var values []string
+	for _, value := range strings.Split(p.str(name, ""), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
 		}
+	}
+	return values
+}
+
+func (p *configParser) oneOf(name, fallback string, allowed ...string) string {
+	value := p.str(name, fallback)
+	if !slices.Contains(allowed, value) {
+		p.fail(name, fmt.Errorf("must be one of %s, is %q", strings.Join(allowed, ", "), value))
+	}
+	return value
+}
+
+func (p *configParser) bool(name string) bool {
+	value, ok := p.values[name]
+	if !ok {
+		return false
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		p.fail(name, fmt.Errorf("must be true or false, is %q", value))
+	}
+	return b
+}
+
+func (p *configParser) int(name string, fallback, min, max int) int {
+	value, ok := p.values[name]
+	if !ok {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		p.fail(name, fmt.Errorf("must be an integer between %d and %d, is %q", min, max, value))
+		return fallback
+	}
+	return n
+}
 
-		cfg[key] = value
+// 0 if not set and there's no default
+func (p *configParser) port(name string, fallback int) int {
+	return p.int(name, fallback, 1, 65535)
+}
+
+func (p *configParser) seconds(name string, fallback int) time.Duration {
+	return time.Duration(p.int(name, fallback, 1, math.MaxInt32)) * time.Second
+}
+
+// "<requests>/<s|m|h>", e.g. "600/m" (burst of 600, refilled at 10/s), nil if not set
+func (p *configParser) rate(name string) *c.Rate {
+	value, ok := p.values[name]
+	if !ok {
+		return nil
+	}
+	count, period, ok := strings.Cut(value, "/")
+	n, err := strconv.Atoi(count)
+	if !ok || err != nil || n < 1 {
+		p.fail(name, fmt.Errorf("must be '<requests>/<s|m|h>', is %q", value))
+		return nil
+	}
+	var d time.Duration
+	switch period {
+	case "s":
+		d = time.Second
+	case "m":
+		d = time.Minute
+	case "h":
+		d = time.Hour
+	default:
+		p.fail(name, fmt.Errorf("period must be one of s, m, h, is %q", period))
+		return nil
+	}
+	return &c.Rate{PerSecond: float64(n) / d.Seconds(), Burst: n}
+}
+
+// base64url, exactly configKeySize bytes for AES keys, at least that for HMAC secrets
+func (p *configParser) key(name string, aes bool) []byte {
+	value := p.required(name)
+	if value == "" {
+		return nil
+	}
+	key, err := b64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		p.fail(name, errors.New("must be base64url encoded (RFC 4648, no padding)"))
+		return nil
+	}
+	if aes && len(key) != configKeySize {
+		p.fail(name, fmt.Errorf("must be %d bytes, is %d", configKeySize, len(key)))
+	} else if !aes && len(key) < configKeySize {
+		p.fail(name, fmt.Errorf("must be at least %d bytes, is %d", configKeySize, len(key)))
 	}
-	return c.KmsConfig(cfg), nil
+	return key
 }
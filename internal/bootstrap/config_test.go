@@ -1,10 +1,15 @@
 package bootstrap
 
 import (
+	"encoding/base64"
 	"errors"
+	c "kms/internal/bootstrap/context"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 )
 
 func writeTempFile(t *testing.T, content string) string {
@@ -21,64 +26,305 @@ func writeTempFile(t *testing.T, content string) string {
 	return f.Name()
 }
 
-func TestLoadConfig_Valid(t *testing.T) {
+func TestReadEnvFile(t *testing.T) {
 	content := `
     # comment line
     JWT_SECRET = foo
     SIGNUP_SECRET=bar
+
     # another comment
     KEK = baz
+    LOG_LEVEL=
     `
 	fname := writeTempFile(t, content)
 	defer os.Remove(fname)
-	cfg, err := LoadConfig(fname)
+	values, err := readEnvFile(fname)
 	if err != nil {
-		t.Fatalf("LoadConfig failed: %v", err)
+		t.Fatalf("readEnvFile failed: %v", err)
 	}
-	if cfg["JWT_SECRET"] != "foo" || cfg["SIGNUP_SECRET"] != "bar" || cfg["KEK"] != "baz" {
-		t.Errorf("unexpected config: %#v", cfg)
+	if len(values) != 3 || values["JWT_SECRET"] != "foo" || values["SIGNUP_SECRET"] != "bar" || values["KEK"] != "baz" {
+		t.Errorf("unexpected values: %#v", values)
 	}
 }
 
-func TestLoadConfig_BlankAndCommentLines(t *testing.T) {
-	content := "\n\n# comment\nFOO=bar\n\n# another\nBAZ=qux\n"
-	fname := writeTempFile(t, content)
+func TestReadEnvFile_InvalidFormat(t *testing.T) {
+	for _, content := range []string{"FOO=bar\nBADLINE\nBAZ=qux", "FOO=bar\n=novalue"} {
+		fname := writeTempFile(t, content)
+		defer os.Remove(fname)
+		if _, err := readEnvFile(fname); err == nil || !strings.Contains(err.Error(), "invalid format") {
+			t.Errorf("%q: expected invalid format error, got: %v", content, err)
+		}
+	}
+}
+
+func TestReadEnvFile_FileNotFound(t *testing.T) {
+	_, err := readEnvFile("/no/such/file/shouldexist.env")
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist, got: %v", err)
+	}
+}
+
+func TestLoadClientConfig(t *testing.T) {
+	fname := writeTempFile(t, "ENV=dev\nSERVER_HOST=localhost\nSERVER_PORT=8443\nKEK=\n")
 	defer os.Remove(fname)
-	cfg, err := LoadConfig(fname)
+
+	cfg, err := LoadClientConfig(fname)
 	if err != nil {
-		t.Fatalf("LoadConfig failed: %v", err)
+		t.Fatalf("unexpected error: %v", err)
 	}
-	if len(cfg) != 2 || cfg["FOO"] != "bar" || cfg["BAZ"] != "qux" {
-		t.Errorf("unexpected config: %#v", cfg)
+	if cfg.Env != "dev" || cfg.URL("/auth/login") != "https://localhost:8443/v1/auth/login" {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	t.Setenv("SERVER_HOST", "::1")
+	cfg, err = LoadClientConfig(fname)
+	if err != nil || cfg.URL("/keys") != "https://[::1]:8443/v1/keys" {
+		t.Errorf("expected SERVER_HOST from the environment, got %+v (%v)", cfg, err)
 	}
 }
 
-func TestLoadConfig_InvalidFormat(t *testing.T) {
-	content := "FOO=bar\nBADLINE\nBAZ=qux"
-	fname := writeTempFile(t, content)
+func TestLoadClientConfig_Invalid(t *testing.T) {
+	fname := writeTempFile(t, "SERVER_PORT=https\n")
 	defer os.Remove(fname)
-	_, err := LoadConfig(fname)
-	if err == nil || !strings.Contains(err.Error(), "invalid format") {
-		t.Errorf("expected invalid format error, got: %v", err)
+
+	_, err := LoadClientConfig(fname)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	for _, name := range []string{"SERVER_HOST", "SERVER_PORT"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("expected %s in %v", name, err)
+		}
+	}
+
+	if _, err := LoadClientConfig(filepath.Join(t.TempDir(), ".env")); err == nil || !strings.Contains(err.Error(), "SERVER_HOST: required") {
+		t.Errorf("expected required error without a file, got %v", err)
 	}
 }
 
-func TestLoadConfig_EmptyKeyOrValue(t *testing.T) {
-	content := "FOO=bar\n=novalue\nkeyonly=\nBAZ=qux"
-	fname := writeTempFile(t, content)
-	defer os.Remove(fname)
-	_, err := LoadConfig(fname)
-	if err == nil || !strings.Contains(err.Error(), "invalid key or value") {
-		t.Errorf("expected invalid key or value error, got: %v", err)
+func testKey(size int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.Repeat("k", size)))
+}
+
+// Only the required settings
+func validSettings() map[string]string {
+	return map[string]string{
+		"DB_NAME":               "kms",
+		"DB_USER":               "kms",
+		"SERVER_PORT":           "8443",
+		"MASTER_ADMIN_USERNAME": "admin",
+		"MASTER_ADMIN_PASSWORD": "Valid123!1234",
+		"KEK":                   testKey(32),
+		"DB_SECRET":             testKey(32),
+		"JWT_SECRET":            testKey(32),
+		"SIGNUP_SECRET":         testKey(48),
+		"KEY_REF_SECRET":        testKey(32),
+		"USERNAME_SECRET":       testKey(32),
+	}
+}
+
+func TestParseKmsConfig_Defaults(t *testing.T) {
+	cfg, err := ParseKmsConfig(validSettings())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.DB.Port != 5432 || cfg.DB.SSLMode != "require" || cfg.Server.Port != 8443 {
+		t.Errorf("unexpected database or port %+v %+v", cfg.DB, cfg.Server)
+	}
+	if cfg.Server.ReadHeaderTimeout != 10*time.Second || cfg.Server.WriteTimeout != 60*time.Second ||
+		cfg.Server.MaxHeaderBytes != 64<<10 || cfg.Server.RequestTimeout != 30*time.Second ||
+		cfg.Server.ShutdownTimeout != 30*time.Second || cfg.Server.CertFile != "kms.crt" {
+		t.Errorf("unexpected server config %+v", cfg.Server)
+	}
+	if cfg.JWTTTL != time.Hour || cfg.DefaultRole != "client" || cfg.Log.Level != "info" || cfg.Log.Format != LogFormatJSON {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if cfg.KeyDeletionWindow != 30*24*time.Hour || cfg.LoginMaxFailures != 5 || cfg.LoginLockout != 15*time.Minute {
+		t.Errorf("unexpected key or login settings %+v", cfg)
+	}
+	if cfg.OIDC.MaxTokenLifetime != 24*time.Hour {
+		t.Errorf("unexpected OIDC max token lifetime %v", cfg.OIDC.MaxTokenLifetime)
+	}
+	if cfg.RateLimit != nil || cfg.RateLimitKeyRead != nil || cfg.RateLimitAuth != nil || cfg.KeyReadQuotaDaily != 0 {
+		t.Errorf("expected no limits, got %+v", cfg)
+	}
+	if len(cfg.Keys.KEK) != 32 || len(cfg.Keys.SignupSecret) != 48 {
+		t.Errorf("expected decoded keys, got %d and %d bytes", len(cfg.Keys.KEK), len(cfg.Keys.SignupSecret))
+	}
+}
+
+func TestParseKmsConfig_Limits(t *testing.T) {
+	settings := validSettings()
+	settings["RATE_LIMIT"] = "600/m"
+	settings["RATE_LIMIT_AUTH"] = "5/s"
+	settings["KEY_READ_QUOTA_DAILY"] = "1000"
+	settings["KEY_DELETION_WINDOW_DAYS"] = "7"
+	settings["LOGIN_MAX_FAILURES"] = "3"
+	settings["LOGIN_LOCKOUT_MINUTES"] = "30"
+	settings["REQUEST_TIMEOUT_SECONDS"] = "5"
+	settings["SERVER_WRITE_TIMEOUT_SECONDS"] = "10"
+
+	cfg, err := ParseKmsConfig(settings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *cfg.RateLimit != (c.Rate{PerSecond: 10, Burst: 600}) || *cfg.RateLimitAuth != (c.Rate{PerSecond: 5, Burst: 5}) {
+		t.Errorf("unexpected rates %+v %+v", cfg.RateLimit, cfg.RateLimitAuth)
+	}
+	if cfg.RateLimitKeyRead != cfg.RateLimit {
+		t.Errorf("expected RATE_LIMIT_KEY_READ to default to RATE_LIMIT, got %+v", cfg.RateLimitKeyRead)
+	}
+	if cfg.KeyReadQuotaDaily != 1000 || cfg.KeyDeletionWindow != 7*24*time.Hour || cfg.Server.RequestTimeout != 5*time.Second {
+		t.Errorf("unexpected quota, deletion window or request timeout %+v", cfg)
+	}
+	if cfg.LoginMaxFailures != 3 || cfg.LoginLockout != 30*time.Minute {
+		t.Errorf("unexpected login limits %d %v", cfg.LoginMaxFailures, cfg.LoginLockout)
 	}
 }
 
-func TestLoadConfig_FileNotFound(t *testing.T) {
-	_, err := LoadConfig("/no/such/file/shouldexist.env")
+func TestParseKmsConfig_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"SERVER_PORT":                        "70000",
+		"DB_PORT":                            "abc",
+		"DB_SSLMODE":                         "sometimes",
+		"KEK":                                testKey(16),
+		"DB_SECRET":                          testKey(64),
+		"JWT_SECRET":                         "not base64!",
+		"USERNAME_SECRET":                    testKey(31),
+		"JWT_TTL":                            "invalid",
+		"SERVER_READ_TIMEOUT_SECONDS":        "0",
+		"SERVER_IDLE_TIMEOUT_SECONDS":        "1m",
+		"SERVER_MAX_HEADER_BYTES":            "1024",
+		"SERVER_WRITE_TIMEOUT_SECONDS":       "10",
+		"LOG_LEVEL":                          "verbose",
+		"LOG_FILE_MAX_MB":                    "0",
+		"LOG_FILE_MAX_BACKUPS":               "-1",
+		"OTEL_EXPORTER_OTLP_ENDPOINT":        "grpc://localhost:4317",
+		"KEY_DELETION_WINDOW_DAYS":           "31",
+		"RATE_LIMIT":                         "0/s",
+		"RATE_LIMIT_KEY_READ":                "10",
+		"RATE_LIMIT_AUTH":                    "10/d",
+		"KEY_READ_QUOTA_DAILY":               "0",
+		"LOGIN_MAX_FAILURES":                 "x",
+		"LOGIN_LOCKOUT_MINUTES":              "-1",
+		"REQUEST_TIMEOUT_SECONDS":            "5s",
+		"ADMIN_MFA_REQUIRED":                 "yes",
+		"MASTER_ADMIN_USERNAME":              "a",
+		"SERVER_READ_HEADER_TIMEOUT_SECONDS": "-5",
+	}
+	for name, value := range tests {
+		settings := validSettings()
+		settings[name] = value
+		_, err := ParseKmsConfig(settings)
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s=%s: expected error, got %v", name, value, err)
+		}
+	}
+
+	// required settings
+	for _, name := range []string{"SERVER_PORT", "DB_NAME", "KEK", "MASTER_ADMIN_PASSWORD"} {
+		settings := validSettings()
+		delete(settings, name)
+		if _, err := ParseKmsConfig(settings); err == nil || !strings.Contains(err.Error(), name+": required") {
+			t.Errorf("%s: expected required error, got %v", name, err)
+		}
+	}
+
+	settings := validSettings()
+	settings["OIDC_ISSUER"] = "https://issuer.example"
+	if _, err := ParseKmsConfig(settings); err == nil || !strings.Contains(err.Error(), "OIDC_JWKS_FILE") {
+		t.Errorf("expected OIDC_JWKS_FILE to be required with OIDC_ISSUER, got %v", err)
+	}
+	if _, err := ParseKmsConfig(settings); err == nil || !strings.Contains(err.Error(), "OIDC_CLIENTS: required") {
+		t.Errorf("expected OIDC_CLIENTS to be required with OIDC_ISSUER, got %v", err)
+	}
+
+	settings["OIDC_AUDIENCE"] = "kms"
+	settings["OIDC_JWKS_FILE"] = "jwks.json"
+	settings["OIDC_CLIENTS"] = " payments-api, ,billing "
+	cfg, err := ParseKmsConfig(settings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(cfg.OIDC.Clients, []string{"payments-api", "billing"}) {
+		t.Errorf("unexpected OIDC clients %q", cfg.OIDC.Clients)
+	}
+}
+
+func TestParseKmsConfig_ReportsAllErrors(t *testing.T) {
+	settings := validSettings()
+	settings["KEK"] = testKey(16)
+	settings["JWT_TTL"] = "0"
+	delete(settings, "DB_USER")
+
+	_, err := ParseKmsConfig(settings)
 	if err == nil {
-		t.Error("expected error for missing file, got nil")
+		t.Fatal("expected error, got nil")
 	}
-	if !errors.Is(err, os.ErrNotExist) {
-		t.Errorf("expected os.ErrNotExist, got: %v", err)
+	for _, name := range []string{"KEK", "JWT_TTL", "DB_USER"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("expected %s in %v", name, err)
+		}
+	}
+}
+
+func TestParseKmsConfig_SecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	kekFile := filepath.Join(dir, "kek")
+	if err := os.WriteFile(kekFile, []byte(testKey(32)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := validSettings()
+	delete(settings, "KEK")
+	settings["KEK_FILE"] = kekFile
+	cfg, err := ParseKmsConfig(settings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Keys.KEK) != 32 {
+		t.Errorf("expected KEK from file, got %d bytes", len(cfg.Keys.KEK))
+	}
+	if _, ok := settings["KEK"]; ok {
+		t.Error("expected the settings to be left as they were")
+	}
+
+	settings["KEK"] = testKey(32)
+	if _, err := ParseKmsConfig(settings); err == nil || !strings.Contains(err.Error(), "KEK_FILE") {
+		t.Errorf("expected error when both are set, got %v", err)
+	}
+
+	delete(settings, "KEK")
+	settings["KEK_FILE"] = filepath.Join(dir, "missing")
+	if _, err := ParseKmsConfig(settings); err == nil || !strings.Contains(err.Error(), "KEK_FILE") {
+		t.Errorf("expected error for missing file, got %v", err)
+	}
+}
+
+func TestLoadKmsConfig_EnvOverrides(t *testing.T) {
+	var content strings.Builder
+	for name, value := range validSettings() {
+		content.WriteString(name + "=" + value + "\n")
+	}
+	content.WriteString("JWT_TTL=60000\nLOG_LEVEL=\nKEK_FILE=/no/such/file\n")
+	fname := writeTempFile(t, content.String())
+	defer os.Remove(fname)
+
+	t.Setenv("SERVER_PORT", "9443")
+	t.Setenv("KEK", testKey(32))
+	cfg, err := LoadKmsConfig(fname)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Server.Port != 9443 || cfg.JWTTTL != time.Minute || cfg.Log.Level != "info" {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	// without a file, everything comes from the environment
+	for name, value := range validSettings() {
+		t.Setenv(name, value)
+	}
+	if _, err := LoadKmsConfig(filepath.Join(t.TempDir(), ".env")); err != nil {
+		t.Errorf("unexpected error: %v", err)
 	}
 }
@@ -0,0 +1,114 @@
+package context
+
+import "time"
+
+// The server's settings, loaded and validated at startup by bootstrap.LoadKmsConfig.
+// Optional settings that aren't set hold their defaults.
+type Config struct {
+	// 'dev' enables the dev-only routes and CLEAR_DB
+	Env     string
+	ClearDB bool
+
+	DB          DBConfig
+	Server      ServerConfig
+	Log         LogConfig
+	Tracing     TracingConfig
+	MetricsAddr string
+
+	Keys        KeysConfig
+	JWTTTL      time.Duration
+	MasterAdmin MasterAdminConfig
+	DefaultRole string
+
+	KeyDeletionWindow time.Duration
+	LoginMaxFailures  int
+	LoginLockout      time.Duration
+	AdminMfaRequired  bool
+	// nil is unlimited, the route specific rates default to RateLimit (with buckets of their own)
+	RateLimit        *Rate
+	RateLimitKeyRead *Rate
+	RateLimitAuth    *Rate
+	// 0 is unlimited
+	KeyReadQuotaDaily int
+
+	// PEM bundle of CAs for mTLS login, empty disables it
+	MTLSCAFile string
+	// Issuer is empty if OIDC login is disabled
+	OIDC OIDCConfig
+}
+
+// Token bucket of a rate limit, from '<requests>/<s|m|h>'
+type Rate struct {
+	// Tokens added per second
+	PerSecond float64
+	// Bucket size, i.e. the number of requests allowed in a burst
+	Burst int
+}
+
+type DBConfig struct {
+	Host     string
+	Port     int
+	Name     string
+	User     string
+	Password string
+	SSLMode  string
+}
+
+type ServerConfig struct {
+	Port     int
+	CertFile string
+	KeyFile  string
+
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	// Should stay above RequestTimeout, otherwise timed out requests get no response
+	WriteTimeout   time.Duration
+	IdleTimeout    time.Duration
+	MaxHeaderBytes int
+	// Deadline of each request's context
+	RequestTimeout time.Duration
+	// Time in-flight requests and jobs get to finish after SIGTERM/SIGINT
+	ShutdownTimeout time.Duration
+}
+
+type LogConfig struct {
+	Level          string
+	Format         string
+	File           string
+	FileMaxMB      int
+	FileMaxBackups int
+	Syslog         string
+}
+
+type TracingConfig struct {
+	// OTLP/HTTP endpoint, spans aren't exported if empty
+	Endpoint    string
+	ServiceName string
+}
+
+// Decoded from base64url
+type KeysConfig struct {
+	KEK          []byte
+	DBSecret     []byte
+	JWTSecret    []byte
+	SignupSecret []byte
+	KeyRefSecret []byte
+	// USERNAME_SECRET, hashes clientnames
+	UsernameSecret []byte
+}
+
+type MasterAdminConfig struct {
+	Username string
+	Password string
+}
+
+type OIDCConfig struct {
+	Issuer      string
+	Audience    string
+	JWKSFile    string
+	ClientClaim string
+	// Clientnames that may log in with an ID token, any other client (admins included) is refused
+	Clients []string
+	// Upper bound for exp - iat of accepted ID tokens
+	MaxTokenLifetime time.Duration
+}
@@ -1,3 +0,0 @@
-package context
-
-type KmsConfig map[string]string
@@ -5,13 +5,17 @@ import (
 	"fmt"
 	c "kms/internal/bootstrap/context"
 	"log"
+	"strings"
 
 	_ "github.com/lib/pq"
 )
 
-func ConnectDatabase(cfg c.KmsConfig) (*sql.DB, error) {
+func ConnectDatabase(cfg *c.Config) (*sql.DB, error) {
 	connStr := fmt.Sprintf("port=%v user=%v password=%v dbname=%v sslmode=%v",
-		cfg["DB_PORT"], cfg["DB_USER"], cfg["DB_PASSWORD"], cfg["DB_NAME"], cfg["DB_SSLMODE"])
+		cfg.DB.Port, quoteConnValue(cfg.DB.User), quoteConnValue(cfg.DB.Password), quoteConnValue(cfg.DB.Name), cfg.DB.SSLMode)
+	if cfg.DB.Host != "" {
+		connStr += " host=" + quoteConnValue(cfg.DB.Host)
+	}
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
 		return db, err
@@ -24,3 +28,8 @@ func ConnectDatabase(cfg c.KmsConfig) (*sql.DB, error) {
 	log.Println("Succesfully connected to database")
 	return db, nil
 }
+
+// Values in a connection string are single quoted, so passwords can hold spaces
+func quoteConnValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
@@ -6,12 +6,14 @@ import (
 )
 
 func TestConnectDatabase_InvalidConfig(t *testing.T) {
-	cfg := c.KmsConfig{
-		"DB_PORT":     "5432",
-		"DB_USER":     "invalid_client",
-		"DB_PASSWORD": "invalid_pass",
-		"DB_NAME":     "invalid_db",
-		"DB_SSLMODE":  "disable",
+	cfg := &c.Config{
+		DB: c.DBConfig{
+			Port:     5432,
+			User:     "invalid_client",
+			Password: "invalid_pass",
+			Name:     "invalid_db",
+			SSLMode:  "disable",
+		},
 	}
 
 	db, err := ConnectDatabase(cfg)
@@ -1,7 +1,6 @@
 package bootstrap
 
 import (
-	b64 "encoding/base64"
 	"fmt"
 	c "kms/internal/bootstrap/context"
 )
@@ -14,42 +13,32 @@ type StaticKeyManager struct {
 	HashKeys_  map[string][]byte
 }
 
-func InitStaticKeyManager(cfg c.KmsConfig) (*StaticKeyManager, error) {
-	jwtKey, err := b64.RawURLEncoding.DecodeString(cfg["JWT_SECRET"])
-	if err != nil {
-		return nil, err
-	}
-	signupKey, err := b64.RawURLEncoding.DecodeString(cfg["SIGNUP_SECRET"])
-	if err != nil {
-		return nil, err
-	}
-	kek, err := b64.RawURLEncoding.DecodeString(cfg["KEK"])
-	if err != nil {
-		return nil, err
-	}
-	dbKey, err := b64.RawURLEncoding.DecodeString(cfg["DB_SECRET"])
-	if err != nil {
-		return nil, err
-	}
-	keyRefKey, err := b64.RawURLEncoding.DecodeString(cfg["KEY_REF_SECRET"])
-	if err != nil {
-		return nil, err
-	}
-	clientnameKey, err := b64.RawURLEncoding.DecodeString(cfg["USERNAME_SECRET"])
-	if err != nil {
-		return nil, err
+// Keys are decoded and validated by LoadKmsConfig
+func InitStaticKeyManager(cfg *c.Config) (*StaticKeyManager, error) {
+	keys := cfg.Keys
+	for name, key := range map[string][]byte{
+		"JWT_SECRET":      keys.JWTSecret,
+		"SIGNUP_SECRET":   keys.SignupSecret,
+		"KEK":             keys.KEK,
+		"DB_SECRET":       keys.DBSecret,
+		"KEY_REF_SECRET":  keys.KeyRefSecret,
+		"USERNAME_SECRET": keys.UsernameSecret,
+	} {
+		if len(key) == 0 {
+			return nil, fmt.Errorf("%s is not set", name)
+		}
 	}
 
 	hashKeys := map[string][]byte{
-		"keyReference": keyRefKey,
-		"clientname":     clientnameKey,
+		"keyReference": keys.KeyRefSecret,
+		"clientname":     keys.UsernameSecret,
 	}
 
 	return &StaticKeyManager{
-		JwtKey_:    jwtKey,
-		SignupKey_: signupKey,
-		KEK_:       kek,
-		DBKey_:     dbKey,
+		JwtKey_:    keys.JWTSecret,
+		SignupKey_: keys.SignupSecret,
+		KEK_:       keys.KEK,
+		DBKey_:     keys.DBSecret,
 		HashKeys_:  hashKeys,
 	}, nil
 }
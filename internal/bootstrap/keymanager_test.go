@@ -1,25 +1,23 @@
 package bootstrap
 
 import (
-	"encoding/base64"
 	c "kms/internal/bootstrap/context"
 	"testing"
 )
 
-func mustB64(s string) string {
-	return base64.RawURLEncoding.EncodeToString([]byte(s))
+func testKeys() c.KeysConfig {
+	return c.KeysConfig{
+		JWTSecret:      []byte("jwt"),
+		SignupSecret:   []byte("signup"),
+		KEK:            []byte("kek"),
+		DBSecret:       []byte("db"),
+		KeyRefSecret:   []byte("keyref"),
+		UsernameSecret: []byte("uname"),
+	}
 }
 
 func TestInitStaticKeyManager_Success(t *testing.T) {
-	cfg := c.KmsConfig{
-		"JWT_SECRET":      mustB64("jwt"),
-		"SIGNUP_SECRET":   mustB64("signup"),
-		"KEK":             mustB64("kek"),
-		"DB_SECRET":       mustB64("db"),
-		"KEY_REF_SECRET":  mustB64("keyref"),
-		"USERNAME_SECRET": mustB64("uname"),
-	}
-	km, err := InitStaticKeyManager(cfg)
+	km, err := InitStaticKeyManager(&c.Config{Keys: testKeys()})
 	if err != nil {
 		t.Fatalf("InitStaticKeyManager failed: %v", err)
 	}
@@ -45,31 +43,17 @@ func TestInitStaticKeyManager_Success(t *testing.T) {
 	}
 }
 
-func TestInitStaticKeyManager_BadBase64(t *testing.T) {
-	cfg := c.KmsConfig{
-		"JWT_SECRET":      "notbase64",
-		"SIGNUP_SECRET":   mustB64("signup"),
-		"KEK":             mustB64("kek"),
-		"DB_SECRET":       mustB64("db"),
-		"KEY_REF_SECRET":  mustB64("keyref"),
-		"USERNAME_SECRET": mustB64("uname"),
-	}
-	_, err := InitStaticKeyManager(cfg)
+func TestInitStaticKeyManager_MissingKey(t *testing.T) {
+	keys := testKeys()
+	keys.JWTSecret = nil
+	_, err := InitStaticKeyManager(&c.Config{Keys: keys})
 	if err == nil {
-		t.Error("expected error for bad base64 JWT_SECRET, got nil")
+		t.Error("expected error for missing JWT_SECRET, got nil")
 	}
 }
 
 func TestStaticKeyManager_HashKey_NotFound(t *testing.T) {
-	cfg := c.KmsConfig{
-		"JWT_SECRET":      mustB64("jwt"),
-		"SIGNUP_SECRET":   mustB64("signup"),
-		"KEK":             mustB64("kek"),
-		"DB_SECRET":       mustB64("db"),
-		"KEY_REF_SECRET":  mustB64("keyref"),
-		"USERNAME_SECRET": mustB64("uname"),
-	}
-	km, err := InitStaticKeyManager(cfg)
+	km, err := InitStaticKeyManager(&c.Config{Keys: testKeys()})
 	if err != nil {
 		t.Fatalf("InitStaticKeyManager failed: %v", err)
 	}
@@ -0,0 +1,196 @@
+package bootstrap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Receives formatted entries, level as in mapLogLevel. Calls are serialized by the logger.
+type LogSink interface {
+	WriteEntry(level int, line []byte) error
+	Close() error
+}
+
+func closeSinks(sinks []LogSink) error {
+	var errs []error
+	for _, sink := range sinks {
+		errs = append(errs, sink.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// e.g. os.Stdout, not closed
+type WriterSink struct {
+	w io.Writer
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) WriteEntry(level int, line []byte) error {
+	_, err := s.w.Write(line)
+	return err
+}
+
+func (s *WriterSink) Close() error {
+	return nil
+}
+
+// Once a write would exceed maxBytes, path is moved to path.1 (path.1 to path.2, ...) and the oldest backup dropped
+type RotatingFileSink struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	file *os.File
+	size int64
+}
+
+func NewRotatingFileSink(path string, maxBytes int64, maxBackups int) (*RotatingFileSink, error) {
+	s := &RotatingFileSink{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *RotatingFileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+func (s *RotatingFileSink) WriteEntry(level int, line []byte) error {
+	if s.size > 0 && s.size+int64(len(line)) > s.maxBytes {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+func (s *RotatingFileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+
+	backup := func(i int) string { return s.path + "." + strconv.Itoa(i) }
+	if s.maxBackups == 0 {
+		if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	} else {
+		os.Remove(backup(s.maxBackups))
+		for i := s.maxBackups - 1; i >= 1; i-- {
+			if err := os.Rename(backup(i), backup(i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+		if err := os.Rename(s.path, backup(1)); err != nil {
+			return err
+		}
+	}
+	return s.open()
+}
+
+func (s *RotatingFileSink) Close() error {
+	return s.file.Close()
+}
+
+// RFC 5424 messages to a syslog daemon, addr is e.g. 'unixgram:///dev/log', 'udp://host:514' or 'tcp://host:514'.
+// Reconnects once if a write fails.
+type SyslogSink struct {
+	network  string
+	address  string
+	appName  string
+	hostname string
+
+	conn net.Conn
+}
+
+// local0
+const syslogFacility = 16
+
+func NewSyslogSink(addr, appName string) (*SyslogSink, error) {
+	network, address, ok := strings.Cut(addr, "://")
+	if !ok || address == "" {
+		return nil, fmt.Errorf("invalid syslog address: %v", addr)
+	}
+	switch network {
+	case "unix", "unixgram", "udp", "tcp":
+	default:
+		return nil, fmt.Errorf("unsupported syslog network: %v", network)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "-"
+	}
+	s := &SyslogSink{network: network, address: address, appName: appName, hostname: hostname}
+	if err := s.dial(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SyslogSink) dial() error {
+	conn, err := net.DialTimeout(s.network, s.address, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	s.conn = conn
+	return nil
+}
+
+// Our levels are the syslog severities in reverse, debug (0) is 7, emergency (7) is 0
+func (s *SyslogSink) format(level int, line []byte) []byte {
+	priority := syslogFacility*8 + (7 - level)
+	header := fmt.Sprintf("<%d>1 %s %s %s %d - - ", priority, time.Now().UTC().Format(time.RFC3339), s.hostname, s.appName, os.Getpid())
+	msg := append([]byte(header), line...)
+	// stream transports are newline framed
+	if s.network == "tcp" || s.network == "unix" {
+		return msg
+	}
+	return []byte(strings.TrimSuffix(string(msg), "\n"))
+}
+
+func (s *SyslogSink) WriteEntry(level int, line []byte) error {
+	msg := s.format(level, line)
+	if s.conn != nil {
+		if _, err := s.conn.Write(msg); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	if err := s.dial(); err != nil {
+		return err
+	}
+	_, err := s.conn.Write(msg)
+	return err
+}
+
+func (s *SyslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
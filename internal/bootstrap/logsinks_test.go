@@ -0,0 +1,91 @@
+package bootstrap
+
+import (
+	"kms/internal/test"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kms.log")
+	sink, err := NewRotatingFileSink(path, 10, 2)
+	test.RequireErrNil(t, err)
+	defer sink.Close()
+
+	// every entry after the first exceeds 10 bytes, so each one rotates
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		test.RequireErrNil(t, sink.WriteEntry(1, []byte(line)))
+	}
+
+	for file, want := range map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	} {
+		got, err := os.ReadFile(file)
+		test.RequireErrNil(t, err)
+		if string(got) != want {
+			t.Errorf("%s: expected %q, got %q", filepath.Base(file), want, got)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 backups, got %v", err)
+	}
+
+	info, err := os.Stat(path)
+	test.RequireErrNil(t, err)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+}
+
+func TestRotatingFileSink_Append(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kms.log")
+	test.RequireErrNil(t, os.WriteFile(path, []byte("existing\n"), 0600))
+
+	sink, err := NewRotatingFileSink(path, 1<<20, 1)
+	test.RequireErrNil(t, err)
+	test.RequireErrNil(t, sink.WriteEntry(1, []byte("new\n")))
+	test.RequireErrNil(t, sink.Close())
+
+	got, err := os.ReadFile(path)
+	test.RequireErrNil(t, err)
+	if string(got) != "existing\nnew\n" {
+		t.Errorf("expected appended entry, got %q", got)
+	}
+}
+
+func TestSyslogSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	test.RequireErrNil(t, err)
+	defer conn.Close()
+
+	sink, err := NewSyslogSink("udp://"+conn.LocalAddr().String(), "kms")
+	test.RequireErrNil(t, err)
+	defer sink.Close()
+
+	// error (4) -> severity 3, local0 -> 16*8+3
+	test.RequireErrNil(t, sink.WriteEntry(4, []byte(`{"msg":"boom"}`+"\n")))
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	test.RequireErrNil(t, err)
+	msg := string(buf[:n])
+	if !strings.HasPrefix(msg, "<131>1 ") || !strings.HasSuffix(msg, ` kms `+strconv.Itoa(os.Getpid())+` - - {"msg":"boom"}`) {
+		t.Errorf("unexpected syslog message %q", msg)
+	}
+}
+
+func TestNewSyslogSink_InvalidAddress(t *testing.T) {
+	for _, addr := range []string{"/dev/log", "http://localhost:514", "udp://"} {
+		if _, err := NewSyslogSink(addr, "kms"); err == nil {
+			t.Errorf("%s: expected error, got nil", addr)
+		}
+	}
+}
@@ -1,12 +1,17 @@
 package bootstrap
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"os"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/lib/pq"
 )
 
 func MigrateUp(db *sql.DB, path string) error {
@@ -38,3 +43,41 @@ func MigrateDown(db *sql.DB, path string) error {
 	}
 	return m.Down()
 }
+
+// Version of the last migration in path, what the schema should be at after MigrateUp
+func LatestMigration(path string) (uint, error) {
+	src, err := source.Open(fmt.Sprintf("file://%s", path))
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	version, err := src.First()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
+
+// Reads migrate's version table directly, its driver holds on to a connection and closes the pool with it.
+// ok is false if no migration ran yet.
+func SchemaVersion(ctx context.Context, db *sql.DB) (version uint, dirty, ok bool, err error) {
+	query := "SELECT version, dirty FROM " + pq.QuoteIdentifier(postgres.DefaultMigrationsTable) + " LIMIT 1"
+	err = db.QueryRowContext(ctx, query).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, false, nil
+	}
+	if err != nil {
+		return 0, false, false, err
+	}
+	return version, dirty, true, nil
+}
@@ -0,0 +1,28 @@
+package bootstrap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLatestMigration(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"00001_init.up.sql", "00001_init.down.sql", "00003_keys.up.sql", "00003_keys.down.sql", "00002_clients.up.sql"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("SELECT 1;"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	version, err := LatestMigration(dir)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if version != 3 {
+		t.Errorf("expected version 3, got %d", version)
+	}
+
+	if _, err := LatestMigration(t.TempDir()); err == nil {
+		t.Error("expected error without migrations, got nil")
+	}
+}
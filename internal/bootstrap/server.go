@@ -0,0 +1,22 @@
+package bootstrap
+
+import (
+	"crypto/tls"
+	"fmt"
+	c "kms/internal/bootstrap/context"
+	"net/http"
+)
+
+// Listens on all interfaces at the configured port, with its limits
+func NewServer(serverCfg c.ServerConfig, handler http.Handler, tlsCfg *tls.Config) *http.Server {
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%d", serverCfg.Port),
+		Handler:           handler,
+		TLSConfig:         tlsCfg,
+		ReadHeaderTimeout: serverCfg.ReadHeaderTimeout,
+		ReadTimeout:       serverCfg.ReadTimeout,
+		WriteTimeout:      serverCfg.WriteTimeout,
+		IdleTimeout:       serverCfg.IdleTimeout,
+		MaxHeaderBytes:    serverCfg.MaxHeaderBytes,
+	}
+}
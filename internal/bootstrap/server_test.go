@@ -0,0 +1,27 @@
+package bootstrap
+
+import (
+	c "kms/internal/bootstrap/context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewServer(t *testing.T) {
+	serverCfg := c.ServerConfig{
+		Port:              8443,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		MaxHeaderBytes:    64 << 10,
+	}
+
+	server := NewServer(serverCfg, http.NotFoundHandler(), nil)
+	if server.Addr != ":8443" {
+		t.Errorf("expected :8443, got %s", server.Addr)
+	}
+	if server.ReadHeaderTimeout != 10*time.Second || server.WriteTimeout != 60*time.Second || server.MaxHeaderBytes != 64<<10 {
+		t.Errorf("expected limits on the server, got %+v", server)
+	}
+}
@@ -0,0 +1,254 @@
+package bootstrap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	c "kms/internal/bootstrap/context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	LogFormatJSON   = "json"
+	LogFormatLogfmt = "logfmt"
+)
+
+var levelNames = []string{"debug", "info", "notice", "warn", "error", "critical", "alert", "emergency"}
+
+// One entry per line with time, level, msg, caller and requestId (if given) first, written to every sink.
+// Sensitive fields and anything that looks like a JWT are redacted.
+type StructuredLogger struct {
+	LogLevel int
+	Format   string
+	Sinks    []LogSink
+
+	mu sync.Mutex
+	// after an Emergency entry, os.Exit outside of tests
+	exit func(int)
+}
+
+func NewStructuredLogger(logLevel, format string, sinks ...LogSink) (*StructuredLogger, error) {
+	lvl, err := mapLogLevel(logLevel)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		format = LogFormatJSON
+	}
+	if format != LogFormatJSON && format != LogFormatLogfmt {
+		return nil, fmt.Errorf("unknown log format: %v", format)
+	}
+	return &StructuredLogger{
+		LogLevel: lvl,
+		Format:   format,
+		Sinks:    sinks,
+		exit:     os.Exit,
+	}, nil
+}
+
+// Logs to stdout and, if set, to LOG_FILE (rotated at LOG_FILE_MAX_MB, keeping LOG_FILE_MAX_BACKUPS) and LOG_SYSLOG
+func InitLogger(cfg *c.Config) (*StructuredLogger, error) {
+	sinks := []LogSink{NewWriterSink(os.Stdout)}
+
+	if cfg.Log.File != "" {
+		file, err := NewRotatingFileSink(cfg.Log.File, int64(cfg.Log.FileMaxMB)<<20, cfg.Log.FileMaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, file)
+	}
+
+	if cfg.Log.Syslog != "" {
+		syslog, err := NewSyslogSink(cfg.Log.Syslog, "kms")
+		if err != nil {
+			closeSinks(sinks)
+			return nil, err
+		}
+		sinks = append(sinks, syslog)
+	}
+
+	logger, err := NewStructuredLogger(cfg.Log.Level, cfg.Log.Format, sinks...)
+	if err != nil {
+		closeSinks(sinks)
+		return nil, err
+	}
+	return logger, nil
+}
+
+func (l *StructuredLogger) Debug(msg string, args ...any)    { l.log(0, msg, args) }
+func (l *StructuredLogger) Info(msg string, args ...any)     { l.log(1, msg, args) }
+func (l *StructuredLogger) Notice(msg string, args ...any)   { l.log(2, msg, args) }
+func (l *StructuredLogger) Warn(msg string, args ...any)     { l.log(3, msg, args) }
+func (l *StructuredLogger) Error(msg string, args ...any)    { l.log(4, msg, args) }
+func (l *StructuredLogger) Critical(msg string, args ...any) { l.log(5, msg, args) }
+func (l *StructuredLogger) Alert(msg string, args ...any)    { l.log(6, msg, args) }
+
+// Logs and exits, like log.Fatalf but the sinks are closed first
+func (l *StructuredLogger) Emergency(msg string, args ...any) {
+	l.log(7, msg, args)
+	l.Close()
+	l.exit(1)
+}
+
+func (l *StructuredLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return closeSinks(l.Sinks)
+}
+
+type field struct {
+	key   string
+	value any
+}
+
+func (l *StructuredLogger) log(level int, msg string, args []any) {
+	if level < l.LogLevel {
+		return
+	}
+
+	fields := []field{
+		{"time", time.Now().UTC().Format(time.RFC3339Nano)},
+		{"level", levelNames[level]},
+		{"msg", msg},
+		{"caller", caller(3)},
+	}
+	fields = append(fields, argFields(args)...)
+	for i := range fields {
+		fields[i].value = redact(fields[i].key, fields[i].value)
+	}
+
+	var line []byte
+	if l.Format == LogFormatLogfmt {
+		line = encodeLogfmt(fields)
+	} else {
+		line = encodeJSON(fields)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, sink := range l.Sinks {
+		if err := sink.WriteEntry(level, line); err != nil {
+			fmt.Fprintf(os.Stderr, "log sink failed: %v\n", err)
+		}
+	}
+}
+
+// requestId is moved to the front, a trailing key without value is kept under '!BADKEY'
+func argFields(args []any) []field {
+	var fields []field
+	var requestId *field
+	for i := 0; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			fields = append(fields, field{"!BADKEY", args[i]})
+			break
+		}
+		f := field{fmt.Sprint(args[i]), args[i+1]}
+		if f.key == "requestId" && requestId == nil {
+			requestId = &f
+			continue
+		}
+		fields = append(fields, f)
+	}
+	if requestId != nil {
+		fields = append([]field{*requestId}, fields...)
+	}
+	return fields
+}
+
+// e.g. 'auth/service.go:42'
+func caller(skip int) string {
+	_, file, line, ok := runtime.Caller(skip)
+	if !ok {
+		return "unknown"
+	}
+	return filepath.Base(filepath.Dir(file)) + "/" + filepath.Base(file) + ":" + strconv.Itoa(line)
+}
+
+const redacted = "[REDACTED]"
+
+// Matched against the lower case key without '_' and '-', also as suffix, e.g. 'signupToken' or 'DB_PASSWORD'
+var sensitiveKeys = []string{"password", "secret", "dek", "kek", "jwt", "token", "otp", "apikey", "authorization", "privatekey", "recoverycodes"}
+
+var jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+
+func isSensitiveKey(key string) bool {
+	normalized := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+	for _, sensitive := range sensitiveKeys {
+		if strings.HasSuffix(normalized, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// Errors and Stringers are formatted first, so JWTs wrapped into errors are caught as well
+func redact(key string, value any) any {
+	if isSensitiveKey(key) {
+		return redacted
+	}
+	switch v := value.(type) {
+	case time.Time:
+		return v
+	case string:
+		return jwtPattern.ReplaceAllString(v, redacted)
+	case error:
+		return jwtPattern.ReplaceAllString(v.Error(), redacted)
+	case fmt.Stringer:
+		return jwtPattern.ReplaceAllString(v.String(), redacted)
+	case []byte:
+		return redacted
+	}
+	return value
+}
+
+func encodeJSON(fields []field) []byte {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, f := range fields {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.Write(marshalJSON(f.key))
+		b.WriteByte(':')
+		b.Write(marshalJSON(f.value))
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+// Not escaped as HTML, values json can't encode (e.g. funcs) are formatted with fmt
+func marshalJSON(value any) []byte {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		b.Reset()
+		enc.Encode(fmt.Sprint(value))
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n"))
+}
+
+func encodeLogfmt(fields []field) []byte {
+	var b bytes.Buffer
+	for i, f := range fields {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(f.key)
+		b.WriteByte('=')
+		value := fmt.Sprint(f.value)
+		if value == "" || strings.ContainsAny(value, " =\"\t\n") {
+			value = strconv.Quote(value)
+		}
+		b.WriteString(value)
+	}
+	b.WriteByte('\n')
+	return b.Bytes()
+}
@@ -0,0 +1,30 @@
+package bootstrap
+
+import (
+	"crypto/tls"
+)
+
+// Client certificates are optional, they're only requested when MTLS_CA_FILE (PEM bundle of trusted CAs) is set.
+// Verified certificates can log in at '/auth/login/certificate', all other endpoints still need a JWT.
+// The certificate and CAs are taken from certs on every handshake, so reloads apply to new connections.
+func InitTLSConfig(certs *CertReloader) *tls.Config {
+	tlsCfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certs.GetCertificate,
+	}
+
+	if certs.ClientCAs() == nil {
+		return tlsCfg
+	}
+
+	tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	// the returned config replaces the one http.Server sets up, which would offer h2
+	tlsCfg.NextProtos = []string{"h2", "http/1.1"}
+	tlsCfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		clientCfg := tlsCfg.Clone()
+		clientCfg.GetConfigForClient = nil
+		clientCfg.ClientCAs = certs.ClientCAs()
+		return clientCfg, nil
+	}
+	return tlsCfg
+}
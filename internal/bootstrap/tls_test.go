@@ -0,0 +1,133 @@
+package bootstrap
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"kms/internal/test/mocks"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Self-signed CA for commonName, written to dir/ca.pem
+func writeCA(t *testing.T, dir, commonName string, modTime time.Time) string {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate failed: %v", err)
+	}
+	path := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("chtimes failed: %v", err)
+	}
+	return path
+}
+
+// The ClientCAs a new connection is verified with
+func handshakeCAs(t *testing.T, tlsCfg *tls.Config) *x509.CertPool {
+	t.Helper()
+	clientCfg, err := tlsCfg.GetConfigForClient(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if clientCfg.ClientAuth != tls.VerifyClientCertIfGiven || clientCfg.GetCertificate == nil {
+		t.Errorf("expected optional verified client certificates")
+	}
+	return clientCfg.ClientCAs
+}
+
+func TestInitTLSConfig_NoMTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCertPair(t, dir, "server", time.Now())
+	certs, err := NewCertReloader(certFile, keyFile, "", mocks.NewLoggerMock())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tlsCfg := InitTLSConfig(certs)
+	if tlsCfg.ClientAuth != tls.NoClientCert || tlsCfg.ClientCAs != nil || tlsCfg.GetConfigForClient != nil {
+		t.Errorf("expected no client certificates to be requested")
+	}
+	if tlsCfg.GetCertificate == nil {
+		t.Errorf("expected the server certificate to come from the reloader")
+	}
+}
+
+func TestInitTLSConfig_MTLS(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	certFile, keyFile := writeCertPair(t, dir, "server", now)
+	caFile := writeCA(t, dir, "mesh ca", now)
+
+	certs, err := NewCertReloader(certFile, keyFile, caFile, mocks.NewLoggerMock())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tlsCfg := InitTLSConfig(certs)
+	if tlsCfg.ClientAuth != tls.VerifyClientCertIfGiven {
+		t.Errorf("expected optional verified client certificates")
+	}
+	old := handshakeCAs(t, tlsCfg)
+	if old == nil || !old.Equal(certs.ClientCAs()) {
+		t.Fatalf("expected the loaded CAs, got %v", old)
+	}
+
+	// new connections use the replaced bundle
+	writeCA(t, dir, "rotated ca", now.Add(time.Second))
+	if err := certs.Reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rotated := handshakeCAs(t, tlsCfg); rotated.Equal(old) || !rotated.Equal(certs.ClientCAs()) {
+		t.Errorf("expected the rotated CAs")
+	}
+}
+
+func TestCertReloader_InvalidCABundle(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	certFile, keyFile := writeCertPair(t, dir, "server", now)
+	caFile := writeCA(t, dir, "mesh ca", now)
+
+	certs, err := NewCertReloader(certFile, keyFile, caFile, mocks.NewLoggerMock())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	loaded := certs.ClientCAs()
+
+	// a broken bundle keeps the current CAs
+	if err := os.WriteFile(caFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := certs.Reload(); err == nil {
+		t.Errorf("expected error for bundle without certificates")
+	}
+	if !certs.ClientCAs().Equal(loaded) {
+		t.Errorf("expected the current CAs to be kept")
+	}
+
+	if _, err := NewCertReloader(certFile, keyFile, caFile, mocks.NewLoggerMock()); err == nil {
+		t.Errorf("expected error for bundle without certificates")
+	}
+	if _, err := NewCertReloader(certFile, keyFile, caFile+".missing", mocks.NewLoggerMock()); err == nil {
+		t.Errorf("expected error for missing bundle")
+	}
+}
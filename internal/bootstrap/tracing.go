@@ -0,0 +1,23 @@
+package bootstrap
+
+import (
+	c "kms/internal/bootstrap/context"
+	"kms/pkg/tracing"
+)
+
+const defaultServiceName = "kms"
+
+// Exports sampled spans to OTEL_EXPORTER_OTLP_ENDPOINT (OTLP/HTTP, e.g. http://localhost:4318), nil if it's not set.
+// Without it spans are still created, so request logs carry a traceId and traceparent headers are passed on.
+func InitTracing(cfg *c.Config, logger c.Logger) *tracing.OTLPExporter {
+	if cfg.Tracing.Endpoint == "" {
+		return nil
+	}
+
+	exporter := tracing.NewOTLPExporter(cfg.Tracing.Endpoint, cfg.Tracing.ServiceName)
+	exporter.OnError = func(err error) {
+		logger.Warn("Failed to export spans", "error", err)
+	}
+	tracing.SetExporter(exporter)
+	return exporter
+}
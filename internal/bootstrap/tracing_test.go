@@ -0,0 +1,24 @@
+package bootstrap
+
+import (
+	"context"
+	c "kms/internal/bootstrap/context"
+	"kms/internal/test/mocks"
+	"kms/pkg/tracing"
+	"testing"
+)
+
+func TestInitTracing(t *testing.T) {
+	if exporter := InitTracing(&c.Config{}, mocks.NewLoggerMock()); exporter != nil {
+		t.Error("expected no exporter without an endpoint")
+	}
+
+	cfg := &c.Config{Tracing: c.TracingConfig{Endpoint: "http://localhost:4318", ServiceName: "kms"}}
+	exporter := InitTracing(cfg, mocks.NewLoggerMock())
+	defer tracing.SetExporter(nil)
+	defer exporter.Shutdown(context.Background())
+
+	if _, span := tracing.Start(context.Background(), "root"); !span.SpanContext.Sampled {
+		t.Error("expected root spans to be sampled once an exporter is set")
+	}
+}
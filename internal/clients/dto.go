@@ -1,9 +1,54 @@
 package clients
 
+import "time"
+
 type Client struct {
 	ID               int    `json:"id"`
 	Clientname       string `json:"clientname" encrypt:"true"`
-	HashedClientname string `json:"hashedClientname"`
+	HashedClientname string `json:"hashedClientname" context:"true"`
 	Password         string `json:"password"`
 	Role             string `json:"role" encrypt:"true"`
 }
+
+// Bound into the encryption context of the client's encrypted fields, so it must never change
+func (c *Client) EncryptionContextType() string {
+	return "Client"
+}
+
+// Client certificate (mTLS) that authenticates as the client, see auth.CertificateIdentities
+type Certificate struct {
+	ID             int       `json:"id"`
+	ClientId       int       `json:"clientId"`
+	HashedIdentity string    `json:"hashedIdentity"`
+	Pin            string    `json:"pin,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// TOTP enrolment of a client, the secret is encrypted and bound to the client.
+// Recovery codes are stored hashed, see auth.Service.ActivateMfa
+type Mfa struct {
+	ClientId      int       `json:"clientId" context:"true"`
+	Secret        string    `json:"-" encrypt:"true"`
+	Enabled       bool      `json:"enabled"`
+	LastStep      int64     `json:"-"`
+	RecoveryCodes []string  `json:"-"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// Bound into the encryption context of the secret, so it must never change
+func (m *Mfa) EncryptionContextType() string {
+	return "Mfa"
+}
+
+// Long-lived credential of a client, see auth.ParseApiKey for the format
+type ApiKey struct {
+	ID         int        `json:"id"`
+	ClientId   int        `json:"clientId"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	HashedKey  string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
@@ -1,6 +1,7 @@
 package clients
 
 import (
+	"context"
 	c "kms/internal/bootstrap/context"
 	kmsErrors "kms/pkg/errors"
 	pHttp "kms/pkg/http"
@@ -20,11 +21,11 @@ func NewHandler(clientService ClientService, logger c.Logger) *Handler {
 }
 
 type ClientService interface {
-	GetAll() ([]Client, *kmsErrors.AppError)
+	GetAll(ctx context.Context) ([]Client, *kmsErrors.AppError)
 }
 
 func (h *Handler) GetAllDev(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
-	clients, appErr := h.Service.GetAll()
+	clients, appErr := h.Service.GetAll(r.Context())
 	if appErr != nil {
 		return appErr
 	}
@@ -1,6 +1,7 @@
 package clients
 
 import (
+	"context"
 	"kms/internal/test/mocks"
 	kmsErrors "kms/pkg/errors"
 	"net/http/httptest"
@@ -10,7 +11,7 @@ import (
 
 func TestHandler_GetAllDev_Success(t *testing.T) {
 	mockService := NewClientServiceMock()
-	mockService.GetAllFunc = func() ([]Client, *kmsErrors.AppError) {
+	mockService.GetAllFunc = func(ctx context.Context) ([]Client, *kmsErrors.AppError) {
 		return []Client{{
 			ID:         1,
 			Clientname: "client",
@@ -33,7 +34,7 @@ func TestHandler_GetAllDev_Success(t *testing.T) {
 
 func TestHandler_GetAllDev_ServiceError(t *testing.T) {
 	mockService := NewClientServiceMock()
-	mockService.GetAllFunc = func() ([]Client, *kmsErrors.AppError) {
+	mockService.GetAllFunc = func(ctx context.Context) ([]Client, *kmsErrors.AppError) {
 		return nil, kmsErrors.NewAppError(nil, "service error", 500)
 	}
 	mockLogger := mocks.NewLoggerMock()
@@ -1,86 +1,199 @@
 package clients
 
 import (
+	"context"
 	"errors"
 	kmsErrors "kms/pkg/errors"
 )
 
 // Repository mock for Client operations
 type ClientRepositoryMock struct {
-	CreateClientFunc           func(client *Client) (int, error)
-	GetClientFunc              func(id int) (*Client, error)
-	GetAllFunc                 func() ([]Client, error)
-	DeleteFunc                 func(clientId int) error
-	FindByHashedClientnameFunc func(email string) (*Client, error)
-	UpdateRoleFunc             func(id int, role string) error
-	GetRoleFunc                func(id int) (string, error)
+	CreateClientFunc           func(ctx context.Context, client *Client) (int, error)
+	GetClientFunc              func(ctx context.Context, id int) (*Client, error)
+	GetAllFunc                 func(ctx context.Context) ([]Client, error)
+	DeleteFunc                 func(ctx context.Context, clientId int) error
+	FindByHashedClientnameFunc func(ctx context.Context, email string) (*Client, error)
+	UpdateRoleFunc             func(ctx context.Context, id int, role string) error
+	GetRoleFunc                func(ctx context.Context, id int) (string, error)
+	UpdatePasswordFunc         func(ctx context.Context, id int, hashedPassword string) error
+	CreateCertificateFunc      func(ctx context.Context, cert *Certificate) (int, error)
+	FindCertificateFunc        func(ctx context.Context, hashedIdentity string) (*Certificate, error)
+	GetCertificatesFunc        func(ctx context.Context, clientId int) ([]Certificate, error)
+	DeleteCertificateFunc      func(ctx context.Context, clientId, id int) error
+	SaveMfaFunc                func(ctx context.Context, mfa *Mfa) error
+	GetMfaFunc                 func(ctx context.Context, clientId int) (*Mfa, error)
+	UseMfaFunc                 func(ctx context.Context, prev *Mfa, lastStep int64, recoveryCodes []string) error
+	DeleteMfaFunc              func(ctx context.Context, clientId int) error
+	CreateApiKeyFunc           func(ctx context.Context, apiKey *ApiKey) (int, error)
+	FindApiKeyFunc             func(ctx context.Context, prefix string) (*ApiKey, error)
+	GetApiKeysFunc             func(ctx context.Context, clientId int) ([]ApiKey, error)
+	DeleteApiKeyFunc           func(ctx context.Context, clientId, id int) error
+	TouchApiKeyFunc            func(ctx context.Context, id int) error
 }
 
 func NewClientRepositoryMock() *ClientRepositoryMock {
 	return &ClientRepositoryMock{}
 }
 
-func (m *ClientRepositoryMock) CreateClient(client *Client) (int, error) {
+func (m *ClientRepositoryMock) CreateClient(ctx context.Context, client *Client) (int, error) {
 	if m.CreateClientFunc != nil {
-		return m.CreateClientFunc(client)
+		return m.CreateClientFunc(ctx, client)
 	}
 	return 0, errors.New("CreateClientFunc not implemented in mock")
 }
 
-func (m *ClientRepositoryMock) GetClient(id int) (*Client, error) {
+func (m *ClientRepositoryMock) GetClient(ctx context.Context, id int) (*Client, error) {
 	if m.GetClientFunc != nil {
-		return m.GetClientFunc(id)
+		return m.GetClientFunc(ctx, id)
 	}
 	return nil, nil
 }
 
-func (m *ClientRepositoryMock) GetAll() ([]Client, error) {
+func (m *ClientRepositoryMock) GetAll(ctx context.Context) ([]Client, error) {
 	if m.GetAllFunc != nil {
-		return m.GetAllFunc()
+		return m.GetAllFunc(ctx)
 	}
 	return nil, errors.New("GetAllFunc not implemented in mock")
 }
 
-func (m *ClientRepositoryMock) Delete(clientId int) error {
+func (m *ClientRepositoryMock) Delete(ctx context.Context, clientId int) error {
 	if m.DeleteFunc != nil {
-		return m.DeleteFunc(clientId)
+		return m.DeleteFunc(ctx, clientId)
 	}
 	return errors.New("DeleteFunc not implemented in mock")
 }
 
-func (m *ClientRepositoryMock) FindByHashedClientname(email string) (*Client, error) {
+func (m *ClientRepositoryMock) FindByHashedClientname(ctx context.Context, email string) (*Client, error) {
 	if m.FindByHashedClientnameFunc != nil {
-		return m.FindByHashedClientnameFunc(email)
+		return m.FindByHashedClientnameFunc(ctx, email)
 	}
 	return nil, errors.New("FindByHashedClientnameFunc not implemented in mock")
 }
 
-func (m *ClientRepositoryMock) UpdateRole(id int, role string) error {
+func (m *ClientRepositoryMock) UpdateRole(ctx context.Context, id int, role string) error {
 	if m.UpdateRoleFunc != nil {
-		return m.UpdateRoleFunc(id, role)
+		return m.UpdateRoleFunc(ctx, id, role)
 	}
 	return errors.New("UpdateRoleFunc not implemented in mock")
 }
 
-func (m *ClientRepositoryMock) GetRole(id int) (string, error) {
+func (m *ClientRepositoryMock) GetRole(ctx context.Context, id int) (string, error) {
 	if m.GetRoleFunc != nil {
-		return m.GetRoleFunc(id)
+		return m.GetRoleFunc(ctx, id)
 	}
 	return "", errors.New("GetRoleFunc not implemented in mock")
 }
 
+func (m *ClientRepositoryMock) UpdatePassword(ctx context.Context, id int, hashedPassword string) error {
+	if m.UpdatePasswordFunc != nil {
+		return m.UpdatePasswordFunc(ctx, id, hashedPassword)
+	}
+	return errors.New("UpdatePasswordFunc not implemented in mock")
+}
+
+func (m *ClientRepositoryMock) CreateCertificate(ctx context.Context, cert *Certificate) (int, error) {
+	if m.CreateCertificateFunc != nil {
+		return m.CreateCertificateFunc(ctx, cert)
+	}
+	return 0, errors.New("CreateCertificateFunc not implemented in mock")
+}
+
+func (m *ClientRepositoryMock) FindCertificate(ctx context.Context, hashedIdentity string) (*Certificate, error) {
+	if m.FindCertificateFunc != nil {
+		return m.FindCertificateFunc(ctx, hashedIdentity)
+	}
+	return nil, errors.New("FindCertificateFunc not implemented in mock")
+}
+
+func (m *ClientRepositoryMock) GetCertificates(ctx context.Context, clientId int) ([]Certificate, error) {
+	if m.GetCertificatesFunc != nil {
+		return m.GetCertificatesFunc(ctx, clientId)
+	}
+	return nil, errors.New("GetCertificatesFunc not implemented in mock")
+}
+
+func (m *ClientRepositoryMock) DeleteCertificate(ctx context.Context, clientId, id int) error {
+	if m.DeleteCertificateFunc != nil {
+		return m.DeleteCertificateFunc(ctx, clientId, id)
+	}
+	return errors.New("DeleteCertificateFunc not implemented in mock")
+}
+
+func (m *ClientRepositoryMock) CreateApiKey(ctx context.Context, apiKey *ApiKey) (int, error) {
+	if m.CreateApiKeyFunc != nil {
+		return m.CreateApiKeyFunc(ctx, apiKey)
+	}
+	return 0, errors.New("CreateApiKeyFunc not implemented in mock")
+}
+
+func (m *ClientRepositoryMock) FindApiKey(ctx context.Context, prefix string) (*ApiKey, error) {
+	if m.FindApiKeyFunc != nil {
+		return m.FindApiKeyFunc(ctx, prefix)
+	}
+	return nil, errors.New("FindApiKeyFunc not implemented in mock")
+}
+
+func (m *ClientRepositoryMock) GetApiKeys(ctx context.Context, clientId int) ([]ApiKey, error) {
+	if m.GetApiKeysFunc != nil {
+		return m.GetApiKeysFunc(ctx, clientId)
+	}
+	return nil, errors.New("GetApiKeysFunc not implemented in mock")
+}
+
+func (m *ClientRepositoryMock) DeleteApiKey(ctx context.Context, clientId, id int) error {
+	if m.DeleteApiKeyFunc != nil {
+		return m.DeleteApiKeyFunc(ctx, clientId, id)
+	}
+	return errors.New("DeleteApiKeyFunc not implemented in mock")
+}
+
+func (m *ClientRepositoryMock) TouchApiKey(ctx context.Context, id int) error {
+	if m.TouchApiKeyFunc != nil {
+		return m.TouchApiKeyFunc(ctx, id)
+	}
+	return nil
+}
+
 // Service mock for Client operations
 type ClientServiceMock struct {
-	GetAllFunc func() ([]Client, *kmsErrors.AppError)
+	GetAllFunc func(ctx context.Context) ([]Client, *kmsErrors.AppError)
 }
 
 func NewClientServiceMock() *ClientServiceMock {
 	return &ClientServiceMock{}
 }
 
-func (m *ClientServiceMock) GetAll() ([]Client, *kmsErrors.AppError) {
+func (m *ClientServiceMock) GetAll(ctx context.Context) ([]Client, *kmsErrors.AppError) {
 	if m.GetAllFunc != nil {
-		return m.GetAllFunc()
+		return m.GetAllFunc(ctx)
 	}
 	return nil, kmsErrors.LiftToAppError(errors.New("GetAll not implemented in mock"))
 }
+
+func (m *ClientRepositoryMock) SaveMfa(ctx context.Context, mfa *Mfa) error {
+	if m.SaveMfaFunc != nil {
+		return m.SaveMfaFunc(ctx, mfa)
+	}
+	return errors.New("SaveMfaFunc not implemented in mock")
+}
+
+func (m *ClientRepositoryMock) GetMfa(ctx context.Context, clientId int) (*Mfa, error) {
+	if m.GetMfaFunc != nil {
+		return m.GetMfaFunc(ctx, clientId)
+	}
+	return nil, errors.New("GetMfaFunc not implemented in mock")
+}
+
+func (m *ClientRepositoryMock) UseMfa(ctx context.Context, prev *Mfa, lastStep int64, recoveryCodes []string) error {
+	if m.UseMfaFunc != nil {
+		return m.UseMfaFunc(ctx, prev, lastStep, recoveryCodes)
+	}
+	return errors.New("UseMfaFunc not implemented in mock")
+}
+
+func (m *ClientRepositoryMock) DeleteMfa(ctx context.Context, clientId int) error {
+	if m.DeleteMfaFunc != nil {
+		return m.DeleteMfaFunc(ctx, clientId)
+	}
+	return errors.New("DeleteMfaFunc not implemented in mock")
+}
@@ -1,8 +1,11 @@
 package clients
 
 import (
+	"context"
+	"fmt"
 	c "kms/internal/bootstrap/context"
 	kmsErrors "kms/pkg/errors"
+	"unicode"
 )
 
 type Service struct {
@@ -18,19 +21,46 @@ func NewService(clientRepo ClientRepository, logger c.Logger) *Service {
 }
 
 type ClientRepository interface {
-	CreateClient(client *Client) (int, error)
-	GetClient(id int) (*Client, error)
-	GetAll() ([]Client, error)
-	Delete(clientId int) error
-	FindByHashedClientname(email string) (*Client, error)
-	UpdateRole(id int, role string) error
-	GetRole(id int) (string, error)
+	CreateClient(ctx context.Context, client *Client) (int, error)
+	GetClient(ctx context.Context, id int) (*Client, error)
+	GetAll(ctx context.Context) ([]Client, error)
+	Delete(ctx context.Context, clientId int) error
+	FindByHashedClientname(ctx context.Context, email string) (*Client, error)
+	UpdateRole(ctx context.Context, id int, role string) error
+	GetRole(ctx context.Context, id int) (string, error)
+	UpdatePassword(ctx context.Context, id int, hashedPassword string) error
+	CreateCertificate(ctx context.Context, cert *Certificate) (int, error)
+	FindCertificate(ctx context.Context, hashedIdentity string) (*Certificate, error)
+	GetCertificates(ctx context.Context, clientId int) ([]Certificate, error)
+	DeleteCertificate(ctx context.Context, clientId, id int) error
+	CreateApiKey(ctx context.Context, apiKey *ApiKey) (int, error)
+	FindApiKey(ctx context.Context, prefix string) (*ApiKey, error)
+	GetApiKeys(ctx context.Context, clientId int) ([]ApiKey, error)
+	DeleteApiKey(ctx context.Context, clientId, id int) error
+	TouchApiKey(ctx context.Context, id int) error
+	SaveMfa(ctx context.Context, mfa *Mfa) error
+	GetMfa(ctx context.Context, clientId int) (*Mfa, error)
+	UseMfa(ctx context.Context, prev *Mfa, lastStep int64, recoveryCodes []string) error
+	DeleteMfa(ctx context.Context, clientId int) error
 }
 
-func (s *Service) GetAll() ([]Client, *kmsErrors.AppError) {
-	clients, err := s.ClientRepo.GetAll()
+func (s *Service) GetAll(ctx context.Context) ([]Client, *kmsErrors.AppError) {
+	clients, err := s.ClientRepo.GetAll(ctx)
 	if err != nil {
 		return nil, kmsErrors.MapRepoErr(err)
 	}
 	return clients, nil
 }
+
+// Allow 0-9, a-Z and '-' in clientname
+func ValidateClientname(clientname string) error {
+	if len(clientname) < 4 || len(clientname) > 64 {
+		return fmt.Errorf("clientname length should be between 4 and 64, is %d", len(clientname))
+	}
+	for _, r := range clientname {
+		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-') {
+			return fmt.Errorf("invalid character in clientname (%v): %c", clientname, r)
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package clients
 
 import (
+	"context"
 	"errors"
 	"kms/internal/test/mocks"
 	"testing"
@@ -8,12 +9,12 @@ import (
 
 func TestService_GetAll_Success(t *testing.T) {
 	mockRepo := NewClientRepositoryMock()
-	mockRepo.GetAllFunc = func() ([]Client, error) {
+	mockRepo.GetAllFunc = func(ctx context.Context) ([]Client, error) {
 		return []Client{{Clientname: "testclient"}}, nil
 	}
 	mockLogger := mocks.NewLoggerMock()
 	service := NewService(mockRepo, mockLogger)
-	clients, err := service.GetAll()
+	clients, err := service.GetAll(context.Background())
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -24,13 +25,34 @@ func TestService_GetAll_Success(t *testing.T) {
 
 func TestService_GetAll_Error(t *testing.T) {
 	mockRepo := NewClientRepositoryMock()
-	mockRepo.GetAllFunc = func() ([]Client, error) {
+	mockRepo.GetAllFunc = func(ctx context.Context) ([]Client, error) {
 		return nil, errors.New("repo error")
 	}
 	mockLogger := mocks.NewLoggerMock()
 	service := NewService(mockRepo, mockLogger)
-	_, err := service.GetAll()
+	_, err := service.GetAll(context.Background())
 	if err == nil {
 		t.Fatal("expected an error, got nil")
 	}
 }
+
+func TestValidateClientname(t *testing.T) {
+	tests := []struct {
+		clientname  string
+		expectError bool
+	}{
+		{"validClient", false},
+		{"valid-client123", false},
+		{"invalid@client", true},
+		{"", true},
+		{"abc", true},
+		{"valid-key-with-maximum-length-12345678901234567890123456789012345678901234567890123456789012345678901234567890", true},
+	}
+	for _, tt := range tests {
+		err := ValidateClientname(tt.clientname)
+		if (err != nil) != tt.expectError {
+			t.Errorf("ValidateClientname(%q) error = %v, expectError %v", tt.clientname, err, tt.expectError)
+			continue
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package escrow
+
+import (
+	"fmt"
+)
+
+type ExportRequest struct {
+	ClientId int `json:"clientId"`
+	// Export all keys of the client if empty
+	KeyReferences []string `json:"keyReferences,omitempty"`
+	Algorithm     string   `json:"algorithm"`
+	// base64url PKIX DER public key of the escrow operator
+	PublicKey string `json:"publicKey"`
+}
+
+func (r *ExportRequest) Validate() error {
+	if r.ClientId <= 0 || r.Algorithm == "" || r.PublicKey == "" {
+		return fmt.Errorf("clientId, algorithm and publicKey should be non-empty")
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package escrow
+
+import (
+	"crypto/sha256"
+	b64 "encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// See docs/escrow.md for a description of the format
+const (
+	FormatName    = "kms-escrow"
+	FormatVersion = 2
+	// Files of this version can still be imported, their labels only bind clientname, reference and version
+	legacyFormatVersion = 1
+)
+
+type File struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+	Algorithm string    `json:"algorithm"`
+	// base64url SHA-256 of the recipient's PKIX DER public key
+	Recipient  string  `json:"recipient"`
+	Clientname string  `json:"clientname"`
+	Keys       []Entry `json:"keys"`
+	// base64url SHA-256 of CanonicalBytes
+	Checksum string `json:"checksum"`
+}
+
+type Entry struct {
+	// Only known if the export was requested by key reference, references are stored hashed
+	KeyReference    string    `json:"keyReference,omitempty"`
+	HashedReference string    `json:"hashedReference"`
+	Version         int       `json:"version"`
+	State           string    `json:"state"`
+	Algorithm       string    `json:"algorithm"`
+	CreatedAt       time.Time `json:"createdAt"`
+	// base64url DEK, wrapped for the recipient with the file's Label as OAEP label/additional data
+	WrappedKey string `json:"wrappedKey"`
+}
+
+// Binds a wrapped key to the header and every other field of its entry, so none of them can be edited
+// without the key failing to unwrap. The checksum alone can be recomputed by anyone.
+func (f *File) Label(e *Entry) []byte {
+	if f.Version == legacyFormatVersion {
+		return []byte(strings.Join([]string{
+			FormatName + "/" + strconv.Itoa(legacyFormatVersion),
+			f.Clientname,
+			e.HashedReference,
+			strconv.Itoa(e.Version),
+		}, "\x00"))
+	}
+	return []byte(f.header() + e.line(false))
+}
+
+// Line based encoding of every field except the checksum
+func (f *File) CanonicalBytes() []byte {
+	var sb strings.Builder
+	sb.WriteString(f.header())
+	for _, e := range f.Keys {
+		sb.WriteString(e.line(true))
+	}
+	return []byte(sb.String())
+}
+
+func (f *File) header() string {
+	return fmt.Sprintf("%s/%d\n%s\n%s\n%s\n%s\n",
+		f.Format,
+		f.Version,
+		f.CreatedAt.UTC().Format(time.RFC3339Nano),
+		f.Algorithm,
+		f.Recipient,
+		f.Clientname,
+	)
+}
+
+// The wrapped key itself is left out of its label
+func (e *Entry) line(withWrappedKey bool) string {
+	line := fmt.Sprintf("%s\t%s\t%d\t%s\t%s\t%s",
+		e.KeyReference,
+		e.HashedReference,
+		e.Version,
+		e.State,
+		e.Algorithm,
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	)
+	if withWrappedKey {
+		line += "\t" + e.WrappedKey
+	}
+	return line + "\n"
+}
+
+func (f *File) ComputeChecksum() string {
+	sum := sha256.Sum256(f.CanonicalBytes())
+	return b64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Checks the format and checksum, doesn't unwrap any keys, so only accidental corruption is detected
+func (f *File) Verify() error {
+	if f.Format != FormatName {
+		return fmt.Errorf("unknown format: %v", f.Format)
+	}
+	if f.Version != FormatVersion && f.Version != legacyFormatVersion {
+		return fmt.Errorf("unsupported format version: %d", f.Version)
+	}
+	if f.ComputeChecksum() != f.Checksum {
+		return fmt.Errorf("checksum mismatch, file is corrupted or has been modified")
+	}
+	return nil
+}
+
+func Fingerprint(publicKeyDER []byte) string {
+	sum := sha256.Sum256(publicKeyDER)
+	return b64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,84 @@
+package escrow
+
+import (
+	"kms/internal/test"
+	"testing"
+	"time"
+)
+
+func newTestFile() *File {
+	f := &File{
+		Format:     FormatName,
+		Version:    FormatVersion,
+		CreatedAt:  time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Algorithm:  "ECDH-P256",
+		Recipient:  "recipient",
+		Clientname: "client",
+		Keys: []Entry{
+			{HashedReference: "ref", Version: 1, State: "in-use", WrappedKey: "wrapped"},
+		},
+	}
+	f.Checksum = f.ComputeChecksum()
+	return f
+}
+
+func TestFile_Verify_Success(t *testing.T) {
+	test.RequireErrNil(t, newTestFile().Verify())
+}
+
+func TestFile_Verify_TamperedEntry(t *testing.T) {
+	f := newTestFile()
+	f.Keys[0].Version = 2
+
+	err := f.Verify()
+	test.RequireErrNotNil(t, err)
+	test.RequireErrContains(t, err, "checksum mismatch")
+}
+
+func TestFile_Verify_UnsupportedVersion(t *testing.T) {
+	f := newTestFile()
+	f.Version = 3
+	f.Checksum = f.ComputeChecksum()
+
+	err := f.Verify()
+	test.RequireErrNotNil(t, err)
+	test.RequireErrContains(t, err, "unsupported format version")
+}
+
+func TestFile_Label_BindsFields(t *testing.T) {
+	f := newTestFile()
+	label := string(f.Label(&f.Keys[0]))
+
+	edits := map[string]func(f *File){
+		"clientname":   func(f *File) { f.Clientname = "other" },
+		"createdAt":    func(f *File) { f.CreatedAt = f.CreatedAt.Add(time.Second) },
+		"recipient":    func(f *File) { f.Recipient = "other" },
+		"keyReference": func(f *File) { f.Keys[0].KeyReference = "other" },
+		"version":      func(f *File) { f.Keys[0].Version = 2 },
+		"state":        func(f *File) { f.Keys[0].State = "deprecated" },
+		"algorithm":    func(f *File) { f.Keys[0].Algorithm = "AES-128-GCM" },
+	}
+	for field, edit := range edits {
+		edited := newTestFile()
+		edit(edited)
+		if string(edited.Label(&edited.Keys[0])) == label {
+			t.Errorf("%s: expected the label to change", field)
+		}
+	}
+
+	f.Keys[0].WrappedKey = "other"
+	if string(f.Label(&f.Keys[0])) != label {
+		t.Error("expected the wrapped key to be left out of its label")
+	}
+}
+
+func TestFile_Label_Legacy(t *testing.T) {
+	f := newTestFile()
+	f.Version = legacyFormatVersion
+	f.Checksum = f.ComputeChecksum()
+	test.RequireErrNil(t, f.Verify())
+
+	if got := string(f.Label(&f.Keys[0])); got != "kms-escrow/1\x00client\x00ref\x001" {
+		t.Errorf("unexpected version 1 label %q", got)
+	}
+}
@@ -0,0 +1,50 @@
+package escrow
+
+import (
+	"context"
+	c "kms/internal/bootstrap/context"
+	"kms/internal/httpctx"
+	kmsErrors "kms/pkg/errors"
+	pHttp "kms/pkg/http"
+	"kms/pkg/json"
+	"net/http"
+)
+
+type Handler struct {
+	Service EscrowService
+	Logger  c.Logger
+}
+
+func NewHandler(escrowService EscrowService, logger c.Logger) *Handler {
+	return &Handler{
+		Service: escrowService,
+		Logger:  logger,
+	}
+}
+
+type EscrowService interface {
+	Export(ctx context.Context, req *ExportRequest, adminId string) (*File, *kmsErrors.AppError)
+}
+
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	token, err := httpctx.ExtractToken(r.Context())
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	var body ExportRequest
+	if err := json.ParseBody(r.Body, &body); err != nil {
+		return kmsErrors.NewInvalidBodyError(err)
+	}
+
+	if err := body.Validate(); err != nil {
+		return kmsErrors.NewInvalidBodyError(err)
+	}
+
+	file, appErr := h.Service.Export(r.Context(), &body, token.Payload.Sub)
+	if appErr != nil {
+		return appErr
+	}
+
+	return pHttp.WriteJSON(w, file)
+}
@@ -0,0 +1,54 @@
+package escrow
+
+import (
+	"context"
+	"kms/internal/auth"
+	"kms/internal/httpctx"
+	"kms/internal/test/mocks"
+	kmsErrors "kms/pkg/errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newAdminRequest(body string) *http.Request {
+	req := httptest.NewRequest("POST", "/admin/escrow/export", strings.NewReader(body))
+	ctx := context.WithValue(req.Context(), httpctx.TokenCtxKey, auth.Token{
+		Payload: &auth.TokenPayload{Sub: "admin-id"},
+	})
+	return req.WithContext(ctx)
+}
+
+func TestHandler_Export_Success(t *testing.T) {
+	mockService := NewEscrowServiceMock()
+	mockService.ExportFunc = func(ctx context.Context, req *ExportRequest, adminId string) (*File, *kmsErrors.AppError) {
+		if req.ClientId != 1 || adminId != "admin-id" {
+			t.Errorf("unexpected request: %+v by %s", req, adminId)
+		}
+		return &File{Format: FormatName, Version: FormatVersion, Clientname: "client"}, nil
+	}
+	handler := NewHandler(mockService, mocks.NewLoggerMock())
+
+	rr := httptest.NewRecorder()
+	appErr := handler.Export(rr, newAdminRequest(`{"clientId": 1, "algorithm": "ECDH-P256", "publicKey": "AQID"}`))
+	if appErr != nil {
+		t.Fatalf("handler returned an error: %v", appErr)
+	}
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if !strings.Contains(rr.Body.String(), `"format":"kms-escrow"`) {
+		t.Errorf("expected escrow file in body, got %s", rr.Body.String())
+	}
+}
+
+func TestHandler_Export_InvalidBody(t *testing.T) {
+	handler := NewHandler(NewEscrowServiceMock(), mocks.NewLoggerMock())
+
+	rr := httptest.NewRecorder()
+	appErr := handler.Export(rr, newAdminRequest(`{"clientId": 1}`))
+	if appErr == nil || appErr.Code != 400 {
+		t.Fatalf("expected 400, got %v", appErr)
+	}
+}
@@ -0,0 +1,23 @@
+package escrow
+
+import (
+	"context"
+	"errors"
+	kmsErrors "kms/pkg/errors"
+)
+
+// Service mock for escrow operations
+type EscrowServiceMock struct {
+	ExportFunc func(ctx context.Context, req *ExportRequest, adminId string) (*File, *kmsErrors.AppError)
+}
+
+func NewEscrowServiceMock() *EscrowServiceMock {
+	return &EscrowServiceMock{}
+}
+
+func (m *EscrowServiceMock) Export(ctx context.Context, req *ExportRequest, adminId string) (*File, *kmsErrors.AppError) {
+	if m.ExportFunc != nil {
+		return m.ExportFunc(ctx, req, adminId)
+	}
+	return nil, kmsErrors.LiftToAppError(errors.New("ExportFunc not implemented in mock"))
+}
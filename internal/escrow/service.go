@@ -0,0 +1,241 @@
+package escrow
+
+import (
+	"context"
+	b64 "encoding/base64"
+	"fmt"
+	c "kms/internal/bootstrap/context"
+	"kms/internal/clients"
+	"kms/internal/keys"
+	"kms/pkg/encryption"
+	kmsErrors "kms/pkg/errors"
+	"kms/pkg/hashing"
+	"time"
+)
+
+type Service struct {
+	KeyRepo    keys.KeyRepository
+	ClientRepo clients.ClientRepository
+	KeyManager c.KeyManager
+	Logger     c.Logger
+}
+
+func NewService(keyRepo keys.KeyRepository, clientRepo clients.ClientRepository, keyManager c.KeyManager, logger c.Logger) *Service {
+	return &Service{
+		KeyRepo:    keyRepo,
+		ClientRepo: clientRepo,
+		KeyManager: keyManager,
+		Logger:     logger,
+	}
+}
+
+// Wrap the client's DEKs (all of them, or only the given references) for the operator's public key
+func (s *Service) Export(ctx context.Context, req *ExportRequest, adminId string) (*File, *kmsErrors.AppError) {
+	if req.Algorithm != encryption.WrapAlgRSAOAEP256 && req.Algorithm != encryption.WrapAlgECDHP256 {
+		return nil, kmsErrors.NewAppError(
+			fmt.Errorf("unsupported wrapping algorithm: %v", req.Algorithm),
+			"Unsupported wrapping algorithm",
+			400,
+		)
+	}
+
+	publicKey, err := b64.RawURLEncoding.DecodeString(req.PublicKey)
+	if err != nil {
+		return nil, kmsErrors.NewAppError(err, "Public key must be base64url encoded", 400)
+	}
+
+	client, err := s.ClientRepo.GetClient(ctx, req.ClientId)
+	if err != nil {
+		return nil, kmsErrors.MapRepoErr(err)
+	}
+
+	toExport, appErr := s.getExportKeys(ctx, req)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	file := &File{
+		Format:     FormatName,
+		Version:    FormatVersion,
+		CreatedAt:  time.Now().UTC(),
+		Algorithm:  req.Algorithm,
+		Recipient:  Fingerprint(publicKey),
+		Clientname: client.Clientname,
+		Keys:       []Entry{},
+	}
+
+	for _, k := range toExport {
+		// keys pending deletion are left out, escrowing them would defeat the deletion
+		if k.key.IsPendingDeletion() {
+			continue
+		}
+
+		DEKBytes, err := b64.RawURLEncoding.DecodeString(k.key.DEK)
+		if err != nil {
+			return nil, kmsErrors.NewInternalServerError(err)
+		}
+
+		entry := Entry{
+			KeyReference:    k.reference,
+			HashedReference: k.key.KeyReference,
+			Version:         k.key.Version,
+			State:           k.key.State,
+			Algorithm:       k.key.Algorithm,
+			CreatedAt:       k.key.CreatedAt.UTC(),
+		}
+
+		wrapped, err := encryption.WrapKeyWithLabel(req.Algorithm, publicKey, DEKBytes, file.Label(&entry))
+		if err != nil {
+			return nil, kmsErrors.NewAppError(err, "Failed to wrap keys with public key", 400)
+		}
+		entry.WrappedKey = b64.RawURLEncoding.EncodeToString(wrapped)
+
+		file.Keys = append(file.Keys, entry)
+	}
+
+	file.Checksum = file.ComputeChecksum()
+
+	s.Logger.Notice("Keys exported for escrow", "adminId", adminId, "clientId", client.ID, "count", len(file.Keys), "recipient", file.Recipient)
+
+	return file, nil
+}
+
+type exportKey struct {
+	reference string
+	key       keys.Key
+}
+
+func (s *Service) getExportKeys(ctx context.Context, req *ExportRequest) ([]exportKey, *kmsErrors.AppError) {
+	var toExport []exportKey
+
+	if len(req.KeyReferences) == 0 {
+		clientKeys, err := s.KeyRepo.GetClientKeys(ctx, req.ClientId)
+		if err != nil {
+			return nil, kmsErrors.MapRepoErr(err)
+		}
+		for _, k := range clientKeys {
+			toExport = append(toExport, exportKey{key: k})
+		}
+		return toExport, nil
+	}
+
+	keyRefSecret, err := s.KeyManager.HashKey("keyReference")
+	if err != nil {
+		return nil, kmsErrors.NewInternalServerError(err)
+	}
+
+	for _, ref := range req.KeyReferences {
+		hashedReference := hashing.HashHS256ToB64([]byte(ref), keyRefSecret)
+		versions, err := s.KeyRepo.GetVersions(ctx, req.ClientId, hashedReference)
+		if err != nil {
+			return nil, kmsErrors.MapRepoErr(err)
+		}
+		if len(versions) == 0 {
+			return nil, kmsErrors.NewAppError(
+				fmt.Errorf("no versions found for key (%s)", hashedReference),
+				"Key not found: "+ref,
+				404,
+			)
+		}
+		for _, k := range versions {
+			toExport = append(toExport, exportKey{reference: ref, key: k})
+		}
+	}
+
+	return toExport, nil
+}
+
+// Restore an escrow file, all keys are imported or none are.
+// Keys are imported for clientId, or the client with the file's clientname if clientId is 0.
+func (s *Service) Import(ctx context.Context, file *File, operatorKey *encryption.WrappingKey, clientId int) (int, *kmsErrors.AppError) {
+	if err := file.Verify(); err != nil {
+		return 0, kmsErrors.NewAppError(err, "Invalid escrow file", 400)
+	}
+
+	if file.Algorithm != operatorKey.Algorithm {
+		return 0, kmsErrors.NewAppError(
+			fmt.Errorf("file is wrapped with %s, key is %s", file.Algorithm, operatorKey.Algorithm),
+			"Escrow file was not exported for this key",
+			400,
+		)
+	}
+	publicKey, err := operatorKey.PublicKeyDER()
+	if err != nil {
+		return 0, kmsErrors.NewInternalServerError(err)
+	}
+	if Fingerprint(publicKey) != file.Recipient {
+		return 0, kmsErrors.NewAppError(
+			fmt.Errorf("recipient %s does not match key %s", file.Recipient, Fingerprint(publicKey)),
+			"Escrow file was not exported for this key",
+			400,
+		)
+	}
+
+	if clientId == 0 {
+		clientnameSecret, err := s.KeyManager.HashKey("clientname")
+		if err != nil {
+			return 0, kmsErrors.NewInternalServerError(err)
+		}
+		client, err := s.ClientRepo.FindByHashedClientname(ctx, hashing.HashHS256ToB64([]byte(file.Clientname), clientnameSecret))
+		if err != nil {
+			return 0, kmsErrors.MapRepoErr(err)
+		}
+		clientId = client.ID
+	}
+
+	keyRefSecret, err := s.KeyManager.HashKey("keyReference")
+	if err != nil {
+		return 0, kmsErrors.NewInternalServerError(err)
+	}
+
+	repo, err := s.KeyRepo.BeginTransaction(ctx)
+	if err != nil {
+		return 0, kmsErrors.MapRepoErr(err)
+	}
+	defer func() {
+		if err := repo.RollbackTransaction(); err != nil {
+			s.Logger.Critical("Failed to rollback transaction", "error", err.Error(), "clientId", clientId)
+		}
+	}()
+
+	for _, e := range file.Keys {
+		wrapped, err := b64.RawURLEncoding.DecodeString(e.WrappedKey)
+		if err != nil {
+			return 0, kmsErrors.NewAppError(err, "Invalid escrow file", 400)
+		}
+		DEKBytes, err := operatorKey.UnwrapWithLabel(wrapped, file.Label(&e))
+		if err != nil {
+			return 0, kmsErrors.NewAppError(err, "Failed to unwrap key from escrow file", 400)
+		}
+		if err := encryption.ValidateKey(e.Algorithm, DEKBytes); err != nil {
+			return 0, kmsErrors.NewAppError(err, "Invalid escrow file", 400)
+		}
+
+		// hashed references are only valid if this instance uses the same KEY_REF_SECRET
+		hashedReference := e.HashedReference
+		if e.KeyReference != "" {
+			hashedReference = hashing.HashHS256ToB64([]byte(e.KeyReference), keyRefSecret)
+		}
+
+		_, err = repo.CreateKey(ctx, &keys.Key{
+			ClientId:     clientId,
+			KeyReference: hashedReference,
+			Version:      e.Version,
+			DEK:          b64.RawURLEncoding.EncodeToString(DEKBytes),
+			State:        e.State,
+			Encoding:     "base64url (RFC 4648)",
+			Algorithm:    e.Algorithm,
+		})
+		if err != nil {
+			return 0, kmsErrors.MapRepoErr(err)
+		}
+	}
+
+	if err := repo.CommitTransaction(); err != nil {
+		return 0, kmsErrors.MapRepoErr(err)
+	}
+
+	s.Logger.Notice("Keys imported from escrow", "clientId", clientId, "count", len(file.Keys), "recipient", file.Recipient)
+
+	return len(file.Keys), nil
+}
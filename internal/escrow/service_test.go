@@ -0,0 +1,228 @@
+package escrow
+
+import (
+	"bytes"
+	"context"
+	b64 "encoding/base64"
+	"kms/internal/clients"
+	"kms/internal/keys"
+	"kms/internal/test"
+	"kms/internal/test/mocks"
+	"kms/pkg/encryption"
+	"kms/pkg/hashing"
+	"testing"
+	"time"
+)
+
+func newTestService(keyRepo *keys.KeyRepositoryMock) *Service {
+	clientRepo := clients.NewClientRepositoryMock()
+	clientRepo.GetClientFunc = func(ctx context.Context, id int) (*clients.Client, error) {
+		return &clients.Client{ID: id, Clientname: "client"}, nil
+	}
+	keyManager := mocks.NewKeyManagerMock()
+	keyManager.HashKeyFunc = func(kind string) ([]byte, error) {
+		return []byte(kind + "Secret"), nil
+	}
+	return NewService(keyRepo, clientRepo, keyManager, mocks.NewLoggerMock())
+}
+
+func newExportRequest(t *testing.T, operatorKey *encryption.WrappingKey) *ExportRequest {
+	publicKey, err := operatorKey.PublicKeyDER()
+	test.RequireErrNil(t, err)
+	return &ExportRequest{
+		ClientId:  1,
+		Algorithm: operatorKey.Algorithm,
+		PublicKey: b64.RawURLEncoding.EncodeToString(publicKey),
+	}
+}
+
+func TestService_ExportImport_Roundtrip(t *testing.T) {
+	deleteAfter := time.Now().Add(time.Hour)
+	DEKs := [][]byte{bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)}
+
+	exportRepo := keys.NewKeyRepositoryMock()
+	exportRepo.GetClientKeysFunc = func(ctx context.Context, clientId int) ([]keys.Key, error) {
+		return []keys.Key{
+			{ClientId: clientId, KeyReference: "ref", Version: 1, State: "deprecated", Algorithm: encryption.AlgAES256GCM, DEK: b64.RawURLEncoding.EncodeToString(DEKs[0])},
+			{ClientId: clientId, KeyReference: "ref", Version: 2, State: "in-use", Algorithm: encryption.AlgAES256GCM, DEK: b64.RawURLEncoding.EncodeToString(DEKs[1])},
+			{ClientId: clientId, KeyReference: "deleted", Version: 1, State: "in-use", DEK: "AA", DeleteAfter: &deleteAfter},
+		}, nil
+	}
+
+	operatorKey, err := encryption.GenerateWrappingKey(encryption.WrapAlgECDHP256)
+	test.RequireErrNil(t, err)
+
+	file, appErr := newTestService(exportRepo).Export(context.Background(), newExportRequest(t, operatorKey), "admin")
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	if len(file.Keys) != 2 {
+		t.Fatalf("expected 2 exported keys (pending deletion excluded), got %d", len(file.Keys))
+	}
+	test.RequireErrNil(t, file.Verify())
+
+	var imported []*keys.Key
+	importRepo := keys.NewKeyRepositoryMock()
+	importRepo.BeginTransactionFunc = func(ctx context.Context) (keys.KeyRepository, error) {
+		return importRepo, nil
+	}
+	importRepo.CreateKeyFunc = func(ctx context.Context, key *keys.Key) (*keys.Key, error) {
+		imported = append(imported, key)
+		return key, nil
+	}
+	importRepo.CommitTransactionFunc = func() error { return nil }
+	importRepo.RollbackTransactionFunc = func() error { return nil }
+
+	count, appErr := newTestService(importRepo).Import(context.Background(), file, operatorKey, 7)
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	if count != 2 || len(imported) != 2 {
+		t.Fatalf("expected 2 imported keys, got %d", count)
+	}
+	for i, key := range imported {
+		if key.ClientId != 7 || key.KeyReference != "ref" || key.Version != i+1 {
+			t.Errorf("unexpected imported key: %+v", key)
+		}
+		if key.DEK != b64.RawURLEncoding.EncodeToString(DEKs[i]) {
+			t.Errorf("expected DEK of version %d to be preserved", i+1)
+		}
+	}
+	if imported[0].State != "deprecated" || imported[0].Algorithm != encryption.AlgAES256GCM {
+		t.Errorf("expected state and algorithm to be preserved, got %s (%s)", imported[0].State, imported[0].Algorithm)
+	}
+}
+
+func TestService_Export_ByReference(t *testing.T) {
+	hashedReference := hashing.HashHS256ToB64([]byte("myKey"), []byte("keyReferenceSecret"))
+
+	repo := keys.NewKeyRepositoryMock()
+	repo.GetVersionsFunc = func(ctx context.Context, clientId int, keyReference string) ([]keys.Key, error) {
+		if keyReference != hashedReference {
+			t.Errorf("expected hashed reference %s, got %s", hashedReference, keyReference)
+		}
+		return []keys.Key{{KeyReference: keyReference, Version: 1, State: "in-use", DEK: "AQID"}}, nil
+	}
+
+	operatorKey, err := encryption.GenerateWrappingKey(encryption.WrapAlgECDHP256)
+	test.RequireErrNil(t, err)
+	req := newExportRequest(t, operatorKey)
+	req.KeyReferences = []string{"myKey"}
+
+	file, appErr := newTestService(repo).Export(context.Background(), req, "admin")
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	if len(file.Keys) != 1 || file.Keys[0].KeyReference != "myKey" {
+		t.Errorf("expected entry with plaintext reference, got %+v", file.Keys)
+	}
+}
+
+func TestService_Export_UnknownReference(t *testing.T) {
+	repo := keys.NewKeyRepositoryMock()
+	repo.GetVersionsFunc = func(ctx context.Context, clientId int, keyReference string) ([]keys.Key, error) {
+		return []keys.Key{}, nil
+	}
+
+	operatorKey, err := encryption.GenerateWrappingKey(encryption.WrapAlgECDHP256)
+	test.RequireErrNil(t, err)
+	req := newExportRequest(t, operatorKey)
+	req.KeyReferences = []string{"missing"}
+
+	_, appErr := newTestService(repo).Export(context.Background(), req, "admin")
+	if appErr == nil || appErr.Code != 404 {
+		t.Fatalf("expected 404, got %v", appErr)
+	}
+}
+
+func TestService_Import_WrongRecipient(t *testing.T) {
+	repo := keys.NewKeyRepositoryMock()
+	repo.GetClientKeysFunc = func(ctx context.Context, clientId int) ([]keys.Key, error) {
+		return []keys.Key{{KeyReference: "ref", Version: 1, State: "in-use", DEK: "AQID"}}, nil
+	}
+
+	operatorKey, err := encryption.GenerateWrappingKey(encryption.WrapAlgECDHP256)
+	test.RequireErrNil(t, err)
+	otherKey, err := encryption.GenerateWrappingKey(encryption.WrapAlgECDHP256)
+	test.RequireErrNil(t, err)
+
+	file, appErr := newTestService(repo).Export(context.Background(), newExportRequest(t, operatorKey), "admin")
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+
+	_, appErr = newTestService(repo).Import(context.Background(), file, otherKey, 1)
+	if appErr == nil || appErr.Code != 400 {
+		t.Fatalf("expected 400, got %v", appErr)
+	}
+	test.RequireContains(t, appErr.Message, "not exported for this key")
+}
+
+func TestService_Import_SwappedEntries(t *testing.T) {
+	repo := keys.NewKeyRepositoryMock()
+	repo.GetClientKeysFunc = func(ctx context.Context, clientId int) ([]keys.Key, error) {
+		return []keys.Key{
+			{KeyReference: "ref", Version: 1, State: "deprecated", DEK: "AQID"},
+			{KeyReference: "ref", Version: 2, State: "in-use", DEK: "BAUG"},
+		}, nil
+	}
+	repo.BeginTransactionFunc = func(ctx context.Context) (keys.KeyRepository, error) {
+		return repo, nil
+	}
+	repo.CreateKeyFunc = func(ctx context.Context, key *keys.Key) (*keys.Key, error) {
+		return key, nil
+	}
+	repo.RollbackTransactionFunc = func() error { return nil }
+
+	operatorKey, err := encryption.GenerateWrappingKey(encryption.WrapAlgRSAOAEP256)
+	test.RequireErrNil(t, err)
+
+	file, appErr := newTestService(repo).Export(context.Background(), newExportRequest(t, operatorKey), "admin")
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+
+	// swap the wrapped keys and recompute the checksum, label binding should still catch it
+	file.Keys[0].WrappedKey, file.Keys[1].WrappedKey = file.Keys[1].WrappedKey, file.Keys[0].WrappedKey
+	file.Checksum = file.ComputeChecksum()
+
+	_, appErr = newTestService(repo).Import(context.Background(), file, operatorKey, 1)
+	if appErr == nil || appErr.Code != 400 {
+		t.Fatalf("expected 400, got %v", appErr)
+	}
+	test.RequireContains(t, appErr.Message, "Failed to unwrap")
+}
+
+func TestService_Import_EditedHeader(t *testing.T) {
+	repo := keys.NewKeyRepositoryMock()
+	repo.GetClientKeysFunc = func(ctx context.Context, clientId int) ([]keys.Key, error) {
+		return []keys.Key{{KeyReference: "ref", Version: 1, State: "in-use", DEK: "AQID"}}, nil
+	}
+	repo.BeginTransactionFunc = func(ctx context.Context) (keys.KeyRepository, error) {
+		return repo, nil
+	}
+	repo.CreateKeyFunc = func(ctx context.Context, key *keys.Key) (*keys.Key, error) {
+		return key, nil
+	}
+	repo.RollbackTransactionFunc = func() error { return nil }
+
+	operatorKey, err := encryption.GenerateWrappingKey(encryption.WrapAlgECDHP256)
+	test.RequireErrNil(t, err)
+
+	file, appErr := newTestService(repo).Export(context.Background(), newExportRequest(t, operatorKey), "admin")
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+
+	// the checksum can be recomputed by anyone, the labels can't
+	file.Clientname = "other"
+	file.Keys[0].State = "deprecated"
+	file.Checksum = file.ComputeChecksum()
+	test.RequireErrNil(t, file.Verify())
+
+	_, appErr = newTestService(repo).Import(context.Background(), file, operatorKey, 1)
+	if appErr == nil || appErr.Code != 400 {
+		t.Fatalf("expected 400, got %v", appErr)
+	}
+	test.RequireContains(t, appErr.Message, "Failed to unwrap")
+}
@@ -0,0 +1,81 @@
+package health
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"kms/internal/bootstrap"
+	c "kms/internal/bootstrap/context"
+	"kms/pkg/encryption"
+)
+
+func DatabaseCheck(db *sql.DB) Check {
+	return Check{Name: "database", Run: db.PingContext}
+}
+
+// The schema is at the latest migration in the migrations directory and not dirty
+func MigrationCheck(db *sql.DB, expected uint) Check {
+	return Check{Name: "migrations", Run: func(ctx context.Context) error {
+		version, dirty, ok, err := bootstrap.SchemaVersion(ctx, db)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("no migration applied")
+		}
+		if dirty {
+			return fmt.Errorf("migration %d failed, schema is dirty", version)
+		}
+		if version != expected {
+			return fmt.Errorf("schema is at version %d, expected %d", version, expected)
+		}
+		return nil
+	}}
+}
+
+// All keys are loaded
+func KeyManagerCheck(keyManager c.KeyManager) Check {
+	return Check{Name: "keyManager", Run: func(ctx context.Context) error {
+		if keyManager == nil {
+			return errors.New("no key manager")
+		}
+		for name, key := range map[string][]byte{
+			"JWT":    keyManager.JWTKey(),
+			"signup": keyManager.SignupKey(),
+			"KEK":    keyManager.KEK(),
+			"DB":     keyManager.DBKey(),
+		} {
+			if len(key) == 0 {
+				return fmt.Errorf("%s key not loaded", name)
+			}
+		}
+		return nil
+	}}
+}
+
+var kekCheckContext = encryption.EncryptionContext{"type": "readiness"}
+
+// Seals a random value with the KEK once, every check opens it again
+func KEKCheck(keyManager c.KeyManager) (Check, error) {
+	plaintext, err := encryption.GenerateKey(32)
+	if err != nil {
+		return Check{}, err
+	}
+	sealed, err := encryption.EncryptWithContext(plaintext, keyManager.KEK(), kekCheckContext)
+	if err != nil {
+		return Check{}, fmt.Errorf("KEK self-test failed: %w", err)
+	}
+
+	return Check{Name: "kek", Run: func(ctx context.Context) error {
+		opened, err := encryption.DecryptWithContext(sealed, keyManager.KEK(), kekCheckContext)
+		if err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare(opened, plaintext) != 1 {
+			return errors.New("KEK self-test returned a different value")
+		}
+		return nil
+	}}, nil
+}
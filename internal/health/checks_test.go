@@ -0,0 +1,42 @@
+package health
+
+import (
+	"context"
+	"kms/internal/test"
+	"kms/internal/test/mocks"
+	"testing"
+)
+
+func newKeyManager(kek []byte) *mocks.KeyManagerMock {
+	keyManager := mocks.NewKeyManagerMock()
+	key := []byte("0123456789abcdef0123456789abcdef")
+	keyManager.JWTKeyFunc = func() []byte { return key }
+	keyManager.SignupKeyFunc = func() []byte { return key }
+	keyManager.DBKeyFunc = func() []byte { return key }
+	keyManager.KEKFunc = func() []byte { return kek }
+	return keyManager
+}
+
+func TestKeyManagerCheck(t *testing.T) {
+	test.RequireErrNil(t, KeyManagerCheck(newKeyManager([]byte("0123456789abcdef0123456789abcdef"))).Run(context.Background()))
+
+	err := KeyManagerCheck(newKeyManager(nil)).Run(context.Background())
+	test.RequireErrNotNil(t, err)
+	test.RequireErrContains(t, err, "KEK key not loaded")
+}
+
+func TestKEKCheck(t *testing.T) {
+	kek := []byte("0123456789abcdef0123456789abcdef")
+	keyManager := newKeyManager(kek)
+	check, err := KEKCheck(keyManager)
+	test.RequireErrNil(t, err)
+	test.RequireErrNil(t, check.Run(context.Background()))
+
+	// e.g. a key manager that lost or swapped its KEK
+	keyManager.KEKFunc = func() []byte { return []byte("fedcba9876543210fedcba9876543210") }
+	test.RequireErrNotNil(t, check.Run(context.Background()))
+
+	if _, err := KEKCheck(newKeyManager([]byte("short"))); err == nil {
+		t.Error("expected error for an invalid KEK, got nil")
+	}
+}
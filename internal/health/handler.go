@@ -0,0 +1,99 @@
+package health
+
+import (
+	"context"
+	c "kms/internal/bootstrap/context"
+	kmsErrors "kms/pkg/errors"
+	pHttp "kms/pkg/http"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// Time all readiness checks together may take, a probe waiting longer than that is failed anyway
+const readyTimeout = 5 * time.Second
+
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+type Handler struct {
+	Checks  []Check
+	Logger  c.Logger
+	version *VersionResponse
+}
+
+func NewHandler(checks []Check, logger c.Logger) *Handler {
+	return &Handler{
+		Checks:  checks,
+		Logger:  logger,
+		version: buildVersion(),
+	}
+}
+
+type StatusResponse struct {
+	Status string `json:"status"`
+	// 'ok' or 'failed' per check, the errors are only logged
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+type VersionResponse struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"goVersion"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+}
+
+// Liveness, the process is up and serving
+func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	return pHttp.WriteJSON(w, StatusResponse{Status: "ok"})
+}
+
+// Readiness, 503 unless every check passes
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	resp := StatusResponse{Status: "ok", Checks: make(map[string]string, len(h.Checks))}
+	for _, check := range h.Checks {
+		if err := check.Run(ctx); err != nil {
+			h.Logger.Warn("Readiness check failed", "check", check.Name, "error", err)
+			resp.Checks[check.Name] = "failed"
+			resp.Status = "unavailable"
+			continue
+		}
+		resp.Checks[check.Name] = "ok"
+	}
+
+	if resp.Status != "ok" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	return pHttp.WriteJSON(w, resp)
+}
+
+func (h *Handler) Version(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	return pHttp.WriteJSON(w, h.version)
+}
+
+// Module version and the VCS stamp 'go build' embeds
+func buildVersion() *VersionResponse {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return &VersionResponse{Version: "unknown"}
+	}
+	version := &VersionResponse{Version: info.Main.Version, GoVersion: info.GoVersion}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			version.Revision = setting.Value
+		case "vcs.time":
+			version.Time = setting.Value
+		case "vcs.modified":
+			version.Modified = setting.Value == "true"
+		}
+	}
+	return version
+}
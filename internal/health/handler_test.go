@@ -0,0 +1,87 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"kms/internal/test"
+	"kms/internal/test/mocks"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func okCheck(name string) Check {
+	return Check{Name: name, Run: func(ctx context.Context) error { return nil }}
+}
+
+func readyz(t *testing.T, checks ...Check) (*httptest.ResponseRecorder, StatusResponse) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	appErr := NewHandler(checks, mocks.NewLoggerMock()).Readyz(rr, httptest.NewRequest("GET", "/readyz", nil))
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	var resp StatusResponse
+	test.RequireErrNil(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	return rr, resp
+}
+
+func TestHealthz(t *testing.T) {
+	rr := httptest.NewRecorder()
+	failing := Check{Name: "database", Run: func(ctx context.Context) error { return errors.New("down") }}
+	if appErr := NewHandler([]Check{failing}, mocks.NewLoggerMock()).Healthz(rr, httptest.NewRequest("GET", "/healthz", nil)); appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	// liveness doesn't depend on the checks
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", rr.Code)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	rr, resp := readyz(t, okCheck("database"), okCheck("kek"))
+	if rr.Code != http.StatusOK || resp.Status != "ok" {
+		t.Errorf("expected 200 ok, got %d %s", rr.Code, resp.Status)
+	}
+	if resp.Checks["database"] != "ok" || resp.Checks["kek"] != "ok" {
+		t.Errorf("unexpected checks %v", resp.Checks)
+	}
+}
+
+func TestReadyz_CheckFailed(t *testing.T) {
+	failing := Check{Name: "database", Run: func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("expected checks to run with a deadline")
+		}
+		return errors.New("dial tcp 10.0.0.5:5432: connection refused")
+	}}
+
+	rr, resp := readyz(t, failing, okCheck("kek"))
+	if rr.Code != http.StatusServiceUnavailable || resp.Status != "unavailable" {
+		t.Errorf("expected 503 unavailable, got %d %s", rr.Code, resp.Status)
+	}
+	if resp.Checks["database"] != "failed" || resp.Checks["kek"] != "ok" {
+		t.Errorf("unexpected checks %v", resp.Checks)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected application/json, got %q", ct)
+	}
+	// the error is only logged
+	if strings.Contains(rr.Body.String(), "10.0.0.5") {
+		t.Errorf("expected no error details, got %s", rr.Body.String())
+	}
+}
+
+func TestVersion(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if appErr := NewHandler(nil, mocks.NewLoggerMock()).Version(rr, httptest.NewRequest("GET", "/version", nil)); appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	var resp VersionResponse
+	test.RequireErrNil(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	if resp.Version == "" || resp.GoVersion == "" {
+		t.Errorf("expected version and Go version, got %+v", resp)
+	}
+}
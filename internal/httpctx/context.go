@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"kms/internal/auth"
 	c "kms/internal/bootstrap/context"
+	"kms/internal/metrics"
 	kmsErrors "kms/pkg/errors"
+	pHttp "kms/pkg/http"
 	"kms/pkg/id"
+	"kms/pkg/tracing"
 	"net/http"
 	"time"
 )
@@ -14,7 +17,7 @@ import (
 type contextKey string
 
 const RouteParamsCtxKey contextKey = "routeParams"
-const TokenCtxKey contextKey = "token"
+const TokenCtxKey = auth.TokenCtxKey
 const RequestIDKey contextKey = "requestId"
 
 type AppHandler func(http.ResponseWriter, *http.Request) *kmsErrors.AppError
@@ -31,31 +34,61 @@ func NewAppHandler(logger c.Logger, handler AppHandler) http.Handler {
 		reqID, err := id.GenerateUUID()
 		if err != nil {
 			logger.Error("HTTP handler", "message", "Failed to generate UUID for request", "error", err)
-			http.Error(w, "Internal server error", 500)
+			pHttp.WriteProblem(w, kmsErrors.NewInternalServerError(err).Problem(r.URL.Path, ""))
 			return
 		}
 		w.Header().Set("X-Request-ID", reqID)
 
+		// Continue the caller's trace if it sent a traceparent, the span is named after the route once it's matched
+		ctx, span := tracing.StartSpan(tracing.Extract(r.Context(), r.Header), tracing.KindServer, r.Method,
+			"http.request.method", r.Method,
+			"url.path", r.URL.Path,
+			"kms.request_id", reqID,
+		)
+		defer span.End()
+		traceID := span.SpanContext.TraceID.String()
+
 		// Add UUID to request context
-		ctx := context.WithValue(r.Context(), RequestIDKey, reqID)
+		ctx = context.WithValue(ctx, RequestIDKey, reqID)
 		r = r.WithContext(ctx)
 
 		// Log request start
 		start := time.Now()
 		logger.Info("HTTP request start",
 			"requestId", reqID,
+			"traceId", traceID,
 			"method", r.Method,
 			"path", r.URL.Path,
 		)
 
 		rec := newStatusRecorder(w)
+		defer func() {
+			if rec.route != "" {
+				span.SetName(r.Method + " " + rec.route)
+				span.SetAttributes("http.route", rec.route)
+			}
+		}()
 
 		// Handle error
 		if appErr := handler(rec, r); appErr != nil {
+			// a failure because the client went away isn't the server's
+			if appErr.Code >= 500 {
+				if ctxErr := kmsErrors.FromContext(r.Context(), appErr.Err); ctxErr != nil {
+					appErr = ctxErr
+				}
+			}
+			problem := appErr.Problem(r.URL.Path, reqID)
+			span.SetAttributes("http.response.status_code", appErr.Code, "kms.error_code", problem.Code)
+			// client errors are not failures of the server
+			if appErr.Code >= 500 {
+				span.RecordError(appErr.Err)
+			}
 			entry := []any{
 				"requestId", reqID,
+				"traceId", traceID,
 				"path", r.URL.Path,
 				"code", appErr.Code,
+				"errorCode", problem.Code,
 				"message", appErr.Message,
 				"error", appErr.Err,
 			}
@@ -64,13 +97,17 @@ func NewAppHandler(logger c.Logger, handler AppHandler) http.Handler {
 			} else {
 				logger.Warn("HTTP handler", entry...)
 			}
-			http.Error(w, appErr.Message, appErr.Code)
+			pHttp.WriteProblem(w, problem)
+			metrics.ObserveRequest(r.Method, rec.route, appErr.Code, time.Since(start))
 			return
 		}
+		metrics.ObserveRequest(r.Method, rec.route, rec.statusCode, time.Since(start))
+		span.SetAttributes("http.response.status_code", rec.statusCode)
 
 		// Log request finished
 		logger.Info("HTTP request finished",
 			"requestId", reqID,
+			"traceId", traceID,
 			"method", r.Method,
 			"path", r.URL.Path,
 			"status", rec.statusCode,
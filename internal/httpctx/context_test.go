@@ -8,6 +8,7 @@ import (
 	"kms/internal/test/mocks"
 	kmsErrors "kms/pkg/errors"
 	pHttp "kms/pkg/http"
+	"kms/pkg/tracing"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -153,3 +154,100 @@ func TestNewAppHandler_Error(t *testing.T) {
 		})
 	}
 }
+
+func TestNewAppHandler_Problem(t *testing.T) {
+	handler := AppHandler(func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+		return kmsErrors.NewAppError(errors.New("no rows"), "Entity not found", 404).WithErrorCode(kmsErrors.CodeKeyNotFound)
+	})
+	req, err := http.NewRequest("GET", "/v1/keys/ref/latest", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	NewAppHandler(mocks.NewLoggerMock(), handler).ServeHTTP(rr, req)
+
+	if ct := rr.Header().Get("Content-Type"); ct != kmsErrors.ProblemContentType {
+		t.Errorf("Expected Content-Type %s, got: %s", kmsErrors.ProblemContentType, ct)
+	}
+	var problem kmsErrors.Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("Failed to parse response body: %v", err)
+	}
+	want := kmsErrors.Problem{
+		Type:      "about:blank",
+		Title:     "Not Found",
+		Status:    404,
+		Detail:    "Entity not found",
+		Instance:  "/v1/keys/ref/latest",
+		Code:      kmsErrors.CodeKeyNotFound,
+		RequestId: rr.Header().Get("X-Request-ID"),
+	}
+	if problem != want {
+		t.Errorf("Expected %+v, got: %+v", want, problem)
+	}
+}
+
+func TestNewAppHandler_Tracing(t *testing.T) {
+	recorder := &tracing.SpanRecorder{}
+	tracing.SetExporter(recorder)
+	defer tracing.SetExporter(nil)
+
+	var child *tracing.Span
+	handler := AppHandler(func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+		w.(RouteRecorder).SetRoute("/v1/keys/{keyReference}/latest")
+		_, child = tracing.Start(r.Context(), "KeyService.GetKey")
+		child.End()
+		return kmsErrors.NewInternalServerError(errors.New("db down"))
+	})
+	req, err := http.NewRequest("GET", "/v1/keys/ref/latest", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rr := httptest.NewRecorder()
+	NewAppHandler(mocks.NewLoggerMock(), handler).ServeHTTP(rr, req)
+
+	spans := recorder.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got: %d", len(spans))
+	}
+	server := spans[1]
+	if server.Name != "GET /v1/keys/{keyReference}/latest" || server.Kind != tracing.KindServer {
+		t.Errorf("Unexpected server span %s (kind %d)", server.Name, server.Kind)
+	}
+	if server.SpanContext.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || server.Parent.String() != "00f067aa0ba902b7" {
+		t.Errorf("Expected the caller's trace, got trace %s parent %s", server.SpanContext.TraceID, server.Parent)
+	}
+	if child.Parent != server.SpanContext.SpanID {
+		t.Errorf("Expected child of the server span, got parent %s", child.Parent)
+	}
+	if server.Err() == nil {
+		t.Error("Expected server span to record the error")
+	}
+}
+
+func TestNewAppHandler_ClientClosedRequest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := AppHandler(func(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+		// the client disconnects while the query runs
+		cancel()
+		return kmsErrors.NewInternalServerError(errors.New("pq: canceling statement due to user request"))
+	})
+	req, err := http.NewRequestWithContext(ctx, "GET", "/v1/keys/ref/latest", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	NewAppHandler(mocks.NewLoggerMock(), handler).ServeHTTP(rr, req)
+
+	if rr.Code != kmsErrors.StatusClientClosedRequest {
+		t.Errorf("Expected status code 499, got: %d", rr.Code)
+	}
+	var problem kmsErrors.Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("Failed to parse response body: %v", err)
+	}
+	if problem.Code != kmsErrors.CodeClientClosedRequest || problem.Title != "Client Closed Request" {
+		t.Errorf("Unexpected problem %+v", problem)
+	}
+}
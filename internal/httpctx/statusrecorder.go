@@ -4,9 +4,15 @@ import (
 	"net/http"
 )
 
+// Implemented by the writer NewAppHandler passes on, the router reports the matched pattern (e.g. '/v1/keys/{keyReference}/latest')
+type RouteRecorder interface {
+	SetRoute(pattern string)
+}
+
 type statusRecorder struct {
 	http.ResponseWriter
 	statusCode int
+	route      string
 }
 
 func newStatusRecorder(w http.ResponseWriter) *statusRecorder {
@@ -20,3 +26,7 @@ func (rec *statusRecorder) WriteHeader(code int) {
 	rec.statusCode = code
 	rec.ResponseWriter.WriteHeader(code)
 }
+
+func (rec *statusRecorder) SetRoute(pattern string) {
+	rec.route = pattern
+}
@@ -9,27 +9,64 @@ const (
 )
 
 type Key struct {
-	ID           int    `json:"id"`
-	ClientId     int    `json:"clientId"`
-	KeyReference string `json:"keyReference"`
-	Version      int    `json:"version"`
-	DEK          string `json:"dek" encrypt:"true" encoded:"true" key:"kek"`
-	State        string `json:"state" encrypt:"true"`
-	Encoding     string `json:"encoding" encrypt:"true"`
+	ID           int        `json:"id"`
+	ClientId     int        `json:"clientId" context:"true"`
+	KeyReference string     `json:"keyReference" context:"true"`
+	Version      int        `json:"version" context:"true"`
+	DEK          string     `json:"dek" encrypt:"true" encoded:"true" key:"kek"`
+	State        string     `json:"state" encrypt:"true"`
+	Encoding     string     `json:"encoding" encrypt:"true"`
+	Algorithm    string     `json:"algorithm"`
+	DeleteAfter  *time.Time `json:"deleteAfter,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+// Bound into the encryption context of the key's encrypted fields, so it must never change
+func (k *Key) EncryptionContextType() string {
+	return "Key"
 }
 
 func (k *Key) Is(o *Key) bool {
 	return k.ID == o.ID
 }
 
+// Keys scheduled for deletion can't be used, but can still be restored until DeleteAfter has passed
+func (k *Key) IsPendingDeletion() bool {
+	return k.DeleteAfter != nil
+}
+
+// Algorithm defaults to AES-256-GCM
 type GenerateKeyRequest struct {
 	KeyReference string `json:"keyReference"`
+	Algorithm    string `json:"algorithm,omitempty"`
+}
+
+type WrappingKeyRequest struct {
+	Algorithm string `json:"algorithm"`
+}
+
+// PublicKey is the base64url encoded PKIX (SubjectPublicKeyInfo) DER public key
+type WrappingKeyResponse struct {
+	ImportToken string    `json:"importToken"`
+	Algorithm   string    `json:"algorithm"`
+	PublicKey   string    `json:"publicKey"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+// WrappedKey is the base64url encoded key material, wrapped with the public key belonging to ImportToken.
+// Algorithm defaults to AES-256-GCM for new keys and must match the existing versions otherwise
+type ImportKeyRequest struct {
+	KeyReference string `json:"keyReference"`
+	ImportToken  string `json:"importToken"`
+	WrappedKey   string `json:"wrappedKey"`
+	Algorithm    string `json:"algorithm,omitempty"`
 }
 
 type KeyResponse struct {
 	DEK       string    `json:"dek"`
 	Version   int       `json:"version"`
 	Encoding  string    `json:"encoding"`
+	Algorithm string    `json:"algorithm"`
 	ExpiresAt time.Time `json:"expiresAt"`
 }
 
@@ -38,6 +75,7 @@ func BuildKeyResponse(k *Key) *KeyResponse {
 		DEK:       k.DEK,
 		Version:   k.Version,
 		Encoding:  k.Encoding,
+		Algorithm: k.Algorithm,
 		ExpiresAt: time.Now().Add(time.Minute * 5),
 	}
 }
@@ -53,3 +91,17 @@ func BuildKeyLookupReponse(ka, kb *Key) *KeyLookupResponse {
 		EncryptWith: BuildKeyResponse(kb),
 	}
 }
+
+type KeyDeletionResponse struct {
+	DeleteAfter time.Time `json:"deleteAfter"`
+}
+
+// Versions matching all given criteria are destroyed, the latest version is always kept
+type PruneKeyVersionsRequest struct {
+	OlderThanVersion int        `json:"olderThanVersion,omitempty"`
+	DeprecatedBefore *time.Time `json:"deprecatedBefore,omitempty"`
+}
+
+type PruneKeyVersionsResponse struct {
+	DestroyedVersions []int `json:"destroyedVersions"`
+}
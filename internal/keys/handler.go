@@ -1,6 +1,8 @@
 package keys
 
 import (
+	"context"
+	"fmt"
 	c "kms/internal/bootstrap/context"
 	"kms/internal/httpctx"
 	kmsErrors "kms/pkg/errors"
@@ -8,6 +10,7 @@ import (
 	"kms/pkg/json"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 type Handler struct {
@@ -23,11 +26,16 @@ func NewHandler(keyService KeyService, logger c.Logger) *Handler {
 }
 
 type KeyService interface {
-	CreateKey(clientId int, keyReference string, version int) (*Key, *kmsErrors.AppError)
-	GetKey(clientId int, keyReference string, version int) (*Key, *Key, *kmsErrors.AppError)
-	RotateKey(clientId int, keyReference string) (*Key, *kmsErrors.AppError)
-	DeleteKey(clientId int, keyReference string) *kmsErrors.AppError
-	GetAll() ([]Key, *kmsErrors.AppError)
+	CreateKey(ctx context.Context, clientId int, keyReference string, version int, algorithm string) (*Key, *kmsErrors.AppError)
+	GetKey(ctx context.Context, clientId int, keyReference string, version int) (*Key, *Key, *kmsErrors.AppError)
+	RotateKey(ctx context.Context, clientId int, keyReference string) (*Key, *kmsErrors.AppError)
+	CreateWrappingKey(ctx context.Context, clientId int, algorithm string) (*WrappingKeyResponse, *kmsErrors.AppError)
+	ImportKey(ctx context.Context, clientId int, req *ImportKeyRequest) (*Key, *kmsErrors.AppError)
+	DeleteKey(ctx context.Context, clientId int, keyReference string) (time.Time, *kmsErrors.AppError)
+	RestoreKey(ctx context.Context, clientId int, keyReference string) *kmsErrors.AppError
+	DestroyKeyVersion(ctx context.Context, clientId int, keyReference string, version int) *kmsErrors.AppError
+	PruneKeyVersions(ctx context.Context, clientId int, keyReference string, req *PruneKeyVersionsRequest) ([]int, *kmsErrors.AppError)
+	GetAll(ctx context.Context) ([]Key, *kmsErrors.AppError)
 }
 
 func (h *Handler) GenerateKey(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
@@ -43,10 +51,10 @@ func (h *Handler) GenerateKey(w http.ResponseWriter, r *http.Request) *kmsErrors
 
 	var requestBody GenerateKeyRequest
 	if err := json.ParseBody(r.Body, &requestBody); err != nil {
-		return kmsErrors.NewAppError(err, "Invalid request body", 400)
+		return kmsErrors.NewInvalidBodyError(err)
 	}
 
-	key, appErr := h.Service.CreateKey(clientId, requestBody.KeyReference, 1)
+	key, appErr := h.Service.CreateKey(r.Context(), clientId, requestBody.KeyReference, 1, requestBody.Algorithm)
 	if appErr != nil {
 		return appErr
 	}
@@ -72,17 +80,24 @@ func (h *Handler) GetKey(w http.ResponseWriter, r *http.Request) *kmsErrors.AppE
 		return kmsErrors.NewInternalServerError(err)
 	}
 
+	// '/keys/{keyReference}/latest' has no version param
 	versionStr, err := httpctx.GetRouteParam(r.Context(), "version")
 	if err != nil {
-		return kmsErrors.NewInternalServerError(err)
+		versionStr = "latest"
 	}
 
-	version, err := strconv.Atoi(versionStr)
-	if err != nil {
-		return kmsErrors.NewAppError(err, "Invalid path parameter", 400)
+	version := LatestVersion
+	if versionStr != "latest" {
+		version, err = strconv.Atoi(versionStr)
+		if err != nil {
+			return kmsErrors.NewAppError(err, "Invalid path parameter", 400).WithErrorCode(kmsErrors.CodeInvalidParameter)
+		}
+		if version == LatestVersion {
+			return kmsErrors.NewAppError(fmt.Errorf("invalid version: %d", version), "Invalid path parameter", 400).WithErrorCode(kmsErrors.CodeInvalidParameter)
+		}
 	}
 
-	decKey, encKey, appErr := h.Service.GetKey(clientId, keyReference, version)
+	decKey, encKey, appErr := h.Service.GetKey(r.Context(), clientId, keyReference, version)
 	if appErr != nil {
 		return appErr
 	}
@@ -114,7 +129,57 @@ func (h *Handler) RotateKey(w http.ResponseWriter, r *http.Request) *kmsErrors.A
 		return kmsErrors.NewInternalServerError(err)
 	}
 
-	key, appErr := h.Service.RotateKey(clientId, keyReference)
+	key, appErr := h.Service.RotateKey(r.Context(), clientId, keyReference)
+	if appErr != nil {
+		return appErr
+	}
+
+	response := BuildKeyResponse(key)
+
+	return pHttp.WriteJSON(w, response)
+}
+
+func (h *Handler) CreateWrappingKey(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	token, err := httpctx.ExtractToken(r.Context())
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	clientId, err := strconv.Atoi(token.Payload.Sub)
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	var requestBody WrappingKeyRequest
+	if err := json.ParseBody(r.Body, &requestBody); err != nil {
+		return kmsErrors.NewInvalidBodyError(err)
+	}
+
+	response, appErr := h.Service.CreateWrappingKey(r.Context(), clientId, requestBody.Algorithm)
+	if appErr != nil {
+		return appErr
+	}
+
+	return pHttp.WriteJSON(w, response)
+}
+
+func (h *Handler) ImportKey(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	token, err := httpctx.ExtractToken(r.Context())
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	clientId, err := strconv.Atoi(token.Payload.Sub)
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	var requestBody ImportKeyRequest
+	if err := json.ParseBody(r.Body, &requestBody); err != nil {
+		return kmsErrors.NewInvalidBodyError(err)
+	}
+
+	key, appErr := h.Service.ImportKey(r.Context(), clientId, &requestBody)
 	if appErr != nil {
 		return appErr
 	}
@@ -140,15 +205,109 @@ func (h *Handler) DeleteKey(w http.ResponseWriter, r *http.Request) *kmsErrors.A
 		return kmsErrors.NewInternalServerError(err)
 	}
 
-	if appErr := h.Service.DeleteKey(clientId, keyReference); appErr != nil {
+	deleteAfter, appErr := h.Service.DeleteKey(r.Context(), clientId, keyReference)
+	if appErr != nil {
+		return appErr
+	}
+
+	response := &KeyDeletionResponse{
+		DeleteAfter: deleteAfter,
+	}
+
+	return pHttp.WriteJSON(w, response)
+}
+
+func (h *Handler) RestoreKey(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	token, err := httpctx.ExtractToken(r.Context())
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	clientId, err := strconv.Atoi(token.Payload.Sub)
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	keyReference, err := httpctx.GetRouteParam(r.Context(), "keyReference")
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	if appErr := h.Service.RestoreKey(r.Context(), clientId, keyReference); appErr != nil {
 		return appErr
 	}
 
 	return pHttp.WriteStatus(w, 204)
 }
 
+func (h *Handler) DestroyKeyVersion(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	token, err := httpctx.ExtractToken(r.Context())
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	clientId, err := strconv.Atoi(token.Payload.Sub)
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	keyReference, err := httpctx.GetRouteParam(r.Context(), "keyReference")
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	versionStr, err := httpctx.GetRouteParam(r.Context(), "version")
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	version, err := strconv.Atoi(versionStr)
+	if err != nil {
+		return kmsErrors.NewAppError(err, "Invalid path parameter", 400).WithErrorCode(kmsErrors.CodeInvalidParameter)
+	}
+
+	if appErr := h.Service.DestroyKeyVersion(r.Context(), clientId, keyReference, version); appErr != nil {
+		return appErr
+	}
+
+	return pHttp.WriteStatus(w, 204)
+}
+
+func (h *Handler) PruneKeyVersions(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
+	token, err := httpctx.ExtractToken(r.Context())
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	clientId, err := strconv.Atoi(token.Payload.Sub)
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	keyReference, err := httpctx.GetRouteParam(r.Context(), "keyReference")
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	var requestBody PruneKeyVersionsRequest
+	if err := json.ParseBody(r.Body, &requestBody); err != nil {
+		return kmsErrors.NewInvalidBodyError(err)
+	}
+
+	destroyed, appErr := h.Service.PruneKeyVersions(r.Context(), clientId, keyReference, &requestBody)
+	if appErr != nil {
+		return appErr
+	}
+
+	response := &PruneKeyVersionsResponse{
+		DestroyedVersions: destroyed,
+	}
+
+	return pHttp.WriteJSON(w, response)
+}
+
 func (h *Handler) GetAllDev(w http.ResponseWriter, r *http.Request) *kmsErrors.AppError {
-	keys, appErr := h.Service.GetAll()
+	keys, appErr := h.Service.GetAll(r.Context())
 	if appErr != nil {
 		return appErr
 	}
@@ -2,6 +2,7 @@ package keys
 
 import (
 	"context"
+	"encoding/json"
 	"kms/internal/auth"
 	"kms/internal/httpctx"
 	"kms/internal/test"
@@ -10,11 +11,12 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestHandler_GenerateKey_Success(t *testing.T) {
 	mockService := NewKeyServiceMock()
-	mockService.CreateKeyFunc = func(clientId int, keyReference string, version int) (*Key, *kmsErrors.AppError) {
+	mockService.CreateKeyFunc = func(ctx context.Context, clientId int, keyReference string, version int, algorithm string) (*Key, *kmsErrors.AppError) {
 		return &Key{
 			DEK:      "dek",
 			Version:  1,
@@ -38,8 +40,8 @@ func TestHandler_GenerateKey_Success(t *testing.T) {
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if !strings.Contains(rr.Body.String(), `"dek":"dek","version":1,"encoding":"encoding","expiresAt":`) {
-		t.Errorf("expected \"dek\":\"dek\",\"version\":1,\"encoding\":\"encoding\",\"expiresAt\":[time], got: %v", rr.Body.String())
+	if !strings.Contains(rr.Body.String(), `"dek":"dek","version":1,"encoding":"encoding","algorithm":"","expiresAt":`) {
+		t.Errorf("expected \"dek\":\"dek\",\"version\":1,\"encoding\":\"encoding\",\"algorithm\":\"\",\"expiresAt\":[time], got: %v", rr.Body.String())
 	}
 }
 
@@ -117,7 +119,7 @@ func TestHandler_GenerateKey_ParseBodyError(t *testing.T) {
 
 func TestHandler_GenerateKey_ServiceError(t *testing.T) {
 	mockService := NewKeyServiceMock()
-	mockService.CreateKeyFunc = func(clientId int, keyReference string, v int) (*Key, *kmsErrors.AppError) {
+	mockService.CreateKeyFunc = func(ctx context.Context, clientId int, keyReference string, v int, algorithm string) (*Key, *kmsErrors.AppError) {
 		return nil, kmsErrors.NewAppError(nil, "service error", 500)
 	}
 	mockLogger := mocks.NewLoggerMock()
@@ -146,7 +148,7 @@ func TestHandler_GenerateKey_ServiceError(t *testing.T) {
 
 func TestHandler_GetKey_Success(t *testing.T) {
 	mockService := NewKeyServiceMock()
-	mockService.GetKeyFunc = func(clientId int, keyReference string, version int) (*Key, *Key, *kmsErrors.AppError) {
+	mockService.GetKeyFunc = func(ctx context.Context, clientId int, keyReference string, version int) (*Key, *Key, *kmsErrors.AppError) {
 		return &Key{
 				DEK:      "dek",
 				Version:  1,
@@ -179,8 +181,8 @@ func TestHandler_GetKey_Success(t *testing.T) {
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if !strings.Contains(rr.Body.String(), `{"decryptWith":{"dek":"dek","version":1,"encoding":"encoding","expiresAt":`) || !strings.Contains(rr.Body.String(), `"encryptWith":{"dek":"dek","version":2,"encoding":"encoding","expiresAt":`) {
-		t.Errorf(`expected {"decryptWith":{"dek":"dek","version":1,"encoding":"encoding","expiresAt":[time]},"encryptWith":{"dek":"dek","version":2,"encoding":"encoding","expiresAt":[time]}}, got %s`, rr.Body.String())
+	if !strings.Contains(rr.Body.String(), `{"decryptWith":{"dek":"dek","version":1,"encoding":"encoding","algorithm":"","expiresAt":`) || !strings.Contains(rr.Body.String(), `"encryptWith":{"dek":"dek","version":2,"encoding":"encoding","algorithm":"","expiresAt":`) {
+		t.Errorf(`expected {"decryptWith":{"dek":"dek","version":1,"encoding":"encoding","algorithm":"","expiresAt":[time]},"encryptWith":{"dek":"dek","version":2,"encoding":"encoding","algorithm":"","expiresAt":[time]}}, got %s`, rr.Body.String())
 	}
 }
 
@@ -258,7 +260,7 @@ func TestHandler_GetKey_MissingRouteParam(t *testing.T) {
 
 func TestHandler_GetKey_ServiceError(t *testing.T) {
 	mockService := NewKeyServiceMock()
-	mockService.GetKeyFunc = func(clientId int, keyReference string, version int) (*Key, *Key, *kmsErrors.AppError) {
+	mockService.GetKeyFunc = func(ctx context.Context, clientId int, keyReference string, version int) (*Key, *Key, *kmsErrors.AppError) {
 		return nil, nil, kmsErrors.NewAppError(nil, "service error", 500)
 	}
 	mockLogger := mocks.NewLoggerMock()
@@ -291,7 +293,7 @@ func TestHandler_GetKey_ServiceError(t *testing.T) {
 
 func TestHandler_RotateKey_Success(t *testing.T) {
 	mockService := NewKeyServiceMock()
-	mockService.RotateKeyFunc = func(clientId int, keyReference string) (*Key, *kmsErrors.AppError) {
+	mockService.RotateKeyFunc = func(ctx context.Context, clientId int, keyReference string) (*Key, *kmsErrors.AppError) {
 		return &Key{
 			DEK:      "dek",
 			Version:  1,
@@ -322,8 +324,8 @@ func TestHandler_RotateKey_Success(t *testing.T) {
 	if rr.Code != 200 {
 		t.Errorf("expected status 200, got %d", rr.Code)
 	}
-	if !strings.Contains(rr.Body.String(), `{"dek":"dek","version":1,"encoding":"encoding","expiresAt":`) {
-		t.Errorf(`expected {"dek":"dek","version":1,"encoding":"encoding","expiresAt":[time]}, got %s`, rr.Body.String())
+	if !strings.Contains(rr.Body.String(), `{"dek":"dek","version":1,"encoding":"encoding","algorithm":"","expiresAt":`) {
+		t.Errorf(`expected {"dek":"dek","version":1,"encoding":"encoding","algorithm":"","expiresAt":[time]}, got %s`, rr.Body.String())
 	}
 }
 
@@ -379,7 +381,7 @@ func TestHandler_RotateKey_MissingRouteParam(t *testing.T) {
 
 func TestHandler_RotateKey_ServiceError(t *testing.T) {
 	mockService := NewKeyServiceMock()
-	mockService.RotateKeyFunc = func(clientId int, keyReference string) (*Key, *kmsErrors.AppError) {
+	mockService.RotateKeyFunc = func(ctx context.Context, clientId int, keyReference string) (*Key, *kmsErrors.AppError) {
 		return nil, kmsErrors.NewAppError(nil, "service error", 500)
 	}
 	mockLogger := mocks.NewLoggerMock()
@@ -413,8 +415,9 @@ func TestHandler_RotateKey_ServiceError(t *testing.T) {
 
 func TestHandler_DeleteKey_Success(t *testing.T) {
 	mockService := NewKeyServiceMock()
-	mockService.DeleteKeyFunc = func(clientId int, keyReference string) *kmsErrors.AppError {
-		return nil
+	deleteAfter := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockService.DeleteKeyFunc = func(ctx context.Context, clientId int, keyReference string) (time.Time, *kmsErrors.AppError) {
+		return deleteAfter, nil
 	}
 	mockLogger := mocks.NewLoggerMock()
 	handler := NewHandler(mockService, mockLogger)
@@ -436,8 +439,15 @@ func TestHandler_DeleteKey_Success(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if rr.Result().StatusCode != 204 {
-		t.Errorf("expected status 204, got %d", rr.Result().StatusCode)
+	if rr.Result().StatusCode != 200 {
+		t.Errorf("expected status 200, got %d", rr.Result().StatusCode)
+	}
+	var resp KeyDeletionResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !resp.DeleteAfter.Equal(deleteAfter) {
+		t.Errorf("expected deleteAfter=%v, got %v", deleteAfter, resp.DeleteAfter)
 	}
 }
 
@@ -515,8 +525,8 @@ func TestHandler_DeleteKey_MissingRouteParam(t *testing.T) {
 
 func TestHandler_DeleteKey_ServiceError(t *testing.T) {
 	mockService := NewKeyServiceMock()
-	mockService.DeleteKeyFunc = func(clientId int, keyReference string) *kmsErrors.AppError {
-		return kmsErrors.NewAppError(nil, "service error", 500)
+	mockService.DeleteKeyFunc = func(ctx context.Context, clientId int, keyReference string) (time.Time, *kmsErrors.AppError) {
+		return time.Time{}, kmsErrors.NewAppError(nil, "service error", 500)
 	}
 	mockLogger := mocks.NewLoggerMock()
 	handler := NewHandler(mockService, mockLogger)
@@ -545,9 +555,72 @@ func TestHandler_DeleteKey_ServiceError(t *testing.T) {
 	test.RequireContains(t, err.Message, "service error")
 }
 
+func TestHandler_RestoreKey_Success(t *testing.T) {
+	mockService := NewKeyServiceMock()
+	mockService.RestoreKeyFunc = func(ctx context.Context, clientId int, keyReference string) *kmsErrors.AppError {
+		if clientId != 1 || keyReference != "keyRef" {
+			t.Errorf("unexpected arguments: %d, %s", clientId, keyReference)
+		}
+		return nil
+	}
+	mockLogger := mocks.NewLoggerMock()
+	handler := NewHandler(mockService, mockLogger)
+
+	req := httptest.NewRequest("POST", "/keys/keyRef/actions/restore", nil)
+	ctx := context.WithValue(req.Context(), httpctx.TokenCtxKey, auth.Token{
+		Payload: &auth.TokenPayload{
+			Sub: "1",
+		},
+	})
+	ctx_ := context.WithValue(ctx, httpctx.RouteParamsCtxKey, map[string]string{
+		"keyReference": "keyRef",
+	})
+	req = req.WithContext(ctx_)
+	rr := httptest.NewRecorder()
+
+	err := handler.RestoreKey(rr, req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rr.Result().StatusCode != 204 {
+		t.Errorf("expected status 204, got %d", rr.Result().StatusCode)
+	}
+}
+
+func TestHandler_RestoreKey_ServiceError(t *testing.T) {
+	mockService := NewKeyServiceMock()
+	mockService.RestoreKeyFunc = func(ctx context.Context, clientId int, keyReference string) *kmsErrors.AppError {
+		return kmsErrors.NewAppError(nil, "Key is not pending deletion", 409)
+	}
+	mockLogger := mocks.NewLoggerMock()
+	handler := NewHandler(mockService, mockLogger)
+
+	req := httptest.NewRequest("POST", "/keys/keyRef/actions/restore", nil)
+	ctx := context.WithValue(req.Context(), httpctx.TokenCtxKey, auth.Token{
+		Payload: &auth.TokenPayload{
+			Sub: "1",
+		},
+	})
+	ctx_ := context.WithValue(ctx, httpctx.RouteParamsCtxKey, map[string]string{
+		"keyReference": "keyRef",
+	})
+	req = req.WithContext(ctx_)
+	rr := httptest.NewRecorder()
+
+	err := handler.RestoreKey(rr, req)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	if err.Code != 409 {
+		t.Errorf("expected status 409, got %d", err.Code)
+	}
+}
+
 func TestHandler_GetAllDev_Success(t *testing.T) {
 	mockService := NewKeyServiceMock()
-	mockService.GetAllFunc = func() ([]Key, *kmsErrors.AppError) {
+	mockService.GetAllFunc = func(ctx context.Context) ([]Key, *kmsErrors.AppError) {
 		return []Key{{
 			KeyReference: "keyRef",
 			DEK:          "dek",
@@ -563,14 +636,14 @@ func TestHandler_GetAllDev_Success(t *testing.T) {
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if !strings.Contains(rr.Body.String(), `{"id":0,"clientId":0,"keyReference":"keyRef","version":0,"dek":"dek","state":"","encoding":""}`) {
+	if !strings.Contains(rr.Body.String(), `{"id":0,"clientId":0,"keyReference":"keyRef","version":0,"dek":"dek","state":"","encoding":"","algorithm":"","createdAt":"0001-01-01T00:00:00Z"}`) {
 		t.Errorf("unexpected body: %v", rr.Body.String())
 	}
 }
 
 func TestHandler_GetAllDev_ServiceError(t *testing.T) {
 	mockService := NewKeyServiceMock()
-	mockService.GetAllFunc = func() ([]Key, *kmsErrors.AppError) {
+	mockService.GetAllFunc = func(ctx context.Context) ([]Key, *kmsErrors.AppError) {
 		return nil, kmsErrors.NewAppError(nil, "service error", 500)
 	}
 	mockLogger := mocks.NewLoggerMock()
@@ -587,3 +660,231 @@ func TestHandler_GetAllDev_ServiceError(t *testing.T) {
 		t.Errorf("expected service error, got: %v", err.Message)
 	}
 }
+
+func TestHandler_DestroyKeyVersion_Success(t *testing.T) {
+	mockService := NewKeyServiceMock()
+	mockService.DestroyKeyVersionFunc = func(ctx context.Context, clientId int, keyReference string, version int) *kmsErrors.AppError {
+		if clientId != 1 || keyReference != "keyRef" || version != 2 {
+			t.Errorf("unexpected arguments: %d, %s, %d", clientId, keyReference, version)
+		}
+		return nil
+	}
+	mockLogger := mocks.NewLoggerMock()
+	handler := NewHandler(mockService, mockLogger)
+
+	req := httptest.NewRequest("DELETE", "/keys/keyRef/2/actions/destroy", nil)
+	ctx := context.WithValue(req.Context(), httpctx.TokenCtxKey, auth.Token{
+		Payload: &auth.TokenPayload{
+			Sub: "1",
+		},
+	})
+	ctx_ := context.WithValue(ctx, httpctx.RouteParamsCtxKey, map[string]string{
+		"keyReference": "keyRef",
+		"version":      "2",
+	})
+	req = req.WithContext(ctx_)
+	rr := httptest.NewRecorder()
+
+	err := handler.DestroyKeyVersion(rr, req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rr.Result().StatusCode != 204 {
+		t.Errorf("expected status 204, got %d", rr.Result().StatusCode)
+	}
+}
+
+func TestHandler_DestroyKeyVersion_InvalidVersion(t *testing.T) {
+	mockService := NewKeyServiceMock()
+	mockLogger := mocks.NewLoggerMock()
+	handler := NewHandler(mockService, mockLogger)
+
+	req := httptest.NewRequest("DELETE", "/keys/keyRef/two/actions/destroy", nil)
+	ctx := context.WithValue(req.Context(), httpctx.TokenCtxKey, auth.Token{
+		Payload: &auth.TokenPayload{
+			Sub: "1",
+		},
+	})
+	ctx_ := context.WithValue(ctx, httpctx.RouteParamsCtxKey, map[string]string{
+		"keyReference": "keyRef",
+		"version":      "two",
+	})
+	req = req.WithContext(ctx_)
+	rr := httptest.NewRecorder()
+
+	err := handler.DestroyKeyVersion(rr, req)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	if err.Code != 400 {
+		t.Errorf("expected status 400, got %d", err.Code)
+	}
+}
+
+func TestHandler_PruneKeyVersions_Success(t *testing.T) {
+	mockService := NewKeyServiceMock()
+	mockService.PruneKeyVersionsFunc = func(ctx context.Context, clientId int, keyReference string, req *PruneKeyVersionsRequest) ([]int, *kmsErrors.AppError) {
+		if req.OlderThanVersion != 3 {
+			t.Errorf("expected olderThanVersion=3, got %d", req.OlderThanVersion)
+		}
+		return []int{1, 2}, nil
+	}
+	mockLogger := mocks.NewLoggerMock()
+	handler := NewHandler(mockService, mockLogger)
+
+	req := httptest.NewRequest("POST", "/keys/keyRef/actions/prune", strings.NewReader(`{"olderThanVersion":3}`))
+	ctx := context.WithValue(req.Context(), httpctx.TokenCtxKey, auth.Token{
+		Payload: &auth.TokenPayload{
+			Sub: "1",
+		},
+	})
+	ctx_ := context.WithValue(ctx, httpctx.RouteParamsCtxKey, map[string]string{
+		"keyReference": "keyRef",
+	})
+	req = req.WithContext(ctx_)
+	rr := httptest.NewRecorder()
+
+	err := handler.PruneKeyVersions(rr, req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var resp PruneKeyVersionsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.DestroyedVersions) != 2 {
+		t.Errorf("expected 2 destroyed versions, got %v", resp.DestroyedVersions)
+	}
+}
+
+func TestHandler_PruneKeyVersions_InvalidBody(t *testing.T) {
+	mockService := NewKeyServiceMock()
+	mockLogger := mocks.NewLoggerMock()
+	handler := NewHandler(mockService, mockLogger)
+
+	req := httptest.NewRequest("POST", "/keys/keyRef/actions/prune", strings.NewReader(`{"keepLatest":3}`))
+	ctx := context.WithValue(req.Context(), httpctx.TokenCtxKey, auth.Token{
+		Payload: &auth.TokenPayload{
+			Sub: "1",
+		},
+	})
+	ctx_ := context.WithValue(ctx, httpctx.RouteParamsCtxKey, map[string]string{
+		"keyReference": "keyRef",
+	})
+	req = req.WithContext(ctx_)
+	rr := httptest.NewRecorder()
+
+	err := handler.PruneKeyVersions(rr, req)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	if err.Code != 400 {
+		t.Errorf("expected status 400, got %d", err.Code)
+	}
+}
+
+func TestHandler_ImportKey_Success(t *testing.T) {
+	mockService := NewKeyServiceMock()
+	mockService.ImportKeyFunc = func(ctx context.Context, clientId int, req *ImportKeyRequest) (*Key, *kmsErrors.AppError) {
+		if req.KeyReference != "keyRef" || req.ImportToken != "token" || req.WrappedKey != "wrapped" {
+			t.Errorf("unexpected request: %v", req)
+		}
+		return &Key{KeyReference: "keyRef", Version: 1, DEK: "dek"}, nil
+	}
+	mockLogger := mocks.NewLoggerMock()
+	handler := NewHandler(mockService, mockLogger)
+
+	req := httptest.NewRequest("POST", "/keys/actions/import", strings.NewReader(`{"keyReference":"keyRef","importToken":"token","wrappedKey":"wrapped"}`))
+	ctx := context.WithValue(req.Context(), httpctx.TokenCtxKey, auth.Token{
+		Payload: &auth.TokenPayload{
+			Sub: "1",
+		},
+	})
+	req = req.WithContext(ctx)
+	rr := httptest.NewRecorder()
+
+	err := handler.ImportKey(rr, req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var resp KeyResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Version != 1 || resp.DEK != "dek" {
+		t.Errorf("unexpected response: %v", resp)
+	}
+}
+
+func TestHandler_CreateWrappingKey_Success(t *testing.T) {
+	mockService := NewKeyServiceMock()
+	mockService.CreateWrappingKeyFunc = func(ctx context.Context, clientId int, algorithm string) (*WrappingKeyResponse, *kmsErrors.AppError) {
+		if algorithm != "ECDH-P256" {
+			t.Errorf("expected algorithm ECDH-P256, got %s", algorithm)
+		}
+		return &WrappingKeyResponse{ImportToken: "token", Algorithm: algorithm, PublicKey: "pub"}, nil
+	}
+	mockLogger := mocks.NewLoggerMock()
+	handler := NewHandler(mockService, mockLogger)
+
+	req := httptest.NewRequest("POST", "/keys/actions/import/wrapping-key", strings.NewReader(`{"algorithm":"ECDH-P256"}`))
+	ctx := context.WithValue(req.Context(), httpctx.TokenCtxKey, auth.Token{
+		Payload: &auth.TokenPayload{
+			Sub: "1",
+		},
+	})
+	req = req.WithContext(ctx)
+	rr := httptest.NewRecorder()
+
+	err := handler.CreateWrappingKey(rr, req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var resp WrappingKeyResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.ImportToken != "token" || resp.PublicKey != "pub" {
+		t.Errorf("unexpected response: %v", resp)
+	}
+}
+
+func TestHandler_GetKey_Latest(t *testing.T) {
+	mockService := NewKeyServiceMock()
+	mockService.GetKeyFunc = func(ctx context.Context, clientId int, keyReference string, version int) (*Key, *Key, *kmsErrors.AppError) {
+		if version != LatestVersion {
+			t.Errorf("expected latest version, got %d", version)
+		}
+		return &Key{Version: 2}, &Key{Version: 2}, nil
+	}
+	handler := NewHandler(mockService, mocks.NewLoggerMock())
+
+	for versionStr, code := range map[string]int{"latest": 0, "0": 400} {
+		req := httptest.NewRequest("GET", "/keys/keyRef/"+versionStr, nil)
+		ctx := context.WithValue(req.Context(), httpctx.TokenCtxKey, auth.Token{
+			Payload: &auth.TokenPayload{
+				Sub: "1",
+			},
+		})
+		ctx = context.WithValue(ctx, httpctx.RouteParamsCtxKey, map[string]string{
+			"keyReference": "keyRef",
+			"version":      versionStr,
+		})
+		req = req.WithContext(ctx)
+		rr := httptest.NewRecorder()
+
+		err := handler.GetKey(rr, req)
+		if code == 0 && err != nil {
+			t.Errorf("%s: unexpected error: %v", versionStr, err)
+		}
+		if code != 0 && (err == nil || err.Code != code) {
+			t.Errorf("%s: expected %d, got %v", versionStr, code, err)
+		}
+	}
+}
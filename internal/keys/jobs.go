@@ -0,0 +1,25 @@
+package keys
+
+import (
+	"context"
+	"time"
+)
+
+// Periodically destroy keys whose deletion window has passed, until ctx is cancelled.
+// A pass that's running then isn't cancelled with it, the caller waits for it to return instead.
+func RunDestructionJob(ctx context.Context, service *Service, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, appErr := service.DestroyPendingKeys(context.WithoutCancel(ctx), time.Now()); appErr != nil {
+			service.Logger.Error("Key destruction job failed", "error", appErr.Err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,31 +1,39 @@
 package keys
 
 import (
+	"context"
 	"errors"
 	kmsErrors "kms/pkg/errors"
+	"time"
 )
 
 // Repository mock for Key operations
 type KeyRepositoryMock struct {
-	BeginTransactionFunc    func() (KeyRepository, error)
+	BeginTransactionFunc    func(ctx context.Context) (KeyRepository, error)
 	CommitTransactionFunc   func() error
 	RollbackTransactionFunc func() error
 
-	GetKeyFunc       func(id int, keyReference string, version int) (*Key, error)
-	GetLatestKeyFunc func(id int, keyReference string) (*Key, error)
-	CreateKeyFunc    func(key *Key) (*Key, error)
-	UpdateKeyFunc    func(clientId int, keyReference string, version int, state string) error
-	DeleteFunc       func(clientId int, keyReference string) (int, error)
-	GetAllFunc       func() ([]Key, error)
+	GetKeyFunc        func(ctx context.Context, id int, keyReference string, version int) (*Key, error)
+	GetLatestKeyFunc  func(ctx context.Context, id int, keyReference string) (*Key, error)
+	CreateKeyFunc     func(ctx context.Context, key *Key) (*Key, error)
+	UpdateKeyFunc     func(ctx context.Context, clientId int, keyReference string, version int, state string) error
+	GetVersionsFunc   func(ctx context.Context, clientId int, keyReference string) ([]Key, error)
+	GetClientKeysFunc func(ctx context.Context, clientId int) ([]Key, error)
+	GetAllFunc        func(ctx context.Context) ([]Key, error)
+
+	ScheduleDeletionFunc func(ctx context.Context, clientId int, keyReference string, deleteAfter time.Time) error
+	CancelDeletionFunc   func(ctx context.Context, clientId int, keyReference string) error
+	DestroyScheduledFunc func(ctx context.Context, before time.Time) (int, error)
+	DestroyVersionFunc   func(ctx context.Context, clientId int, keyReference string, version int) error
 }
 
 func NewKeyRepositoryMock() *KeyRepositoryMock {
 	return &KeyRepositoryMock{}
 }
 
-func (m *KeyRepositoryMock) BeginTransaction() (KeyRepository, error) {
+func (m *KeyRepositoryMock) BeginTransaction(ctx context.Context) (KeyRepository, error) {
 	if m.BeginTransactionFunc != nil {
-		return m.BeginTransactionFunc()
+		return m.BeginTransactionFunc(ctx)
 	}
 	return nil, errors.New("BeginTransaction not implemented")
 }
@@ -44,92 +52,168 @@ func (m *KeyRepositoryMock) RollbackTransaction() error {
 	return errors.New("RollbackTransaction not implemented")
 }
 
-func (m *KeyRepositoryMock) GetKey(id int, keyReference string, version int) (*Key, error) {
+func (m *KeyRepositoryMock) GetKey(ctx context.Context, id int, keyReference string, version int) (*Key, error) {
 	if m.GetKeyFunc != nil {
-		return m.GetKeyFunc(id, keyReference, version)
+		return m.GetKeyFunc(ctx, id, keyReference, version)
 	}
 	return nil, errors.New("GetKey not implemented")
 }
 
-func (m *KeyRepositoryMock) GetLatestKey(id int, keyReference string) (*Key, error) {
+func (m *KeyRepositoryMock) GetLatestKey(ctx context.Context, id int, keyReference string) (*Key, error) {
 	if m.GetLatestKeyFunc != nil {
-		return m.GetLatestKeyFunc(id, keyReference)
+		return m.GetLatestKeyFunc(ctx, id, keyReference)
 	}
 	return nil, errors.New("GetKey not implemented")
 }
 
-func (m *KeyRepositoryMock) CreateKey(key *Key) (*Key, error) {
+func (m *KeyRepositoryMock) CreateKey(ctx context.Context, key *Key) (*Key, error) {
 	if m.CreateKeyFunc != nil {
-		return m.CreateKeyFunc(key)
+		return m.CreateKeyFunc(ctx, key)
 	}
 	return nil, errors.New("CreateKey not implemented")
 }
 
-func (m *KeyRepositoryMock) UpdateKey(clientId int, keyReference string, version int, state string) error {
+func (m *KeyRepositoryMock) UpdateKey(ctx context.Context, clientId int, keyReference string, version int, state string) error {
 	if m.UpdateKeyFunc != nil {
-		return m.UpdateKeyFunc(clientId, keyReference, version, state)
+		return m.UpdateKeyFunc(ctx, clientId, keyReference, version, state)
 	}
 	return errors.New("UpdateKey not implemented")
 }
 
-func (m *KeyRepositoryMock) Delete(clientId int, keyReference string) (int, error) {
-	if m.DeleteFunc != nil {
-		return m.DeleteFunc(clientId, keyReference)
+func (m *KeyRepositoryMock) ScheduleDeletion(ctx context.Context, clientId int, keyReference string, deleteAfter time.Time) error {
+	if m.ScheduleDeletionFunc != nil {
+		return m.ScheduleDeletionFunc(ctx, clientId, keyReference, deleteAfter)
+	}
+	return errors.New("ScheduleDeletion not implemented")
+}
+
+func (m *KeyRepositoryMock) CancelDeletion(ctx context.Context, clientId int, keyReference string) error {
+	if m.CancelDeletionFunc != nil {
+		return m.CancelDeletionFunc(ctx, clientId, keyReference)
+	}
+	return errors.New("CancelDeletion not implemented")
+}
+
+func (m *KeyRepositoryMock) DestroyScheduled(ctx context.Context, before time.Time) (int, error) {
+	if m.DestroyScheduledFunc != nil {
+		return m.DestroyScheduledFunc(ctx, before)
+	}
+	return 0, errors.New("DestroyScheduled not implemented")
+}
+
+func (m *KeyRepositoryMock) GetVersions(ctx context.Context, clientId int, keyReference string) ([]Key, error) {
+	if m.GetVersionsFunc != nil {
+		return m.GetVersionsFunc(ctx, clientId, keyReference)
+	}
+	return nil, errors.New("GetVersions not implemented")
+}
+
+func (m *KeyRepositoryMock) GetClientKeys(ctx context.Context, clientId int) ([]Key, error) {
+	if m.GetClientKeysFunc != nil {
+		return m.GetClientKeysFunc(ctx, clientId)
+	}
+	return nil, errors.New("GetClientKeys not implemented")
+}
+
+func (m *KeyRepositoryMock) DestroyVersion(ctx context.Context, clientId int, keyReference string, version int) error {
+	if m.DestroyVersionFunc != nil {
+		return m.DestroyVersionFunc(ctx, clientId, keyReference, version)
 	}
-	return 0, errors.New("UpdateKey not implemented")
+	return errors.New("DestroyVersion not implemented")
 }
 
-func (m *KeyRepositoryMock) GetAll() ([]Key, error) {
+func (m *KeyRepositoryMock) GetAll(ctx context.Context) ([]Key, error) {
 	if m.GetAllFunc != nil {
-		return m.GetAllFunc()
+		return m.GetAllFunc(ctx)
 	}
 	return nil, errors.New("GetAll not implemented")
 }
 
 // Service mock for Key operations
 type KeyServiceMock struct {
-	GetKeyFunc    func(clientId int, keyReference string, version int) (*Key, *Key, *kmsErrors.AppError)
-	CreateKeyFunc func(clientId int, keyReference string, version int) (*Key, *kmsErrors.AppError)
-	RotateKeyFunc func(clientId int, keyReference string) (*Key, *kmsErrors.AppError)
-	DeleteKeyFunc func(clientId int, keyReference string) *kmsErrors.AppError
-	GetAllFunc    func() ([]Key, *kmsErrors.AppError)
+	GetKeyFunc     func(ctx context.Context, clientId int, keyReference string, version int) (*Key, *Key, *kmsErrors.AppError)
+	CreateKeyFunc  func(ctx context.Context, clientId int, keyReference string, version int, algorithm string) (*Key, *kmsErrors.AppError)
+	RotateKeyFunc  func(ctx context.Context, clientId int, keyReference string) (*Key, *kmsErrors.AppError)
+	DeleteKeyFunc  func(ctx context.Context, clientId int, keyReference string) (time.Time, *kmsErrors.AppError)
+	RestoreKeyFunc func(ctx context.Context, clientId int, keyReference string) *kmsErrors.AppError
+	GetAllFunc     func(ctx context.Context) ([]Key, *kmsErrors.AppError)
+
+	CreateWrappingKeyFunc func(ctx context.Context, clientId int, algorithm string) (*WrappingKeyResponse, *kmsErrors.AppError)
+	ImportKeyFunc         func(ctx context.Context, clientId int, req *ImportKeyRequest) (*Key, *kmsErrors.AppError)
+	DestroyKeyVersionFunc func(ctx context.Context, clientId int, keyReference string, version int) *kmsErrors.AppError
+	PruneKeyVersionsFunc  func(ctx context.Context, clientId int, keyReference string, req *PruneKeyVersionsRequest) ([]int, *kmsErrors.AppError)
 }
 
 func NewKeyServiceMock() *KeyServiceMock {
 	return &KeyServiceMock{}
 }
 
-func (m *KeyServiceMock) GetKey(clientId int, keyReference string, version int) (*Key, *Key, *kmsErrors.AppError) {
+func (m *KeyServiceMock) GetKey(ctx context.Context, clientId int, keyReference string, version int) (*Key, *Key, *kmsErrors.AppError) {
 	if m.GetKeyFunc != nil {
-		return m.GetKeyFunc(clientId, keyReference, version)
+		return m.GetKeyFunc(ctx, clientId, keyReference, version)
 	}
 	return nil, nil, kmsErrors.LiftToAppError(errors.New("GetKey not implemented in mock"))
 }
 
-func (m *KeyServiceMock) CreateKey(clientId int, keyReference string, version int) (*Key, *kmsErrors.AppError) {
+func (m *KeyServiceMock) CreateKey(ctx context.Context, clientId int, keyReference string, version int, algorithm string) (*Key, *kmsErrors.AppError) {
 	if m.CreateKeyFunc != nil {
-		return m.CreateKeyFunc(clientId, keyReference, version)
+		return m.CreateKeyFunc(ctx, clientId, keyReference, version, algorithm)
 	}
 	return nil, kmsErrors.LiftToAppError(errors.New("CreateKey not implemented in mock"))
 }
 
-func (m *KeyServiceMock) RotateKey(clientId int, keyReference string) (*Key, *kmsErrors.AppError) {
+func (m *KeyServiceMock) RotateKey(ctx context.Context, clientId int, keyReference string) (*Key, *kmsErrors.AppError) {
 	if m.RotateKeyFunc != nil {
-		return m.RotateKeyFunc(clientId, keyReference)
+		return m.RotateKeyFunc(ctx, clientId, keyReference)
 	}
 	return nil, kmsErrors.LiftToAppError(errors.New("RotateKey not implemented in mock"))
 }
 
-func (m *KeyServiceMock) DeleteKey(clientId int, keyReference string) *kmsErrors.AppError {
+func (m *KeyServiceMock) CreateWrappingKey(ctx context.Context, clientId int, algorithm string) (*WrappingKeyResponse, *kmsErrors.AppError) {
+	if m.CreateWrappingKeyFunc != nil {
+		return m.CreateWrappingKeyFunc(ctx, clientId, algorithm)
+	}
+	return nil, kmsErrors.LiftToAppError(errors.New("CreateWrappingKey not implemented in mock"))
+}
+
+func (m *KeyServiceMock) ImportKey(ctx context.Context, clientId int, req *ImportKeyRequest) (*Key, *kmsErrors.AppError) {
+	if m.ImportKeyFunc != nil {
+		return m.ImportKeyFunc(ctx, clientId, req)
+	}
+	return nil, kmsErrors.LiftToAppError(errors.New("ImportKey not implemented in mock"))
+}
+
+func (m *KeyServiceMock) DeleteKey(ctx context.Context, clientId int, keyReference string) (time.Time, *kmsErrors.AppError) {
 	if m.DeleteKeyFunc != nil {
-		return m.DeleteKeyFunc(clientId, keyReference)
+		return m.DeleteKeyFunc(ctx, clientId, keyReference)
+	}
+	return time.Time{}, kmsErrors.LiftToAppError(errors.New("DeleteKey not implemented in mock"))
+}
+
+func (m *KeyServiceMock) RestoreKey(ctx context.Context, clientId int, keyReference string) *kmsErrors.AppError {
+	if m.RestoreKeyFunc != nil {
+		return m.RestoreKeyFunc(ctx, clientId, keyReference)
+	}
+	return kmsErrors.LiftToAppError(errors.New("RestoreKey not implemented in mock"))
+}
+
+func (m *KeyServiceMock) DestroyKeyVersion(ctx context.Context, clientId int, keyReference string, version int) *kmsErrors.AppError {
+	if m.DestroyKeyVersionFunc != nil {
+		return m.DestroyKeyVersionFunc(ctx, clientId, keyReference, version)
+	}
+	return kmsErrors.LiftToAppError(errors.New("DestroyKeyVersion not implemented in mock"))
+}
+
+func (m *KeyServiceMock) PruneKeyVersions(ctx context.Context, clientId int, keyReference string, req *PruneKeyVersionsRequest) ([]int, *kmsErrors.AppError) {
+	if m.PruneKeyVersionsFunc != nil {
+		return m.PruneKeyVersionsFunc(ctx, clientId, keyReference, req)
 	}
-	return kmsErrors.LiftToAppError(errors.New("RotateKey not implemented in mock"))
+	return nil, kmsErrors.LiftToAppError(errors.New("PruneKeyVersions not implemented in mock"))
 }
 
-func (m *KeyServiceMock) GetAll() ([]Key, *kmsErrors.AppError) {
+func (m *KeyServiceMock) GetAll(ctx context.Context) ([]Key, *kmsErrors.AppError) {
 	if m.GetAllFunc != nil {
-		return m.GetAllFunc()
+		return m.GetAllFunc(ctx)
 	}
 	return nil, kmsErrors.LiftToAppError(errors.New("GetAll not implemented in mock"))
 }
@@ -1,53 +1,89 @@
 package keys
 
 import (
+	"context"
+	"database/sql"
 	b64 "encoding/base64"
+	"errors"
 	"fmt"
 	c "kms/internal/bootstrap/context"
 	"kms/pkg/encryption"
 	kmsErrors "kms/pkg/errors"
 	"kms/pkg/hashing"
+	"kms/pkg/id"
+	"kms/pkg/tracing"
+	"strings"
+	"time"
 	"unicode"
 )
 
+const (
+	// KEY_DELETION_WINDOW_DAYS if not set
+	DefaultDeletionWindow = 30 * 24 * time.Hour
+
+	// Requests the latest version of a key, '/keys/{keyReference}/latest'
+	LatestVersion = 0
+)
+
 type Service struct {
-	KeyRepo    KeyRepository
-	KeyManager c.KeyManager
-	Logger     c.Logger
+	KeyRepo        KeyRepository
+	KeyManager     c.KeyManager
+	DeletionWindow time.Duration
+	WrappingKeys   *WrappingKeyStore
+	Logger         c.Logger
 }
 
-func NewService(keyRepo KeyRepository, keyManager c.KeyManager, logger c.Logger) *Service {
+func NewService(keyRepo KeyRepository, keyManager c.KeyManager, deletionWindow time.Duration, logger c.Logger) *Service {
 	return &Service{
-		KeyRepo:    keyRepo,
-		KeyManager: keyManager,
-		Logger:     logger,
+		KeyRepo:        keyRepo,
+		KeyManager:     keyManager,
+		DeletionWindow: deletionWindow,
+		WrappingKeys:   NewWrappingKeyStore(),
+		Logger:         logger,
 	}
 }
 
 type KeyRepository interface {
 	// For transaction support
-	BeginTransaction() (KeyRepository, error)
+	BeginTransaction(ctx context.Context) (KeyRepository, error)
 	CommitTransaction() error
 	RollbackTransaction() error
 
-	CreateKey(key *Key) (*Key, error)
-	GetKey(clientId int, keyReference string, version int) (*Key, error)
-	GetLatestKey(clientId int, keyReference string) (*Key, error)
-	UpdateKey(clientId int, keyReference string, version int, state string) error
-	Delete(clientId int, keyReference string) (int, error)
-	GetAll() ([]Key, error)
+	CreateKey(ctx context.Context, key *Key) (*Key, error)
+	GetKey(ctx context.Context, clientId int, keyReference string, version int) (*Key, error)
+	GetLatestKey(ctx context.Context, clientId int, keyReference string) (*Key, error)
+	UpdateKey(ctx context.Context, clientId int, keyReference string, version int, state string) error
+	ScheduleDeletion(ctx context.Context, clientId int, keyReference string, deleteAfter time.Time) error
+	CancelDeletion(ctx context.Context, clientId int, keyReference string) error
+	DestroyScheduled(ctx context.Context, before time.Time) (int, error)
+	GetVersions(ctx context.Context, clientId int, keyReference string) ([]Key, error)
+	GetClientKeys(ctx context.Context, clientId int) ([]Key, error)
+	DestroyVersion(ctx context.Context, clientId int, keyReference string, version int) error
+	GetAll(ctx context.Context) ([]Key, error)
 }
 
-func (s *Service) CreateKey(clientId int, keyReference string, version int) (*Key, *kmsErrors.AppError) {
+func (s *Service) CreateKey(ctx context.Context, clientId int, keyReference string, version int, algorithm string) (*Key, *kmsErrors.AppError) {
+	ctx, span := tracing.Start(ctx, "KeyService.CreateKey", "kms.client_id", clientId)
+	defer span.End()
+
 	if err := validateKeyReference(keyReference); err != nil {
-		return nil, kmsErrors.NewAppError(err, "Key reference does not meet minimum requirements. 0 < len <= 64 & contains only [0-9a-Z\\-]", 400)
+		return nil, kmsErrors.NewAppError(err, "Key reference does not meet minimum requirements. 0 < len <= 64 & contains only [0-9a-Z\\-]", 400).WithErrorCode(kmsErrors.CodeInvalidKeyReference)
 	}
 
-	DEKBytes, err := encryption.GenerateKey(32)
-	if err != nil {
-		return nil, kmsErrors.NewAppError(err, "Failed to generate key", 500)
+	if algorithm == "" {
+		algorithm = encryption.DefaultAlgorithm
+	}
+
+	DEKBytes, appErr := generateDEK(algorithm)
+	if appErr != nil {
+		return nil, appErr
 	}
 
+	return s.createKey(ctx, s.KeyRepo, clientId, keyReference, version, algorithm, DEKBytes)
+}
+
+// Store the given DEK through repo (s.KeyRepo or a transaction), keyReference and algorithm are expected to be validated already
+func (s *Service) createKey(ctx context.Context, repo KeyRepository, clientId int, keyReference string, version int, algorithm string, DEKBytes []byte) (*Key, *kmsErrors.AppError) {
 	keyRefSecret, err := s.KeyManager.HashKey("keyReference")
 	if err != nil {
 		return nil, kmsErrors.NewInternalServerError(err)
@@ -64,18 +100,33 @@ func (s *Service) CreateKey(clientId int, keyReference string, version int) (*Ke
 		DEK:          DEKB64,
 		State:        StateInUse,
 		Encoding:     "base64url (RFC 4648)",
+		Algorithm:    algorithm,
 	}
 
-	newKey, err := s.KeyRepo.CreateKey(key)
+	newKey, err := repo.CreateKey(ctx, key)
 	if err != nil {
-		return nil, kmsErrors.MapRepoErr(err)
+		return nil, mapKeyRepoErr(err)
 	}
 
-	s.Logger.Info("Key created", "keyId", newKey.ID, "clientId", newKey.ClientId)
+	s.Logger.Info("Key created", "keyId", newKey.ID, "clientId", newKey.ClientId, "algorithm", algorithm)
 
 	return newKey, nil
 }
 
+// Random key of the right length for the algorithm
+func generateDEK(algorithm string) ([]byte, *kmsErrors.AppError) {
+	size, err := encryption.KeySize(algorithm)
+	if err != nil {
+		return nil, newUnsupportedAlgorithmError(err)
+	}
+
+	DEKBytes, err := encryption.GenerateKey(size)
+	if err != nil {
+		return nil, kmsErrors.NewAppError(err, "Failed to generate key", 500)
+	}
+	return DEKBytes, nil
+}
+
 // Allow 0-9, a-Z and '-' in custom key reference
 func validateKeyReference(keyReference string) error {
 	if len(keyReference) < 1 || len(keyReference) > 64 {
@@ -89,9 +140,12 @@ func validateKeyReference(keyReference string) error {
 	return nil
 }
 
-func (s *Service) GetKey(clientId int, keyReference string, version int) (*Key, *Key, *kmsErrors.AppError) {
+func (s *Service) GetKey(ctx context.Context, clientId int, keyReference string, version int) (*Key, *Key, *kmsErrors.AppError) {
+	ctx, span := tracing.Start(ctx, "KeyService.GetKey", "kms.client_id", clientId)
+	defer span.End()
+
 	if err := validateKeyReference(keyReference); err != nil {
-		return nil, nil, kmsErrors.NewAppError(err, "Invalid key reference", 400)
+		return nil, nil, newInvalidReferenceError(err)
 	}
 	keyRefSecret, err := s.KeyManager.HashKey("keyReference")
 	if err != nil {
@@ -101,17 +155,26 @@ func (s *Service) GetKey(clientId int, keyReference string, version int) (*Key,
 	hashedReference := hashing.HashHS256ToB64([]byte(keyReference), keyRefSecret)
 
 	// get requested key
-	decKey, err := s.KeyRepo.GetKey(clientId, hashedReference, version)
+	var decKey *Key
+	if version == LatestVersion {
+		decKey, err = s.KeyRepo.GetLatestKey(ctx, clientId, hashedReference)
+	} else {
+		decKey, err = s.KeyRepo.GetKey(ctx, clientId, hashedReference, version)
+	}
 	if err != nil {
-		return nil, nil, kmsErrors.MapRepoErr(err)
+		return nil, nil, mapKeyRepoErr(err)
+	}
+
+	if decKey.IsPendingDeletion() {
+		return nil, nil, newPendingDeletionError(decKey)
 	}
 
 	s.Logger.Info("Key retrieved", "keyId", decKey.ID, "clientId", clientId)
 
 	// get latest key
-	encKey, err := s.KeyRepo.GetLatestKey(clientId, hashedReference)
+	encKey, err := s.KeyRepo.GetLatestKey(ctx, clientId, hashedReference)
 	if err != nil {
-		return nil, nil, kmsErrors.MapRepoErr(err)
+		return nil, nil, mapKeyRepoErr(err)
 	}
 
 	s.Logger.Info("Key retrieved", "keyId", encKey.ID, "clientId", clientId)
@@ -119,11 +182,21 @@ func (s *Service) GetKey(clientId int, keyReference string, version int) (*Key,
 	return decKey, encKey, nil
 }
 
-func (s *Service) RotateKey(clientId int, keyReference string) (key *Key, appErr *kmsErrors.AppError) {
+func (s *Service) RotateKey(ctx context.Context, clientId int, keyReference string) (*Key, *kmsErrors.AppError) {
+	ctx, span := tracing.Start(ctx, "KeyService.RotateKey", "kms.client_id", clientId)
+	defer span.End()
+
 	if err := validateKeyReference(keyReference); err != nil {
-		return nil, kmsErrors.NewAppError(err, "Invalid key reference", 400)
+		return nil, newInvalidReferenceError(err)
 	}
 
+	// new version is generated for the algorithm of the latest one
+	return s.rotateKey(ctx, clientId, keyReference, "", nil)
+}
+
+// Deprecate the latest version and store the given DEK as the next one.
+// If DEKBytes is nil, a new DEK is generated for the latest version's algorithm.
+func (s *Service) rotateKey(ctx context.Context, clientId int, keyReference string, algorithm string, DEKBytes []byte) (key *Key, appErr *kmsErrors.AppError) {
 	keyRefSecret, err := s.KeyManager.HashKey("keyReference")
 	if err != nil {
 		return nil, kmsErrors.NewInternalServerError(err)
@@ -132,22 +205,22 @@ func (s *Service) RotateKey(clientId int, keyReference string) (key *Key, appErr
 	hashedReference := hashing.HashHS256ToB64([]byte(keyReference), keyRefSecret)
 
 	// begin transaction
-	newRepo, err := s.KeyRepo.BeginTransaction()
+	// the transaction has a repo of its own, s.KeyRepo is shared by all requests
+	repo, err := s.KeyRepo.BeginTransaction(ctx)
 	if err != nil {
-		return nil, kmsErrors.MapRepoErr(err)
+		return nil, mapKeyRepoErr(err)
 	}
-	s.KeyRepo = newRepo
 
 	// ensure rollback if anything fails
 	defer func() {
-		err := s.KeyRepo.RollbackTransaction()
+		err := repo.RollbackTransaction()
 		s.Logger.Debug("Transaction rollback attempted", "error", err)
 
 		if err != nil {
 			// log the rollback error, but return the original error (if any)
 			s.Logger.Critical("Failed to rollback transaction", "error", err.Error(), "clientId", clientId, "keyReference", keyReference)
 			if appErr == nil {
-				appErr = kmsErrors.MapRepoErr(err)
+				appErr = mapKeyRepoErr(err)
 			}
 		}
 	}()
@@ -155,22 +228,37 @@ func (s *Service) RotateKey(clientId int, keyReference string) (key *Key, appErr
 	s.Logger.Info("Key rotation started", "clientId", clientId)
 
 	// get latest key
-	latest, err := s.KeyRepo.GetLatestKey(clientId, hashedReference)
+	latest, err := repo.GetLatestKey(ctx, clientId, hashedReference)
 	if err != nil {
-		return nil, kmsErrors.MapRepoErr(err)
+		return nil, mapKeyRepoErr(err)
 	}
 
 	s.Logger.Info("Latest key retrieved", "keyId", latest.ID, "clientId", clientId)
 
+	if latest.IsPendingDeletion() {
+		return nil, newPendingDeletionError(latest)
+	}
+
+	// all versions of a key share the same algorithm
+	if DEKBytes == nil {
+		algorithm = latest.Algorithm
+		DEKBytes, appErr = generateDEK(algorithm)
+		if appErr != nil {
+			return nil, appErr
+		}
+	} else if algorithm != latest.Algorithm {
+		return nil, newAlgorithmMismatchError(latest, algorithm)
+	}
+
 	// set latest key's state to deprecated
-	if err := s.KeyRepo.UpdateKey(clientId, hashedReference, latest.Version, StateDeprecated); err != nil {
-		return nil, kmsErrors.MapRepoErr(err)
+	if err := repo.UpdateKey(ctx, clientId, hashedReference, latest.Version, StateDeprecated); err != nil {
+		return nil, mapKeyRepoErr(err)
 	}
 
 	s.Logger.Info("Latest key deprecated", "keyId", latest.ID, "clientId", clientId)
 
 	// create new key
-	newKey, appErr := s.CreateKey(clientId, keyReference, latest.Version+1)
+	newKey, appErr := s.createKey(ctx, repo, clientId, keyReference, latest.Version+1, algorithm, DEKBytes)
 	if appErr != nil {
 		return nil, appErr // TODO: Wrap so it's clear which function threw an error
 	}
@@ -178,8 +266,8 @@ func (s *Service) RotateKey(clientId int, keyReference string) (key *Key, appErr
 	s.Logger.Info("New key created", "keyId", newKey.ID, "clientId", clientId)
 
 	// commit transaction
-	if err := s.KeyRepo.CommitTransaction(); err != nil {
-		return nil, kmsErrors.MapRepoErr(err)
+	if err := repo.CommitTransaction(); err != nil {
+		return nil, mapKeyRepoErr(err)
 	}
 
 	s.Logger.Info("Key rotated", "keyId", newKey.ID, "clientId", clientId)
@@ -187,9 +275,225 @@ func (s *Service) RotateKey(clientId int, keyReference string) (key *Key, appErr
 	return newKey, nil
 }
 
-func (s *Service) DeleteKey(clientId int, keyReference string) *kmsErrors.AppError {
+// Generate a one-time key pair the client can wrap existing key material with
+func (s *Service) CreateWrappingKey(ctx context.Context, clientId int, algorithm string) (*WrappingKeyResponse, *kmsErrors.AppError) {
+	_, span := tracing.Start(ctx, "KeyService.CreateWrappingKey", "kms.client_id", clientId)
+	defer span.End()
+
+	if algorithm == "" {
+		algorithm = encryption.WrapAlgRSAOAEP256
+	}
+
+	token, err := id.GenerateUUID()
+	if err != nil {
+		return nil, kmsErrors.NewInternalServerError(err)
+	}
+
+	// reserve before generating, key generation (RSA-3072) is the expensive part
+	expiresAt := time.Now().Add(WrappingKeyTtl).UTC()
+	if !s.WrappingKeys.Reserve(token, clientId, expiresAt) {
+		return nil, kmsErrors.NewAppError(
+			fmt.Errorf("client (%d) has too many pending wrapping keys", clientId),
+			"Too many pending imports",
+			429,
+		)
+	}
+
+	wrappingKey, err := encryption.GenerateWrappingKey(algorithm)
+	if err != nil {
+		s.WrappingKeys.Release(token)
+		return nil, kmsErrors.NewAppError(err, "Unsupported wrapping algorithm", 400).WithErrorCode(kmsErrors.CodeUnsupportedAlgorithm)
+	}
+
+	publicKey, err := wrappingKey.PublicKeyDER()
+	if err != nil {
+		s.WrappingKeys.Release(token)
+		return nil, kmsErrors.NewInternalServerError(err)
+	}
+	s.WrappingKeys.Set(token, wrappingKey)
+
+	s.Logger.Info("Wrapping key created", "clientId", clientId, "algorithm", algorithm)
+
+	return &WrappingKeyResponse{
+		ImportToken: token,
+		Algorithm:   algorithm,
+		PublicKey:   b64.RawURLEncoding.EncodeToString(publicKey),
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
+// Unwrap externally generated key material and store it as a new version of the reference
+func (s *Service) ImportKey(ctx context.Context, clientId int, req *ImportKeyRequest) (*Key, *kmsErrors.AppError) {
+	ctx, span := tracing.Start(ctx, "KeyService.ImportKey", "kms.client_id", clientId)
+	defer span.End()
+
+	if err := validateKeyReference(req.KeyReference); err != nil {
+		return nil, newInvalidReferenceError(err)
+	}
+
+	// consumes the wrapping key, even if the import fails
+	wrappingKey, ok := s.WrappingKeys.Take(req.ImportToken, clientId, time.Now())
+	if !ok {
+		return nil, kmsErrors.NewAppError(
+			fmt.Errorf("unknown or expired import token for client (%d)", clientId),
+			"Invalid or expired import token",
+			400,
+		)
+	}
+
+	wrapped, err := b64.RawURLEncoding.DecodeString(req.WrappedKey)
+	if err != nil {
+		return nil, kmsErrors.NewAppError(err, "Wrapped key must be base64url encoded", 400)
+	}
+
+	DEKBytes, err := wrappingKey.Unwrap(wrapped)
+	if err != nil {
+		return nil, kmsErrors.NewAppError(err, "Failed to unwrap key", 400)
+	}
+
+	keyRefSecret, err := s.KeyManager.HashKey("keyReference")
+	if err != nil {
+		return nil, kmsErrors.NewInternalServerError(err)
+	}
+
+	hashedReference := hashing.HashHS256ToB64([]byte(req.KeyReference), keyRefSecret)
+
+	latest, err := s.KeyRepo.GetLatestKey(ctx, clientId, hashedReference)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, mapKeyRepoErr(err)
+	}
+	exists := err == nil
+
+	algorithm := req.Algorithm
+	switch {
+	case exists && algorithm == "":
+		algorithm = latest.Algorithm
+	case exists && algorithm != latest.Algorithm:
+		return nil, newAlgorithmMismatchError(latest, algorithm)
+	case algorithm == "":
+		algorithm = encryption.DefaultAlgorithm
+	}
+
+	if _, err := encryption.KeySize(algorithm); err != nil {
+		return nil, newUnsupportedAlgorithmError(err)
+	}
+	if err := encryption.ValidateKey(algorithm, DEKBytes); err != nil {
+		return nil, kmsErrors.NewAppError(err, "Imported key has the wrong length for "+algorithm, 400)
+	}
+
+	var key *Key
+	var appErr *kmsErrors.AppError
+	if exists {
+		key, appErr = s.rotateKey(ctx, clientId, req.KeyReference, algorithm, DEKBytes)
+	} else {
+		key, appErr = s.createKey(ctx, s.KeyRepo, clientId, req.KeyReference, 1, algorithm, DEKBytes)
+	}
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	s.Logger.Info("Key imported", "keyId", key.ID, "clientId", clientId, "version", key.Version, "wrappingAlgorithm", wrappingKey.Algorithm)
+
+	return key, nil
+}
+
+// Schedule all versions of a key for destruction once the deletion window has passed
+func (s *Service) DeleteKey(ctx context.Context, clientId int, keyReference string) (time.Time, *kmsErrors.AppError) {
+	ctx, span := tracing.Start(ctx, "KeyService.DeleteKey", "kms.client_id", clientId)
+	defer span.End()
+
+	if err := validateKeyReference(keyReference); err != nil {
+		return time.Time{}, newInvalidReferenceError(err)
+	}
+
+	keyRefSecret, err := s.KeyManager.HashKey("keyReference")
+	if err != nil {
+		return time.Time{}, kmsErrors.NewInternalServerError(err)
+	}
+
+	hashedReference := hashing.HashHS256ToB64([]byte(keyReference), keyRefSecret)
+
+	latest, err := s.KeyRepo.GetLatestKey(ctx, clientId, hashedReference)
+	if err != nil {
+		return time.Time{}, mapKeyRepoErr(err)
+	}
+
+	if latest.IsPendingDeletion() {
+		return time.Time{}, newPendingDeletionError(latest)
+	}
+
+	deleteAfter := time.Now().Add(s.DeletionWindow).UTC()
+	if err := s.KeyRepo.ScheduleDeletion(ctx, clientId, hashedReference, deleteAfter); err != nil {
+		return time.Time{}, mapKeyRepoErr(err)
+	}
+
+	s.Logger.Info("Key scheduled for deletion", "keyId", latest.ID, "clientId", clientId, "deleteAfter", deleteAfter)
+
+	return deleteAfter, nil
+}
+
+// Cancel a scheduled deletion, as long as the key hasn't been destroyed yet
+func (s *Service) RestoreKey(ctx context.Context, clientId int, keyReference string) *kmsErrors.AppError {
+	ctx, span := tracing.Start(ctx, "KeyService.RestoreKey", "kms.client_id", clientId)
+	defer span.End()
+
+	if err := validateKeyReference(keyReference); err != nil {
+		return newInvalidReferenceError(err)
+	}
+
+	keyRefSecret, err := s.KeyManager.HashKey("keyReference")
+	if err != nil {
+		return kmsErrors.NewInternalServerError(err)
+	}
+
+	hashedReference := hashing.HashHS256ToB64([]byte(keyReference), keyRefSecret)
+
+	latest, err := s.KeyRepo.GetLatestKey(ctx, clientId, hashedReference)
+	if err != nil {
+		return mapKeyRepoErr(err)
+	}
+
+	if !latest.IsPendingDeletion() {
+		return kmsErrors.NewAppError(
+			fmt.Errorf("key (%d) is not pending deletion", latest.ID),
+			"Key is not pending deletion",
+			409,
+		)
+	}
+
+	if err := s.KeyRepo.CancelDeletion(ctx, clientId, hashedReference); err != nil {
+		return mapKeyRepoErr(err)
+	}
+
+	s.Logger.Info("Key restored", "keyId", latest.ID, "clientId", clientId)
+
+	return nil
+}
+
+// Destroy every key whose deletion window has passed before 'now'
+func (s *Service) DestroyPendingKeys(ctx context.Context, now time.Time) (int, *kmsErrors.AppError) {
+	ctx, span := tracing.Start(ctx, "KeyService.DestroyPendingKeys")
+	defer span.End()
+
+	n, err := s.KeyRepo.DestroyScheduled(ctx, now)
+	if err != nil {
+		return 0, mapKeyRepoErr(err)
+	}
+
+	if n > 0 {
+		s.Logger.Notice("Pending keys destroyed", "count", n)
+	}
+
+	return n, nil
+}
+
+// Immediately destroy a single version of a key, the latest version can never be destroyed this way
+func (s *Service) DestroyKeyVersion(ctx context.Context, clientId int, keyReference string, version int) (appErr *kmsErrors.AppError) {
+	ctx, span := tracing.Start(ctx, "KeyService.DestroyKeyVersion", "kms.client_id", clientId)
+	defer span.End()
+
 	if err := validateKeyReference(keyReference); err != nil {
-		return kmsErrors.NewAppError(err, "Invalid key reference", 400)
+		return newInvalidReferenceError(err)
 	}
 
 	keyRefSecret, err := s.KeyManager.HashKey("keyReference")
@@ -198,20 +502,195 @@ func (s *Service) DeleteKey(clientId int, keyReference string) *kmsErrors.AppErr
 	}
 
 	hashedReference := hashing.HashHS256ToB64([]byte(keyReference), keyRefSecret)
-	keyId, err := s.KeyRepo.Delete(clientId, hashedReference)
+
+	// begin transaction, so no new version can be rotated in between the checks and the delete
+	repo, err := s.KeyRepo.BeginTransaction(ctx)
 	if err != nil {
-		return kmsErrors.MapRepoErr(err)
+		return mapKeyRepoErr(err)
 	}
 
-	s.Logger.Info("Key deleted", "keyId", keyId, "clientId", clientId)
+	// ensure rollback if anything fails
+	defer func() {
+		if err := repo.RollbackTransaction(); err != nil {
+			s.Logger.Critical("Failed to rollback transaction", "error", err.Error(), "clientId", clientId, "keyReference", keyReference)
+			if appErr == nil {
+				appErr = mapKeyRepoErr(err)
+			}
+		}
+	}()
+
+	latest, err := repo.GetLatestKey(ctx, clientId, hashedReference)
+	if err != nil {
+		return mapKeyRepoErr(err)
+	}
+
+	if latest.IsPendingDeletion() {
+		return newPendingDeletionError(latest)
+	}
+
+	if version >= latest.Version {
+		return kmsErrors.NewAppError(
+			fmt.Errorf("attempted to destroy latest version (%d) of key (%d)", version, latest.ID),
+			"Latest key version can't be destroyed",
+			409,
+		)
+	}
+
+	key, err := repo.GetKey(ctx, clientId, hashedReference, version)
+	if err != nil {
+		return mapKeyRepoErr(err)
+	}
+
+	if key.State == StateInUse {
+		return newInUseError(key)
+	}
+
+	if err := repo.DestroyVersion(ctx, clientId, hashedReference, version); err != nil {
+		return mapKeyRepoErr(err)
+	}
+
+	if err := repo.CommitTransaction(); err != nil {
+		return mapKeyRepoErr(err)
+	}
+
+	s.Logger.Notice("Key version destroyed", "keyId", key.ID, "clientId", clientId, "version", version)
 
 	return nil
 }
 
-func (s *Service) GetAll() ([]Key, *kmsErrors.AppError) {
-	keys, err := s.KeyRepo.GetAll()
+// Destroy all old versions matching the request, returns the destroyed versions
+func (s *Service) PruneKeyVersions(ctx context.Context, clientId int, keyReference string, req *PruneKeyVersionsRequest) (destroyed []int, appErr *kmsErrors.AppError) {
+	ctx, span := tracing.Start(ctx, "KeyService.PruneKeyVersions", "kms.client_id", clientId)
+	defer span.End()
+
+	if req.OlderThanVersion <= 0 && req.DeprecatedBefore == nil {
+		return nil, kmsErrors.NewAppError(
+			fmt.Errorf("no prune criteria given"),
+			"Either 'olderThanVersion' or 'deprecatedBefore' is required",
+			400,
+		)
+	}
+
+	if err := validateKeyReference(keyReference); err != nil {
+		return nil, newInvalidReferenceError(err)
+	}
+
+	keyRefSecret, err := s.KeyManager.HashKey("keyReference")
+	if err != nil {
+		return nil, kmsErrors.NewInternalServerError(err)
+	}
+
+	hashedReference := hashing.HashHS256ToB64([]byte(keyReference), keyRefSecret)
+
+	// begin transaction, so versions are either all destroyed or none are
+	repo, err := s.KeyRepo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, mapKeyRepoErr(err)
+	}
+
+	// ensure rollback if anything fails
+	defer func() {
+		if err := repo.RollbackTransaction(); err != nil {
+			s.Logger.Critical("Failed to rollback transaction", "error", err.Error(), "clientId", clientId, "keyReference", keyReference)
+			if appErr == nil {
+				destroyed, appErr = nil, mapKeyRepoErr(err)
+			}
+		}
+	}()
+
+	versions, err := repo.GetVersions(ctx, clientId, hashedReference)
+	if err != nil {
+		return nil, mapKeyRepoErr(err)
+	}
+	if len(versions) == 0 {
+		return nil, kmsErrors.NewAppError(
+			fmt.Errorf("no versions found for key (%s)", hashedReference),
+			"Not found",
+			404,
+		).WithErrorCode(kmsErrors.CodeKeyNotFound)
+	}
+
+	latest := versions[len(versions)-1]
+	if latest.IsPendingDeletion() {
+		return nil, newPendingDeletionError(&latest)
+	}
+
+	destroyed = []int{}
+	// skip latest version
+	for i, key := range versions[:len(versions)-1] {
+		if req.OlderThanVersion > 0 && key.Version >= req.OlderThanVersion {
+			continue
+		}
+		// a version is deprecated once its successor is created
+		if req.DeprecatedBefore != nil && !versions[i+1].CreatedAt.Before(*req.DeprecatedBefore) {
+			continue
+		}
+		if key.State == StateInUse {
+			return nil, newInUseError(&key)
+		}
+		if err := repo.DestroyVersion(ctx, clientId, hashedReference, key.Version); err != nil {
+			return nil, mapKeyRepoErr(err)
+		}
+		destroyed = append(destroyed, key.Version)
+	}
+
+	if err := repo.CommitTransaction(); err != nil {
+		return nil, mapKeyRepoErr(err)
+	}
+
+	s.Logger.Notice("Key versions pruned", "keyId", latest.ID, "clientId", clientId, "versions", destroyed)
+
+	return destroyed, nil
+}
+
+func newInUseError(k *Key) *kmsErrors.AppError {
+	return kmsErrors.NewAppError(
+		fmt.Errorf("key (%d) version %d is in use", k.ID, k.Version),
+		"Key version is in use",
+		409,
+	).WithErrorCode(kmsErrors.CodeKeyVersionInUse)
+}
+
+func newUnsupportedAlgorithmError(err error) *kmsErrors.AppError {
+	return kmsErrors.NewAppError(err, "Unsupported algorithm, must be one of "+strings.Join(encryption.Algorithms, ", "), 400).WithErrorCode(kmsErrors.CodeUnsupportedAlgorithm)
+}
+
+func newAlgorithmMismatchError(k *Key, algorithm string) *kmsErrors.AppError {
+	return kmsErrors.NewAppError(
+		fmt.Errorf("key (%d) uses %s, got %s", k.ID, k.Algorithm, algorithm),
+		"Algorithm does not match the key's algorithm ("+k.Algorithm+")",
+		409,
+	).WithErrorCode(kmsErrors.CodeAlgorithmMismatch)
+}
+
+func newPendingDeletionError(k *Key) *kmsErrors.AppError {
+	return kmsErrors.NewAppError(
+		fmt.Errorf("key (%d) is pending deletion until %v", k.ID, k.DeleteAfter),
+		"Key is pending deletion",
+		409,
+	).WithErrorCode(kmsErrors.CodeKeyPendingDeletion)
+}
+
+func newInvalidReferenceError(err error) *kmsErrors.AppError {
+	return kmsErrors.NewAppError(err, "Invalid key reference", 400).WithErrorCode(kmsErrors.CodeInvalidKeyReference)
+}
+
+// Every entity looked up here is a key (version)
+func mapKeyRepoErr(err error) *kmsErrors.AppError {
+	appErr := kmsErrors.MapRepoErr(err)
+	if appErr.Code == 404 {
+		appErr.ErrorCode = kmsErrors.CodeKeyNotFound
+	}
+	return appErr
+}
+
+func (s *Service) GetAll(ctx context.Context) ([]Key, *kmsErrors.AppError) {
+	ctx, span := tracing.Start(ctx, "KeyService.GetAll")
+	defer span.End()
+
+	keys, err := s.KeyRepo.GetAll(ctx)
 	if err != nil {
-		return nil, kmsErrors.MapRepoErr(err)
+		return nil, mapKeyRepoErr(err)
 	}
 	return keys, nil
 }
@@ -1,17 +1,24 @@
 package keys
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"kms/internal/test"
 	"kms/internal/test/mocks"
+	"kms/pkg/encryption"
 	"kms/pkg/hashing"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestService_CreateKey_Success(t *testing.T) {
 	mockRepo := NewKeyRepositoryMock()
-	mockRepo.CreateKeyFunc = func(key *Key) (*Key, error) {
+	mockRepo.CreateKeyFunc = func(ctx context.Context, key *Key) (*Key, error) {
 		return key, nil
 	}
 	mockLogger := mocks.NewLoggerMock()
@@ -21,9 +28,9 @@ func TestService_CreateKey_Success(t *testing.T) {
 		return keyRefSecret, nil
 	}
 
-	service := NewService(mockRepo, mockKeyManager, mockLogger)
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
 
-	key, err := service.CreateKey(1, "testKey", 1)
+	key, err := service.CreateKey(context.Background(), 1, "testKey", 1, "")
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -44,9 +51,9 @@ func TestService_CreateKey_ValidateReferenceError(t *testing.T) {
 	mockLogger := mocks.NewLoggerMock()
 	mockKeyManager := mocks.NewKeyManagerMock()
 
-	service := NewService(mockRepo, mockKeyManager, mockLogger)
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
 
-	_, err := service.CreateKey(1, "invalid/key", 1)
+	_, err := service.CreateKey(context.Background(), 1, "invalid/key", 1, "")
 	if err == nil || !strings.Contains(err.Err.Error(), "invalid character in keyreference") {
 		t.Fatalf("expected validation error, got %v", err)
 	}
@@ -63,9 +70,9 @@ func TestService_CreateKey_HashKeyError(t *testing.T) {
 		return nil, errors.New("hashing error")
 	}
 
-	service := NewService(mockRepo, mockKeyManager, mockLogger)
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
 
-	_, err := service.CreateKey(1, "testKey", 1)
+	_, err := service.CreateKey(context.Background(), 1, "testKey", 1, "")
 	if err == nil || !strings.Contains(err.Err.Error(), "hashing error") {
 		t.Fatalf("expected hashing error, got %v", err)
 	}
@@ -76,7 +83,7 @@ func TestService_CreateKey_HashKeyError(t *testing.T) {
 
 func TestService_CreateKey_RepoError(t *testing.T) {
 	mockRepo := NewKeyRepositoryMock()
-	mockRepo.CreateKeyFunc = func(key *Key) (*Key, error) {
+	mockRepo.CreateKeyFunc = func(ctx context.Context, key *Key) (*Key, error) {
 		return nil, errors.New("repo error")
 	}
 	mockLogger := mocks.NewLoggerMock()
@@ -85,9 +92,9 @@ func TestService_CreateKey_RepoError(t *testing.T) {
 		return []byte("keyRefSecret"), nil
 	}
 
-	service := NewService(mockRepo, mockKeyManager, mockLogger)
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
 
-	_, err := service.CreateKey(1, "testKey", 1)
+	_, err := service.CreateKey(context.Background(), 1, "testKey", 1, "")
 	if err == nil || !strings.Contains(err.Err.Error(), "repo error") {
 		t.Fatalf("expected repo error, got %v", err)
 	}
@@ -96,12 +103,59 @@ func TestService_CreateKey_RepoError(t *testing.T) {
 	}
 }
 
+func TestService_CreateKey_Algorithms(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		expected  string
+		size      int
+	}{
+		{"", encryption.AlgAES256GCM, 32},
+		{encryption.AlgAES128GCM, encryption.AlgAES128GCM, 16},
+		{encryption.AlgXChaCha20Poly1305, encryption.AlgXChaCha20Poly1305, 32},
+		{encryption.AlgAES256SIV, encryption.AlgAES256SIV, 64},
+		{encryption.AlgHMACSHA512, encryption.AlgHMACSHA512, 64},
+	}
+	for _, tt := range tests {
+		mockRepo := NewKeyRepositoryMock()
+		mockRepo.CreateKeyFunc = func(ctx context.Context, key *Key) (*Key, error) {
+			return key, nil
+		}
+		mockKeyManager := mocks.NewKeyManagerMock()
+		mockKeyManager.HashKeyFunc = func(key string) ([]byte, error) {
+			return []byte("keyRefSecret"), nil
+		}
+		service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mocks.NewLoggerMock())
+
+		key, appErr := service.CreateKey(context.Background(), 1, "testKey", 1, tt.algorithm)
+		if appErr != nil {
+			t.Fatalf("expected no error, got %v", appErr)
+		}
+		if key.Algorithm != tt.expected {
+			t.Errorf("expected algorithm %s, got %s", tt.expected, key.Algorithm)
+		}
+		DEKBytes, err := base64.RawURLEncoding.DecodeString(key.DEK)
+		test.RequireErrNil(t, err)
+		if len(DEKBytes) != tt.size {
+			t.Errorf("expected %d byte key for %s, got %d", tt.size, tt.expected, len(DEKBytes))
+		}
+	}
+}
+
+func TestService_CreateKey_UnsupportedAlgorithm(t *testing.T) {
+	service := NewService(NewKeyRepositoryMock(), mocks.NewKeyManagerMock(), DefaultDeletionWindow, mocks.NewLoggerMock())
+
+	_, appErr := service.CreateKey(context.Background(), 1, "testKey", 1, "DES")
+	if appErr == nil || appErr.Code != 400 {
+		t.Fatalf("expected 400, got %v", appErr)
+	}
+}
+
 func TestService_GetKey_Success(t *testing.T) {
 	mockRepo := NewKeyRepositoryMock()
-	mockRepo.GetKeyFunc = func(clientId int, keyReference string, version int) (*Key, error) {
+	mockRepo.GetKeyFunc = func(ctx context.Context, clientId int, keyReference string, version int) (*Key, error) {
 		return &Key{ClientId: clientId, KeyReference: "testKey", Version: 1}, nil
 	}
-	mockRepo.GetLatestKeyFunc = func(clientId int, keyReference string) (*Key, error) {
+	mockRepo.GetLatestKeyFunc = func(ctx context.Context, clientId int, keyReference string) (*Key, error) {
 		return &Key{ClientId: clientId, KeyReference: "testKey", Version: 2}, nil
 	}
 	mockLogger := mocks.NewLoggerMock()
@@ -110,9 +164,9 @@ func TestService_GetKey_Success(t *testing.T) {
 		return []byte("keyRefSecret"), nil
 	}
 
-	service := NewService(mockRepo, mockKeyManager, mockLogger)
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
 
-	decKey, encKey, err := service.GetKey(1, "testKey", 1)
+	decKey, encKey, err := service.GetKey(context.Background(), 1, "testKey", 1)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -143,8 +197,8 @@ func TestService_GetKey_KeyManagerError(t *testing.T) {
 	mockKeyManager.HashKeyFunc = func(key string) ([]byte, error) {
 		return nil, errors.New("hashing error")
 	}
-	service := NewService(mockRepo, mockKeyManager, mockLogger)
-	_, _, err := service.GetKey(1, "testKey", 1)
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
+	_, _, err := service.GetKey(context.Background(), 1, "testKey", 1)
 	if err == nil || err.Err.Error() != "hashing error" {
 		t.Fatalf("expected hashing error, got %v", err)
 	}
@@ -155,13 +209,13 @@ func TestService_GetKey_KeyManagerError(t *testing.T) {
 
 func TestService_GetKey_RepoError(t *testing.T) {
 	mockRepo := NewKeyRepositoryMock()
-	mockRepo.GetKeyFunc = func(id int, keyReference string, v int) (*Key, error) {
+	mockRepo.GetKeyFunc = func(ctx context.Context, id int, keyReference string, v int) (*Key, error) {
 		return nil, errors.New("repo error")
 	}
 	mockLogger := mocks.NewLoggerMock()
 	mockKeyManager := mocks.NewKeyManagerMock()
-	service := NewService(mockRepo, mockKeyManager, mockLogger)
-	_, _, err := service.GetKey(1, "testKey", 1)
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
+	_, _, err := service.GetKey(context.Background(), 1, "testKey", 1)
 	if err == nil || err.Err.Error() != "repo error" {
 		t.Fatalf("expected repo error, got %v", err)
 	}
@@ -173,16 +227,16 @@ func TestService_GetKey_RepoError(t *testing.T) {
 func TestService_RotateKey_Success(t *testing.T) {
 	clientId := 1
 	mockRepo := NewKeyRepositoryMock()
-	mockRepo.BeginTransactionFunc = func() (KeyRepository, error) { return mockRepo, nil }
+	mockRepo.BeginTransactionFunc = func(ctx context.Context) (KeyRepository, error) { return mockRepo, nil }
 	mockRepo.CommitTransactionFunc = func() error { return nil }
 	mockRepo.RollbackTransactionFunc = func() error { return nil }
-	mockRepo.GetLatestKeyFunc = func(c int, k string) (*Key, error) {
-		return &Key{ClientId: clientId, KeyReference: "testKey", Version: 1}, nil
+	mockRepo.GetLatestKeyFunc = func(ctx context.Context, c int, k string) (*Key, error) {
+		return &Key{ClientId: clientId, KeyReference: "testKey", Version: 1, Algorithm: encryption.AlgAES256GCM}, nil
 	}
-	mockRepo.UpdateKeyFunc = func(clientId int, keyRef string, version int, state string) error {
+	mockRepo.UpdateKeyFunc = func(ctx context.Context, clientId int, keyRef string, version int, state string) error {
 		return nil
 	}
-	mockRepo.CreateKeyFunc = func(key *Key) (*Key, error) {
+	mockRepo.CreateKeyFunc = func(ctx context.Context, key *Key) (*Key, error) {
 		return &Key{ClientId: clientId, KeyReference: "testKey", Version: 2}, nil
 	}
 
@@ -199,9 +253,9 @@ func TestService_RotateKey_Success(t *testing.T) {
 		return refKey, nil
 	}
 
-	service := NewService(mockRepo, mockKeyManager, mockLogger)
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
 
-	key, appErr := service.RotateKey(1, "keyRef")
+	key, appErr := service.RotateKey(context.Background(), 1, "keyRef")
 	if appErr != nil {
 		t.Fatalf("unexpected error: %v", appErr)
 	}
@@ -225,6 +279,36 @@ func TestService_RotateKey_Success(t *testing.T) {
 	}
 }
 
+// Other requests keep using the shared repo while a rotation runs
+func TestService_RotateKey_OwnTransaction(t *testing.T) {
+	sharedRepo := NewKeyRepositoryMock()
+	txRepo := NewKeyRepositoryMock()
+	sharedRepo.BeginTransactionFunc = func(ctx context.Context) (KeyRepository, error) { return txRepo, nil }
+	committed := false
+	txRepo.CommitTransactionFunc = func() error { committed = true; return nil }
+	txRepo.RollbackTransactionFunc = func() error { return nil }
+	txRepo.GetLatestKeyFunc = func(ctx context.Context, c int, k string) (*Key, error) {
+		return &Key{ClientId: c, KeyReference: k, Version: 1, Algorithm: encryption.AlgAES256GCM}, nil
+	}
+	txRepo.UpdateKeyFunc = func(ctx context.Context, clientId int, keyRef string, version int, state string) error {
+		return nil
+	}
+	txRepo.CreateKeyFunc = func(ctx context.Context, key *Key) (*Key, error) {
+		return key, nil
+	}
+
+	service := NewService(sharedRepo, mocks.NewKeyManagerMock(), DefaultDeletionWindow, mocks.NewLoggerMock())
+	if _, appErr := service.RotateKey(context.Background(), 1, "keyRef"); appErr != nil {
+		t.Fatalf("unexpected error: %v", appErr)
+	}
+	if !committed {
+		t.Error("expected the transaction to be committed")
+	}
+	if service.KeyRepo != KeyRepository(sharedRepo) {
+		t.Error("expected the service to keep its shared repo")
+	}
+}
+
 func TestService_RotateKey_MissingHashKey(t *testing.T) {
 	mockRepo := NewKeyRepositoryMock()
 	mockLogger := mocks.NewLoggerMock()
@@ -233,9 +317,9 @@ func TestService_RotateKey_MissingHashKey(t *testing.T) {
 		return nil, errors.New("keymanager error")
 	}
 
-	service := NewService(mockRepo, mockKeyManager, mockLogger)
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
 
-	_, appErr := service.RotateKey(1, "keyRef")
+	_, appErr := service.RotateKey(context.Background(), 1, "keyRef")
 	if appErr == nil {
 		t.Fatal("expected key manager error")
 	}
@@ -252,24 +336,24 @@ func TestService_RotateKey_MissingHashKey(t *testing.T) {
 func TestService_RotateKey_RepoError(t *testing.T) {
 
 	tests := []struct {
-		getLatestFunc func(c int, r string) (*Key, error)
-		updateFunc    func(c int, r string, v int, s string) error
+		getLatestFunc func(ctx context.Context, c int, r string) (*Key, error)
+		updateFunc    func(ctx context.Context, c int, r string, v int, s string) error
 	}{
 		{
-			func(c int, r string) (*Key, error) { return nil, errors.New("repo error") },
-			func(c int, r string, v int, s string) error { return nil },
+			func(ctx context.Context, c int, r string) (*Key, error) { return nil, errors.New("repo error") },
+			func(ctx context.Context, c int, r string, v int, s string) error { return nil },
 		},
 		{
-			func(c int, r string) (*Key, error) {
-				return &Key{ClientId: 1, KeyReference: "testKey", Version: 1}, nil
+			func(ctx context.Context, c int, r string) (*Key, error) {
+				return &Key{ClientId: 1, KeyReference: "testKey", Version: 1, Algorithm: encryption.AlgAES256GCM}, nil
 			},
-			func(c int, r string, v int, s string) error { return errors.New("repo error") },
+			func(ctx context.Context, c int, r string, v int, s string) error { return errors.New("repo error") },
 		},
 	}
 
 	for _, tt := range tests {
 		mockRepo := NewKeyRepositoryMock()
-		mockRepo.BeginTransactionFunc = func() (KeyRepository, error) { return mockRepo, nil }
+		mockRepo.BeginTransactionFunc = func(ctx context.Context) (KeyRepository, error) { return mockRepo, nil }
 		mockRepo.CommitTransactionFunc = func() error { return nil }
 		mockRepo.UpdateKeyFunc = tt.updateFunc
 		mockRepo.GetLatestKeyFunc = tt.getLatestFunc
@@ -287,9 +371,9 @@ func TestService_RotateKey_RepoError(t *testing.T) {
 			return refKey, nil
 		}
 
-		service := NewService(mockRepo, mockKeyManager, mockLogger)
+		service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
 
-		_, appErr := service.RotateKey(1, "keyRef")
+		_, appErr := service.RotateKey(context.Background(), 1, "keyRef")
 		if appErr == nil {
 			t.Fatal("expected repo error")
 		}
@@ -321,15 +405,15 @@ func TestService_RotateKey_RepoError(t *testing.T) {
 func TestService_RotateKey_ServiceError(t *testing.T) {
 	clientId := 1
 	mockRepo := NewKeyRepositoryMock()
-	mockRepo.BeginTransactionFunc = func() (KeyRepository, error) { return mockRepo, nil }
+	mockRepo.BeginTransactionFunc = func(ctx context.Context) (KeyRepository, error) { return mockRepo, nil }
 	mockRepo.CommitTransactionFunc = func() error { return nil }
-	mockRepo.GetLatestKeyFunc = func(c int, k string) (*Key, error) {
-		return &Key{ClientId: clientId, KeyReference: "testKey", Version: 1}, nil
+	mockRepo.GetLatestKeyFunc = func(ctx context.Context, c int, k string) (*Key, error) {
+		return &Key{ClientId: clientId, KeyReference: "testKey", Version: 1, Algorithm: encryption.AlgAES256GCM}, nil
 	}
-	mockRepo.UpdateKeyFunc = func(clientId int, keyRef string, version int, state string) error {
+	mockRepo.UpdateKeyFunc = func(ctx context.Context, clientId int, keyRef string, version int, state string) error {
 		return nil
 	}
-	mockRepo.CreateKeyFunc = func(key *Key) (*Key, error) {
+	mockRepo.CreateKeyFunc = func(ctx context.Context, key *Key) (*Key, error) {
 		return nil, errors.New("repo error")
 	}
 
@@ -346,9 +430,9 @@ func TestService_RotateKey_ServiceError(t *testing.T) {
 		return refKey, nil
 	}
 
-	service := NewService(mockRepo, mockKeyManager, mockLogger)
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
 
-	key, appErr := service.RotateKey(1, "keyRef")
+	key, appErr := service.RotateKey(context.Background(), 1, "keyRef")
 
 	if key != nil {
 		t.Fatalf("expected key = nil, got %v", key)
@@ -380,15 +464,15 @@ func TestService_RotateKey_ServiceError(t *testing.T) {
 
 func TestService_RotateKey_BeginTransactionError(t *testing.T) {
 	mockRepo := NewKeyRepositoryMock()
-	mockRepo.BeginTransactionFunc = func() (KeyRepository, error) {
+	mockRepo.BeginTransactionFunc = func(ctx context.Context) (KeyRepository, error) {
 		return nil, errors.New("begin transaction error")
 	}
 	mockLogger := mocks.NewLoggerMock()
 	mockKeyManager := mocks.NewKeyManagerMock()
 
-	service := NewService(mockRepo, mockKeyManager, mockLogger)
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
 
-	_, appErr := service.RotateKey(1, "keyRef")
+	_, appErr := service.RotateKey(context.Background(), 1, "keyRef")
 
 	if appErr == nil || appErr.Err.Error() != "begin transaction error" {
 		t.Fatalf("expected begin transaction error, got %v", appErr)
@@ -402,16 +486,16 @@ func TestService_RotateKey_BeginTransactionError(t *testing.T) {
 func TestService_RotateKey_CommitTransactionError(t *testing.T) {
 	clientId := 1
 	mockRepo := NewKeyRepositoryMock()
-	mockRepo.BeginTransactionFunc = func() (KeyRepository, error) { return mockRepo, nil }
+	mockRepo.BeginTransactionFunc = func(ctx context.Context) (KeyRepository, error) { return mockRepo, nil }
 	mockRepo.CommitTransactionFunc = func() error { return errors.New("commit transaction error") }
 	mockRepo.RollbackTransactionFunc = func() error { return nil }
-	mockRepo.GetLatestKeyFunc = func(c int, k string) (*Key, error) {
-		return &Key{ClientId: clientId, KeyReference: "testKey", Version: 1}, nil
+	mockRepo.GetLatestKeyFunc = func(ctx context.Context, c int, k string) (*Key, error) {
+		return &Key{ClientId: clientId, KeyReference: "testKey", Version: 1, Algorithm: encryption.AlgAES256GCM}, nil
 	}
-	mockRepo.UpdateKeyFunc = func(clientId int, keyRef string, version int, state string) error {
+	mockRepo.UpdateKeyFunc = func(ctx context.Context, clientId int, keyRef string, version int, state string) error {
 		return nil
 	}
-	mockRepo.CreateKeyFunc = func(key *Key) (*Key, error) {
+	mockRepo.CreateKeyFunc = func(ctx context.Context, key *Key) (*Key, error) {
 		return &Key{ClientId: clientId, KeyReference: "testKey", Version: 2}, nil
 	}
 
@@ -423,9 +507,9 @@ func TestService_RotateKey_CommitTransactionError(t *testing.T) {
 		return refKey, nil
 	}
 
-	service := NewService(mockRepo, mockKeyManager, mockLogger)
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
 
-	key, appErr := service.RotateKey(1, "keyRef")
+	key, appErr := service.RotateKey(context.Background(), 1, "keyRef")
 
 	if appErr == nil {
 		t.Fatal("expected commit transaction error")
@@ -447,16 +531,16 @@ func TestService_RotateKey_CommitTransactionError(t *testing.T) {
 func TestService_RotateKey_RollbackTransactionError(t *testing.T) {
 	clientId := 1
 	mockRepo := NewKeyRepositoryMock()
-	mockRepo.BeginTransactionFunc = func() (KeyRepository, error) { return mockRepo, nil }
+	mockRepo.BeginTransactionFunc = func(ctx context.Context) (KeyRepository, error) { return mockRepo, nil }
 	mockRepo.CommitTransactionFunc = func() error { return nil }
 	mockRepo.RollbackTransactionFunc = func() error { return errors.New("rollback transaction error") }
-	mockRepo.GetLatestKeyFunc = func(c int, k string) (*Key, error) {
-		return &Key{ClientId: clientId, KeyReference: "testKey", Version: 1}, nil
+	mockRepo.GetLatestKeyFunc = func(ctx context.Context, c int, k string) (*Key, error) {
+		return &Key{ClientId: clientId, KeyReference: "testKey", Version: 1, Algorithm: encryption.AlgAES256GCM}, nil
 	}
-	mockRepo.UpdateKeyFunc = func(clientId int, keyRef string, version int, state string) error {
+	mockRepo.UpdateKeyFunc = func(ctx context.Context, clientId int, keyRef string, version int, state string) error {
 		return nil
 	}
-	mockRepo.CreateKeyFunc = func(key *Key) (*Key, error) {
+	mockRepo.CreateKeyFunc = func(ctx context.Context, key *Key) (*Key, error) {
 		return nil, errors.New("repo error")
 	}
 
@@ -473,9 +557,9 @@ func TestService_RotateKey_RollbackTransactionError(t *testing.T) {
 		return refKey, nil
 	}
 
-	service := NewService(mockRepo, mockKeyManager, mockLogger)
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
 
-	key, appErr := service.RotateKey(1, "keyRef")
+	key, appErr := service.RotateKey(context.Background(), 1, "keyRef")
 
 	if key != nil {
 		t.Fatalf("expected key = nil, got %v", key)
@@ -507,8 +591,13 @@ func TestService_RotateKey_RollbackTransactionError(t *testing.T) {
 
 func TestService_DeleteKey_Success(t *testing.T) {
 	mockRepo := NewKeyRepositoryMock()
-	mockRepo.DeleteFunc = func(clientId int, keyRef string) (int, error) {
-		return 1, nil
+	mockRepo.GetLatestKeyFunc = func(ctx context.Context, clientId int, keyRef string) (*Key, error) {
+		return &Key{ClientId: clientId, KeyReference: keyRef, Version: 2}, nil
+	}
+	var scheduled time.Time
+	mockRepo.ScheduleDeletionFunc = func(ctx context.Context, clientId int, keyRef string, deleteAfter time.Time) error {
+		scheduled = deleteAfter
+		return nil
 	}
 	mockLogger := mocks.NewLoggerMock()
 	refKey := []byte("keyRefHashKey")
@@ -517,12 +606,42 @@ func TestService_DeleteKey_Success(t *testing.T) {
 		return refKey, nil
 	}
 
-	service := NewService(mockRepo, mockKeyManager, mockLogger)
+	service := NewService(mockRepo, mockKeyManager, 7*24*time.Hour, mockLogger)
 
-	appErr := service.DeleteKey(1, "keyRef")
+	before := time.Now()
+	deleteAfter, appErr := service.DeleteKey(context.Background(), 1, "keyRef")
 	if appErr != nil {
 		t.Fatalf("unexpected error: %v", appErr)
 	}
+	if !deleteAfter.Equal(scheduled) {
+		t.Errorf("expected returned deleteAfter to match scheduled, got %v and %v", deleteAfter, scheduled)
+	}
+	if deleteAfter.Before(before.Add(7*24*time.Hour)) || deleteAfter.After(time.Now().Add(7*24*time.Hour)) {
+		t.Errorf("expected deleteAfter to be 7 days from now, got %v", deleteAfter)
+	}
+}
+
+func TestService_DeleteKey_AlreadyPending(t *testing.T) {
+	deleteAfter := time.Now().Add(time.Hour)
+	mockRepo := NewKeyRepositoryMock()
+	mockRepo.GetLatestKeyFunc = func(ctx context.Context, clientId int, keyRef string) (*Key, error) {
+		return &Key{ClientId: clientId, KeyReference: keyRef, DeleteAfter: &deleteAfter}, nil
+	}
+	mockLogger := mocks.NewLoggerMock()
+	mockKeyManager := mocks.NewKeyManagerMock()
+	mockKeyManager.HashKeyFunc = func(ref string) ([]byte, error) {
+		return []byte("keyRefHashKey"), nil
+	}
+
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
+
+	_, appErr := service.DeleteKey(context.Background(), 1, "keyRef")
+	if appErr == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if appErr.Code != 409 {
+		t.Errorf("expected status 409, got %d", appErr.Code)
+	}
 }
 
 func TestService_DeleteKey_InvalidKeyReference(t *testing.T) {
@@ -530,9 +649,9 @@ func TestService_DeleteKey_InvalidKeyReference(t *testing.T) {
 	mockLogger := mocks.NewLoggerMock()
 	mockKeyManager := mocks.NewKeyManagerMock()
 
-	service := NewService(mockRepo, mockKeyManager, mockLogger)
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
 
-	appErr := service.DeleteKey(1, "invalid+keyRef")
+	_, appErr := service.DeleteKey(context.Background(), 1, "invalid+keyRef")
 	if appErr == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -547,18 +666,15 @@ func TestService_DeleteKey_InvalidKeyReference(t *testing.T) {
 
 func TestService_DeleteKey_MissingHashKey(t *testing.T) {
 	mockRepo := NewKeyRepositoryMock()
-	mockRepo.DeleteFunc = func(clientId int, keyRef string) (int, error) {
-		return 1, nil
-	}
 	mockLogger := mocks.NewLoggerMock()
 	mockKeyManager := mocks.NewKeyManagerMock()
 	mockKeyManager.HashKeyFunc = func(ref string) ([]byte, error) {
 		return nil, errors.New("keymanager error")
 	}
 
-	service := NewService(mockRepo, mockKeyManager, mockLogger)
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
 
-	appErr := service.DeleteKey(1, "keyRef")
+	_, appErr := service.DeleteKey(context.Background(), 1, "keyRef")
 
 	if appErr == nil {
 		t.Fatal("expected error, got nil")
@@ -568,8 +684,11 @@ func TestService_DeleteKey_MissingHashKey(t *testing.T) {
 
 func TestService_DeleteKey_RepoError(t *testing.T) {
 	mockRepo := NewKeyRepositoryMock()
-	mockRepo.DeleteFunc = func(clientId int, keyRef string) (int, error) {
-		return 1, errors.New("repo error")
+	mockRepo.GetLatestKeyFunc = func(ctx context.Context, clientId int, keyRef string) (*Key, error) {
+		return &Key{ClientId: clientId, KeyReference: keyRef}, nil
+	}
+	mockRepo.ScheduleDeletionFunc = func(ctx context.Context, clientId int, keyRef string, deleteAfter time.Time) error {
+		return errors.New("repo error")
 	}
 	mockLogger := mocks.NewLoggerMock()
 	refKey := []byte("keyRefHashKey")
@@ -578,9 +697,9 @@ func TestService_DeleteKey_RepoError(t *testing.T) {
 		return refKey, nil
 	}
 
-	service := NewService(mockRepo, mockKeyManager, mockLogger)
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
 
-	appErr := service.DeleteKey(1, "keyRef")
+	_, appErr := service.DeleteKey(context.Background(), 1, "keyRef")
 
 	if appErr == nil {
 		t.Fatal("expected error, got nil")
@@ -588,17 +707,116 @@ func TestService_DeleteKey_RepoError(t *testing.T) {
 	test.RequireContains(t, appErr.Err.Error(), "repo error")
 }
 
+func TestService_RestoreKey_Success(t *testing.T) {
+	deleteAfter := time.Now().Add(time.Hour)
+	mockRepo := NewKeyRepositoryMock()
+	mockRepo.GetLatestKeyFunc = func(ctx context.Context, clientId int, keyRef string) (*Key, error) {
+		return &Key{ClientId: clientId, KeyReference: keyRef, DeleteAfter: &deleteAfter}, nil
+	}
+	cancelled := false
+	mockRepo.CancelDeletionFunc = func(ctx context.Context, clientId int, keyRef string) error {
+		cancelled = true
+		return nil
+	}
+	mockLogger := mocks.NewLoggerMock()
+	mockKeyManager := mocks.NewKeyManagerMock()
+	mockKeyManager.HashKeyFunc = func(ref string) ([]byte, error) {
+		return []byte("keyRefHashKey"), nil
+	}
+
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
+
+	appErr := service.RestoreKey(context.Background(), 1, "keyRef")
+	if appErr != nil {
+		t.Fatalf("unexpected error: %v", appErr)
+	}
+	if !cancelled {
+		t.Error("expected deletion to be cancelled")
+	}
+}
+
+func TestService_RestoreKey_NotPending(t *testing.T) {
+	mockRepo := NewKeyRepositoryMock()
+	mockRepo.GetLatestKeyFunc = func(ctx context.Context, clientId int, keyRef string) (*Key, error) {
+		return &Key{ClientId: clientId, KeyReference: keyRef}, nil
+	}
+	mockLogger := mocks.NewLoggerMock()
+	mockKeyManager := mocks.NewKeyManagerMock()
+	mockKeyManager.HashKeyFunc = func(ref string) ([]byte, error) {
+		return []byte("keyRefHashKey"), nil
+	}
+
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
+
+	appErr := service.RestoreKey(context.Background(), 1, "keyRef")
+	if appErr == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if appErr.Code != 409 {
+		t.Errorf("expected status 409, got %d", appErr.Code)
+	}
+}
+
+func TestService_GetKey_PendingDeletion(t *testing.T) {
+	deleteAfter := time.Now().Add(time.Hour)
+	mockRepo := NewKeyRepositoryMock()
+	mockRepo.GetKeyFunc = func(ctx context.Context, clientId int, keyReference string, version int) (*Key, error) {
+		return &Key{ClientId: clientId, KeyReference: "testKey", Version: 1, DeleteAfter: &deleteAfter}, nil
+	}
+	mockRepo.GetLatestKeyFunc = func(ctx context.Context, clientId int, keyReference string) (*Key, error) {
+		return &Key{ClientId: clientId, KeyReference: "testKey", Version: 1, DeleteAfter: &deleteAfter}, nil
+	}
+	mockLogger := mocks.NewLoggerMock()
+	mockKeyManager := mocks.NewKeyManagerMock()
+	mockKeyManager.HashKeyFunc = func(key string) ([]byte, error) {
+		return []byte("keyRefSecret"), nil
+	}
+
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
+
+	_, _, appErr := service.GetKey(context.Background(), 1, "testKey", 1)
+	if appErr == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if appErr.Code != 409 {
+		t.Errorf("expected status 409, got %d", appErr.Code)
+	}
+}
+
+func TestService_DestroyPendingKeys(t *testing.T) {
+	now := time.Now()
+	mockRepo := NewKeyRepositoryMock()
+	mockRepo.DestroyScheduledFunc = func(ctx context.Context, before time.Time) (int, error) {
+		if !before.Equal(now) {
+			t.Errorf("expected before=%v, got %v", now, before)
+		}
+		return 2, nil
+	}
+	mockLogger := mocks.NewLoggerMock()
+	mockKeyManager := mocks.NewKeyManagerMock()
+
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
+
+	n, appErr := service.DestroyPendingKeys(context.Background(), now)
+	if appErr != nil {
+		t.Fatalf("unexpected error: %v", appErr)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 destroyed keys, got %d", n)
+	}
+}
+
 func TestService_GetAll_Success(t *testing.T) {
 	mockRepo := NewKeyRepositoryMock()
-	mockRepo.GetAllFunc = func() ([]Key, error) {
+	mockRepo.GetAllFunc = func(ctx context.Context) ([]Key, error) {
 		return []Key{{ID: 1, KeyReference: "testKey"}}, nil
 	}
 	mockLogger := mocks.NewLoggerMock()
 	mockKeyManager := mocks.NewKeyManagerMock()
 
-	service := NewService(mockRepo, mockKeyManager, mockLogger)
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
 
-	keys, err := service.GetAll()
+	keys, err := service.GetAll(context.Background())
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -609,15 +827,15 @@ func TestService_GetAll_Success(t *testing.T) {
 
 func TestService_GetAll_RepoError(t *testing.T) {
 	mockRepo := NewKeyRepositoryMock()
-	mockRepo.GetAllFunc = func() ([]Key, error) {
+	mockRepo.GetAllFunc = func(ctx context.Context) ([]Key, error) {
 		return nil, errors.New("repo error")
 	}
 	mockLogger := mocks.NewLoggerMock()
 	mockKeyManager := mocks.NewKeyManagerMock()
 
-	service := NewService(mockRepo, mockKeyManager, mockLogger)
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
 
-	_, err := service.GetAll()
+	_, err := service.GetAll(context.Background())
 	if err == nil || !strings.Contains(err.Err.Error(), "repo error") {
 		t.Fatalf("expected repo error, got %v", err)
 	}
@@ -646,3 +864,476 @@ func TestService_validateKeyReference(t *testing.T) {
 		}
 	}
 }
+
+func TestService_DestroyKeyVersion_Success(t *testing.T) {
+	// the checks and the delete only go through the transaction's repo
+	txRepo := NewKeyRepositoryMock()
+	txRepo.GetLatestKeyFunc = func(ctx context.Context, clientId int, keyRef string) (*Key, error) {
+		return &Key{ID: 3, ClientId: clientId, KeyReference: keyRef, Version: 3, State: StateInUse}, nil
+	}
+	txRepo.GetKeyFunc = func(ctx context.Context, clientId int, keyRef string, version int) (*Key, error) {
+		return &Key{ID: 1, ClientId: clientId, KeyReference: keyRef, Version: version, State: StateDeprecated}, nil
+	}
+	destroyed := 0
+	txRepo.DestroyVersionFunc = func(ctx context.Context, clientId int, keyRef string, version int) error {
+		destroyed = version
+		return nil
+	}
+	committed := false
+	txRepo.CommitTransactionFunc = func() error {
+		committed = true
+		return nil
+	}
+	txRepo.RollbackTransactionFunc = func() error {
+		return nil
+	}
+	mockRepo := NewKeyRepositoryMock()
+	mockRepo.BeginTransactionFunc = func(ctx context.Context) (KeyRepository, error) {
+		return txRepo, nil
+	}
+	mockLogger := mocks.NewLoggerMock()
+	mockKeyManager := mocks.NewKeyManagerMock()
+	mockKeyManager.HashKeyFunc = func(ref string) ([]byte, error) {
+		return []byte("keyRefHashKey"), nil
+	}
+
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
+
+	appErr := service.DestroyKeyVersion(context.Background(), 1, "keyRef", 1)
+	if appErr != nil {
+		t.Fatalf("unexpected error: %v", appErr)
+	}
+	if destroyed != 1 {
+		t.Errorf("expected version 1 to be destroyed, got %d", destroyed)
+	}
+	if !committed {
+		t.Error("expected transaction to be committed")
+	}
+}
+
+func TestService_DestroyKeyVersion_Guards(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		state   string
+	}{
+		{"latest version", 3, StateInUse},
+		{"newer than latest", 4, StateInUse},
+		{"older version in use", 2, StateInUse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rolledBack := false
+			mockRepo := NewKeyRepositoryMock()
+			mockRepo.BeginTransactionFunc = func(ctx context.Context) (KeyRepository, error) {
+				return mockRepo, nil
+			}
+			mockRepo.RollbackTransactionFunc = func() error {
+				rolledBack = true
+				return nil
+			}
+			mockRepo.GetLatestKeyFunc = func(ctx context.Context, clientId int, keyRef string) (*Key, error) {
+				return &Key{ID: 3, ClientId: clientId, KeyReference: keyRef, Version: 3, State: StateInUse}, nil
+			}
+			mockRepo.GetKeyFunc = func(ctx context.Context, clientId int, keyRef string, version int) (*Key, error) {
+				return &Key{ID: 2, ClientId: clientId, KeyReference: keyRef, Version: version, State: tt.state}, nil
+			}
+			mockRepo.DestroyVersionFunc = func(ctx context.Context, clientId int, keyRef string, version int) error {
+				t.Errorf("expected version %d not to be destroyed", version)
+				return nil
+			}
+			mockLogger := mocks.NewLoggerMock()
+			mockKeyManager := mocks.NewKeyManagerMock()
+			mockKeyManager.HashKeyFunc = func(ref string) ([]byte, error) {
+				return []byte("keyRefHashKey"), nil
+			}
+
+			service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
+
+			appErr := service.DestroyKeyVersion(context.Background(), 1, "keyRef", tt.version)
+			if appErr == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if appErr.Code != 409 {
+				t.Errorf("expected status 409, got %d", appErr.Code)
+			}
+			if !rolledBack {
+				t.Error("expected transaction to be rolled back")
+			}
+		})
+	}
+}
+
+func TestService_PruneKeyVersions(t *testing.T) {
+	now := time.Now()
+	versions := []Key{
+		{ID: 1, Version: 1, State: StateDeprecated, CreatedAt: now.Add(-72 * time.Hour)},
+		{ID: 2, Version: 2, State: StateDeprecated, CreatedAt: now.Add(-48 * time.Hour)},
+		{ID: 3, Version: 3, State: StateDeprecated, CreatedAt: now.Add(-24 * time.Hour)},
+		{ID: 4, Version: 4, State: StateInUse, CreatedAt: now},
+	}
+	deprecatedBefore := now.Add(-36 * time.Hour)
+
+	tests := []struct {
+		name     string
+		req      *PruneKeyVersionsRequest
+		expected []int
+	}{
+		{"older than version", &PruneKeyVersionsRequest{OlderThanVersion: 3}, []int{1, 2}},
+		{"older than latest", &PruneKeyVersionsRequest{OlderThanVersion: 10}, []int{1, 2, 3}},
+		{"deprecated before", &PruneKeyVersionsRequest{DeprecatedBefore: &deprecatedBefore}, []int{1}},
+		{"both criteria", &PruneKeyVersionsRequest{OlderThanVersion: 2, DeprecatedBefore: &now}, []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var destroyed []int
+			committed := false
+			mockRepo := NewKeyRepositoryMock()
+			mockRepo.BeginTransactionFunc = func(ctx context.Context) (KeyRepository, error) {
+				return mockRepo, nil
+			}
+			mockRepo.CommitTransactionFunc = func() error {
+				committed = true
+				return nil
+			}
+			mockRepo.RollbackTransactionFunc = func() error {
+				return nil
+			}
+			mockRepo.GetVersionsFunc = func(ctx context.Context, clientId int, keyRef string) ([]Key, error) {
+				return versions, nil
+			}
+			mockRepo.DestroyVersionFunc = func(ctx context.Context, clientId int, keyRef string, version int) error {
+				destroyed = append(destroyed, version)
+				return nil
+			}
+			mockLogger := mocks.NewLoggerMock()
+			mockKeyManager := mocks.NewKeyManagerMock()
+			mockKeyManager.HashKeyFunc = func(ref string) ([]byte, error) {
+				return []byte("keyRefHashKey"), nil
+			}
+
+			service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
+
+			result, appErr := service.PruneKeyVersions(context.Background(), 1, "keyRef", tt.req)
+			if appErr != nil {
+				t.Fatalf("unexpected error: %v", appErr)
+			}
+			if !committed {
+				t.Error("expected transaction to be committed")
+			}
+			if fmt.Sprint(result) != fmt.Sprint(tt.expected) || fmt.Sprint(destroyed) != fmt.Sprint(tt.expected) {
+				t.Errorf("expected versions %v to be destroyed, got %v (returned %v)", tt.expected, destroyed, result)
+			}
+		})
+	}
+}
+
+func TestService_PruneKeyVersions_MissingCriteria(t *testing.T) {
+	mockRepo := NewKeyRepositoryMock()
+	mockLogger := mocks.NewLoggerMock()
+	mockKeyManager := mocks.NewKeyManagerMock()
+
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
+
+	_, appErr := service.PruneKeyVersions(context.Background(), 1, "keyRef", &PruneKeyVersionsRequest{})
+	if appErr == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if appErr.Code != 400 {
+		t.Errorf("expected status 400, got %d", appErr.Code)
+	}
+}
+
+func TestService_PruneKeyVersions_DestroyError(t *testing.T) {
+	rolledBack := false
+	mockRepo := NewKeyRepositoryMock()
+	mockRepo.BeginTransactionFunc = func(ctx context.Context) (KeyRepository, error) {
+		return mockRepo, nil
+	}
+	mockRepo.RollbackTransactionFunc = func() error {
+		rolledBack = true
+		return nil
+	}
+	mockRepo.GetVersionsFunc = func(ctx context.Context, clientId int, keyRef string) ([]Key, error) {
+		return []Key{
+			{Version: 1, State: StateDeprecated},
+			{Version: 2, State: StateInUse},
+		}, nil
+	}
+	mockRepo.DestroyVersionFunc = func(ctx context.Context, clientId int, keyRef string, version int) error {
+		return errors.New("repo error")
+	}
+	mockLogger := mocks.NewLoggerMock()
+	mockKeyManager := mocks.NewKeyManagerMock()
+	mockKeyManager.HashKeyFunc = func(ref string) ([]byte, error) {
+		return []byte("keyRefHashKey"), nil
+	}
+
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mockLogger)
+
+	_, appErr := service.PruneKeyVersions(context.Background(), 1, "keyRef", &PruneKeyVersionsRequest{OlderThanVersion: 2})
+	if appErr == nil {
+		t.Fatal("expected error, got nil")
+	}
+	test.RequireContains(t, appErr.Err.Error(), "repo error")
+	if !rolledBack {
+		t.Error("expected transaction to be rolled back")
+	}
+}
+
+func requireWrappedKey(t *testing.T, service *Service, clientId int, alg string, dek []byte) (string, string) {
+	t.Helper()
+	resp, appErr := service.CreateWrappingKey(context.Background(), clientId, alg)
+	if appErr != nil {
+		t.Fatalf("failed to create wrapping key: %v", appErr)
+	}
+	pub, err := base64.RawURLEncoding.DecodeString(resp.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to decode public key: %v", err)
+	}
+	wrapped, err := encryption.WrapKey(resp.Algorithm, pub, dek)
+	if err != nil {
+		t.Fatalf("failed to wrap key: %v", err)
+	}
+	return resp.ImportToken, base64.RawURLEncoding.EncodeToString(wrapped)
+}
+
+func TestService_CreateWrappingKey_UnsupportedAlgorithm(t *testing.T) {
+	service := NewService(NewKeyRepositoryMock(), mocks.NewKeyManagerMock(), DefaultDeletionWindow, mocks.NewLoggerMock())
+
+	_, appErr := service.CreateWrappingKey(context.Background(), 1, "AES-KW")
+	if appErr == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if appErr.Code != 400 {
+		t.Errorf("expected status 400, got %d", appErr.Code)
+	}
+
+	// the failed attempt doesn't keep its slot
+	for i := 0; i < MaxPendingWrappingKeys; i++ {
+		if !service.WrappingKeys.Reserve(fmt.Sprint(i), 1, time.Now().Add(time.Minute)) {
+			t.Fatalf("expected reservation %d to succeed", i)
+		}
+	}
+}
+
+func TestService_CreateWrappingKey_PendingLimit(t *testing.T) {
+	service := NewService(NewKeyRepositoryMock(), mocks.NewKeyManagerMock(), DefaultDeletionWindow, mocks.NewLoggerMock())
+	for i := 0; i < MaxPendingWrappingKeys; i++ {
+		service.WrappingKeys.Reserve(fmt.Sprint(i), 1, time.Now().Add(time.Minute))
+	}
+
+	// the limit is checked before the algorithm, i.e. before any key is generated
+	_, appErr := service.CreateWrappingKey(context.Background(), 1, "AES-KW")
+	if appErr == nil || appErr.Code != 429 {
+		t.Fatalf("expected status 429, got %v", appErr)
+	}
+}
+
+func TestService_ImportKey_NewReference(t *testing.T) {
+	dek := bytes.Repeat([]byte{0x42}, 32)
+	mockRepo := NewKeyRepositoryMock()
+	mockRepo.GetLatestKeyFunc = func(ctx context.Context, clientId int, keyRef string) (*Key, error) {
+		return nil, sql.ErrNoRows
+	}
+	mockRepo.CreateKeyFunc = func(ctx context.Context, key *Key) (*Key, error) {
+		return key, nil
+	}
+	mockKeyManager := mocks.NewKeyManagerMock()
+	mockKeyManager.HashKeyFunc = func(ref string) ([]byte, error) {
+		return []byte("keyRefHashKey"), nil
+	}
+
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mocks.NewLoggerMock())
+
+	for _, alg := range []string{encryption.WrapAlgRSAOAEP256, encryption.WrapAlgECDHP256} {
+		token, wrapped := requireWrappedKey(t, service, 1, alg, dek)
+
+		key, appErr := service.ImportKey(context.Background(), 1, &ImportKeyRequest{
+			KeyReference: "keyRef",
+			ImportToken:  token,
+			WrappedKey:   wrapped,
+		})
+		if appErr != nil {
+			t.Fatalf("%s: unexpected error: %v", alg, appErr)
+		}
+		if key.Version != 1 {
+			t.Errorf("%s: expected version 1, got %d", alg, key.Version)
+		}
+		if key.DEK != base64.RawURLEncoding.EncodeToString(dek) {
+			t.Errorf("%s: expected imported DEK to be stored, got %s", alg, key.DEK)
+		}
+	}
+}
+
+func TestService_ImportKey_ExistingReference(t *testing.T) {
+	dek := bytes.Repeat([]byte{0x42}, 32)
+	mockRepo := NewKeyRepositoryMock()
+	mockRepo.BeginTransactionFunc = func(ctx context.Context) (KeyRepository, error) {
+		return mockRepo, nil
+	}
+	mockRepo.CommitTransactionFunc = func() error {
+		return nil
+	}
+	mockRepo.RollbackTransactionFunc = func() error {
+		return nil
+	}
+	mockRepo.GetLatestKeyFunc = func(ctx context.Context, clientId int, keyRef string) (*Key, error) {
+		return &Key{ID: 1, ClientId: clientId, KeyReference: keyRef, Version: 2, State: StateInUse, Algorithm: encryption.AlgAES256GCM}, nil
+	}
+	deprecated := 0
+	mockRepo.UpdateKeyFunc = func(ctx context.Context, clientId int, keyRef string, version int, state string) error {
+		deprecated = version
+		return nil
+	}
+	mockRepo.CreateKeyFunc = func(ctx context.Context, key *Key) (*Key, error) {
+		return key, nil
+	}
+	mockKeyManager := mocks.NewKeyManagerMock()
+	mockKeyManager.HashKeyFunc = func(ref string) ([]byte, error) {
+		return []byte("keyRefHashKey"), nil
+	}
+
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mocks.NewLoggerMock())
+	token, wrapped := requireWrappedKey(t, service, 1, encryption.WrapAlgECDHP256, dek)
+
+	key, appErr := service.ImportKey(context.Background(), 1, &ImportKeyRequest{
+		KeyReference: "keyRef",
+		ImportToken:  token,
+		WrappedKey:   wrapped,
+	})
+	if appErr != nil {
+		t.Fatalf("unexpected error: %v", appErr)
+	}
+	if key.Version != 3 {
+		t.Errorf("expected version 3, got %d", key.Version)
+	}
+	if deprecated != 2 {
+		t.Errorf("expected version 2 to be deprecated, got %d", deprecated)
+	}
+}
+
+func TestService_ImportKey_AlgorithmMismatch(t *testing.T) {
+	mockRepo := NewKeyRepositoryMock()
+	mockRepo.GetLatestKeyFunc = func(ctx context.Context, clientId int, keyRef string) (*Key, error) {
+		return &Key{ID: 1, ClientId: clientId, KeyReference: keyRef, Version: 1, State: StateInUse, Algorithm: encryption.AlgAES256GCM}, nil
+	}
+	mockKeyManager := mocks.NewKeyManagerMock()
+	mockKeyManager.HashKeyFunc = func(ref string) ([]byte, error) {
+		return []byte("keyRefHashKey"), nil
+	}
+
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mocks.NewLoggerMock())
+	token, wrapped := requireWrappedKey(t, service, 1, encryption.WrapAlgECDHP256, make([]byte, 32))
+
+	_, appErr := service.ImportKey(context.Background(), 1, &ImportKeyRequest{
+		KeyReference: "keyRef",
+		ImportToken:  token,
+		WrappedKey:   wrapped,
+		Algorithm:    encryption.AlgChaCha20Poly1305,
+	})
+	if appErr == nil || appErr.Code != 409 {
+		t.Fatalf("expected 409, got %v", appErr)
+	}
+}
+
+func TestService_ImportKey_Errors(t *testing.T) {
+	mockKeyManager := mocks.NewKeyManagerMock()
+	mockKeyManager.HashKeyFunc = func(ref string) ([]byte, error) {
+		return []byte("keyRefHashKey"), nil
+	}
+	mockRepo := NewKeyRepositoryMock()
+	mockRepo.GetLatestKeyFunc = func(ctx context.Context, clientId int, keyRef string) (*Key, error) {
+		return nil, sql.ErrNoRows
+	}
+	service := NewService(mockRepo, mockKeyManager, DefaultDeletionWindow, mocks.NewLoggerMock())
+
+	tests := []struct {
+		name    string
+		request func() *ImportKeyRequest
+		message string
+	}{
+		{
+			name: "unknown token",
+			request: func() *ImportKeyRequest {
+				_, wrapped := requireWrappedKey(t, service, 1, encryption.WrapAlgECDHP256, make([]byte, 32))
+				return &ImportKeyRequest{KeyReference: "keyRef", ImportToken: "unknown", WrappedKey: wrapped}
+			},
+			message: "Invalid or expired import token",
+		},
+		{
+			name: "token of other client",
+			request: func() *ImportKeyRequest {
+				token, wrapped := requireWrappedKey(t, service, 2, encryption.WrapAlgECDHP256, make([]byte, 32))
+				return &ImportKeyRequest{KeyReference: "keyRef", ImportToken: token, WrappedKey: wrapped}
+			},
+			message: "Invalid or expired import token",
+		},
+		{
+			name: "wrong key size",
+			request: func() *ImportKeyRequest {
+				token, wrapped := requireWrappedKey(t, service, 1, encryption.WrapAlgECDHP256, make([]byte, 16))
+				return &ImportKeyRequest{KeyReference: "keyRef", ImportToken: token, WrappedKey: wrapped}
+			},
+			message: "Imported key has the wrong length for AES-256-GCM",
+		},
+		{
+			name: "unsupported algorithm",
+			request: func() *ImportKeyRequest {
+				token, wrapped := requireWrappedKey(t, service, 1, encryption.WrapAlgECDHP256, make([]byte, 32))
+				return &ImportKeyRequest{KeyReference: "keyRef", ImportToken: token, WrappedKey: wrapped, Algorithm: "DES"}
+			},
+			message: "Unsupported algorithm, must be one of AES-128-GCM, AES-256-GCM, ChaCha20-Poly1305, XChaCha20-Poly1305, AES-256-SIV, HMAC-SHA512",
+		},
+		{
+			name: "not wrapped with token's key",
+			request: func() *ImportKeyRequest {
+				token, _ := requireWrappedKey(t, service, 1, encryption.WrapAlgECDHP256, make([]byte, 32))
+				_, wrapped := requireWrappedKey(t, service, 1, encryption.WrapAlgECDHP256, make([]byte, 32))
+				return &ImportKeyRequest{KeyReference: "keyRef", ImportToken: token, WrappedKey: wrapped}
+			},
+			message: "Failed to unwrap key",
+		},
+		{
+			name: "invalid reference",
+			request: func() *ImportKeyRequest {
+				return &ImportKeyRequest{KeyReference: "invalid+ref"}
+			},
+			message: "Invalid key reference",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, appErr := service.ImportKey(context.Background(), 1, tt.request())
+			if appErr == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if appErr.Code != 400 {
+				t.Errorf("expected status 400, got %d", appErr.Code)
+			}
+			if appErr.Message != tt.message {
+				t.Errorf("expected message '%s', got '%s'", tt.message, appErr.Message)
+			}
+		})
+	}
+}
+
+func TestService_GetKey_Latest(t *testing.T) {
+	mockRepo := NewKeyRepositoryMock()
+	mockRepo.GetKeyFunc = func(ctx context.Context, clientId int, keyReference string, version int) (*Key, error) {
+		t.Fatalf("expected no lookup by version")
+		return nil, nil
+	}
+	mockRepo.GetLatestKeyFunc = func(ctx context.Context, clientId int, keyReference string) (*Key, error) {
+		return &Key{ClientId: clientId, KeyReference: "testKey", Version: 3}, nil
+	}
+	service := NewService(mockRepo, mocks.NewKeyManagerMock(), DefaultDeletionWindow, mocks.NewLoggerMock())
+
+	decKey, encKey, appErr := service.GetKey(context.Background(), 1, "testKey", LatestVersion)
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	if decKey.Version != 3 || encKey.Version != 3 {
+		t.Errorf("expected version 3, got %d and %d", decKey.Version, encKey.Version)
+	}
+}
@@ -0,0 +1,102 @@
+package keys
+
+import (
+	"kms/pkg/encryption"
+	"sync"
+	"time"
+)
+
+const (
+	WrappingKeyTtl = 10 * time.Minute
+	// Limit outstanding wrapping keys so clients can't grow the store indefinitely
+	MaxPendingWrappingKeys = 10
+)
+
+type wrappingKeyEntry struct {
+	clientId int
+	// nil while the key is being generated
+	key       *encryption.WrappingKey
+	expiresAt time.Time
+}
+
+// In-memory store of one-time wrapping keys, private keys never leave the process
+type WrappingKeyStore struct {
+	mu      sync.Mutex
+	entries map[string]*wrappingKeyEntry
+}
+
+func NewWrappingKeyStore() *WrappingKeyStore {
+	return &WrappingKeyStore{
+		entries: make(map[string]*wrappingKeyEntry),
+	}
+}
+
+// Takes one of the client's pending slots for token before its key is generated, so clients at the limit cost no key generation.
+// Returns false if the client already has too many pending wrapping keys.
+func (s *WrappingKeyStore) Reserve(token string, clientId int, expiresAt time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeExpired(time.Now())
+
+	pending := 0
+	for _, e := range s.entries {
+		if e.clientId == clientId {
+			pending++
+		}
+	}
+	if pending >= MaxPendingWrappingKeys {
+		return false
+	}
+
+	s.entries[token] = &wrappingKeyEntry{
+		clientId:  clientId,
+		expiresAt: expiresAt,
+	}
+	return true
+}
+
+// Stores the key of a reserved token, no-op if the reservation has expired
+func (s *WrappingKeyStore) Set(token string, key *encryption.WrappingKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[token]; ok {
+		e.key = key
+	}
+}
+
+// Frees a reservation whose key couldn't be generated
+func (s *WrappingKeyStore) Release(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, token)
+}
+
+// Removes the wrapping key, so it can only be used once (even if the client doesn't match)
+func (s *WrappingKeyStore) Take(token string, clientId int, now time.Time) (*encryption.WrappingKey, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeExpired(now)
+
+	e, ok := s.entries[token]
+	if !ok {
+		return nil, false
+	}
+	delete(s.entries, token)
+
+	if e.clientId != clientId || e.key == nil {
+		return nil, false
+	}
+	return e.key, true
+}
+
+func (s *WrappingKeyStore) purgeExpired(now time.Time) {
+	for token, e := range s.entries {
+		if !now.Before(e.expiresAt) {
+			delete(s.entries, token)
+		}
+	}
+}
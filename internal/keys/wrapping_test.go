@@ -0,0 +1,92 @@
+package keys
+
+import (
+	"kms/pkg/encryption"
+	"testing"
+	"time"
+)
+
+func put(store *WrappingKeyStore, token string, clientId int, key *encryption.WrappingKey, expiresAt time.Time) bool {
+	if !store.Reserve(token, clientId, expiresAt) {
+		return false
+	}
+	store.Set(token, key)
+	return true
+}
+
+func TestWrappingKeyStore_TakeOnce(t *testing.T) {
+	store := NewWrappingKeyStore()
+	key := &encryption.WrappingKey{Algorithm: encryption.WrapAlgECDHP256}
+
+	if !put(store, "token", 1, key, time.Now().Add(time.Minute)) {
+		t.Fatal("expected reservation to succeed")
+	}
+
+	taken, ok := store.Take("token", 1, time.Now())
+	if !ok || taken != key {
+		t.Fatalf("expected to take stored key, got %v, %v", taken, ok)
+	}
+
+	if _, ok := store.Take("token", 1, time.Now()); ok {
+		t.Error("expected wrapping key to be usable only once")
+	}
+}
+
+func TestWrappingKeyStore_WrongClient(t *testing.T) {
+	store := NewWrappingKeyStore()
+	put(store, "token", 1, &encryption.WrappingKey{}, time.Now().Add(time.Minute))
+
+	if _, ok := store.Take("token", 2, time.Now()); ok {
+		t.Error("expected other client not to be able to take wrapping key")
+	}
+	if _, ok := store.Take("token", 1, time.Now()); ok {
+		t.Error("expected wrapping key to be consumed by failed attempt")
+	}
+}
+
+func TestWrappingKeyStore_Expired(t *testing.T) {
+	store := NewWrappingKeyStore()
+	put(store, "token", 1, &encryption.WrappingKey{}, time.Now().Add(time.Minute))
+
+	if _, ok := store.Take("token", 1, time.Now().Add(2*time.Minute)); ok {
+		t.Error("expected expired wrapping key to be rejected")
+	}
+}
+
+func TestWrappingKeyStore_PendingLimit(t *testing.T) {
+	store := NewWrappingKeyStore()
+	expiresAt := time.Now().Add(time.Minute)
+	for i := 0; i < MaxPendingWrappingKeys; i++ {
+		if !put(store, string(rune('a'+i)), 1, &encryption.WrappingKey{}, expiresAt) {
+			t.Fatalf("expected reservation %d to succeed", i)
+		}
+	}
+
+	if put(store, "one-too-many", 1, &encryption.WrappingKey{}, expiresAt) {
+		t.Error("expected reservation over the limit to fail")
+	}
+	if !put(store, "other-client", 2, &encryption.WrappingKey{}, expiresAt) {
+		t.Error("expected limit to be per client")
+	}
+}
+
+func TestWrappingKeyStore_Reserved(t *testing.T) {
+	store := NewWrappingKeyStore()
+	expiresAt := time.Now().Add(time.Minute)
+	for i := 0; i < MaxPendingWrappingKeys; i++ {
+		if !store.Reserve(string(rune('a'+i)), 1, expiresAt) {
+			t.Fatalf("expected reservation %d to succeed", i)
+		}
+	}
+
+	if store.Reserve("one-too-many", 1, expiresAt) {
+		t.Error("expected reservations to count towards the limit")
+	}
+	if _, ok := store.Take("a", 1, time.Now()); ok {
+		t.Error("expected reserved token without key not to be taken")
+	}
+	store.Release("b")
+	if !store.Reserve("after-release", 1, expiresAt) {
+		t.Error("expected released reservation to free its slot")
+	}
+}
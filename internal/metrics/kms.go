@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Served on the admin listener (METRICS_ADDR)
+var Default = NewRegistry()
+
+var (
+	httpRequests = Default.NewCounterVec("kms_http_requests_total",
+		"HTTP requests by method, route pattern and status.", "method", "route", "status")
+	httpDuration = Default.NewHistogramVec("kms_http_request_duration_seconds",
+		"HTTP request latency by method and route pattern.", DefaultBuckets, "method", "route")
+
+	KeyOperations = Default.NewCounterVec("kms_key_operations_total",
+		"Key operations by operation (e.g. generate, get) and outcome (success or the error code).", "operation", "outcome")
+	LoginFailures = Default.NewCounterVec("kms_login_failures_total",
+		"Failed password logins by reason.", "reason")
+)
+
+// Requests that didn't match a route are counted with this route label
+const UnmatchedRoute = "unmatched"
+
+var knownMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+func ObserveRequest(method, route string, status int, duration time.Duration) {
+	if route == "" {
+		// the method is client controlled, keep the label values bounded
+		route = UnmatchedRoute
+		if !knownMethods[method] {
+			method = "OTHER"
+		}
+	}
+	httpRequests.Inc(method, route, strconv.Itoa(status))
+	httpDuration.Observe(duration.Seconds(), method, route)
+}
+
+// Connection pool stats, read on every scrape
+func RegisterDBStats(r *Registry, db *sql.DB) {
+	stat := func(get func(sql.DBStats) float64) func() float64 {
+		return func() float64 { return get(db.Stats()) }
+	}
+	r.NewGaugeFunc("kms_db_open_connections", "Open database connections, in use and idle.",
+		stat(func(s sql.DBStats) float64 { return float64(s.OpenConnections) }))
+	r.NewGaugeFunc("kms_db_in_use_connections", "Database connections in use.",
+		stat(func(s sql.DBStats) float64 { return float64(s.InUse) }))
+	r.NewGaugeFunc("kms_db_idle_connections", "Idle database connections.",
+		stat(func(s sql.DBStats) float64 { return float64(s.Idle) }))
+	r.NewGaugeFunc("kms_db_max_open_connections", "Maximum open database connections, 0 is unlimited.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }))
+	r.NewCounterFunc("kms_db_wait_count_total", "Connections waited for.",
+		stat(func(s sql.DBStats) float64 { return float64(s.WaitCount) }))
+	r.NewCounterFunc("kms_db_wait_duration_seconds_total", "Time spent waiting for connections.",
+		stat(func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }))
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"database/sql"
+	"kms/internal/test"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounterVec("test_requests_total", "Requests.", "method", "path")
+	latency := r.NewHistogramVec("test_latency_seconds", "Latency.", []float64{0.1, 1}, "method")
+	r.NewGaugeFunc("test_open", "Open things.", func() float64 { return 3 })
+
+	requests.Inc("GET", "/a")
+	requests.Add(2, "GET", "/a")
+	requests.Inc("POST", `/"quoted"`)
+	latency.Observe(0.05, "GET")
+	latency.Observe(0.5, "GET")
+	latency.Observe(5, "GET")
+
+	rr := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	test.RequireContains(t, rr.Header().Get("Content-Type"), "version=0.0.4")
+	want := `# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{method="GET",le="0.1"} 1
+test_latency_seconds_bucket{method="GET",le="1"} 2
+test_latency_seconds_bucket{method="GET",le="+Inf"} 3
+test_latency_seconds_sum{method="GET"} 5.55
+test_latency_seconds_count{method="GET"} 3
+# HELP test_open Open things.
+# TYPE test_open gauge
+test_open 3
+# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{method="GET",path="/a"} 3
+test_requests_total{method="POST",path="/\"quoted\""} 1
+`
+	if rr.Body.String() != want {
+		t.Errorf("unexpected exposition:\n%s\nwant:\n%s", rr.Body.String(), want)
+	}
+}
+
+func TestRegistry_Invalid(t *testing.T) {
+	r := NewRegistry()
+	counter := r.NewCounterVec("test_total", "Test.", "label")
+
+	requirePanic(t, "duplicate name", func() { r.NewCounterVec("test_total", "Test.") })
+	requirePanic(t, "missing label value", func() { counter.Inc() })
+}
+
+func requirePanic(t *testing.T, name string, f func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s: expected panic", name)
+		}
+	}()
+	f()
+}
+
+func TestObserveRequest(t *testing.T) {
+	before := httpRequests.Value("OTHER", UnmatchedRoute, "404")
+	ObserveRequest("BREW", "", 404, time.Millisecond)
+	if got := httpRequests.Value("OTHER", UnmatchedRoute, "404"); got != before+1 {
+		t.Errorf("expected unknown methods of unmatched requests as OTHER, got %v", got)
+	}
+
+	ObserveRequest("GET", "/v1/keys/{keyReference}/latest", 200, time.Millisecond)
+	if httpRequests.Value("GET", "/v1/keys/{keyReference}/latest", "200") == 0 {
+		t.Error("expected request to be counted by route pattern")
+	}
+}
+
+func TestRegisterDBStats(t *testing.T) {
+	r := NewRegistry()
+	// not connected until used, Stats works without a server
+	db, err := sql.Open("postgres", "host=localhost dbname=none")
+	test.RequireErrNil(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(7)
+
+	RegisterDBStats(r, db)
+
+	var out strings.Builder
+	r.WriteText(&out)
+	test.RequireContains(t, out.String(), "kms_db_max_open_connections 7\n")
+	test.RequireContains(t, out.String(), "# TYPE kms_db_wait_count_total counter\n")
+}
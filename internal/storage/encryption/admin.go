@@ -1,9 +1,11 @@
 package encryption
 
 import (
+	"context"
 	"kms/internal/admin"
 	c "kms/internal/bootstrap/context"
 	"kms/internal/clients"
+	"kms/pkg/tracing"
 )
 
 type EncryptedAdminRepo struct {
@@ -18,8 +20,11 @@ func NewEncryptedAdminRepo(adminRepo admin.AdminRepository, keyManager c.KeyMana
 	}
 }
 
-func (r *EncryptedAdminRepo) GetAdmin(id int) (*clients.Client, error) {
-	client, err := r.AdminRepo.GetAdmin(id)
+func (r *EncryptedAdminRepo) GetAdmin(ctx context.Context, id int) (*clients.Client, error) {
+	ctx, span := tracing.Start(ctx, "EncryptedAdminRepo.GetAdmin")
+	defer span.End()
+
+	client, err := r.AdminRepo.GetAdmin(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -1,6 +1,7 @@
 package encryption
 
 import (
+	"context"
 	"errors"
 	"kms/internal/admin"
 	"kms/internal/clients"
@@ -33,12 +34,12 @@ func TestGetAdmin_Success(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 	mockRepo := admin.NewAdminRepositoryMock()
-	mockRepo.GetAdminFunc = func(id int) (*clients.Client, error) {
+	mockRepo.GetAdminFunc = func(ctx context.Context, id int) (*clients.Client, error) {
 		return &a, nil
 	}
 
 	repo := NewEncryptedAdminRepo(mockRepo, keyManager)
-	retrieved, err := repo.GetAdmin(1)
+	retrieved, err := repo.GetAdmin(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -50,14 +51,14 @@ func TestGetAdmin_Success(t *testing.T) {
 
 func TestAdmin_RepoError(t *testing.T) {
 	mockRepo := admin.NewAdminRepositoryMock()
-	mockRepo.GetAdminFunc = func(id int) (*clients.Client, error) {
+	mockRepo.GetAdminFunc = func(ctx context.Context, id int) (*clients.Client, error) {
 		return nil, errors.New("repo error")
 	}
 
 	keyManager := mocks.NewKeyManagerMock()
 	repo := NewEncryptedAdminRepo(mockRepo, keyManager)
 
-	_, err := repo.GetAdmin(1)
+	_, err := repo.GetAdmin(context.Background(), 1)
 	if err == nil {
 		t.Fatalf("expected error")
 	}
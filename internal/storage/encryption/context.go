@@ -2,36 +2,32 @@ package encryption
 
 import (
 	b64 "encoding/base64"
+	"fmt"
 	c "kms/internal/bootstrap/context"
 	"kms/pkg/encryption"
 	kmsErrors "kms/pkg/errors"
 	"reflect"
 )
 
-func EncryptFields(dst, src any, keyManager c.KeyManager) error {
-	vSrc := reflect.ValueOf(src)
-	vDst := reflect.ValueOf(dst)
-	if vSrc.Kind() != reflect.Ptr || vDst.Kind() != reflect.Ptr {
-		return kmsErrors.WrapError(kmsErrors.ErrRepoEncryption, map[string]interface{}{
-			"msg": "src and dst must be pointers",
-		})
-	}
-
-	vSrc = vSrc.Elem()
-	vDst = vDst.Elem()
-	if vSrc.Kind() != reflect.Struct || vDst.Kind() != reflect.Struct {
-		return kmsErrors.WrapError(kmsErrors.ErrRepoEncryption, map[string]interface{}{
-			"msg": "src and dst must point to structs",
-		})
-	}
+// Implemented by the structs with encrypted fields. The type is bound into their ciphertexts instead of the
+// struct's name, so renaming the struct doesn't make the stored rows undecryptable.
+type ContextTyper interface {
+	EncryptionContextType() string
+}
 
-	if vSrc.Type() != vDst.Type() {
-		return kmsErrors.WrapError(kmsErrors.ErrRepoEncryption, map[string]interface{}{
-			"msg": "src and dst must be the same struct type",
-		})
+// Encrypted fields are bound to their row: the ContextTyper type, the field name and all fields tagged with context:"true".
+// A ciphertext copied to another row or column fails to decrypt.
+func EncryptFields(dst, src any, keyManager c.KeyManager) error {
+	vDst, vSrc, err := structPair(dst, src)
+	if err != nil {
+		return err
 	}
 
 	tSrc := vSrc.Type()
+	rowCtx, err := rowContext(vSrc)
+	if err != nil {
+		return err
+	}
 
 	for i := 0; i < tSrc.NumField(); i++ {
 		vSrcField := vSrc.Field(i)
@@ -46,8 +42,6 @@ func EncryptFields(dst, src any, keyManager c.KeyManager) error {
 		}
 
 		if tSrcField.Tag.Get("encrypt") == "true" {
-			isEncoded := tSrcField.Tag.Get("encoded") == "true"
-
 			toEncrypt, ok := vSrcField.Interface().(string)
 			if !ok {
 				return kmsErrors.WrapError(kmsErrors.ErrRepoEncryption, map[string]interface{}{
@@ -56,39 +50,11 @@ func EncryptFields(dst, src any, keyManager c.KeyManager) error {
 				})
 			}
 
-			// Only decode to base64 if decrypted value is encoded in base64
-			var decoded []byte
-			var err error
-			if isEncoded {
-				decoded, err = b64.RawURLEncoding.DecodeString(toEncrypt)
-				if err != nil {
-					return kmsErrors.WrapError(kmsErrors.ErrRepoEncryption, map[string]interface{}{
-						"msg":   "Failed to decode field",
-						"field": tSrcField.Name,
-						"err":   err,
-					})
-				}
-			} else {
-				decoded = []byte(toEncrypt)
-			}
-
-			var encrypted []byte
-			if tSrcField.Tag.Get("key") == "kek" {
-				encrypted, err = encryption.Encrypt(decoded, keyManager.KEK())
-			} else {
-				encrypted, err = encryption.Encrypt(decoded, keyManager.DBKey())
-			}
+			encoded, err := encryptValue(tSrcField, toEncrypt, fieldContext(rowCtx, tSrcField), keyManager)
 			if err != nil {
-				return kmsErrors.WrapError(kmsErrors.ErrRepoEncryption, map[string]interface{}{
-					"msg":   "Failed to encrypt field",
-					"field": tSrcField.Name,
-					"err":   err,
-				})
+				return err
 			}
 
-			// Always encode encrypted values
-			encoded := b64.RawURLEncoding.EncodeToString(encrypted)
-
 			vDstField.SetString(encoded)
 		} else {
 			vDstField.Set(vSrcField)
@@ -99,29 +65,28 @@ func EncryptFields(dst, src any, keyManager c.KeyManager) error {
 }
 
 func DecryptFields(dst, src any, keyManager c.KeyManager) error {
-	vSrc := reflect.ValueOf(src)
-	vDst := reflect.ValueOf(dst)
-	if vSrc.Kind() != reflect.Ptr || vDst.Kind() != reflect.Ptr {
-		return kmsErrors.WrapError(kmsErrors.ErrRepoEncryption, map[string]interface{}{
-			"msg": "src and dst must be pointers",
-		})
-	}
+	return decryptFields(dst, src, keyManager, true)
+}
 
-	vSrc = vSrc.Elem()
-	vDst = vDst.Elem()
-	if vSrc.Kind() != reflect.Struct || vDst.Kind() != reflect.Struct {
-		return kmsErrors.WrapError(kmsErrors.ErrRepoEncryption, map[string]interface{}{
-			"msg": "src and dst must point to structs",
-		})
-	}
+// Decrypts fields that were encrypted before ciphertexts were bound to their row.
+// Only meant for migrating those rows, see postgres.MigrateEncryptionContext
+func DecryptFieldsLegacy(dst, src any, keyManager c.KeyManager) error {
+	return decryptFields(dst, src, keyManager, false)
+}
 
-	if vSrc.Type() != vDst.Type() {
-		return kmsErrors.WrapError(kmsErrors.ErrRepoEncryption, map[string]interface{}{
-			"msg": "src and dst must be the same struct type",
-		})
+func decryptFields(dst, src any, keyManager c.KeyManager, bindContext bool) error {
+	vDst, vSrc, err := structPair(dst, src)
+	if err != nil {
+		return err
 	}
 
 	tSrc := vSrc.Type()
+	var rowCtx encryption.EncryptionContext
+	if bindContext {
+		if rowCtx, err = rowContext(vSrc); err != nil {
+			return err
+		}
+	}
 
 	for i := 0; i < tSrc.NumField(); i++ {
 		vSrcField := vSrc.Field(i)
@@ -136,8 +101,6 @@ func DecryptFields(dst, src any, keyManager c.KeyManager) error {
 		}
 
 		if tSrcField.Tag.Get("encrypt") == "true" {
-			isEncoded := tSrcField.Tag.Get("encoded") == "true"
-
 			toDecrypt, ok := vSrcField.Interface().(string)
 			if !ok {
 				return kmsErrors.WrapError(kmsErrors.ErrRepoEncryption, map[string]interface{}{
@@ -145,39 +108,17 @@ func DecryptFields(dst, src any, keyManager c.KeyManager) error {
 					"field": tSrcField.Name,
 				})
 			}
-			// Always decode encrypted values
-			decoded, err := b64.RawURLEncoding.DecodeString(toDecrypt)
-			if err != nil {
-				return kmsErrors.WrapError(kmsErrors.ErrRepoEncryption, map[string]interface{}{
-					"msg":   "Failed to decode field",
-					"field": tSrcField.Name,
-					"err":   err,
-				})
-			}
 
-			var decrypted []byte
-			if tSrcField.Tag.Get("key") == "kek" {
-				decrypted, err = encryption.Decrypt(decoded, keyManager.KEK())
-			} else {
-				decrypted, err = encryption.Decrypt(decoded, keyManager.DBKey())
+			var ctx encryption.EncryptionContext
+			if bindContext {
+				ctx = fieldContext(rowCtx, tSrcField)
 			}
+			decrypted, err := decryptValue(tSrcField, toDecrypt, ctx, keyManager)
 			if err != nil {
-				return kmsErrors.WrapError(kmsErrors.ErrRepoEncryption, map[string]interface{}{
-					"msg":   "Failed to decrypt field",
-					"field": tSrcField.Name,
-					"err":   err,
-				})
+				return err
 			}
 
-			// Only encode to base64 if decrypted is base64
-			var encoded string
-			if isEncoded {
-				encoded = b64.RawURLEncoding.EncodeToString(decrypted)
-			} else {
-				encoded = string(decrypted)
-			}
-
-			vDstField.SetString(encoded)
+			vDstField.SetString(decrypted)
 		} else {
 			vDstField.Set(vSrcField)
 		}
@@ -186,6 +127,165 @@ func DecryptFields(dst, src any, keyManager c.KeyManager) error {
 	return nil
 }
 
+// Encrypt a single field of src, for updates that don't write the whole row.
+// src must have the context fields of the row set.
+func EncryptField(src any, field string, value string, keyManager c.KeyManager) (string, error) {
+	vSrc, tField, err := structField(src, field)
+	if err != nil {
+		return "", err
+	}
+	rowCtx, err := rowContext(vSrc)
+	if err != nil {
+		return "", err
+	}
+	return encryptValue(tField, value, fieldContext(rowCtx, tField), keyManager)
+}
+
+// Decrypt a single (encrypted) field of src, src must have the context fields of the row set.
+func DecryptField(src any, field string, value string, keyManager c.KeyManager) (string, error) {
+	vSrc, tField, err := structField(src, field)
+	if err != nil {
+		return "", err
+	}
+	rowCtx, err := rowContext(vSrc)
+	if err != nil {
+		return "", err
+	}
+	return decryptValue(tField, value, fieldContext(rowCtx, tField), keyManager)
+}
+
+func structPair(dst, src any) (reflect.Value, reflect.Value, error) {
+	vSrc := reflect.ValueOf(src)
+	vDst := reflect.ValueOf(dst)
+	if vSrc.Kind() != reflect.Ptr || vDst.Kind() != reflect.Ptr {
+		return vDst, vSrc, kmsErrors.WrapError(kmsErrors.ErrRepoEncryption, map[string]interface{}{
+			"msg": "src and dst must be pointers",
+		})
+	}
+
+	vSrc = vSrc.Elem()
+	vDst = vDst.Elem()
+	if vSrc.Kind() != reflect.Struct || vDst.Kind() != reflect.Struct {
+		return vDst, vSrc, kmsErrors.WrapError(kmsErrors.ErrRepoEncryption, map[string]interface{}{
+			"msg": "src and dst must point to structs",
+		})
+	}
+
+	if vSrc.Type() != vDst.Type() {
+		return vDst, vSrc, kmsErrors.WrapError(kmsErrors.ErrRepoEncryption, map[string]interface{}{
+			"msg": "src and dst must be the same struct type",
+		})
+	}
+
+	return vDst, vSrc, nil
+}
+
+func structField(src any, field string) (reflect.Value, reflect.StructField, error) {
+	vSrc := reflect.ValueOf(src)
+	if vSrc.Kind() != reflect.Ptr || vSrc.Elem().Kind() != reflect.Struct {
+		return vSrc, reflect.StructField{}, kmsErrors.WrapError(kmsErrors.ErrRepoEncryption, map[string]interface{}{
+			"msg": "src must point to a struct",
+		})
+	}
+	vSrc = vSrc.Elem()
+
+	tField, ok := vSrc.Type().FieldByName(field)
+	if !ok || tField.Tag.Get("encrypt") != "true" {
+		return vSrc, tField, kmsErrors.WrapError(kmsErrors.ErrRepoEncryption, map[string]interface{}{
+			"msg":   "Field is not marked for encryption",
+			"field": field,
+		})
+	}
+	return vSrc, tField, nil
+}
+
+// Identifies the row: its ContextTyper type and the values of all fields tagged with context:"true"
+func rowContext(v reflect.Value) (encryption.EncryptionContext, error) {
+	t := v.Type()
+	typer, ok := v.Addr().Interface().(ContextTyper)
+	if !ok {
+		return nil, kmsErrors.WrapError(kmsErrors.ErrRepoEncryption, map[string]interface{}{
+			"msg":    "Struct doesn't implement ContextTyper",
+			"struct": t.String(),
+		})
+	}
+	ctx := encryption.EncryptionContext{"type": typer.EncryptionContextType()}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("context") == "true" {
+			ctx[t.Field(i).Name] = fmt.Sprint(v.Field(i))
+		}
+	}
+	return ctx, nil
+}
+
+func fieldContext(rowCtx encryption.EncryptionContext, tField reflect.StructField) encryption.EncryptionContext {
+	return rowCtx.With("field", tField.Name)
+}
+
+func fieldKey(tField reflect.StructField, keyManager c.KeyManager) []byte {
+	if tField.Tag.Get("key") == "kek" {
+		return keyManager.KEK()
+	}
+	return keyManager.DBKey()
+}
+
+func encryptValue(tField reflect.StructField, toEncrypt string, ctx encryption.EncryptionContext, keyManager c.KeyManager) (string, error) {
+	// Only decode to base64 if decrypted value is encoded in base64
+	var decoded []byte
+	var err error
+	if tField.Tag.Get("encoded") == "true" {
+		decoded, err = b64.RawURLEncoding.DecodeString(toEncrypt)
+		if err != nil {
+			return "", kmsErrors.WrapError(kmsErrors.ErrRepoEncryption, map[string]interface{}{
+				"msg":   "Failed to decode field",
+				"field": tField.Name,
+				"err":   err,
+			})
+		}
+	} else {
+		decoded = []byte(toEncrypt)
+	}
+
+	encrypted, err := encryption.EncryptWithContext(decoded, fieldKey(tField, keyManager), ctx)
+	if err != nil {
+		return "", kmsErrors.WrapError(kmsErrors.ErrRepoEncryption, map[string]interface{}{
+			"msg":   "Failed to encrypt field",
+			"field": tField.Name,
+			"err":   err,
+		})
+	}
+
+	// Always encode encrypted values
+	return b64.RawURLEncoding.EncodeToString(encrypted), nil
+}
+
+func decryptValue(tField reflect.StructField, toDecrypt string, ctx encryption.EncryptionContext, keyManager c.KeyManager) (string, error) {
+	// Always decode encrypted values
+	decoded, err := b64.RawURLEncoding.DecodeString(toDecrypt)
+	if err != nil {
+		return "", kmsErrors.WrapError(kmsErrors.ErrRepoEncryption, map[string]interface{}{
+			"msg":   "Failed to decode field",
+			"field": tField.Name,
+			"err":   err,
+		})
+	}
+
+	decrypted, err := encryption.DecryptWithContext(decoded, fieldKey(tField, keyManager), ctx)
+	if err != nil {
+		return "", kmsErrors.WrapError(kmsErrors.ErrRepoEncryption, map[string]interface{}{
+			"msg":   "Failed to decrypt field",
+			"field": tField.Name,
+			"err":   err,
+		})
+	}
+
+	// Only encode to base64 if decrypted is base64
+	if tField.Tag.Get("encoded") == "true" {
+		return b64.RawURLEncoding.EncodeToString(decrypted), nil
+	}
+	return string(decrypted), nil
+}
+
 func EncryptString(str string, key []byte) (string, error) {
 	encrypted, err := encryption.Encrypt([]byte(str), key)
 	if err != nil {
@@ -56,6 +56,10 @@ type Foo struct {
 	Ref    string `encoded:"true" encrypt:"true"`
 }
 
+func (f *Foo) EncryptionContextType() string {
+	return "Foo"
+}
+
 func (f *Foo) Equals(fc *Foo) bool {
 	return f.Id == fc.Id && f.Client == fc.Client && f.Ref == fc.Ref
 }
@@ -111,6 +115,30 @@ func TestFields_Roundtrip_Success(t *testing.T) {
 	}
 }
 
+type unexported struct {
+	client string
+}
+
+func (u *unexported) EncryptionContextType() string {
+	return "unexported"
+}
+
+type nonString struct {
+	ID int `encrypt:"true"`
+}
+
+func (n *nonString) EncryptionContextType() string {
+	return "nonString"
+}
+
+type encoded struct {
+	Client string `encrypt:"true" encoded:"true"`
+}
+
+func (e *encoded) EncryptionContextType() string {
+	return "encoded"
+}
+
 func TestEncryptFields_InvalidInput(t *testing.T) {
 	dbKey, err := encryption.GenerateKey(32)
 	if err != nil {
@@ -143,17 +171,10 @@ func TestEncryptFields_InvalidInput(t *testing.T) {
 		{"*interface{}", new(interface{}), new(interface{}), "src and dst must point to structs"},
 		{"**Foo", new(*Foo), new(*Foo), "src and dst must point to structs"},
 		{"different structs", &Foo{}, &struct{}{}, "src and dst must be the same struct type"},
-		{"unable to set field", &struct{ client string }{}, &struct{ client string }{client: "client"}, "Unable to set field"},
-		{"non-string", &struct {
-			ID int `encrypt:"true"`
-		}{}, &struct {
-			ID int `encrypt:"true"`
-		}{ID: 1}, "Field marked for encryption but is not a string"},
-		{"invalid base64", &struct {
-			Client string `encrypt:"true" encoded:"true"`
-		}{}, &struct {
-			Client string `encrypt:"true" encoded:"true"`
-		}{Client: "not+base64"}, "Failed to decode field"},
+		{"no context type", &struct{ Client string }{}, &struct{ Client string }{Client: "client"}, "Struct doesn't implement ContextTyper"},
+		{"unable to set field", &unexported{}, &unexported{client: "client"}, "Unable to set field"},
+		{"non-string", &nonString{}, &nonString{ID: 1}, "Field marked for encryption but is not a string"},
+		{"invalid base64", &encoded{}, &encoded{Client: "not+base64"}, "Failed to decode field"},
 	}
 
 	for _, tt := range tests {
@@ -204,17 +225,10 @@ func TestDecryptFields_InvalidInput(t *testing.T) {
 		{"*interface{}", new(interface{}), new(interface{}), "src and dst must point to structs"},
 		{"**Foo", new(*Foo), new(*Foo), "src and dst must point to structs"},
 		{"different structs", &Foo{}, &struct{}{}, "src and dst must be the same struct type"},
-		{"unable to set field", &struct{ client string }{}, &struct{ client string }{client: "client"}, "Unable to set field"},
-		{"non-string", &struct {
-			ID int `encrypt:"true"`
-		}{}, &struct {
-			ID int `encrypt:"true"`
-		}{ID: 1}, "Field marked for decryption but is not a string"},
-		{"invalid base64", &struct {
-			Client string `encrypt:"true" encoded:"true"`
-		}{}, &struct {
-			Client string `encrypt:"true" encoded:"true"`
-		}{Client: "not+base64"}, "Failed to decode field"},
+		{"no context type", &struct{ Client string }{}, &struct{ Client string }{Client: "client"}, "Struct doesn't implement ContextTyper"},
+		{"unable to set field", &unexported{}, &unexported{client: "client"}, "Unable to set field"},
+		{"non-string", &nonString{}, &nonString{ID: 1}, "Field marked for decryption but is not a string"},
+		{"invalid base64", &encoded{}, &encoded{Client: "not+base64"}, "Failed to decode field"},
 	}
 
 	for _, tt := range tests {
@@ -232,3 +246,79 @@ func TestDecryptFields_InvalidInput(t *testing.T) {
 		})
 	}
 }
+
+type Row struct {
+	Id     int    `context:"true"`
+	Secret string `encrypt:"true"`
+	Other  string `encrypt:"true"`
+}
+
+func (r *Row) EncryptionContextType() string {
+	return "Row"
+}
+
+func TestFields_BoundToRowAndField(t *testing.T) {
+	dbKey, err := encryption.GenerateKey(32)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keyManager := mocks.NewKeyManagerMock()
+	keyManager.DBKeyFunc = func() []byte {
+		return dbKey
+	}
+
+	enc := &Row{}
+	if err := EncryptFields(enc, &Row{Id: 1, Secret: "secret", Other: "other"}, keyManager); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// other row
+	moved := *enc
+	moved.Id = 2
+	if err := DecryptFields(&Row{}, &moved, keyManager); err == nil {
+		t.Errorf("expected ciphertext in other row to fail")
+	}
+
+	// other field
+	swapped := *enc
+	swapped.Secret, swapped.Other = enc.Other, enc.Secret
+	if err := DecryptFields(&Row{}, &swapped, keyManager); err == nil {
+		t.Errorf("expected ciphertext in other field to fail")
+	}
+
+	// single field
+	secret, err := DecryptField(enc, "Secret", enc.Secret, keyManager)
+	if err != nil || secret != "secret" {
+		t.Errorf("expected 'secret', got %s (%v)", secret, err)
+	}
+}
+
+func TestDecryptFieldsLegacy(t *testing.T) {
+	dbKey, err := encryption.GenerateKey(32)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keyManager := mocks.NewKeyManagerMock()
+	keyManager.DBKeyFunc = func() []byte {
+		return dbKey
+	}
+
+	// written before ciphertexts were bound to their row
+	legacy, err := EncryptString("secret", dbKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	row := &Row{Id: 1, Secret: legacy, Other: legacy}
+
+	if err := DecryptFields(&Row{}, row, keyManager); err == nil {
+		t.Errorf("expected legacy ciphertext to fail with row context")
+	}
+
+	dec := &Row{}
+	if err := DecryptFieldsLegacy(dec, row, keyManager); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if dec.Secret != "secret" {
+		t.Errorf("expected 'secret', got %s", dec.Secret)
+	}
+}
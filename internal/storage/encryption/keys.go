@@ -1,10 +1,14 @@
 package encryption
 
 import (
+	"context"
 	c "kms/internal/bootstrap/context"
 	"kms/internal/keys"
+	"kms/pkg/tracing"
+	"time"
 )
 
+// Methods that encrypt or decrypt fields get their own span, the others only pass ctx on
 type EncryptedKeyRepo struct {
 	KeyRepo    keys.KeyRepository
 	KeyManager c.KeyManager
@@ -17,13 +21,12 @@ func NewEncryptedKeyRepo(keyRepo keys.KeyRepository, keyManager c.KeyManager) *E
 	}
 }
 
-func (r *EncryptedKeyRepo) BeginTransaction() (keys.KeyRepository, error) {
-	newRepo, err := r.KeyRepo.BeginTransaction()
+func (r *EncryptedKeyRepo) BeginTransaction(ctx context.Context) (keys.KeyRepository, error) {
+	txRepo, err := r.KeyRepo.BeginTransaction(ctx)
 	if err != nil {
 		return nil, err
 	}
-	r.KeyRepo = newRepo
-	return r, nil
+	return &EncryptedKeyRepo{KeyRepo: txRepo, KeyManager: r.KeyManager}, nil
 }
 
 func (r *EncryptedKeyRepo) CommitTransaction() error {
@@ -34,12 +37,15 @@ func (r *EncryptedKeyRepo) RollbackTransaction() error {
 	return r.KeyRepo.RollbackTransaction()
 }
 
-func (r *EncryptedKeyRepo) CreateKey(key *keys.Key) (*keys.Key, error) {
+func (r *EncryptedKeyRepo) CreateKey(ctx context.Context, key *keys.Key) (*keys.Key, error) {
+	ctx, span := tracing.Start(ctx, "EncryptedKeyRepo.CreateKey")
+	defer span.End()
+
 	encKey := &keys.Key{}
 	if err := EncryptFields(encKey, key, r.KeyManager); err != nil {
 		return nil, err
 	}
-	stored, err := r.KeyRepo.CreateKey(encKey)
+	stored, err := r.KeyRepo.CreateKey(ctx, encKey)
 	if err != nil {
 		return nil, err
 	}
@@ -50,8 +56,11 @@ func (r *EncryptedKeyRepo) CreateKey(key *keys.Key) (*keys.Key, error) {
 	return retKey, nil
 }
 
-func (r *EncryptedKeyRepo) GetKey(id int, keyReference string, version int) (*keys.Key, error) {
-	key, err := r.KeyRepo.GetKey(id, keyReference, version)
+func (r *EncryptedKeyRepo) GetKey(ctx context.Context, id int, keyReference string, version int) (*keys.Key, error) {
+	ctx, span := tracing.Start(ctx, "EncryptedKeyRepo.GetKey")
+	defer span.End()
+
+	key, err := r.KeyRepo.GetKey(ctx, id, keyReference, version)
 	if err != nil {
 		return nil, err
 	}
@@ -64,8 +73,11 @@ func (r *EncryptedKeyRepo) GetKey(id int, keyReference string, version int) (*ke
 	return retKey, nil
 }
 
-func (r *EncryptedKeyRepo) GetLatestKey(id int, keyReference string) (*keys.Key, error) {
-	key, err := r.KeyRepo.GetLatestKey(id, keyReference)
+func (r *EncryptedKeyRepo) GetLatestKey(ctx context.Context, id int, keyReference string) (*keys.Key, error) {
+	ctx, span := tracing.Start(ctx, "EncryptedKeyRepo.GetLatestKey")
+	defer span.End()
+
+	key, err := r.KeyRepo.GetLatestKey(ctx, id, keyReference)
 	if err != nil {
 		return nil, err
 	}
@@ -78,20 +90,74 @@ func (r *EncryptedKeyRepo) GetLatestKey(id int, keyReference string) (*keys.Key,
 	return retKey, nil
 }
 
-func (r *EncryptedKeyRepo) UpdateKey(clientId int, keyReference string, version int, state string) error {
-	encState, err := EncryptString(state, r.KeyManager.DBKey())
+func (r *EncryptedKeyRepo) UpdateKey(ctx context.Context, clientId int, keyReference string, version int, state string) error {
+	ctx, span := tracing.Start(ctx, "EncryptedKeyRepo.UpdateKey", "kms.client_id", clientId)
+	defer span.End()
+
+	row := &keys.Key{ClientId: clientId, KeyReference: keyReference, Version: version}
+	encState, err := EncryptField(row, "State", state, r.KeyManager)
 	if err != nil {
 		return err
 	}
 
-	return r.KeyRepo.UpdateKey(clientId, keyReference, version, encState)
+	return r.KeyRepo.UpdateKey(ctx, clientId, keyReference, version, encState)
+}
+
+func (r *EncryptedKeyRepo) ScheduleDeletion(ctx context.Context, clientId int, keyReference string, deleteAfter time.Time) error {
+	return r.KeyRepo.ScheduleDeletion(ctx, clientId, keyReference, deleteAfter)
+}
+
+func (r *EncryptedKeyRepo) CancelDeletion(ctx context.Context, clientId int, keyReference string) error {
+	return r.KeyRepo.CancelDeletion(ctx, clientId, keyReference)
+}
+
+func (r *EncryptedKeyRepo) DestroyScheduled(ctx context.Context, before time.Time) (int, error) {
+	return r.KeyRepo.DestroyScheduled(ctx, before)
+}
+
+func (r *EncryptedKeyRepo) GetVersions(ctx context.Context, clientId int, keyReference string) ([]keys.Key, error) {
+	ctx, span := tracing.Start(ctx, "EncryptedKeyRepo.GetVersions", "kms.client_id", clientId)
+	defer span.End()
+
+	versions, err := r.KeyRepo.GetVersions(ctx, clientId, keyReference)
+	if err != nil {
+		return nil, err
+	}
+
+	retVersions := make([]keys.Key, len(versions))
+	for i := range versions {
+		if err := DecryptFields(&retVersions[i], &versions[i], r.KeyManager); err != nil {
+			return nil, err
+		}
+	}
+
+	return retVersions, nil
+}
+
+func (r *EncryptedKeyRepo) GetClientKeys(ctx context.Context, clientId int) ([]keys.Key, error) {
+	ctx, span := tracing.Start(ctx, "EncryptedKeyRepo.GetClientKeys", "kms.client_id", clientId)
+	defer span.End()
+
+	clientKeys, err := r.KeyRepo.GetClientKeys(ctx, clientId)
+	if err != nil {
+		return nil, err
+	}
+
+	retKeys := make([]keys.Key, len(clientKeys))
+	for i := range clientKeys {
+		if err := DecryptFields(&retKeys[i], &clientKeys[i], r.KeyManager); err != nil {
+			return nil, err
+		}
+	}
+
+	return retKeys, nil
 }
 
-func (r *EncryptedKeyRepo) Delete(clientId int, keyReference string) (int, error) {
-	return r.KeyRepo.Delete(clientId, keyReference)
+func (r *EncryptedKeyRepo) DestroyVersion(ctx context.Context, clientId int, keyReference string, version int) error {
+	return r.KeyRepo.DestroyVersion(ctx, clientId, keyReference, version)
 }
 
 // Dev
-func (r *EncryptedKeyRepo) GetAll() ([]keys.Key, error) {
-	return r.KeyRepo.GetAll()
+func (r *EncryptedKeyRepo) GetAll(ctx context.Context) ([]keys.Key, error) {
+	return r.KeyRepo.GetAll(ctx)
 }
@@ -1,12 +1,14 @@
 package encryption
 
 import (
+	"context"
 	"errors"
 	"kms/internal/keys"
 	"kms/internal/test"
 	"kms/internal/test/mocks"
 	"kms/pkg/encryption"
 	"testing"
+	"time"
 )
 
 func TestCreateKey_Success(t *testing.T) {
@@ -20,7 +22,7 @@ func TestCreateKey_Success(t *testing.T) {
 		Encoding:     "encoding",
 	}
 	mockRepo := keys.NewKeyRepositoryMock()
-	mockRepo.CreateKeyFunc = func(k *keys.Key) (*keys.Key, error) {
+	mockRepo.CreateKeyFunc = func(ctx context.Context, k *keys.Key) (*keys.Key, error) {
 		return k, nil
 	}
 	keyManager := mocks.NewKeyManagerMock()
@@ -41,7 +43,7 @@ func TestCreateKey_Success(t *testing.T) {
 
 	repo := NewEncryptedKeyRepo(mockRepo, keyManager)
 
-	created, err := repo.CreateKey(key)
+	created, err := repo.CreateKey(context.Background(), key)
 
 	test.RequireErrNil(t, err)
 
@@ -50,6 +52,42 @@ func TestCreateKey_Success(t *testing.T) {
 	}
 }
 
+func TestGetKey_CopiedCiphertext(t *testing.T) {
+	keyManager := mocks.NewKeyManagerMock()
+	dbKey, err := encryption.GenerateKey(32)
+	test.RequireErrNil(t, err)
+	kek, err := encryption.GenerateKey(32)
+	test.RequireErrNil(t, err)
+	keyManager.DBKeyFunc = func() []byte {
+		return dbKey
+	}
+	keyManager.KEKFunc = func() []byte {
+		return kek
+	}
+
+	var stored *keys.Key
+	mockRepo := keys.NewKeyRepositoryMock()
+	mockRepo.CreateKeyFunc = func(ctx context.Context, k *keys.Key) (*keys.Key, error) {
+		stored = k
+		return k, nil
+	}
+	repo := NewEncryptedKeyRepo(mockRepo, keyManager)
+
+	_, err = repo.CreateKey(context.Background(), &keys.Key{ClientId: 1, KeyReference: "ref", Version: 1, DEK: "validB64", State: "state", Encoding: "encoding"})
+	test.RequireErrNil(t, err)
+
+	// same ciphertexts in another client's row
+	copied := *stored
+	copied.ClientId = 2
+	mockRepo.GetKeyFunc = func(ctx context.Context, id int, keyReference string, version int) (*keys.Key, error) {
+		return &copied, nil
+	}
+
+	_, err = repo.GetKey(context.Background(), 2, "ref", 1)
+	test.RequireErrNotNil(t, err)
+	test.RequireErrContains(t, err, "Failed to decrypt field")
+}
+
 func TestCreateKey_RepoError(t *testing.T) {
 	key := &keys.Key{
 		ID:           1,
@@ -61,7 +99,7 @@ func TestCreateKey_RepoError(t *testing.T) {
 		Encoding:     "encoding",
 	}
 	mockRepo := keys.NewKeyRepositoryMock()
-	mockRepo.CreateKeyFunc = func(k *keys.Key) (*keys.Key, error) {
+	mockRepo.CreateKeyFunc = func(ctx context.Context, k *keys.Key) (*keys.Key, error) {
 		return nil, errors.New("repo error")
 	}
 	keyManager := mocks.NewKeyManagerMock()
@@ -82,7 +120,7 @@ func TestCreateKey_RepoError(t *testing.T) {
 
 	repo := NewEncryptedKeyRepo(mockRepo, keyManager)
 
-	_, err = repo.CreateKey(key)
+	_, err = repo.CreateKey(context.Background(), key)
 
 	test.RequireErrNotNil(t, err)
 	test.RequireErrContains(t, err, "repo error")
@@ -119,12 +157,12 @@ func TestGetKey_Success(t *testing.T) {
 	test.RequireErrNil(t, err)
 
 	mockRepo := keys.NewKeyRepositoryMock()
-	mockRepo.GetKeyFunc = func(id int, keyReference string, v int) (*keys.Key, error) {
+	mockRepo.GetKeyFunc = func(ctx context.Context, id int, keyReference string, v int) (*keys.Key, error) {
 		return &enc, nil
 	}
 
 	repo := NewEncryptedKeyRepo(mockRepo, keyManager)
-	retrieved, err := repo.GetKey(1, "ref", 1)
+	retrieved, err := repo.GetKey(context.Background(), 1, "ref", 1)
 
 	test.RequireErrNil(t, err)
 
@@ -135,13 +173,13 @@ func TestGetKey_Success(t *testing.T) {
 
 func TestGetKey_RepoError(t *testing.T) {
 	mockRepo := keys.NewKeyRepositoryMock()
-	mockRepo.GetKeyFunc = func(id int, ref string, v int) (*keys.Key, error) {
+	mockRepo.GetKeyFunc = func(ctx context.Context, id int, ref string, v int) (*keys.Key, error) {
 		return nil, errors.New("repo error")
 	}
 	keyManager := mocks.NewKeyManagerMock()
 	repo := NewEncryptedKeyRepo(mockRepo, keyManager)
 
-	_, err := repo.GetKey(1, "ref", 1)
+	_, err := repo.GetKey(context.Background(), 1, "ref", 1)
 
 	test.RequireErrNotNil(t, err)
 	test.RequireErrContains(t, err, "repo error")
@@ -178,12 +216,12 @@ func TestGetLatestKey_Success(t *testing.T) {
 	test.RequireErrNil(t, err)
 
 	mockRepo := keys.NewKeyRepositoryMock()
-	mockRepo.GetLatestKeyFunc = func(id int, keyReference string) (*keys.Key, error) {
+	mockRepo.GetLatestKeyFunc = func(ctx context.Context, id int, keyReference string) (*keys.Key, error) {
 		return &enc, nil
 	}
 
 	repo := NewEncryptedKeyRepo(mockRepo, keyManager)
-	retrieved, err := repo.GetLatestKey(1, "ref")
+	retrieved, err := repo.GetLatestKey(context.Background(), 1, "ref")
 
 	test.RequireErrNil(t, err)
 
@@ -194,13 +232,13 @@ func TestGetLatestKey_Success(t *testing.T) {
 
 func TestGetLatestKey_RepoError(t *testing.T) {
 	mockRepo := keys.NewKeyRepositoryMock()
-	mockRepo.GetKeyFunc = func(id int, ref string, v int) (*keys.Key, error) {
+	mockRepo.GetKeyFunc = func(ctx context.Context, id int, ref string, v int) (*keys.Key, error) {
 		return nil, errors.New("repo error")
 	}
 	keyManager := mocks.NewKeyManagerMock()
 	repo := NewEncryptedKeyRepo(mockRepo, keyManager)
 
-	_, err := repo.GetKey(1, "ref", 1)
+	_, err := repo.GetKey(context.Background(), 1, "ref", 1)
 
 	test.RequireErrNotNil(t, err)
 	test.RequireErrContains(t, err, "repo error")
@@ -237,12 +275,12 @@ func TestUpdateKey_Success(t *testing.T) {
 	test.RequireErrNil(t, err)
 
 	mockRepo := keys.NewKeyRepositoryMock()
-	mockRepo.UpdateKeyFunc = func(id int, keyReference string, v int, s string) error {
+	mockRepo.UpdateKeyFunc = func(ctx context.Context, id int, keyReference string, v int, s string) error {
 		return nil
 	}
 
 	repo := NewEncryptedKeyRepo(mockRepo, keyManager)
-	err = repo.UpdateKey(1, "ref", 1, "state")
+	err = repo.UpdateKey(context.Background(), 1, "ref", 1, "state")
 
 	test.RequireErrNil(t, err)
 }
@@ -260,7 +298,7 @@ func TestUpdateKey_InvalidKey(t *testing.T) {
 	mockRepo := keys.NewKeyRepositoryMock()
 
 	repo := NewEncryptedKeyRepo(mockRepo, keyManager)
-	err = repo.UpdateKey(1, "ref", 1, "state")
+	err = repo.UpdateKey(context.Background(), 1, "ref", 1, "state")
 
 	test.RequireErrNotNil(t, err)
 }
@@ -276,58 +314,75 @@ func TestUpdateKey_RepoError(t *testing.T) {
 	}
 
 	mockRepo := keys.NewKeyRepositoryMock()
-	mockRepo.UpdateKeyFunc = func(x int, y string, v int, z string) error {
+	mockRepo.UpdateKeyFunc = func(ctx context.Context, x int, y string, v int, z string) error {
 		return errors.New("repo error")
 	}
 
 	repo := NewEncryptedKeyRepo(mockRepo, keyManager)
-	err = repo.UpdateKey(1, "ref", 1, "state")
+	err = repo.UpdateKey(context.Background(), 1, "ref", 1, "state")
 
 	test.RequireErrNotNil(t, err)
 	test.RequireContains(t, err.Error(), "repo error")
 }
 
-func TestDeleteKey_Success(t *testing.T) {
+func TestScheduleDeletion_Success(t *testing.T) {
+	deleteAfter := time.Now().Add(time.Hour)
 	mockRepo := keys.NewKeyRepositoryMock()
-	mockRepo.DeleteFunc = func(x int, y string) (int, error) {
-		return 1, nil
+	mockRepo.ScheduleDeletionFunc = func(ctx context.Context, x int, y string, z time.Time) error {
+		if !z.Equal(deleteAfter) {
+			t.Errorf("expected deleteAfter=%v, got %v", deleteAfter, z)
+		}
+		return nil
 	}
 	mockKeyManager := mocks.NewKeyManagerMock()
 
 	repo := NewEncryptedKeyRepo(mockRepo, mockKeyManager)
-	keyId, err := repo.Delete(12, "keyRef")
+	err := repo.ScheduleDeletion(context.Background(), 12, "keyRef", deleteAfter)
 
 	test.RequireErrNil(t, err)
-	if keyId != 1 {
-		t.Errorf("expected keyId=1, got %d", keyId)
-	}
 }
 
-func TestDeleteKey_RepoError(t *testing.T) {
+func TestScheduleDeletion_RepoError(t *testing.T) {
 	mockRepo := keys.NewKeyRepositoryMock()
-	mockRepo.DeleteFunc = func(x int, y string) (int, error) {
-		return 1, errors.New("repo error")
+	mockRepo.ScheduleDeletionFunc = func(ctx context.Context, x int, y string, z time.Time) error {
+		return errors.New("repo error")
 	}
 	mockKeyManager := mocks.NewKeyManagerMock()
 
 	repo := NewEncryptedKeyRepo(mockRepo, mockKeyManager)
-	_, err := repo.Delete(12, "keyRef")
+	err := repo.ScheduleDeletion(context.Background(), 12, "keyRef", time.Now())
 
 	test.RequireErrNotNil(t, err)
 }
 
+func TestDestroyScheduled_Success(t *testing.T) {
+	mockRepo := keys.NewKeyRepositoryMock()
+	mockRepo.DestroyScheduledFunc = func(ctx context.Context, before time.Time) (int, error) {
+		return 3, nil
+	}
+	mockKeyManager := mocks.NewKeyManagerMock()
+
+	repo := NewEncryptedKeyRepo(mockRepo, mockKeyManager)
+	n, err := repo.DestroyScheduled(context.Background(), time.Now())
+
+	test.RequireErrNil(t, err)
+	if n != 3 {
+		t.Errorf("expected n=3, got %d", n)
+	}
+}
+
 func TestGetAllKeys(t *testing.T) {
 	keyManager := mocks.NewKeyManagerMock()
 	stored := []keys.Key{
 		{ID: 1, KeyReference: "reference"},
 	}
 	mockRepo := keys.NewKeyRepositoryMock()
-	mockRepo.GetAllFunc = func() ([]keys.Key, error) {
+	mockRepo.GetAllFunc = func(ctx context.Context) ([]keys.Key, error) {
 		return stored, nil
 	}
 	repo := NewEncryptedKeyRepo(mockRepo, keyManager)
 
-	retrieved, err := repo.GetAll()
+	retrieved, err := repo.GetAll(context.Background())
 	test.RequireErrNil(t, err)
 
 	if len(retrieved) != 1 {
@@ -338,3 +393,67 @@ func TestGetAllKeys(t *testing.T) {
 		t.Errorf("expected ID=1 and reference='reference', got %v", retrieved[0])
 	}
 }
+
+func TestGetClientKeys_Decrypts(t *testing.T) {
+	keyManager := mocks.NewKeyManagerMock()
+	dbKey, err := encryption.GenerateKey(32)
+	test.RequireErrNil(t, err)
+	kek, err := encryption.GenerateKey(32)
+	test.RequireErrNil(t, err)
+	keyManager.DBKeyFunc = func() []byte {
+		return dbKey
+	}
+	keyManager.KEKFunc = func() []byte {
+		return kek
+	}
+
+	var stored []keys.Key
+	mockRepo := keys.NewKeyRepositoryMock()
+	mockRepo.CreateKeyFunc = func(ctx context.Context, k *keys.Key) (*keys.Key, error) {
+		stored = append(stored, *k)
+		return k, nil
+	}
+	mockRepo.GetClientKeysFunc = func(ctx context.Context, clientId int) ([]keys.Key, error) {
+		return stored, nil
+	}
+	repo := NewEncryptedKeyRepo(mockRepo, keyManager)
+
+	originals := []keys.Key{
+		{ClientId: 1, KeyReference: "a", Version: 1, DEK: "validB64", State: keys.StateDeprecated, Encoding: "encoding"},
+		{ClientId: 1, KeyReference: "a", Version: 2, DEK: "otherB64", State: keys.StateInUse, Encoding: "encoding"},
+	}
+	for i := range originals {
+		_, err := repo.CreateKey(context.Background(), &originals[i])
+		test.RequireErrNil(t, err)
+	}
+
+	retrieved, err := repo.GetClientKeys(context.Background(), 1)
+	test.RequireErrNil(t, err)
+
+	if len(retrieved) != len(originals) {
+		t.Fatalf("expected %d keys, got %d", len(originals), len(retrieved))
+	}
+	for i := range originals {
+		if retrieved[i] != originals[i] {
+			t.Errorf("expected %v, got %v", originals[i], retrieved[i])
+		}
+	}
+}
+
+func TestBeginTransaction_OwnRepo(t *testing.T) {
+	mockRepo := keys.NewKeyRepositoryMock()
+	txRepo := keys.NewKeyRepositoryMock()
+	mockRepo.BeginTransactionFunc = func(ctx context.Context) (keys.KeyRepository, error) { return txRepo, nil }
+	repo := NewEncryptedKeyRepo(mockRepo, mocks.NewKeyManagerMock())
+
+	tx, err := repo.BeginTransaction(context.Background())
+	test.RequireErrNil(t, err)
+
+	encTx, ok := tx.(*EncryptedKeyRepo)
+	if !ok || encTx == repo || encTx.KeyRepo != keys.KeyRepository(txRepo) {
+		t.Errorf("expected a new repo wrapping the transaction, got %+v", tx)
+	}
+	if repo.KeyRepo != keys.KeyRepository(mockRepo) {
+		t.Error("expected the shared repo to be left as is")
+	}
+}
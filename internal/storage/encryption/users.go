@@ -1,8 +1,10 @@
 package encryption
 
 import (
+	"context"
 	c "kms/internal/bootstrap/context"
 	"kms/internal/clients"
+	"kms/pkg/tracing"
 )
 
 type EncryptedClientRepo struct {
@@ -17,20 +19,26 @@ func NewEncryptedClientRepo(clientRepo clients.ClientRepository, keyManager c.Ke
 	}
 }
 
-func (r *EncryptedClientRepo) CreateClient(client *clients.Client) (int, error) {
+func (r *EncryptedClientRepo) CreateClient(ctx context.Context, client *clients.Client) (int, error) {
+	ctx, span := tracing.Start(ctx, "EncryptedClientRepo.CreateClient")
+	defer span.End()
+
 	encClient := &clients.Client{}
 	if err := EncryptFields(encClient, client, r.KeyManager); err != nil {
 		return 0, err
 	}
-	id, err := r.ClientRepo.CreateClient(encClient)
+	id, err := r.ClientRepo.CreateClient(ctx, encClient)
 	if err != nil {
 		return 0, err
 	}
 	return id, nil
 }
 
-func (r *EncryptedClientRepo) GetClient(id int) (*clients.Client, error) {
-	client, err := r.ClientRepo.GetClient(id)
+func (r *EncryptedClientRepo) GetClient(ctx context.Context, id int) (*clients.Client, error) {
+	ctx, span := tracing.Start(ctx, "EncryptedClientRepo.GetClient")
+	defer span.End()
+
+	client, err := r.ClientRepo.GetClient(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -41,8 +49,11 @@ func (r *EncryptedClientRepo) GetClient(id int) (*clients.Client, error) {
 	return decClient, nil
 }
 
-func (r *EncryptedClientRepo) GetAll() ([]clients.Client, error) {
-	stored, err := r.ClientRepo.GetAll()
+func (r *EncryptedClientRepo) GetAll(ctx context.Context) ([]clients.Client, error) {
+	ctx, span := tracing.Start(ctx, "EncryptedClientRepo.GetAll")
+	defer span.End()
+
+	stored, err := r.ClientRepo.GetAll(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -59,12 +70,15 @@ func (r *EncryptedClientRepo) GetAll() ([]clients.Client, error) {
 	return decClients, nil
 }
 
-func (r *EncryptedClientRepo) Delete(clientId int) error {
-	return r.ClientRepo.Delete(clientId)
+func (r *EncryptedClientRepo) Delete(ctx context.Context, clientId int) error {
+	return r.ClientRepo.Delete(ctx, clientId)
 }
 
-func (r *EncryptedClientRepo) FindByHashedClientname(email string) (*clients.Client, error) {
-	client, err := r.ClientRepo.FindByHashedClientname(email)
+func (r *EncryptedClientRepo) FindByHashedClientname(ctx context.Context, email string) (*clients.Client, error) {
+	ctx, span := tracing.Start(ctx, "EncryptedClientRepo.FindByHashedClientname")
+	defer span.End()
+
+	client, err := r.ClientRepo.FindByHashedClientname(ctx, email)
 	if err != nil {
 		return nil, err
 	}
@@ -75,22 +89,111 @@ func (r *EncryptedClientRepo) FindByHashedClientname(email string) (*clients.Cli
 	return decClient, nil
 }
 
-func (r *EncryptedClientRepo) UpdateRole(id int, role string) error {
-	encRole, err := EncryptString(role, r.KeyManager.DBKey())
+func (r *EncryptedClientRepo) UpdateRole(ctx context.Context, id int, role string) error {
+	ctx, span := tracing.Start(ctx, "EncryptedClientRepo.UpdateRole")
+	defer span.End()
+
+	// role is bound to the client's row, which is identified by its hashed clientname
+	client, err := r.ClientRepo.GetClient(ctx, id)
+	if err != nil {
+		return err
+	}
+	encRole, err := EncryptField(client, "Role", role, r.KeyManager)
 	if err != nil {
 		return err
 	}
-	return r.ClientRepo.UpdateRole(id, encRole)
+	return r.ClientRepo.UpdateRole(ctx, id, encRole)
+}
+
+// Passwords are bcrypt hashes
+func (r *EncryptedClientRepo) UpdatePassword(ctx context.Context, id int, hashedPassword string) error {
+	return r.ClientRepo.UpdatePassword(ctx, id, hashedPassword)
 }
 
-func (r *EncryptedClientRepo) GetRole(id int) (string, error) {
-	encRole, err := r.ClientRepo.GetRole(id)
+func (r *EncryptedClientRepo) GetRole(ctx context.Context, id int) (string, error) {
+	ctx, span := tracing.Start(ctx, "EncryptedClientRepo.GetRole")
+	defer span.End()
+
+	client, err := r.ClientRepo.GetClient(ctx, id)
 	if err != nil {
 		return "", err
 	}
-	role, err := DecryptString(encRole, r.KeyManager.DBKey())
+	role, err := DecryptField(client, "Role", client.Role, r.KeyManager)
 	if err != nil {
 		return "", err
 	}
 	return role, nil
 }
+
+// Identities are hashed by the caller and pins are public, so certificates are stored as they are
+func (r *EncryptedClientRepo) CreateCertificate(ctx context.Context, cert *clients.Certificate) (int, error) {
+	return r.ClientRepo.CreateCertificate(ctx, cert)
+}
+
+func (r *EncryptedClientRepo) FindCertificate(ctx context.Context, hashedIdentity string) (*clients.Certificate, error) {
+	return r.ClientRepo.FindCertificate(ctx, hashedIdentity)
+}
+
+func (r *EncryptedClientRepo) GetCertificates(ctx context.Context, clientId int) ([]clients.Certificate, error) {
+	return r.ClientRepo.GetCertificates(ctx, clientId)
+}
+
+func (r *EncryptedClientRepo) DeleteCertificate(ctx context.Context, clientId, id int) error {
+	return r.ClientRepo.DeleteCertificate(ctx, clientId, id)
+}
+
+// The secret part of API keys is hashed by the caller, the rest is not sensitive
+func (r *EncryptedClientRepo) CreateApiKey(ctx context.Context, apiKey *clients.ApiKey) (int, error) {
+	return r.ClientRepo.CreateApiKey(ctx, apiKey)
+}
+
+func (r *EncryptedClientRepo) FindApiKey(ctx context.Context, prefix string) (*clients.ApiKey, error) {
+	return r.ClientRepo.FindApiKey(ctx, prefix)
+}
+
+func (r *EncryptedClientRepo) GetApiKeys(ctx context.Context, clientId int) ([]clients.ApiKey, error) {
+	return r.ClientRepo.GetApiKeys(ctx, clientId)
+}
+
+func (r *EncryptedClientRepo) DeleteApiKey(ctx context.Context, clientId, id int) error {
+	return r.ClientRepo.DeleteApiKey(ctx, clientId, id)
+}
+
+func (r *EncryptedClientRepo) TouchApiKey(ctx context.Context, id int) error {
+	return r.ClientRepo.TouchApiKey(ctx, id)
+}
+
+func (r *EncryptedClientRepo) SaveMfa(ctx context.Context, mfa *clients.Mfa) error {
+	ctx, span := tracing.Start(ctx, "EncryptedClientRepo.SaveMfa")
+	defer span.End()
+
+	encMfa := &clients.Mfa{}
+	if err := EncryptFields(encMfa, mfa, r.KeyManager); err != nil {
+		return err
+	}
+	return r.ClientRepo.SaveMfa(ctx, encMfa)
+}
+
+func (r *EncryptedClientRepo) GetMfa(ctx context.Context, clientId int) (*clients.Mfa, error) {
+	ctx, span := tracing.Start(ctx, "EncryptedClientRepo.GetMfa", "kms.client_id", clientId)
+	defer span.End()
+
+	mfa, err := r.ClientRepo.GetMfa(ctx, clientId)
+	if err != nil {
+		return nil, err
+	}
+	decMfa := &clients.Mfa{}
+	if err := DecryptFields(decMfa, mfa, r.KeyManager); err != nil {
+		return nil, err
+	}
+	return decMfa, nil
+}
+
+// Only compares the step and recovery codes, the secret isn't touched
+func (r *EncryptedClientRepo) UseMfa(ctx context.Context, prev *clients.Mfa, lastStep int64, recoveryCodes []string) error {
+	return r.ClientRepo.UseMfa(ctx, prev, lastStep, recoveryCodes)
+}
+
+func (r *EncryptedClientRepo) DeleteMfa(ctx context.Context, clientId int) error {
+	return r.ClientRepo.DeleteMfa(ctx, clientId)
+}
@@ -1,6 +1,7 @@
 package encryption
 
 import (
+	"context"
 	"errors"
 	"kms/internal/clients"
 	"kms/internal/test"
@@ -11,7 +12,7 @@ import (
 
 func TestCreateClient_Success(t *testing.T) {
 	mockRepo := clients.NewClientRepositoryMock()
-	mockRepo.CreateClientFunc = func(client *clients.Client) (int, error) {
+	mockRepo.CreateClientFunc = func(ctx context.Context, client *clients.Client) (int, error) {
 		return 1, nil
 	}
 	keyManager := mocks.NewKeyManagerMock()
@@ -34,7 +35,7 @@ func TestCreateClient_Success(t *testing.T) {
 		ID: 1,
 	}
 
-	id, err := repo.CreateClient(original)
+	id, err := repo.CreateClient(context.Background(), original)
 	test.RequireErrNil(t, err)
 
 	if id != 1 {
@@ -44,7 +45,7 @@ func TestCreateClient_Success(t *testing.T) {
 
 func TestCreateClient_RepoError(t *testing.T) {
 	mockRepo := clients.NewClientRepositoryMock()
-	mockRepo.CreateClientFunc = func(client *clients.Client) (int, error) {
+	mockRepo.CreateClientFunc = func(ctx context.Context, client *clients.Client) (int, error) {
 		return 1, errors.New("repo error")
 	}
 	keyManager := mocks.NewKeyManagerMock()
@@ -67,7 +68,7 @@ func TestCreateClient_RepoError(t *testing.T) {
 		ID: 1,
 	}
 
-	_, err = repo.CreateClient(original)
+	_, err = repo.CreateClient(context.Background(), original)
 
 	test.RequireErrNotNil(t, err)
 	test.RequireErrContains(t, err, "repo error")
@@ -101,13 +102,13 @@ func TestGetClient_Success(t *testing.T) {
 	test.RequireErrNil(t, err)
 
 	mockRepo := clients.NewClientRepositoryMock()
-	mockRepo.GetClientFunc = func(id int) (*clients.Client, error) {
+	mockRepo.GetClientFunc = func(ctx context.Context, id int) (*clients.Client, error) {
 		return &u, nil
 	}
 
 	repo := NewEncryptedClientRepo(mockRepo, keyManager)
 
-	retrieved, err := repo.GetClient(1)
+	retrieved, err := repo.GetClient(context.Background(), 1)
 
 	test.RequireErrNil(t, err)
 
@@ -119,13 +120,13 @@ func TestGetClient_Success(t *testing.T) {
 func TestGetClient_RepoError(t *testing.T) {
 	keyManager := mocks.NewKeyManagerMock()
 	mockRepo := clients.NewClientRepositoryMock()
-	mockRepo.GetClientFunc = func(id int) (*clients.Client, error) {
+	mockRepo.GetClientFunc = func(ctx context.Context, id int) (*clients.Client, error) {
 		return nil, errors.New("repo error")
 	}
 
 	repo := NewEncryptedClientRepo(mockRepo, keyManager)
 
-	_, err := repo.GetClient(1)
+	_, err := repo.GetClient(context.Background(), 1)
 
 	test.RequireErrNotNil(t, err)
 	test.RequireErrContains(t, err, "repo error")
@@ -159,7 +160,7 @@ func TestGetAllClients_Success(t *testing.T) {
 	test.RequireErrNil(t, err)
 
 	mockRepo := clients.NewClientRepositoryMock()
-	mockRepo.GetAllFunc = func() ([]clients.Client, error) {
+	mockRepo.GetAllFunc = func(ctx context.Context) ([]clients.Client, error) {
 		return []clients.Client{
 			u,
 		}, nil
@@ -167,7 +168,7 @@ func TestGetAllClients_Success(t *testing.T) {
 
 	repo := NewEncryptedClientRepo(mockRepo, keyManager)
 
-	retrieved, err := repo.GetAll()
+	retrieved, err := repo.GetAll(context.Background())
 	test.RequireErrNil(t, err)
 
 	if len(retrieved) != 1 {
@@ -182,13 +183,13 @@ func TestGetAllClients_Success(t *testing.T) {
 func TestGetAllClients_RepoError(t *testing.T) {
 	keyManager := mocks.NewKeyManagerMock()
 	mockRepo := clients.NewClientRepositoryMock()
-	mockRepo.GetAllFunc = func() ([]clients.Client, error) {
+	mockRepo.GetAllFunc = func(ctx context.Context) ([]clients.Client, error) {
 		return nil, errors.New("repo error")
 	}
 
 	repo := NewEncryptedClientRepo(mockRepo, keyManager)
 
-	_, err := repo.GetAll()
+	_, err := repo.GetAll(context.Background())
 	test.RequireErrNotNil(t, err)
 
 	test.RequireContains(t, err.Error(), "repo error")
@@ -197,13 +198,13 @@ func TestGetAllClients_RepoError(t *testing.T) {
 func TestDeleteClient_Success(t *testing.T) {
 	keyManager := mocks.NewKeyManagerMock()
 	mockRepo := clients.NewClientRepositoryMock()
-	mockRepo.DeleteFunc = func(x int) error {
+	mockRepo.DeleteFunc = func(ctx context.Context, x int) error {
 		return nil
 	}
 
 	repo := NewEncryptedClientRepo(mockRepo, keyManager)
 
-	if err := repo.Delete(1); err != nil {
+	if err := repo.Delete(context.Background(), 1); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
@@ -211,13 +212,13 @@ func TestDeleteClient_Success(t *testing.T) {
 func TestDeleteClient_RepoError(t *testing.T) {
 	keyManager := mocks.NewKeyManagerMock()
 	mockRepo := clients.NewClientRepositoryMock()
-	mockRepo.DeleteFunc = func(x int) error {
+	mockRepo.DeleteFunc = func(ctx context.Context, x int) error {
 		return errors.New("repo error")
 	}
 
 	repo := NewEncryptedClientRepo(mockRepo, keyManager)
 
-	err := repo.Delete(1)
+	err := repo.Delete(context.Background(), 1)
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -253,13 +254,13 @@ func TestFindByHashedClientname_Success(t *testing.T) {
 	test.RequireErrNil(t, err)
 
 	mockRepo := clients.NewClientRepositoryMock()
-	mockRepo.FindByHashedClientnameFunc = func(e string) (*clients.Client, error) {
+	mockRepo.FindByHashedClientnameFunc = func(ctx context.Context, e string) (*clients.Client, error) {
 		return &u, nil
 	}
 
 	repo := NewEncryptedClientRepo(mockRepo, keyManager)
 
-	retrieved, err := repo.FindByHashedClientname("email")
+	retrieved, err := repo.FindByHashedClientname(context.Background(), "email")
 	test.RequireErrNil(t, err)
 
 	if *retrieved != *original {
@@ -270,13 +271,13 @@ func TestFindByHashedClientname_Success(t *testing.T) {
 func TestFindByHashedClientname_RepoError(t *testing.T) {
 	keyManager := mocks.NewKeyManagerMock()
 	mockRepo := clients.NewClientRepositoryMock()
-	mockRepo.FindByHashedClientnameFunc = func(e string) (*clients.Client, error) {
+	mockRepo.FindByHashedClientnameFunc = func(ctx context.Context, e string) (*clients.Client, error) {
 		return nil, errors.New("repo error")
 	}
 
 	repo := NewEncryptedClientRepo(mockRepo, keyManager)
 
-	_, err := repo.FindByHashedClientname("email")
+	_, err := repo.FindByHashedClientname(context.Background(), "email")
 
 	test.RequireErrNotNil(t, err)
 	test.RequireErrContains(t, err, "repo error")
@@ -299,16 +300,30 @@ func TestUpdateRole_Success(t *testing.T) {
 		return kek
 	}
 
+	stored := &clients.Client{ID: 1, HashedClientname: "hashed"}
 	mockRepo := clients.NewClientRepositoryMock()
-	mockRepo.UpdateRoleFunc = func(id int, role string) error {
+	mockRepo.GetClientFunc = func(ctx context.Context, id int) (*clients.Client, error) {
+		return stored, nil
+	}
+	mockRepo.UpdateRoleFunc = func(ctx context.Context, id int, role string) error {
+		stored.Role = role
 		return nil
 	}
 
 	repo := NewEncryptedClientRepo(mockRepo, keyManager)
 
-	err = repo.UpdateRole(1, "role")
+	err = repo.UpdateRole(context.Background(), 1, "role")
+
+	test.RequireErrNil(t, err)
 
+	// role is bound to the client's row
+	role, err := DecryptField(stored, "Role", stored.Role, keyManager)
 	test.RequireErrNil(t, err)
+	if role != "role" {
+		t.Errorf("expected 'role', got %s", role)
+	}
+	_, err = DecryptField(&clients.Client{HashedClientname: "other"}, "Role", stored.Role, keyManager)
+	test.RequireErrNotNil(t, err)
 }
 
 func TestUpdateRole_RepoError(t *testing.T) {
@@ -321,13 +336,16 @@ func TestUpdateRole_RepoError(t *testing.T) {
 		return dbKey
 	}
 	mockRepo := clients.NewClientRepositoryMock()
-	mockRepo.UpdateRoleFunc = func(id int, role string) error {
+	mockRepo.GetClientFunc = func(ctx context.Context, id int) (*clients.Client, error) {
+		return &clients.Client{ID: id, HashedClientname: "hashed"}, nil
+	}
+	mockRepo.UpdateRoleFunc = func(ctx context.Context, id int, role string) error {
 		return errors.New("repo error")
 	}
 
 	repo := NewEncryptedClientRepo(mockRepo, keyManager)
 
-	err = repo.UpdateRole(1, "role")
+	err = repo.UpdateRole(context.Background(), 1, "role")
 
 	test.RequireErrNotNil(t, err)
 	test.RequireErrContains(t, err, "repo error")
@@ -350,17 +368,19 @@ func TestGetRole_Success(t *testing.T) {
 		return kek
 	}
 
-	encRole, err := EncryptString("role", dbKey)
+	row := &clients.Client{ID: 1, HashedClientname: "hashed"}
+	encRole, err := EncryptField(row, "Role", "role", keyManager)
 	test.RequireErrNil(t, err)
+	row.Role = encRole
 
 	mockRepo := clients.NewClientRepositoryMock()
-	mockRepo.GetRoleFunc = func(id int) (string, error) {
-		return encRole, nil
+	mockRepo.GetClientFunc = func(ctx context.Context, id int) (*clients.Client, error) {
+		return row, nil
 	}
 
 	repo := NewEncryptedClientRepo(mockRepo, keyManager)
 
-	role, err := repo.GetRole(1)
+	role, err := repo.GetRole(context.Background(), 1)
 
 	test.RequireErrNil(t, err)
 	if role != "role" {
@@ -371,13 +391,13 @@ func TestGetRole_Success(t *testing.T) {
 func TestGetRole_RepoError(t *testing.T) {
 	keyManager := mocks.NewKeyManagerMock()
 	mockRepo := clients.NewClientRepositoryMock()
-	mockRepo.GetRoleFunc = func(id int) (string, error) {
-		return "", errors.New("repo error")
+	mockRepo.GetClientFunc = func(ctx context.Context, id int) (*clients.Client, error) {
+		return nil, errors.New("repo error")
 	}
 
 	repo := NewEncryptedClientRepo(mockRepo, keyManager)
 
-	_, err := repo.GetRole(1)
+	_, err := repo.GetRole(context.Background(), 1)
 
 	test.RequireErrNotNil(t, err)
 	test.RequireErrContains(t, err, "repo error")
@@ -1,6 +1,7 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"kms/internal/clients"
 )
@@ -13,9 +14,12 @@ func NewPostgresAdminRepo(db *sql.DB) *PostgresAdminRepo {
 	return &PostgresAdminRepo{db: db}
 }
 
-func (r *PostgresAdminRepo) GetAdmin(id int) (*clients.Client, error) {
+func (r *PostgresAdminRepo) GetAdmin(ctx context.Context, id int) (*clients.Client, error) {
+	ctx, span := startSpan(ctx, "PostgresAdminRepo.GetAdmin")
+	defer span.End()
+
 	query := "SELECT * FROM clients WHERE id = $1"
 	var client clients.Client
-	err := r.db.QueryRow(query, id).Scan(&client.ID, &client.Clientname, &client.Password, &client.Role)
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&client.ID, &client.Clientname, &client.Password, &client.Role)
 	return &client, err
 }
@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"kms/internal/clients"
+	"strings"
+)
+
+// Columns in the order of 'SELECT * FROM api_keys', scopes are stored space separated
+func scanApiKey(row rowScanner, apiKey *clients.ApiKey) error {
+	var (
+		scopes     string
+		expiresAt  sql.NullTime
+		lastUsedAt sql.NullTime
+	)
+	if err := row.Scan(&apiKey.ID, &apiKey.ClientId, &apiKey.Name, &apiKey.Prefix, &apiKey.HashedKey, &scopes, &expiresAt, &lastUsedAt, &apiKey.CreatedAt); err != nil {
+		return err
+	}
+	apiKey.Scopes = strings.Fields(scopes)
+	if expiresAt.Valid {
+		apiKey.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		apiKey.LastUsedAt = &lastUsedAt.Time
+	}
+	return nil
+}
+
+func (r *PostgresClientRepo) CreateApiKey(ctx context.Context, apiKey *clients.ApiKey) (int, error) {
+	ctx, span := startSpan(ctx, "PostgresClientRepo.CreateApiKey")
+	defer span.End()
+
+	query := "INSERT INTO api_keys (clientId, name, prefix, hashedKey, scopes, expiresAt) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, createdAt"
+	var expiresAt sql.NullTime
+	if apiKey.ExpiresAt != nil {
+		expiresAt = sql.NullTime{Time: *apiKey.ExpiresAt, Valid: true}
+	}
+	var id int
+	err := r.db.QueryRowContext(ctx, query, apiKey.ClientId, apiKey.Name, apiKey.Prefix, apiKey.HashedKey, strings.Join(apiKey.Scopes, " "), expiresAt).Scan(&id, &apiKey.CreatedAt)
+	return id, err
+}
+
+func (r *PostgresClientRepo) FindApiKey(ctx context.Context, prefix string) (*clients.ApiKey, error) {
+	ctx, span := startSpan(ctx, "PostgresClientRepo.FindApiKey")
+	defer span.End()
+
+	query := "SELECT * FROM api_keys WHERE prefix = $1"
+	var apiKey clients.ApiKey
+	err := scanApiKey(r.db.QueryRowContext(ctx, query, prefix), &apiKey)
+	return &apiKey, err
+}
+
+func (r *PostgresClientRepo) GetApiKeys(ctx context.Context, clientId int) ([]clients.ApiKey, error) {
+	ctx, span := startSpan(ctx, "PostgresClientRepo.GetApiKeys", "kms.client_id", clientId)
+	defer span.End()
+
+	query := "SELECT * FROM api_keys WHERE clientId = $1 ORDER BY id ASC"
+	var apiKeys []clients.ApiKey
+	rows, err := r.db.QueryContext(ctx, query, clientId)
+	if err != nil {
+		return apiKeys, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var apiKey clients.ApiKey
+		if err := scanApiKey(rows, &apiKey); err != nil {
+			return apiKeys, err
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+	return apiKeys, rows.Err()
+}
+
+func (r *PostgresClientRepo) DeleteApiKey(ctx context.Context, clientId, id int) error {
+	ctx, span := startSpan(ctx, "PostgresClientRepo.DeleteApiKey", "kms.client_id", clientId)
+	defer span.End()
+
+	query := "DELETE FROM api_keys WHERE clientId = $1 AND id = $2"
+	res, err := r.db.ExecContext(ctx, query, clientId, id)
+	if err != nil {
+		return err
+	}
+	return requireRowsAffected(res, map[string]interface{}{
+		"clientId": clientId,
+		"id":       id,
+	})
+}
+
+func (r *PostgresClientRepo) TouchApiKey(ctx context.Context, id int) error {
+	ctx, span := startSpan(ctx, "PostgresClientRepo.TouchApiKey")
+	defer span.End()
+
+	_, err := r.db.ExecContext(ctx, "UPDATE api_keys SET lastUsedAt = NOW() WHERE id = $1", id)
+	return err
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"kms/internal/clients"
+)
+
+// Columns in the order of 'SELECT * FROM client_certificates'
+func scanCertificate(row rowScanner, cert *clients.Certificate) error {
+	var pin sql.NullString
+	if err := row.Scan(&cert.ID, &cert.ClientId, &cert.HashedIdentity, &pin, &cert.CreatedAt); err != nil {
+		return err
+	}
+	cert.Pin = pin.String
+	return nil
+}
+
+func (r *PostgresClientRepo) CreateCertificate(ctx context.Context, cert *clients.Certificate) (int, error) {
+	ctx, span := startSpan(ctx, "PostgresClientRepo.CreateCertificate")
+	defer span.End()
+
+	query := "INSERT INTO client_certificates (clientId, hashedIdentity, pin) VALUES ($1, $2, $3) RETURNING id"
+	pin := sql.NullString{String: cert.Pin, Valid: cert.Pin != ""}
+	var id int
+	err := r.db.QueryRowContext(ctx, query, cert.ClientId, cert.HashedIdentity, pin).Scan(&id)
+	return id, err
+}
+
+func (r *PostgresClientRepo) FindCertificate(ctx context.Context, hashedIdentity string) (*clients.Certificate, error) {
+	ctx, span := startSpan(ctx, "PostgresClientRepo.FindCertificate")
+	defer span.End()
+
+	query := "SELECT * FROM client_certificates WHERE hashedIdentity = $1"
+	var cert clients.Certificate
+	err := scanCertificate(r.db.QueryRowContext(ctx, query, hashedIdentity), &cert)
+	return &cert, err
+}
+
+func (r *PostgresClientRepo) GetCertificates(ctx context.Context, clientId int) ([]clients.Certificate, error) {
+	ctx, span := startSpan(ctx, "PostgresClientRepo.GetCertificates", "kms.client_id", clientId)
+	defer span.End()
+
+	query := "SELECT * FROM client_certificates WHERE clientId = $1 ORDER BY id ASC"
+	var certs []clients.Certificate
+	rows, err := r.db.QueryContext(ctx, query, clientId)
+	if err != nil {
+		return certs, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cert clients.Certificate
+		if err := scanCertificate(rows, &cert); err != nil {
+			return certs, err
+		}
+		certs = append(certs, cert)
+	}
+	return certs, rows.Err()
+}
+
+func (r *PostgresClientRepo) DeleteCertificate(ctx context.Context, clientId, id int) error {
+	ctx, span := startSpan(ctx, "PostgresClientRepo.DeleteCertificate", "kms.client_id", clientId)
+	defer span.End()
+
+	query := "DELETE FROM client_certificates WHERE clientId = $1 AND id = $2"
+	res, err := r.db.ExecContext(ctx, query, clientId, id)
+	if err != nil {
+		return err
+	}
+	return requireRowsAffected(res, map[string]interface{}{
+		"clientId": clientId,
+		"id":       id,
+	})
+}
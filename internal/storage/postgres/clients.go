@@ -1,6 +1,7 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"kms/internal/clients"
 	kmsErrors "kms/pkg/errors"
@@ -14,24 +15,33 @@ func NewPostgresClientRepo(db *sql.DB) *PostgresClientRepo {
 	return &PostgresClientRepo{db: db}
 }
 
-func (r *PostgresClientRepo) CreateClient(client *clients.Client) (int, error) {
+func (r *PostgresClientRepo) CreateClient(ctx context.Context, client *clients.Client) (int, error) {
+	ctx, span := startSpan(ctx, "PostgresClientRepo.CreateClient")
+	defer span.End()
+
 	query := "INSERT INTO clients (clientname, hashedClientname, password, role) VALUES ($1, $2, $3, $4) RETURNING id"
 	var id int
-	err := r.db.QueryRow(query, client.Clientname, client.HashedClientname, client.Password, client.Role).Scan(&id)
+	err := r.db.QueryRowContext(ctx, query, client.Clientname, client.HashedClientname, client.Password, client.Role).Scan(&id)
 	return id, err
 }
 
-func (r *PostgresClientRepo) GetClient(id int) (*clients.Client, error) {
+func (r *PostgresClientRepo) GetClient(ctx context.Context, id int) (*clients.Client, error) {
+	ctx, span := startSpan(ctx, "PostgresClientRepo.GetClient")
+	defer span.End()
+
 	query := "SELECT * FROM clients WHERE id = $1"
 	var client clients.Client
-	err := r.db.QueryRow(query, id).Scan(&client.ID, &client.Clientname, &client.HashedClientname, &client.Password, &client.Role)
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&client.ID, &client.Clientname, &client.HashedClientname, &client.Password, &client.Role)
 	return &client, err
 }
 
-func (r *PostgresClientRepo) GetAll() ([]clients.Client, error) {
+func (r *PostgresClientRepo) GetAll(ctx context.Context) ([]clients.Client, error) {
+	ctx, span := startSpan(ctx, "PostgresClientRepo.GetAll")
+	defer span.End()
+
 	query := "SELECT * FROM clients"
 	var allClients []clients.Client
-	rows, err := r.db.Query(query)
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return allClients, err
 	}
@@ -47,25 +57,48 @@ func (r *PostgresClientRepo) GetAll() ([]clients.Client, error) {
 	return allClients, nil
 }
 
-func (r *PostgresClientRepo) Delete(clientId int) error {
+func (r *PostgresClientRepo) Delete(ctx context.Context, clientId int) error {
+	ctx, span := startSpan(ctx, "PostgresClientRepo.Delete", "kms.client_id", clientId)
+	defer span.End()
+
 	query := "DELETE FROM clients WHERE id = $1"
-	_, err := r.db.Exec(query, clientId)
+	_, err := r.db.ExecContext(ctx, query, clientId)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r *PostgresClientRepo) FindByHashedClientname(name string) (*clients.Client, error) {
+func (r *PostgresClientRepo) FindByHashedClientname(ctx context.Context, name string) (*clients.Client, error) {
+	ctx, span := startSpan(ctx, "PostgresClientRepo.FindByHashedClientname")
+	defer span.End()
+
 	query := "SELECT * FROM clients WHERE hashedClientname = $1"
 	var client clients.Client
-	err := r.db.QueryRow(query, name).Scan(&client.ID, &client.Clientname, &client.HashedClientname, &client.Password, &client.Role)
+	err := r.db.QueryRowContext(ctx, query, name).Scan(&client.ID, &client.Clientname, &client.HashedClientname, &client.Password, &client.Role)
 	return &client, err
 }
 
-func (r *PostgresClientRepo) UpdateRole(id int, role string) error {
+func (r *PostgresClientRepo) UpdatePassword(ctx context.Context, id int, hashedPassword string) error {
+	ctx, span := startSpan(ctx, "PostgresClientRepo.UpdatePassword")
+	defer span.End()
+
+	query := "UPDATE clients SET password = $1 WHERE id = $2"
+	res, err := r.db.ExecContext(ctx, query, hashedPassword, id)
+	if err != nil {
+		return err
+	}
+	return requireRowsAffected(res, map[string]interface{}{
+		"id": id,
+	})
+}
+
+func (r *PostgresClientRepo) UpdateRole(ctx context.Context, id int, role string) error {
+	ctx, span := startSpan(ctx, "PostgresClientRepo.UpdateRole")
+	defer span.End()
+
 	query := "UPDATE clients SET role = $1 WHERE id = $2"
-	res, err := r.db.Exec(query, role, id)
+	res, err := r.db.ExecContext(ctx, query, role, id)
 
 	if err != nil {
 		return err
@@ -84,9 +117,12 @@ func (r *PostgresClientRepo) UpdateRole(id int, role string) error {
 	return nil
 }
 
-func (r *PostgresClientRepo) GetRole(id int) (string, error) {
+func (r *PostgresClientRepo) GetRole(ctx context.Context, id int) (string, error) {
+	ctx, span := startSpan(ctx, "PostgresClientRepo.GetRole")
+	defer span.End()
+
 	query := "SELECT role FROM clients WHERE id = $1"
 	var role string
-	err := r.db.QueryRow(query, id).Scan(&role)
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&role)
 	return role, err
 }
@@ -1,13 +1,18 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"kms/internal/keys"
+	kmsErrors "kms/pkg/errors"
+	"kms/pkg/tracing"
+	"time"
 )
 
 type PostgresKeyRepo struct {
 	db *sql.DB
+	// only set on the repos returned by BeginTransaction
 	tx *sql.Tx
 }
 
@@ -15,13 +20,30 @@ func NewPostgresKeyRepo(db *sql.DB) *PostgresKeyRepo {
 	return &PostgresKeyRepo{db: db}
 }
 
-func (r *PostgresKeyRepo) BeginTransaction() (keys.KeyRepository, error) {
-	tx, err := r.db.Begin()
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// Span per repository call, the statement itself is not recorded since it can hold key material
+func startSpan(ctx context.Context, name string, attrs ...any) (context.Context, *tracing.Span) {
+	return tracing.Start(ctx, name, append([]any{"db.system", "postgresql"}, attrs...)...)
+}
+
+// Columns in the order of 'SELECT * FROM keys'
+func scanKey(row rowScanner, key *keys.Key) error {
+	return row.Scan(&key.ID, &key.ClientId, &key.KeyReference, &key.Version, &key.DEK, &key.State, &key.Encoding, &key.DeleteAfter, &key.CreatedAt, &key.Algorithm)
+}
+
+// Returns a new repo whose queries run in the transaction, r itself is left as is
+func (r *PostgresKeyRepo) BeginTransaction(ctx context.Context) (keys.KeyRepository, error) {
+	ctx, span := startSpan(ctx, "PostgresKeyRepo.BeginTransaction")
+	defer span.End()
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
-	r.tx = tx
-	return r, nil
+	return &PostgresKeyRepo{db: r.db, tx: tx}, nil
 }
 
 func (r *PostgresKeyRepo) CommitTransaction() error {
@@ -48,62 +70,198 @@ func (r *PostgresKeyRepo) RollbackTransaction() error {
 	return nil
 }
 
-func (r *PostgresKeyRepo) CreateKey(key *keys.Key) (*keys.Key, error) {
-	query := "INSERT INTO keys (clientId, keyReference, version, dek, state, encoding) VALUES ($1, $2, $3, $4, $5, $6) RETURNING *"
+func (r *PostgresKeyRepo) CreateKey(ctx context.Context, key *keys.Key) (*keys.Key, error) {
+	ctx, span := startSpan(ctx, "PostgresKeyRepo.CreateKey")
+	defer span.End()
+
+	query := "INSERT INTO keys (clientId, keyReference, version, dek, state, encoding, algorithm) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING *"
 	var newKey keys.Key
 	if r.tx != nil {
-		err := r.tx.QueryRow(query, key.ClientId, key.KeyReference, key.Version, key.DEK, key.State, key.Encoding).
-			Scan(&newKey.ID, &newKey.ClientId, &newKey.KeyReference, &newKey.Version, &newKey.DEK, &newKey.State, &newKey.Encoding)
+		err := scanKey(r.tx.QueryRowContext(ctx, query, key.ClientId, key.KeyReference, key.Version, key.DEK, key.State, key.Encoding, key.Algorithm), &newKey)
 		return &newKey, err
 	}
-	err := r.db.QueryRow(query, key.ClientId, key.KeyReference, key.Version, key.DEK, key.State, key.Encoding).
-		Scan(&newKey.ID, &newKey.ClientId, &newKey.KeyReference, &newKey.Version, &newKey.DEK, &newKey.State, &newKey.Encoding)
+	err := scanKey(r.db.QueryRowContext(ctx, query, key.ClientId, key.KeyReference, key.Version, key.DEK, key.State, key.Encoding, key.Algorithm), &newKey)
 	return &newKey, err
 }
 
-func (r *PostgresKeyRepo) GetKey(clientId int, keyReference string, version int) (*keys.Key, error) {
+func (r *PostgresKeyRepo) GetKey(ctx context.Context, clientId int, keyReference string, version int) (*keys.Key, error) {
+	ctx, span := startSpan(ctx, "PostgresKeyRepo.GetKey", "kms.client_id", clientId)
+	defer span.End()
+
 	query := "SELECT * FROM keys WHERE clientId = $1 AND keyReference = $2 AND version = $3"
 	var key keys.Key
-	err := r.db.QueryRow(query, clientId, keyReference, version).
-		Scan(&key.ID, &key.ClientId, &key.KeyReference, &key.Version, &key.DEK, &key.State, &key.Encoding)
+	if r.tx != nil {
+		err := scanKey(r.tx.QueryRowContext(ctx, query, clientId, keyReference, version), &key)
+		return &key, err
+	}
+	err := scanKey(r.db.QueryRowContext(ctx, query, clientId, keyReference, version), &key)
 	return &key, err
 }
 
-func (r *PostgresKeyRepo) GetLatestKey(clientId int, keyReference string) (*keys.Key, error) {
+// In a transaction the row stays locked until it ends, so concurrent rotations and destroys of the key run one after another
+func (r *PostgresKeyRepo) GetLatestKey(ctx context.Context, clientId int, keyReference string) (*keys.Key, error) {
+	ctx, span := startSpan(ctx, "PostgresKeyRepo.GetLatestKey", "kms.client_id", clientId)
+	defer span.End()
+
 	query := "SELECT * FROM keys WHERE clientId = $1 AND keyReference = $2 ORDER BY version DESC LIMIT 1"
 	var key keys.Key
 	if r.tx != nil {
-		err := r.tx.QueryRow(query, clientId, keyReference).
-			Scan(&key.ID, &key.ClientId, &key.KeyReference, &key.Version, &key.DEK, &key.State, &key.Encoding)
+		err := scanKey(r.tx.QueryRowContext(ctx, query+" FOR UPDATE", clientId, keyReference), &key)
 		return &key, err
 	}
-	err := r.db.QueryRow(query, clientId, keyReference).
-		Scan(&key.ID, &key.ClientId, &key.KeyReference, &key.Version, &key.DEK, &key.State, &key.Encoding)
+	err := scanKey(r.db.QueryRowContext(ctx, query, clientId, keyReference), &key)
 	return &key, err
 }
 
-func (r *PostgresKeyRepo) UpdateKey(clientId int, keyReference string, version int, state string) error {
+func (r *PostgresKeyRepo) UpdateKey(ctx context.Context, clientId int, keyReference string, version int, state string) error {
+	ctx, span := startSpan(ctx, "PostgresKeyRepo.UpdateKey", "kms.client_id", clientId)
+	defer span.End()
+
 	query := "UPDATE keys SET state = $1 WHERE clientId = $2 AND keyReference = $3 AND version = $4"
 	if r.tx != nil {
-		_, err := r.tx.Exec(query, state, clientId, keyReference, version)
+		_, err := r.tx.ExecContext(ctx, query, state, clientId, keyReference, version)
 		return err
 	}
-	_, err := r.db.Exec(query, state, clientId, keyReference, version)
+	_, err := r.db.ExecContext(ctx, query, state, clientId, keyReference, version)
 	return err
 }
 
-// don't care for version, delete everything
-func (r *PostgresKeyRepo) Delete(clientId int, keyReference string) (int, error) {
-	query := "DELETE FROM keys WHERE clientId = $1 AND keyReference = $2 RETURNING id"
-	var keyId int
-	err := r.db.QueryRow(query, clientId, keyReference).Scan(&keyId)
-	return keyId, err
+// Applies to every version of the key
+func (r *PostgresKeyRepo) ScheduleDeletion(ctx context.Context, clientId int, keyReference string, deleteAfter time.Time) error {
+	ctx, span := startSpan(ctx, "PostgresKeyRepo.ScheduleDeletion", "kms.client_id", clientId)
+	defer span.End()
+
+	query := "UPDATE keys SET deleteAfter = $1 WHERE clientId = $2 AND keyReference = $3"
+	var res sql.Result
+	var err error
+	if r.tx != nil {
+		res, err = r.tx.ExecContext(ctx, query, deleteAfter, clientId, keyReference)
+	} else {
+		res, err = r.db.ExecContext(ctx, query, deleteAfter, clientId, keyReference)
+	}
+	if err != nil {
+		return err
+	}
+	return requireRowsAffected(res, map[string]interface{}{
+		"clientId":    clientId,
+		"deleteAfter": deleteAfter,
+	})
+}
+
+func (r *PostgresKeyRepo) CancelDeletion(ctx context.Context, clientId int, keyReference string) error {
+	ctx, span := startSpan(ctx, "PostgresKeyRepo.CancelDeletion", "kms.client_id", clientId)
+	defer span.End()
+
+	query := "UPDATE keys SET deleteAfter = NULL WHERE clientId = $1 AND keyReference = $2 AND deleteAfter IS NOT NULL"
+	var res sql.Result
+	var err error
+	if r.tx != nil {
+		res, err = r.tx.ExecContext(ctx, query, clientId, keyReference)
+	} else {
+		res, err = r.db.ExecContext(ctx, query, clientId, keyReference)
+	}
+	if err != nil {
+		return err
+	}
+	return requireRowsAffected(res, map[string]interface{}{
+		"clientId": clientId,
+	})
 }
 
-func (r *PostgresKeyRepo) GetAll() ([]keys.Key, error) {
+// Removes the rows holding the (KEK encrypted) DEKs, which are the only copies the KMS keeps
+func (r *PostgresKeyRepo) DestroyScheduled(ctx context.Context, before time.Time) (int, error) {
+	ctx, span := startSpan(ctx, "PostgresKeyRepo.DestroyScheduled")
+	defer span.End()
+
+	query := "DELETE FROM keys WHERE deleteAfter IS NOT NULL AND deleteAfter <= $1"
+	res, err := r.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// Ordered from oldest to newest version
+func (r *PostgresKeyRepo) GetVersions(ctx context.Context, clientId int, keyReference string) ([]keys.Key, error) {
+	ctx, span := startSpan(ctx, "PostgresKeyRepo.GetVersions", "kms.client_id", clientId)
+	defer span.End()
+
+	query := "SELECT * FROM keys WHERE clientId = $1 AND keyReference = $2 ORDER BY version ASC"
+	var versions []keys.Key
+	var rows *sql.Rows
+	var err error
+	if r.tx != nil {
+		rows, err = r.tx.QueryContext(ctx, query, clientId, keyReference)
+	} else {
+		rows, err = r.db.QueryContext(ctx, query, clientId, keyReference)
+	}
+	if err != nil {
+		return versions, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var key keys.Key
+		if err := scanKey(rows, &key); err != nil {
+			return versions, err
+		}
+		versions = append(versions, key)
+	}
+	return versions, rows.Err()
+}
+
+// All versions of all keys belonging to the client
+func (r *PostgresKeyRepo) GetClientKeys(ctx context.Context, clientId int) ([]keys.Key, error) {
+	ctx, span := startSpan(ctx, "PostgresKeyRepo.GetClientKeys", "kms.client_id", clientId)
+	defer span.End()
+
+	query := "SELECT * FROM keys WHERE clientId = $1 ORDER BY keyReference ASC, version ASC"
+	var clientKeys []keys.Key
+	rows, err := r.db.QueryContext(ctx, query, clientId)
+	if err != nil {
+		return clientKeys, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var key keys.Key
+		if err := scanKey(rows, &key); err != nil {
+			return clientKeys, err
+		}
+		clientKeys = append(clientKeys, key)
+	}
+	return clientKeys, rows.Err()
+}
+
+func (r *PostgresKeyRepo) DestroyVersion(ctx context.Context, clientId int, keyReference string, version int) error {
+	ctx, span := startSpan(ctx, "PostgresKeyRepo.DestroyVersion", "kms.client_id", clientId)
+	defer span.End()
+
+	query := "DELETE FROM keys WHERE clientId = $1 AND keyReference = $2 AND version = $3"
+	var res sql.Result
+	var err error
+	if r.tx != nil {
+		res, err = r.tx.ExecContext(ctx, query, clientId, keyReference, version)
+	} else {
+		res, err = r.db.ExecContext(ctx, query, clientId, keyReference, version)
+	}
+	if err != nil {
+		return err
+	}
+	return requireRowsAffected(res, map[string]interface{}{
+		"clientId": clientId,
+		"version":  version,
+	})
+}
+
+func (r *PostgresKeyRepo) GetAll(ctx context.Context) ([]keys.Key, error) {
+	ctx, span := startSpan(ctx, "PostgresKeyRepo.GetAll")
+	defer span.End()
+
 	query := "SELECT * FROM keys"
 	var allKeys []keys.Key
-	rows, err := r.db.Query(query)
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return allKeys, err
 	}
@@ -111,7 +269,7 @@ func (r *PostgresKeyRepo) GetAll() ([]keys.Key, error) {
 	defer rows.Close()
 	for rows.Next() {
 		var key keys.Key
-		err := rows.Scan(&key.ID, &key.ClientId, &key.KeyReference, &key.Version, &key.DEK, &key.State, &key.Encoding)
+		err := scanKey(rows, &key)
 		if err != nil {
 			return allKeys, err
 		}
@@ -119,3 +277,14 @@ func (r *PostgresKeyRepo) GetAll() ([]keys.Key, error) {
 	}
 	return allKeys, nil
 }
+
+func requireRowsAffected(res sql.Result, data map[string]interface{}) error {
+	nRows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if nRows == 0 {
+		return kmsErrors.WrapError(kmsErrors.ErrNoRowsAffected, data)
+	}
+	return nil
+}
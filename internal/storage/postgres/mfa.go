@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"kms/internal/clients"
+	"strings"
+)
+
+// Enrolling again replaces a previous (not yet enabled) enrolment, recovery codes are stored space separated
+func (r *PostgresClientRepo) SaveMfa(ctx context.Context, mfa *clients.Mfa) error {
+	ctx, span := startSpan(ctx, "PostgresClientRepo.SaveMfa")
+	defer span.End()
+
+	query := `INSERT INTO client_mfa (clientId, secret, enabled, lastStep, recoveryCodes) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (clientId) DO UPDATE SET secret = EXCLUDED.secret, enabled = EXCLUDED.enabled, lastStep = EXCLUDED.lastStep, recoveryCodes = EXCLUDED.recoveryCodes`
+	_, err := r.db.ExecContext(ctx, query, mfa.ClientId, mfa.Secret, mfa.Enabled, mfa.LastStep, strings.Join(mfa.RecoveryCodes, " "))
+	return err
+}
+
+func (r *PostgresClientRepo) GetMfa(ctx context.Context, clientId int) (*clients.Mfa, error) {
+	ctx, span := startSpan(ctx, "PostgresClientRepo.GetMfa", "kms.client_id", clientId)
+	defer span.End()
+
+	query := "SELECT * FROM client_mfa WHERE clientId = $1"
+	var (
+		mfa           clients.Mfa
+		recoveryCodes string
+	)
+	err := r.db.QueryRowContext(ctx, query, clientId).Scan(&mfa.ClientId, &mfa.Secret, &mfa.Enabled, &mfa.LastStep, &recoveryCodes, &mfa.CreatedAt)
+	mfa.RecoveryCodes = strings.Fields(recoveryCodes)
+	return &mfa, err
+}
+
+// Compare-and-swap against prev, so concurrent logins can't use the same code twice (ErrNoRows if they did)
+func (r *PostgresClientRepo) UseMfa(ctx context.Context, prev *clients.Mfa, lastStep int64, recoveryCodes []string) error {
+	ctx, span := startSpan(ctx, "PostgresClientRepo.UseMfa")
+	defer span.End()
+
+	query := "UPDATE client_mfa SET lastStep = $1, recoveryCodes = $2 WHERE clientId = $3 AND lastStep = $4 AND recoveryCodes = $5"
+	res, err := r.db.ExecContext(ctx, query, lastStep, strings.Join(recoveryCodes, " "), prev.ClientId, prev.LastStep, strings.Join(prev.RecoveryCodes, " "))
+	if err != nil {
+		return err
+	}
+	return requireRowsAffected(res, map[string]interface{}{
+		"clientId": prev.ClientId,
+	})
+}
+
+func (r *PostgresClientRepo) DeleteMfa(ctx context.Context, clientId int) error {
+	ctx, span := startSpan(ctx, "PostgresClientRepo.DeleteMfa", "kms.client_id", clientId)
+	defer span.End()
+
+	res, err := r.db.ExecContext(ctx, "DELETE FROM client_mfa WHERE clientId = $1", clientId)
+	if err != nil {
+		return err
+	}
+	return requireRowsAffected(res, map[string]interface{}{
+		"clientId": clientId,
+	})
+}
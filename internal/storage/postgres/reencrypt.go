@@ -0,0 +1,123 @@
+package postgres
+
+import (
+	"database/sql"
+	c "kms/internal/bootstrap/context"
+	"kms/internal/clients"
+	"kms/internal/keys"
+	"kms/internal/storage/encryption"
+)
+
+const encryptionContextMigration = "encryption_context"
+
+// Re-encrypts rows written before ciphertexts were bound to their row context, run by InitSchema at startup.
+// It runs once: a row in data_migrations is written in the same transaction, later calls see it and do nothing.
+// Rows that already decrypt with their context are left as they are.
+// A row that decrypts neither way fails the whole migration, and it's tried again at the next startup.
+// Returns the number of rows that were re-encrypted and whether the migration ran.
+func MigrateEncryptionContext(db *sql.DB, keyManager c.KeyManager) (int, bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	// A concurrent startup blocks on the primary key until this transaction ends, then inserts nothing
+	res, err := tx.Exec("INSERT INTO data_migrations (name) VALUES ($1) ON CONFLICT (name) DO NOTHING", encryptionContextMigration)
+	if err != nil {
+		return 0, false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return 0, false, err
+	}
+
+	keyCount, err := migrateKeys(tx, keyManager)
+	if err != nil {
+		return 0, false, err
+	}
+	clientCount, err := migrateClients(tx, keyManager)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, false, err
+	}
+	return keyCount + clientCount, true, nil
+}
+
+func migrateKeys(tx *sql.Tx, keyManager c.KeyManager) (int, error) {
+	rows, err := tx.Query("SELECT * FROM keys")
+	if err != nil {
+		return 0, err
+	}
+	var legacy []keys.Key
+	for rows.Next() {
+		var key keys.Key
+		if err := scanKey(rows, &key); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if encryption.DecryptFields(&keys.Key{}, &key, keyManager) != nil {
+			legacy = append(legacy, key)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, key := range legacy {
+		dec := &keys.Key{}
+		if err := encryption.DecryptFieldsLegacy(dec, &key, keyManager); err != nil {
+			return 0, err
+		}
+		enc := &keys.Key{}
+		if err := encryption.EncryptFields(enc, dec, keyManager); err != nil {
+			return 0, err
+		}
+		_, err := tx.Exec("UPDATE keys SET dek = $1, state = $2, encoding = $3 WHERE id = $4", enc.DEK, enc.State, enc.Encoding, key.ID)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(legacy), nil
+}
+
+func migrateClients(tx *sql.Tx, keyManager c.KeyManager) (int, error) {
+	rows, err := tx.Query("SELECT * FROM clients")
+	if err != nil {
+		return 0, err
+	}
+	var legacy []clients.Client
+	for rows.Next() {
+		var client clients.Client
+		if err := rows.Scan(&client.ID, &client.Clientname, &client.HashedClientname, &client.Password, &client.Role); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if encryption.DecryptFields(&clients.Client{}, &client, keyManager) != nil {
+			legacy = append(legacy, client)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, client := range legacy {
+		dec := &clients.Client{}
+		if err := encryption.DecryptFieldsLegacy(dec, &client, keyManager); err != nil {
+			return 0, err
+		}
+		enc := &clients.Client{}
+		if err := encryption.EncryptFields(enc, dec, keyManager); err != nil {
+			return 0, err
+		}
+		_, err := tx.Exec("UPDATE clients SET clientname = $1, role = $2 WHERE id = $3", enc.Clientname, enc.Role, client.ID)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(legacy), nil
+}
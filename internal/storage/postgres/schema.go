@@ -3,15 +3,17 @@ package postgres
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"kms/internal/bootstrap"
 	c "kms/internal/bootstrap/context"
+	"kms/internal/clients"
 	"kms/internal/storage/encryption"
 	"kms/pkg/hashing"
 
 	"github.com/golang-migrate/migrate/v4"
 )
 
-func ensureMasterAdmin(cfg c.KmsConfig, db *sql.DB, keyManager c.KeyManager) error {
+func ensureMasterAdmin(cfg *c.Config, db *sql.DB, keyManager c.KeyManager) error {
 	var count int
 	err := db.QueryRow("SELECT COUNT(*) FROM clients WHERE role = 'admin'").Scan(&count)
 	if err != nil {
@@ -19,36 +21,37 @@ func ensureMasterAdmin(cfg c.KmsConfig, db *sql.DB, keyManager c.KeyManager) err
 	}
 
 	if count == 0 {
-		hashedPw, err := hashing.HashPassword(cfg["MASTER_ADMIN_PASSWORD"])
+		hashedPw, err := hashing.HashPassword(cfg.MasterAdmin.Password)
 		if err != nil {
 			return err
 		}
-		encryptedAdmin, err := encryption.EncryptString("admin", keyManager.DBKey())
+		clientnameSecret, err := keyManager.HashKey("clientname")
 		if err != nil {
 			return err
 		}
-		encryptedClientname, err := encryption.EncryptString(cfg["MASTER_ADMIN_USERNAME"], keyManager.DBKey())
-		if err != nil {
-			return err
+		admin := &clients.Client{
+			Clientname:       cfg.MasterAdmin.Username,
+			HashedClientname: hashing.HashHS256ToB64([]byte(cfg.MasterAdmin.Username), clientnameSecret),
+			Role:             "admin",
 		}
-		clientnameSecret, err := keyManager.HashKey("clientname")
-		if err != nil {
+		encAdmin := &clients.Client{}
+		if err := encryption.EncryptFields(encAdmin, admin, keyManager); err != nil {
 			return err
 		}
 		_, err = db.Exec(
 			"INSERT INTO clients (clientname, hashedClientname, password, role) VALUES ($1, $2, $3, $4)",
-			encryptedClientname,
-			hashing.HashHS256ToB64([]byte(cfg["MASTER_ADMIN_USERNAME"]), clientnameSecret),
+			encAdmin.Clientname,
+			encAdmin.HashedClientname,
 			hashedPw,
-			encryptedAdmin,
+			encAdmin.Role,
 		)
 		return err
 	}
 	return nil
 }
 
-func InitSchema(cfg c.KmsConfig, db *sql.DB, keyManager c.KeyManager, migrationsPath string) error {
-	clearTables := cfg["ENV"] == "dev" && cfg["CLEAR_DB"] == "true"
+func InitSchema(cfg *c.Config, db *sql.DB, keyManager c.KeyManager, migrationsPath string) error {
+	clearTables := cfg.Env == "dev" && cfg.ClearDB
 	if clearTables {
 		if err := bootstrap.MigrateDown(db, migrationsPath); err != nil {
 			if !errors.Is(err, migrate.ErrNoChange) {
@@ -62,6 +65,10 @@ func InitSchema(cfg c.KmsConfig, db *sql.DB, keyManager c.KeyManager, migrations
 			return err
 		}
 	}
+	// rows written before ciphertexts were bound to their row would fail to decrypt at request time
+	if _, _, err := MigrateEncryptionContext(db, keyManager); err != nil {
+		return fmt.Errorf("failed to re-encrypt rows with their encryption context: %w", err)
+	}
 	if err := ensureMasterAdmin(cfg, db, keyManager); err != nil {
 		return err
 	}
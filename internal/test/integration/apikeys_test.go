@@ -0,0 +1,164 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"kms/internal/api/dto"
+	"kms/internal/apikeys"
+	"kms/internal/test"
+	"strings"
+	"testing"
+)
+
+func requireApiKey(t *testing.T, token, path, body string) *apikeys.CreateApiKeyResponse {
+	resp, err := doRequest("POST", path, body, "Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+
+	requireStatusCode(t, resp.StatusCode, 200)
+
+	var apiKey apikeys.CreateApiKeyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiKey); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	return &apiKey
+}
+
+func TestApiKey_Scopes(t *testing.T) {
+	u, err := requireClient(appCtx, "apikeys-scopes", "client")
+	test.RequireErrNil(t, err)
+	_, err = requireKey(appCtx, u.ID, "apikeys-scopes-ref", 1, "in-use")
+	test.RequireErrNil(t, err)
+
+	token, err := requireJWT(appCtx, u)
+	test.RequireErrNil(t, err)
+
+	apiKey := requireApiKey(t, token, "/auth/api-keys/actions/create", `{"name":"ci","scopes":["keys:read"]}`)
+
+	// used directly
+	resp, err := doRequest("GET", "/keys/apikeys-scopes-ref/1", "", "Authorization", "ApiKey "+apiKey.Key)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+	requireStatusCode(t, resp.StatusCode, 200)
+
+	resp, err = doRequest("POST", "/keys/apikeys-scopes-ref/actions/rotate", "", "Authorization", "ApiKey "+apiKey.Key)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+	requireForbidden(t, resp)
+
+	// exchanged for a JWT with the same scopes
+	resp, err = doRequest("POST", "/auth/login/api-key", "", "Authorization", "ApiKey "+apiKey.Key)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+	requireStatusCode(t, resp.StatusCode, 200)
+
+	var tokenResponse dto.TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+
+	resp, err = doRequest("POST", "/keys/apikeys-scopes-ref/actions/rotate", "", "Authorization", "Bearer "+tokenResponse.Token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+	requireForbidden(t, resp)
+
+	// can't create a key with more scopes
+	resp, err = doRequest("POST", "/auth/api-keys/actions/create", `{"name":"escalate","scopes":["keys:write"]}`, "Authorization", "ApiKey "+apiKey.Key)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+	requireForbidden(t, resp)
+
+	stored, err := appCtx.ClientRepo.FindApiKey(context.Background(), apiKey.Prefix)
+	test.RequireErrNil(t, err)
+	if stored.LastUsedAt == nil {
+		t.Errorf("expected last use to be recorded")
+	}
+}
+
+func TestApiKey_Revoke(t *testing.T) {
+	admin, err := requireClient(appCtx, "apikeys-revoke-admin", "admin")
+	test.RequireErrNil(t, err)
+	u, err := requireClient(appCtx, "apikeys-revoke-client", "client")
+	test.RequireErrNil(t, err)
+
+	token, err := requireMfaJWT(appCtx, admin)
+	test.RequireErrNil(t, err)
+
+	apiKey := requireApiKey(t, token, fmt.Sprintf("/clients/%d/api-keys/actions/create", u.ID), `{"name":"deploy","scopes":["keys:read","keys:write"],"ttlDays":7}`)
+	if apiKey.ClientId != u.ID || apiKey.ExpiresAt == nil {
+		t.Fatalf("unexpected API key: %+v", apiKey)
+	}
+
+	resp, err := doRequest("DELETE", fmt.Sprintf("/clients/%d/api-keys/%d", u.ID, apiKey.ID), "", "Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+	requireStatusCode(t, resp.StatusCode, 204)
+
+	resp, err = doRequest("POST", "/auth/login/api-key", "", "Authorization", "ApiKey "+apiKey.Key)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+	requireUnauthorized(t, resp)
+}
+
+func TestApiKey_AdminScope(t *testing.T) {
+	admin, err := requireClient(appCtx, "apikeys-admin-scope", "admin")
+	test.RequireErrNil(t, err)
+
+	token, err := requireJWT(appCtx, admin)
+	test.RequireErrNil(t, err)
+
+	apiKey := requireApiKey(t, token, "/auth/api-keys/actions/create", `{"name":"reader","scopes":["keys:read"]}`)
+
+	resp, err := doRequest("GET", "/clients", "", "Authorization", "ApiKey "+apiKey.Key)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+	requireForbidden(t, resp)
+}
+
+// A leaked key (or a JWT exchanged for it) can't manage the client's credentials
+func TestApiKey_CredentialRoutes(t *testing.T) {
+	u, err := requireClient(appCtx, "apikeys-credentials", "client")
+	test.RequireErrNil(t, err)
+
+	token, err := requireJWT(appCtx, u)
+	test.RequireErrNil(t, err)
+
+	fullAccess := requireApiKey(t, token, "/auth/api-keys/actions/create", `{"name":"deploy","scopes":["keys:read","keys:write"]}`)
+	apiKey := requireApiKey(t, token, "/auth/api-keys/actions/create", `{"name":"reader","scopes":["keys:read"]}`)
+
+	resp, err := doRequest("POST", "/auth/login/api-key", "", "Authorization", "ApiKey "+apiKey.Key)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+	requireStatusCode(t, resp.StatusCode, 200)
+	var tokenResponse dto.TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+
+	routes := []struct{ method, path, body string }{
+		{"POST", "/auth/api-keys/actions/create", `{"name":"persist","scopes":["keys:read"]}`},
+		{"GET", "/auth/api-keys", ""},
+		{"DELETE", fmt.Sprintf("/auth/api-keys/%d", fullAccess.ID), ""},
+		{"POST", "/auth/password", `{"oldPassword":"x","newPassword":"y"}`},
+		{"POST", "/auth/mfa/actions/enroll", ""},
+		{"POST", "/auth/mfa/actions/activate", `{"code":"000000"}`},
+		{"POST", "/auth/mfa/actions/disable", `{"code":"000000"}`},
+	}
+	for _, authHeader := range []string{"ApiKey " + apiKey.Key, "Bearer " + tokenResponse.Token} {
+		for _, route := range routes {
+			resp, err := doRequest(route.method, route.path, route.body, "Authorization", authHeader)
+			requireReqNotFailed(t, err)
+			defer resp.Body.Close()
+			if resp.StatusCode != 403 {
+				t.Errorf("%s %s (%s): expected 403, got %d", route.method, route.path, strings.Fields(authHeader)[0], resp.StatusCode)
+			}
+		}
+	}
+
+	// the full access key is still there
+	resp, err = doRequest("POST", "/auth/login/api-key", "", "Authorization", "ApiKey "+fullAccess.Key)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+	requireStatusCode(t, resp.StatusCode, 200)
+}
@@ -1,6 +1,7 @@
 package integration
 
 import (
+	"context"
 	"fmt"
 	"kms/internal/test"
 	"kms/pkg/hashing"
@@ -57,7 +58,7 @@ func TestSignup(t *testing.T) {
 	test.RequireErrNil(t, err)
 
 	// check if client was added to db
-	client, err := appCtx.ClientRepo.FindByHashedClientname(hashing.HashHS256ToB64([]byte(clientname), clientHashKey))
+	client, err := appCtx.ClientRepo.FindByHashedClientname(context.Background(), hashing.HashHS256ToB64([]byte(clientname), clientHashKey))
 	test.RequireErrNil(t, err)
 
 	// check if clientname copied correctly
@@ -65,8 +66,8 @@ func TestSignup(t *testing.T) {
 		t.Errorf("expected clientname: %s, got %s", clientname, client.Clientname)
 	}
 	// check if role was properly set
-	if client.Role != appCtx.Cfg["DEFAULT_ROLE"] {
-		t.Errorf("expected role: %s, got %s", appCtx.Cfg["DEFAULT_ROLE"], client.Role)
+	if client.Role != appCtx.Cfg.DefaultRole {
+		t.Errorf("expected role: %s, got %s", appCtx.Cfg.DefaultRole, client.Role)
 	}
 }
 
@@ -0,0 +1,81 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"kms/internal/clients"
+	"kms/internal/test"
+	"testing"
+)
+
+func TestBindCertificate(t *testing.T) {
+	admin, err := requireClient(appCtx, "certs-bind-admin", "admin")
+	test.RequireErrNil(t, err)
+
+	u, err := requireClient(appCtx, "certs-bind-client", "client")
+	test.RequireErrNil(t, err)
+
+	token, err := requireMfaJWT(appCtx, admin)
+	test.RequireErrNil(t, err)
+
+	resp, err := doRequest("POST", fmt.Sprintf("/clients/%d/certificates/actions/bind", u.ID), `{"identity":"uri:spiffe://mesh/ns/certs-bind"}`,
+		"Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+
+	requireStatusCode(t, resp.StatusCode, 200)
+
+	var cert clients.Certificate
+	if err := json.NewDecoder(resp.Body).Decode(&cert); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+
+	certs, err := appCtx.ClientRepo.GetCertificates(context.Background(), u.ID)
+	test.RequireErrNil(t, err)
+	if len(certs) != 1 || certs[0].ID != cert.ID {
+		t.Fatalf("expected certificate %d, got %v", cert.ID, certs)
+	}
+
+	// the same identity can't be bound twice
+	resp, err = doRequest("POST", fmt.Sprintf("/clients/%d/certificates/actions/bind", admin.ID), `{"identity":"uri:spiffe://mesh/ns/certs-bind"}`,
+		"Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+	requireStatusCode(t, resp.StatusCode, 409)
+
+	resp, err = doRequest("DELETE", fmt.Sprintf("/clients/%d/certificates/%d", u.ID, cert.ID), "",
+		"Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+	requireStatusCode(t, resp.StatusCode, 204)
+
+	certs, err = appCtx.ClientRepo.GetCertificates(context.Background(), u.ID)
+	test.RequireErrNil(t, err)
+	if len(certs) != 0 {
+		t.Errorf("expected no certificates, got %v", certs)
+	}
+}
+
+func TestBindCertificate_NotAdmin(t *testing.T) {
+	u, err := requireClient(appCtx, "certs-bind-notadmin", "client")
+	test.RequireErrNil(t, err)
+
+	token, err := requireJWT(appCtx, u)
+	test.RequireErrNil(t, err)
+
+	resp, err := doRequest("POST", fmt.Sprintf("/clients/%d/certificates/actions/bind", u.ID), `{"identity":"cn:certs-bind-notadmin"}`,
+		"Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+
+	requireForbidden(t, resp)
+}
+
+func TestLoginWithCertificate_NoCertificate(t *testing.T) {
+	resp, err := doRequest("POST", "/auth/login/certificate", "")
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+
+	requireUnauthorized(t, resp)
+}
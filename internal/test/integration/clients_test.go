@@ -1,6 +1,7 @@
 package integration
 
 import (
+	"context"
 	"fmt"
 	"kms/internal/test"
 	"strconv"
@@ -14,7 +15,7 @@ func TestUpdateRole(t *testing.T) {
 	u, err := requireClient(appCtx, "client", "client")
 	test.RequireErrNil(t, err)
 
-	token, err := requireJWT(appCtx, admin)
+	token, err := requireMfaJWT(appCtx, admin)
 	test.RequireErrNil(t, err)
 
 	resp, err := doRequest("POST", fmt.Sprintf("/clients/%d/role", u.ID), `{"role":"admin"}`,
@@ -24,7 +25,7 @@ func TestUpdateRole(t *testing.T) {
 
 	requireStatusCode(t, resp.StatusCode, 204)
 
-	updatedU, err := appCtx.ClientRepo.FindByHashedClientname(u.HashedClientname)
+	updatedU, err := appCtx.ClientRepo.FindByHashedClientname(context.Background(), u.HashedClientname)
 	test.RequireErrNil(t, err)
 
 	if updatedU.Role != "admin" {
@@ -60,7 +61,7 @@ func TestUpdateRole_MissingBody(t *testing.T) {
 	u, err := requireClient(appCtx, "clients-updaterole-missingbody-client", "client")
 	test.RequireErrNil(t, err)
 
-	token, err := requireJWT(appCtx, admin)
+	token, err := requireMfaJWT(appCtx, admin)
 	test.RequireErrNil(t, err)
 
 	resp, err := doRequest("POST", fmt.Sprintf("/clients/%d/role", u.ID), "",
@@ -78,7 +79,7 @@ func TestUpdateRole_InvalidRole(t *testing.T) {
 	u, err := requireClient(appCtx, "clients-updaterole-emptyrole-client", "client")
 	test.RequireErrNil(t, err)
 
-	token, err := requireJWT(appCtx, admin)
+	token, err := requireMfaJWT(appCtx, admin)
 	test.RequireErrNil(t, err)
 
 	resp, err := doRequest("POST", fmt.Sprintf("/clients/%d/role", u.ID), `{"role":""}`,
@@ -89,6 +90,25 @@ func TestUpdateRole_InvalidRole(t *testing.T) {
 	requireBadRequest(t, resp)
 }
 
+func TestUpdateRole_WithoutMfa(t *testing.T) {
+	admin, err := requireClient(appCtx, "clients-updaterole-withoutmfa-admin", "admin")
+	test.RequireErrNil(t, err)
+
+	u, err := requireClient(appCtx, "clients-updaterole-withoutmfa-client", "client")
+	test.RequireErrNil(t, err)
+
+	token, err := requireJWT(appCtx, admin)
+	test.RequireErrNil(t, err)
+
+	resp, err := doRequest("POST", fmt.Sprintf("/clients/%d/role", u.ID), `{"role":"admin"}`,
+		"Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+
+	requireStatusCode(t, resp.StatusCode, 403)
+	test.RequireContains(t, GetBody(resp), "Recent MFA verification required")
+}
+
 func TestUpdateRole_NotAdmin(t *testing.T) {
 	u, err := requireClient(appCtx, "clients-updaterole-notadmin-client", "client")
 	test.RequireErrNil(t, err)
@@ -154,7 +174,7 @@ func TestDeleteClient(t *testing.T) {
 	u, err := requireClient(appCtx, "clients-deleteclient-client", "client")
 	test.RequireErrNil(t, err)
 
-	token, err := requireJWT(appCtx, a)
+	token, err := requireMfaJWT(appCtx, a)
 	test.RequireErrNil(t, err)
 
 	resp, err := doRequest("DELETE", "/clients/"+strconv.Itoa(u.ID), "",
@@ -162,11 +182,30 @@ func TestDeleteClient(t *testing.T) {
 	requireReqNotFailed(t, err)
 	defer resp.Body.Close()
 
-	_, err = appCtx.ClientRepo.FindByHashedClientname(u.HashedClientname)
+	_, err = appCtx.ClientRepo.FindByHashedClientname(context.Background(), u.HashedClientname)
 	test.RequireErrNotNil(t, err)
 	test.RequireContains(t, err.Error(), "no rows")
 }
 
+func TestDeleteClient_WithoutMfa(t *testing.T) {
+	a, err := requireClient(appCtx, "clients-deleteclient-withoutmfa", "admin")
+	test.RequireErrNil(t, err)
+
+	u, err := requireClient(appCtx, "clients-deleteclient-withoutmfa-client", "client")
+	test.RequireErrNil(t, err)
+
+	token, err := requireJWT(appCtx, a)
+	test.RequireErrNil(t, err)
+
+	resp, err := doRequest("DELETE", "/clients/"+strconv.Itoa(u.ID), "",
+		"Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+
+	requireStatusCode(t, resp.StatusCode, 403)
+	test.RequireContains(t, GetBody(resp), "Recent MFA verification required")
+}
+
 func TestDeleteClient_NotAdmin(t *testing.T) {
 	u, err := requireClient(appCtx, "clients-deleteclient-client", "client")
 	test.RequireErrNil(t, err)
@@ -192,6 +231,6 @@ func TestDeleteClient_MissingToken(t *testing.T) {
 
 	requireUnauthorized(t, resp)
 
-	_, err = appCtx.ClientRepo.FindByHashedClientname(u.HashedClientname)
+	_, err = appCtx.ClientRepo.FindByHashedClientname(context.Background(), u.HashedClientname)
 	test.RequireErrNil(t, err)
 }
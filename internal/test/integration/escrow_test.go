@@ -0,0 +1,77 @@
+package integration
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"kms/internal/escrow"
+	"kms/internal/test"
+	"kms/pkg/encryption"
+	"testing"
+)
+
+func TestEscrowExportImport(t *testing.T) {
+	a, err := requireClient(appCtx, "escrow-export", "admin")
+	test.RequireErrNil(t, err)
+	u, err := requireClient(appCtx, "escrow-export-client", "client")
+	test.RequireErrNil(t, err)
+	target, err := requireClient(appCtx, "escrow-import-client", "client")
+	test.RequireErrNil(t, err)
+
+	key, err := requireKey(appCtx, u.ID, "escrow-key", 1, "in-use")
+	test.RequireErrNil(t, err)
+
+	token, err := requireJWT(appCtx, a)
+	test.RequireErrNil(t, err)
+
+	operatorKey, err := encryption.GenerateWrappingKey(encryption.WrapAlgECDHP256)
+	test.RequireErrNil(t, err)
+	pub, err := operatorKey.PublicKeyDER()
+	test.RequireErrNil(t, err)
+
+	body := fmt.Sprintf(`{"clientId":%d,"keyReferences":["escrow-key"],"algorithm":"%s","publicKey":"%s"}`,
+		u.ID, encryption.WrapAlgECDHP256, base64.RawURLEncoding.EncodeToString(pub))
+	resp, err := doRequest("POST", "/admin/escrow/export", body,
+		"Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+
+	requireStatusCode(t, resp.StatusCode, 200)
+
+	var file escrow.File
+	err = json.NewDecoder(resp.Body).Decode(&file)
+	test.RequireErrNil(t, err)
+	test.RequireErrNil(t, file.Verify())
+
+	service := escrow.NewService(appCtx.KeyRepo, appCtx.ClientRepo, appCtx.KeyManager, appCtx.Logger)
+	count, appErr := service.Import(context.Background(), &file, operatorKey, target.ID)
+	if appErr != nil {
+		t.Fatalf("expected no error, got %v", appErr)
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 imported key, got %d", count)
+	}
+
+	imported, err := appCtx.KeyRepo.GetKey(context.Background(), target.ID, key.KeyReference, 1)
+	test.RequireErrNil(t, err)
+	if imported.DEK != key.DEK {
+		t.Errorf("expected imported DEK to match exported DEK")
+	}
+}
+
+func TestEscrowExport_NotAdmin(t *testing.T) {
+	u, err := requireClient(appCtx, "escrow-export-notadmin", "client")
+	test.RequireErrNil(t, err)
+
+	token, err := requireJWT(appCtx, u)
+	test.RequireErrNil(t, err)
+
+	body := fmt.Sprintf(`{"clientId":%d,"algorithm":"ECDH-P256","publicKey":"AQID"}`, u.ID)
+	resp, err := doRequest("POST", "/admin/escrow/export", body,
+		"Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+
+	requireForbidden(t, resp)
+}
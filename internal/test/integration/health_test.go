@@ -0,0 +1,25 @@
+package integration
+
+import (
+	"encoding/json"
+	"kms/internal/health"
+	"net/http"
+	"testing"
+)
+
+func TestReadyz(t *testing.T) {
+	resp, err := http.Get(server.URL + "/readyz")
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+	requireStatusCode(t, resp.StatusCode, http.StatusOK)
+
+	var status health.StatusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	for _, check := range []string{"database", "migrations", "keyManager", "kek"} {
+		if status.Checks[check] != "ok" {
+			t.Errorf("expected check %s to pass, got %v", check, status.Checks)
+		}
+	}
+}
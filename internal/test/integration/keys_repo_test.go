@@ -1,6 +1,7 @@
 package integration
 
 import (
+	"context"
 	"kms/internal/keys"
 	"testing"
 )
@@ -33,7 +34,7 @@ func TestCreateKey_UniqueConstraint(t *testing.T) {
 				State:        "active",
 				Encoding:     "base64",
 			}
-			_, err := appCtx.KeyRepo.CreateKey(key)
+			_, err := appCtx.KeyRepo.CreateKey(context.Background(), key)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CreateKey() error = %v, wantErr %v", err, tt.wantErr)
 			}
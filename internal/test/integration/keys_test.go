@@ -1,10 +1,15 @@
 package integration
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"kms/internal/bootstrap"
 	"kms/internal/keys"
 	"kms/internal/test"
+	"kms/pkg/encryption"
+	kmsErrors "kms/pkg/errors"
 	"kms/pkg/hashing"
 	"strconv"
 	"testing"
@@ -36,7 +41,7 @@ func TestGenerateKey(t *testing.T) {
 	keyRefHash := hashing.HashHS256ToB64([]byte(keyRef), keyRefHashKey)
 
 	// check if key was created
-	key, err := appCtx.KeyRepo.GetKey(u.ID, keyRefHash, 1)
+	key, err := appCtx.KeyRepo.GetKey(context.Background(), u.ID, keyRefHash, 1)
 	test.RequireErrNil(t, err)
 
 	// check if key ref hash is correct
@@ -49,6 +54,59 @@ func TestGenerateKey(t *testing.T) {
 	}
 }
 
+func TestGenerateKey_Algorithm(t *testing.T) {
+	u, err := requireClient(appCtx, "keys-generatekey-algorithm", "client")
+	test.RequireErrNil(t, err)
+
+	token, err := requireJWT(appCtx, u)
+	test.RequireErrNil(t, err)
+
+	keyRef := "hmac-key"
+	resp, err := doRequest("POST", "/keys/actions/generate", fmt.Sprintf(`{"keyReference":"%s","algorithm":"%s"}`, keyRef, encryption.AlgHMACSHA512),
+		"Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+
+	requireStatusCode(t, resp.StatusCode, 200)
+
+	var key keys.KeyResponse
+	err = json.NewDecoder(resp.Body).Decode(&key)
+	test.RequireErrNil(t, err)
+	if key.Algorithm != encryption.AlgHMACSHA512 {
+		t.Errorf("expected algorithm %s, got %s", encryption.AlgHMACSHA512, key.Algorithm)
+	}
+	DEKBytes, err := base64.RawURLEncoding.DecodeString(key.DEK)
+	test.RequireErrNil(t, err)
+	if len(DEKBytes) != 64 {
+		t.Errorf("expected 64 byte key, got %d", len(DEKBytes))
+	}
+
+	// rotation keeps the algorithm
+	resp, err = doRequest("POST", "/keys/"+keyRef+"/actions/rotate", "",
+		"Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+
+	requireStatusCode(t, resp.StatusCode, 200)
+	test.RequireContains(t, GetBody(resp), `"algorithm":"`+encryption.AlgHMACSHA512+`"`)
+}
+
+func TestGenerateKey_UnsupportedAlgorithm(t *testing.T) {
+	u, err := requireClient(appCtx, "keys-generatekey-unsupported", "client")
+	test.RequireErrNil(t, err)
+
+	token, err := requireJWT(appCtx, u)
+	test.RequireErrNil(t, err)
+
+	resp, err := doRequest("POST", "/keys/actions/generate", `{"keyReference":"des-key","algorithm":"DES"}`,
+		"Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+
+	requireStatusCode(t, resp.StatusCode, 400)
+	test.RequireContains(t, GetBody(resp), "Unsupported algorithm")
+}
+
 func TestGenerateKey_MissingToken(t *testing.T) {
 	_, err := requireClient(appCtx, "keys-generatekey-missingtoken", "client")
 	test.RequireErrNil(t, err)
@@ -182,6 +240,36 @@ func TestGetKey_Deprecated(t *testing.T) {
 	}
 }
 
+func TestGetKey_Latest(t *testing.T) {
+	u, err := requireClient(appCtx, "keys-getkey-latest", "client")
+	test.RequireErrNil(t, err)
+
+	keyRef := "db-key"
+	_, err = requireKey(appCtx, u.ID, keyRef, 1, keys.StateDeprecated)
+	test.RequireErrNil(t, err)
+	latestKey, err := requireKey(appCtx, u.ID, keyRef, 2, keys.StateInUse)
+	test.RequireErrNil(t, err)
+
+	token, err := requireJWT(appCtx, u)
+	test.RequireErrNil(t, err)
+
+	resp, err := doRequest("GET", "/keys/"+keyRef+"/latest", "",
+		"Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+
+	requireStatusCode(t, resp.StatusCode, 200)
+	requireHeader(t, &resp.Header, "X-Key-Deprecated", "false")
+
+	var body keys.KeyLookupResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if body.DecryptWith == nil || body.DecryptWith.Version != latestKey.Version || body.DecryptWith.DEK != latestKey.DEK {
+		t.Errorf("expected version %d, got %v", latestKey.Version, body.DecryptWith)
+	}
+}
+
 func TestGetKey_MissingToken(t *testing.T) {
 	u, err := requireClient(appCtx, "keys-getkey-missingtoken", "client")
 	test.RequireErrNil(t, err)
@@ -210,7 +298,11 @@ func TestGetKey_NotFound(t *testing.T) {
 	defer resp.Body.Close()
 
 	requireStatusCode(t, resp.StatusCode, 404)
-	test.RequireContains(t, GetBody(resp), "Entity not found")
+	requireHeader(t, &resp.Header, "Content-Type", kmsErrors.ProblemContentType)
+	body := GetBody(resp)
+	test.RequireContains(t, body, `"detail":"Entity not found"`)
+	test.RequireContains(t, body, `"code":"KEY_NOT_FOUND"`)
+	test.RequireContains(t, body, `"requestId":"`+resp.Header.Get("X-Request-ID")+`"`)
 }
 
 func TestGetKey_InvalidKeyReference(t *testing.T) {
@@ -260,7 +352,7 @@ func TestRotateKey(t *testing.T) {
 		t.Errorf("expected DEKs to be different, got %s == %s", key.DEK, body.DEK)
 	}
 
-	stored, err := appCtx.KeyRepo.GetLatestKey(key.ClientId, key.KeyReference)
+	stored, err := appCtx.KeyRepo.GetLatestKey(context.Background(), key.ClientId, key.KeyReference)
 	test.RequireErrNil(t, err)
 
 	// check if fields that should stay the same, stayed the same
@@ -268,7 +360,7 @@ func TestRotateKey(t *testing.T) {
 		t.Errorf("expected clientId = %d and keyRef = %s, got %d and %s", key.ClientId, key.KeyReference, stored.ClientId, stored.KeyReference)
 	}
 
-	original, err := appCtx.KeyRepo.GetKey(key.ClientId, key.KeyReference, 1)
+	original, err := appCtx.KeyRepo.GetKey(context.Background(), key.ClientId, key.KeyReference, 1)
 	test.RequireErrNil(t, err)
 
 	// check if original's state has been set to 'deprecated'
@@ -307,7 +399,7 @@ func TestDeleteKey(t *testing.T) {
 	test.RequireErrNil(t, err)
 
 	keyRef := "db-key"
-	_, err = requireKey(appCtx, u.ID, keyRef, 1, keys.StateDeprecated)
+	key, err := requireKey(appCtx, u.ID, keyRef, 1, keys.StateDeprecated)
 	test.RequireErrNil(t, err)
 	_, err = requireKey(appCtx, u.ID, keyRef, 2, keys.StateInUse)
 	test.RequireErrNil(t, err)
@@ -320,16 +412,37 @@ func TestDeleteKey(t *testing.T) {
 	requireReqNotFailed(t, err)
 	defer resp.Body.Close()
 
-	requireStatusCode(t, resp.StatusCode, 204)
+	requireStatusCode(t, resp.StatusCode, 200)
 
-	// check if all key versions are deleted
-	_, err = appCtx.KeyRepo.GetKey(u.ID, keyRef, 1)
-	test.RequireErrNotNil(t, err)
-	test.RequireContains(t, err.Error(), "no rows")
+	var deletion keys.KeyDeletionResponse
+	err = json.NewDecoder(resp.Body).Decode(&deletion)
+	test.RequireErrNil(t, err)
+	if deletion.DeleteAfter.Before(time.Now().Add(bootstrap.MinKeyDeletionWindowDays * 24 * time.Hour)) {
+		t.Errorf("expected deletion to be scheduled at least %d days ahead, got %v", bootstrap.MinKeyDeletionWindowDays, deletion.DeleteAfter)
+	}
 
-	_, err = appCtx.KeyRepo.GetKey(u.ID, keyRef, 2)
-	test.RequireErrNotNil(t, err)
-	test.RequireContains(t, err.Error(), "no rows")
+	// check if all key versions are scheduled for deletion
+	for _, v := range []int{1, 2} {
+		k, err := appCtx.KeyRepo.GetKey(context.Background(), u.ID, key.KeyReference, v)
+		test.RequireErrNil(t, err)
+		if !k.IsPendingDeletion() {
+			t.Errorf("expected version %d to be pending deletion", v)
+		}
+	}
+
+	// restore cancels the deletion
+	resp, err = doRequest("POST", "/keys/"+keyRef+"/actions/restore", "",
+		"Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+
+	requireStatusCode(t, resp.StatusCode, 204)
+
+	k, err := appCtx.KeyRepo.GetLatestKey(context.Background(), u.ID, key.KeyReference)
+	test.RequireErrNil(t, err)
+	if k.IsPendingDeletion() {
+		t.Error("expected key to be restored")
+	}
 }
 
 func TestDeleteKey_MissingToken(t *testing.T) {
@@ -347,7 +460,7 @@ func TestDeleteKey_MissingToken(t *testing.T) {
 	requireUnauthorized(t, resp)
 
 	// check if key wasn't deleted regardless
-	_, err = appCtx.KeyRepo.GetKey(u.ID, key.KeyReference, 1)
+	_, err = appCtx.KeyRepo.GetKey(context.Background(), u.ID, key.KeyReference, 1)
 	test.RequireErrNil(t, err)
 }
 
@@ -366,3 +479,127 @@ func TestDeleteKey_InvalidReference(t *testing.T) {
 	requireStatusCode(t, resp.StatusCode, 400)
 	test.RequireContains(t, GetBody(resp), "Invalid key reference")
 }
+
+func TestDestroyKeyVersion(t *testing.T) {
+	u, err := requireClient(appCtx, "keys-destroyversion", "client")
+	test.RequireErrNil(t, err)
+
+	keyRef := "db-key"
+	key, err := requireKey(appCtx, u.ID, keyRef, 1, keys.StateDeprecated)
+	test.RequireErrNil(t, err)
+	_, err = requireKey(appCtx, u.ID, keyRef, 2, keys.StateInUse)
+	test.RequireErrNil(t, err)
+
+	token, err := requireJWT(appCtx, u)
+	test.RequireErrNil(t, err)
+
+	// latest version can't be destroyed
+	resp, err := doRequest("DELETE", "/keys/"+keyRef+"/2/actions/destroy", "",
+		"Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+
+	requireStatusCode(t, resp.StatusCode, 409)
+
+	resp, err = doRequest("DELETE", "/keys/"+keyRef+"/1/actions/destroy", "",
+		"Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+
+	requireStatusCode(t, resp.StatusCode, 204)
+
+	_, err = appCtx.KeyRepo.GetKey(context.Background(), u.ID, key.KeyReference, 1)
+	test.RequireErrNotNil(t, err)
+	test.RequireContains(t, err.Error(), "no rows")
+
+	_, err = appCtx.KeyRepo.GetKey(context.Background(), u.ID, key.KeyReference, 2)
+	test.RequireErrNil(t, err)
+}
+
+func TestPruneKeyVersions(t *testing.T) {
+	u, err := requireClient(appCtx, "keys-prune", "client")
+	test.RequireErrNil(t, err)
+
+	keyRef := "db-key"
+	key, err := requireKey(appCtx, u.ID, keyRef, 1, keys.StateDeprecated)
+	test.RequireErrNil(t, err)
+	_, err = requireKey(appCtx, u.ID, keyRef, 2, keys.StateDeprecated)
+	test.RequireErrNil(t, err)
+	_, err = requireKey(appCtx, u.ID, keyRef, 3, keys.StateInUse)
+	test.RequireErrNil(t, err)
+
+	token, err := requireJWT(appCtx, u)
+	test.RequireErrNil(t, err)
+
+	resp, err := doRequest("POST", "/keys/"+keyRef+"/actions/prune", `{"olderThanVersion":100}`,
+		"Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+
+	requireStatusCode(t, resp.StatusCode, 200)
+
+	var pruned keys.PruneKeyVersionsResponse
+	err = json.NewDecoder(resp.Body).Decode(&pruned)
+	test.RequireErrNil(t, err)
+	if fmt.Sprint(pruned.DestroyedVersions) != "[1 2]" {
+		t.Errorf("expected versions [1 2] to be destroyed, got %v", pruned.DestroyedVersions)
+	}
+
+	versions, err := appCtx.KeyRepo.GetVersions(context.Background(), u.ID, key.KeyReference)
+	test.RequireErrNil(t, err)
+	if len(versions) != 1 || versions[0].Version != 3 {
+		t.Errorf("expected only version 3 to remain, got %v", versions)
+	}
+}
+
+func TestImportKey(t *testing.T) {
+	u, err := requireClient(appCtx, "keys-import", "client")
+	test.RequireErrNil(t, err)
+
+	token, err := requireJWT(appCtx, u)
+	test.RequireErrNil(t, err)
+
+	resp, err := doRequest("POST", "/keys/actions/import/wrapping-key", `{"algorithm":"ECDH-P256"}`,
+		"Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+
+	requireStatusCode(t, resp.StatusCode, 200)
+
+	var wrappingKey keys.WrappingKeyResponse
+	err = json.NewDecoder(resp.Body).Decode(&wrappingKey)
+	test.RequireErrNil(t, err)
+
+	dek, err := encryption.GenerateKey(32)
+	test.RequireErrNil(t, err)
+	pub, err := base64.RawURLEncoding.DecodeString(wrappingKey.PublicKey)
+	test.RequireErrNil(t, err)
+	wrapped, err := encryption.WrapKey(wrappingKey.Algorithm, pub, dek)
+	test.RequireErrNil(t, err)
+
+	keyRef := "imported-key"
+	body := fmt.Sprintf(`{"keyReference":"%s","importToken":"%s","wrappedKey":"%s"}`,
+		keyRef, wrappingKey.ImportToken, base64.RawURLEncoding.EncodeToString(wrapped))
+	resp, err = doRequest("POST", "/keys/actions/import", body,
+		"Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+
+	requireStatusCode(t, resp.StatusCode, 200)
+
+	var key keys.KeyResponse
+	err = json.NewDecoder(resp.Body).Decode(&key)
+	test.RequireErrNil(t, err)
+	if key.Version != 1 || key.DEK != base64.RawURLEncoding.EncodeToString(dek) {
+		t.Errorf("expected imported key as version 1, got %v", key)
+	}
+
+	// import token can only be used once
+	resp, err = doRequest("POST", "/keys/actions/import", body,
+		"Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+
+	requireStatusCode(t, resp.StatusCode, 400)
+	test.RequireContains(t, GetBody(resp), "Invalid or expired import token")
+}
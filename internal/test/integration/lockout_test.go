@@ -0,0 +1,40 @@
+package integration
+
+import (
+	"fmt"
+	"kms/internal/test"
+	"testing"
+)
+
+func TestLogin_LockoutAndUnlock(t *testing.T) {
+	admin, err := requireClient(appCtx, "lockout-admin", "admin")
+	test.RequireErrNil(t, err)
+	u, err := requireClient(appCtx, "lockout-client", "client")
+	test.RequireErrNil(t, err)
+
+	// the second failure backs off further attempts
+	for i := 0; i < 2; i++ {
+		resp, err := doRequest("POST", "/auth/login", `{"clientname":"lockout-client","password":"wrong"}`)
+		requireReqNotFailed(t, err)
+		defer resp.Body.Close()
+		requireStatusCode(t, resp.StatusCode, 401)
+	}
+
+	resp, err := doRequest("POST", "/auth/login", `{"clientname":"lockout-client","password":"password"}`)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+	requireStatusCode(t, resp.StatusCode, 429)
+
+	token, err := requireJWT(appCtx, admin)
+	test.RequireErrNil(t, err)
+
+	resp, err = doRequest("POST", fmt.Sprintf("/clients/%d/actions/unlock", u.ID), "", "Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+	requireStatusCode(t, resp.StatusCode, 204)
+
+	resp, err = doRequest("POST", "/auth/login", `{"clientname":"lockout-client","password":"password"}`)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+	requireStatusCode(t, resp.StatusCode, 200)
+}
@@ -0,0 +1,83 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"kms/internal/api/dto"
+	"kms/internal/test"
+	"kms/pkg/hashing"
+	"testing"
+)
+
+func TestChangePassword(t *testing.T) {
+	u, err := requireClient(appCtx, "password-change", "client")
+	test.RequireErrNil(t, err)
+
+	token, err := requireJWT(appCtx, u)
+	test.RequireErrNil(t, err)
+
+	resp, err := doRequest("POST", "/auth/password", `{"currentPassword":"wrong","newPassword":"Changed123!1234"}`, "Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+	requireStatusCode(t, resp.StatusCode, 401)
+
+	resp, err = doRequest("POST", "/auth/password", `{"currentPassword":"password","newPassword":"Changed123!1234"}`, "Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+	requireStatusCode(t, resp.StatusCode, 204)
+
+	resp, err = doRequest("POST", "/auth/login", `{"clientname":"password-change","password":"Changed123!1234"}`)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+	requireStatusCode(t, resp.StatusCode, 200)
+}
+
+func TestResetPassword(t *testing.T) {
+	admin, err := requireClient(appCtx, "password-reset-admin", "admin")
+	test.RequireErrNil(t, err)
+	u, err := requireClient(appCtx, "password-reset-client", "client")
+	test.RequireErrNil(t, err)
+
+	token, err := requireMfaJWT(appCtx, admin)
+	test.RequireErrNil(t, err)
+
+	resp, err := doRequest("POST", fmt.Sprintf("/clients/%d/password/actions/reset", u.ID), `{}`, "Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+	requireStatusCode(t, resp.StatusCode, 200)
+
+	var resetToken dto.TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&resetToken); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+
+	body := fmt.Sprintf(`{"token":"%s","password":"Reset123!12345"}`, resetToken.Token)
+	resp, err = doRequest("POST", "/auth/password/reset", body)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+	requireStatusCode(t, resp.StatusCode, 200)
+
+	client, err := appCtx.ClientRepo.GetClient(context.Background(), u.ID)
+	test.RequireErrNil(t, err)
+	test.RequireErrNil(t, hashing.CheckPassword(client.Password, "Reset123!12345"))
+
+	// one-time
+	resp, err = doRequest("POST", "/auth/password/reset", body)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+	requireStatusCode(t, resp.StatusCode, 400)
+}
+
+func TestResetPassword_NotAdmin(t *testing.T) {
+	u, err := requireClient(appCtx, "password-reset-notadmin", "client")
+	test.RequireErrNil(t, err)
+
+	token, err := requireJWT(appCtx, u)
+	test.RequireErrNil(t, err)
+
+	resp, err := doRequest("POST", fmt.Sprintf("/clients/%d/password/actions/reset", u.ID), `{}`, "Authorization", "Bearer "+token)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+	requireForbidden(t, resp)
+}
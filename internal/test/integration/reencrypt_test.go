@@ -0,0 +1,63 @@
+package integration
+
+import (
+	"context"
+	"kms/internal/keys"
+	dbEncr "kms/internal/storage/encryption"
+	"kms/internal/storage/postgres"
+	"kms/internal/test"
+	"testing"
+)
+
+func createLegacyKey(t *testing.T, ref string, dek string) {
+	legacyDEK, err := dbEncr.EncryptBase64(dek, appCtx.KeyManager.KEK())
+	test.RequireErrNil(t, err)
+	legacyState, err := dbEncr.EncryptString(keys.StateInUse, appCtx.KeyManager.DBKey())
+	test.RequireErrNil(t, err)
+	legacyEncoding, err := dbEncr.EncryptString("base64url (RFC 4648)", appCtx.KeyManager.DBKey())
+	test.RequireErrNil(t, err)
+
+	_, err = postgres.NewPostgresKeyRepo(appCtx.DB).CreateKey(context.Background(), &keys.Key{
+		ClientId:     1,
+		KeyReference: ref,
+		Version:      1,
+		DEK:          legacyDEK,
+		State:        legacyState,
+		Encoding:     legacyEncoding,
+	})
+	test.RequireErrNil(t, err)
+}
+
+// A key written before ciphertexts were bound to their row is re-encrypted at the first startup, later startups skip it
+func TestInitSchema_ReencryptsLegacyRows(t *testing.T) {
+	dek := "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8"
+	createLegacyKey(t, "legacy-ref", dek)
+
+	if _, err := appCtx.KeyRepo.GetKey(context.Background(), 1, "legacy-ref", 1); err == nil {
+		t.Fatal("expected the legacy row to fail to decrypt before the migration")
+	}
+
+	// the setup already ran it, start over as if upgrading
+	_, err := appCtx.DB.Exec("DELETE FROM data_migrations WHERE name = 'encryption_context'")
+	test.RequireErrNil(t, err)
+
+	// never clear the tables here
+	cfg := *appCtx.Cfg
+	cfg.ClearDB = false
+	test.RequireErrNil(t, postgres.InitSchema(&cfg, appCtx.DB, appCtx.KeyManager, appCtx.MigrationsPath))
+
+	key, err := appCtx.KeyRepo.GetKey(context.Background(), 1, "legacy-ref", 1)
+	test.RequireErrNil(t, err)
+	if key.DEK != dek || key.State != keys.StateInUse {
+		t.Errorf("unexpected key after re-encryption: %+v", key)
+	}
+
+	createLegacyKey(t, "legacy-ref-2", dek)
+	t.Cleanup(func() {
+		appCtx.DB.Exec("DELETE FROM keys WHERE keyReference = 'legacy-ref-2'")
+	})
+	test.RequireErrNil(t, postgres.InitSchema(&cfg, appCtx.DB, appCtx.KeyManager, appCtx.MigrationsPath))
+	if _, err := appCtx.KeyRepo.GetKey(context.Background(), 1, "legacy-ref-2", 1); err == nil {
+		t.Fatal("expected the migration to be skipped once it has run")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"kms/internal/api"
 	"kms/internal/bootstrap"
+	"kms/internal/metrics"
 	dbEncr "kms/internal/storage/encryption"
 	"kms/internal/storage/postgres"
 	"kms/internal/test"
@@ -11,6 +12,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"slices"
+	"strings"
 	"testing"
 )
 
@@ -26,19 +28,19 @@ func TestMain(m *testing.M) {
 
 	http.DefaultServeMux = http.NewServeMux()
 
-	cfg, err := bootstrap.LoadConfig("../../../.env")
+	cfg, err := bootstrap.LoadKmsConfig("../../../.env")
 	if err != nil {
 		panic(err)
 	}
 	// set log level to debug for tests always
-	cfg["LOG_LEVEL"] = "debug"
+	cfg.Log.Level = "debug"
 
 	keyManager, err := bootstrap.InitStaticKeyManager(cfg)
 	if err != nil {
 		panic(err)
 	}
 
-	consoleLogger, err := bootstrap.InitConsoleLogger(cfg["LOG_LEVEL"])
+	logger, err := bootstrap.InitLogger(cfg)
 	if err != nil {
 		panic(err)
 	}
@@ -49,7 +51,8 @@ func TestMain(m *testing.M) {
 	}
 	defer db.Close()
 
-	if err := postgres.InitSchema(cfg, db, keyManager, "test_migrations"); err != nil {
+	migrationsPath := "test_migrations"
+	if err := postgres.InitSchema(cfg, db, keyManager, migrationsPath); err != nil {
 		panic(err)
 	}
 
@@ -59,13 +62,14 @@ func TestMain(m *testing.M) {
 
 	// TODO: Add startup time to dismiss old JWTs
 	appCtx = &bootstrap.AppContext{
-		Cfg:        cfg,
-		KeyManager: keyManager,
-		Logger:     consoleLogger,
-		DB:         db,
-		ClientRepo: clientRepo,
-		KeyRepo:    keyRepo,
-		AdminRepo:  adminRepo,
+		Cfg:            cfg,
+		KeyManager:     keyManager,
+		Logger:         logger,
+		DB:             db,
+		MigrationsPath: migrationsPath,
+		ClientRepo:     clientRepo,
+		KeyRepo:        keyRepo,
+		AdminRepo:      adminRepo,
 	}
 
 	if err := api.RegisterRoutes(appCtx); err != nil {
@@ -81,7 +85,7 @@ func TestMain(m *testing.M) {
 }
 
 func TestMethodNotAllowed(t *testing.T) {
-	methods := []string{"CONNECT", "GET", "POST", "PATCH", "PUT", "OPTIONS", "DELETE", "HEAD", "TRACE"}
+	methods := []string{"CONNECT", "GET", "POST", "PATCH", "PUT", "DELETE", "HEAD", "TRACE"}
 
 	tests := []struct {
 		path           string
@@ -99,6 +103,9 @@ func TestMethodNotAllowed(t *testing.T) {
 	}
 
 	for _, tt := range tests {
+		allow := append(slices.Clone(tt.allowedMethods), "OPTIONS")
+		slices.Sort(allow)
+
 		for _, m := range methods {
 			if slices.Contains(tt.allowedMethods, m) {
 				continue
@@ -111,6 +118,76 @@ func TestMethodNotAllowed(t *testing.T) {
 			if m != "HEAD" {
 				test.RequireContains(t, GetBody(resp), "Method not allowed")
 			}
+			if got := resp.Header.Get("Allow"); got != strings.Join(allow, ", ") {
+				t.Errorf("%s %s: unexpected Allow header %q", m, tt.path, got)
+			}
 		}
+
+		resp, err := doRequest("OPTIONS", tt.path, "")
+		requireReqNotFailed(t, err)
+		defer resp.Body.Close()
+
+		requireStatusCode(t, resp.StatusCode, 204)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	for _, path := range []string{"/", "/unknown", "/keys/keyRef/abc", "/clients/abc/role"} {
+		resp, err := doRequest("POST", path, "")
+		requireReqNotFailed(t, err)
+		defer resp.Body.Close()
+
+		requireStatusCode(t, resp.StatusCode, 404)
+	}
+}
+
+func TestVersionedPaths(t *testing.T) {
+	_, err := requireClient(appCtx, "setup-versioned-client", "client")
+	test.RequireErrNil(t, err)
+
+	body := `{"clientname":"setup-versioned-client","password":"password"}`
+
+	resp, err := doRequest("POST", "/v1/auth/login", body)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+
+	requireStatusCode(t, resp.StatusCode, 200)
+	if resp.Header.Get("Deprecation") != "" {
+		t.Errorf("expected no Deprecation header, got %q", resp.Header.Get("Deprecation"))
+	}
+
+	resp, err = doRequest("POST", "/auth/login", body)
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+
+	requireStatusCode(t, resp.StatusCode, 200)
+	if resp.Header.Get("Deprecation") == "" || resp.Header.Get("Link") != `</v1/auth/login>; rel="successor-version"` {
+		t.Errorf("expected Deprecation and Link headers, got %v", resp.Header)
 	}
 }
+
+func TestOpenAPI(t *testing.T) {
+	resp, err := doRequest("GET", "/v1/openapi.json", "")
+	requireReqNotFailed(t, err)
+	defer resp.Body.Close()
+
+	requireStatusCode(t, resp.StatusCode, 200)
+	spec := GetBody(resp)
+	test.RequireContains(t, spec, `"openapi":"3.0.3"`)
+	test.RequireContains(t, spec, `"/keys/{keyReference}/{version}"`)
+	test.RequireContains(t, spec, `"KeyLookupResponse"`)
+}
+
+func TestMetrics(t *testing.T) {
+	resp, err := doRequest("GET", "/v1/keys/metrics-key/1", "")
+	requireReqNotFailed(t, err)
+	resp.Body.Close()
+
+	rr := httptest.NewRecorder()
+	metrics.Default.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := rr.Body.String()
+	test.RequireContains(t, body, `kms_http_requests_total{method="GET",route="/v1/keys/{keyReference}/{version:int}",status="401"}`)
+	test.RequireContains(t, body, `kms_http_request_duration_seconds_count{method="GET",route="/v1/keys/{keyReference}/{version:int}"}`)
+	test.RequireContains(t, body, "# TYPE kms_login_failures_total counter")
+}
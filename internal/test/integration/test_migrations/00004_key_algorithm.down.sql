@@ -0,0 +1,2 @@
+ALTER TABLE keys ALTER COLUMN dek TYPE VARCHAR(80);
+ALTER TABLE keys DROP COLUMN IF EXISTS algorithm;
@@ -0,0 +1,8 @@
+-- Client certificates (mTLS) that authenticate as a client, identities are stored hashed like clientnames
+CREATE TABLE IF NOT EXISTS client_certificates (
+    id SERIAL PRIMARY KEY,
+    clientId INTEGER NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+    hashedIdentity VARCHAR(44) UNIQUE NOT NULL,
+    pin VARCHAR(43),
+    createdAt TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
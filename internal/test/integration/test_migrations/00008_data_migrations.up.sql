@@ -0,0 +1,5 @@
+-- One row per data migration that has run, so it isn't run again at the next startup.
+CREATE TABLE IF NOT EXISTS data_migrations (
+    name TEXT PRIMARY KEY,
+    appliedAt TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -2,6 +2,7 @@ package integration
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"errors"
 	"io"
@@ -13,7 +14,6 @@ import (
 	"kms/pkg/encryption"
 	"kms/pkg/hashing"
 	"net/http"
-	"strconv"
 	"testing"
 )
 
@@ -46,9 +46,8 @@ func GetBody(resp *http.Response) string {
 }
 
 func requireJWT(appCtx *bootstrap.AppContext, u *clients.Client) (string, error) {
-	ttl, _ := strconv.ParseInt(appCtx.Cfg["JWT_TTL"], 10, 64)
 	genInfo := &auth.TokenGenInfo{
-		Ttl:    ttl,
+		Ttl:    appCtx.Cfg.JWTTTL.Milliseconds(),
 		Secret: appCtx.KeyManager.JWTKey(),
 		Typ:    "jwt",
 	}
@@ -56,6 +55,17 @@ func requireJWT(appCtx *bootstrap.AppContext, u *clients.Client) (string, error)
 	return auth.GenerateJWT(genInfo, u)
 }
 
+// JWT of a login verified with MFA just now
+func requireMfaJWT(appCtx *bootstrap.AppContext, u *clients.Client) (string, error) {
+	genInfo := &auth.TokenGenInfo{
+		Ttl:    appCtx.Cfg.JWTTTL.Milliseconds(),
+		Secret: appCtx.KeyManager.JWTKey(),
+		Typ:    "jwt",
+	}
+
+	return auth.GenerateMfaJWT(genInfo, u)
+}
+
 func requireSignupToken(appCtx *bootstrap.AppContext, clientname string) (string, error) {
 	genInfo := &auth.TokenGenInfo{
 		Ttl:    3600,
@@ -76,7 +86,7 @@ func requireClient(appCtx *bootstrap.AppContext, clientname, role string) (*clie
 		return nil, err
 	}
 	hashedClient := hashing.HashHS256ToB64([]byte(clientname), clientHashKey)
-	_, err = appCtx.ClientRepo.CreateClient(&clients.Client{
+	_, err = appCtx.ClientRepo.CreateClient(context.Background(), &clients.Client{
 		Clientname:       clientname,
 		HashedClientname: hashedClient,
 		Password:         password,
@@ -86,7 +96,7 @@ func requireClient(appCtx *bootstrap.AppContext, clientname, role string) (*clie
 		return nil, err
 	}
 
-	return appCtx.ClientRepo.FindByHashedClientname(hashedClient)
+	return appCtx.ClientRepo.FindByHashedClientname(context.Background(), hashedClient)
 }
 
 func doRequest(method, path, payload string, headers ...string) (*http.Response, error) {
@@ -119,13 +129,14 @@ func requireKey(appCtx *bootstrap.AppContext, clientID int, keyRef string, v int
 		return nil, err
 	}
 	dek := base64.RawURLEncoding.EncodeToString(dekBytes)
-	return appCtx.KeyRepo.CreateKey(&keys.Key{
+	return appCtx.KeyRepo.CreateKey(context.Background(), &keys.Key{
 		KeyReference: hashing.HashHS256ToB64([]byte(keyRef), keyRefHashKey),
 		DEK:          dek,
 		ClientId:     clientID,
 		Version:      v,
 		State:        s,
 		Encoding:     "encoding",
+		Algorithm:    encryption.AlgAES256GCM,
 	})
 }
 
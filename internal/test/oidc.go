@@ -0,0 +1,96 @@
+package test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	b64 "encoding/base64"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// Local stand-in for an OIDC issuer (e.g. the Kubernetes API server), signs ID tokens with an RSA ("rsa") and an EC ("ec") key
+type FakeIssuer struct {
+	Issuer string
+	RSAKey *rsa.PrivateKey
+	ECKey  *ecdsa.PrivateKey
+}
+
+func NewFakeIssuer(t *testing.T, issuer string) *FakeIssuer {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	RequireErrNil(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	RequireErrNil(t, err)
+	return &FakeIssuer{Issuer: issuer, RSAKey: rsaKey, ECKey: ecKey}
+}
+
+func (f *FakeIssuer) JWKS(t *testing.T) []byte {
+	ecPoint, err := f.ECKey.PublicKey.ECDH()
+	RequireErrNil(t, err)
+	xy := ecPoint.Bytes()[1:]
+
+	jwks, err := json.Marshal(map[string]any{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": "rsa",
+				"use": "sig",
+				"n":   b64.RawURLEncoding.EncodeToString(f.RSAKey.N.Bytes()),
+				"e":   b64.RawURLEncoding.EncodeToString(big.NewInt(int64(f.RSAKey.E)).Bytes()),
+			},
+			{
+				"kty": "EC",
+				"kid": "ec",
+				"crv": "P-256",
+				"x":   b64.RawURLEncoding.EncodeToString(xy[:32]),
+				"y":   b64.RawURLEncoding.EncodeToString(xy[32:]),
+			},
+		},
+	})
+	RequireErrNil(t, err)
+	return jwks
+}
+
+// ID token for the audience, valid for an hour. extra is merged into (and overrides) the default claims.
+func (f *FakeIssuer) Sign(t *testing.T, kid, sub, audience string, extra map[string]any) string {
+	now := time.Now()
+	claims := map[string]any{
+		"iss": f.Issuer,
+		"sub": sub,
+		"aud": audience,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
+
+	alg := "RS256"
+	if kid == "ec" {
+		alg = "ES256"
+	}
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	RequireErrNil(t, err)
+	payload, err := json.Marshal(claims)
+	RequireErrNil(t, err)
+
+	signingInput := b64.RawURLEncoding.EncodeToString(header) + "." + b64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	if kid == "ec" {
+		r, s, err := ecdsa.Sign(rand.Reader, f.ECKey, digest[:])
+		RequireErrNil(t, err)
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	} else {
+		signature, err = rsa.SignPKCS1v15(rand.Reader, f.RSAKey, crypto.SHA256, digest[:])
+		RequireErrNil(t, err)
+	}
+
+	return signingInput + "." + b64.RawURLEncoding.EncodeToString(signature)
+}
@@ -1,8 +1,14 @@
 package cli
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	kmsErrors "kms/pkg/errors"
+	"net/http"
 	"os"
+	"strings"
 )
 
 func HandleUnexpectedError(err error) {
@@ -18,3 +24,25 @@ func HandleFailedRequest(err error) {
 		os.Exit(1)
 	}
 }
+
+// Prints the problem of an error response and exits
+func HandleErrorResponse(resp *http.Response) {
+	fmt.Fprintf(os.Stderr, "server error: %v\n", ReadProblem(resp))
+	os.Exit(1)
+}
+
+// Decodes an application/problem+json body, anything else (e.g. from a proxy) ends up in Detail
+func ReadProblem(resp *http.Response) *kmsErrors.Problem {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	problem := &kmsErrors.Problem{}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), kmsErrors.ProblemContentType) && json.Unmarshal(body, problem) == nil {
+		return problem
+	}
+
+	problem.Status = resp.StatusCode
+	problem.Detail = string(bytes.TrimSpace(body))
+	if problem.Detail == "" {
+		problem.Detail = resp.Status // fallback if no body
+	}
+	return problem
+}
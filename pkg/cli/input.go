@@ -54,3 +54,16 @@ func RequirePasswordTwice() (string, error) {
 
 	return password, nil
 }
+
+func RequireMfaCode() (string, error) {
+	fmt.Println("Enter MFA code (or a recovery code):")
+	var code string
+	_, err := fmt.Scanln(&code)
+	if err != nil {
+		return "", fmt.Errorf("error reading MFA code: %w", err)
+	}
+	if code == "" {
+		return "", fmt.Errorf("MFA code cannot be empty")
+	}
+	return code, nil
+}
@@ -0,0 +1,159 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Algorithms a DEK can be generated for, stored with the key so consumers know how to use it
+const (
+	AlgAES128GCM         = "AES-128-GCM"
+	AlgAES256GCM         = "AES-256-GCM"
+	AlgChaCha20Poly1305  = "ChaCha20-Poly1305"
+	AlgXChaCha20Poly1305 = "XChaCha20-Poly1305"
+	AlgAES256SIV         = "AES-256-SIV"
+	AlgHMACSHA512        = "HMAC-SHA512"
+
+	DefaultAlgorithm = AlgAES256GCM
+)
+
+const (
+	aes128KeySize     = 16
+	aes256KeySize     = 32
+	aes256SIVKeySize  = 64
+	hmacSHA512KeySize = 64
+)
+
+var Algorithms = []string{AlgAES128GCM, AlgAES256GCM, AlgChaCha20Poly1305, AlgXChaCha20Poly1305, AlgAES256SIV, AlgHMACSHA512}
+
+// Key length in bytes for the algorithm
+func KeySize(alg string) (int, error) {
+	switch alg {
+	case AlgAES128GCM:
+		return aes128KeySize, nil
+	case AlgAES256GCM:
+		return aes256KeySize, nil
+	case AlgChaCha20Poly1305, AlgXChaCha20Poly1305:
+		return chacha20poly1305.KeySize, nil
+	case AlgAES256SIV:
+		return aes256SIVKeySize, nil
+	case AlgHMACSHA512:
+		return hmacSHA512KeySize, nil
+	default:
+		return 0, fmt.Errorf("unsupported algorithm: %v", alg)
+	}
+}
+
+func ValidateKey(alg string, key []byte) error {
+	size, err := KeySize(alg)
+	if err != nil {
+		return err
+	}
+	if len(key) != size {
+		return fmt.Errorf("%s key must be %d bytes, is %d", alg, size, len(key))
+	}
+	return nil
+}
+
+func NewAEAD(alg string, key []byte) (cipher.AEAD, error) {
+	if err := ValidateKey(alg, key); err != nil {
+		return nil, err
+	}
+
+	switch alg {
+	case AlgAES128GCM, AlgAES256GCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case AlgChaCha20Poly1305:
+		return chacha20poly1305.New(key)
+	case AlgXChaCha20Poly1305:
+		return chacha20poly1305.NewX(key)
+	case AlgAES256SIV:
+		return nil, fmt.Errorf("%s is deterministic, use EncryptSIV", alg)
+	default:
+		return nil, fmt.Errorf("%s is not an AEAD algorithm", alg)
+	}
+}
+
+// Output is nonce || ciphertext + tag, like Encrypt (synthetic IV || ciphertext for AES-256-SIV)
+func EncryptWithAlgorithm(alg string, plaintext, key []byte) ([]byte, error) {
+	return EncryptWithAlgorithmAAD(alg, plaintext, key, nil)
+}
+
+func EncryptWithAlgorithmAAD(alg string, plaintext, key, aad []byte) ([]byte, error) {
+	if alg == AlgAES256SIV {
+		if err := ValidateKey(alg, key); err != nil {
+			return nil, err
+		}
+		return EncryptSIV(plaintext, key, aad)
+	}
+
+	aead, err := NewAEAD(alg, key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func DecryptWithAlgorithm(alg string, ciphertext, key []byte) ([]byte, error) {
+	return DecryptWithAlgorithmAAD(alg, ciphertext, key, nil)
+}
+
+func DecryptWithAlgorithmAAD(alg string, ciphertext, key, aad []byte) ([]byte, error) {
+	if alg == AlgAES256SIV {
+		if err := ValidateKey(alg, key); err != nil {
+			return nil, err
+		}
+		return DecryptSIV(ciphertext, key, aad)
+	}
+
+	aead, err := NewAEAD(alg, key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := aead.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	return aead.Open(nil, ciphertext[:nonceSize], ciphertext[nonceSize:], aad)
+}
+
+func Sign(alg string, message, key []byte) ([]byte, error) {
+	if alg != AlgHMACSHA512 {
+		return nil, fmt.Errorf("%s is not a MAC algorithm", alg)
+	}
+	if err := ValidateKey(alg, key); err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha512.New, key)
+	mac.Write(message)
+	return mac.Sum(nil), nil
+}
+
+// Constant time comparison of the expected and given MAC
+func Verify(alg string, message, signature, key []byte) (bool, error) {
+	expected, err := Sign(alg, message, key)
+	if err != nil {
+		return false, err
+	}
+	return hmac.Equal(expected, signature), nil
+}
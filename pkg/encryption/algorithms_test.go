@@ -0,0 +1,76 @@
+package encryption
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptWithAlgorithm_Roundtrip(t *testing.T) {
+	for _, alg := range []string{AlgAES128GCM, AlgAES256GCM, AlgChaCha20Poly1305, AlgXChaCha20Poly1305, AlgAES256SIV} {
+		size, err := KeySize(alg)
+		if err != nil {
+			t.Fatalf("%s: key size failed: %v", alg, err)
+		}
+		key, err := GenerateKey(size)
+		if err != nil {
+			t.Fatalf("%s: generate key failed: %v", alg, err)
+		}
+
+		want := randomBytes(123)
+		encrypted, err := EncryptWithAlgorithm(alg, want, key)
+		if err != nil {
+			t.Fatalf("%s: encryption failed: %v", alg, err)
+		}
+
+		decrypted, err := DecryptWithAlgorithm(alg, encrypted, key)
+		if err != nil {
+			t.Fatalf("%s: decryption failed: %v", alg, err)
+		}
+		if !bytes.Equal(want, decrypted) {
+			t.Errorf("%s: roundtrip failed", alg)
+		}
+	}
+}
+
+func TestEncryptWithAlgorithm_WrongKeySize(t *testing.T) {
+	key, _ := GenerateKey(32)
+	if _, err := EncryptWithAlgorithm(AlgAES128GCM, []byte("data"), key); err == nil {
+		t.Errorf("expected error for 32 byte AES-128-GCM key")
+	}
+}
+
+func TestEncryptWithAlgorithm_NotAEAD(t *testing.T) {
+	key, _ := GenerateKey(hmacSHA512KeySize)
+	if _, err := EncryptWithAlgorithm(AlgHMACSHA512, []byte("data"), key); err == nil {
+		t.Errorf("expected error when encrypting with HMAC key")
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	key, _ := GenerateKey(hmacSHA512KeySize)
+	message := []byte("message")
+
+	signature, err := Sign(AlgHMACSHA512, message, key)
+	if err != nil {
+		t.Fatalf("sign failed: %v", err)
+	}
+	if len(signature) != 64 {
+		t.Errorf("expected 64 byte signature, got %d", len(signature))
+	}
+
+	ok, err := Verify(AlgHMACSHA512, message, signature, key)
+	if err != nil || !ok {
+		t.Errorf("expected valid signature, got %v (%v)", ok, err)
+	}
+
+	ok, _ = Verify(AlgHMACSHA512, []byte("other"), signature, key)
+	if ok {
+		t.Errorf("expected invalid signature for other message")
+	}
+}
+
+func TestKeySize_Unsupported(t *testing.T) {
+	if _, err := KeySize("DES"); err == nil {
+		t.Errorf("expected error for unsupported algorithm")
+	}
+}
@@ -0,0 +1,52 @@
+package encryption
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// Key/value pairs a ciphertext is bound to (e.g. table, column and row), used as additional authenticated data.
+// Decryption only succeeds with the exact same context.
+type EncryptionContext map[string]string
+
+// Canonical encoding: pairs sorted by key, every key and value prefixed with its length (uint32, big endian)
+func (c EncryptionContext) AAD() []byte {
+	if len(c) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var aad []byte
+	for _, k := range keys {
+		aad = binary.BigEndian.AppendUint32(aad, uint32(len(k)))
+		aad = append(aad, k...)
+		aad = binary.BigEndian.AppendUint32(aad, uint32(len(c[k])))
+		aad = append(aad, c[k]...)
+	}
+	return aad
+}
+
+// Copy of the context with the given pairs added
+func (c EncryptionContext) With(kv ...string) EncryptionContext {
+	out := make(EncryptionContext, len(c)+len(kv)/2)
+	for k, v := range c {
+		out[k] = v
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		out[kv[i]] = kv[i+1]
+	}
+	return out
+}
+
+func EncryptWithContext(plaintext, key []byte, ctx EncryptionContext) ([]byte, error) {
+	return EncryptWithAAD(plaintext, key, ctx.AAD())
+}
+
+func DecryptWithContext(ciphertext, key []byte, ctx EncryptionContext) ([]byte, error) {
+	return DecryptWithAAD(ciphertext, key, ctx.AAD())
+}
@@ -0,0 +1,59 @@
+package encryption
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptWithContext_Roundtrip(t *testing.T) {
+	key, _ := GenerateKey(32)
+	ctx := EncryptionContext{"table": "keys", "column": "dek", "clientId": "1"}
+
+	encrypted, err := EncryptWithContext([]byte("secret"), key, ctx)
+	if err != nil {
+		t.Fatalf("encryption failed: %v", err)
+	}
+
+	decrypted, err := DecryptWithContext(encrypted, key, EncryptionContext{"clientId": "1", "column": "dek", "table": "keys"})
+	if err != nil {
+		t.Fatalf("decryption failed: %v", err)
+	}
+	if !bytes.Equal(decrypted, []byte("secret")) {
+		t.Errorf("roundtrip failed: got %q", decrypted)
+	}
+}
+
+func TestDecryptWithContext_WrongContext(t *testing.T) {
+	key, _ := GenerateKey(32)
+	ctx := EncryptionContext{"table": "keys", "clientId": "1"}
+
+	encrypted, err := EncryptWithContext([]byte("secret"), key, ctx)
+	if err != nil {
+		t.Fatalf("encryption failed: %v", err)
+	}
+
+	for _, other := range []EncryptionContext{nil, ctx.With("clientId", "2"), ctx.With("column", "dek")} {
+		if _, err := DecryptWithContext(encrypted, key, other); err == nil {
+			t.Errorf("expected decryption with context %v to fail", other)
+		}
+	}
+}
+
+func TestEncryptionContext_AADUnambiguous(t *testing.T) {
+	a := EncryptionContext{"ab": "c"}
+	b := EncryptionContext{"a": "bc"}
+	if bytes.Equal(a.AAD(), b.AAD()) {
+		t.Errorf("expected different AAD for different contexts")
+	}
+}
+
+func TestEncryptWithAlgorithmAAD_WrongAAD(t *testing.T) {
+	key, _ := GenerateKey(32)
+	encrypted, err := EncryptWithAlgorithmAAD(AlgChaCha20Poly1305, []byte("secret"), key, []byte("aad"))
+	if err != nil {
+		t.Fatalf("encryption failed: %v", err)
+	}
+	if _, err := DecryptWithAlgorithmAAD(AlgChaCha20Poly1305, encrypted, key, []byte("other")); err == nil {
+		t.Errorf("expected decryption with other aad to fail")
+	}
+}
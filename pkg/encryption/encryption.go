@@ -9,6 +9,11 @@ import (
 )
 
 func Encrypt(plaintext, key []byte) ([]byte, error) {
+	return EncryptWithAAD(plaintext, key, nil)
+}
+
+// AES-GCM with additional authenticated data, the same aad is required to decrypt
+func EncryptWithAAD(plaintext, key, aad []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -24,11 +29,15 @@ func Encrypt(plaintext, key []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	ciphertext := aesgcm.Seal(nonce, nonce, plaintext, nil)
+	ciphertext := aesgcm.Seal(nonce, nonce, plaintext, aad)
 	return ciphertext, nil
 }
 
 func Decrypt(ciphertext, key []byte) ([]byte, error) {
+	return DecryptWithAAD(ciphertext, key, nil)
+}
+
+func DecryptWithAAD(ciphertext, key, aad []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -47,7 +56,7 @@ func Decrypt(ciphertext, key []byte) ([]byte, error) {
 	nonce := ciphertext[:nonceSize]
 	ciphertextOnly := ciphertext[nonceSize:]
 
-	plaintext, err := aesgcm.Open(nil, nonce, ciphertextOnly, nil)
+	plaintext, err := aesgcm.Open(nil, nonce, ciphertextOnly, aad)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,146 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+)
+
+// AES-SIV (RFC 5297), deterministic authenticated encryption: the same plaintext, key and aad always give the same ciphertext.
+// Used for values that need to be looked up by equality, it does reveal which ciphertexts share a plaintext.
+// Output is the synthetic IV (16 bytes) || ciphertext, the key is split into a CMAC and a CTR key.
+
+const sivTagSize = aes.BlockSize
+
+var errSIVOpen = errors.New("cipher: message authentication failed")
+
+// Key is 32 (AES-SIV-CMAC-256) or 64 (AES-SIV-CMAC-512) bytes
+func EncryptSIV(plaintext, key, aad []byte) ([]byte, error) {
+	macBlock, ctrBlock, err := sivBlocks(key)
+	if err != nil {
+		return nil, err
+	}
+
+	v := s2v(macBlock, aad, plaintext)
+	out := make([]byte, sivTagSize+len(plaintext))
+	copy(out, v)
+	cipher.NewCTR(ctrBlock, sivCounter(v)).XORKeyStream(out[sivTagSize:], plaintext)
+	return out, nil
+}
+
+func DecryptSIV(ciphertext, key, aad []byte) ([]byte, error) {
+	if len(ciphertext) < sivTagSize {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	macBlock, ctrBlock, err := sivBlocks(key)
+	if err != nil {
+		return nil, err
+	}
+
+	v := ciphertext[:sivTagSize]
+	plaintext := make([]byte, len(ciphertext)-sivTagSize)
+	cipher.NewCTR(ctrBlock, sivCounter(v)).XORKeyStream(plaintext, ciphertext[sivTagSize:])
+
+	if subtle.ConstantTimeCompare(s2v(macBlock, aad, plaintext), v) != 1 {
+		return nil, errSIVOpen
+	}
+	return plaintext, nil
+}
+
+func sivBlocks(key []byte) (cipher.Block, cipher.Block, error) {
+	if len(key) != 32 && len(key) != 64 {
+		return nil, nil, fmt.Errorf("AES-SIV key must be 32 or 64 bytes, is %d", len(key))
+	}
+	macBlock, err := aes.NewCipher(key[:len(key)/2])
+	if err != nil {
+		return nil, nil, err
+	}
+	ctrBlock, err := aes.NewCipher(key[len(key)/2:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return macBlock, ctrBlock, nil
+}
+
+// Clears the 31st and 63rd bit (from the right), RFC 5297 section 2.6
+func sivCounter(v []byte) []byte {
+	q := make([]byte, sivTagSize)
+	copy(q, v)
+	q[8] &= 0x7f
+	q[12] &= 0x7f
+	return q
+}
+
+// RFC 5297 section 2.4, nil aad means no associated data component at all
+func s2v(block cipher.Block, aad, plaintext []byte) []byte {
+	d := cmac(block, make([]byte, aes.BlockSize))
+	if aad != nil {
+		d = xorBlock(dbl(d), cmac(block, aad))
+	}
+
+	if len(plaintext) >= aes.BlockSize {
+		t := make([]byte, len(plaintext))
+		copy(t, plaintext)
+		end := t[len(t)-aes.BlockSize:]
+		copy(end, xorBlock(end, d))
+		return cmac(block, t)
+	}
+
+	padded := make([]byte, aes.BlockSize)
+	copy(padded, plaintext)
+	padded[len(plaintext)] = 0x80
+	return cmac(block, xorBlock(dbl(d), padded))
+}
+
+// AES-CMAC (RFC 4493)
+func cmac(block cipher.Block, msg []byte) []byte {
+	l := make([]byte, aes.BlockSize)
+	block.Encrypt(l, l)
+	k1 := dbl(l)
+	k2 := dbl(k1)
+
+	n := (len(msg) + aes.BlockSize - 1) / aes.BlockSize
+	complete := n > 0 && len(msg)%aes.BlockSize == 0
+	if n == 0 {
+		n = 1
+	}
+
+	last := make([]byte, aes.BlockSize)
+	if complete {
+		copy(last, xorBlock(msg[(n-1)*aes.BlockSize:], k1))
+	} else {
+		rest := msg[(n-1)*aes.BlockSize:]
+		copy(last, rest)
+		last[len(rest)] = 0x80
+		last = xorBlock(last, k2)
+	}
+
+	x := make([]byte, aes.BlockSize)
+	for i := 0; i < n-1; i++ {
+		x = xorBlock(x, msg[i*aes.BlockSize:(i+1)*aes.BlockSize])
+		block.Encrypt(x, x)
+	}
+	x = xorBlock(x, last)
+	block.Encrypt(x, x)
+	return x
+}
+
+// Multiplication by x in GF(2^128)
+func dbl(b []byte) []byte {
+	out := make([]byte, aes.BlockSize)
+	var carry byte
+	for i := aes.BlockSize - 1; i >= 0; i-- {
+		out[i] = b[i]<<1 | carry
+		carry = b[i] >> 7
+	}
+	out[aes.BlockSize-1] ^= 0x87 * carry
+	return out
+}
+
+func xorBlock(a, b []byte) []byte {
+	out := make([]byte, aes.BlockSize)
+	subtle.XORBytes(out, a[:aes.BlockSize], b[:aes.BlockSize])
+	return out
+}
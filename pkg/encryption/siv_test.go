@@ -0,0 +1,96 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("invalid hex: %v", err)
+	}
+	return b
+}
+
+// RFC 4493, section 4
+func TestCMAC_Vectors(t *testing.T) {
+	block, _ := aes.NewCipher(mustHex(t, "2b7e151628aed2a6abf7158809cf4f3c"))
+
+	tests := []struct {
+		msg  string
+		want string
+	}{
+		{"", "bb1d6929e95937287fa37d129b756746"},
+		{"6bc1bee22e409f96e93d7e117393172a", "070a16b46b4d4144f79bdd9dd04a287c"},
+	}
+	for _, tt := range tests {
+		if got := cmac(block, mustHex(t, tt.msg)); !bytes.Equal(got, mustHex(t, tt.want)) {
+			t.Errorf("cmac(%s) = %x, want %s", tt.msg, got, tt.want)
+		}
+	}
+}
+
+// RFC 5297, appendix A.1
+func TestEncryptSIV_Vector(t *testing.T) {
+	key := mustHex(t, "fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+	aad := mustHex(t, "101112131415161718191a1b1c1d1e1f2021222324252627")
+	plaintext := mustHex(t, "112233445566778899aabbccddee")
+	want := mustHex(t, "85632d07c6e8f37f950acd320a2ecc9340c02b9690c4dc04daef7f6afe5c")
+
+	got, err := EncryptSIV(plaintext, key, aad)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %x, want %x", got, want)
+	}
+
+	decrypted, err := DecryptSIV(got, key, aad)
+	if err != nil || !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("roundtrip failed: %v", err)
+	}
+}
+
+func TestEncryptSIV_Deterministic(t *testing.T) {
+	key, _ := GenerateKey(64)
+
+	for _, n := range []int{0, 5, 16, 100} {
+		plaintext := randomBytes(n)
+		a, err := EncryptWithAlgorithm(AlgAES256SIV, plaintext, key)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		b, _ := EncryptWithAlgorithm(AlgAES256SIV, plaintext, key)
+		if !bytes.Equal(a, b) {
+			t.Errorf("%d bytes: expected equal ciphertexts", n)
+		}
+
+		// aad is bound like with the other algorithms
+		c, _ := EncryptWithAlgorithmAAD(AlgAES256SIV, plaintext, key, []byte("users.email"))
+		if bytes.Equal(a, c) {
+			t.Errorf("%d bytes: expected aad to change the ciphertext", n)
+		}
+		if _, err := DecryptWithAlgorithm(AlgAES256SIV, c, key); err == nil {
+			t.Errorf("%d bytes: expected missing aad to fail", n)
+		}
+	}
+}
+
+func TestDecryptSIV_Modified(t *testing.T) {
+	key, _ := GenerateKey(64)
+	ciphertext, _ := EncryptSIV([]byte("alice@example.com"), key, nil)
+
+	for i := range ciphertext {
+		tampered := bytes.Clone(ciphertext)
+		tampered[i] ^= 1
+		if _, err := DecryptSIV(tampered, key, nil); err == nil {
+			t.Fatalf("expected modified byte %d to fail", i)
+		}
+	}
+	if _, err := DecryptSIV(ciphertext[:10], key, nil); err == nil {
+		t.Errorf("expected short ciphertext to fail")
+	}
+}
@@ -0,0 +1,322 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Segmented AEAD for data that doesn't fit in memory (STREAM construction, see docs/stream.md).
+// The plaintext is split into segments that are sealed separately with the nonce
+// noncePrefix || segment counter (uint32, big endian) || last segment flag,
+// so segments can't be reordered, dropped or appended, and truncation is detected.
+// Every segment is sealed with a key derived from the DEK and a random salt,
+// and the header is bound to every segment as additional data.
+const (
+	StreamMagic         = "KMSS"
+	StreamVersion       = 1
+	DefaultSegmentSize  = 64 * 1024
+	MaxSegmentSize      = 16 * 1024 * 1024
+	streamSaltSize      = 32
+	streamCounterSize   = 4
+	streamLastFlagSize  = 1
+	streamKeyDerivation = "kms-stream/1"
+)
+
+var ErrStreamTruncated = errors.New("stream is truncated")
+
+type StreamHeader struct {
+	Algorithm    string
+	KeyReference string
+	KeyVersion   int
+	SegmentSize  int
+
+	salt        []byte
+	noncePrefix []byte
+	raw         []byte
+}
+
+func (h *StreamHeader) marshal() ([]byte, error) {
+	if len(h.Algorithm) > 255 || len(h.KeyReference) > 255 {
+		return nil, fmt.Errorf("algorithm and key reference must be at most 255 bytes")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(StreamMagic)
+	buf.WriteByte(StreamVersion)
+	buf.WriteByte(byte(len(h.Algorithm)))
+	buf.WriteString(h.Algorithm)
+	buf.WriteByte(byte(len(h.KeyReference)))
+	buf.WriteString(h.KeyReference)
+	buf.Write(binary.BigEndian.AppendUint32(nil, uint32(h.KeyVersion)))
+	buf.Write(binary.BigEndian.AppendUint32(nil, uint32(h.SegmentSize)))
+	buf.Write(h.salt)
+	buf.WriteByte(byte(len(h.noncePrefix)))
+	buf.Write(h.noncePrefix)
+	return buf.Bytes(), nil
+}
+
+// Reads the header, so the caller knows which key to fetch before decrypting with NewStreamReader.
+func ReadStreamHeader(r io.Reader) (*StreamHeader, error) {
+	var raw bytes.Buffer
+	tr := io.TeeReader(r, &raw)
+
+	readN := func(n int) ([]byte, error) {
+		b := make([]byte, n)
+		if _, err := io.ReadFull(tr, b); err != nil {
+			return nil, fmt.Errorf("invalid stream header: %w", err)
+		}
+		return b, nil
+	}
+	readLenPrefixed := func() ([]byte, error) {
+		l, err := readN(1)
+		if err != nil {
+			return nil, err
+		}
+		return readN(int(l[0]))
+	}
+
+	magic, err := readN(len(StreamMagic) + 1)
+	if err != nil {
+		return nil, err
+	}
+	if string(magic[:len(StreamMagic)]) != StreamMagic {
+		return nil, fmt.Errorf("not an encrypted stream")
+	}
+	if magic[len(StreamMagic)] != StreamVersion {
+		return nil, fmt.Errorf("unsupported stream version: %d", magic[len(StreamMagic)])
+	}
+
+	h := &StreamHeader{}
+	alg, err := readLenPrefixed()
+	if err != nil {
+		return nil, err
+	}
+	h.Algorithm = string(alg)
+	ref, err := readLenPrefixed()
+	if err != nil {
+		return nil, err
+	}
+	h.KeyReference = string(ref)
+	nums, err := readN(8)
+	if err != nil {
+		return nil, err
+	}
+	h.KeyVersion = int(binary.BigEndian.Uint32(nums[:4]))
+	h.SegmentSize = int(binary.BigEndian.Uint32(nums[4:]))
+	if h.SegmentSize < 1 || h.SegmentSize > MaxSegmentSize {
+		return nil, fmt.Errorf("invalid segment size: %d", h.SegmentSize)
+	}
+	if h.salt, err = readN(streamSaltSize); err != nil {
+		return nil, err
+	}
+	if h.noncePrefix, err = readLenPrefixed(); err != nil {
+		return nil, err
+	}
+
+	h.raw = raw.Bytes()
+	return h, nil
+}
+
+func newStreamAEAD(h *StreamHeader, key []byte) (cipher.AEAD, error) {
+	if err := ValidateKey(h.Algorithm, key); err != nil {
+		return nil, err
+	}
+	segmentKey := make([]byte, len(key))
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, h.salt, []byte(streamKeyDerivation)), segmentKey); err != nil {
+		return nil, err
+	}
+	aead, err := NewAEAD(h.Algorithm, segmentKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(h.noncePrefix)+streamCounterSize+streamLastFlagSize != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce prefix for %s", h.Algorithm)
+	}
+	return aead, nil
+}
+
+func segmentNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := binary.BigEndian.AppendUint32(append([]byte{}, prefix...), counter)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+type streamWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  *StreamHeader
+	buf     []byte
+	counter uint32
+	closed  bool
+}
+
+// Writes the header to w, plaintext written to the returned writer is encrypted in segments.
+// Close must be called to write the last segment, without it the stream is detected as truncated.
+func NewStreamWriter(w io.Writer, key []byte, algorithm, keyReference string, keyVersion, segmentSize int) (io.WriteCloser, error) {
+	if segmentSize < 1 || segmentSize > MaxSegmentSize {
+		return nil, fmt.Errorf("segment size must be between 1 and %d bytes", MaxSegmentSize)
+	}
+
+	h := &StreamHeader{
+		Algorithm:    algorithm,
+		KeyReference: keyReference,
+		KeyVersion:   keyVersion,
+		SegmentSize:  segmentSize,
+		salt:         make([]byte, streamSaltSize),
+	}
+	if _, err := rand.Read(h.salt); err != nil {
+		return nil, err
+	}
+
+	// the nonce size depends on the algorithm
+	aead, err := NewAEAD(algorithm, key)
+	if err != nil {
+		return nil, err
+	}
+	h.noncePrefix = make([]byte, aead.NonceSize()-streamCounterSize-streamLastFlagSize)
+	if _, err := rand.Read(h.noncePrefix); err != nil {
+		return nil, err
+	}
+
+	if aead, err = newStreamAEAD(h, key); err != nil {
+		return nil, err
+	}
+	if h.raw, err = h.marshal(); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(h.raw); err != nil {
+		return nil, err
+	}
+
+	return &streamWriter{
+		w:      w,
+		aead:   aead,
+		header: h,
+		buf:    make([]byte, 0, segmentSize),
+	}, nil
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, errors.New("write to closed stream")
+	}
+
+	n := 0
+	for len(p) > 0 {
+		// a full segment is only sealed once more data arrives, the last one is sealed on Close
+		if len(s.buf) == s.header.SegmentSize {
+			if err := s.seal(false); err != nil {
+				return n, err
+			}
+		}
+		c := copy(s.buf[len(s.buf):cap(s.buf)], p)
+		s.buf = s.buf[:len(s.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+func (s *streamWriter) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.seal(true)
+}
+
+func (s *streamWriter) seal(last bool) error {
+	if s.counter == ^uint32(0) {
+		return errors.New("stream is too long")
+	}
+	ciphertext := s.aead.Seal(nil, segmentNonce(s.header.noncePrefix, s.counter, last), s.buf, s.header.raw)
+	if _, err := s.w.Write(ciphertext); err != nil {
+		return err
+	}
+	s.counter++
+	s.buf = s.buf[:0]
+	return nil
+}
+
+type streamReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	header  *StreamHeader
+	segment []byte
+	buf     []byte
+	counter uint32
+	done    bool
+}
+
+// Decrypts the segments following the header, r must be positioned right after ReadStreamHeader.
+// Read returns an error if any segment was modified or the stream was truncated.
+func NewStreamReader(r io.Reader, key []byte, header *StreamHeader) (io.Reader, error) {
+	aead, err := newStreamAEAD(header, key)
+	if err != nil {
+		return nil, err
+	}
+	return &streamReader{
+		r:       bufio.NewReaderSize(r, header.SegmentSize+aead.Overhead()+1),
+		aead:    aead,
+		header:  header,
+		segment: make([]byte, header.SegmentSize+aead.Overhead()),
+	}, nil
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+		if err := s.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+func (s *streamReader) open() error {
+	n, err := io.ReadFull(s.r, s.segment)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	if n < s.aead.Overhead() {
+		return ErrStreamTruncated
+	}
+
+	// a short segment, or a full one with nothing after it, must be the last one
+	last := n < len(s.segment)
+	if !last {
+		if _, err := s.r.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	plaintext, err := s.aead.Open(s.segment[:0], segmentNonce(s.header.noncePrefix, s.counter, last), s.segment[:n], s.header.raw)
+	if err != nil {
+		if last {
+			return ErrStreamTruncated
+		}
+		return fmt.Errorf("segment %d failed to authenticate: %w", s.counter, err)
+	}
+
+	s.buf = plaintext
+	s.counter++
+	s.done = last
+	return nil
+}
@@ -0,0 +1,155 @@
+package encryption
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func encryptStream(t *testing.T, alg string, key, plaintext []byte, segmentSize int) []byte {
+	var out bytes.Buffer
+	w, err := NewStreamWriter(&out, key, alg, "backups", 3, segmentSize)
+	if err != nil {
+		t.Fatalf("new writer failed: %v", err)
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	return out.Bytes()
+}
+
+func decryptStream(key, ciphertext []byte) ([]byte, error) {
+	r := bytes.NewReader(ciphertext)
+	header, err := ReadStreamHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	sr, err := NewStreamReader(r, key, header)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(sr)
+}
+
+func TestStream_Roundtrip(t *testing.T) {
+	for _, alg := range []string{AlgAES128GCM, AlgAES256GCM, AlgChaCha20Poly1305, AlgXChaCha20Poly1305} {
+		size, _ := KeySize(alg)
+		key, _ := GenerateKey(size)
+
+		// empty, shorter than, exactly and not a multiple of the segment size
+		for _, n := range []int{0, 10, 64, 64 * 3, 200} {
+			plaintext, _ := GenerateKey(n)
+			ciphertext := encryptStream(t, alg, key, plaintext, 64)
+
+			decrypted, err := decryptStream(key, ciphertext)
+			if err != nil {
+				t.Fatalf("%s (%d bytes): decrypt failed: %v", alg, n, err)
+			}
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("%s (%d bytes): roundtrip failed", alg, n)
+			}
+		}
+	}
+}
+
+func TestReadStreamHeader(t *testing.T) {
+	key, _ := GenerateKey(32)
+	ciphertext := encryptStream(t, AlgAES256GCM, key, []byte("secret"), DefaultSegmentSize)
+
+	header, err := ReadStreamHeader(bytes.NewReader(ciphertext))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if header.Algorithm != AlgAES256GCM || header.KeyReference != "backups" || header.KeyVersion != 3 || header.SegmentSize != DefaultSegmentSize {
+		t.Errorf("unexpected header: %+v", header)
+	}
+
+	if _, err := ReadStreamHeader(bytes.NewReader([]byte("not a stream"))); err == nil {
+		t.Errorf("expected error for invalid header")
+	}
+}
+
+func TestStream_Truncated(t *testing.T) {
+	key, _ := GenerateKey(32)
+	plaintext, _ := GenerateKey(64 * 3)
+	ciphertext := encryptStream(t, AlgAES256GCM, key, plaintext, 64)
+	header, _ := ReadStreamHeader(bytes.NewReader(ciphertext))
+	segment := 64 + 16
+
+	// cut at a segment boundary, and in the middle of a segment
+	for _, cut := range []int{segment, segment + 10} {
+		_, err := decryptStream(key, ciphertext[:len(ciphertext)-cut])
+		if !errors.Is(err, ErrStreamTruncated) {
+			t.Errorf("cut %d: expected truncation error, got %v", cut, err)
+		}
+	}
+
+	// only the header
+	_, err := decryptStream(key, ciphertext[:len(header.raw)])
+	if !errors.Is(err, ErrStreamTruncated) {
+		t.Errorf("expected truncation error, got %v", err)
+	}
+}
+
+func TestStream_Modified(t *testing.T) {
+	key, _ := GenerateKey(32)
+	plaintext, _ := GenerateKey(64 * 3)
+	ciphertext := encryptStream(t, AlgAES256GCM, key, plaintext, 64)
+	header, _ := ReadStreamHeader(bytes.NewReader(ciphertext))
+	segment := 64 + 16
+
+	tampered := bytes.Clone(ciphertext)
+	tampered[len(header.raw)+5] ^= 1
+	if _, err := decryptStream(key, tampered); err == nil {
+		t.Errorf("expected modified segment to fail")
+	}
+
+	// swap the first two segments
+	swapped := bytes.Clone(ciphertext)
+	start := len(header.raw)
+	copy(swapped[start:], ciphertext[start+segment:start+2*segment])
+	copy(swapped[start+segment:], ciphertext[start:start+segment])
+	if _, err := decryptStream(key, swapped); err == nil {
+		t.Errorf("expected reordered segments to fail")
+	}
+
+	// the header is bound to the segments, so the key version can't be changed
+	changed := bytes.Clone(ciphertext)
+	changed[len(StreamMagic)+1+1+len(AlgAES256GCM)+1+len("backups")+3] = 4
+	if _, err := decryptStream(key, changed); err == nil {
+		t.Errorf("expected modified header to fail")
+	}
+}
+
+func TestStream_WrongKey(t *testing.T) {
+	key, _ := GenerateKey(32)
+	otherKey, _ := GenerateKey(32)
+	ciphertext := encryptStream(t, AlgAES256GCM, key, []byte("secret"), 64)
+
+	if _, err := decryptStream(otherKey, ciphertext); err == nil {
+		t.Errorf("expected wrong key to fail")
+	}
+}
+
+func TestNewStreamWriter_InvalidInput(t *testing.T) {
+	key, _ := GenerateKey(64)
+	if _, err := NewStreamWriter(io.Discard, key, AlgHMACSHA512, "ref", 1, 64); err == nil {
+		t.Errorf("expected error for non-AEAD algorithm")
+	}
+	key, _ = GenerateKey(32)
+	if _, err := NewStreamWriter(io.Discard, key, AlgAES256GCM, "ref", 1, 0); err == nil {
+		t.Errorf("expected error for invalid segment size")
+	}
+}
+
+func TestNewStreamWriter_Deterministic(t *testing.T) {
+	key, _ := GenerateKey(64)
+	var out bytes.Buffer
+	if _, err := NewStreamWriter(&out, key, AlgAES256SIV, "ref", 1, 64); err == nil {
+		t.Errorf("expected error for deterministic algorithm")
+	}
+}
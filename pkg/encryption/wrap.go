@@ -0,0 +1,227 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Algorithms for wrapping key material that is imported into the KMS
+const (
+	// RSAES-OAEP with SHA-256 and MGF1-SHA-256, 3072 bit key
+	WrapAlgRSAOAEP256 = "RSA-OAEP-256"
+	// Ephemeral-static ECDH on P-256, HKDF-SHA256 derived AES-256-GCM key.
+	// Wrapped format: ephemeral public key (65 bytes, uncompressed) || nonce (12 bytes) || ciphertext + tag
+	WrapAlgECDHP256 = "ECDH-P256"
+)
+
+const (
+	rsaWrappingKeyBits = 3072
+	ecdhWrapInfo       = "kms key import"
+)
+
+// Private half of a wrapping key pair, public half is shared with whoever wraps the key material
+type WrappingKey struct {
+	Algorithm string
+	rsaKey    *rsa.PrivateKey
+	ecdhKey   *ecdh.PrivateKey
+}
+
+func GenerateWrappingKey(alg string) (*WrappingKey, error) {
+	switch alg {
+	case WrapAlgRSAOAEP256:
+		key, err := rsa.GenerateKey(rand.Reader, rsaWrappingKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		return &WrappingKey{Algorithm: alg, rsaKey: key}, nil
+	case WrapAlgECDHP256:
+		key, err := ecdh.P256().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return &WrappingKey{Algorithm: alg, ecdhKey: key}, nil
+	default:
+		return nil, fmt.Errorf("unsupported wrapping algorithm: %v", alg)
+	}
+}
+
+// PKIX (SubjectPublicKeyInfo) DER encoding of the public key
+func (k *WrappingKey) PublicKeyDER() ([]byte, error) {
+	if k.rsaKey != nil {
+		return x509.MarshalPKIXPublicKey(&k.rsaKey.PublicKey)
+	}
+	return x509.MarshalPKIXPublicKey(k.ecdhKey.PublicKey())
+}
+
+// Load a PKCS #8 encoded private key (e.g. an operator's escrow key) as wrapping key
+func ParseWrappingKey(alg string, privateKeyDER []byte) (*WrappingKey, error) {
+	priv, err := x509.ParsePKCS8PrivateKey(privateKeyDER)
+	if err != nil {
+		return nil, err
+	}
+
+	switch alg {
+	case WrapAlgRSAOAEP256:
+		rsaKey, ok := priv.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private key is not an RSA key")
+		}
+		return &WrappingKey{Algorithm: alg, rsaKey: rsaKey}, nil
+	case WrapAlgECDHP256:
+		ecKey, ok := priv.(interface {
+			ECDH() (*ecdh.PrivateKey, error)
+		})
+		if !ok {
+			return nil, fmt.Errorf("private key is not an EC key")
+		}
+		ecdhKey, err := ecKey.ECDH()
+		if err != nil {
+			return nil, err
+		}
+		if ecdhKey.Curve() != ecdh.P256() {
+			return nil, fmt.Errorf("private key is not a P-256 key")
+		}
+		return &WrappingKey{Algorithm: alg, ecdhKey: ecdhKey}, nil
+	default:
+		return nil, fmt.Errorf("unsupported wrapping algorithm: %v", alg)
+	}
+}
+
+// PKCS #8 DER encoding of the private key
+func (k *WrappingKey) PrivateKeyDER() ([]byte, error) {
+	if k.rsaKey != nil {
+		return x509.MarshalPKCS8PrivateKey(k.rsaKey)
+	}
+	return x509.MarshalPKCS8PrivateKey(k.ecdhKey)
+}
+
+func (k *WrappingKey) Unwrap(wrapped []byte) ([]byte, error) {
+	return k.UnwrapWithLabel(wrapped, nil)
+}
+
+// Label must match the one the key was wrapped with, see WrapKeyWithLabel
+func (k *WrappingKey) UnwrapWithLabel(wrapped, label []byte) ([]byte, error) {
+	switch k.Algorithm {
+	case WrapAlgRSAOAEP256:
+		return rsa.DecryptOAEP(sha256.New(), nil, k.rsaKey, wrapped, label)
+	case WrapAlgECDHP256:
+		pubLen := len(k.ecdhKey.PublicKey().Bytes())
+		if len(wrapped) < pubLen {
+			return nil, fmt.Errorf("wrapped key too short")
+		}
+		ephemeral, err := ecdh.P256().NewPublicKey(wrapped[:pubLen])
+		if err != nil {
+			return nil, err
+		}
+		shared, err := k.ecdhKey.ECDH(ephemeral)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := ecdhWrapAEAD(shared, ephemeral, k.ecdhKey.PublicKey())
+		if err != nil {
+			return nil, err
+		}
+		rest := wrapped[pubLen:]
+		if len(rest) < aead.NonceSize() {
+			return nil, fmt.Errorf("wrapped key too short")
+		}
+		return aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], append(ephemeral.Bytes(), label...))
+	default:
+		return nil, fmt.Errorf("unsupported wrapping algorithm: %v", k.Algorithm)
+	}
+}
+
+// Wrap key material for import, using the PKIX DER encoded public key handed out by the KMS
+func WrapKey(alg string, publicKeyDER, key []byte) ([]byte, error) {
+	return WrapKeyWithLabel(alg, publicKeyDER, key, nil)
+}
+
+// Binds the wrapped key to label (OAEP label or additional authenticated data), so it can't be unwrapped in another context
+func WrapKeyWithLabel(alg string, publicKeyDER, key, label []byte) ([]byte, error) {
+	pub, err := x509.ParsePKIXPublicKey(publicKeyDER)
+	if err != nil {
+		return nil, err
+	}
+
+	switch alg {
+	case WrapAlgRSAOAEP256:
+		rsaPub, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("public key is not an RSA key")
+		}
+		return rsa.EncryptOAEP(sha256.New(), rand.Reader, rsaPub, key, label)
+	case WrapAlgECDHP256:
+		ecdhPub, err := toECDHP256(pub)
+		if err != nil {
+			return nil, err
+		}
+		ephemeral, err := ecdh.P256().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		shared, err := ephemeral.ECDH(ecdhPub)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := ecdhWrapAEAD(shared, ephemeral.PublicKey(), ecdhPub)
+		if err != nil {
+			return nil, err
+		}
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return nil, err
+		}
+		out := append(ephemeral.PublicKey().Bytes(), nonce...)
+		return aead.Seal(out, nonce, key, append(ephemeral.PublicKey().Bytes(), label...)), nil
+	default:
+		return nil, fmt.Errorf("unsupported wrapping algorithm: %v", alg)
+	}
+}
+
+// x509 parses P-256 keys as *ecdsa.PublicKey
+func toECDHP256(pub any) (*ecdh.PublicKey, error) {
+	switch p := pub.(type) {
+	case *ecdh.PublicKey:
+		if p.Curve() != ecdh.P256() {
+			return nil, fmt.Errorf("public key is not a P-256 key")
+		}
+		return p, nil
+	case interface {
+		ECDH() (*ecdh.PublicKey, error)
+	}:
+		ecdhPub, err := p.ECDH()
+		if err != nil {
+			return nil, err
+		}
+		if ecdhPub.Curve() != ecdh.P256() {
+			return nil, fmt.Errorf("public key is not a P-256 key")
+		}
+		return ecdhPub, nil
+	default:
+		return nil, fmt.Errorf("public key is not an EC key")
+	}
+}
+
+// Derive the AES-256-GCM key from the shared secret, bound to both public keys
+func ecdhWrapAEAD(shared []byte, ephemeral, recipient *ecdh.PublicKey) (cipher.AEAD, error) {
+	salt := bytes.Join([][]byte{ephemeral.Bytes(), recipient.Bytes()}, nil)
+	kek := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(ecdhWrapInfo)), kek); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,147 @@
+package encryption
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWrapKeyRoundtrip(t *testing.T) {
+	for _, alg := range []string{WrapAlgRSAOAEP256, WrapAlgECDHP256} {
+		t.Run(alg, func(t *testing.T) {
+			wrappingKey, err := GenerateWrappingKey(alg)
+			if err != nil {
+				t.Fatalf("generate wrapping key failed: %v", err)
+			}
+			pub, err := wrappingKey.PublicKeyDER()
+			if err != nil {
+				t.Fatalf("marshal public key failed: %v", err)
+			}
+
+			want := randomBytes(32)
+			wrapped, err := WrapKey(alg, pub, want)
+			if err != nil {
+				t.Fatalf("wrap failed: %v", err)
+			}
+
+			unwrapped, err := wrappingKey.Unwrap(wrapped)
+			if err != nil {
+				t.Fatalf("unwrap failed: %v", err)
+			}
+			if !bytes.Equal(want, unwrapped) {
+				t.Errorf("roundtrip failed: got %x; want %x", unwrapped, want)
+			}
+		})
+	}
+}
+
+func TestWrapKey_Tampered(t *testing.T) {
+	for _, alg := range []string{WrapAlgRSAOAEP256, WrapAlgECDHP256} {
+		t.Run(alg, func(t *testing.T) {
+			wrappingKey, err := GenerateWrappingKey(alg)
+			if err != nil {
+				t.Fatalf("generate wrapping key failed: %v", err)
+			}
+			pub, err := wrappingKey.PublicKeyDER()
+			if err != nil {
+				t.Fatalf("marshal public key failed: %v", err)
+			}
+
+			wrapped, err := WrapKey(alg, pub, randomBytes(32))
+			if err != nil {
+				t.Fatalf("wrap failed: %v", err)
+			}
+			wrapped[len(wrapped)-1] ^= 0x01
+
+			if _, err := wrappingKey.Unwrap(wrapped); err == nil {
+				t.Error("expected unwrap of tampered key to fail")
+			}
+		})
+	}
+}
+
+func TestWrapKey_WrongKeyType(t *testing.T) {
+	ecdhKey, err := GenerateWrappingKey(WrapAlgECDHP256)
+	if err != nil {
+		t.Fatalf("generate wrapping key failed: %v", err)
+	}
+	pub, err := ecdhKey.PublicKeyDER()
+	if err != nil {
+		t.Fatalf("marshal public key failed: %v", err)
+	}
+
+	if _, err := WrapKey(WrapAlgRSAOAEP256, pub, randomBytes(32)); err == nil {
+		t.Error("expected wrapping with mismatched algorithm to fail")
+	}
+	if _, err := GenerateWrappingKey("AES-KW"); err == nil {
+		t.Error("expected unsupported algorithm to fail")
+	}
+}
+
+func TestWrapKeyWithLabel(t *testing.T) {
+	for _, alg := range []string{WrapAlgRSAOAEP256, WrapAlgECDHP256} {
+		t.Run(alg, func(t *testing.T) {
+			wrappingKey, err := GenerateWrappingKey(alg)
+			if err != nil {
+				t.Fatalf("generate wrapping key failed: %v", err)
+			}
+			pub, err := wrappingKey.PublicKeyDER()
+			if err != nil {
+				t.Fatalf("marshal public key failed: %v", err)
+			}
+
+			want := randomBytes(32)
+			wrapped, err := WrapKeyWithLabel(alg, pub, want, []byte("label"))
+			if err != nil {
+				t.Fatalf("wrap failed: %v", err)
+			}
+
+			if _, err := wrappingKey.UnwrapWithLabel(wrapped, []byte("other")); err == nil {
+				t.Error("expected unwrap with other label to fail")
+			}
+			unwrapped, err := wrappingKey.UnwrapWithLabel(wrapped, []byte("label"))
+			if err != nil {
+				t.Fatalf("unwrap failed: %v", err)
+			}
+			if !bytes.Equal(want, unwrapped) {
+				t.Errorf("roundtrip failed: got %x; want %x", unwrapped, want)
+			}
+		})
+	}
+}
+
+func TestParseWrappingKey(t *testing.T) {
+	for _, alg := range []string{WrapAlgRSAOAEP256, WrapAlgECDHP256} {
+		t.Run(alg, func(t *testing.T) {
+			wrappingKey, err := GenerateWrappingKey(alg)
+			if err != nil {
+				t.Fatalf("generate wrapping key failed: %v", err)
+			}
+			pub, err := wrappingKey.PublicKeyDER()
+			if err != nil {
+				t.Fatalf("marshal public key failed: %v", err)
+			}
+			priv, err := wrappingKey.PrivateKeyDER()
+			if err != nil {
+				t.Fatalf("marshal private key failed: %v", err)
+			}
+
+			parsed, err := ParseWrappingKey(alg, priv)
+			if err != nil {
+				t.Fatalf("parse private key failed: %v", err)
+			}
+
+			want := randomBytes(32)
+			wrapped, err := WrapKey(alg, pub, want)
+			if err != nil {
+				t.Fatalf("wrap failed: %v", err)
+			}
+			unwrapped, err := parsed.Unwrap(wrapped)
+			if err != nil {
+				t.Fatalf("unwrap with parsed key failed: %v", err)
+			}
+			if !bytes.Equal(want, unwrapped) {
+				t.Errorf("roundtrip failed: got %x; want %x", unwrapped, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package errors
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Stable error codes, clients match on these instead of the message. Errors without one get the
+// upper snake case status text, e.g. 'NOT_FOUND' or 'INTERNAL_SERVER_ERROR'.
+const (
+	CodeInvalidBody        = "INVALID_BODY"
+	CodeInvalidParameter   = "INVALID_PARAMETER"
+	CodeMissingCredentials = "MISSING_CREDENTIALS"
+	CodeInvalidCredentials = "INVALID_CREDENTIALS"
+	CodeAlreadyExists      = "ALREADY_EXISTS"
+
+	CodeTokenExpired = "TOKEN_EXPIRED"
+	CodeTokenInvalid = "TOKEN_INVALID"
+	CodeMfaRequired  = "MFA_REQUIRED"
+	CodeMfaInvalid   = "MFA_INVALID"
+
+	CodeLoginThrottled = "LOGIN_THROTTLED"
+	CodeRateLimited    = "RATE_LIMITED"
+	CodeQuotaExceeded  = "QUOTA_EXCEEDED"
+
+	CodeTimeout             = "TIMEOUT"
+	CodeClientClosedRequest = "CLIENT_CLOSED_REQUEST"
+
+	CodeKeyNotFound          = "KEY_NOT_FOUND"
+	CodeKeyPendingDeletion   = "KEY_PENDING_DELETION"
+	CodeKeyVersionInUse      = "KEY_VERSION_IN_USE"
+	CodeInvalidKeyReference  = "INVALID_KEY_REFERENCE"
+	CodeUnsupportedAlgorithm = "UNSUPPORTED_ALGORITHM"
+	CodeAlgorithmMismatch    = "ALGORITHM_MISMATCH"
+)
+
+// e.g. 404 -> 'NOT_FOUND'
+func DefaultErrorCode(status int) string {
+	text := statusText(status)
+	if text == "" {
+		return "UNKNOWN_ERROR"
+	}
+	return strings.ToUpper(strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text))
+}
+
+// http.StatusText, plus the non-standard statuses we send
+func statusText(status int) string {
+	if status == StatusClientClosedRequest {
+		return "Client Closed Request"
+	}
+	return http.StatusText(status)
+}
@@ -1,14 +1,21 @@
 package errors
 
 import (
+	"context"
 	"errors"
 	"fmt"
 )
 
+// Not in net/http, nginx's status for requests the client gave up on. Nobody reads the response,
+// it's for the logs and metrics.
+const StatusClientClosedRequest = 499
+
 type AppError struct {
 	Err     error
 	Message string
 	Code    int
+	// Stable and machine readable, e.g. 'KEY_NOT_FOUND', derived from Code if empty
+	ErrorCode string
 }
 
 func NewAppError(err error, msg string, code int) *AppError {
@@ -23,8 +30,14 @@ func (e *AppError) Error() string {
 	return e.Err.Error()
 }
 
+func (e *AppError) WithErrorCode(code string) *AppError {
+	e.ErrorCode = code
+	return e
+}
+
 var ErrNoRowsAffected = errors.New("no rows affected")
 var ErrInvalidToken = errors.New("invalid token")
+var ErrTokenExpired = fmt.Errorf("%w: expired", ErrInvalidToken)
 var ErrRepoEncryption = errors.New("database encryption wrapper failed")
 
 func WrapError(err error, data map[string]interface{}) error {
@@ -40,9 +53,35 @@ func NewInternalServerError(err error) *AppError {
 }
 
 func NewInvalidBodyError(err error) *AppError {
-	return NewAppError(err, "Invalid request body", 400)
+	return NewAppError(err, "Invalid request body", 400).WithErrorCode(CodeInvalidBody)
 }
 
 func NewMissingCredentialsError(err error) *AppError {
-	return NewAppError(err, "Missing credentials", 400)
+	return NewAppError(err, "Missing credentials", 400).WithErrorCode(CodeMissingCredentials)
+}
+
+func NewTimeoutError(err error) *AppError {
+	return NewAppError(err, "Request timed out", 503).WithErrorCode(CodeTimeout)
+}
+
+func NewClientClosedError(err error) *AppError {
+	return NewAppError(err, "Client closed request", StatusClientClosedRequest).WithErrorCode(CodeClientClosedRequest)
+}
+
+// The error for a request whose context is done, wrapping err, nil if the context isn't done
+func FromContext(ctx context.Context, err error) *AppError {
+	ctxErr := ctx.Err()
+	if ctxErr == nil {
+		return nil
+	}
+	if err == nil {
+		err = ctxErr
+	} else if !errors.Is(err, ctxErr) {
+		err = fmt.Errorf("%w: %w", ctxErr, err)
+	}
+
+	if errors.Is(ctxErr, context.DeadlineExceeded) {
+		return NewTimeoutError(err)
+	}
+	return NewClientClosedError(err)
 }
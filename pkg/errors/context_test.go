@@ -1,9 +1,11 @@
 package errors
 
 import (
+	"context"
 	"errors"
 	"kms/internal/test"
 	"testing"
+	"time"
 )
 
 func TestWrapError(t *testing.T) {
@@ -65,3 +67,26 @@ func TestNewMissingCredentialsError(t *testing.T) {
 		t.Errorf("expected 'Missing credentials', got %s", err.Message)
 	}
 }
+
+func TestFromContext(t *testing.T) {
+	if err := FromContext(context.Background(), errors.New("boom")); err != nil {
+		t.Errorf("expected nil for a live context, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := FromContext(ctx, errors.New("boom"))
+	if err.Code != 499 || err.ErrorCode != CodeClientClosedRequest {
+		t.Errorf("expected 499 CLIENT_CLOSED_REQUEST, got %d %s", err.Code, err.ErrorCode)
+	}
+	if !errors.Is(err.Err, context.Canceled) || err.Err.Error() != "context canceled: boom" {
+		t.Errorf("expected wrapped error, got %v", err.Err)
+	}
+
+	ctx, cancel = context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+	err = FromContext(ctx, context.DeadlineExceeded)
+	if err.Code != 503 || err.ErrorCode != CodeTimeout || err.Err != context.DeadlineExceeded {
+		t.Errorf("expected 503 TIMEOUT, got %d %s %v", err.Code, err.ErrorCode, err.Err)
+	}
+}
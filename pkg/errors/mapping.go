@@ -1,6 +1,7 @@
 package errors
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 
@@ -9,6 +10,14 @@ import (
 )
 
 func MapRepoErr(err error) *AppError {
+	// the request's deadline passed or the client went away while the query ran
+	if errors.Is(err, context.DeadlineExceeded) {
+		return NewTimeoutError(err)
+	}
+	if errors.Is(err, context.Canceled) {
+		return NewClientClosedError(err)
+	}
+
 	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, ErrNoRowsAffected) {
 		return NewAppError(err, "Entity not found", 404)
 	}
@@ -16,13 +25,15 @@ func MapRepoErr(err error) *AppError {
 	if pqErr, ok := err.(*pq.Error); ok {
 		switch pqErr.Code {
 		case "23505": // Unique constraint
-			return NewAppError(err, "Resource already exists", 409)
+			return NewAppError(err, "Resource already exists", 409).WithErrorCode(CodeAlreadyExists)
 		case "23503": // FK violation
 			return NewAppError(err, "Invalid foreign key", 400)
 		case "23502": // Not null violation
 			return NewAppError(err, "Missing required value", 400)
 		case "22001": // Input too long
 			return NewAppError(err, "Value too long", 400)
+		case "57014": // Query canceled, by statement_timeout or a cancelled context
+			return NewTimeoutError(err)
 		case "42703", "42P01", "42601": // Undefined column, undefined table, SQL syntax
 			return NewAppError(err, "Internal server error", 500)
 		}
@@ -33,15 +44,18 @@ func MapRepoErr(err error) *AppError {
 
 func MapHashErr(err error) *AppError {
 	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-		return NewAppError(err, "Incorrect clientname or password", 401)
+		return NewAppError(err, "Incorrect clientname or password", 401).WithErrorCode(CodeInvalidCredentials)
 	}
 
 	return NewInternalServerError(err)
 }
 
 func MapVerifyTokenErr(err error) *AppError {
+	if errors.Is(err, ErrTokenExpired) {
+		return NewAppError(err, "Unauthorized", 401).WithErrorCode(CodeTokenExpired)
+	}
 	if errors.Is(err, ErrInvalidToken) {
-		return NewAppError(err, "Unauthorized", 401)
+		return NewAppError(err, "Unauthorized", 401).WithErrorCode(CodeTokenInvalid)
 	}
 
 	return NewInternalServerError(err)
@@ -1,8 +1,10 @@
 package errors
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/lib/pq"
@@ -25,6 +27,10 @@ func TestMapRepoErr(t *testing.T) {
 		{"undefined column", &pq.Error{Code: "42703"}, 500, "Internal server error"},
 		{"undefined table", &pq.Error{Code: "42P01"}, 500, "Internal server error"},
 		{"syntax error", &pq.Error{Code: "42601"}, 500, "Internal server error"},
+		{"query canceled", &pq.Error{Code: "57014"}, 503, "Request timed out"},
+		{"deadline exceeded", context.DeadlineExceeded, 503, "Request timed out"},
+		{"wrapped deadline exceeded", fmt.Errorf("query: %w", context.DeadlineExceeded), 503, "Request timed out"},
+		{"canceled", context.Canceled, 499, "Client closed request"},
 		{"other error", errors.New("boom"), 500, "Internal server error"},
 	}
 
@@ -79,15 +85,20 @@ func TestMapVerifyTokenErr(t *testing.T) {
 		input       error
 		wantCode    int
 		wantMessage string
+		wantErrCode string
 	}{
-		{"invalid token", ErrInvalidToken, 401, "Unauthorized"},
-		{"other error", errors.New("boom"), 500, "Internal server error"},
+		{"invalid token", ErrInvalidToken, 401, "Unauthorized", CodeTokenInvalid},
+		{"expired token", WrapError(ErrTokenExpired, map[string]any{"msg": "TTL has passed"}), 401, "Unauthorized", CodeTokenExpired},
+		{"other error", errors.New("boom"), 500, "Internal server error", ""},
 	}
 
 	for _, tc := range tests {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
 			appErr := MapVerifyTokenErr(tc.input)
+			if appErr.ErrorCode != tc.wantErrCode {
+				t.Errorf("MapVerifyTokenErr(%v).ErrorCode = %q; want %q", tc.input, appErr.ErrorCode, tc.wantErrCode)
+			}
 			if appErr.Code != tc.wantCode {
 				t.Errorf("MapVerifyTokenErr(%v).Code = %d; want %d", tc.input, appErr.Code, tc.wantCode)
 			}
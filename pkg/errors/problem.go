@@ -0,0 +1,56 @@
+package errors
+
+import (
+	"fmt"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// RFC 7807 error response body, Code and RequestId are extension members
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestId string `json:"requestId,omitempty"`
+}
+
+// ErrorCode, or the one derived from the status
+func (e *AppError) CodeOrDefault() string {
+	if e.ErrorCode == "" {
+		return DefaultErrorCode(e.Code)
+	}
+	return e.ErrorCode
+}
+
+// instance is the request path, Err is left out, it's only for the logs
+func (e *AppError) Problem(instance, requestId string) *Problem {
+	return &Problem{
+		Type:      "about:blank",
+		Title:     statusText(e.Code),
+		Status:    e.Code,
+		Detail:    e.Message,
+		Instance:  instance,
+		Code:      e.CodeOrDefault(),
+		RequestId: requestId,
+	}
+}
+
+// For clients printing a decoded problem
+func (p *Problem) Error() string {
+	msg := p.Detail
+	if msg == "" {
+		msg = p.Title
+	}
+	if p.Code != "" {
+		msg = fmt.Sprintf("%s (%d %s)", msg, p.Status, p.Code)
+	} else {
+		msg = fmt.Sprintf("%s (%d)", msg, p.Status)
+	}
+	if p.RequestId != "" {
+		msg += ", request " + p.RequestId
+	}
+	return msg
+}
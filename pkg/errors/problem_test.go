@@ -0,0 +1,56 @@
+package errors
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDefaultErrorCode(t *testing.T) {
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{404, "NOT_FOUND"},
+		{405, "METHOD_NOT_ALLOWED"},
+		{418, "IM_A_TEAPOT"},
+		{499, "CLIENT_CLOSED_REQUEST"},
+		{500, "INTERNAL_SERVER_ERROR"},
+		{599, "UNKNOWN_ERROR"},
+	}
+
+	for _, tc := range tests {
+		if got := DefaultErrorCode(tc.status); got != tc.want {
+			t.Errorf("DefaultErrorCode(%d) = %q; want %q", tc.status, got, tc.want)
+		}
+	}
+}
+
+func TestAppError_Problem(t *testing.T) {
+	problem := NewAppError(errors.New("secret detail"), "Daily quota exceeded", 429).
+		WithErrorCode(CodeQuotaExceeded).
+		Problem("/v1/keys/ref/latest", "req-1")
+
+	want := Problem{
+		Type:      "about:blank",
+		Title:     "Too Many Requests",
+		Status:    429,
+		Detail:    "Daily quota exceeded",
+		Instance:  "/v1/keys/ref/latest",
+		Code:      CodeQuotaExceeded,
+		RequestId: "req-1",
+	}
+	if *problem != want {
+		t.Errorf("Problem() = %+v; want %+v", *problem, want)
+	}
+
+	if got := NewInternalServerError(errors.New("boom")).Problem("", "").Code; got != "INTERNAL_SERVER_ERROR" {
+		t.Errorf("expected default code INTERNAL_SERVER_ERROR, got %q", got)
+	}
+}
+
+func TestProblem_Error(t *testing.T) {
+	problem := &Problem{Title: "Not Found", Status: 404, Detail: "Entity not found", Code: CodeKeyNotFound, RequestId: "req-1"}
+	if got, want := problem.Error(), "Entity not found (404 KEY_NOT_FOUND), request req-1"; got != want {
+		t.Errorf("Error() = %q; want %q", got, want)
+	}
+}
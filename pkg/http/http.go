@@ -23,3 +23,13 @@ func WriteStatus(w http.ResponseWriter, status int) *kmsErrors.AppError {
 	w.WriteHeader(status)
 	return nil
 }
+
+// Error responses, not escaped as HTML since clients print the detail
+func WriteProblem(w http.ResponseWriter, problem *kmsErrors.Problem) error {
+	w.Header().Set("Content-Type", kmsErrors.ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(problem)
+}
@@ -1,9 +1,12 @@
 package sdk
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"kms/pkg/tracing"
 	"net/http"
 	"os"
 	"strings"
@@ -17,35 +20,62 @@ type Client struct {
 	pass string
 	http *http.Client
 
+	useCert bool
+	apiKey  string
+	// re-read on every login, projected service account tokens are rotated
+	oidcTokenFile string
+
 	mu        sync.RWMutex
 	token     string
 	expiresAt time.Time
 }
 
-var ErrMissingConfig = errors.New("missing configuration: KMS_BASE_URL, KMS_USER, KMS_PASS must be set")
+var ErrMissingConfig = errors.New("missing configuration: KMS_BASE_URL, and KMS_API_KEY, KMS_OIDC_TOKEN_FILE, KMS_USER, KMS_PASS or KMS_CLIENT_CERT, KMS_CLIENT_KEY must be set")
 
 func NewClient() (*Client, error) {
 	base := os.Getenv("KMS_BASE_URL")
 	user := os.Getenv("KMS_USER")
 	pass := os.Getenv("KMS_PASS")
-	if base == "" || user == "" || pass == "" {
+	certFile := os.Getenv("KMS_CLIENT_CERT")
+	keyFile := os.Getenv("KMS_CLIENT_KEY")
+	apiKey := os.Getenv("KMS_API_KEY")
+	oidcTokenFile := os.Getenv("KMS_OIDC_TOKEN_FILE")
+	useCert := certFile != "" && keyFile != ""
+	if base == "" || (apiKey == "" && oidcTokenFile == "" && !useCert && (user == "" || pass == "")) {
 		return nil, ErrMissingConfig
 	}
 
 	// Allow skipping TLS verification for local testing
 	// (not recommended for production use)
-	var tr *http.Transport
+	tlsCfg := &tls.Config{}
 	skipVerify := os.Getenv("KMS_INSECURE_SKIP_VERIFY") == "true"
 	if skipVerify {
+		tlsCfg.InsecureSkipVerify = true
+	}
+
+	// Log in with a client certificate (mTLS) instead of a password
+	if useCert {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	var tr *http.Transport
+	if skipVerify || useCert {
 		tr = &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			TLSClientConfig: tlsCfg,
 		}
 	}
 
 	return &Client{
-		base: strings.TrimRight(base, "/"),
-		user: user,
-		pass: pass,
+		base:          strings.TrimRight(base, "/"),
+		user:          user,
+		pass:          pass,
+		useCert:       useCert,
+		apiKey:        apiKey,
+		oidcTokenFile: oidcTokenFile,
 		http: &http.Client{
 			Timeout:   10 * time.Second,
 			Transport: tr,
@@ -53,41 +83,85 @@ func NewClient() (*Client, error) {
 	}, nil
 }
 
-func (c *Client) tokenOrLogin() error {
+func (c *Client) tokenOrLogin(ctx context.Context) error {
 	c.mu.RLock()
 	if c.token != "" && time.Now().Before(c.expiresAt) {
+		c.mu.RUnlock()
 		return nil
 	}
 	c.mu.RUnlock()
 
-	loginBody := map[string]string{
-		"clientname": c.user,
-		"password":   c.pass,
-	}
-	bodyBytes, err := json.Marshal(loginBody)
-	if err != nil {
-		return err
-	}
+	ctx, span := tracing.StartSpan(ctx, tracing.KindClient, "POST /v1/auth/login")
+	defer span.End()
 
-	req, err := http.NewRequest("POST", c.base+"/auth/login", strings.NewReader(string(bodyBytes)))
-	if err != nil {
-		return err
+	var req *http.Request
+	var err error
+	if c.apiKey != "" {
+		// exchanged for a JWT with the key's scopes
+		req, err = http.NewRequestWithContext(ctx, "POST", c.base+"/v1/auth/login/api-key", nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "ApiKey "+c.apiKey)
+	} else if c.oidcTokenFile != "" {
+		// exchanged for a JWT of the client named after the token's subject
+		idToken, err := os.ReadFile(c.oidcTokenFile)
+		if err != nil {
+			return err
+		}
+		bodyBytes, err := json.Marshal(map[string]string{
+			"token": strings.TrimSpace(string(idToken)),
+		})
+		if err != nil {
+			return err
+		}
+
+		req, err = http.NewRequestWithContext(ctx, "POST", c.base+"/v1/auth/login/oidc", strings.NewReader(string(bodyBytes)))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+	} else if c.useCert {
+		// the certificate is sent during the TLS handshake
+		req, err = http.NewRequestWithContext(ctx, "POST", c.base+"/v1/auth/login/certificate", nil)
+		if err != nil {
+			return err
+		}
+	} else {
+		loginBody := map[string]string{
+			"clientname": c.user,
+			"password":   c.pass,
+		}
+		bodyBytes, err := json.Marshal(loginBody)
+		if err != nil {
+			return err
+		}
+
+		req, err = http.NewRequestWithContext(ctx, "POST", c.base+"/v1/auth/login", strings.NewReader(string(bodyBytes)))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
 	}
-	req.Header.Set("Content-Type", "application/json")
 
+	tracing.Inject(ctx, req.Header)
 	resp, err := c.http.Do(req)
 	if err != nil {
+		span.RecordError(err)
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return errors.New("login failed: " + resp.Status)
+		err := fmt.Errorf("login failed: %w", newAPIError(resp))
+		span.RecordError(err)
+		return err
 	}
 
 	var respBody struct {
 		Token string `json:"token"`
-		Ttl   int    `json:"ttl"`
+		// ms
+		Ttl int `json:"ttl"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
 		return err
@@ -95,17 +169,17 @@ func (c *Client) tokenOrLogin() error {
 
 	c.mu.Lock()
 	c.token = respBody.Token
-	c.expiresAt = time.Now().Add(time.Duration(respBody.Ttl))
+	c.expiresAt = time.Now().Add(time.Duration(respBody.Ttl) * time.Millisecond)
 	c.mu.Unlock()
 
 	return nil
 }
 
-func (c *Client) forceRefresh() error {
+func (c *Client) forceRefresh(ctx context.Context) error {
 	c.mu.Lock()
 	c.token = ""
 	c.expiresAt = time.Time{}
 	c.mu.Unlock()
 
-	return c.tokenOrLogin()
+	return c.tokenOrLogin(ctx)
 }
@@ -1,9 +1,11 @@
 package sdk
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -69,7 +71,7 @@ func TestNewClient(t *testing.T) {
 
 func TestTokenOrLogin(t *testing.T) {
 	rt := roundTripFunc(func(req *http.Request) *http.Response {
-		if req.URL.Path == "/auth/login" {
+		if req.URL.Path == "/v1/auth/login" {
 			return &http.Response{
 				StatusCode: 200,
 				Body:       io.NopCloser(strings.NewReader(`{"token":"fake-token","ttl":3600}`)),
@@ -88,7 +90,7 @@ func TestTokenOrLogin(t *testing.T) {
 		http: client,
 	}
 
-	err := c.tokenOrLogin()
+	err := c.tokenOrLogin(context.Background())
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -100,7 +102,7 @@ func TestTokenOrLogin(t *testing.T) {
 	}
 
 	// Call again to test cached token path
-	err = c.tokenOrLogin()
+	err = c.tokenOrLogin(context.Background())
 	if err != nil {
 		t.Fatalf("expected no error on cached token, got %v", err)
 	}
@@ -108,7 +110,7 @@ func TestTokenOrLogin(t *testing.T) {
 
 func TestTokenOrLogin_Fail(t *testing.T) {
 	rt := roundTripFunc(func(req *http.Request) *http.Response {
-		if req.URL.Path == "/auth/login" {
+		if req.URL.Path == "/v1/auth/login" {
 			return &http.Response{
 				StatusCode: 401,
 				Body:       io.NopCloser(strings.NewReader("Unauthorized")),
@@ -127,7 +129,7 @@ func TestTokenOrLogin_Fail(t *testing.T) {
 		http: client,
 	}
 
-	err := c.tokenOrLogin()
+	err := c.tokenOrLogin(context.Background())
 	if err == nil {
 		t.Fatalf("expected error, got none")
 	}
@@ -136,7 +138,7 @@ func TestTokenOrLogin_Fail(t *testing.T) {
 func TestForceRefresh(t *testing.T) {
 	callCount := 0
 	rt := roundTripFunc(func(req *http.Request) *http.Response {
-		if req.URL.Path == "/auth/login" {
+		if req.URL.Path == "/v1/auth/login" {
 			callCount++
 			return &http.Response{
 				StatusCode: 200,
@@ -156,7 +158,7 @@ func TestForceRefresh(t *testing.T) {
 		http: client,
 	}
 
-	err := c.tokenOrLogin()
+	err := c.tokenOrLogin(context.Background())
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -164,7 +166,7 @@ func TestForceRefresh(t *testing.T) {
 		t.Errorf("expected 1 call to login, got %d", callCount)
 	}
 
-	err = c.forceRefresh()
+	err = c.forceRefresh(context.Background())
 	if err != nil {
 		t.Fatalf("expected no error on force refresh, got %v", err)
 	}
@@ -172,3 +174,90 @@ func TestForceRefresh(t *testing.T) {
 		t.Errorf("expected 2 calls to login after force refresh, got %d", callCount)
 	}
 }
+
+func TestTokenOrLogin_Certificate(t *testing.T) {
+	rt := roundTripFunc(func(req *http.Request) *http.Response {
+		if req.URL.Path == "/v1/auth/login/certificate" {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(`{"token":"cert-token","ttl":3600}`)),
+				Header:     make(http.Header),
+			}
+		}
+		t.Fatalf("unexpected path: %s", req.URL.Path)
+		return nil
+	})
+
+	c := &Client{
+		base:    "http://fake",
+		useCert: true,
+		http:    &http.Client{Transport: rt},
+	}
+
+	if err := c.tokenOrLogin(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if c.token != "cert-token" {
+		t.Errorf("expected token to be cert-token, got %s", c.token)
+	}
+}
+
+func TestTokenOrLogin_ApiKey(t *testing.T) {
+	rt := roundTripFunc(func(req *http.Request) *http.Response {
+		if req.URL.Path == "/v1/auth/login/api-key" && req.Header.Get("Authorization") == "ApiKey kms_0123456789ab_secret" {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(`{"token":"api-key-token","ttl":3600}`)),
+				Header:     make(http.Header),
+			}
+		}
+		t.Fatalf("unexpected request: %s", req.URL.Path)
+		return nil
+	})
+
+	c := &Client{
+		base:   "http://fake",
+		apiKey: "kms_0123456789ab_secret",
+		http:   &http.Client{Transport: rt},
+	}
+
+	if err := c.tokenOrLogin(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if c.token != "api-key-token" {
+		t.Errorf("expected token to be api-key-token, got %s", c.token)
+	}
+}
+
+func TestTokenOrLogin_OIDC(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("id-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	rt := roundTripFunc(func(req *http.Request) *http.Response {
+		body, _ := io.ReadAll(req.Body)
+		if req.URL.Path == "/v1/auth/login/oidc" && string(body) == `{"token":"id-token"}` {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(`{"token":"oidc-token","ttl":3600}`)),
+				Header:     make(http.Header),
+			}
+		}
+		t.Fatalf("unexpected request: %s %s", req.URL.Path, body)
+		return nil
+	})
+
+	c := &Client{
+		base:          "http://fake",
+		oidcTokenFile: tokenFile,
+		http:          &http.Client{Transport: rt},
+	}
+
+	if err := c.tokenOrLogin(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if c.token != "oidc-token" {
+		t.Errorf("expected token to be oidc-token, got %s", c.token)
+	}
+}
@@ -0,0 +1,87 @@
+package sdk
+
+import (
+	b64 "encoding/base64"
+	"kms/pkg/encryption"
+)
+
+// Servers that predate per-key algorithms only hand out AES-256-GCM keys
+func (k *Key) algorithm() string {
+	if k.Algorithm == "" {
+		return encryption.DefaultAlgorithm
+	}
+	return k.Algorithm
+}
+
+func (k *Key) bytes() ([]byte, error) {
+	return b64.RawURLEncoding.DecodeString(k.DEK)
+}
+
+// Encrypt with the key's (AEAD) algorithm, output is nonce || ciphertext + tag
+func (k *Key) Encrypt(plaintext []byte) ([]byte, error) {
+	key, err := k.bytes()
+	if err != nil {
+		return nil, err
+	}
+	return encryption.EncryptWithAlgorithm(k.algorithm(), plaintext, key)
+}
+
+func (k *Key) Decrypt(ciphertext []byte) ([]byte, error) {
+	key, err := k.bytes()
+	if err != nil {
+		return nil, err
+	}
+	return encryption.DecryptWithAlgorithm(k.algorithm(), ciphertext, key)
+}
+
+// Like Encrypt, but the ciphertext only decrypts with the same encryption context
+func (k *Key) EncryptWithContext(plaintext []byte, ctx encryption.EncryptionContext) ([]byte, error) {
+	key, err := k.bytes()
+	if err != nil {
+		return nil, err
+	}
+	return encryption.EncryptWithAlgorithmAAD(k.algorithm(), plaintext, key, ctx.AAD())
+}
+
+func (k *Key) DecryptWithContext(ciphertext []byte, ctx encryption.EncryptionContext) ([]byte, error) {
+	key, err := k.bytes()
+	if err != nil {
+		return nil, err
+	}
+	return encryption.DecryptWithAlgorithmAAD(k.algorithm(), ciphertext, key, ctx.AAD())
+}
+
+// Only for HMAC keys
+func (k *Key) Sign(message []byte) ([]byte, error) {
+	key, err := k.bytes()
+	if err != nil {
+		return nil, err
+	}
+	return encryption.Sign(k.algorithm(), message, key)
+}
+
+func (k *Key) Verify(message, signature []byte) (bool, error) {
+	key, err := k.bytes()
+	if err != nil {
+		return false, err
+	}
+	return encryption.Verify(k.algorithm(), message, signature, key)
+}
+
+// Encrypts with the latest version of the key
+func (b *KeyBundle) Encrypt(plaintext []byte) ([]byte, error) {
+	return b.EncryptWith.Encrypt(plaintext)
+}
+
+// Decrypts with the requested version of the key
+func (b *KeyBundle) Decrypt(ciphertext []byte) ([]byte, error) {
+	return b.DecryptWith.Decrypt(ciphertext)
+}
+
+func (b *KeyBundle) EncryptWithContext(plaintext []byte, ctx encryption.EncryptionContext) ([]byte, error) {
+	return b.EncryptWith.EncryptWithContext(plaintext, ctx)
+}
+
+func (b *KeyBundle) DecryptWithContext(ciphertext []byte, ctx encryption.EncryptionContext) ([]byte, error) {
+	return b.DecryptWith.DecryptWithContext(ciphertext, ctx)
+}
@@ -0,0 +1,119 @@
+package sdk
+
+import (
+	"bytes"
+	b64 "encoding/base64"
+	"kms/pkg/encryption"
+	"testing"
+)
+
+func newTestKey(t *testing.T, alg string, version int) *Key {
+	size, err := encryption.KeySize(alg)
+	if err != nil {
+		t.Fatalf("key size failed: %v", err)
+	}
+	key, err := encryption.GenerateKey(size)
+	if err != nil {
+		t.Fatalf("generate key failed: %v", err)
+	}
+	return &Key{DEK: b64.RawURLEncoding.EncodeToString(key), Version: version, Algorithm: alg}
+}
+
+func TestKeyBundle_EncryptDecrypt(t *testing.T) {
+	for _, alg := range []string{encryption.AlgAES128GCM, encryption.AlgChaCha20Poly1305, encryption.AlgXChaCha20Poly1305} {
+		key := newTestKey(t, alg, 1)
+		bundle := &KeyBundle{DecryptWith: key, EncryptWith: key}
+
+		ciphertext, err := bundle.Encrypt([]byte("secret"))
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", alg, err)
+		}
+		plaintext, err := bundle.Decrypt(ciphertext)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", alg, err)
+		}
+		if !bytes.Equal(plaintext, []byte("secret")) {
+			t.Errorf("%s: roundtrip failed", alg)
+		}
+	}
+}
+
+func TestKey_DefaultAlgorithm(t *testing.T) {
+	// keys from servers without per-key algorithms
+	key := newTestKey(t, encryption.AlgAES256GCM, 1)
+	key.Algorithm = ""
+
+	ciphertext, err := key.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	_, err = encryption.Decrypt(ciphertext, mustDecode(t, key.DEK))
+	if err != nil {
+		t.Errorf("expected AES-256-GCM ciphertext, got %v", err)
+	}
+}
+
+func mustDecode(t *testing.T, s string) []byte {
+	b, err := b64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	return b
+}
+
+func TestKey_EncryptWithHMACKey(t *testing.T) {
+	key := newTestKey(t, encryption.AlgHMACSHA512, 1)
+	if _, err := key.Encrypt([]byte("secret")); err == nil {
+		t.Errorf("expected error when encrypting with HMAC key")
+	}
+}
+
+func TestKey_SignVerify(t *testing.T) {
+	key := newTestKey(t, encryption.AlgHMACSHA512, 1)
+
+	signature, err := key.Sign([]byte("message"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	ok, err := key.Verify([]byte("message"), signature)
+	if err != nil || !ok {
+		t.Errorf("expected valid signature, got %v (%v)", ok, err)
+	}
+}
+
+func TestKey_EncryptWithContext(t *testing.T) {
+	key := newTestKey(t, encryption.AlgChaCha20Poly1305, 1)
+	ctx := encryption.EncryptionContext{"table": "users", "id": "1"}
+
+	ciphertext, err := key.EncryptWithContext([]byte("secret"), ctx)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	plaintext, err := key.DecryptWithContext(ciphertext, ctx)
+	if err != nil || !bytes.Equal(plaintext, []byte("secret")) {
+		t.Fatalf("roundtrip failed: %v", err)
+	}
+	if _, err := key.DecryptWithContext(ciphertext, ctx.With("id", "2")); err == nil {
+		t.Errorf("expected other context to fail")
+	}
+	if _, err := key.Decrypt(ciphertext); err == nil {
+		t.Errorf("expected missing context to fail")
+	}
+}
+
+func TestKey_EncryptDeterministic(t *testing.T) {
+	key := newTestKey(t, encryption.AlgAES256SIV, 1)
+
+	a, err := key.Encrypt([]byte("alice@example.com"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	b, _ := key.Encrypt([]byte("alice@example.com"))
+	if !bytes.Equal(a, b) {
+		t.Errorf("expected equal ciphertexts for lookups")
+	}
+	plaintext, err := key.Decrypt(a)
+	if err != nil || !bytes.Equal(plaintext, []byte("alice@example.com")) {
+		t.Errorf("roundtrip failed: %v", err)
+	}
+}
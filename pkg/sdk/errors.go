@@ -0,0 +1,86 @@
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Match an *APIError with errors.Is, e.g. errors.Is(err, sdk.ErrKeyNotFound)
+var (
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrTokenExpired       = errors.New("token expired")
+	ErrMfaRequired        = errors.New("MFA required")
+	ErrForbidden          = errors.New("forbidden")
+	ErrNotFound           = errors.New("not found")
+	ErrKeyNotFound        = errors.New("key not found")
+	ErrKeyPendingDeletion = errors.New("key pending deletion")
+	ErrRateLimited        = errors.New("rate limited")
+	ErrQuotaExceeded      = errors.New("daily quota exceeded")
+)
+
+// Error codes of the KMS, more specific than the status
+var codeErrors = map[string]error{
+	"TOKEN_EXPIRED":        ErrTokenExpired,
+	"MFA_REQUIRED":         ErrMfaRequired,
+	"KEY_NOT_FOUND":        ErrKeyNotFound,
+	"KEY_PENDING_DELETION": ErrKeyPendingDeletion,
+	"QUOTA_EXCEEDED":       ErrQuotaExceeded,
+}
+
+var statusErrors = map[int]error{
+	http.StatusUnauthorized:    ErrUnauthorized,
+	http.StatusForbidden:       ErrForbidden,
+	http.StatusNotFound:        ErrNotFound,
+	http.StatusTooManyRequests: ErrRateLimited,
+}
+
+// Error response of the KMS (RFC 7807 problem), Code is empty for servers answering with plain text
+type APIError struct {
+	Status    int    `json:"status"`
+	Code      string `json:"code"`
+	Detail    string `json:"detail"`
+	RequestId string `json:"requestId"`
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s (%d", e.Detail, e.Status)
+	if e.Code != "" {
+		msg += " " + e.Code
+	}
+	msg += ")"
+	if e.RequestId != "" {
+		msg += ", request " + e.RequestId
+	}
+	return msg
+}
+
+// e.g. a KEY_NOT_FOUND error is both ErrKeyNotFound and ErrNotFound
+func (e *APIError) Is(target error) bool {
+	return codeErrors[e.Code] == target || statusErrors[e.Status] == target
+}
+
+func newAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{Status: resp.StatusCode}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		apiErr.Detail = resp.Status
+		return apiErr
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/problem+json") {
+		if err := json.Unmarshal(body, apiErr); err == nil {
+			apiErr.Status = resp.StatusCode
+			return apiErr
+		}
+	}
+
+	apiErr.Detail = strings.TrimSpace(string(body))
+	if apiErr.Detail == "" {
+		apiErr.Detail = resp.Status
+	}
+	return apiErr
+}
@@ -0,0 +1,75 @@
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNewAPIError(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		want        APIError
+		is          []error
+		isNot       []error
+	}{
+		{
+			"expired token", 401, "application/problem+json",
+			`{"status":401,"detail":"Unauthorized","code":"TOKEN_EXPIRED","requestId":"req-1"}`,
+			APIError{Status: 401, Code: "TOKEN_EXPIRED", Detail: "Unauthorized", RequestId: "req-1"},
+			[]error{ErrTokenExpired, ErrUnauthorized},
+			[]error{ErrForbidden},
+		},
+		{
+			"quota", 429, "application/problem+json",
+			`{"status":429,"detail":"Daily quota exceeded","code":"QUOTA_EXCEEDED"}`,
+			APIError{Status: 429, Code: "QUOTA_EXCEEDED", Detail: "Daily quota exceeded"},
+			[]error{ErrQuotaExceeded, ErrRateLimited},
+			nil,
+		},
+		{
+			"plain text", 404, "text/plain; charset=utf-8", "Entity not found\n",
+			APIError{Status: 404, Detail: "Entity not found"},
+			[]error{ErrNotFound},
+			[]error{ErrKeyNotFound},
+		},
+		{
+			"empty body", 500, "", "",
+			APIError{Status: 500, Detail: "500 Internal Server Error"},
+			nil,
+			[]error{ErrNotFound, ErrUnauthorized},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := make(http.Header)
+			header.Set("Content-Type", tt.contentType)
+			apiErr := newAPIError(&http.Response{
+				Status:     fmt.Sprintf("%d %s", tt.status, http.StatusText(tt.status)),
+				StatusCode: tt.status,
+				Header:     header,
+				Body:       io.NopCloser(strings.NewReader(tt.body)),
+			})
+			if *apiErr != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, *apiErr)
+			}
+			for _, target := range tt.is {
+				if !errors.Is(apiErr, target) {
+					t.Errorf("expected %v to be %v", apiErr, target)
+				}
+			}
+			for _, target := range tt.isNot {
+				if errors.Is(apiErr, target) {
+					t.Errorf("expected %v not to be %v", apiErr, target)
+				}
+			}
+		})
+	}
+}
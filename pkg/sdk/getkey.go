@@ -1,9 +1,10 @@
 package sdk
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"kms/pkg/tracing"
 	"net/http"
 	"time"
 )
@@ -17,20 +18,33 @@ type Key struct {
 	DEK       string    `json:"dek"`
 	Version   int       `json:"version"`
 	Encoding  string    `json:"encoding"`
+	Algorithm string    `json:"algorithm"`
 	ExpiresAt time.Time `json:"expiresAt"`
 }
 
 func (c *Client) GetKey(keyReference string, version int) (*KeyBundle, error) {
-	if err := c.tokenOrLogin(); err != nil {
+	return c.GetKeyContext(context.Background(), keyReference, version)
+}
+
+// Like GetKey, the requests are cancelled with ctx and continue the trace of the span in ctx (see pkg/tracing)
+func (c *Client) GetKeyContext(ctx context.Context, keyReference string, version int) (_ *KeyBundle, err error) {
+	ctx, span := tracing.StartSpan(ctx, tracing.KindClient, "GET /v1/keys/{keyReference}/{version}")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if err := c.tokenOrLogin(ctx); err != nil {
 		return nil, err
 	}
 
-	url := c.base + fmt.Sprintf("/keys/%s/%d", keyReference, version)
-	req, err := http.NewRequest("GET", url, nil)
+	url := c.base + fmt.Sprintf("/v1/keys/%s/%d", keyReference, version)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Authorization", "Bearer "+c.token)
+	tracing.Inject(ctx, req.Header)
 
 	resp, err := c.http.Do(req)
 	if err != nil {
@@ -40,7 +54,8 @@ func (c *Client) GetKey(keyReference string, version int) (*KeyBundle, error) {
 
 	if resp.StatusCode == http.StatusUnauthorized {
 		// Token might be expired, refresh and retry once
-		if err := c.forceRefresh(); err != nil {
+		resp.Body.Close()
+		if err := c.forceRefresh(ctx); err != nil {
 			return nil, err
 		}
 		req.Header.Set("Authorization", "Bearer "+c.token)
@@ -48,10 +63,11 @@ func (c *Client) GetKey(keyReference string, version int) (*KeyBundle, error) {
 		if err != nil {
 			return nil, err
 		}
+		defer resp.Body.Close()
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New("failed to get key: " + resp.Status)
+		return nil, fmt.Errorf("failed to get key: %w", newAPIError(resp))
 	}
 
 	var bundle KeyBundle
@@ -1,7 +1,10 @@
 package sdk
 
 import (
+	"context"
+	"errors"
 	"io"
+	"kms/pkg/tracing"
 	"net/http"
 	"strings"
 	"testing"
@@ -24,14 +27,14 @@ func TestGetKey(t *testing.T) {
 	}`
 	fakeLoginResp := `{"token":"fake-token","ttl":3600}`
 	rt := roundTripFunc(func(req *http.Request) *http.Response {
-		if req.URL.Path == "/keys/example-key/1" {
+		if req.URL.Path == "/v1/keys/example-key/1" {
 			return &http.Response{
 				StatusCode: 200,
 				Body:       io.NopCloser(strings.NewReader(fakeKeyResp)),
 				Header:     make(http.Header),
 			}
 		}
-		if req.URL.Path == "/auth/login" {
+		if req.URL.Path == "/v1/auth/login" {
 			return &http.Response{
 				StatusCode: 200,
 				Body:       io.NopCloser(strings.NewReader(fakeLoginResp)),
@@ -87,7 +90,7 @@ func TestGetKey_Refresh(t *testing.T) {
 	fakeLoginResp := `{"token":"fake-token","ttl":3600}`
 	var firstCall = true
 	rt := roundTripFunc(func(req *http.Request) *http.Response {
-		if req.URL.Path == "/keys/example-key/1" {
+		if req.URL.Path == "/v1/keys/example-key/1" {
 			if firstCall {
 				firstCall = false
 				return &http.Response{
@@ -102,7 +105,7 @@ func TestGetKey_Refresh(t *testing.T) {
 				Header:     make(http.Header),
 			}
 		}
-		if req.URL.Path == "/auth/login" {
+		if req.URL.Path == "/v1/auth/login" {
 			return &http.Response{
 				StatusCode: 200,
 				Body:       io.NopCloser(strings.NewReader(fakeLoginResp)),
@@ -143,7 +146,7 @@ func TestGetKey_Refresh(t *testing.T) {
 func TestGetKey_RefreshOnce(t *testing.T) {
 	var callCount = 0
 	rt := roundTripFunc(func(req *http.Request) *http.Response {
-		if req.URL.Path == "/keys/example-key/1" {
+		if req.URL.Path == "/v1/keys/example-key/1" {
 			callCount++
 			return &http.Response{
 				StatusCode: 401,
@@ -151,7 +154,7 @@ func TestGetKey_RefreshOnce(t *testing.T) {
 				Header:     make(http.Header),
 			}
 		}
-		if req.URL.Path == "/auth/login" {
+		if req.URL.Path == "/v1/auth/login" {
 			return &http.Response{
 				StatusCode: 200,
 				Body:       io.NopCloser(strings.NewReader(`{"token":"fake-token","ttl":3600}`)),
@@ -182,14 +185,14 @@ func TestGetKey_RefreshOnce(t *testing.T) {
 
 func TestGetKey_Fail(t *testing.T) {
 	rt := roundTripFunc(func(req *http.Request) *http.Response {
-		if req.URL.Path == "/keys/example-key/1" {
+		if req.URL.Path == "/v1/keys/example-key/1" {
 			return &http.Response{
 				StatusCode: 500,
 				Body:       io.NopCloser(strings.NewReader("Internal Server Error")),
 				Header:     make(http.Header),
 			}
 		}
-		if req.URL.Path == "/auth/login" {
+		if req.URL.Path == "/v1/auth/login" {
 			return &http.Response{
 				StatusCode: 200,
 				Body:       io.NopCloser(strings.NewReader(`{"token":"fake-token","ttl":3600}`)),
@@ -213,3 +216,81 @@ func TestGetKey_Fail(t *testing.T) {
 		t.Fatalf("expected error, got none")
 	}
 }
+
+func TestGetKey_NotFound(t *testing.T) {
+	rt := roundTripFunc(func(req *http.Request) *http.Response {
+		if req.URL.Path == "/v1/keys/example-key/1" {
+			header := make(http.Header)
+			header.Set("Content-Type", "application/problem+json")
+			return &http.Response{
+				StatusCode: 404,
+				Body: io.NopCloser(strings.NewReader(`{"type":"about:blank","title":"Not Found","status":404,` +
+					`"detail":"Entity not found","code":"KEY_NOT_FOUND","requestId":"req-1"}`)),
+				Header: header,
+			}
+		}
+		if req.URL.Path == "/v1/auth/login" {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(`{"token":"fake-token","ttl":3600}`)),
+				Header:     make(http.Header),
+			}
+		}
+		t.Fatalf("unexpected path: %s", req.URL.Path)
+		return nil
+	})
+
+	c := &Client{
+		base: "http://fake",
+		user: "test",
+		pass: "pass",
+		http: &http.Client{Transport: rt},
+	}
+
+	_, err := c.GetKey("example-key", 1)
+	if !errors.Is(err, ErrKeyNotFound) || !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.RequestId != "req-1" {
+		t.Errorf("expected APIError with request ID, got %v", err)
+	}
+}
+
+func TestGetKeyContext_Traceparent(t *testing.T) {
+	traceparents := map[string]string{}
+	rt := roundTripFunc(func(req *http.Request) *http.Response {
+		traceparents[req.URL.Path] = req.Header.Get("traceparent")
+		body := `{"token":"fake-token","ttl":3600}`
+		if req.URL.Path == "/v1/keys/example-key/1" {
+			body = `{"decryptWith":{"version":1},"encryptWith":{"version":1}}`
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Header:     make(http.Header),
+		}
+	})
+	c := &Client{
+		base: "http://fake",
+		user: "test",
+		pass: "pass",
+		http: &http.Client{Transport: rt},
+	}
+
+	ctx, span := tracing.Start(context.Background(), "caller")
+	defer span.End()
+	if _, err := c.GetKeyContext(ctx, "example-key", 1); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for _, path := range []string{"/v1/auth/login", "/v1/keys/example-key/1"} {
+		sc, err := tracing.ParseTraceparent(traceparents[path])
+		if err != nil {
+			t.Fatalf("%s: expected traceparent, got %v", path, err)
+		}
+		if sc.TraceID != span.SpanContext.TraceID || sc.SpanID == span.SpanContext.SpanID {
+			t.Errorf("%s: expected a client span in the caller's trace, got %s", path, traceparents[path])
+		}
+	}
+}
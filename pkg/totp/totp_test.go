@@ -0,0 +1,70 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B (SHA1), the 6 digit codes are the last 6 of the 8 digit ones
+func TestCode_RFC6238(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		if got := Code(secret, Step(time.Unix(tt.unix, 0))); got != tt.code {
+			t.Errorf("%d: expected %s, got %s", tt.unix, tt.code, got)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+
+	if got, ok := Verify(secret, "050471", now, 0); !ok || got != step {
+		t.Errorf("expected step %d, got %d (%v)", step, got, ok)
+	}
+	// previous step is still accepted
+	if _, ok := Verify(secret, Code(secret, step-1), now, 0); !ok {
+		t.Error("expected code of previous step to be accepted")
+	}
+	if _, ok := Verify(secret, Code(secret, step-2), now, 0); ok {
+		t.Error("expected code two steps old to be rejected")
+	}
+	// replay
+	if _, ok := Verify(secret, "050471", now, step); ok {
+		t.Error("expected used code to be rejected")
+	}
+	if _, ok := Verify(secret, "05047", now, 0); ok {
+		t.Error("expected short code to be rejected")
+	}
+}
+
+func TestSecretAndURI(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil || len(secret) != SecretSize {
+		t.Fatalf("unexpected secret %x (%v)", secret, err)
+	}
+
+	decoded, err := DecodeSecret(EncodeSecret(secret))
+	if err != nil || string(decoded) != string(secret) {
+		t.Errorf("secret does not round trip: %v", err)
+	}
+
+	uri := URI("KMS", "admin@kms.local", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/KMS:admin@kms.local?") || !strings.Contains(uri, "secret="+EncodeSecret(secret)) {
+		t.Errorf("unexpected URI %s", uri)
+	}
+}
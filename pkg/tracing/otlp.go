@@ -0,0 +1,235 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Batches spans and posts them as OTLP/HTTP JSON to '<endpoint>/v1/traces', e.g. an OpenTelemetry
+// collector at http://localhost:4318. Spans are dropped rather than blocking requests when the
+// collector can't keep up.
+type OTLPExporter struct {
+	url         string
+	serviceName string
+	client      *http.Client
+
+	batchSize     int
+	flushInterval time.Duration
+
+	queue chan *Span
+	flush chan chan error
+	done  chan struct{}
+	once  sync.Once
+
+	// Reports failed exports, the exporter has no logger
+	OnError func(err error)
+}
+
+const (
+	defaultBatchSize     = 512
+	defaultQueueSize     = 4096
+	defaultFlushInterval = 5 * time.Second
+)
+
+func NewOTLPExporter(endpoint, serviceName string) *OTLPExporter {
+	e := &OTLPExporter{
+		url:           strings.TrimRight(endpoint, "/") + "/v1/traces",
+		serviceName:   serviceName,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		batchSize:     defaultBatchSize,
+		flushInterval: defaultFlushInterval,
+		queue:         make(chan *Span, defaultQueueSize),
+		flush:         make(chan chan error),
+		done:          make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+func (e *OTLPExporter) ExportSpan(span *Span) {
+	select {
+	case e.queue <- span:
+	default:
+	}
+}
+
+func (e *OTLPExporter) run() {
+	ticker := time.NewTicker(e.flushInterval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	send := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := e.post(batch)
+		batch = nil
+		if err != nil && e.OnError != nil {
+			e.OnError(err)
+		}
+		return err
+	}
+
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) >= e.batchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case result := <-e.flush:
+			// take what was queued before the flush was requested
+			for len(e.queue) > 0 {
+				batch = append(batch, <-e.queue)
+			}
+			result <- send()
+		case <-e.done:
+			return
+		}
+	}
+}
+
+// Sends the queued spans and stops the exporter, spans ended afterwards are dropped
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	var err error
+	e.once.Do(func() {
+		result := make(chan error, 1)
+		select {
+		case e.flush <- result:
+		case <-ctx.Done():
+			err = ctx.Err()
+			close(e.done)
+			return
+		}
+		select {
+		case err = <-result:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		close(e.done)
+	})
+	return err
+}
+
+func (e *OTLPExporter) post(spans []*Span) error {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to export %d spans: %w", len(spans), err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to export %d spans: collector responded %s", len(spans), resp.Status)
+	}
+	return nil
+}
+
+// The OTLP JSON encoding, ids are hex and 64 bit integers strings
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	TraceState        string          `json:"traceState,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+// 0 unset, 2 error
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+func (e *OTLPExporter) request(spans []*Span) otlpRequest {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		s := otlpSpan{
+			TraceID:           span.SpanContext.TraceID.String(),
+			SpanID:            span.SpanContext.SpanID.String(),
+			TraceState:        span.SpanContext.TraceState,
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+		}
+		if span.Parent.IsValid() {
+			s.ParentSpanID = span.Parent.String()
+		}
+		for _, attr := range span.Attributes() {
+			s.Attributes = append(s.Attributes, otlpAttr(attr.Key, attr.Value))
+		}
+		if err := span.Err(); err != nil {
+			s.Status = otlpStatus{Code: 2, Message: err.Error()}
+		}
+		out = append(out, s)
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpAttribute{otlpAttr("service.name", e.serviceName)}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "kms/pkg/tracing"}, Spans: out}},
+	}}}
+}
+
+func otlpAttr(key string, value any) otlpAttribute {
+	var v map[string]any
+	switch value := value.(type) {
+	case bool:
+		v = map[string]any{"boolValue": value}
+	case int:
+		v = map[string]any{"intValue": strconv.Itoa(value)}
+	case int64:
+		v = map[string]any{"intValue": strconv.FormatInt(value, 10)}
+	case float64:
+		v = map[string]any{"doubleValue": value}
+	case string:
+		v = map[string]any{"stringValue": value}
+	default:
+		v = map[string]any{"stringValue": fmt.Sprint(value)}
+	}
+	return otlpAttribute{Key: key, Value: v}
+}
@@ -0,0 +1,81 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOTLPExporter(t *testing.T) {
+	received := make(chan otlpRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid body: %v", err)
+		}
+		received <- req
+	}))
+	defer server.Close()
+
+	exporter := NewOTLPExporter(server.URL+"/", "kms-test")
+	SetExporter(exporter)
+	defer SetExporter(nil)
+
+	ctx, root := StartSpan(context.Background(), KindServer, "GET /v1/keys/{keyReference}/{version}", "http.status_code", 200)
+	_, child := Start(ctx, "postgres.GetKey")
+	child.RecordError(errors.New("no rows"))
+	child.End()
+	root.End()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := exporter.Shutdown(shutdownCtx); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	req := <-received
+	resource := req.ResourceSpans[0]
+	if resource.Resource.Attributes[0].Value["stringValue"] != "kms-test" {
+		t.Errorf("expected service name, got %v", resource.Resource.Attributes)
+	}
+	spans := resource.ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	gotChild, gotRoot := spans[0], spans[1]
+	if gotChild.ParentSpanID != gotRoot.SpanID || gotChild.TraceID != root.SpanContext.TraceID.String() {
+		t.Errorf("expected child of %s, got %+v", gotRoot.SpanID, gotChild)
+	}
+	if gotChild.Status.Code != 2 || gotChild.Status.Message != "no rows" || gotRoot.Status.Code != 0 {
+		t.Errorf("unexpected status %+v and %+v", gotChild.Status, gotRoot.Status)
+	}
+	if gotRoot.Kind != KindServer || gotRoot.Attributes[0].Value["intValue"] != "200" {
+		t.Errorf("unexpected root span %+v", gotRoot)
+	}
+}
+
+func TestOTLPExporter_CollectorError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	exporter := NewOTLPExporter(server.URL, "kms-test")
+	var reported error
+	exporter.OnError = func(err error) { reported = err }
+
+	_, span := Start(context.Background(), "root")
+	span.SpanContext.Sampled = true
+	exporter.ExportSpan(span)
+
+	if err := exporter.Shutdown(context.Background()); err == nil || reported == nil {
+		t.Errorf("expected export error, got %v and %v", err, reported)
+	}
+}
@@ -0,0 +1,83 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+const flagSampled = 0x01
+
+// 'version-traceid-spanid-flags', e.g. '00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01'
+func ParseTraceparent(value string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return SpanContext{}, fmt.Errorf("invalid traceparent: expected 4 fields, got %d", len(parts))
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	// later versions may append fields, but must keep the first four
+	if len(version) != 2 || version == "ff" || (version == "00" && len(parts) != 4) {
+		return SpanContext{}, fmt.Errorf("invalid traceparent version: %q", version)
+	}
+
+	var sc SpanContext
+	if err := decodeHex(traceID, sc.TraceID[:]); err != nil || !sc.TraceID.IsValid() {
+		return SpanContext{}, fmt.Errorf("invalid trace id: %q", traceID)
+	}
+	if err := decodeHex(spanID, sc.SpanID[:]); err != nil || !sc.SpanID.IsValid() {
+		return SpanContext{}, fmt.Errorf("invalid parent id: %q", spanID)
+	}
+	var flagBytes [1]byte
+	if err := decodeHex(flags, flagBytes[:]); err != nil {
+		return SpanContext{}, fmt.Errorf("invalid trace flags: %q", flags)
+	}
+	sc.Sampled = flagBytes[0]&flagSampled != 0
+	return sc, nil
+}
+
+// Lowercase hex of exactly len(dst) bytes, as the spec requires
+func decodeHex(s string, dst []byte) error {
+	if len(s) != 2*len(dst) || strings.ToLower(s) != s {
+		return fmt.Errorf("expected %d lowercase hex characters", 2*len(dst))
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err
+}
+
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// Remote parent for the next span started from the returned context. Invalid headers are ignored, the
+// request then starts a new trace.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, err := ParseTraceparent(header.Get(TraceparentHeader))
+	if err != nil {
+		return ctx
+	}
+	sc.TraceState = header.Get(TracestateHeader)
+	return context.WithValue(ctx, remoteCtxKey{}, sc)
+}
+
+// Sets traceparent (and tracestate) to the active span, nothing if there is none
+func Inject(ctx context.Context, header http.Header) {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	header.Set(TraceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		header.Set(TracestateHeader, sc.TraceState)
+	}
+}
@@ -0,0 +1,244 @@
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// W3C trace context (https://www.w3.org/TR/trace-context/) spans, exported in the OTLP format so any
+// OpenTelemetry collector can receive them without pulling in the SDK
+
+type TraceID [16]byte
+type SpanID [8]byte
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+	// Vendor specific, passed on unchanged
+	TraceState string
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Values of the OTLP SpanKind enum
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+type Span struct {
+	Name        string
+	Kind        SpanKind
+	SpanContext SpanContext
+	// Zero for root spans
+	Parent    SpanID
+	StartTime time.Time
+	EndTime   time.Time
+
+	mu         sync.Mutex
+	attributes []Attribute
+	err        error
+	ended      bool
+}
+
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// Receives sampled spans once they end
+type Exporter interface {
+	ExportSpan(span *Span)
+}
+
+var exporter atomic.Pointer[Exporter]
+
+// New root spans are only sampled while an exporter is set, nil disables it again
+func SetExporter(e Exporter) {
+	if e == nil {
+		exporter.Store(nil)
+		return
+	}
+	exporter.Store(&e)
+}
+
+func currentExporter() Exporter {
+	if e := exporter.Load(); e != nil {
+		return *e
+	}
+	return nil
+}
+
+type spanCtxKey struct{}
+type remoteCtxKey struct{}
+
+// Child of the span in ctx (or the remote parent from Extract), a new trace otherwise.
+// Attributes are key-value pairs like the logger's arguments.
+func Start(ctx context.Context, name string, attrs ...any) (context.Context, *Span) {
+	return StartSpan(ctx, KindInternal, name, attrs...)
+}
+
+func StartSpan(ctx context.Context, kind SpanKind, name string, attrs ...any) (context.Context, *Span) {
+	span := &Span{Name: name, Kind: kind, StartTime: time.Now()}
+
+	parent := SpanContextFromContext(ctx)
+	if parent.IsValid() {
+		span.SpanContext = parent
+		span.Parent = parent.SpanID
+	} else {
+		span.SpanContext = SpanContext{TraceID: newTraceID(), Sampled: currentExporter() != nil}
+	}
+	span.SpanContext.SpanID = newSpanID()
+	span.SetAttributes(attrs...)
+
+	return context.WithValue(ctx, spanCtxKey{}, span), span
+}
+
+// The active span, nil if there is none
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanCtxKey{}).(*Span)
+	return span
+}
+
+// Of the active span, or the remote parent if no span was started yet
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext
+	}
+	sc, _ := ctx.Value(remoteCtxKey{}).(SpanContext)
+	return sc
+}
+
+// Trace ID of the active span, empty if there is none (e.g. for log correlation)
+func TraceIDFromContext(ctx context.Context) string {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return ""
+	}
+	return sc.TraceID.String()
+}
+
+func (s *Span) SetName(name string) {
+	s.mu.Lock()
+	s.Name = name
+	s.mu.Unlock()
+}
+
+func (s *Span) SetAttributes(attrs ...any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < len(attrs); i += 2 {
+		key := fmt.Sprint(attrs[i])
+		var value any = "!MISSING"
+		if i+1 < len(attrs) {
+			value = attrs[i+1]
+		}
+		s.attributes = append(s.attributes, Attribute{Key: key, Value: value})
+	}
+}
+
+func (s *Span) Attributes() []Attribute {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Attribute(nil), s.attributes...)
+}
+
+// Marks the span as failed, nil is ignored
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+func (s *Span) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Hands the span to the exporter if it is sampled, only the first call counts
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.EndTime = time.Now()
+	s.mu.Unlock()
+
+	if !s.SpanContext.Sampled {
+		return
+	}
+	if e := currentExporter(); e != nil {
+		e.ExportSpan(s)
+	}
+}
+
+// Span IDs only need to be unique, not unpredictable
+func newTraceID() TraceID {
+	var t TraceID
+	for !t.IsValid() {
+		binary.BigEndian.PutUint64(t[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(t[8:], rand.Uint64())
+	}
+	return t
+}
+
+func newSpanID() SpanID {
+	var s SpanID
+	for !s.IsValid() {
+		binary.BigEndian.PutUint64(s[:], rand.Uint64())
+	}
+	return s
+}
+
+// Keeps ended spans in memory, e.g. for tests
+type SpanRecorder struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func (r *SpanRecorder) ExportSpan(span *Span) {
+	r.mu.Lock()
+	r.spans = append(r.spans, span)
+	r.mu.Unlock()
+}
+
+// In the order they ended
+func (r *SpanRecorder) Spans() []*Span {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Span(nil), r.spans...)
+}
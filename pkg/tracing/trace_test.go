@@ -0,0 +1,123 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func withRecorder(t *testing.T) *SpanRecorder {
+	t.Helper()
+	recorder := &SpanRecorder{}
+	SetExporter(recorder)
+	t.Cleanup(func() { SetExporter(nil) })
+	return recorder
+}
+
+func TestStart_ChildSpans(t *testing.T) {
+	recorder := withRecorder(t)
+
+	ctx, root := Start(context.Background(), "root", "clientId", 1)
+	_, child := Start(ctx, "child")
+	child.RecordError(errors.New("boom"))
+	child.End()
+	root.End()
+	root.End()
+
+	spans := recorder.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if child.SpanContext.TraceID != root.SpanContext.TraceID || child.Parent != root.SpanContext.SpanID {
+		t.Errorf("expected child of root, got trace %s parent %s", child.SpanContext.TraceID, child.Parent)
+	}
+	if root.Parent.IsValid() || !root.SpanContext.Sampled {
+		t.Errorf("expected sampled root span, got parent %s sampled %v", root.Parent, root.SpanContext.Sampled)
+	}
+	if child.Err() == nil || root.Err() != nil {
+		t.Errorf("expected only the child to fail, got %v and %v", child.Err(), root.Err())
+	}
+	if attrs := root.Attributes(); len(attrs) != 1 || attrs[0].Key != "clientId" || attrs[0].Value != 1 {
+		t.Errorf("unexpected attributes %v", attrs)
+	}
+}
+
+func TestStart_NotSampledWithoutExporter(t *testing.T) {
+	SetExporter(nil)
+	ctx, span := Start(context.Background(), "root")
+	span.End()
+
+	if span.SpanContext.Sampled || !span.SpanContext.IsValid() {
+		t.Errorf("expected valid unsampled span, got %+v", span.SpanContext)
+	}
+	if TraceIDFromContext(ctx) != span.SpanContext.TraceID.String() {
+		t.Errorf("expected trace id %s, got %q", span.SpanContext.TraceID, TraceIDFromContext(ctx))
+	}
+}
+
+func TestExtractInject(t *testing.T) {
+	recorder := withRecorder(t)
+	in := http.Header{}
+	in.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	in.Set("tracestate", "vendor=1")
+
+	ctx, span := StartSpan(Extract(context.Background(), in), KindServer, "GET /v1/keys")
+	out := http.Header{}
+	Inject(ctx, out)
+	span.End()
+
+	want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + span.SpanContext.SpanID.String() + "-00"
+	if out.Get("traceparent") != want || out.Get("tracestate") != "vendor=1" {
+		t.Errorf("expected traceparent %s, got %v", want, out)
+	}
+	if span.Parent.String() != "00f067aa0ba902b7" {
+		t.Errorf("expected remote parent, got %s", span.Parent)
+	}
+	// the caller decided not to sample
+	if len(recorder.Spans()) != 0 {
+		t.Errorf("expected no exported spans, got %d", len(recorder.Spans()))
+	}
+}
+
+func TestInject_NoSpan(t *testing.T) {
+	header := http.Header{}
+	Inject(context.Background(), header)
+	if len(header) != 0 {
+		t.Errorf("expected no headers, got %v", header)
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	sc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || !sc.Sampled {
+		t.Errorf("unexpected span context %+v", sc)
+	}
+	if sc.Traceparent() != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("unexpected traceparent %s", sc.Traceparent())
+	}
+
+	// later versions may add fields
+	if _, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"); err != nil {
+		t.Errorf("expected future version to parse, got %v", err)
+	}
+
+	for _, value := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0x",
+	} {
+		if _, err := ParseTraceparent(value); err == nil {
+			t.Errorf("%q: expected error, got nil", value)
+		}
+	}
+}